@@ -1,66 +1,196 @@
 package main
 
 import (
+	"bufio"
 	"context"
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/exec"
 	"os/signal"
-	"path/filepath"
 	"runtime"
+	"strings"
 	"syscall"
 	"time"
 
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/term"
+	"golang.org/x/time/rate"
+
 	"codex-backup-tool/internal/api"
 	"codex-backup-tool/internal/core"
+	"codex-backup-tool/internal/middleware"
+	"codex-backup-tool/internal/util"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "key":
+			os.Exit(runKeyCommand(os.Args[2:], os.Stdin, os.Stdout, os.Stderr))
+		case "rekey":
+			os.Exit(runRekey(os.Args[2:], os.Stdin, os.Stdout, os.Stderr))
+		}
+	}
 	configPath := flag.String("config", "config.json", "配置文件路径")
+	createToken := flag.String("create-token", "", "创建指定名称的 API 令牌，打印明文后退出")
+	tokenTTL := flag.Duration("token-ttl", 0, "配合 --create-token 使用的令牌有效期，0 表示永不过期")
+	debugBundle := flag.String("debug-bundle", "", "生成脱敏诊断包（与 GET /api/debug/bundle 内容相同）写入指定的 zip 文件后退出，需先停止服务")
+	logLevel := flag.String("log-level", "", "日志级别（debug、info、warn、error），覆盖配置文件中的 log_level")
+	hashPassword := flag.Bool("hash-password", false, "从标准输入读取密码，打印可用于 ui_password_hash 的 bcrypt 哈希后退出")
 	flag.Parse()
-	logger := log.New(os.Stdout, "[codex-backup] ", log.LstdFlags)
+	if *hashPassword {
+		if err := runHashPassword(os.Stdin); err != nil {
+			fmt.Fprintf(os.Stderr, "生成密码哈希失败: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	// 服务日志与命令行、HTTP 中间件的日志都以 JSON 行写到标准输出；加载配置前按 info 级别输出。
+	stdout := util.NewRedactWriter(os.Stdout)
+	errLogger := core.NewStdLogger(core.NewJSONLogger(stdout, slog.LevelInfo), slog.LevelError)
 	cfg, usedDefaults, err := core.LoadConfig(*configPath)
 	if err != nil {
-		logger.Fatalf("加载配置失败: %v", err)
+		errLogger.Fatalf("加载配置失败: %v", err)
 	}
+	if *logLevel != "" {
+		if cfg.LogLevel, err = core.ParseLogLevel(*logLevel); err != nil {
+			errLogger.Fatalf("解析 -log-level 失败: %v", err)
+		}
+	}
+	svcLogger := core.NewJSONLogger(stdout, cfg.LogLevel)
+	logger, warnLogger, errLogger := newStdLoggers(svcLogger)
 	if usedDefaults {
 		logger.Printf("未找到配置文件 %s，使用默认配置", *configPath)
 	} else {
 		logger.Printf("已加载配置文件 %s", *configPath)
 	}
-	svc, err := core.NewService(cfg, logger)
+	for _, w := range cfg.Warnings {
+		warnLogger.Printf("配置警告: %s", w)
+	}
+	if *createToken != "" {
+		if err := runCreateToken(cfg, *createToken, *tokenTTL); err != nil {
+			errLogger.Fatalf("创建令牌失败: %v", err)
+		}
+		return
+	}
+	if err := promptKeyringFallback(&cfg, warnLogger); err != nil {
+		errLogger.Fatalf("读取加密口令失败: %v", err)
+	}
+	svc, err := core.NewService(cfg, svcLogger)
+	if err != nil {
+		errLogger.Fatalf("初始化服务失败: %v", err)
+	}
+	if *debugBundle != "" {
+		err := runDebugBundle(svc, *debugBundle)
+		svc.Stop()
+		if err != nil {
+			errLogger.Fatalf("生成诊断包失败: %v", err)
+		}
+		logger.Printf("诊断包已写入 %s", *debugBundle)
+		return
+	}
+	if err := svc.CheckExposure(); err != nil {
+		if !errors.Is(err, core.ErrUnauthenticatedRemote) {
+			errLogger.Fatalf("检查认证配置失败: %v", err)
+		}
+		warnUnauthenticatedRemote(warnLogger, cfg.BindHost)
+		if cfg.RequireAuthForRemote {
+			errLogger.Fatalf("拒绝在 %q 上无认证启动：请使用 --create-token 创建令牌或配置 ui_password_hash，"+
+				"或将 bind_host 改为 127.0.0.1；确需开放可设置 security.require_auth_for_remote=false", cfg.BindHost)
+		}
+	}
+	tlsConfig, err := cfg.ServerTLSConfig()
 	if err != nil {
-		logger.Fatalf("初始化服务失败: %v", err)
+		svc.Stop()
+		errLogger.Fatalf("加载 TLS 配置失败: %v", err)
 	}
+	// 在启动后台任务和打开浏览器之前同步监听，端口被占用等错误可以直接报告。
+	addr := net.JoinHostPort(cfg.BindHost, cfg.Port)
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		svc.Stop()
+		errLogger.Fatalf("监听 %s 失败: %v", addr, err)
+	}
+	var healthListener net.Listener
+	if cfg.HealthAddr != "" {
+		healthListener, err = net.Listen("tcp", cfg.HealthAddr)
+		if err != nil {
+			listener.Close()
+			svc.Stop()
+			errLogger.Fatalf("健康检查服务监听 %s 失败: %v", cfg.HealthAddr, err)
+		}
+	}
+
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
-	defer stop()
 	svc.Start(ctx)
-	defer svc.Stop()
 
 	mux := http.NewServeMux()
-	api.New(svc).Register(mux)
-	mountStatic(mux)
+	handlers := api.New(svc)
+	handlers.Register(mux)
+	mux.Handle("/", api.StaticHandler(os.DirFS("web")))
 
-	addr := fmt.Sprintf(":%s", cfg.Port)
-	srv := &http.Server{Addr: addr, Handler: loggingMiddleware(logger, mux)}
+	// 由内向外：认证、限流（未认证的请求同样计数）、CORS（预检请求不携带凭据，须在认证之前应答）、
+	// 访问日志、panic 恢复、追踪、请求 ID。
+	handler := rateLimitAPI(cfg, handlers.RequireAuth(mux))
+	handler = handlers.LoggingMiddleware(logger, corsMiddleware(cfg.CORS, handler))
+	handler = handlers.Tracing(mux, handlers.Recover(errLogger, handler))
+	srv := &http.Server{Addr: addr, Handler: api.WithRequestID(handler), TLSConfig: tlsConfig}
+	// Shutdown 不会中断长连接的事件流，需主动结束，否则要等到关闭超时。
+	srv.RegisterOnShutdown(svc.CloseEventStreams)
 
+	// serveErr 接收服务异常退出的错误，由下方的 select 统一走正常关闭流程；
+	// ready 在监听器开始接受连接后关闭，用于控制浏览器打开时机。
+	serveErr := make(chan error, 2)
+	ready := make(chan struct{})
 	go func() {
-		logger.Printf("HTTP 服务启动，监听 %s", addr)
-		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
-			logger.Fatalf("HTTP 服务异常退出: %v", err)
+		var err error
+		close(ready)
+		if tlsConfig != nil {
+			logger.Printf("HTTPS 服务启动，监听 %s（客户端证书校验: %t）", listener.Addr(), cfg.TLSClientCAFile != "")
+			err = srv.ServeTLS(listener, cfg.TLSCertFile, cfg.TLSKeyFile)
+		} else {
+			logger.Printf("HTTP 服务启动，监听 %s", listener.Addr())
+			err = srv.Serve(listener)
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serveErr <- fmt.Errorf("HTTP 服务异常退出: %w", err)
 		}
 	}()
 
+	var healthSrv *http.Server
+	if healthListener != nil {
+		healthSrv = &http.Server{Addr: cfg.HealthAddr, Handler: handlers.HealthHandler()}
+		go func() {
+			logger.Printf("健康检查服务启动，监听 %s", healthListener.Addr())
+			if err := healthSrv.Serve(healthListener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				serveErr <- fmt.Errorf("健康检查服务异常退出: %w", err)
+			}
+		}()
+	}
+
 	if cfg.AutoOpenBrowser {
 		go func() {
-			time.Sleep(400 * time.Millisecond)
-			url := fmt.Sprintf("http://localhost:%s", cfg.Port)
+			select {
+			case <-ready:
+			case <-ctx.Done():
+				return
+			}
+			scheme := "http"
+			if tlsConfig != nil {
+				scheme = "https"
+			}
+			_, port, _ := net.SplitHostPort(listener.Addr().String())
+			url := scheme + "://" + net.JoinHostPort(browserHost(cfg.BindHost), port)
 			if err := openBrowser(url); err != nil {
-				logger.Printf("自动打开浏览器失败: %v", err)
+				warnLogger.Printf("自动打开浏览器失败: %v", err)
 			} else {
 				logger.Printf("已尝试在浏览器打开 %s", url)
 			}
@@ -69,31 +199,374 @@ func main() {
 		logger.Println("已禁用自动打开浏览器，可手动访问服务页面")
 	}
 
-	<-ctx.Done()
+	exitCode := 0
+	select {
+	case <-ctx.Done():
+	case err := <-serveErr:
+		errLogger.Printf("%v，正在关闭服务", err)
+		exitCode = 1
+	}
+	stop()
 	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+	if healthSrv != nil {
+		_ = healthSrv.Shutdown(shutdownCtx)
+	}
 	if err := srv.Shutdown(shutdownCtx); err != nil {
-		logger.Printf("HTTP 优雅关闭失败: %v", err)
+		warnLogger.Printf("HTTP 优雅关闭失败: %v", err)
 	} else {
 		logger.Println("HTTP 服务已停止")
 	}
+	cancel()
+	svc.Stop()
+	if exitCode != 0 {
+		os.Exit(exitCode)
+	}
+}
+
+// newStdLoggers 返回写入 l 的 Info、Warn 与 Error 级别的标准库 logger。启动失败（Fatalf）、服务异常退出与 panic
+// 使用 Error 级别，安全警告与可恢复的失败使用 Warn 级别，因此 log_level 为 warn 或 error 时它们仍会输出。
+func newStdLoggers(l core.Logger) (info, warn, errLog *log.Logger) {
+	return core.NewStdLogger(l, slog.LevelInfo), core.NewStdLogger(l, slog.LevelWarn), core.NewStdLogger(l, slog.LevelError)
+}
+
+// warnUnauthenticatedRemote 输出醒目的多行警告，提醒凭据恢复接口正暴露给其他主机。
+func warnUnauthenticatedRemote(logger *log.Logger, host string) {
+	logger.Println("!!! ==================================================================")
+	logger.Printf("!!! 警告：服务监听非回环地址 %q，但未配置任何认证方式", host)
+	logger.Println("!!! 同一网络中的任何人都可以读取、恢复或删除 Codex 凭据备份")
+	logger.Println("!!! 请使用 --create-token 创建 API 令牌，或配置 ui_password_hash 启用密码登录")
+	logger.Println("!!! ==================================================================")
+}
+
+// rateLimitAPI 在配置了 rate_limit_rps 时为 /api/ 请求加上共用的令牌桶限流，其他路径不受影响。
+func rateLimitAPI(cfg core.Config, next http.Handler) http.Handler {
+	if cfg.RateLimitRPS <= 0 {
+		return next
+	}
+	limited := middleware.RateLimitMiddleware(rate.NewLimiter(rate.Limit(cfg.RateLimitRPS), cfg.RateLimitBurst), next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/api/") {
+			limited.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
 }
 
-func mountStatic(mux *http.ServeMux) {
-	webDir := "web"
-	serveFile := func(path string) string {
-		return filepath.Join(webDir, path)
+// corsAllowMethods 与 corsAllowHeaders 为预检请求应答中允许的方法与请求头，覆盖 API 用到的全部方法与自定义头。
+const (
+	corsAllowMethods = "GET, HEAD, POST, PUT, PATCH, DELETE"
+	corsAllowHeaders = "Authorization, Content-Type, X-CSRF-Token, X-Confirm-Purge, X-Request-ID, traceparent"
+	corsMaxAge       = "600"
+)
+
+// corsMiddleware 为允许来源的请求添加 CORS 响应头并直接应答预检请求；未配置来源时原样返回 next。
+// 不在允许列表中的来源不会得到任何 CORS 响应头，由浏览器拒绝跨域读取。
+func corsMiddleware(cfg core.CORSConfig, next http.Handler) http.Handler {
+	if !cfg.Enabled() {
+		return next
 	}
-	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		if r.URL.Path != "/" {
-			http.NotFound(w, r)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Origin")
+		origin := r.Header.Get("Origin")
+		if !cfg.AllowsOrigin(origin) {
+			next.ServeHTTP(w, r)
 			return
 		}
-		http.ServeFile(w, r, serveFile("index.html"))
+		h := w.Header()
+		h.Set("Access-Control-Allow-Origin", origin)
+		if cfg.AllowCredentials {
+			h.Set("Access-Control-Allow-Credentials", "true")
+		}
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			h.Add("Vary", "Access-Control-Request-Method")
+			h.Add("Vary", "Access-Control-Request-Headers")
+			h.Set("Access-Control-Allow-Methods", corsAllowMethods)
+			h.Set("Access-Control-Allow-Headers", corsAllowHeaders)
+			h.Set("Access-Control-Max-Age", corsMaxAge)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		h.Set("Access-Control-Expose-Headers", api.RequestIDHeader+", Content-Disposition")
+		next.ServeHTTP(w, r)
 	})
-	fs := http.FileServer(http.Dir(webDir))
-	mux.Handle("/style.css", fs)
-	mux.Handle("/app.js", fs)
+}
+
+// browserHost 返回自动打开浏览器时使用的主机名，监听回环或所有网卡时使用 localhost。
+func browserHost(bindHost string) string {
+	if bindHost == "" || util.IsLoopbackHost(bindHost) {
+		return "localhost"
+	}
+	if ip := net.ParseIP(bindHost); ip != nil && ip.IsUnspecified() {
+		return "localhost"
+	}
+	return bindHost
+}
+
+// runCreateToken 签发令牌并在标准输出打印明文，用于首次启用认证或找回访问权限。
+func runCreateToken(cfg core.Config, name string, ttl time.Duration) error {
+	if ttl < 0 {
+		return fmt.Errorf("token-ttl 不能为负数: %s", ttl)
+	}
+	var expiresAt *time.Time
+	if ttl > 0 {
+		t := time.Now().UTC().Add(ttl)
+		expiresAt = &t
+	}
+	if err := util.EnsureDirMode(cfg.DataDir, cfg.DataDirMode); err != nil {
+		return fmt.Errorf("ensure data dir: %w", err)
+	}
+	tokens := core.NewTokenStore(cfg.TokensPath, util.LockOptions{Timeout: cfg.LockTimeout, StaleAfter: cfg.LockStaleAfter})
+	secret, _, err := tokens.Create(name, expiresAt)
+	audit := core.NewAuditLog(cfg.AuditPath, cfg.AuditMaxSize)
+	entry := core.AuditEntry{Op: "token.create", ItemID: name, Source: core.AuditSourceCLI}
+	entry.InstanceID, _ = core.LoadInstanceID(cfg.DataDir)
+	if err != nil {
+		entry.Outcome, entry.Error = core.AuditOutcomeFailed, err.Error()
+	}
+	if aerr := errors.Join(audit.Record(entry), audit.Close()); aerr != nil {
+		fmt.Fprintf(os.Stderr, "写入审计日志失败: %v\n", aerr)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "已创建令牌 %s，明文只显示这一次，请妥善保存：\n", name)
+	fmt.Println(secret)
+	return nil
+}
+
+// runDebugBundle 离线生成诊断包，先写入临时文件，完成后再重命名为 out。
+func runDebugBundle(svc *core.Service, out string) (err error) {
+	defer func() {
+		entry := core.AuditEntry{Op: "debug.bundle", Source: core.AuditSourceCLI, Outcome: core.AuditOutcomeOK}
+		if err != nil {
+			entry.Outcome, entry.Error = core.AuditOutcomeFailed, err.Error()
+		}
+		svc.RecordAudit(entry)
+	}()
+	tmp := out + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if err := errors.Join(svc.WriteDebugBundle(f), f.Close()); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, out)
+}
+
+// runKeyCommand 实现 key set|get|delete 子命令，管理系统凭据存储中当前数据目录的加密口令，返回进程退出码。
+// set 在终端中以不回显的方式输入两次口令，否则读取标准输入的第一行。
+func runKeyCommand(args []string, stdin *os.File, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("key", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	configPath := fs.String("config", "config.json", "配置文件路径")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "用法: key [-config config.json] set|get|delete")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+	cfg, _, err := core.LoadConfig(*configPath)
+	if err != nil {
+		fmt.Fprintf(stderr, "加载配置失败: %v\n", err)
+		return 1
+	}
+	key := core.KeyringKey{Account: cfg.DataDir}
+	switch fs.Arg(0) {
+	case "set":
+		var passphrase string
+		if passphrase, err = newPassphraseReader(stdin, stderr).read("加密口令: ", true); err == nil {
+			err = key.SetPassphrase(passphrase)
+		}
+		if err == nil {
+			fmt.Fprintf(stderr, "已将加密口令写入系统凭据存储（%s / %s）\n", core.KeyringService, key.Account)
+		}
+	case "get":
+		var passphrase string
+		if passphrase, err = key.Passphrase(); err == nil {
+			fmt.Fprintln(stdout, passphrase)
+		}
+	case "delete":
+		if err = key.DeletePassphrase(); err == nil {
+			fmt.Fprintf(stderr, "已从系统凭据存储删除 %s 的加密口令\n", key.Account)
+		}
+	default:
+		fs.Usage()
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "key %s 失败: %v\n", fs.Arg(0), err)
+		return 1
+	}
+	return 0
+}
+
+// passphraseReader 读取口令：标准输入是终端时不回显地提示输入，否则逐行读取标准输入。
+type passphraseReader struct {
+	fd     int
+	tty    bool
+	lines  *bufio.Reader
+	stderr io.Writer
+}
+
+func newPassphraseReader(stdin *os.File, stderr io.Writer) *passphraseReader {
+	fd := int(stdin.Fd())
+	return &passphraseReader{fd: fd, tty: term.IsTerminal(fd), lines: bufio.NewReader(stdin), stderr: stderr}
+}
+
+// read 读取一个非空口令；confirm 为 true 且在终端中时要求再次输入确认。
+func (p *passphraseReader) read(prompt string, confirm bool) (string, error) {
+	if !p.tty {
+		line, err := p.lines.ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return "", err
+		}
+		passphrase := strings.TrimRight(line, "\r\n")
+		if passphrase == "" {
+			return "", errors.New("口令不能为空")
+		}
+		return passphrase, nil
+	}
+	first, err := readPassword(p.fd, p.stderr, prompt)
+	if err != nil || !confirm {
+		return first, err
+	}
+	second, err := readPassword(p.fd, p.stderr, "再次输入: ")
+	if err != nil {
+		return "", err
+	}
+	if first != second {
+		return "", errors.New("两次输入的口令不一致")
+	}
+	return first, nil
+}
+
+func readPassword(fd int, stderr io.Writer, prompt string) (string, error) {
+	fmt.Fprint(stderr, prompt)
+	data, err := term.ReadPassword(fd)
+	fmt.Fprintln(stderr)
+	if err != nil {
+		return "", err
+	}
+	if len(data) == 0 {
+		return "", errors.New("口令不能为空")
+	}
+	return string(data), nil
+}
+
+// runRekey 实现 rekey 子命令：离线用新口令重新加密全部加密备份，需先停止服务，返回进程退出码。
+// 终端中依次提示输入旧口令与新口令（新口令需确认），否则从标准输入读取两行；-dry-run 时只读取旧口令并验证。
+func runRekey(args []string, stdin *os.File, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("rekey", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	configPath := fs.String("config", "config.json", "配置文件路径")
+	dryRun := fs.Bool("dry-run", false, "只验证旧口令能解密全部加密备份，不修改任何文件")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	cfg, _, err := core.LoadConfig(*configPath)
+	if err != nil {
+		fmt.Fprintf(stderr, "加载配置失败: %v\n", err)
+		return 1
+	}
+	logger := core.NewJSONLogger(util.NewRedactWriter(stderr), cfg.LogLevel)
+	if err := promptKeyringFallback(&cfg, core.NewStdLogger(logger, slog.LevelWarn)); err != nil {
+		fmt.Fprintf(stderr, "读取加密口令失败: %v\n", err)
+		return 1
+	}
+	passphrases := newPassphraseReader(stdin, stderr)
+	var opts core.RotateOptions
+	opts.DryRun = *dryRun
+	oldPassphrase, err := passphrases.read("旧口令: ", false)
+	if err != nil {
+		fmt.Fprintf(stderr, "读取旧口令失败: %v\n", err)
+		return 1
+	}
+	opts.OldPassphrase = util.SecretString(oldPassphrase)
+	if !opts.DryRun {
+		newPassphrase, err := passphrases.read("新口令: ", true)
+		if err != nil {
+			fmt.Fprintf(stderr, "读取新口令失败: %v\n", err)
+			return 1
+		}
+		opts.NewPassphrase = util.SecretString(newPassphrase)
+	}
+	svc, err := core.NewService(cfg, logger)
+	if err != nil {
+		fmt.Fprintf(stderr, "初始化服务失败: %v\n", err)
+		return 1
+	}
+	defer svc.Stop()
+	res, err := svc.RotateEncryption(opts)
+	if !opts.DryRun {
+		entry := core.AuditEntry{Op: "encryption.rotate", Source: core.AuditSourceCLI, Outcome: core.AuditOutcomeOK}
+		if err != nil {
+			entry.Outcome, entry.Error = core.AuditOutcomeFailed, err.Error()
+		}
+		svc.RecordAudit(entry)
+	}
+	if res != nil {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(res)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "轮换加密口令失败: %v\n", err)
+		return 1
+	}
+	if res.ConfigUpdateRequired {
+		fmt.Fprintln(stderr, "已改用新口令加密全部备份，请在重启服务前将配置中的加密口令改为新口令")
+	}
+	return 0
+}
+
+// promptKeyringFallback 在配置了 encryption_keyring 但系统凭据存储不可用或没有口令时，
+// 若标准输入是终端则提示输入口令并改用该口令；非交互启动时返回原错误。
+func promptKeyringFallback(cfg *core.Config, logger *log.Logger) error {
+	key, ok := cfg.EncryptionKey.(core.KeyringKey)
+	if !ok {
+		return nil
+	}
+	_, err := key.Passphrase()
+	if err == nil {
+		return nil
+	}
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return err
+	}
+	logger.Printf("无法从系统凭据存储读取加密口令: %v", err)
+	passphrase, perr := readPassword(fd, os.Stderr, "请输入加密口令: ")
+	if perr != nil {
+		return errors.Join(err, perr)
+	}
+	cfg.EncryptionKey = core.StaticKey{Secret: util.SecretString(passphrase)}
+	return nil
+}
+
+// runHashPassword 读取第一行作为密码并输出 bcrypt 哈希。
+func runHashPassword(r io.Reader) error {
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	password := strings.TrimRight(line, "\r\n")
+	if password == "" {
+		return errors.New("密码不能为空")
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(hash))
+	return nil
 }
 
 func openBrowser(url string) error {
@@ -108,22 +581,3 @@ func openBrowser(url string) error {
 	}
 	return cmd.Start()
 }
-
-func loggingMiddleware(logger *log.Logger, next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		start := time.Now()
-		rw := &responseWriter{ResponseWriter: w, status: http.StatusOK}
-		next.ServeHTTP(rw, r)
-		logger.Printf("%s %s %d %s", r.Method, r.URL.Path, rw.status, time.Since(start))
-	})
-}
-
-type responseWriter struct {
-	http.ResponseWriter
-	status int
-}
-
-func (rw *responseWriter) WriteHeader(status int) {
-	rw.status = status
-	rw.ResponseWriter.WriteHeader(status)
-}
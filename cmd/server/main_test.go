@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/zalando/go-keyring"
+
+	"codex-backup-tool/internal/core"
+)
+
+func TestCORSMiddleware(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	const origin = "http://localhost:5173"
+	handler := corsMiddleware(core.CORSConfig{AllowedOrigins: []string{origin}, AllowCredentials: true}, next)
+
+	req := httptest.NewRequest(http.MethodOptions, "/api/backups", nil)
+	req.Header.Set("Origin", origin)
+	req.Header.Set("Access-Control-Request-Method", http.MethodDelete)
+	req.Header.Set("Access-Control-Request-Headers", "authorization, content-type")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	h := rec.Header()
+	if rec.Code != http.StatusNoContent || h.Get("Access-Control-Allow-Origin") != origin ||
+		h.Get("Access-Control-Allow-Credentials") != "true" || h.Get("Access-Control-Allow-Methods") != corsAllowMethods ||
+		h.Get("Access-Control-Allow-Headers") != corsAllowHeaders {
+		t.Fatalf("unexpected preflight response: %d %v", rec.Code, h)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/backups", nil)
+	req.Header.Set("Origin", origin)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusTeapot || rec.Header().Get("Access-Control-Allow-Origin") != origin {
+		t.Fatalf("expected the request to reach the handler with CORS headers, got %d %v", rec.Code, rec.Header())
+	}
+
+	for _, method := range []string{http.MethodOptions, http.MethodGet} {
+		req = httptest.NewRequest(method, "/api/backups", nil)
+		req.Header.Set("Origin", "http://evil.example")
+		req.Header.Set("Access-Control-Request-Method", http.MethodDelete)
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusTeapot || rec.Header().Get("Access-Control-Allow-Origin") != "" || rec.Header().Get("Access-Control-Allow-Methods") != "" {
+			t.Fatalf("%s: unlisted origin must not be reflected, got %d %v", method, rec.Code, rec.Header())
+		}
+	}
+
+	disabled := corsMiddleware(core.CORSConfig{}, next)
+	req = httptest.NewRequest(http.MethodOptions, "/api/backups", nil)
+	req.Header.Set("Origin", origin)
+	req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+	rec = httptest.NewRecorder()
+	disabled.ServeHTTP(rec, req)
+	if rec.Code != http.StatusTeapot || len(rec.Header()) != 0 {
+		t.Fatalf("expected a no-op without allowed origins, got %d %v", rec.Code, rec.Header())
+	}
+}
+
+func TestRateLimitAPIOnlyLimitsAPIRoutes(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) })
+	handler := rateLimitAPI(core.Config{RateLimitRPS: 0.001, RateLimitBurst: 1}, next)
+	codes := func(path string) []int {
+		var out []int
+		for i := 0; i < 3; i++ {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+			out = append(out, rec.Code)
+		}
+		return out
+	}
+	if got := codes("/api/status"); got[0] != http.StatusNoContent || got[1] != http.StatusTooManyRequests || got[2] != http.StatusTooManyRequests {
+		t.Fatalf("expected /api/ requests beyond the burst to be limited, got %v", got)
+	}
+	for _, code := range codes("/index.html") {
+		if code != http.StatusNoContent {
+			t.Fatalf("expected static files not to be limited, got %d", code)
+		}
+	}
+}
+
+func TestRunKeyCommand(t *testing.T) {
+	keyring.MockInit()
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(configPath, []byte(`{"data_dir":`+strconv.Quote(filepath.Join(dir, "data"))+`,"encryption_keyring":true}`), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	stdinWith := func(content string) *os.File {
+		path := filepath.Join(t.TempDir(), "stdin")
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("write stdin: %v", err)
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatalf("open stdin: %v", err)
+		}
+		t.Cleanup(func() { f.Close() })
+		return f
+	}
+	run := func(stdin string, args ...string) (int, string) {
+		var stdout, stderr bytes.Buffer
+		code := runKeyCommand(append([]string{"-config", configPath}, args...), stdinWith(stdin), &stdout, &stderr)
+		return code, stdout.String()
+	}
+
+	if code, _ := run("", "get"); code != 1 {
+		t.Fatalf("expected get to fail before set, got %d", code)
+	}
+	if code, _ := run("from stdin\n", "set"); code != 0 {
+		t.Fatalf("set: exit %d", code)
+	}
+	if code, out := run("", "get"); code != 0 || out != "from stdin\n" {
+		t.Fatalf("expected stored passphrase, got %d %q", code, out)
+	}
+	if code, _ := run("", "delete"); code != 0 {
+		t.Fatalf("delete: exit %d", code)
+	}
+	if code, _ := run("", "get"); code != 1 {
+		t.Fatalf("expected get to fail after delete, got %d", code)
+	}
+	if code, _ := run("", "rotate"); code != 2 {
+		t.Fatalf("expected usage error for an unknown action, got %d", code)
+	}
+}
+
+func TestRunRekey(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.json")
+	body := `{"codex_dir":` + strconv.Quote(filepath.Join(dir, "codex")) + `,"data_dir":` + strconv.Quote(filepath.Join(dir, "data")) +
+		`,"encryption_passphrase":"old passphrase","auto_open_browser":false}`
+	if err := os.WriteFile(configPath, []byte(body), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	cfg, _, err := core.LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(cfg.TargetPath), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(cfg.TargetPath, []byte(`{"access_token":"sk-rekey"}`), 0o600); err != nil {
+		t.Fatalf("write target: %v", err)
+	}
+	svc, err := core.NewService(cfg, core.NewJSONLogger(io.Discard, slog.LevelInfo))
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	if _, err := svc.CreateBackup(nil); err != nil {
+		t.Fatalf("create backup: %v", err)
+	}
+	svc.Stop()
+
+	run := func(stdin string, args ...string) (int, core.RotateResult) {
+		path := filepath.Join(t.TempDir(), "stdin")
+		if err := os.WriteFile(path, []byte(stdin), 0o600); err != nil {
+			t.Fatalf("write stdin: %v", err)
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatalf("open stdin: %v", err)
+		}
+		defer f.Close()
+		var stdout bytes.Buffer
+		code := runRekey(append([]string{"-config", configPath}, args...), f, &stdout, io.Discard)
+		var res core.RotateResult
+		_ = json.Unmarshal(stdout.Bytes(), &res)
+		return code, res
+	}
+	if code, _ := run("wrong\n", "-dry-run"); code != 1 {
+		t.Fatalf("expected dry run with a wrong passphrase to fail, got %d", code)
+	}
+	if code, res := run("old passphrase\n", "-dry-run"); code != 0 || !res.DryRun || len(res.Rotated) != 1 {
+		t.Fatalf("dry run: %d %+v", code, res)
+	}
+	if code, res := run("old passphrase\nnew passphrase\n"); code != 0 || len(res.Rotated) != 1 || !res.ConfigUpdateRequired {
+		t.Fatalf("rekey: %d %+v", code, res)
+	}
+	if code, res := run("old passphrase\nnew passphrase\n"); code != 0 || len(res.AlreadyRotated) != 1 || len(res.Rotated) != 0 {
+		t.Fatalf("expected a second rekey to find everything rotated: %d %+v", code, res)
+	}
+}
+
+func TestStdLoggersAtErrorLevel(t *testing.T) {
+	var buf bytes.Buffer
+	info, warn, errLog := newStdLoggers(core.NewJSONLogger(&buf, slog.LevelError))
+	info.Printf("HTTP 服务启动，监听 %s", ":8080")
+	warn.Println("!!! 警告")
+	errLog.Printf("监听 %s 失败: %v", ":8080", "address already in use")
+
+	var entries []map[string]any
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var entry map[string]any
+		if err := dec.Decode(&entry); err != nil {
+			t.Fatalf("decode log line: %v", err)
+		}
+		entries = append(entries, entry)
+	}
+	if len(entries) != 1 || entries[0]["level"] != "ERROR" || entries[0]["msg"] != "监听 :8080 失败: address already in use" {
+		t.Fatalf("expected only the error line at log_level=error, got %v", entries)
+	}
+}
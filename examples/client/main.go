@@ -0,0 +1,47 @@
+// 示例：使用 pkg/client 查询状态、创建备份并列出备份记录。
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+
+	"codex-backup-tool/internal/core"
+	"codex-backup-tool/pkg/client"
+)
+
+func main() {
+	addr := flag.String("addr", "http://localhost:8080", "服务地址")
+	remark := flag.String("remark", "", "备份备注（可选）")
+	token := flag.String("token", "", "API 令牌（服务端启用认证时必填）")
+	flag.Parse()
+
+	c := client.New(*addr, client.WithToken(*token))
+	status, err := c.Status()
+	if err != nil {
+		log.Fatalf("读取状态失败: %v", err)
+	}
+	fmt.Printf("目标文件 %s 存在=%v 大小=%d\n", status.TargetPath, status.Exists, status.Size)
+
+	var remarkPtr *string
+	if *remark != "" {
+		remarkPtr = remark
+	}
+	res, err := c.CreateBackup(remarkPtr)
+	if err != nil {
+		log.Fatalf("创建备份失败: %v", err)
+	}
+	if res.Created {
+		fmt.Printf("已创建备份 %s (%s)\n", res.Item.ID, res.Item.Remark)
+	} else {
+		fmt.Printf("未创建备份: %s\n", res.Reason)
+	}
+
+	items, err := c.ListBackups()
+	if err != nil {
+		log.Fatalf("列出备份失败: %v", err)
+	}
+	for _, item := range items {
+		fmt.Printf("%s  %-24s  %s\n", item.CreatedAt.Format("2006-01-02 15:04:05"), item.Remark, core.ShortHash(item.ContentHash))
+	}
+}
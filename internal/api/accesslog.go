@@ -0,0 +1,127 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"codex-backup-tool/internal/core"
+)
+
+// pathMatcher 在启动时由 access_log_exclude 构建，请求时只做一次 map 查找与若干前缀比较。
+type pathMatcher struct {
+	exact    map[string]struct{}
+	prefixes []string
+}
+
+// newPathMatcher 解析路径列表：以 * 结尾的项按前缀匹配，其余须完全相同。
+func newPathMatcher(patterns []string) *pathMatcher {
+	m := &pathMatcher{exact: make(map[string]struct{})}
+	for _, p := range patterns {
+		if prefix, ok := strings.CutSuffix(p, "*"); ok {
+			m.prefixes = append(m.prefixes, prefix)
+			continue
+		}
+		m.exact[p] = struct{}{}
+	}
+	return m
+}
+
+func (m *pathMatcher) match(path string) bool {
+	if _, ok := m.exact[path]; ok {
+		return true
+	}
+	for _, prefix := range m.prefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// accessEntry 为一条访问日志的内容，按配置的格式输出。
+type accessEntry struct {
+	start    time.Time
+	elapsed  time.Duration
+	status   int
+	bytes    int64
+	id       string
+	clientCN string
+	slow     bool
+}
+
+// accessFormatter 将一条访问日志格式化为不含换行的文本。
+type accessFormatter func(r *http.Request, e accessEntry) string
+
+func accessFormatterFor(format string) accessFormatter {
+	switch format {
+	case core.AccessLogFormatCombined:
+		return formatCombined
+	case core.AccessLogFormatJSON:
+		return formatJSON
+	default:
+		return formatDefault
+	}
+}
+
+func formatDefault(r *http.Request, e accessEntry) string {
+	prefix := ""
+	if e.slow {
+		prefix = "SLOW "
+	}
+	line := prefix + r.Method + " " + r.URL.Path + " " + strconv.Itoa(e.status) + " " + e.elapsed.String() + " request_id=" + e.id
+	if e.clientCN != "" {
+		line += " client_cn=" + strconv.Quote(e.clientCN)
+	}
+	return line
+}
+
+// formatCombined 输出 Apache combined 格式；用户字段为客户端证书 CN，请求行与 Referer 去掉查询参数。
+func formatCombined(r *http.Request, e accessEntry) string {
+	user := "-"
+	if e.clientCN != "" {
+		user = e.clientCN
+	}
+	size := "-"
+	if e.bytes > 0 {
+		size = strconv.FormatInt(e.bytes, 10)
+	}
+	referer := "-"
+	if ref := r.Referer(); ref != "" {
+		referer, _, _ = strings.Cut(ref, "?")
+	}
+	ua := r.UserAgent()
+	if ua == "" {
+		ua = "-"
+	}
+	return clientIP(r) + " - " + user + " [" + e.start.Format("02/Jan/2006:15:04:05 -0700") + "] " +
+		strconv.Quote(r.Method+" "+r.URL.Path+" "+r.Proto) + " " + strconv.Itoa(e.status) + " " + size + " " +
+		strconv.Quote(referer) + " " + strconv.Quote(ua)
+}
+
+func formatJSON(r *http.Request, e accessEntry) string {
+	payload, _ := json.Marshal(struct {
+		Time       string  `json:"time"`
+		Method     string  `json:"method"`
+		Path       string  `json:"path"`
+		Status     int     `json:"status"`
+		DurationMs float64 `json:"duration_ms"`
+		Bytes      int64   `json:"bytes"`
+		RequestID  string  `json:"request_id"`
+		ClientCN   string  `json:"client_cn,omitempty"`
+		Slow       bool    `json:"slow,omitempty"`
+	}{
+		Time:       e.start.UTC().Format(time.RFC3339Nano),
+		Method:     r.Method,
+		Path:       r.URL.Path,
+		Status:     e.status,
+		DurationMs: float64(e.elapsed.Microseconds()) / 1000,
+		Bytes:      e.bytes,
+		RequestID:  e.id,
+		ClientCN:   e.clientCN,
+		Slow:       e.slow,
+	})
+	return string(payload)
+}
@@ -0,0 +1,232 @@
+package api
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"codex-backup-tool/internal/util"
+)
+
+// RequireAuth 在存在 API 令牌、配置了 api_token 或登录密码时要求 /api/ 与 /metrics 请求通过认证（开启 metrics_public 时 /metrics 除外），
+// 有效的会话 Cookie 或 Bearer 令牌（tokens.json 中的令牌或 api_token）均可。都未启用时保持开放，
+// 首个令牌需通过 --create-token 命令行创建。GET 与 HEAD /api/health 只返回各组件状态，供负载均衡与监控探活，无需认证。
+func (a *API) RequireAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !a.protected(r.URL.Path) || r.URL.Path == "/api/login" || r.URL.Path == "/api/logout" ||
+			((r.Method == http.MethodGet || r.Method == http.MethodHead) && r.URL.Path == "/api/health") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		tokens := a.svc.Tokens()
+		configured, err := tokens.Configured()
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		if !configured && a.sessions == nil && a.apiToken == nil {
+			if strings.HasPrefix(r.URL.Path, "/api/tokens") && r.Method != http.MethodGet {
+				writeErrorWithMessage(w, http.StatusForbidden, "尚未配置任何令牌，请先使用 --create-token 命令行参数创建首个令牌")
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+		// 携带 Bearer 令牌的请求不依赖 Cookie，无需 CSRF 校验。
+		if secret, ok := bearerToken(r); ok {
+			if a.apiToken != nil && subtle.ConstantTimeCompare([]byte(secret), a.apiToken) == 1 {
+				next.ServeHTTP(w, r)
+				return
+			}
+			name, ok, err := tokens.Authenticate(secret)
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, err)
+				return
+			}
+			if !ok {
+				a.unauthorized(w, "访问令牌无效或已过期", `, error="invalid_token"`)
+				return
+			}
+			tokens.TouchAsync(name, time.Now())
+			next.ServeHTTP(w, r)
+			return
+		}
+		if a.sessions != nil {
+			if id := sessionIDFromRequest(r); id != "" {
+				if csrf, ok := a.sessions.touch(id); ok {
+					// 浏览器会自动附带 Cookie，写请求必须额外证明来自本站页面。
+					if isUnsafeMethod(r.Method) && !validCSRF(r, csrf) {
+						writeErrorCode(w, http.StatusForbidden, CodeCSRFFailed, "CSRF 校验失败，请刷新页面后重试")
+						return
+					}
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+		}
+		a.unauthorized(w, "缺少访问令牌", "")
+	})
+}
+
+// protected 判断路径是否需要认证：/api/ 下的全部路径，以及未开启 metrics_public 时的 /metrics。
+func (a *API) protected(path string) bool {
+	return strings.HasPrefix(path, "/api/") || (path == "/metrics" && !a.metricsPublic)
+}
+
+// unauthorized 返回 401，data.password_login 告知前端是否可以改用密码登录。
+func (a *API) unauthorized(w http.ResponseWriter, msg, challengeExtra string) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="codex-backup"`+challengeExtra)
+	writeJSON(w, http.StatusUnauthorized, response{
+		Ok:    false,
+		Error: msg,
+		Data:  map[string]bool{"password_login": a.sessions != nil},
+	})
+}
+
+func (a *API) handleLogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		notAllowed(w, http.MethodPost)
+		return
+	}
+	if a.sessions == nil {
+		writeErrorWithMessage(w, http.StatusNotFound, "未启用密码登录")
+		return
+	}
+	var req struct {
+		Password util.SecretString `json:"password"`
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	// 无论成功与否都作废请求携带的旧会话，防止会话固定攻击。
+	if old := sessionIDFromRequest(r); old != "" {
+		a.sessions.revoke(old)
+	}
+	if bcrypt.CompareHashAndPassword(a.passwordHash, []byte(req.Password.Reveal())) != nil {
+		a.audit(r, "login", "", errors.New("invalid password"))
+		setSessionCookie(w, r, "", -1)
+		writeErrorWithMessage(w, http.StatusUnauthorized, "密码错误")
+		return
+	}
+	id, csrf, err := a.sessions.create()
+	a.audit(r, "login", "", err)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	setSessionCookie(w, r, id, 0)
+	writeOK(w, map[string]interface{}{
+		"idle_timeout_seconds": int64(a.sessions.idle / time.Second),
+		"csrf_token":           csrf,
+	})
+}
+
+// handleCSRF 返回当前会话绑定的 CSRF 令牌，供页面刷新后重新获取。
+func (a *API) handleCSRF(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		notAllowed(w, http.MethodGet)
+		return
+	}
+	if a.sessions == nil {
+		writeErrorWithMessage(w, http.StatusNotFound, "未启用密码登录")
+		return
+	}
+	id := sessionIDFromRequest(r)
+	csrf, ok := a.sessions.touch(id)
+	if id == "" || !ok {
+		writeErrorWithMessage(w, http.StatusBadRequest, "当前请求未使用会话登录，无需 CSRF 令牌")
+		return
+	}
+	writeOK(w, map[string]string{"csrf_token": csrf})
+}
+
+func (a *API) handleLogout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		notAllowed(w, http.MethodPost)
+		return
+	}
+	if a.sessions != nil {
+		if id := sessionIDFromRequest(r); id != "" {
+			if csrf, ok := a.sessions.touch(id); ok && !validCSRF(r, csrf) {
+				writeErrorCode(w, http.StatusForbidden, CodeCSRFFailed, "CSRF 校验失败，请刷新页面后重试")
+				return
+			}
+			a.sessions.revoke(id)
+		}
+	}
+	setSessionCookie(w, r, "", -1)
+	writeOK(w, map[string]bool{"logged_out": true})
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	header := r.Header.Get("Authorization")
+	scheme, token, found := strings.Cut(header, " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+func (a *API) handleTokensRoot(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		infos, err := a.svc.Tokens().List()
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeOK(w, infos)
+	case http.MethodPost:
+		var req struct {
+			Name             string `json:"name"`
+			ExpiresInSeconds int64  `json:"expires_in_seconds"`
+		}
+		if !decodeJSON(w, r, &req) {
+			return
+		}
+		if req.ExpiresInSeconds < 0 {
+			writeErrorWithMessage(w, http.StatusBadRequest, "expires_in_seconds 不能为负数")
+			return
+		}
+		var expiresAt *time.Time
+		if req.ExpiresInSeconds > 0 {
+			t := time.Now().UTC().Add(time.Duration(req.ExpiresInSeconds) * time.Second)
+			expiresAt = &t
+		}
+		secret, info, err := a.svc.Tokens().Create(req.Name, expiresAt)
+		a.audit(r, "token.create", req.Name, err)
+		if err != nil {
+			status, msg := mapServiceError(err)
+			writeErrorWithMessage(w, status, msg)
+			return
+		}
+		writeOK(w, map[string]interface{}{"token": secret, "info": info})
+	default:
+		notAllowed(w, http.MethodGet, http.MethodPost)
+	}
+}
+
+func (a *API) handleTokenByName(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/api/tokens/")
+	if name == "" || strings.Contains(name, "/") {
+		writeErrorWithMessage(w, http.StatusBadRequest, "无效的令牌名称")
+		return
+	}
+	if r.Method != http.MethodDelete {
+		notAllowed(w, http.MethodDelete)
+		return
+	}
+	err := a.svc.Tokens().Delete(name)
+	a.audit(r, "token.delete", name, err)
+	if err != nil {
+		status, msg := mapServiceError(err)
+		writeErrorWithMessage(w, status, msg)
+		return
+	}
+	writeOK(w, map[string]string{"deleted": name})
+}
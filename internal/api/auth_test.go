@@ -0,0 +1,351 @@
+package api_test
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"codex-backup-tool/internal/api"
+	"codex-backup-tool/internal/core"
+	"codex-backup-tool/internal/util"
+)
+
+func newAuthServer(t *testing.T, maxSessions int) *httptest.Server {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte("s3cret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("hash: %v", err)
+	}
+	base := t.TempDir()
+	dataDir := filepath.Join(base, "data")
+	svc, err := core.NewService(core.Config{
+		TargetPath:     filepath.Join(base, "codex", "auth.json"),
+		DataDir:        dataDir,
+		BackupsDir:     filepath.Join(dataDir, "backups"),
+		IndexPath:      filepath.Join(dataDir, "index.json"),
+		ScanInterval:   time.Minute,
+		UIPasswordHash: util.SecretString(hash),
+		SessionIdle:    time.Minute,
+		MaxSessions:    maxSessions,
+	}, core.NewJSONLogger(io.Discard, slog.LevelInfo))
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	t.Cleanup(svc.Stop)
+	mux := http.NewServeMux()
+	handlers := api.New(svc)
+	handlers.Register(mux)
+	srv := httptest.NewServer(handlers.RequireAuth(mux))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+type loginResult struct {
+	status int
+	cookie *http.Cookie
+	csrf   string
+}
+
+func login(t *testing.T, srv *httptest.Server, password string, existing *http.Cookie) loginResult {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+"/api/login", strings.NewReader(`{"password":"`+password+`"}`))
+	if existing != nil {
+		req.AddCookie(existing)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	defer resp.Body.Close()
+	var body struct {
+		Data struct {
+			CSRFToken string `json:"csrf_token"`
+		} `json:"data"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&body)
+	res := loginResult{status: resp.StatusCode, csrf: body.Data.CSRFToken}
+	for _, c := range resp.Cookies() {
+		if c.Name == "codex_backup_session" && c.MaxAge >= 0 {
+			res.cookie = c
+		}
+	}
+	return res
+}
+
+func doWithSession(t *testing.T, method, url string, cookie *http.Cookie, csrf string) (int, string) {
+	t.Helper()
+	req, _ := http.NewRequest(method, url, nil)
+	req.AddCookie(cookie)
+	if csrf != "" {
+		req.Header.Set("X-CSRF-Token", csrf)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, url, err)
+	}
+	defer resp.Body.Close()
+	var body struct {
+		Code string `json:"code"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&body)
+	return resp.StatusCode, body.Code
+}
+
+func getStatus(t *testing.T, srv *httptest.Server, cookie *http.Cookie) int {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/api/status", nil)
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestSessionLoginAndLogout(t *testing.T) {
+	srv := newAuthServer(t, 5)
+
+	if code := getStatus(t, srv, nil); code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without session, got %d", code)
+	}
+	if res := login(t, srv, "wrong", nil); res.status != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for wrong password, got %d", res.status)
+	}
+	first := login(t, srv, "s3cret", nil)
+	if first.status != http.StatusOK || first.cookie == nil || first.csrf == "" {
+		t.Fatalf("login failed: %+v", first)
+	}
+	if !first.cookie.HttpOnly || first.cookie.SameSite != http.SameSiteStrictMode {
+		t.Fatalf("session cookie must be HttpOnly and SameSite=Strict: %+v", first.cookie)
+	}
+	if code := getStatus(t, srv, first.cookie); code != http.StatusOK {
+		t.Fatalf("expected 200 with session, got %d", code)
+	}
+
+	// 重新登录必须签发新会话并作废旧会话
+	renewed := login(t, srv, "s3cret", first.cookie)
+	if renewed.cookie == nil || renewed.cookie.Value == first.cookie.Value {
+		t.Fatalf("expected a fresh session id on re-login")
+	}
+	if code := getStatus(t, srv, first.cookie); code != http.StatusUnauthorized {
+		t.Fatalf("old session should be revoked, got %d", code)
+	}
+
+	if code, _ := doWithSession(t, http.MethodPost, srv.URL+"/api/logout", renewed.cookie, renewed.csrf); code != http.StatusOK {
+		t.Fatalf("logout: %d", code)
+	}
+	if code := getStatus(t, srv, renewed.cookie); code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 after logout, got %d", code)
+	}
+}
+
+func TestSessionLimitEvictsOldest(t *testing.T) {
+	srv := newAuthServer(t, 2)
+	first := login(t, srv, "s3cret", nil)
+	second := login(t, srv, "s3cret", nil)
+	third := login(t, srv, "s3cret", nil)
+	if code := getStatus(t, srv, first.cookie); code != http.StatusUnauthorized {
+		t.Fatalf("oldest session should be evicted, got %d", code)
+	}
+	for _, res := range []loginResult{second, third} {
+		if code := getStatus(t, srv, res.cookie); code != http.StatusOK {
+			t.Fatalf("recent session should stay valid, got %d", code)
+		}
+	}
+}
+
+func TestCookieWritesRequireCSRFToken(t *testing.T) {
+	srv := newAuthServer(t, 5)
+	res := login(t, srv, "s3cret", nil)
+	url := srv.URL + "/api/backups/missing"
+
+	if code, errCode := doWithSession(t, http.MethodDelete, url, res.cookie, ""); code != http.StatusForbidden || errCode != "CSRF_FAILED" {
+		t.Fatalf("expected CSRF_FAILED without header, got %d %q", code, errCode)
+	}
+	if code, errCode := doWithSession(t, http.MethodDelete, url, res.cookie, "forged"); code != http.StatusForbidden || errCode != "CSRF_FAILED" {
+		t.Fatalf("expected CSRF_FAILED with wrong header, got %d %q", code, errCode)
+	}
+	if code, _ := doWithSession(t, http.MethodDelete, url, res.cookie, res.csrf); code != http.StatusNotFound {
+		t.Fatalf("expected request to reach handler with valid CSRF token, got %d", code)
+	}
+
+	// 页面刷新后可以通过 /api/csrf 重新取回令牌
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/api/csrf", nil)
+	req.AddCookie(res.cookie)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("csrf: %v", err)
+	}
+	defer resp.Body.Close()
+	var body struct {
+		Data struct {
+			CSRFToken string `json:"csrf_token"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil || body.Data.CSRFToken != res.csrf {
+		t.Fatalf("expected csrf token %q, got %+v %v", res.csrf, body, err)
+	}
+}
+
+func TestLoginAttemptsAreAudited(t *testing.T) {
+	srv := newAuthServer(t, 5)
+	login(t, srv, "wrong", nil)
+	ok := login(t, srv, "s3cret", nil)
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/api/audit?limit=10", nil)
+	req.AddCookie(ok.cookie)
+	req.Header.Set("User-Agent", "audit-test")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("audit: %v", err)
+	}
+	defer resp.Body.Close()
+	var body struct {
+		Data []core.AuditEntry `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(body.Data) != 2 {
+		t.Fatalf("expected two login entries, got %+v", body.Data)
+	}
+	latest, first := body.Data[0], body.Data[1]
+	if latest.Op != "login" || latest.Outcome != core.AuditOutcomeOK || first.Outcome != core.AuditOutcomeFailed {
+		t.Fatalf("unexpected entries: %+v", body.Data)
+	}
+	if latest.Source != core.AuditSourceHTTP || latest.ClientIP != "127.0.0.1" {
+		t.Fatalf("missing client info: %+v", latest)
+	}
+}
+
+func TestConfigEndpointOmitsPasswordHash(t *testing.T) {
+	srv := newAuthServer(t, 5)
+	res := login(t, srv, "s3cret", nil)
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/api/config", nil)
+	req.AddCookie(res.cookie)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("config: %v", err)
+	}
+	defer resp.Body.Close()
+	raw, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || strings.Contains(string(raw), "$2a$") || strings.Contains(string(raw), "hash") {
+		t.Fatalf("config response leaks password hash: %d %s", resp.StatusCode, raw)
+	}
+	var body struct {
+		Data core.PublicConfig `json:"data"`
+	}
+	if err := json.Unmarshal(raw, &body); err != nil || !body.Data.PasswordLogin || body.Data.MaxSessions != 5 {
+		t.Fatalf("unexpected config: %s %v", raw, err)
+	}
+}
+
+func TestStaticAPIToken(t *testing.T) {
+	const token = "0123456789abcdef-static"
+	get := func(srv *httptest.Server, auth string) int {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+"/api/status", nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("status: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	srv := newOpenServer(t, func(cfg *core.Config) { cfg.APIToken = util.SecretString(token) })
+	for _, auth := range []string{"", "Bearer wrong-token", "Basic " + token} {
+		if status := get(srv, auth); status != http.StatusUnauthorized {
+			t.Fatalf("expected 401 for %q, got %d", auth, status)
+		}
+	}
+	if status := get(srv, "Bearer "+token); status != http.StatusOK {
+		t.Fatalf("expected 200 with the configured token, got %d", status)
+	}
+
+	// 健康检查无需认证，其他方法仍需令牌。
+	resp, err := http.Get(srv.URL + "/api/health")
+	if err != nil {
+		t.Fatalf("health: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected unauthenticated GET /api/health to pass, got %d", resp.StatusCode)
+	}
+	resp, err = http.Head(srv.URL + "/api/health")
+	if err != nil {
+		t.Fatalf("health head: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected unauthenticated HEAD /api/health to pass, got %d", resp.StatusCode)
+	}
+	resp, err = http.Post(srv.URL+"/api/health", "application/json", nil)
+	if err != nil {
+		t.Fatalf("health post: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected POST /api/health to require auth, got %d", resp.StatusCode)
+	}
+
+	open := newOpenServer(t)
+	if status := get(open, ""); status != http.StatusOK {
+		t.Fatalf("expected passthrough without a configured token, got %d", status)
+	}
+}
+
+func TestMetricsRequireAuth(t *testing.T) {
+	const token = "0123456789abcdef-metrics"
+	scrape := func(srv *httptest.Server, auth string) int {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+"/metrics", nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("metrics: %v", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusOK && !strings.Contains(string(body), "backup_created_total") {
+			t.Fatalf("unexpected metrics body: %s", body)
+		}
+		return resp.StatusCode
+	}
+
+	srv := newOpenServer(t, func(cfg *core.Config) {
+		cfg.EnableMetrics = true
+		cfg.APIToken = util.SecretString(token)
+	})
+	if status := scrape(srv, ""); status != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for unauthenticated /metrics, got %d", status)
+	}
+	if status := scrape(srv, "Bearer "+token); status != http.StatusOK {
+		t.Fatalf("expected 200 with the configured token, got %d", status)
+	}
+
+	public := newOpenServer(t, func(cfg *core.Config) {
+		cfg.EnableMetrics = true
+		cfg.MetricsPublic = true
+		cfg.APIToken = util.SecretString(token)
+	})
+	if status := scrape(public, ""); status != http.StatusOK {
+		t.Fatalf("expected metrics_public to allow unauthenticated /metrics, got %d", status)
+	}
+}
@@ -0,0 +1,114 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// CodeInvalidBody 为请求体为空、不是合法 JSON 对象或字段类型不符时响应中的错误代码。
+const CodeInvalidBody = "INVALID_BODY"
+
+// CodeUnsupportedMediaType 为请求体的 Content-Type 不是 JSON 时响应中的错误代码。
+const CodeUnsupportedMediaType = "UNSUPPORTED_MEDIA_TYPE"
+
+// decodeJSON 将必须提供的 JSON 对象请求体解析到 v，失败时写入 400/415 响应并返回 false。
+func decodeJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	return decodeBody(w, r, v, false)
+}
+
+// decodeOptionalJSON 与 decodeJSON 相同，但允许请求体为空，此时 v 保持零值。
+func decodeOptionalJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	return decodeBody(w, r, v, true)
+}
+
+func decodeBody(w http.ResponseWriter, r *http.Request, v interface{}, allowEmpty bool) bool {
+	if ct := r.Header.Get("Content-Type"); ct != "" && !isJSONMediaType(ct) {
+		writeErrorCode(w, http.StatusUnsupportedMediaType, CodeUnsupportedMediaType, "Content-Type 必须为 application/json")
+		return false
+	}
+	var body []byte
+	if r.Body != nil {
+		defer r.Body.Close()
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeErrorCode(w, http.StatusBadRequest, CodeInvalidBody, fmt.Sprintf("读取请求体失败: %v", err))
+			return false
+		}
+		body = bytes.TrimSpace(data)
+	}
+	if len(body) == 0 {
+		if allowEmpty {
+			return true
+		}
+		writeErrorCode(w, http.StatusBadRequest, CodeInvalidBody, "请求体不能为空，应为 JSON 对象")
+		return false
+	}
+	if body[0] != '{' {
+		writeErrorCode(w, http.StatusBadRequest, CodeInvalidBody, "请求体必须是 JSON 对象")
+		return false
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		writeErrorCode(w, http.StatusBadRequest, CodeInvalidBody, describeJSONError(err))
+		return false
+	}
+	return true
+}
+
+// isJSONMediaType 判断 Content-Type 是否为 application/json 或 +json 后缀的类型。
+func isJSONMediaType(ct string) bool {
+	mediaType, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// describeJSONError 将 encoding/json 的错误转换为指明位置或字段的提示，不直接暴露 Go 类型名。
+func describeJSONError(err error) string {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		return fmt.Sprintf("请求体不是合法的 JSON（第 %d 字节附近）", syntaxErr.Offset)
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		return fmt.Sprintf("字段 %s 应为 %s，实际为 %s", typeErr.Field, jsonTypeName(typeErr.Type), typeErr.Value)
+	case errors.As(err, &typeErr):
+		return fmt.Sprintf("请求体应为 %s，实际为 %s", jsonTypeName(typeErr.Type), typeErr.Value)
+	default:
+		return "请求体不是合法的 JSON"
+	}
+}
+
+// jsonTypeName 返回 Go 类型对应的 JSON 类型名。
+func jsonTypeName(t reflect.Type) string {
+	if t == nil {
+		return "value"
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Struct, reflect.Map:
+		return "object"
+	default:
+		return "value"
+	}
+}
@@ -0,0 +1,74 @@
+package api
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+	"strings"
+)
+
+//go:generate go run gen_swagger.go
+
+// docsFS 内嵌 docs/ 下的文档页面、接口描述与由 gen_swagger.go 放入的 swagger-ui-dist 资源，页面不依赖外部 CDN。
+//
+//go:embed docs
+var docsFS embed.FS
+
+// handleDocs 提供内嵌的 Swagger UI 页面及其脚本、样式，页面从 /api/openapi.json 加载接口描述。
+func (a *API) handleDocs(w http.ResponseWriter, r *http.Request) {
+	if !a.svc.Config().DocsEnabled {
+		http.NotFound(w, r)
+		return
+	}
+	switch r.URL.Path {
+	case "/api/docs":
+		http.Redirect(w, r, "/api/docs/", http.StatusMovedPermanently)
+	case "/api/docs/":
+		a.serveDoc(w, r, "docs/index.html", "text/html; charset=utf-8")
+	default:
+		a.serveDocAsset(w, r, strings.TrimPrefix(r.URL.Path, "/api/docs/"))
+	}
+}
+
+func (a *API) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	if !a.svc.Config().DocsEnabled {
+		http.NotFound(w, r)
+		return
+	}
+	a.serveDoc(w, r, "docs/openapi.json", "application/json; charset=utf-8")
+}
+
+func (a *API) serveDoc(w http.ResponseWriter, r *http.Request, name, contentType string) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		notAllowed(w, http.MethodGet, http.MethodHead)
+		return
+	}
+	data, err := docsFS.ReadFile(name)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	_, _ = w.Write(data)
+}
+
+// serveDocAsset 提供 docs/ 下的其他文件（swagger-ui.css、swagger-ui-bundle.js 等），路径检查与 StaticHandler 相同。
+func (a *API) serveDocAsset(w http.ResponseWriter, r *http.Request, urlPath string) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		notAllowed(w, http.MethodGet, http.MethodHead)
+		return
+	}
+	name, ok := staticName(urlPath)
+	if !ok || strings.Contains(name, "/") {
+		http.NotFound(w, r)
+		return
+	}
+	assets, err := fs.Sub(docsFS, "docs")
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if !serveStaticFile(w, r, assets, name) {
+		http.NotFound(w, r)
+	}
+}
@@ -4,29 +4,85 @@ import (
 	"encoding/json"
 	"errors"
 	"io"
+	"mime"
+	"net"
 	"net/http"
+	"net/url"
+	"path"
+	"strconv"
 	"strings"
+	"time"
 
 	"codex-backup-tool/internal/core"
+	"codex-backup-tool/internal/util"
 )
 
 // API 聚合 HTTP 处理逻辑。
 type API struct {
 	svc *core.Service
+	// sessions 仅在配置了 ui_password_hash 时非空。
+	sessions     *sessionStore
+	passwordHash []byte
+	// apiToken 为配置中的 api_token，未配置时为空。
+	apiToken []byte
+	// metricsPublic 为配置中的 metrics_public，为 true 时 /metrics 无需认证。
+	metricsPublic bool
 }
 
 // New 构造 API。
 func New(svc *core.Service) *API {
-	return &API{svc: svc}
+	cfg := svc.Config()
+	a := &API{svc: svc, metricsPublic: cfg.MetricsPublic}
+	if cfg.APIToken != "" {
+		a.apiToken = []byte(cfg.APIToken.Reveal())
+	}
+	if cfg.UIPasswordHash != "" {
+		a.passwordHash = []byte(cfg.UIPasswordHash.Reveal())
+		a.sessions = newSessionStore(cfg.SessionIdle, cfg.MaxSessions)
+	}
+	return a
 }
 
 // Register 将 API 注册到 mux。
 func (a *API) Register(mux *http.ServeMux) {
 	mux.HandleFunc("/api/status", a.handleStatus)
+	mux.HandleFunc("/api/config", a.handleConfig)
+	mux.HandleFunc("/api/stats", a.handleStats)
+	mux.HandleFunc("/api/selftest", a.handleSelfTest)
+	mux.HandleFunc("/api/health", a.handleHealth)
+	mux.HandleFunc("/readyz", a.handleReadyz)
+	if reg := a.svc.Metrics(); reg != nil {
+		mux.Handle("/metrics", reg.Handler())
+	}
+	mux.HandleFunc("/api/target/history", a.handleTargetHistory)
 	mux.HandleFunc("/api/scan", a.handleScan)
+	mux.HandleFunc("/api/scan/history", a.handleScanHistory)
 	mux.HandleFunc("/api/backups", a.handleBackupsRoot)
 	mux.HandleFunc("/api/backups/", a.handleBackupByID)
 	mux.HandleFunc("/api/codex/login", a.handleCodexLogin)
+	mux.HandleFunc("/api/audit", a.handleAudit)
+	mux.HandleFunc("/api/events", a.handleEvents)
+	mux.HandleFunc("/api/events/unread", a.handleUnreadEvents)
+	mux.HandleFunc("/api/events/ack", a.handleAckEvents)
+	mux.HandleFunc("/api/diff", a.handleDiff)
+	mux.HandleFunc("/api/compare/latest", a.handleCompareLatest)
+	mux.HandleFunc("/api/index/check", a.handleIndexCheck)
+	mux.HandleFunc("/api/debug/bundle", a.handleDebugBundle)
+	mux.HandleFunc("/api/export", a.handleExport)
+	mux.HandleFunc("/api/import", a.handleImport)
+	mux.HandleFunc("/api/index/migrate-remarks", a.handleMigrateRemarks)
+	mux.HandleFunc("/api/encryption/rotate", a.handleRotateEncryption)
+	mux.HandleFunc("/api/index/accept", a.handleAcceptIndex)
+	mux.HandleFunc("/api/target/acknowledge", a.handleAcknowledgeTargetChange)
+	mux.HandleFunc("/api/login", a.handleLogin)
+	mux.HandleFunc("/api/logout", a.handleLogout)
+	mux.HandleFunc("/api/csrf", a.handleCSRF)
+	mux.HandleFunc("/api/everything", a.handlePurgeAll)
+	mux.HandleFunc("/api/tokens", a.handleTokensRoot)
+	mux.HandleFunc("/api/tokens/", a.handleTokenByName)
+	mux.HandleFunc("/api/docs", a.handleDocs)
+	mux.HandleFunc("/api/docs/", a.handleDocs)
+	mux.HandleFunc("/api/openapi.json", a.handleOpenAPI)
 }
 
 func (a *API) handleStatus(w http.ResponseWriter, r *http.Request) {
@@ -42,6 +98,119 @@ func (a *API) handleStatus(w http.ResponseWriter, r *http.Request) {
 	writeOK(w, status)
 }
 
+// HealthHandler 返回只提供 /healthz 与 /readyz 的处理器，用于无需认证的回环健康检查监听。
+func (a *API) HealthHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			notAllowed(w, http.MethodGet, http.MethodHead)
+			return
+		}
+		writeOK(w, map[string]string{"status": "ok"})
+	})
+	mux.HandleFunc("/readyz", a.handleReadyz)
+	return mux
+}
+
+// readiness 为 /readyz 的响应体；该端点无需认证，因此只列出失败项名称，详情见 /api/status。
+type readiness struct {
+	Ready    bool     `json:"ready"`
+	Warnings []string `json:"warnings"`
+}
+
+// handleReadyz 依据最近一次自检判断服务是否就绪，存在致命失败项时返回 503。
+func (a *API) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		notAllowed(w, http.MethodGet, http.MethodHead)
+		return
+	}
+	body := readiness{Ready: true, Warnings: make([]string, 0)}
+	if report := a.svc.LastSelfTest(); report != nil {
+		body.Ready = report.Ready()
+		for _, c := range report.Checks {
+			if !c.Passed && !c.Fatal {
+				body.Warnings = append(body.Warnings, c.Name)
+			}
+		}
+	}
+	status := http.StatusOK
+	if !body.Ready {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, response{Ok: body.Ready, Data: body})
+}
+
+// health 为 /api/health 的响应体，status 为 ok 或 degraded。
+type health struct {
+	Status     string            `json:"status"`
+	Components map[string]string `json:"components"`
+}
+
+// handleHealth 返回各组件的状态，存在降级组件时返回 503；目标文件缺失不算降级。
+func (a *API) handleHealth(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		notAllowed(w, http.MethodGet, http.MethodHead)
+		return
+	}
+	body := health{Status: "ok", Components: a.svc.HealthCheck()}
+	status := http.StatusOK
+	healthy := core.Healthy(body.Components)
+	if !healthy {
+		body.Status = "degraded"
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, response{Ok: healthy, Data: body})
+}
+
+// handleSelfTest 重新执行环境自检并返回完整报告。
+func (a *API) handleSelfTest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		notAllowed(w, http.MethodPost)
+		return
+	}
+	writeOK(w, a.svc.SelfTest())
+}
+
+// handleConfig 返回脱敏后的运行配置，不直接序列化 core.Config。
+func (a *API) handleConfig(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		notAllowed(w, http.MethodGet)
+		return
+	}
+	writeOK(w, a.svc.PublicConfig())
+}
+
+func (a *API) handleStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		notAllowed(w, http.MethodGet)
+		return
+	}
+	writeOK(w, a.svc.Stats())
+}
+
+// handleTargetHistory 返回最近 hours 小时内每次扫描记录的目标文件大小，供趋势图使用。
+func (a *API) handleTargetHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		notAllowed(w, http.MethodGet)
+		return
+	}
+	hours := 168
+	if v := r.URL.Query().Get("hours"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 24*365 {
+			writeErrorWithMessage(w, http.StatusBadRequest, "hours 需为 1-8760 之间的整数")
+			return
+		}
+		hours = n
+	}
+	samples, err := a.svc.TargetHistory(time.Duration(hours) * time.Hour)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeOK(w, samples)
+}
+
 func (a *API) handleScan(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		notAllowed(w, http.MethodPost)
@@ -49,12 +218,25 @@ func (a *API) handleScan(w http.ResponseWriter, r *http.Request) {
 	}
 	var req struct {
 		Remark *string `json:"remark"`
+		// Auto 默认为 true：手动触发的扫描与定时扫描一样生成 auto- 备注。
+		Auto *bool `json:"auto"`
 	}
-	if err := decodeJSON(r, &req); err != nil {
-		writeError(w, http.StatusBadRequest, err)
+	if !decodeOptionalJSON(w, r, &req) {
 		return
 	}
-	res, err := a.svc.Scan(false, req.Remark)
+	if req.Remark != nil {
+		remark, ok := a.validRemark(w, *req.Remark, false)
+		if !ok {
+			return
+		}
+		req.Remark = &remark
+	}
+	opts := core.ScanOptions{Auto: true, Remark: req.Remark, Trigger: core.BackupTriggerAPIScan}
+	if req.Auto != nil {
+		opts.Auto = *req.Auto
+	}
+	res, err := a.svc.ScanWithOptions(r.Context(), opts)
+	a.auditScan(r, res, err)
 	if err != nil {
 		status, msg := mapServiceError(err)
 		writeErrorWithMessage(w, status, msg)
@@ -66,7 +248,21 @@ func (a *API) handleScan(w http.ResponseWriter, r *http.Request) {
 func (a *API) handleBackupsRoot(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodGet:
-		items, err := a.svc.ListBackups()
+		params := r.URL.Query()
+		filter, ok := parseBackupFilter(w, params)
+		if !ok {
+			return
+		}
+		order, err := core.ParseBackupSort(params.Get("sort"), params.Get("order"))
+		if err != nil {
+			writeErrorCode(w, http.StatusBadRequest, CodeValidationFailed, "sort 仅支持 created_at、size、remark，order 仅支持 asc、desc")
+			return
+		}
+		if params.Has("cursor") || params.Has("limit") || params.Has("offset") {
+			a.listBackupsPage(w, params, filter, order)
+			return
+		}
+		items, err := a.svc.ListBackupsSorted(filter, order)
 		if err != nil {
 			writeError(w, http.StatusInternalServerError, err)
 			return
@@ -76,11 +272,18 @@ func (a *API) handleBackupsRoot(w http.ResponseWriter, r *http.Request) {
 		var req struct {
 			Remark *string `json:"remark"`
 		}
-		if err := decodeJSON(r, &req); err != nil {
-			writeError(w, http.StatusBadRequest, err)
+		if !decodeOptionalJSON(w, r, &req) {
 			return
 		}
-		res, err := a.svc.CreateBackup(req.Remark)
+		if req.Remark != nil {
+			remark, ok := a.validRemark(w, *req.Remark, false)
+			if !ok {
+				return
+			}
+			req.Remark = &remark
+		}
+		res, err := a.svc.ScanContext(r.Context(), false, req.Remark)
+		a.auditScan(r, res, err)
 		if err != nil {
 			status, msg := mapServiceError(err)
 			writeErrorWithMessage(w, status, msg)
@@ -92,47 +295,187 @@ func (a *API) handleBackupsRoot(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (a *API) handleBackupByID(w http.ResponseWriter, r *http.Request) {
-	rest := strings.TrimPrefix(r.URL.Path, "/api/backups/")
+// listBackupsPage 处理带 cursor、limit 或 offset 参数的 GET /api/backups；不带参数时仍返回完整数组以兼容旧客户端。
+func (a *API) listBackupsPage(w http.ResponseWriter, params url.Values, filter core.BackupFilter, order core.BackupSort) {
+	limit, offset := 0, 0
+	for name, dst := range map[string]*int{"limit": &limit, "offset": &offset} {
+		v := params.Get(name)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			writeErrorWithMessage(w, http.StatusBadRequest, name+" 需为整数")
+			return
+		}
+		*dst = n
+	}
+	if offset < 0 {
+		writeErrorWithMessage(w, http.StatusBadRequest, "offset 不能为负数")
+		return
+	}
+	page, err := a.svc.ListBackupsPage(filter, order, params.Get("cursor"), offset, limit)
+	if err != nil {
+		status, msg := mapServiceError(err)
+		writeErrorWithMessage(w, status, msg)
+		return
+	}
+	writeOK(w, page)
+}
+
+// parseBackupFilter 解析 GET /api/backups 的 from、to、created_after、created_before（RFC 3339）、is_auto、remark、q、
+// tag_key 与 tag_value 参数，空值表示不限制；取值无效时写入 400 与 VALIDATION_FAILED。
+func parseBackupFilter(w http.ResponseWriter, params url.Values) (core.BackupFilter, bool) {
+	f := core.BackupFilter{
+		RemarkContains: strings.TrimSpace(params.Get("remark")),
+		Query:          strings.TrimSpace(params.Get("q")),
+		TagKey:         strings.TrimSpace(params.Get("tag_key")),
+		TagValue:       params.Get("tag_value"),
+	}
+	bounds := map[string]*time.Time{
+		"from":           &f.From,
+		"to":             &f.To,
+		"created_after":  &f.CreatedAfter,
+		"created_before": &f.CreatedBefore,
+	}
+	for name, dst := range bounds {
+		v := params.Get(name)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			writeErrorCode(w, http.StatusBadRequest, CodeValidationFailed, name+" 需为 RFC 3339 时间")
+			return f, false
+		}
+		*dst = t
+	}
+	if v := params.Get("is_auto"); v != "" {
+		auto, err := strconv.ParseBool(v)
+		if err != nil {
+			writeErrorCode(w, http.StatusBadRequest, CodeValidationFailed, "is_auto 需为 true 或 false")
+			return f, false
+		}
+		f.IsAuto = &auto
+	}
+	return f, true
+}
+
+// backupActions 为 /api/backups/{id}[/{action}] 各操作允许的方法，空字符串表示不带操作的路径。
+var backupActions = map[string]string{
+	"":             http.MethodDelete,
+	"remark":       http.MethodPatch,
+	"restore":      http.MethodPost,
+	"download":     http.MethodGet,
+	"preview":      http.MethodGet,
+	"diff":         http.MethodGet,
+	"diff-current": http.MethodGet,
+	"tags":         http.MethodPatch,
+}
+
+// parseBackupPath 解析 /api/backups/ 之后的路径：逐段反转义，忽略末尾的一个斜杠。
+// 返回 nil 表示路径中含有无法反转义的片段。
+func parseBackupPath(r *http.Request) []string {
+	rest := strings.TrimPrefix(r.URL.EscapedPath(), "/api/backups/")
+	rest = strings.TrimSuffix(rest, "/")
 	if rest == "" {
+		return []string{}
+	}
+	parts := strings.Split(rest, "/")
+	for i, part := range parts {
+		seg, err := url.PathUnescape(part)
+		if err != nil {
+			return nil
+		}
+		parts[i] = seg
+	}
+	return parts
+}
+
+func (a *API) handleBackupByID(w http.ResponseWriter, r *http.Request) {
+	parts := parseBackupPath(r)
+	switch {
+	case parts == nil:
+		writeErrorWithMessage(w, http.StatusBadRequest, "无效的备份 ID")
+		return
+	case len(parts) == 0:
 		writeErrorWithMessage(w, http.StatusBadRequest, "缺少备份 ID")
 		return
+	case len(parts) == 1 && parts[0] == "prune":
+		a.pruneBackups(w, r)
+		return
+	case len(parts) == 3 && parts[0] == "remark" && parts[2] == "restore":
+		a.restoreByRemark(w, r, parts[1])
+		return
+	case len(parts) == 3 && parts[0] != "" && parts[1] == "tags":
+		a.deleteTag(w, r, parts[0], parts[2])
+		return
+	case len(parts) > 2:
+		writeErrorWithMessage(w, http.StatusNotFound, "未知操作")
+		return
 	}
-	parts := strings.Split(rest, "/")
 	id := parts[0]
 	if id == "" {
 		writeErrorWithMessage(w, http.StatusBadRequest, "无效的备份 ID")
 		return
 	}
-	if len(parts) == 1 {
-		switch r.Method {
-		case http.MethodDelete:
-			if err := a.svc.DeleteBackup(id); err != nil {
-				status, msg := mapServiceError(err)
-				writeErrorWithMessage(w, status, msg)
-				return
-			}
-			writeOK(w, map[string]string{"deleted": id})
-		default:
-			notAllowed(w, http.MethodDelete)
+	action := ""
+	if len(parts) == 2 {
+		action = parts[1]
+	}
+	method, ok := backupActions[action]
+	if !ok {
+		writeErrorWithMessage(w, http.StatusNotFound, "未知操作")
+		return
+	}
+	if r.Method != method {
+		// 备份不存在时仍返回 404，避免方法错误掩盖 ID 错误。
+		if _, err := a.svc.GetBackup(id); err != nil {
+			status, msg := mapServiceError(err)
+			writeErrorWithMessage(w, status, msg)
+			return
 		}
+		notAllowed(w, method)
 		return
 	}
-	action := parts[1]
 	switch action {
-	case "remark":
-		if r.Method != http.MethodPatch {
-			notAllowed(w, http.MethodPatch)
+	case "":
+		if !a.allow(w, core.CapDelete) {
+			return
+		}
+		err := a.svc.DeleteBackup(id)
+		a.audit(r, "backup.delete", id, err)
+		if err != nil {
+			status, msg := mapServiceError(err)
+			writeErrorWithMessage(w, status, msg)
 			return
 		}
+		writeOK(w, map[string]string{"deleted": id})
+	case "remark":
 		var req struct {
 			Remark string `json:"remark"`
+			// Clear 为 true 时恢复为按创建时间生成的默认备注，此时不能同时提供 remark。
+			Clear bool `json:"clear"`
 		}
-		if err := decodeJSON(r, &req); err != nil {
-			writeError(w, http.StatusBadRequest, err)
+		if !decodeJSON(w, r, &req) {
 			return
 		}
-		item, err := a.svc.UpdateRemark(id, req.Remark)
+		var item *core.BackupItem
+		var err error
+		if req.Clear {
+			if req.Remark != "" {
+				writeErrorCode(w, http.StatusBadRequest, CodeValidationFailed, "clear 为 true 时不能同时提供 remark")
+				return
+			}
+			item, err = a.svc.ClearRemark(id)
+		} else {
+			remark, ok := a.validRemark(w, req.Remark, false)
+			if !ok {
+				return
+			}
+			item, err = a.svc.UpdateRemark(id, remark)
+		}
+		a.audit(r, "backup.remark", id, err)
 		if err != nil {
 			status, msg := mapServiceError(err)
 			writeErrorWithMessage(w, status, msg)
@@ -140,19 +483,179 @@ func (a *API) handleBackupByID(w http.ResponseWriter, r *http.Request) {
 		}
 		writeOK(w, item)
 	case "restore":
-		if r.Method != http.MethodPost {
-			notAllowed(w, http.MethodPost)
+		if !a.allow(w, core.CapRestore, core.CapTargetWrite) {
+			return
+		}
+		item, err := a.svc.RestoreBackupContext(r.Context(), id)
+		a.audit(r, "backup.restore", id, err)
+		writeRestoreResult(w, r, id, item, err)
+	case "download":
+		a.downloadBackup(w, r, id)
+	case "preview":
+		preview, err := a.svc.PreviewBackup(id)
+		if errors.Is(err, core.ErrNotJSON) {
+			writeErrorCode(w, http.StatusUnprocessableEntity, CodeBackupNotJSON, "备份内容不是合法的 JSON，无法预览")
 			return
 		}
-		if err := a.svc.RestoreBackup(id); err != nil {
+		if err != nil {
 			status, msg := mapServiceError(err)
 			writeErrorWithMessage(w, status, msg)
 			return
 		}
+		writeOK(w, preview)
+	case "tags":
+		var req struct {
+			Key   string `json:"key"`
+			Value string `json:"value"`
+		}
+		if !decodeJSON(w, r, &req) {
+			return
+		}
+		item, err := a.svc.SetTag(id, req.Key, req.Value)
+		a.audit(r, "backup.tag", id, err)
+		if err != nil {
+			status, msg := mapServiceError(err)
+			writeErrorWithMessage(w, status, msg)
+			return
+		}
+		writeOK(w, item)
+	case "diff":
+		against := strings.TrimSpace(r.URL.Query().Get("against"))
+		if against == "" {
+			writeErrorWithMessage(w, http.StatusBadRequest, "缺少 against 参数")
+			return
+		}
+		res, err := a.svc.DiffBackups(id, against)
+		if err != nil {
+			status, msg := mapServiceError(err)
+			writeErrorWithMessage(w, status, msg)
+			return
+		}
+		writeOK(w, res)
+	case "diff-current":
+		res, err := a.svc.DiffAgainstTarget(id)
+		if err != nil {
+			status, msg := mapServiceError(err)
+			writeErrorWithMessage(w, status, msg)
+			return
+		}
+		writeOK(w, res)
+	}
+}
+
+// deleteTag 处理 DELETE /api/backups/{id}/tags/{key}。
+func (a *API) deleteTag(w http.ResponseWriter, r *http.Request, id, key string) {
+	if r.Method != http.MethodDelete {
+		notAllowed(w, http.MethodDelete)
+		return
+	}
+	item, err := a.svc.DeleteTag(id, key)
+	a.audit(r, "backup.untag", id, err)
+	if err != nil {
+		status, msg := mapServiceError(err)
+		writeErrorWithMessage(w, status, msg)
+		return
+	}
+	writeOK(w, item)
+}
+
+// restoreByRemark 处理 POST /api/backups/remark/{remark}/restore，按备注而非 ID 还原备份。
+func (a *API) restoreByRemark(w http.ResponseWriter, r *http.Request, remark string) {
+	if r.Method != http.MethodPost {
+		notAllowed(w, http.MethodPost)
+		return
+	}
+	if !a.allow(w, core.CapRestore, core.CapTargetWrite) {
+		return
+	}
+	item, err := a.svc.RestoreByRemarkContext(r.Context(), remark)
+	id := remark
+	if item != nil {
+		id = item.ID
+	}
+	a.audit(r, "backup.restore", id, err)
+	writeRestoreResult(w, r, id, item, err)
+}
+
+// pruneBackups 按请求中的规则清理自动备份，dry_run 为 true 时只返回将被删除的备份。
+func (a *API) pruneBackups(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		notAllowed(w, http.MethodPost)
+		return
+	}
+	var req struct {
+		KeepAuto      int   `json:"keep_auto"`
+		OlderThanDays int   `json:"older_than_days"`
+		MaxTotalBytes int64 `json:"max_total_bytes"`
+		DryRun        bool  `json:"dry_run"`
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if req.KeepAuto < 0 || req.OlderThanDays < 0 || req.MaxTotalBytes < 0 {
+		writeErrorCode(w, http.StatusBadRequest, CodeValidationFailed, "keep_auto、older_than_days 与 max_total_bytes 不能为负数")
+		return
+	}
+	if req.KeepAuto == 0 && req.OlderThanDays == 0 && req.MaxTotalBytes == 0 {
+		writeErrorCode(w, http.StatusBadRequest, CodeValidationFailed, "至少需要指定 keep_auto、older_than_days 或 max_total_bytes 之一")
+		return
+	}
+	if !a.allow(w, core.CapPrune) {
+		return
+	}
+	policy := core.PrunePolicy{
+		KeepAuto:      req.KeepAuto,
+		OlderThan:     time.Duration(req.OlderThanDays) * 24 * time.Hour,
+		MaxTotalBytes: req.MaxTotalBytes,
+	}
+	res, err := a.svc.PruneWithPolicy(policy, req.DryRun)
+	if !req.DryRun {
+		a.audit(r, "backup.prune", "", err)
+	}
+	if err != nil {
+		status, msg := mapServiceError(err)
+		writeErrorWithMessage(w, status, msg)
+		return
+	}
+	writeOK(w, res)
+}
+
+// writeRestoreResult 输出还原结果，?format=legacy 时返回旧格式 {"restored": id}。
+func writeRestoreResult(w http.ResponseWriter, r *http.Request, id string, item *core.BackupItem, err error) {
+	if errors.Is(err, core.ErrTargetReadOnly) {
+		writeErrorCode(w, http.StatusConflict, CodeTargetReadOnly, "目标文件为只读，已拒绝还原；如需覆盖请在配置中开启 restore_override_readonly")
+		return
+	}
+	if err != nil {
+		status, msg := mapServiceError(err)
+		writeErrorWithMessage(w, status, msg)
+		return
+	}
+	if r.URL.Query().Get("format") == "legacy" {
 		writeOK(w, map[string]string{"restored": id})
-	default:
-		writeErrorWithMessage(w, http.StatusNotFound, "未知操作")
+		return
 	}
+	writeOK(w, item)
+}
+
+// downloadBackup 以附件形式流式返回备份内容，不把整个文件读入内存；加密或压缩的备份在服务端解密、解压后以原始 JSON 返回。
+func (a *API) downloadBackup(w http.ResponseWriter, r *http.Request, id string) {
+	f, item, err := a.svc.OpenBackup(id)
+	a.audit(r, "backup.download", id, err)
+	if err != nil {
+		status, msg := mapServiceError(err)
+		writeErrorWithMessage(w, status, msg)
+		return
+	}
+	defer f.Close()
+	// OpenBackup 已解密、解压，压缩备份以去掉 .gz 的文件名下载，解压后的长度未知时不设 Content-Length。
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": path.Base(core.DecompressedFilename(item.Filename))}))
+	if item.Size >= 0 {
+		w.Header().Set("Content-Length", strconv.FormatInt(item.Size, 10))
+	}
+	// 响应头已发送，复制中途出错只能中断连接。
+	_, _ = io.Copy(w, f)
 }
 
 func (a *API) handleCodexLogin(w http.ResponseWriter, r *http.Request) {
@@ -160,6 +663,9 @@ func (a *API) handleCodexLogin(w http.ResponseWriter, r *http.Request) {
 		notAllowed(w, http.MethodPost)
 		return
 	}
+	if !a.allow(w, core.CapCodexLogin, core.CapTargetWrite) {
+		return
+	}
 	stdout, stderr, exitCode, err := a.svc.CodexLogin(r.Context())
 	payload := map[string]interface{}{"stdout": stdout, "stderr": stderr, "exit_code": exitCode}
 	if err != nil {
@@ -169,12 +675,490 @@ func (a *API) handleCodexLogin(w http.ResponseWriter, r *http.Request) {
 	writeOK(w, payload)
 }
 
+// handleAudit 返回最近的审计记录，limit 默认 100，最大 1000。
+func (a *API) handleAudit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		notAllowed(w, http.MethodGet)
+		return
+	}
+	limit := 100
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 1000 {
+			writeErrorWithMessage(w, http.StatusBadRequest, "limit 需为 1-1000 之间的整数")
+			return
+		}
+		limit = n
+	}
+	entries, err := a.svc.Audit().Recent(limit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeOK(w, entries)
+}
+
+// handleEvents 按时间倒序分页查询事件日志，since/before 为 RFC 3339 时间。
+func (a *API) handleEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		notAllowed(w, http.MethodGet)
+		return
+	}
+	if isEventStream(r) {
+		a.streamEvents(w, r)
+		return
+	}
+	params := r.URL.Query()
+	q := core.EventQuery{Type: strings.TrimSpace(params.Get("type")), Limit: 100}
+	if v := params.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 1000 {
+			writeErrorWithMessage(w, http.StatusBadRequest, "limit 需为 1-1000 之间的整数")
+			return
+		}
+		q.Limit = n
+	}
+	for name, dst := range map[string]*time.Time{"since": &q.Since, "before": &q.Before} {
+		v := params.Get(name)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			writeErrorWithMessage(w, http.StatusBadRequest, name+" 需为 RFC 3339 时间")
+			return
+		}
+		*dst = t
+	}
+	page, err := a.svc.Events().Query(q)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeOK(w, page)
+}
+
+// eventStreamKeepAlive 为事件流中注释行心跳的间隔，避免代理因长时间无数据断开连接。
+const eventStreamKeepAlive = 30 * time.Second
+
+// isEventStream 判断请求是否为 GET /api/events 的 Server-Sent Events 订阅（Accept 含 text/event-stream）。
+func isEventStream(r *http.Request) bool {
+	return r.Method == http.MethodGet && r.URL.Path == "/api/events" &&
+		strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+}
+
+// streamEvents 以 Server-Sent Events 推送此后发生的事件，每个事件为一帧 "data: <json>"，
+// 可用 ?type= 只订阅一种事件。客户端断开或服务关闭时结束。
+func (a *API) streamEvents(w http.ResponseWriter, r *http.Request) {
+	typ := strings.TrimSpace(r.URL.Query().Get("type"))
+	rc := http.NewResponseController(w)
+	sub := a.svc.SubscribeEvents()
+	defer a.svc.UnsubscribeEvents(sub)
+
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		return
+	}
+	keepAlive := time.NewTicker(eventStreamKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		var frame []byte
+		select {
+		case <-r.Context().Done():
+			return
+		case <-sub.Done():
+			return
+		case <-keepAlive.C:
+			frame = []byte(": keep-alive\n\n")
+		case e := <-sub.Events():
+			if typ != "" && e.Type != typ {
+				continue
+			}
+			data, err := json.Marshal(e)
+			if err != nil {
+				continue
+			}
+			frame = append(append([]byte("data: "), data...), "\n\n"...)
+		}
+		if _, err := w.Write(frame); err != nil {
+			return
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// handleScanHistory 返回最近的扫描记录（新的在前），previous_run 标记来自此前进程的记录。
+func (a *API) handleScanHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		notAllowed(w, http.MethodGet)
+		return
+	}
+	limit := 50
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 1000 {
+			writeErrorWithMessage(w, http.StatusBadRequest, "limit 需为 1-1000 之间的整数")
+			return
+		}
+		limit = n
+	}
+	writeOK(w, map[string]any{"records": a.svc.ScanHistory(limit)})
+}
+
+// handleUnreadEvents 返回某个消费者已读位置之后的事件与未读数量。
+func (a *API) handleUnreadEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		notAllowed(w, http.MethodGet)
+		return
+	}
+	params := r.URL.Query()
+	limit := 100
+	if v := params.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 1000 {
+			writeErrorWithMessage(w, http.StatusBadRequest, "limit 需为 1-1000 之间的整数")
+			return
+		}
+		limit = n
+	}
+	unread, err := a.svc.UnreadEvents(strings.TrimSpace(params.Get("consumer")), limit)
+	if err != nil {
+		status, msg := mapServiceError(err)
+		writeErrorWithMessage(w, status, msg)
+		return
+	}
+	writeOK(w, unread)
+}
+
+// handleAckEvents 将消费者的已读位置推进到指定事件；未知或更早的事件 ID 不报错。
+func (a *API) handleAckEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		notAllowed(w, http.MethodPost)
+		return
+	}
+	var req struct {
+		Consumer string `json:"consumer"`
+		ID       string `json:"id"`
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	consumer := strings.TrimSpace(req.Consumer)
+	cursor, err := a.svc.AckEvents(consumer, strings.TrimSpace(req.ID))
+	if err != nil {
+		status, msg := mapServiceError(err)
+		writeErrorWithMessage(w, status, msg)
+		return
+	}
+	writeOK(w, map[string]string{"consumer": consumer, "cursor": cursor})
+}
+
+// handleDiff 比较两个备份的内容，目前只支持按 JSON 键列出变化（format=json-keys）。
+func (a *API) handleDiff(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		notAllowed(w, http.MethodGet)
+		return
+	}
+	q := r.URL.Query()
+	idA, idB := strings.TrimSpace(q.Get("a")), strings.TrimSpace(q.Get("b"))
+	if idA == "" || idB == "" {
+		writeErrorWithMessage(w, http.StatusBadRequest, "需要同时提供参数 a 与 b")
+		return
+	}
+	if format := q.Get("format"); format != "" && format != "json-keys" {
+		writeErrorWithMessage(w, http.StatusBadRequest, "不支持的 format: "+format)
+		return
+	}
+	changes, err := a.svc.DiffBackupsJSON(idA, idB)
+	if err != nil {
+		status, msg := mapServiceError(err)
+		writeErrorWithMessage(w, status, msg)
+		return
+	}
+	writeOK(w, map[string]interface{}{
+		"format":  "json-keys",
+		"same":    len(changes) == 0,
+		"changes": changes,
+	})
+}
+
+func (a *API) handleCompareLatest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		notAllowed(w, http.MethodGet)
+		return
+	}
+	res, err := a.svc.CompareTargetToLatest()
+	if err != nil {
+		status, msg := mapServiceError(err)
+		writeErrorWithMessage(w, status, msg)
+		return
+	}
+	writeOK(w, res)
+}
+
+func (a *API) handleIndexCheck(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		notAllowed(w, http.MethodGet)
+		return
+	}
+	report, err := a.svc.CheckConsistency()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeOK(w, report)
+}
+
+// handleDebugBundle 以 zip 附件形式返回脱敏后的诊断包，供提交问题时附上。
+func (a *API) handleDebugBundle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		notAllowed(w, http.MethodGet)
+		return
+	}
+	name := "codex-backup-debug-" + time.Now().UTC().Format("20060102-150405") + ".zip"
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+name+`"`)
+	// 诊断包边生成边写出，出错时响应头已发送，只能记入审计日志。
+	err := a.svc.WriteDebugBundle(w)
+	a.audit(r, "debug.bundle", "", err)
+}
+
+// handleExport 以 zip 附件形式返回全部备份文件与索引，供迁移到其他机器。
+func (a *API) handleExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		notAllowed(w, http.MethodGet)
+		return
+	}
+	name := "codex-backup-export-" + time.Now().UTC().Format("20060102-150405") + ".zip"
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+name+`"`)
+	// 与诊断包相同，边打包边写出，出错时只能记入审计日志。
+	_, err := a.svc.WriteExport(w)
+	a.audit(r, "backup.export", "", err)
+}
+
+// maxImportSize 为 POST /api/import 上传归档的最大字节数。
+const maxImportSize = 512 << 20
+
+// handleImport 接收 multipart 表单字段 file 中的导出归档（见 handleExport），合并到当前索引。
+func (a *API) handleImport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		notAllowed(w, http.MethodPost)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+	file, header, err := r.FormFile("file")
+	if r.MultipartForm != nil {
+		defer r.MultipartForm.RemoveAll()
+	}
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeErrorCode(w, http.StatusRequestEntityTooLarge, CodeValidationFailed, "归档不能超过 "+strconv.Itoa(maxImportSize)+" 字节")
+			return
+		}
+		writeErrorCode(w, http.StatusBadRequest, CodeInvalidBody, "请求体应为 multipart/form-data，并在 file 字段中上传导出的 zip 归档")
+		return
+	}
+	defer file.Close()
+	res, err := a.svc.Import(file, header.Size)
+	a.audit(r, "backup.import", "", err)
+	if err != nil {
+		status, msg := mapServiceError(err)
+		writeErrorWithMessage(w, status, msg)
+		return
+	}
+	writeOK(w, res)
+}
+
+// handleAcceptIndex 确认当前索引内容并重新签名，解除签名不符导致的只读状态。
+func (a *API) handleAcceptIndex(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		notAllowed(w, http.MethodPost)
+		return
+	}
+	err := a.svc.AcceptIndex()
+	a.audit(r, "index.accept", "", err)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeOK(w, map[string]bool{"accepted": true})
+}
+
+// handleAcknowledgeTargetChange 确认启动时检测到的目标路径变更，之后 /api/status 不再返回 target_change。
+func (a *API) handleAcknowledgeTargetChange(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		notAllowed(w, http.MethodPost)
+		return
+	}
+	acknowledged, err := a.svc.AcknowledgeTargetChange()
+	a.audit(r, "target.acknowledge", "", err)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeOK(w, map[string]bool{"acknowledged": acknowledged})
+}
+
+func (a *API) handleMigrateRemarks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		notAllowed(w, http.MethodPost)
+		return
+	}
+	n, err := a.svc.MigrateRemarks()
+	a.audit(r, "index.migrate_remarks", "", err)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeOK(w, map[string]int{"migrated": n})
+}
+
+// handleRotateEncryption 用新口令重新加密全部加密备份，dry_run 为 true 时只验证旧口令能解密全部备份。
+func (a *API) handleRotateEncryption(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		notAllowed(w, http.MethodPost)
+		return
+	}
+	var req core.RotateOptions
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	res, err := a.svc.RotateEncryption(req)
+	if !req.DryRun {
+		a.audit(r, "encryption.rotate", "", err)
+	}
+	if errors.Is(err, core.ErrDecryptFailed) {
+		writeErrorWithMessage(w, http.StatusUnprocessableEntity, "旧口令无法解密备份或解密后校验失败，已完成的部分保留: "+err.Error())
+		return
+	}
+	if err != nil {
+		status, msg := mapServiceError(err)
+		writeErrorWithMessage(w, status, msg)
+		return
+	}
+	writeOK(w, res)
+}
+
+// purgeConfirmPhrase 为清空全部数据时请求体必须携带的确认语。
+const purgeConfirmPhrase = "PURGE ALL DATA"
+
+func (a *API) handlePurgeAll(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		notAllowed(w, http.MethodDelete)
+		return
+	}
+	var req struct {
+		Confirm string `json:"confirm"`
+	}
+	if !decodeOptionalJSON(w, r, &req) {
+		return
+	}
+	if !a.allow(w, core.CapDelete) {
+		return
+	}
+	if req.Confirm != purgeConfirmPhrase || r.Header.Get("X-Confirm-Purge") != "yes" {
+		writeErrorCode(w, http.StatusBadRequest, "PURGE_NOT_CONFIRMED",
+			`需要请求体 {"confirm":"`+purgeConfirmPhrase+`"} 且请求头 X-Confirm-Purge: yes`)
+		return
+	}
+	err := a.svc.PurgeAll(r.Context())
+	a.audit(r, "purge", "", err)
+	if err != nil {
+		status, msg := mapServiceError(err)
+		writeErrorWithMessage(w, status, msg)
+		return
+	}
+	writeOK(w, map[string]bool{"purged": true})
+}
+
 // ---- 辅助函数 ----
 
+// CodeCapabilityDisabled 为操作被 capabilities 配置关闭时响应中的错误代码。
+const CodeCapabilityDisabled = "CAPABILITY_DISABLED"
+
+// CodeValidationFailed 为请求参数未通过校验时响应中的错误代码。
+const CodeValidationFailed = "VALIDATION_FAILED"
+
+// CodeBackupNotJSON 为备份内容不是合法 JSON、无法预览时响应中的错误代码。
+const CodeBackupNotJSON = "BACKUP_NOT_JSON"
+
+// CodeTargetReadOnly 为目标文件只读导致还原被拒绝时响应中的错误代码。
+const CodeTargetReadOnly = "TARGET_READ_ONLY"
+
+// validRemark 规范化请求中的备注，不合法时返回 400 并说明原因。
+func (a *API) validRemark(w http.ResponseWriter, remark string, allowEmpty bool) (string, bool) {
+	normalized, err := a.svc.ValidateRemark(remark, allowEmpty)
+	var remarkErr *core.RemarkError
+	if errors.As(err, &remarkErr) {
+		writeErrorCode(w, http.StatusBadRequest, CodeValidationFailed, remarkErr.Reason)
+		return "", false
+	}
+	return normalized, true
+}
+
+// allow 在分发请求前检查能力开关，任一能力被关闭时返回 403 并指明能力名称。
+func (a *API) allow(w http.ResponseWriter, caps ...string) bool {
+	var capErr *core.CapabilityError
+	if err := a.svc.RequireCapability(caps...); errors.As(err, &capErr) {
+		writeErrorCode(w, http.StatusForbidden, CodeCapabilityDisabled, "已在配置中禁用能力: "+capErr.Name)
+		return false
+	}
+	return true
+}
+
+// audit 记录一次经由 HTTP 的变更操作，附带客户端 IP 与 User-Agent。
+func (a *API) audit(r *http.Request, op, itemID string, err error) {
+	entry := core.AuditEntry{
+		Op:        op,
+		ItemID:    itemID,
+		Source:    core.AuditSourceHTTP,
+		ClientIP:  clientIP(r),
+		UserAgent: r.UserAgent(),
+		ClientCN:  core.ClientCertCN(r),
+		RequestID: RequestID(r),
+		Outcome:   core.AuditOutcomeOK,
+	}
+	if err != nil {
+		entry.Outcome = core.AuditOutcomeFailed
+		entry.Error = err.Error()
+	}
+	a.svc.RecordAudit(entry)
+}
+
+// auditScan 只记录实际创建了备份或失败的扫描，未变化的扫描不写审计日志。
+func (a *API) auditScan(r *http.Request, res *core.ScanResult, err error) {
+	switch {
+	case err != nil:
+		a.audit(r, "backup.create", "", err)
+	case res.Created:
+		a.audit(r, "backup.create", res.Item.ID, nil)
+	}
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
 type response struct {
 	Ok    bool        `json:"ok"`
 	Data  interface{} `json:"data,omitempty"`
 	Error string      `json:"error,omitempty"`
+	// Code 为机器可读的错误代码，供客户端区分同一状态码下的不同错误。
+	Code string `json:"code,omitempty"`
+	// RequestID 为请求经过 WithRequestID 时分配的 ID，只在错误响应中返回，便于与访问日志、审计日志对应。
+	RequestID string `json:"request_id,omitempty"`
 }
 
 func writeOK(w http.ResponseWriter, data interface{}) {
@@ -189,7 +1173,15 @@ func writeErrorWithMessage(w http.ResponseWriter, status int, msg string) {
 	writeJSON(w, status, response{Ok: false, Error: msg})
 }
 
+func writeErrorCode(w http.ResponseWriter, status int, code, msg string) {
+	writeJSON(w, status, response{Ok: false, Error: msg, Code: code})
+}
+
+// writeJSON 写出响应；错误响应未指定 RequestID 时取 WithRequestID 写入的响应头，处理器无需逐个传递。
 func writeJSON(w http.ResponseWriter, status int, resp response) {
+	if !resp.Ok && resp.RequestID == "" {
+		resp.RequestID = w.Header().Get(RequestIDHeader)
+	}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
 	_ = json.NewEncoder(w).Encode(resp)
@@ -200,30 +1192,52 @@ func notAllowed(w http.ResponseWriter, methods ...string) {
 	writeErrorWithMessage(w, http.StatusMethodNotAllowed, "Method Not Allowed")
 }
 
-func decodeJSON(r *http.Request, v interface{}) error {
-	if r.Body == nil {
-		return nil
-	}
-	defer r.Body.Close()
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		return err
-	}
-	if len(body) == 0 {
-		return nil
-	}
-	if err := json.Unmarshal(body, v); err != nil {
-		return err
-	}
-	return nil
-}
-
 func mapServiceError(err error) (int, string) {
 	switch {
 	case errors.Is(err, core.ErrRemarkExists):
 		return http.StatusConflict, "备注已存在"
+	case errors.Is(err, core.ErrInvalidRemark):
+		return http.StatusBadRequest, err.Error()
 	case errors.Is(err, core.ErrBackupNotFound):
 		return http.StatusNotFound, "备份不存在"
+	case errors.Is(err, core.ErrInvalidTag):
+		return http.StatusBadRequest, "标签键不能为空"
+	case errors.Is(err, core.ErrTagNotFound):
+		return http.StatusNotFound, "标签不存在"
+	case errors.Is(err, core.ErrBackupFileMissing):
+		return http.StatusNotFound, "备份文件已不存在"
+	case errors.Is(err, core.ErrEncryptionKeyMissing):
+		return http.StatusUnprocessableEntity, "备份已加密，但未配置加密口令，无法读取"
+	case errors.Is(err, core.ErrDecryptFailed):
+		return http.StatusUnprocessableEntity, "备份解密失败：加密口令与创建备份时不同，或备份文件已损坏"
+	case errors.Is(err, core.ErrInvalidImport):
+		return http.StatusBadRequest, err.Error()
+	case errors.Is(err, core.ErrInvalidRotation):
+		return http.StatusBadRequest, err.Error()
+	case errors.Is(err, core.ErrInvalidCursor):
+		return http.StatusBadRequest, "cursor 无效"
+	case errors.Is(err, core.ErrInvalidSort):
+		return http.StatusBadRequest, "按 size 或 remark 排序时不支持 cursor，请使用 offset"
+	case errors.Is(err, core.ErrTokenExists):
+		return http.StatusConflict, "令牌名称已存在"
+	case errors.Is(err, core.ErrTokenNotFound):
+		return http.StatusNotFound, "令牌不存在"
+	case errors.Is(err, core.ErrInvalidTokenName):
+		return http.StatusBadRequest, "令牌名称只能包含字母、数字、点、下划线和连字符，长度 1-64"
+	case errors.Is(err, core.ErrLastToken):
+		return http.StatusConflict, "不能删除最后一个令牌"
+	case errors.Is(err, core.ErrInvalidConsumer):
+		return http.StatusBadRequest, "consumer 只能包含字母、数字、点、下划线和连字符，长度 1-64"
+	case errors.Is(err, core.ErrNotJSON):
+		return http.StatusUnprocessableEntity, "备份内容不是合法的 JSON，无法按键比较"
+	case errors.Is(err, core.ErrIndexTampered):
+		return http.StatusConflict, "索引签名校验失败，已进入只读模式；确认内容无误后调用 POST /api/index/accept 重新签名"
+	case errors.Is(err, core.ErrCapabilityDisabled):
+		return http.StatusForbidden, err.Error()
+	case errors.Is(err, core.ErrUnsafePurge):
+		return http.StatusConflict, err.Error()
+	case errors.Is(err, util.ErrUnsafePath):
+		return http.StatusUnprocessableEntity, "索引中的备份文件名不安全，已拒绝访问"
 	default:
 		return http.StatusInternalServerError, err.Error()
 	}
@@ -0,0 +1,1322 @@
+package api_test
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"codex-backup-tool/internal/api"
+	"codex-backup-tool/internal/core"
+	"codex-backup-tool/internal/util"
+)
+
+// newOpenServer 启动未配置任何认证的 API 服务。
+func newOpenServer(t *testing.T, opts ...func(*core.Config)) *httptest.Server {
+	t.Helper()
+	handlers, _ := newTestAPI(t, opts...)
+	mux := http.NewServeMux()
+	handlers.Register(mux)
+	srv := httptest.NewServer(handlers.RequireAuth(mux))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// newTestAPI 创建使用临时数据目录的服务与 API。
+func newTestAPI(t *testing.T, opts ...func(*core.Config)) (*api.API, *core.Service) {
+	t.Helper()
+	base := t.TempDir()
+	dataDir := filepath.Join(base, "data")
+	cfg := core.Config{
+		TargetPath:   filepath.Join(base, "codex", "auth.json"),
+		DataDir:      dataDir,
+		BackupsDir:   filepath.Join(dataDir, "backups"),
+		IndexPath:    filepath.Join(dataDir, "index.json"),
+		ScanInterval: time.Minute,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	svc, err := core.NewService(cfg, core.NewJSONLogger(io.Discard, slog.LevelInfo))
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	t.Cleanup(svc.Stop)
+	return api.New(svc), svc
+}
+
+func TestDisabledCapabilityIsRejected(t *testing.T) {
+	srv := newOpenServer(t, func(cfg *core.Config) {
+		cfg.Capabilities = map[string]bool{core.CapDelete: false, core.CapRestore: true}
+	})
+
+	req, _ := http.NewRequest(http.MethodDelete, srv.URL+"/api/backups/some-id", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	defer resp.Body.Close()
+	var body struct {
+		Code  string `json:"code"`
+		Error string `json:"error"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&body)
+	if resp.StatusCode != http.StatusForbidden || body.Code != api.CodeCapabilityDisabled {
+		t.Fatalf("expected 403 %s, got %d %+v", api.CodeCapabilityDisabled, resp.StatusCode, body)
+	}
+
+	// 清空全部数据同样受 delete 能力约束，且在确认检查之前拒绝。
+	req, _ = http.NewRequest(http.MethodDelete, srv.URL+"/api/everything", strings.NewReader(`{"confirm":"x"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("purge: %v", err)
+	}
+	body.Code = ""
+	_ = json.NewDecoder(resp.Body).Decode(&body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden || body.Code != api.CodeCapabilityDisabled {
+		t.Fatalf("expected purge to be rejected with 403 %s, got %d %+v", api.CodeCapabilityDisabled, resp.StatusCode, body)
+	}
+
+	// 未关闭的能力照常分发：备份不存在时返回 404 而不是 403。
+	resp, err = http.Post(srv.URL+"/api/backups/some-id/restore", "application/json", nil)
+	if err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected restore to reach the service, got %d", resp.StatusCode)
+	}
+
+	resp, err = http.Get(srv.URL + "/api/status")
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	defer resp.Body.Close()
+	var status struct {
+		Data core.StatusInfo `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		t.Fatalf("decode status: %v", err)
+	}
+	if len(status.Data.DisabledCapabilities) != 1 || status.Data.DisabledCapabilities[0] != core.CapDelete {
+		t.Fatalf("unexpected disabled capabilities: %v", status.Data.DisabledCapabilities)
+	}
+}
+
+func TestDocsServesSwaggerUI(t *testing.T) {
+	srv := newOpenServer(t, func(cfg *core.Config) { cfg.DocsEnabled = true })
+
+	resp, err := http.Get(srv.URL + "/api/docs/")
+	if err != nil {
+		t.Fatalf("docs: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
+		t.Fatalf("expected html 200, got %d %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	if !strings.Contains(string(body), "/api/openapi.json") {
+		t.Fatalf("expected swagger ui to point at the spec")
+	}
+	if strings.Contains(string(body), "://") {
+		t.Fatalf("expected docs page to load only same-origin assets:\n%s", body)
+	}
+
+	resp, err = http.Get(srv.URL + "/api/docs/swagger-ui-bundle.js")
+	if err != nil {
+		t.Fatalf("bundle: %v", err)
+	}
+	bundle, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.Contains(resp.Header.Get("Content-Type"), "javascript") {
+		t.Fatalf("expected javascript 200, got %d %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	if !bytes.Contains(bundle, []byte("SwaggerUIBundle")) {
+		t.Fatalf("expected swagger-ui bundle, got %d bytes", len(bundle))
+	}
+
+	resp, err = http.Get(srv.URL + "/api/docs/swagger-ui.css")
+	if err != nil {
+		t.Fatalf("css: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/css") {
+		t.Fatalf("expected css 200, got %d %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	for _, p := range []string{"/api/docs/.hidden", "/api/docs/missing.js"} {
+		resp, err = http.Get(srv.URL + p)
+		if err != nil {
+			t.Fatalf("%s: %v", p, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotFound {
+			t.Fatalf("expected 404 for %s, got %d", p, resp.StatusCode)
+		}
+	}
+
+	noRedirect := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err = noRedirect.Get(srv.URL + "/api/docs")
+	if err != nil {
+		t.Fatalf("docs redirect: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMovedPermanently || resp.Header.Get("Location") != "/api/docs/" {
+		t.Fatalf("expected redirect to /api/docs/, got %d %q", resp.StatusCode, resp.Header.Get("Location"))
+	}
+
+	resp, err = http.Get(srv.URL + "/api/openapi.json")
+	if err != nil {
+		t.Fatalf("openapi: %v", err)
+	}
+	var spec struct {
+		OpenAPI string `json:"openapi"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&spec)
+	resp.Body.Close()
+	if err != nil || spec.OpenAPI == "" {
+		t.Fatalf("expected valid openapi document, got %v", err)
+	}
+
+	disabled := newOpenServer(t)
+	resp, err = http.Get(disabled.URL + "/api/docs/")
+	if err != nil {
+		t.Fatalf("docs disabled: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 when docs are disabled, got %d", resp.StatusCode)
+	}
+}
+
+func TestReadyzAndSelfTest(t *testing.T) {
+	srv := newOpenServer(t)
+
+	resp, err := http.Get(srv.URL + "/readyz")
+	if err != nil {
+		t.Fatalf("readyz: %v", err)
+	}
+	var ready struct {
+		Ok   bool `json:"ok"`
+		Data struct {
+			Ready    bool     `json:"ready"`
+			Warnings []string `json:"warnings"`
+		} `json:"data"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&ready)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatalf("decode readyz: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || !ready.Data.Ready || len(ready.Data.Warnings) != 1 || ready.Data.Warnings[0] != core.CheckTargetDir {
+		t.Fatalf("unexpected readyz: %d %+v", resp.StatusCode, ready)
+	}
+
+	resp, err = http.Post(srv.URL+"/api/selftest", "application/json", nil)
+	if err != nil {
+		t.Fatalf("selftest: %v", err)
+	}
+	var report struct {
+		Data core.SelfTestReport `json:"data"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&report)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatalf("decode selftest: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || len(report.Data.Checks) != 5 || !report.Data.Ready() {
+		t.Fatalf("unexpected selftest: %d %+v", resp.StatusCode, report.Data)
+	}
+}
+
+func TestHealth(t *testing.T) {
+	handlers, svc := newTestAPI(t)
+	mux := http.NewServeMux()
+	handlers.Register(mux)
+	check := func(wantStatus int, want string, components map[string]string) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/health", nil))
+		var body struct {
+			Ok   bool `json:"ok"`
+			Data struct {
+				Status     string            `json:"status"`
+				Components map[string]string `json:"components"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("decode health: %v", err)
+		}
+		if rec.Code != wantStatus || body.Data.Status != want || body.Ok != (wantStatus == http.StatusOK) {
+			t.Fatalf("expected %d %s, got %d %s", wantStatus, want, rec.Code, rec.Body.String())
+		}
+		for name, status := range components {
+			if body.Data.Components[name] != status {
+				t.Fatalf("expected %s=%s, got %v", name, status, body.Data.Components)
+			}
+		}
+	}
+
+	// 目标文件缺失（例如尚未登录）不算降级。
+	check(http.StatusOK, "ok", map[string]string{core.HealthStore: core.HealthOK, core.HealthTarget: core.HealthMissing})
+
+	target := svc.Config().TargetPath
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(target, []byte(`{"token":"a"}`), 0o600); err != nil {
+		t.Fatalf("write target: %v", err)
+	}
+	if _, err := svc.CreateBackup(nil); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	check(http.StatusOK, "ok", map[string]string{core.HealthStore: core.HealthOK, core.HealthTarget: core.HealthOK})
+
+	if err := os.WriteFile(svc.Config().IndexPath, []byte("{not json"), 0o600); err != nil {
+		t.Fatalf("corrupt index: %v", err)
+	}
+	check(http.StatusServiceUnavailable, "degraded", map[string]string{core.HealthStore: core.HealthError, core.HealthTarget: core.HealthOK})
+}
+
+func TestDebugBundleDownload(t *testing.T) {
+	srv := newOpenServer(t)
+	resp, err := http.Get(srv.URL + "/api/debug/bundle")
+	if err != nil {
+		t.Fatalf("debug bundle: %v", err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatalf("read bundle: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "application/zip" ||
+		!strings.HasPrefix(resp.Header.Get("Content-Disposition"), "attachment;") {
+		t.Fatalf("unexpected response: %d %v", resp.StatusCode, resp.Header)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatalf("open bundle: %v", err)
+	}
+	if len(zr.File) == 0 || zr.File[0].Name != "platform.json" {
+		t.Fatalf("unexpected bundle entries: %d", len(zr.File))
+	}
+}
+
+func TestExportDownload(t *testing.T) {
+	srv := newOpenServer(t)
+	resp, err := http.Get(srv.URL + "/api/export")
+	if err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatalf("read export: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "application/zip" ||
+		!strings.Contains(resp.Header.Get("Content-Disposition"), "codex-backup-export-") {
+		t.Fatalf("unexpected response: %d %v", resp.StatusCode, resp.Header)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatalf("open export: %v", err)
+	}
+	names := make(map[string]bool, len(zr.File))
+	for _, f := range zr.File {
+		names[f.Name] = true
+	}
+	if !names["index.json"] || !names["manifest.json"] {
+		t.Fatalf("unexpected export entries: %v", names)
+	}
+
+	resp, err = http.Post(srv.URL+"/api/export", "application/json", nil)
+	if err != nil {
+		t.Fatalf("post export: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405, got %d", resp.StatusCode)
+	}
+}
+
+func TestImportUpload(t *testing.T) {
+	srcHandlers, src := newTestAPI(t)
+	srcMux := http.NewServeMux()
+	srcHandlers.Register(srcMux)
+	srcSrv := httptest.NewServer(srcMux)
+	defer srcSrv.Close()
+	target := src.Config().TargetPath
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(target, []byte(`{"token":"import"}`), 0o600); err != nil {
+		t.Fatalf("write target: %v", err)
+	}
+	if _, err := src.Scan(false, nil); err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	resp, err := http.Get(srcSrv.URL + "/api/export")
+	if err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	archive, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatalf("read export: %v", err)
+	}
+
+	srv := newOpenServer(t)
+	upload := func(field string) (int, map[string]any) {
+		t.Helper()
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		fw, err := mw.CreateFormFile(field, "export.zip")
+		if err != nil {
+			t.Fatalf("create form file: %v", err)
+		}
+		fw.Write(archive)
+		mw.Close()
+		resp, err := http.Post(srv.URL+"/api/import", mw.FormDataContentType(), &body)
+		if err != nil {
+			t.Fatalf("import: %v", err)
+		}
+		defer resp.Body.Close()
+		var payload map[string]any
+		if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return resp.StatusCode, payload
+	}
+
+	status, payload := upload("file")
+	data, _ := payload["data"].(map[string]any)
+	if imported, _ := data["imported"].([]any); status != http.StatusOK || len(imported) != 1 {
+		t.Fatalf("unexpected import response: %d %v", status, payload)
+	}
+	status, payload = upload("file")
+	data, _ = payload["data"].(map[string]any)
+	if skipped, _ := data["skipped"].([]any); status != http.StatusOK || len(skipped) != 1 {
+		t.Fatalf("re-import should skip the duplicate: %d %v", status, payload)
+	}
+	if status, payload = upload("archive"); status != http.StatusBadRequest || payload["code"] != api.CodeInvalidBody {
+		t.Fatalf("expected 400 without file field, got %d %v", status, payload)
+	}
+}
+
+func TestScanEndpointRecordsTrigger(t *testing.T) {
+	handlers, svc := newTestAPI(t)
+	mux := http.NewServeMux()
+	handlers.Register(mux)
+	srv := httptest.NewServer(handlers.RequireAuth(mux))
+	defer srv.Close()
+
+	target := svc.Config().TargetPath
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	scan := func(content, body string) core.BackupItem {
+		t.Helper()
+		if err := os.WriteFile(target, []byte(content), 0o600); err != nil {
+			t.Fatalf("write target: %v", err)
+		}
+		var payload io.Reader
+		if body != "" {
+			payload = strings.NewReader(body)
+		}
+		resp, err := http.Post(srv.URL+"/api/scan", "application/json", payload)
+		if err != nil {
+			t.Fatalf("scan: %v", err)
+		}
+		var out struct {
+			Data core.ScanResult `json:"data"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&out)
+		resp.Body.Close()
+		if err != nil || !out.Data.Created {
+			t.Fatalf("expected backup, got %+v (%v)", out.Data, err)
+		}
+		return *out.Data.Item
+	}
+
+	// 不带请求体的旧客户端：视为自动扫描。
+	item := scan(`{"token":"a"}`, "")
+	if !item.IsAuto || item.Trigger != core.BackupTriggerAPIScan || !strings.HasPrefix(item.Remark, "auto-") {
+		t.Fatalf("unexpected default scan item: %+v", item)
+	}
+	item = scan(`{"token":"b"}`, `{"auto":false}`)
+	if item.IsAuto || item.Trigger != core.BackupTriggerAPIScan || !strings.HasPrefix(item.Remark, "manual-") {
+		t.Fatalf("unexpected manual scan item: %+v", item)
+	}
+	if err := os.WriteFile(target, []byte(`{"token":"c"}`), 0o600); err != nil {
+		t.Fatalf("write target: %v", err)
+	}
+	resp, err := http.Post(srv.URL+"/api/backups", "application/json", nil)
+	if err != nil {
+		t.Fatalf("create backup: %v", err)
+	}
+	resp.Body.Close()
+	items, err := svc.ListBackups()
+	if err != nil || len(items) != 3 || items[0].Trigger != core.BackupTriggerManualCreate || items[0].IsAuto {
+		t.Fatalf("unexpected items: %+v (%v)", items, err)
+	}
+	status, err := svc.Status()
+	if err != nil || status.LastScanTrigger != core.ScanTriggerManual {
+		t.Fatalf("api scans should be reported as manual: %+v (%v)", status, err)
+	}
+}
+
+func TestRemarkValidationFailed(t *testing.T) {
+	srv := newOpenServer(t, func(cfg *core.Config) { cfg.RemarkMaxLength = 4 })
+	for _, tc := range []struct{ path, body string }{
+		{"/api/backups", `{"remark":"too long"}`},
+		{"/api/scan", `{"remark":"   "}`},
+	} {
+		resp, err := http.Post(srv.URL+tc.path, "application/json", strings.NewReader(tc.body))
+		if err != nil {
+			t.Fatalf("post %s: %v", tc.path, err)
+		}
+		var out struct {
+			Ok    bool   `json:"ok"`
+			Code  string `json:"code"`
+			Error string `json:"error"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&out)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		if resp.StatusCode != http.StatusBadRequest || out.Ok || out.Code != api.CodeValidationFailed || out.Error == "" {
+			t.Fatalf("%s: unexpected response %d %+v", tc.path, resp.StatusCode, out)
+		}
+	}
+}
+
+func TestPatchRemarkClear(t *testing.T) {
+	handlers, svc := newTestAPI(t)
+	mux := http.NewServeMux()
+	handlers.Register(mux)
+	srv := httptest.NewServer(handlers.RequireAuth(mux))
+	defer srv.Close()
+
+	target := svc.Config().TargetPath
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(target, []byte(`{"token":"a"}`), 0o600); err != nil {
+		t.Fatalf("write target: %v", err)
+	}
+	remark := "custom"
+	res, err := svc.CreateBackup(&remark)
+	if err != nil || !res.Created {
+		t.Fatalf("create: %+v (%v)", res, err)
+	}
+	patch := func(body string) (int, string, core.BackupItem) {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodPatch, srv.URL+"/api/backups/"+res.Item.ID+"/remark", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("patch: %v", err)
+		}
+		defer resp.Body.Close()
+		var out struct {
+			Code string          `json:"code"`
+			Data core.BackupItem `json:"data"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return resp.StatusCode, out.Code, out.Data
+	}
+
+	if status, code, _ := patch(`{"remark":""}`); status != http.StatusBadRequest || code != api.CodeValidationFailed {
+		t.Fatalf("empty remark: %d %s", status, code)
+	}
+	if status, code, _ := patch(`{"remark":"x","clear":true}`); status != http.StatusBadRequest || code != api.CodeValidationFailed {
+		t.Fatalf("remark with clear: %d %s", status, code)
+	}
+	status, _, item := patch(`{"clear":true}`)
+	if status != http.StatusOK || !strings.HasPrefix(item.Remark, "manual-") {
+		t.Fatalf("clear: %d %+v", status, item)
+	}
+}
+
+func TestBackupByIDRouting(t *testing.T) {
+	handlers, svc := newTestAPI(t)
+	mux := http.NewServeMux()
+	handlers.Register(mux)
+
+	target := svc.Config().TargetPath
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(target, []byte(`{"token":"a"}`), 0o600); err != nil {
+		t.Fatalf("write target: %v", err)
+	}
+	res, err := svc.CreateBackup(nil)
+	if err != nil || !res.Created {
+		t.Fatalf("create: %+v (%v)", res, err)
+	}
+	id := res.Item.ID
+	escaped := fmt.Sprintf("%%%02X", id[0]) + id[1:]
+
+	for _, tc := range []struct {
+		method, path, body string
+		status             int
+		allow              string
+	}{
+		{http.MethodDelete, "/api/backups/", "", http.StatusBadRequest, ""},
+		{http.MethodPost, "/api/backups/" + id + "/restore/extra", "", http.StatusNotFound, ""},
+		{http.MethodPost, "/api/backups/" + id + "/unknown", "", http.StatusNotFound, ""},
+		{http.MethodGet, "/api/backups/" + id + "/restore", "", http.StatusMethodNotAllowed, http.MethodPost},
+		{http.MethodGet, "/api/backups/" + id + "/restore/", "", http.StatusMethodNotAllowed, http.MethodPost},
+		{http.MethodPost, "/api/backups/" + id + "/remark", "", http.StatusMethodNotAllowed, http.MethodPatch},
+		{http.MethodPatch, "/api/backups/" + id + "/", "", http.StatusMethodNotAllowed, http.MethodDelete},
+		{http.MethodGet, "/api/backups/missing/restore", "", http.StatusNotFound, ""},
+		{http.MethodPatch, "/api/backups/" + escaped + "/remark/", `{"remark":"renamed"}`, http.StatusOK, ""},
+	} {
+		req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		if rec.Code != tc.status || rec.Header().Get("Allow") != tc.allow {
+			t.Errorf("%s %s: got %d allow=%q, want %d allow=%q", tc.method, tc.path, rec.Code, rec.Header().Get("Allow"), tc.status, tc.allow)
+		}
+	}
+	if item, err := svc.GetBackup(id); err != nil || item.Remark != "renamed" {
+		t.Fatalf("expected remark updated through the escaped id, got %+v (%v)", item, err)
+	}
+}
+
+func TestJSONBodyValidation(t *testing.T) {
+	handlers, _ := newTestAPI(t)
+	mux := http.NewServeMux()
+	handlers.Register(mux)
+
+	for _, tc := range []struct {
+		name, method, path, contentType, body string
+		status                                int
+		code                                  string
+		message                               string
+	}{
+		{"array body", http.MethodPost, "/api/scan", "application/json", `[1,2,3]`, http.StatusBadRequest, api.CodeInvalidBody, "JSON 对象"},
+		{"string body", http.MethodPost, "/api/backups", "application/json", `"hello"`, http.StatusBadRequest, api.CodeInvalidBody, "JSON 对象"},
+		{"syntax error", http.MethodPost, "/api/scan", "application/json", `{"remark":`, http.StatusBadRequest, api.CodeInvalidBody, "合法的 JSON"},
+		{"field type mismatch", http.MethodPost, "/api/scan", "application/json", `{"remark":1}`, http.StatusBadRequest, api.CodeInvalidBody, "字段 remark 应为 string，实际为 number"},
+		{"nested type mismatch", http.MethodPost, "/api/scan", "application/json", `{"auto":"yes"}`, http.StatusBadRequest, api.CodeInvalidBody, "字段 auto 应为 boolean"},
+		{"text content type", http.MethodPost, "/api/scan", "text/plain", `{}`, http.StatusUnsupportedMediaType, api.CodeUnsupportedMediaType, "application/json"},
+		{"form content type", http.MethodPost, "/api/events/ack", "application/x-www-form-urlencoded", `consumer=x`, http.StatusUnsupportedMediaType, api.CodeUnsupportedMediaType, "application/json"},
+		{"required body missing", http.MethodPost, "/api/events/ack", "application/json", ``, http.StatusBadRequest, api.CodeInvalidBody, "不能为空"},
+		{"required body whitespace", http.MethodPost, "/api/events/ack", "", "  \n", http.StatusBadRequest, api.CodeInvalidBody, "不能为空"},
+		{"optional body empty", http.MethodPost, "/api/scan", "", ``, http.StatusOK, "", ""},
+		{"optional body with charset", http.MethodPost, "/api/scan", "application/json; charset=utf-8", `{}`, http.StatusOK, "", ""},
+		{"purge without body", http.MethodDelete, "/api/everything", "", ``, http.StatusBadRequest, "PURGE_NOT_CONFIRMED", ""},
+	} {
+		req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+		if tc.contentType != "" {
+			req.Header.Set("Content-Type", tc.contentType)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		var out struct {
+			Code  string `json:"code"`
+			Error string `json:"error"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil {
+			t.Fatalf("%s: decode: %v", tc.name, err)
+		}
+		if rec.Code != tc.status || out.Code != tc.code || !strings.Contains(out.Error, tc.message) {
+			t.Errorf("%s: got %d %q %q, want %d %q containing %q", tc.name, rec.Code, out.Code, out.Error, tc.status, tc.code, tc.message)
+		}
+	}
+}
+
+func TestDownloadBackup(t *testing.T) {
+	handlers, svc := newTestAPI(t)
+	mux := http.NewServeMux()
+	handlers.Register(mux)
+	srv := httptest.NewServer(handlers.RequireAuth(mux))
+	defer srv.Close()
+
+	content := `{"token":"download"}`
+	target := svc.Config().TargetPath
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(target, []byte(content), 0o600); err != nil {
+		t.Fatalf("write target: %v", err)
+	}
+	res, err := svc.CreateBackup(nil)
+	if err != nil || !res.Created {
+		t.Fatalf("create: %+v (%v)", res, err)
+	}
+	downloadURL := srv.URL + "/api/backups/" + res.Item.ID + "/download"
+	resp, err := http.Get(downloadURL)
+	if err != nil {
+		t.Fatalf("download: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != content {
+		t.Fatalf("unexpected download: %d %q", resp.StatusCode, body)
+	}
+	if got := resp.Header.Get("Content-Disposition"); got != "attachment; filename="+res.Item.Filename {
+		t.Fatalf("unexpected Content-Disposition %q", got)
+	}
+	if resp.ContentLength != int64(len(content)) {
+		t.Fatalf("expected Content-Length %d, got %d", len(content), resp.ContentLength)
+	}
+
+	if err := os.Remove(filepath.Join(svc.Config().BackupsDir, res.Item.Filename)); err != nil {
+		t.Fatalf("remove backup file: %v", err)
+	}
+	resp, err = http.Get(downloadURL)
+	if err != nil {
+		t.Fatalf("download missing: %v", err)
+	}
+	defer resp.Body.Close()
+	var out struct {
+		Ok    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.StatusCode != http.StatusNotFound || out.Ok || out.Error == "" {
+		t.Fatalf("expected 404 envelope for missing file, got %d %+v", resp.StatusCode, out)
+	}
+}
+
+func TestDownloadCompressedBackup(t *testing.T) {
+	for _, passphrase := range []string{"", "correct horse battery staple"} {
+		handlers, svc := newTestAPI(t, func(cfg *core.Config) {
+			cfg.Compression = core.CompressionGzip
+			cfg.EncryptionPassphrase = util.SecretString(passphrase)
+		})
+		mux := http.NewServeMux()
+		handlers.Register(mux)
+		srv := httptest.NewServer(handlers.RequireAuth(mux))
+
+		content := `{"token":"compressed-download"}`
+		target := svc.Config().TargetPath
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(target, []byte(content), 0o600); err != nil {
+			t.Fatalf("write target: %v", err)
+		}
+		res, err := svc.CreateBackup(nil)
+		if err != nil || !res.Created || !core.IsCompressedBackup(res.Item.Filename) {
+			t.Fatalf("create: %+v (%v)", res, err)
+		}
+		resp, err := http.Get(srv.URL + "/api/backups/" + res.Item.ID + "/download")
+		if err != nil {
+			t.Fatalf("download: %v", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		srv.Close()
+		if resp.StatusCode != http.StatusOK || string(body) != content {
+			t.Fatalf("expected decompressed content (passphrase %q), got %d %q", passphrase, resp.StatusCode, body)
+		}
+		if got := resp.Header.Get("Content-Type"); got != "application/json" {
+			t.Fatalf("unexpected Content-Type %q", got)
+		}
+		want := "attachment; filename=" + strings.TrimSuffix(res.Item.Filename, ".gz")
+		if got := resp.Header.Get("Content-Disposition"); got != want || strings.HasSuffix(got, ".gz") {
+			t.Fatalf("expected Content-Disposition %q, got %q", want, got)
+		}
+	}
+}
+
+func TestListBackupsPagination(t *testing.T) {
+	handlers, svc := newTestAPI(t)
+	mux := http.NewServeMux()
+	handlers.Register(mux)
+
+	target := svc.Config().TargetPath
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	for _, content := range []string{`{"token":"a"}`, `{"token":"b"}`} {
+		if err := os.WriteFile(target, []byte(content), 0o600); err != nil {
+			t.Fatalf("write target: %v", err)
+		}
+		if res, err := svc.CreateBackup(nil); err != nil || !res.Created {
+			t.Fatalf("create: %+v (%v)", res, err)
+		}
+	}
+	get := func(query string, out interface{}) int {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/backups"+query, nil))
+		if err := json.Unmarshal(rec.Body.Bytes(), out); err != nil {
+			t.Fatalf("decode %s: %v", query, err)
+		}
+		return rec.Code
+	}
+	type page struct {
+		Data struct {
+			Items      []core.BackupItem `json:"items"`
+			NextCursor string            `json:"next_cursor"`
+			Total      int               `json:"total"`
+			Limit      int               `json:"limit"`
+			Offset     int               `json:"offset"`
+		} `json:"data"`
+	}
+	var legacy struct {
+		Data []core.BackupItem `json:"data"`
+	}
+	if status := get("", &legacy); status != http.StatusOK || len(legacy.Data) != 2 {
+		t.Fatalf("expected the full array without parameters, got %d %d items", status, len(legacy.Data))
+	}
+	var first, second page
+	if status := get("?limit=1", &first); status != http.StatusOK || len(first.Data.Items) != 1 || first.Data.NextCursor == "" {
+		t.Fatalf("unexpected first page: %d %+v", status, first.Data)
+	}
+	if first.Data.Items[0].ID != legacy.Data[0].ID {
+		t.Fatalf("expected newest backup first")
+	}
+	if status := get("?limit=1&cursor="+first.Data.NextCursor, &second); status != http.StatusOK ||
+		len(second.Data.Items) != 1 || second.Data.Items[0].ID != legacy.Data[1].ID || second.Data.NextCursor != "" {
+		t.Fatalf("unexpected second page: %d %+v", status, second.Data)
+	}
+	var byOffset, beyond page
+	if status := get("?limit=1&offset=1", &byOffset); status != http.StatusOK || byOffset.Data.Total != 2 || byOffset.Data.Limit != 1 ||
+		byOffset.Data.Offset != 1 || len(byOffset.Data.Items) != 1 || byOffset.Data.Items[0].ID != legacy.Data[1].ID {
+		t.Fatalf("unexpected offset page: %d %+v", status, byOffset.Data)
+	}
+	if status := get("?offset=10", &beyond); status != http.StatusOK || beyond.Data.Total != 2 || beyond.Data.Items == nil ||
+		len(beyond.Data.Items) != 0 || beyond.Data.Limit != core.DefaultBackupPageLimit {
+		t.Fatalf("expected an empty page for an out-of-range offset, got %d %+v", status, beyond.Data)
+	}
+	var bad struct {
+		Error string `json:"error"`
+	}
+	if status := get("?offset=-1", &bad); status != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a negative offset, got %d", status)
+	}
+	if status := get("?cursor=%21%21", &bad); status != http.StatusBadRequest || bad.Error == "" {
+		t.Fatalf("expected 400 for an invalid cursor, got %d %+v", status, bad)
+	}
+	var filtered struct {
+		Data []core.BackupItem `json:"data"`
+	}
+	if status := get("?is_auto=false&to="+url.QueryEscape(legacy.Data[1].CreatedAt.Format(time.RFC3339Nano)), &filtered); status != http.StatusOK ||
+		len(filtered.Data) != 1 || filtered.Data[0].ID != legacy.Data[1].ID {
+		t.Fatalf("unexpected filtered list: %d %+v", status, filtered.Data)
+	}
+	cutoff := url.QueryEscape(legacy.Data[1].CreatedAt.Format(time.RFC3339Nano))
+	if status := get("?created_after="+cutoff, &filtered); status != http.StatusOK ||
+		len(filtered.Data) != 1 || filtered.Data[0].ID != legacy.Data[0].ID {
+		t.Fatalf("expected the cutoff item to be excluded: %d %+v", status, filtered.Data)
+	}
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/backups?q=no-such-remark", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"data":[]`) {
+		t.Fatalf("expected an empty array for an unmatched search, got %d %s", rec.Code, rec.Body.String())
+	}
+	var coded struct {
+		Code string `json:"code"`
+	}
+	if status := get("?sort=created_at&order=asc", &filtered); status != http.StatusOK ||
+		len(filtered.Data) != 2 || filtered.Data[0].ID != legacy.Data[1].ID {
+		t.Fatalf("expected oldest backup first: %d %+v", status, filtered.Data)
+	}
+	if status := get("?sort=size&limit=1&cursor="+first.Data.NextCursor, &bad); status != http.StatusBadRequest || bad.Error == "" {
+		t.Fatalf("expected 400 for a cursor with size sort, got %d %+v", status, bad)
+	}
+	for _, query := range []string{"?from=yesterday", "?is_auto=maybe", "?created_after=2024-13-01", "?created_before=now",
+		"?sort=name", "?sort=size&order=up"} {
+		if status := get(query, &coded); status != http.StatusBadRequest || coded.Code != api.CodeValidationFailed {
+			t.Fatalf("%s: expected 400 with %s, got %d %q", query, api.CodeValidationFailed, status, coded.Code)
+		}
+	}
+}
+
+func TestRestoreByRemark(t *testing.T) {
+	handlers, svc := newTestAPI(t)
+	mux := http.NewServeMux()
+	handlers.Register(mux)
+	srv := httptest.NewServer(handlers.RequireAuth(mux))
+	defer srv.Close()
+
+	target := svc.Config().TargetPath
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(target, []byte(`{"token":"work"}`), 0o600); err != nil {
+		t.Fatalf("write target: %v", err)
+	}
+	remark := "工作 账号"
+	res, err := svc.CreateBackup(&remark)
+	if err != nil || !res.Created {
+		t.Fatalf("create: %+v (%v)", res, err)
+	}
+	if err := os.WriteFile(target, []byte(`{"token":"personal"}`), 0o600); err != nil {
+		t.Fatalf("overwrite target: %v", err)
+	}
+
+	resp, err := http.Post(srv.URL+"/api/backups/remark/"+url.PathEscape(remark)+"/restore", "application/json", nil)
+	if err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	var out struct {
+		Data core.BackupItem `json:"data"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&out)
+	resp.Body.Close()
+	if err != nil || resp.StatusCode != http.StatusOK || out.Data.ID != res.Item.ID {
+		t.Fatalf("unexpected restore response: %d %+v (%v)", resp.StatusCode, out.Data, err)
+	}
+	if data, err := os.ReadFile(target); err != nil || string(data) != `{"token":"work"}` {
+		t.Fatalf("expected target restored, got %q (%v)", data, err)
+	}
+
+	resp, err = http.Post(srv.URL+"/api/backups/remark/missing/restore", "application/json", nil)
+	if err != nil {
+		t.Fatalf("restore missing: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown remark, got %d", resp.StatusCode)
+	}
+}
+
+func TestPruneEndpoint(t *testing.T) {
+	handlers, svc := newTestAPI(t)
+	mux := http.NewServeMux()
+	handlers.Register(mux)
+	srv := httptest.NewServer(handlers.RequireAuth(mux))
+	defer srv.Close()
+
+	target := svc.Config().TargetPath
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	var ids []string
+	for _, c := range "abc" {
+		if err := os.WriteFile(target, []byte(`{"token":"`+string(c)+`"}`), 0o600); err != nil {
+			t.Fatalf("write target: %v", err)
+		}
+		res, err := svc.Scan(true, nil)
+		if err != nil || !res.Created {
+			t.Fatalf("auto backup %c: %+v (%v)", c, res, err)
+		}
+		ids = append(ids, res.Item.ID)
+	}
+
+	prune := func(body string) (int, core.PruneResult) {
+		t.Helper()
+		resp, err := http.Post(srv.URL+"/api/backups/prune", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("prune: %v", err)
+		}
+		defer resp.Body.Close()
+		var out struct {
+			Data core.PruneResult `json:"data"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return resp.StatusCode, out.Data
+	}
+
+	for _, body := range []string{`{}`, `{"keep_auto":-1}`, `{"older_than_days":-1,"dry_run":true}`} {
+		if status, _ := prune(body); status != http.StatusBadRequest {
+			t.Fatalf("expected 400 for %s, got %d", body, status)
+		}
+	}
+
+	status, res := prune(`{"keep_auto":1,"dry_run":true}`)
+	if status != http.StatusOK || !res.DryRun || len(res.Items) != 2 || len(res.Deleted) != 0 || res.BytesReclaimed != res.Items[0].Size+res.Items[1].Size {
+		t.Fatalf("unexpected dry run response: %d %+v", status, res)
+	}
+	if items, err := svc.ListBackups(); err != nil || len(items) != 3 {
+		t.Fatalf("dry run must not delete backups, got %d (%v)", len(items), err)
+	}
+
+	status, res = prune(`{"keep_auto":1}`)
+	if status != http.StatusOK || res.DryRun || strings.Join(res.Deleted, ",") != ids[0]+","+ids[1] {
+		t.Fatalf("unexpected prune response: %d %+v", status, res)
+	}
+	if items, err := svc.ListBackups(); err != nil || len(items) != 1 || items[0].ID != ids[2] {
+		t.Fatalf("expected only the newest backup to remain, got %+v (%v)", items, err)
+	}
+}
+
+func TestPreviewBackup(t *testing.T) {
+	handlers, svc := newTestAPI(t)
+	mux := http.NewServeMux()
+	handlers.Register(mux)
+
+	target := svc.Config().TargetPath
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(target, []byte(`{"OPENAI_API_KEY":"sk-proj-1234567890abcd"}`), 0o600); err != nil {
+		t.Fatalf("write target: %v", err)
+	}
+	res, err := svc.CreateBackup(nil)
+	if err != nil || !res.Created {
+		t.Fatalf("create: %+v (%v)", res, err)
+	}
+	preview := func() (int, string, string) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/backups/"+res.Item.ID+"/preview", nil))
+		var out struct {
+			Code string `json:"code"`
+			Data struct {
+				Content map[string]string `json:"content"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return rec.Code, out.Code, out.Data.Content["OPENAI_API_KEY"]
+	}
+	if status, _, key := preview(); status != http.StatusOK || key != "sk-...abcd" {
+		t.Fatalf("unexpected preview: %d %q", status, key)
+	}
+	if err := os.WriteFile(filepath.Join(svc.Config().BackupsDir, res.Item.Filename), []byte("{broken"), 0o600); err != nil {
+		t.Fatalf("corrupt backup: %v", err)
+	}
+	if status, code, _ := preview(); status != http.StatusUnprocessableEntity || code != api.CodeBackupNotJSON {
+		t.Fatalf("expected 422 %s, got %d %q", api.CodeBackupNotJSON, status, code)
+	}
+}
+
+func TestDiffBackupAgainst(t *testing.T) {
+	handlers, svc := newTestAPI(t)
+	mux := http.NewServeMux()
+	handlers.Register(mux)
+
+	target := svc.Config().TargetPath
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	var ids []string
+	for _, content := range []string{`{"api_key":"sk-old-0123456789"}`, `{"api_key":"sk-new-0123456789"}`} {
+		if err := os.WriteFile(target, []byte(content), 0o600); err != nil {
+			t.Fatalf("write target: %v", err)
+		}
+		res, err := svc.CreateBackup(nil)
+		if err != nil || !res.Created {
+			t.Fatalf("create: %+v (%v)", res, err)
+		}
+		ids = append(ids, res.Item.ID)
+	}
+	for _, tc := range []struct {
+		query  string
+		status int
+	}{
+		{"", http.StatusBadRequest},
+		{"?against=missing", http.StatusNotFound},
+		{"?against=" + ids[1], http.StatusOK},
+	} {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/backups/"+ids[0]+"/diff"+tc.query, nil))
+		if rec.Code != tc.status {
+			t.Fatalf("%q: expected %d, got %d", tc.query, tc.status, rec.Code)
+		}
+		if tc.status == http.StatusOK && (strings.Contains(rec.Body.String(), "sk-old-0123456789") || !strings.Contains(rec.Body.String(), `"format":"json"`)) {
+			t.Fatalf("expected masked json diff, got %s", rec.Body.String())
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/backups/"+ids[0]+"/diff-current", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"from":"target"`) || strings.Contains(rec.Body.String(), "sk-old-0123456789") {
+		t.Fatalf("unexpected diff-current response: %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestDiffMasksTokens(t *testing.T) {
+	handlers, svc := newTestAPI(t)
+	mux := http.NewServeMux()
+	handlers.Register(mux)
+
+	target := svc.Config().TargetPath
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	var ids []string
+	for _, content := range []string{
+		`{"tokens":{"access_token":"eyJ-old-access-0123","refresh_token":"rt-old-refresh-0123"}}`,
+		`{"tokens":{"access_token":"eyJ-new-access-4567","refresh_token":"rt-new-refresh-4567"}}`,
+	} {
+		if err := os.WriteFile(target, []byte(content), 0o600); err != nil {
+			t.Fatalf("write target: %v", err)
+		}
+		res, err := svc.CreateBackup(nil)
+		if err != nil || !res.Created {
+			t.Fatalf("create: %+v (%v)", res, err)
+		}
+		ids = append(ids, res.Item.ID)
+	}
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/diff?a="+ids[0]+"&b="+ids[1], nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d %s", rec.Code, rec.Body.String())
+	}
+	body := rec.Body.String()
+	for _, secret := range []string{"eyJ-old-access-0123", "eyJ-new-access-4567", "rt-old-refresh-0123", "rt-new-refresh-4567"} {
+		if strings.Contains(body, secret) {
+			t.Fatalf("diff leaked %q: %s", secret, body)
+		}
+	}
+	if !strings.Contains(body, "/tokens/access_token") || !strings.Contains(body, "eyJ...0123") {
+		t.Fatalf("expected masked change for access_token, got %s", body)
+	}
+}
+
+func TestRotateEncryptionEndpoint(t *testing.T) {
+	handlers, svc := newTestAPI(t, func(cfg *core.Config) {
+		cfg.EncryptionPassphrase = "old passphrase"
+	})
+	mux := http.NewServeMux()
+	handlers.Register(mux)
+	target := svc.Config().TargetPath
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(target, []byte(`{"access_token":"sk-rotate"}`), 0o600); err != nil {
+		t.Fatalf("write target: %v", err)
+	}
+	created, err := svc.CreateBackup(nil)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	post := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/encryption/rotate", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+	if rec := post(`{"old_passphrase":"old passphrase"}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 without a new passphrase, got %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := post(`{"old_passphrase":"wrong","new_passphrase":"new passphrase"}`); rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 for a wrong old passphrase, got %d %s", rec.Code, rec.Body.String())
+	}
+	rec := post(`{"old_passphrase":"old passphrase","new_passphrase":"new passphrase"}`)
+	var resp struct {
+		Data core.RotateResult `json:"data"`
+	}
+	if rec.Code != http.StatusOK || json.Unmarshal(rec.Body.Bytes(), &resp) != nil || len(resp.Data.Rotated) != 1 || !resp.Data.ConfigUpdateRequired {
+		t.Fatalf("unexpected rotate response: %d %s", rec.Code, rec.Body.String())
+	}
+	if strings.Contains(rec.Body.String(), "new passphrase") {
+		t.Fatalf("response must not echo the passphrase: %s", rec.Body.String())
+	}
+	if _, err := svc.RestoreBackup(created.Item.ID); err != nil {
+		t.Fatalf("restore after rotation: %v", err)
+	}
+}
+
+func TestBackupTags(t *testing.T) {
+	handlers, svc := newTestAPI(t)
+	mux := http.NewServeMux()
+	handlers.Register(mux)
+	target := svc.Config().TargetPath
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(target, []byte(`{"token":"work"}`), 0o600); err != nil {
+		t.Fatalf("write target: %v", err)
+	}
+	res, err := svc.CreateBackup(nil)
+	if err != nil || !res.Created {
+		t.Fatalf("create: %+v (%v)", res, err)
+	}
+	id := res.Item.ID
+
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := serve(http.MethodPatch, "/api/backups/"+id+"/tags", `{"key":"env","value":"prod"}`)
+	var out struct {
+		Data core.BackupItem `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil || rec.Code != http.StatusOK || out.Data.Tags["env"] != "prod" {
+		t.Fatalf("unexpected set tag response: %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := serve(http.MethodPatch, "/api/backups/"+id+"/tags", `{"key":"","value":"x"}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for empty key, got %d", rec.Code)
+	}
+
+	rec = serve(http.MethodGet, "/api/backups?tag_key=env&tag_value=prod", "")
+	var list struct {
+		Data []core.BackupItem `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil || len(list.Data) != 1 {
+		t.Fatalf("expected filtered list with 1 item, got %d %s", rec.Code, rec.Body.String())
+	}
+	rec = serve(http.MethodGet, "/api/backups?tag_key=env&tag_value=dev", "")
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil || len(list.Data) != 0 {
+		t.Fatalf("expected empty filtered list, got %s", rec.Body.String())
+	}
+
+	if rec := serve(http.MethodGet, "/api/backups/"+id+"/tags/env", ""); rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405, got %d", rec.Code)
+	}
+	if rec := serve(http.MethodDelete, "/api/backups/"+id+"/tags/env", ""); rec.Code != http.StatusOK {
+		t.Fatalf("delete tag: %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := serve(http.MethodDelete, "/api/backups/"+id+"/tags/env", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for missing tag, got %d", rec.Code)
+	}
+}
+
+func TestEventStream(t *testing.T) {
+	handlers, svc := newTestAPI(t)
+	mux := http.NewServeMux()
+	handlers.Register(mux)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/api/events?type="+core.EventBackupCreated, nil)
+	if err != nil {
+		t.Fatalf("new request: %v", err)
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		t.Fatalf("unexpected response: %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	target := svc.Config().TargetPath
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(target, []byte(`{"token":"stream"}`), 0o600); err != nil {
+		t.Fatalf("write target: %v", err)
+	}
+	res, err := svc.CreateBackup(nil)
+	if err != nil || !res.Created {
+		t.Fatalf("create: %+v (%v)", res, err)
+	}
+
+	frames := make(chan string, 1)
+	go func() {
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			if data, ok := strings.CutPrefix(scanner.Text(), "data: "); ok {
+				frames <- data
+				return
+			}
+		}
+	}()
+	select {
+	case data := <-frames:
+		var e core.Event
+		if err := json.Unmarshal([]byte(data), &e); err != nil {
+			t.Fatalf("decode event %q: %v", data, err)
+		}
+		if e.Type != core.EventBackupCreated || e.ItemID != res.Item.ID {
+			t.Fatalf("unexpected event: %+v", e)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no event received")
+	}
+
+	// 不带 Accept: text/event-stream 时仍返回事件日志查询结果。
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/events", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"events"`) {
+		t.Fatalf("expected the JSON event page, got %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestRestoreReadOnlyTargetReturnsCode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows 不支持 Unix 权限位")
+	}
+	handlers, svc := newTestAPI(t)
+	mux := http.NewServeMux()
+	handlers.Register(mux)
+	srv := httptest.NewServer(handlers.RequireAuth(mux))
+	defer srv.Close()
+
+	target := svc.Config().TargetPath
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(target, []byte(`{"token":"a"}`), 0o400); err != nil {
+		t.Fatalf("write target: %v", err)
+	}
+	res, err := svc.CreateBackup(nil)
+	if err != nil || !res.Created {
+		t.Fatalf("create: %+v (%v)", res, err)
+	}
+	resp, err := http.Post(srv.URL+"/api/backups/"+res.Item.ID+"/restore", "application/json", nil)
+	if err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	defer resp.Body.Close()
+	var out struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.StatusCode != http.StatusConflict || out.Code != api.CodeTargetReadOnly {
+		t.Fatalf("expected 409 %s, got %d %q", api.CodeTargetReadOnly, resp.StatusCode, out.Code)
+	}
+}
+
+func TestAcknowledgeTargetChange(t *testing.T) {
+	srv := newOpenServer(t)
+	resp, err := http.Get(srv.URL + "/api/target/acknowledge")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405 for GET, got %d", resp.StatusCode)
+	}
+	resp, err = http.Post(srv.URL+"/api/target/acknowledge", "application/json", nil)
+	if err != nil {
+		t.Fatalf("post: %v", err)
+	}
+	defer resp.Body.Close()
+	var out struct {
+		Data map[string]bool `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || out.Data["acknowledged"] {
+		t.Fatalf("expected nothing to acknowledge, got %d %+v", resp.StatusCode, out.Data)
+	}
+}
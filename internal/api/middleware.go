@@ -0,0 +1,178 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/google/uuid"
+
+	"codex-backup-tool/internal/core"
+	"codex-backup-tool/internal/tracing"
+)
+
+// RequestIDHeader 为请求 ID 使用的请求头与响应头。
+const RequestIDHeader = "X-Request-ID"
+
+type requestIDKey struct{}
+
+// RequestID 返回 WithRequestID 为请求分配的 ID，未经过该中间件时返回空字符串。
+func RequestID(r *http.Request) string {
+	id, _ := r.Context().Value(requestIDKey{}).(string)
+	return id
+}
+
+// WithRequestID 为每个请求分配 ID 并写入响应头，客户端在请求头中提供的合法 ID 会被沿用。
+func WithRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = uuid.NewString()
+		}
+		w.Header().Set(RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+// validRequestID 只接受不超过 64 个字符的字母、数字、'-' 与 '_'，避免日志注入。
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// Tracing 为每个请求创建 server span，沿用请求中合法的 traceparent，并记录路由与状态码。
+// 未配置追踪时直接返回 next，不引入任何开销。
+func (a *API) Tracing(mux *http.ServeMux, next http.Handler) http.Handler {
+	tracer := a.svc.Tracer()
+	if tracer == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, route := mux.Handler(r)
+		if route == "" {
+			route = "unmatched"
+		}
+		ctx := tracing.ContextWithTraceParent(r.Context(), r.Header.Get("traceparent"))
+		ctx, span := tracer.Start(ctx, r.Method+" "+route, tracing.KindServer,
+			tracing.String("http.request.method", r.Method),
+			tracing.String("http.route", route),
+			tracing.String("request_id", RequestID(r)))
+		rw := &responseWriter{ResponseWriter: w, status: http.StatusOK}
+		defer func() {
+			span.SetAttributes(tracing.Int("http.response.status_code", int64(rw.status)))
+			if rw.status >= http.StatusInternalServerError {
+				span.SetError(errors.New(http.StatusText(rw.status)))
+			}
+			span.End()
+		}()
+		next.ServeHTTP(rw, r.WithContext(ctx))
+	})
+}
+
+// CodeInternalPanic 表示处理请求时发生 panic，响应中附带请求 ID。
+const CodeInternalPanic = "INTERNAL_PANIC"
+
+// Recover 捕获处理器中的 panic，记录调用栈与请求 ID 并计数，返回 500 与 CodeInternalPanic，
+// 连接与其他请求不受影响。应放在访问日志中间件之外；响应已开始写出时只能记录日志。
+func (a *API) Recover(logger *log.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rw := &responseWriter{ResponseWriter: w, status: http.StatusOK}
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			// ErrAbortHandler 用于主动中断响应，交还 net/http 处理。
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+			id := RequestID(r)
+			a.svc.CountPanic()
+			logger.Printf("PANIC %s %s request_id=%s: %v\n%s", r.Method, r.URL.Path, id, v, debug.Stack())
+			if rw.wrote {
+				return
+			}
+			writeJSON(w, http.StatusInternalServerError, response{Ok: false, Error: "服务器内部错误", Code: CodeInternalPanic, RequestID: id})
+		}()
+		next.ServeHTTP(rw, r)
+	})
+}
+
+// LoggingMiddleware 为每个请求输出一行访问日志，只记录方法、路径、状态码、耗时与请求 ID，
+// 从不记录请求体、查询参数或请求头，以免令牌与密码进入访问日志。
+// 耗时超过 Config.SlowRequest 的请求以 SLOW 开头并计入统计，
+// 执行超过 Config.InFlightLogAfter 仍未完成的请求会在完成前额外记录一次“进行中”。
+// 格式、排除路径与只记录错误的选项在此处解析一次，之后每个请求不再读取配置。
+func (a *API) LoggingMiddleware(logger *log.Logger, next http.Handler) http.Handler {
+	cfg := a.svc.Config()
+	exclude := newPathMatcher(cfg.AccessLogExclude)
+	format := accessFormatterFor(cfg.AccessLogFormat)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rw := &responseWriter{ResponseWriter: w, status: http.StatusOK}
+		id := RequestID(r)
+		excluded := exclude.match(r.URL.Path)
+		// 事件流是长连接，不计入慢请求，也不记录“进行中”。
+		streaming := isEventStream(r)
+		if cfg.InFlightLogAfter > 0 && !excluded && !streaming {
+			timer := time.AfterFunc(cfg.InFlightLogAfter, func() {
+				logger.Printf("IN PROGRESS %s %s 已执行 %s request_id=%s", r.Method, r.URL.Path, time.Since(start).Round(time.Millisecond), id)
+			})
+			defer timer.Stop()
+		}
+		next.ServeHTTP(rw, r)
+		elapsed := time.Since(start)
+		slow := cfg.SlowRequest > 0 && elapsed > cfg.SlowRequest && !streaming
+		if slow {
+			a.svc.CountSlowRequest()
+		}
+		if excluded || (cfg.AccessLogErrorsOnly && !slow && rw.status >= 200 && rw.status < 300) {
+			return
+		}
+		logger.Print(format(r, accessEntry{
+			start:    start,
+			elapsed:  elapsed,
+			status:   rw.status,
+			bytes:    rw.bytes,
+			id:       id,
+			clientCN: core.ClientCertCN(r),
+			slow:     slow,
+		}))
+	})
+}
+
+type responseWriter struct {
+	http.ResponseWriter
+	status int
+	// wrote 表示已开始写出响应，之后无法再改写状态码。
+	wrote bool
+	bytes int64
+}
+
+func (rw *responseWriter) WriteHeader(status int) {
+	rw.status = status
+	rw.wrote = true
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+func (rw *responseWriter) Write(p []byte) (int, error) {
+	rw.wrote = true
+	n, err := rw.ResponseWriter.Write(p)
+	rw.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap 供 http.ResponseController 访问底层 ResponseWriter。
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
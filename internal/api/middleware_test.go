@@ -0,0 +1,235 @@
+package api_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"codex-backup-tool/internal/api"
+	"codex-backup-tool/internal/core"
+)
+
+// syncBuffer 允许“进行中”日志的定时器与测试并发访问。
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestLoggingMiddlewareTagsSlowRequests(t *testing.T) {
+	handlers, svc := newTestAPI(t, func(cfg *core.Config) {
+		cfg.SlowRequest = 20 * time.Millisecond
+		cfg.InFlightLogAfter = 10 * time.Millisecond
+	})
+	var out syncBuffer
+	logger := log.New(&out, "", 0)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) { time.Sleep(60 * time.Millisecond) })
+	mux.HandleFunc("/fast", func(w http.ResponseWriter, r *http.Request) {})
+	srv := httptest.NewServer(api.WithRequestID(handlers.LoggingMiddleware(logger, mux)))
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/slow", nil)
+	req.Header.Set(api.RequestIDHeader, "req-123")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("slow: %v", err)
+	}
+	resp.Body.Close()
+	if resp.Header.Get(api.RequestIDHeader) != "req-123" {
+		t.Fatalf("expected request id to be echoed, got %q", resp.Header.Get(api.RequestIDHeader))
+	}
+	resp, err = http.Get(srv.URL + "/fast")
+	if err != nil {
+		t.Fatalf("fast: %v", err)
+	}
+	resp.Body.Close()
+	if resp.Header.Get(api.RequestIDHeader) == "" {
+		t.Fatalf("expected generated request id")
+	}
+
+	logs := out.String()
+	if !strings.Contains(logs, "SLOW GET /slow 200") || !strings.Contains(logs, "request_id=req-123") {
+		t.Fatalf("expected slow entry with request id, got:\n%s", logs)
+	}
+	if strings.Count(logs, "IN PROGRESS GET /slow") != 1 || strings.Contains(logs, "SLOW GET /fast") {
+		t.Fatalf("unexpected log lines:\n%s", logs)
+	}
+	if got := svc.Stats().SlowRequests; got != 1 {
+		t.Fatalf("expected 1 slow request in stats, got %d", got)
+	}
+}
+
+func TestErrorResponsesIncludeRequestID(t *testing.T) {
+	handlers, svc := newTestAPI(t)
+	mux := http.NewServeMux()
+	handlers.Register(mux)
+	srv := httptest.NewServer(api.WithRequestID(mux))
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodDelete, srv.URL+"/api/backups/missing", nil)
+	req.Header.Set(api.RequestIDHeader, "req-404")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	var body struct {
+		Ok        bool   `json:"ok"`
+		RequestID string `json:"request_id"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.StatusCode != http.StatusNotFound || body.Ok || body.RequestID != "req-404" || resp.Header.Get(api.RequestIDHeader) != "req-404" {
+		t.Fatalf("expected the request id in the error response, got %d %+v", resp.StatusCode, body)
+	}
+	entries, err := svc.Audit().Recent(1)
+	if err != nil || len(entries) != 1 || entries[0].RequestID != "req-404" {
+		t.Fatalf("expected the request id in the audit entry, got %+v (%v)", entries, err)
+	}
+
+	// 成功响应不重复携带 request_id，只通过响应头返回。
+	resp, err = http.Get(srv.URL + "/api/status")
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	raw := new(bytes.Buffer)
+	_, _ = raw.ReadFrom(resp.Body)
+	resp.Body.Close()
+	if resp.Header.Get(api.RequestIDHeader) == "" || strings.Contains(raw.String(), `"request_id"`) {
+		t.Fatalf("expected the id only in the header for a successful response, got %q", raw.String())
+	}
+}
+
+func TestRecoverReturnsStructuredError(t *testing.T) {
+	handlers, svc := newTestAPI(t)
+	var out syncBuffer
+	logger := log.New(&out, "", 0)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/boom", func(w http.ResponseWriter, r *http.Request) { panic("boom") })
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {})
+	srv := httptest.NewServer(api.WithRequestID(handlers.Recover(logger, handlers.LoggingMiddleware(logger, mux))))
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/boom", nil)
+	req.Header.Set(api.RequestIDHeader, "req-panic")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("boom: %v", err)
+	}
+	var body struct {
+		Ok        bool   `json:"ok"`
+		Code      string `json:"code"`
+		RequestID string `json:"request_id"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.StatusCode != http.StatusInternalServerError || body.Ok || body.Code != api.CodeInternalPanic || body.RequestID != "req-panic" {
+		t.Fatalf("unexpected response: %d %+v", resp.StatusCode, body)
+	}
+	if !strings.Contains(out.String(), "PANIC GET /boom request_id=req-panic: boom") {
+		t.Fatalf("expected panic log with request id, got %q", out.String())
+	}
+
+	resp, err = http.Get(srv.URL + "/ok")
+	if err != nil {
+		t.Fatalf("ok: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected server to keep serving, got %d", resp.StatusCode)
+	}
+	if got := svc.Stats().Panics; got != 1 {
+		t.Fatalf("expected 1 panic, got %d", got)
+	}
+}
+
+func TestLoggingMiddlewareExclusions(t *testing.T) {
+	handlers, _ := newTestAPI(t, func(cfg *core.Config) {
+		cfg.AccessLogExclude = []string{"/api/status", "/assets/*"}
+		cfg.AccessLogErrorsOnly = true
+	})
+	var out syncBuffer
+	logger := log.New(&out, "", 0)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/missing") {
+			http.NotFound(w, r)
+		}
+	})
+	srv := httptest.NewServer(api.WithRequestID(handlers.LoggingMiddleware(logger, mux)))
+	defer srv.Close()
+
+	for _, path := range []string{"/api/status", "/api/status/foo", "/assets/app.js", "/assets/missing", "/api/status/missing"} {
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatalf("get %s: %v", path, err)
+		}
+		resp.Body.Close()
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 1 || !strings.HasPrefix(lines[0], "GET /api/status/missing 404 ") {
+		t.Fatalf("expected only the non-excluded error to be logged, got %q", out.String())
+	}
+}
+
+func TestLoggingMiddlewareFormats(t *testing.T) {
+	for _, format := range []string{core.AccessLogFormatJSON, core.AccessLogFormatCombined} {
+		handlers, _ := newTestAPI(t, func(cfg *core.Config) { cfg.AccessLogFormat = format })
+		var out syncBuffer
+		logger := log.New(&out, "", 0)
+		handler := api.WithRequestID(handlers.LoggingMiddleware(logger, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("hello"))
+		})))
+		req := httptest.NewRequest(http.MethodGet, "/api/status?token=secret", nil)
+		req.Header.Set(api.RequestIDHeader, "req-1")
+		req.Header.Set("Referer", "http://localhost/?token=secret")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		line := strings.TrimSpace(out.String())
+		if strings.Contains(line, "secret") {
+			t.Fatalf("%s: query string leaked into access log: %q", format, line)
+		}
+		switch format {
+		case core.AccessLogFormatJSON:
+			var entry struct {
+				Method    string `json:"method"`
+				Path      string `json:"path"`
+				Status    int    `json:"status"`
+				Bytes     int64  `json:"bytes"`
+				RequestID string `json:"request_id"`
+			}
+			if err := json.Unmarshal([]byte(line), &entry); err != nil {
+				t.Fatalf("json: %v (%q)", err, line)
+			}
+			if entry.Method != "GET" || entry.Path != "/api/status" || entry.Status != 200 || entry.Bytes != 5 || entry.RequestID != "req-1" {
+				t.Fatalf("unexpected json entry %+v", entry)
+			}
+		case core.AccessLogFormatCombined:
+			if !strings.Contains(line, `"GET /api/status HTTP/1.1" 200 5 "http://localhost/" "-"`) {
+				t.Fatalf("unexpected combined line %q", line)
+			}
+		}
+	}
+}
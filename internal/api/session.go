@@ -0,0 +1,149 @@
+package api
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	sessionCookieName = "codex_backup_session"
+	csrfHeader        = "X-CSRF-Token"
+	// CodeCSRFFailed 为 CSRF 校验失败时响应中的错误代码。
+	CodeCSRFFailed = "CSRF_FAILED"
+)
+
+type session struct {
+	createdAt time.Time
+	lastSeen  time.Time
+	// csrfToken 与会话绑定，Cookie 认证的写请求需在 X-CSRF-Token 头中回传。
+	csrfToken string
+}
+
+// sessionStore 为内存中的登录会话表，进程重启后所有会话失效。
+type sessionStore struct {
+	mu       sync.Mutex
+	idle     time.Duration
+	max      int
+	sessions map[string]*session
+	now      func() time.Time
+}
+
+func newSessionStore(idle time.Duration, max int) *sessionStore {
+	if idle <= 0 {
+		idle = 30 * time.Minute
+	}
+	if max <= 0 {
+		max = 5
+	}
+	return &sessionStore{
+		idle:     idle,
+		max:      max,
+		sessions: make(map[string]*session),
+		now:      time.Now,
+	}
+}
+
+// create 签发新的会话 ID 及其 CSRF 令牌。超出会话上限时淘汰最久未活动的会话。
+func (s *sessionStore) create() (string, string, error) {
+	id, err := randomToken()
+	if err != nil {
+		return "", "", err
+	}
+	csrf, err := randomToken()
+	if err != nil {
+		return "", "", err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	s.pruneLocked(now)
+	for len(s.sessions) >= s.max {
+		var oldestID string
+		var oldest time.Time
+		for sid, sess := range s.sessions {
+			if oldestID == "" || sess.lastSeen.Before(oldest) {
+				oldestID, oldest = sid, sess.lastSeen
+			}
+		}
+		delete(s.sessions, oldestID)
+	}
+	s.sessions[id] = &session{createdAt: now, lastSeen: now, csrfToken: csrf}
+	return id, csrf, nil
+}
+
+// touch 校验会话是否有效并刷新空闲计时，返回会话绑定的 CSRF 令牌。
+func (s *sessionStore) touch(id string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.sessions[id]
+	if !ok {
+		return "", false
+	}
+	now := s.now()
+	if now.Sub(sess.lastSeen) >= s.idle {
+		delete(s.sessions, id)
+		return "", false
+	}
+	sess.lastSeen = now
+	return sess.csrfToken, true
+}
+
+func (s *sessionStore) revoke(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, id)
+}
+
+func (s *sessionStore) pruneLocked(now time.Time) {
+	for id, sess := range s.sessions {
+		if now.Sub(sess.lastSeen) >= s.idle {
+			delete(s.sessions, id)
+		}
+	}
+}
+
+func randomToken() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// validCSRF 以常量时间比较请求头中的 CSRF 令牌。
+func validCSRF(r *http.Request, expected string) bool {
+	got := r.Header.Get(csrfHeader)
+	return got != "" && subtle.ConstantTimeCompare([]byte(got), []byte(expected)) == 1
+}
+
+func isUnsafeMethod(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+func sessionIDFromRequest(r *http.Request) string {
+	c, err := r.Cookie(sessionCookieName)
+	if err != nil {
+		return ""
+	}
+	return c.Value
+}
+
+func setSessionCookie(w http.ResponseWriter, r *http.Request, id string, maxAge int) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    id,
+		Path:     "/",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
@@ -0,0 +1,107 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// fingerprintedAsset 匹配文件名中带内容哈希的静态资源（如 app.3f2a9c1d.js），这类文件可长期缓存。
+var fingerprintedAsset = regexp.MustCompile(`\.[0-9a-fA-F]{8,}\.[A-Za-z0-9]+$`)
+
+// StaticHandler 提供 root 下的静态文件，root 可以是 os.DirFS 或 embed.FS。
+// 路径先经过清理，含 .. 或以点开头的路径段一律不可访问；目录请求返回其中的 index.html。
+// 找不到文件且最后一段不带扩展名时返回 index.html，由前端自行处理路由；/api/ 下的未知路径返回 404。
+// index.html 与普通资源每次都需重新验证，带内容哈希的资源缓存一年。
+func StaticHandler(root fs.FS) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api" || strings.HasPrefix(r.URL.Path, "/api/") {
+			writeErrorWithMessage(w, http.StatusNotFound, "接口不存在")
+			return
+		}
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			notAllowed(w, http.MethodGet, http.MethodHead)
+			return
+		}
+		name, ok := staticName(r.URL.Path)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if serveStaticFile(w, r, root, name) {
+			return
+		}
+		if path.Ext(name) != "" || !serveStaticFile(w, r, root, "index.html") {
+			http.NotFound(w, r)
+		}
+	})
+}
+
+// staticName 将请求路径转换为 fs.FS 中的文件名，空字符串表示根目录。
+// r.URL.Path 已完成百分号解码，/..%2fconfig.json 在这里表现为 /../config.json。
+func staticName(urlPath string) (string, bool) {
+	if strings.Contains(urlPath, "\\") || strings.Contains(urlPath, "\x00") {
+		return "", false
+	}
+	for _, seg := range strings.Split(urlPath, "/") {
+		if seg == ".." || (strings.HasPrefix(seg, ".") && seg != ".") {
+			return "", false
+		}
+	}
+	name := strings.TrimPrefix(path.Clean("/"+urlPath), "/")
+	if name == "" {
+		name = "index.html"
+	}
+	return name, fs.ValidPath(name)
+}
+
+// serveStaticFile 写出 name 对应的文件，文件不存在时返回 false 且不写入任何内容。
+func serveStaticFile(w http.ResponseWriter, r *http.Request, root fs.FS, name string) bool {
+	info, err := fs.Stat(root, name)
+	if err == nil && info.IsDir() {
+		name = path.Join(name, "index.html")
+		info, err = fs.Stat(root, name)
+	}
+	if err != nil || info.IsDir() {
+		return false
+	}
+	f, err := root.Open(name)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	content, ok := f.(io.ReadSeeker)
+	if !ok {
+		data, err := io.ReadAll(f)
+		if err != nil {
+			if !errors.Is(err, fs.ErrNotExist) {
+				http.Error(w, "读取静态文件失败", http.StatusInternalServerError)
+				return true
+			}
+			return false
+		}
+		content = bytes.NewReader(data)
+	}
+	if ctype := mime.TypeByExtension(path.Ext(name)); ctype != "" {
+		w.Header().Set("Content-Type", ctype)
+	}
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if fingerprintedAsset.MatchString(name) {
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	} else {
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+	modTime := info.ModTime()
+	if modTime.Equal(time.Unix(0, 0)) {
+		modTime = time.Time{}
+	}
+	http.ServeContent(w, r, name, modTime, content)
+	return true
+}
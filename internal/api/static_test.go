@@ -0,0 +1,162 @@
+package api_test
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"codex-backup-tool/internal/api"
+)
+
+// newStaticServer 在临时目录中准备 web 根目录，并在根目录之外放置一个不应被访问到的 config.json。
+func newStaticServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	base := t.TempDir()
+	web := filepath.Join(base, "web")
+	files := map[string]string{
+		"config.json":             `{"secret":"outside-web-root"}`,
+		"web/index.html":          "<!doctype html><title>index</title>",
+		"web/app.js":              "console.log('app')",
+		"web/app.3f2a9c1d.js":     "console.log('fingerprinted')",
+		"web/style.css":           "body{}",
+		"web/.env":                "SECRET=1",
+		"web/assets/index.html":   "<p>assets</p>",
+		"web/assets/img/logo.svg": "<svg></svg>",
+	}
+	for name, content := range files {
+		path := filepath.Join(base, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/", api.StaticHandler(os.DirFS(web)))
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestStaticHandlerServesFiles(t *testing.T) {
+	srv := newStaticServer(t)
+	cases := []struct {
+		path, contentType, cache, body string
+	}{
+		{"/", "text/html; charset=utf-8", "no-cache", "<title>index</title>"},
+		{"/app.js", "text/javascript; charset=utf-8", "no-cache", "console.log('app')"},
+		{"/app.3f2a9c1d.js", "text/javascript; charset=utf-8", "public, max-age=31536000, immutable", "fingerprinted"},
+		{"/style.css", "text/css; charset=utf-8", "no-cache", "body{}"},
+		{"/assets/img/logo.svg", "image/svg+xml", "no-cache", "<svg>"},
+		{"/assets/", "text/html; charset=utf-8", "no-cache", "<p>assets</p>"},
+		// 前端路由回退到 index.html。
+		{"/backups/123", "text/html; charset=utf-8", "no-cache", "<title>index</title>"},
+	}
+	for _, tc := range cases {
+		resp, err := http.Get(srv.URL + tc.path)
+		if err != nil {
+			t.Fatalf("get %s: %v", tc.path, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("%s: status %d", tc.path, resp.StatusCode)
+		}
+		if got := resp.Header.Get("Content-Type"); got != tc.contentType {
+			t.Errorf("%s: content type %q, want %q", tc.path, got, tc.contentType)
+		}
+		if got := resp.Header.Get("Cache-Control"); got != tc.cache {
+			t.Errorf("%s: cache control %q, want %q", tc.path, got, tc.cache)
+		}
+		if !strings.Contains(string(body), tc.body) {
+			t.Errorf("%s: unexpected body %q", tc.path, body)
+		}
+	}
+}
+
+func TestStaticHandlerNotFound(t *testing.T) {
+	srv := newStaticServer(t)
+	for _, path := range []string{"/missing.js", "/assets/missing.png", "/.env", "/api/unknown"} {
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatalf("get %s: %v", path, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("%s: status %d, want 404", path, resp.StatusCode)
+		}
+	}
+	resp, err := http.Post(srv.URL+"/app.js", "text/plain", nil)
+	if err != nil {
+		t.Fatalf("post: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatalf("post: status %d, want 405", resp.StatusCode)
+	}
+}
+
+func TestStaticHandlerRejectsTraversal(t *testing.T) {
+	srv := newStaticServer(t)
+	addr := strings.TrimPrefix(srv.URL, "http://")
+	for _, target := range []string{
+		"/..%2fconfig.json",
+		"/%2e%2e/config.json",
+		"/%2e%2e%2fconfig.json",
+		"/assets/..%2f..%2fconfig.json",
+		"/..%5cconfig.json",
+		"/../config.json",
+		"/%2e%2e%2f%2e%2e%2fconfig.json",
+	} {
+		// 直接写原始请求行，避免客户端预先规范化路径。
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			t.Fatalf("dial: %v", err)
+		}
+		fmt.Fprintf(conn, "GET %s HTTP/1.1\r\nHost: %s\r\nConnection: close\r\n\r\n", target, addr)
+		resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+		if err != nil {
+			conn.Close()
+			t.Fatalf("%s: read response: %v", target, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		conn.Close()
+		if strings.Contains(string(body), "outside-web-root") {
+			t.Fatalf("%s: served file outside web root", target)
+		}
+		if resp.StatusCode == http.StatusOK && !strings.Contains(string(body), "<title>index</title>") {
+			t.Fatalf("%s: unexpected body %q", target, body)
+		}
+	}
+}
+
+func TestStaticHandlerRejectsTraversalWithoutMux(t *testing.T) {
+	base := t.TempDir()
+	if err := os.WriteFile(filepath.Join(base, "config.json"), []byte("outside-web-root"), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	web := filepath.Join(base, "web")
+	if err := os.MkdirAll(web, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	handler := api.StaticHandler(os.DirFS(web))
+	// ServeMux 会先清理路径，这里直接调用处理器，确认处理器自身也不会越界。
+	for _, p := range []string{"/../config.json", "/..\\config.json", "/a/../../config.json"} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.URL.Path = p
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusNotFound || strings.Contains(rec.Body.String(), "outside-web-root") {
+			t.Fatalf("%s: status %d body %q", p, rec.Code, rec.Body.String())
+		}
+	}
+}
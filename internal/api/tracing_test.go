@@ -0,0 +1,96 @@
+package api_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"codex-backup-tool/internal/core"
+	"codex-backup-tool/internal/tracing"
+)
+
+func TestTracingSpansForScanAndRestore(t *testing.T) {
+	exporter := &tracing.InMemoryExporter{}
+	handlers, svc := newTestAPI(t, func(cfg *core.Config) { cfg.SpanExporter = exporter })
+	mux := http.NewServeMux()
+	handlers.Register(mux)
+	srv := httptest.NewServer(handlers.Tracing(mux, handlers.RequireAuth(mux)))
+	defer srv.Close()
+
+	target := svc.Config().TargetPath
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(target, []byte(`{"token":"a"}`), 0o600); err != nil {
+		t.Fatalf("write target: %v", err)
+	}
+	const incomingTrace = "4bf92f3577b34da6a3ce929d0e0e4736"
+	const incomingSpan = "00f067aa0ba902b7"
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+"/api/scan", nil)
+	req.Header.Set("traceparent", "00-"+incomingTrace+"-"+incomingSpan+"-01")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	resp.Body.Close()
+	items, err := svc.ListBackups()
+	if err != nil || len(items) != 1 {
+		t.Fatalf("expected one backup, got %d (%v)", len(items), err)
+	}
+	resp, err = http.Post(srv.URL+"/api/backups/"+items[0].ID+"/restore", "application/json", nil)
+	if err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("restore status %d", resp.StatusCode)
+	}
+	svc.Tracer().Flush()
+
+	byName := map[string]tracing.SpanData{}
+	for _, sd := range exporter.Spans() {
+		if _, seen := byName[sd.Name]; !seen {
+			byName[sd.Name] = sd
+		}
+	}
+	get := func(name string) tracing.SpanData {
+		t.Helper()
+		sd, ok := byName[name]
+		if !ok {
+			t.Fatalf("missing span %q, got %v", name, spanNames(exporter.Spans()))
+		}
+		return sd
+	}
+	scanServer := get("POST /api/scan")
+	if scanServer.Kind != tracing.KindServer || scanServer.TraceID.String() != incomingTrace || scanServer.ParentID.String() != incomingSpan {
+		t.Fatalf("server span should continue the incoming trace: %+v", scanServer)
+	}
+	scan := get("scan")
+	if scan.ParentID != scanServer.SpanID || scan.TraceID != scanServer.TraceID {
+		t.Fatalf("scan span should be a child of the server span")
+	}
+	for _, phase := range []string{"scan.stat", "scan.hash", "scan.write", "scan.index_update"} {
+		if child := get(phase); child.ParentID != scan.SpanID {
+			t.Fatalf("%s should be a child of the scan span", phase)
+		}
+	}
+	restoreServer := get("POST /api/backups/")
+	restore := get("restore")
+	if restore.ParentID != restoreServer.SpanID || restore.TraceID != restoreServer.TraceID {
+		t.Fatalf("restore span should be a child of the server span")
+	}
+	if restoreServer.TraceID == scanServer.TraceID {
+		t.Fatalf("requests without traceparent should start a new trace")
+	}
+}
+
+func spanNames(spans []tracing.SpanData) string {
+	names := make([]string, 0, len(spans))
+	for _, sd := range spans {
+		names = append(names, sd.Name)
+	}
+	return strings.Join(names, ",")
+}
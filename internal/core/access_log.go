@@ -0,0 +1,30 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 访问日志格式。
+const (
+	// AccessLogFormatDefault 为 "GET /api/status 200 1.2ms request_id=..." 形式的单行日志。
+	AccessLogFormatDefault = "default"
+	// AccessLogFormatCombined 为 Apache combined 格式，请求行与 Referer 不含查询参数。
+	AccessLogFormatCombined = "combined"
+	// AccessLogFormatJSON 每行输出一个 JSON 对象。
+	AccessLogFormatJSON = "json"
+)
+
+func validateAccessLog(format string, exclude []string) error {
+	switch format {
+	case "", AccessLogFormatDefault, AccessLogFormatCombined, AccessLogFormatJSON:
+	default:
+		return fmt.Errorf("access_log_format 只能是 %s、%s 或 %s: %q", AccessLogFormatDefault, AccessLogFormatCombined, AccessLogFormatJSON, format)
+	}
+	for _, p := range exclude {
+		if !strings.HasPrefix(p, "/") {
+			return fmt.Errorf("access_log_exclude 中的路径必须以 / 开头: %q", p)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,231 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"codex-backup-tool/internal/util"
+)
+
+// 审计日志中的来源。
+const (
+	AuditSourceHTTP      = "http"
+	AuditSourceCLI       = "cli"
+	AuditSourceScheduler = "scheduler"
+)
+
+// 审计日志中的操作结果。
+const (
+	AuditOutcomeOK     = "ok"
+	AuditOutcomeFailed = "failed"
+)
+
+const (
+	// auditSyncInterval 为两次 fsync 的最大间隔，期间的写入合并为一次落盘。
+	auditSyncInterval = time.Second
+	// auditKeep 为按大小轮转后保留的历史审计日志份数（audit.log.1 … audit.log.N）。
+	auditKeep = 3
+)
+
+// AuditEntry 为 audit.log 中的一行记录。
+type AuditEntry struct {
+	Time      time.Time `json:"ts"`
+	Op        string    `json:"op"`
+	ItemID    string    `json:"item_id,omitempty"`
+	Source    string    `json:"source"`
+	ClientIP  string    `json:"client_ip,omitempty"`
+	UserAgent string    `json:"user_agent,omitempty"`
+	ClientCN  string    `json:"client_cn,omitempty"`
+	RequestID string    `json:"request_id,omitempty"`
+	Outcome   string    `json:"outcome"`
+	Error     string    `json:"error,omitempty"`
+	// InstanceID 为写入记录的数据目录实例 ID。
+	InstanceID string `json:"instance_id,omitempty"`
+}
+
+// AuditLog 以 JSON Lines 追加写入审计记录，进程重启后保留。
+// 每条记录写入后立即交给操作系统，fsync 按 auditSyncInterval 合并执行；超过 maxSize 时按大小轮转。
+type AuditLog struct {
+	path    string
+	maxSize int64
+
+	mu       sync.Mutex
+	f        *os.File
+	size     int64
+	dirty    bool
+	lastSync time.Time
+	timer    *time.Timer
+}
+
+// NewAuditLog 创建审计日志，文件在首次写入时打开。maxSize 为 0 表示不轮转。
+func NewAuditLog(path string, maxSize int64) *AuditLog {
+	return &AuditLog{path: path, maxSize: maxSize}
+}
+
+// Record 追加一条审计记录，返回前数据已写入文件，但不保证已 fsync。
+func (a *AuditLog) Record(entry AuditEntry) error {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now().UTC()
+	}
+	if entry.Outcome == "" {
+		entry.Outcome = AuditOutcomeOK
+	}
+	entry.Error = util.Redact(entry.Error)
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err := a.openLocked(); err != nil {
+		return err
+	}
+	if a.maxSize > 0 && a.size > 0 && a.size+int64(len(line)) > a.maxSize {
+		if err := a.rotateLocked(); err != nil {
+			return err
+		}
+	}
+	n, err := a.f.Write(line)
+	a.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("write audit log: %w", err)
+	}
+	a.dirty = true
+	if time.Since(a.lastSync) >= auditSyncInterval {
+		return a.syncLocked()
+	}
+	if a.timer == nil {
+		a.timer = time.AfterFunc(auditSyncInterval, func() {
+			a.mu.Lock()
+			defer a.mu.Unlock()
+			a.timer = nil
+			_ = a.syncLocked()
+		})
+	}
+	return nil
+}
+
+// Recent 返回最近的 limit 条记录（新的在前），当前文件不足时继续读取轮转后的历史文件。
+func (a *AuditLog) Recent(limit int) ([]AuditEntry, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	entries := make([]AuditEntry, 0)
+	for i := 0; i <= auditKeep && len(entries) < limit; i++ {
+		data, exists, err := util.ReadFileIfExists(rotatedPath(a.path, i))
+		if err != nil {
+			return nil, fmt.Errorf("read audit log: %w", err)
+		}
+		if !exists {
+			continue
+		}
+		var file []AuditEntry
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+		for scanner.Scan() {
+			var entry AuditEntry
+			// 崩溃时可能留下半行，跳过无法解析的行。
+			if err := json.Unmarshal(scanner.Bytes(), &entry); err == nil {
+				file = append(file, entry)
+			}
+		}
+		for j := len(file) - 1; j >= 0 && len(entries) < limit; j-- {
+			entries = append(entries, file[j])
+		}
+	}
+	return entries, nil
+}
+
+// Close 将未落盘的记录 fsync 后关闭文件，之后的 Record 会重新打开文件。
+func (a *AuditLog) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.timer != nil {
+		a.timer.Stop()
+		a.timer = nil
+	}
+	if a.f == nil {
+		return nil
+	}
+	err := a.syncLocked()
+	if cerr := a.f.Close(); err == nil {
+		err = cerr
+	}
+	a.f = nil
+	return err
+}
+
+func (a *AuditLog) openLocked() error {
+	if a.f != nil {
+		return nil
+	}
+	if err := util.EnsureDir(filepath.Dir(a.path)); err != nil {
+		return fmt.Errorf("ensure audit dir: %w", err)
+	}
+	f, err := os.OpenFile(util.LongPath(a.path), os.O_CREATE|os.O_APPEND|os.O_RDWR, 0o600)
+	if err != nil {
+		return fmt.Errorf("open audit log: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("stat audit log: %w", err)
+	}
+	size := info.Size()
+	// 上次崩溃可能留下不完整的最后一行，先补换行，避免新记录与其拼接成无法解析的行。
+	if size > 0 {
+		last := make([]byte, 1)
+		if _, err := f.ReadAt(last, size-1); err == nil && last[0] != '\n' {
+			n, err := f.Write([]byte{'\n'})
+			size += int64(n)
+			if err != nil {
+				f.Close()
+				return fmt.Errorf("write audit log: %w", err)
+			}
+		}
+	}
+	a.f = f
+	a.size = size
+	return nil
+}
+
+func (a *AuditLog) syncLocked() error {
+	if a.f == nil || !a.dirty {
+		return nil
+	}
+	if err := a.f.Sync(); err != nil {
+		return fmt.Errorf("sync audit log: %w", err)
+	}
+	a.dirty = false
+	a.lastSync = time.Now()
+	return nil
+}
+
+// rotateLocked 将 audit.log 依次后移为 audit.log.1 … audit.log.N 并重新打开空文件。
+func (a *AuditLog) rotateLocked() error {
+	if err := a.syncLocked(); err != nil {
+		return err
+	}
+	if err := a.f.Close(); err != nil {
+		return fmt.Errorf("close audit log: %w", err)
+	}
+	a.f = nil
+	if err := rotateFiles(a.path, auditKeep); err != nil {
+		return fmt.Errorf("rotate audit log: %w", err)
+	}
+	return a.openLocked()
+}
+
+// isLogFile 判断文件名是否为 path 指向的日志或其轮转文件，清空数据时保留审计与事件日志。
+func isLogFile(path, name string) bool {
+	base := filepath.Base(path)
+	return name == base || strings.HasPrefix(name, base+".")
+}
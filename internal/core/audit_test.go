@@ -0,0 +1,80 @@
+package core_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"codex-backup-tool/internal/core"
+)
+
+func TestAuditLogRecordAndRecent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	audit := core.NewAuditLog(path, 0)
+	for _, op := range []string{"backup.create", "backup.restore", "backup.delete"} {
+		if err := audit.Record(core.AuditEntry{Op: op, ItemID: "id-" + op, Source: core.AuditSourceHTTP, ClientIP: "127.0.0.1"}); err != nil {
+			t.Fatalf("record %s: %v", op, err)
+		}
+	}
+	if err := audit.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	// 模拟崩溃时留下的半行，读取时应被跳过。
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	f.WriteString(`{"ts":"2024-01-01T00:00:00Z","op":"trunc`)
+	f.Close()
+
+	// 关闭后重新写入会追加到同一文件，模拟进程重启。
+	reopened := core.NewAuditLog(path, 0)
+	defer reopened.Close()
+	if err := reopened.Record(core.AuditEntry{Op: "login", Source: core.AuditSourceHTTP, Outcome: core.AuditOutcomeFailed, Error: "password=hunter2"}); err != nil {
+		t.Fatalf("record after reopen: %v", err)
+	}
+	entries, err := reopened.Recent(10)
+	if err != nil {
+		t.Fatalf("recent: %v", err)
+	}
+	if len(entries) != 4 || entries[0].Op != "login" || entries[1].Op != "backup.delete" || entries[3].Op != "backup.create" {
+		t.Fatalf("unexpected entries: %+v", entries)
+	}
+	if entries[1].Outcome != core.AuditOutcomeOK || entries[1].Time.IsZero() {
+		t.Fatalf("defaults not applied: %+v", entries[1])
+	}
+
+	latest, err := reopened.Recent(1)
+	if err != nil || len(latest) != 1 || latest[0].Op != "login" {
+		t.Fatalf("expected latest login entry, got %+v %v", latest, err)
+	}
+	if strings.Contains(latest[0].Error, "hunter2") {
+		t.Fatalf("audit error must be redacted: %q", latest[0].Error)
+	}
+}
+
+func TestAuditLogRotatesBySize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	audit := core.NewAuditLog(path, 300)
+	defer audit.Close()
+	for i := 0; i < 20; i++ {
+		if err := audit.Record(core.AuditEntry{Op: "backup.create", ItemID: strings.Repeat("x", 40), Source: core.AuditSourceScheduler}); err != nil {
+			t.Fatalf("record %d: %v", i, err)
+		}
+	}
+	info, err := os.Stat(path)
+	if err != nil || info.Size() > 300 {
+		t.Fatalf("expected current log within limit, got %v %v", info, err)
+	}
+	if _, err := os.Stat(path + ".3"); err != nil {
+		t.Fatalf("expected rotated audit.log.3: %v", err)
+	}
+	if _, err := os.Stat(path + ".4"); !os.IsNotExist(err) {
+		t.Fatalf("expected at most 3 rotated files, got %v", err)
+	}
+	entries, err := audit.Recent(100)
+	if err != nil || len(entries) == 0 || len(entries) >= 20 {
+		t.Fatalf("expected entries from current and kept files only, got %d %v", len(entries), err)
+	}
+}
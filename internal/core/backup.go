@@ -1,7 +1,10 @@
 package core
 
 import (
+	"bytes"
+	"compress/gzip"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -10,10 +13,67 @@ import (
 	"codex-backup-tool/internal/util"
 )
 
-// BuildBackupFilename 根据时间戳与内容哈希生成文件名。
-func BuildBackupFilename(ts time.Time, contentHash string) string {
+// 备份文件名与默认备注中时间的格式。
+const (
+	// TimestampsLocal 使用本地时间并附带数值时区偏移，如 20060102-150405+0800。
+	TimestampsLocal = "local"
+	// TimestampsUTC 使用 UTC 时间并以 Z 结尾，如 20060102-150405Z。
+	TimestampsUTC = "utc"
+)
+
+// FormatTimestamp 按 mode 格式化文件名与默认备注中的时间。本地时间附带时区偏移，
+// 夏令时回拨的重复一小时或在不同时区的机器间迁移数据目录时，仍能还原出唯一的时刻。
+func FormatTimestamp(t time.Time, mode string) string {
+	if mode == TimestampsUTC {
+		return t.UTC().Format("20060102-150405Z")
+	}
+	return t.Local().Format("20060102-150405-0700")
+}
+
+// 备份文件的压缩方式。
+const (
+	// CompressionNone 按原样保存备份内容。
+	CompressionNone = ""
+	// CompressionGzip 以 gzip 压缩保存，文件名以 .json.gz 结尾。
+	CompressionGzip = "gzip"
+)
+
+// gzipSuffix 为 gzip 压缩备份文件名的后缀，读取时据此判断是否需要解压。
+const gzipSuffix = ".gz"
+
+// BuildBackupFilename 根据时间戳与内容哈希生成文件名，mode 取值见 Timestamps* 常量，
+// compression 为 CompressionGzip 时扩展名为 .json.gz。
+func BuildBackupFilename(ts time.Time, mode, contentHash, compression string) string {
 	short := ShortHash(contentHash)
-	return fmt.Sprintf("%s_%s.json", ts.Format("20060102-150405"), short)
+	name := fmt.Sprintf("%s_%s.json", FormatTimestamp(ts, mode), short)
+	if compression == CompressionGzip {
+		name += gzipSuffix
+	}
+	return name
+}
+
+// IsCompressedBackup 判断备份文件是否以 gzip 压缩保存。
+func IsCompressedBackup(filename string) bool {
+	return strings.HasSuffix(filename, gzipSuffix)
+}
+
+// DecompressedFilename 返回备份解压后对应的文件名：压缩备份去掉 .gz 后缀，其他原样返回。
+func DecompressedFilename(filename string) string {
+	return strings.TrimSuffix(filename, gzipSuffix)
+}
+
+// gzipReadCloser 在关闭时同时关闭 gzip 读取器与底层文件。
+type gzipReadCloser struct {
+	*gzip.Reader
+	src io.Closer
+}
+
+func (g *gzipReadCloser) Close() error {
+	err := g.Reader.Close()
+	if cerr := g.src.Close(); err == nil {
+		err = cerr
+	}
+	return err
 }
 
 // EnsureUniqueFilename 确保文件名在目录下唯一。
@@ -26,10 +86,14 @@ func EnsureUniqueFilename(backupsDir, base string) (string, error) {
 	if _, err := os.Stat(path); os.IsNotExist(err) {
 		return candidate, nil
 	}
-	prefix := strings.TrimSuffix(base, ".json")
+	ext := ".json"
+	if IsCompressedBackup(base) {
+		ext += gzipSuffix
+	}
+	prefix := strings.TrimSuffix(base, ext)
 	counter := 1
 	for {
-		candidate = fmt.Sprintf("%s-%d.json", prefix, counter)
+		candidate = fmt.Sprintf("%s-%d%s", prefix, counter, ext)
 		path = filepath.Join(backupsDir, candidate)
 		if _, err := os.Stat(path); os.IsNotExist(err) {
 			return candidate, nil
@@ -38,14 +102,51 @@ func EnsureUniqueFilename(backupsDir, base string) (string, error) {
 	}
 }
 
-// WriteBackupFile 将备份内容写入指定目录，返回文件相对路径。
+// WriteBackupFile 将备份内容写入指定目录，返回文件相对路径；文件名以 .gz 结尾时先以 gzip 压缩。
 func WriteBackupFile(backupsDir, filename string, data []byte) (string, error) {
 	if err := util.EnsureDir(backupsDir); err != nil {
 		return "", err
 	}
+	data, err := encodeBackupData(filename, data)
+	if err != nil {
+		return "", err
+	}
 	path := filepath.Join(backupsDir, filename)
 	if err := util.AtomicWriteFile(path, data, 0o600); err != nil {
 		return "", err
 	}
 	return filename, nil
 }
+
+// encodeBackupData 返回写入备份文件的内容，文件名以 .gz 结尾时以 gzip 压缩。
+func encodeBackupData(filename string, data []byte) ([]byte, error) {
+	if !IsCompressedBackup(filename) {
+		return data, nil
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, fmt.Errorf("压缩备份内容: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("压缩备份内容: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// decodeBackupData 返回备份文件中保存的原始内容，压缩的备份先解压。
+func decodeBackupData(filename string, data []byte) ([]byte, error) {
+	if !IsCompressedBackup(filename) {
+		return data, nil
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("解压备份文件 %s: %w", filename, err)
+	}
+	defer zr.Close()
+	out, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, fmt.Errorf("解压备份文件 %s: %w", filename, err)
+	}
+	return out, nil
+}
@@ -0,0 +1,80 @@
+package core
+
+import (
+	"strings"
+	"time"
+)
+
+// BackupFilter 为备份列表的筛选条件，零值字段表示不限制。
+type BackupFilter struct {
+	// From 与 To 为创建时刻的闭区间边界，From == To 时只匹配恰好在该时刻创建的备份。
+	From time.Time
+	To   time.Time
+	// CreatedAfter 与 CreatedBefore 为开区间边界，恰好在该时刻创建的备份不匹配；可与 From/To 同时使用。
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	// IsAuto 非 nil 时只保留自动（true）或手动（false）备份。
+	IsAuto *bool
+	// RemarkContains 非空时只保留备注包含该子串（不区分大小写）的备份。
+	RemarkContains string
+	// Query 非空时只保留备注或内容哈希前 12 位（见 ShortHash）包含该子串（不区分大小写）的备份。
+	Query string
+	// TagKey 非空时只保留带有该标签的备份；TagValue 非空时还要求标签值与之相等。
+	TagKey   string
+	TagValue string
+}
+
+func (f BackupFilter) match(item *BackupItem) bool {
+	if !f.From.IsZero() && item.CreatedAt.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && item.CreatedAt.After(f.To) {
+		return false
+	}
+	if !f.CreatedAfter.IsZero() && !item.CreatedAt.After(f.CreatedAfter) {
+		return false
+	}
+	if !f.CreatedBefore.IsZero() && !item.CreatedAt.Before(f.CreatedBefore) {
+		return false
+	}
+	if f.IsAuto != nil && item.IsAuto != *f.IsAuto {
+		return false
+	}
+	if f.RemarkContains != "" && !strings.Contains(strings.ToLower(item.Remark), strings.ToLower(f.RemarkContains)) {
+		return false
+	}
+	if f.Query != "" {
+		q := strings.ToLower(f.Query)
+		if !strings.Contains(strings.ToLower(item.Remark), q) && !strings.Contains(strings.ToLower(ShortHash(item.ContentHash)), q) {
+			return false
+		}
+	}
+	if f.TagKey != "" {
+		v, ok := item.Tags[f.TagKey]
+		if !ok || (f.TagValue != "" && v != f.TagValue) {
+			return false
+		}
+	}
+	return true
+}
+
+// ListBackupsFiltered 返回满足 f 的备份，顺序与 ListBackups 相同。
+func (s *Store) ListBackupsFiltered(f BackupFilter) ([]BackupItem, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.refreshUnlocked(); err != nil {
+		return nil, err
+	}
+	return s.sortedItemsUnlocked(f.match), nil
+}
+
+// SearchBackups 返回备注或短内容哈希包含 query（不区分大小写）的备份，顺序与 ListBackups 相同；
+// query 为空时返回全部备份，没有匹配时返回空切片。
+func (s *Store) SearchBackups(query string) ([]BackupItem, error) {
+	return s.ListBackupsFiltered(BackupFilter{Query: strings.TrimSpace(query)})
+}
+
+// ListBackupsFiltered 返回满足 f 的备份，见 Store.ListBackupsFiltered。
+func (s *Service) ListBackupsFiltered(f BackupFilter) ([]BackupItem, error) {
+	return s.store.ListBackupsFiltered(f)
+}
@@ -0,0 +1,115 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// 备份列表的排序键。
+const (
+	SortCreatedAt = "created_at"
+	SortSize      = "size"
+	SortRemark    = "remark"
+)
+
+// 备份列表的排序方向。
+const (
+	OrderAsc  = "asc"
+	OrderDesc = "desc"
+)
+
+// ErrInvalidSort 在排序键或方向未知，或在非 created_at 排序下使用分页游标时返回。
+var ErrInvalidSort = errors.New("invalid sort")
+
+// BackupSort 为备份列表的排序方式，零值即默认的按创建时刻倒序。
+type BackupSort struct {
+	Key   string
+	Order string
+}
+
+// ParseBackupSort 校验排序键与方向：key 为空时按 created_at，order 为空时 created_at 与 size 按 desc、remark 按 asc。
+func ParseBackupSort(key, order string) (BackupSort, error) {
+	key, order = strings.ToLower(strings.TrimSpace(key)), strings.ToLower(strings.TrimSpace(order))
+	switch key {
+	case "":
+		key = SortCreatedAt
+	case SortCreatedAt, SortSize, SortRemark:
+	default:
+		return BackupSort{}, fmt.Errorf("%w: 未知排序键 %q", ErrInvalidSort, key)
+	}
+	switch order {
+	case "":
+		order = OrderDesc
+		if key == SortRemark {
+			order = OrderAsc
+		}
+	case OrderAsc, OrderDesc:
+	default:
+		return BackupSort{}, fmt.Errorf("%w: 未知排序方向 %q", ErrInvalidSort, order)
+	}
+	return BackupSort{Key: key, Order: order}, nil
+}
+
+// isDefault 判断是否为默认的按创建时刻倒序，此时可直接按 SortedIDs 输出。
+func (o BackupSort) isDefault() bool {
+	return (o.Key == "" || o.Key == SortCreatedAt) && (o.Order == "" || o.Order == OrderDesc)
+}
+
+// less 判断 a 是否排在 b 之前。大小或备注（不区分大小写）相同时按默认顺序决定先后，保证结果稳定。
+func (o BackupSort) less(a, b *BackupItem) bool {
+	var cmp int
+	switch o.Key {
+	case SortSize:
+		switch {
+		case a.Size < b.Size:
+			cmp = -1
+		case a.Size > b.Size:
+			cmp = 1
+		}
+	case SortRemark:
+		cmp = strings.Compare(strings.ToLower(a.Remark), strings.ToLower(b.Remark))
+		if cmp == 0 {
+			cmp = strings.Compare(a.Remark, b.Remark)
+		}
+	}
+	if cmp == 0 {
+		// created_at 升序为默认顺序的逆序；size 与 remark 打平时总按默认顺序。
+		if o.Order == OrderAsc && (o.Key == SortCreatedAt || o.Key == "") {
+			return newerFirst(b, a)
+		}
+		return newerFirst(a, b)
+	}
+	if o.Order == OrderAsc {
+		return cmp < 0
+	}
+	return cmp > 0
+}
+
+// sortItems 按 o 原地排序；默认排序时 items 已按 SortedIDs 有序，无需再排。
+func (o BackupSort) sortItems(items []BackupItem) {
+	if o.isDefault() {
+		return
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return o.less(&items[i], &items[j])
+	})
+}
+
+// ListBackupsSorted 返回满足 f 的备份，按 o 排序。
+func (s *Store) ListBackupsSorted(f BackupFilter, o BackupSort) ([]BackupItem, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.refreshUnlocked(); err != nil {
+		return nil, err
+	}
+	items := s.sortedItemsUnlocked(f.match)
+	o.sortItems(items)
+	return items, nil
+}
+
+// ListBackupsSorted 返回满足 f 的备份，见 Store.ListBackupsSorted。
+func (s *Service) ListBackupsSorted(f BackupFilter, o BackupSort) ([]BackupItem, error) {
+	return s.store.ListBackupsSorted(f, o)
+}
@@ -0,0 +1,80 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// 可通过 capabilities 配置单独关闭的操作。
+const (
+	CapRestore     = "restore"
+	CapDelete      = "delete"
+	CapPrune       = "prune"
+	CapCodexLogin  = "codex_login"
+	CapTargetWrite = "target_write"
+)
+
+// KnownCapabilities 为 capabilities 配置允许出现的全部键。
+var KnownCapabilities = []string{CapRestore, CapDelete, CapPrune, CapCodexLogin, CapTargetWrite}
+
+// ErrCapabilityDisabled 在操作被 capabilities 配置关闭时返回，具体能力见 CapabilityError。
+var ErrCapabilityDisabled = errors.New("capability disabled")
+
+// CapabilityError 指明被关闭的能力名称。
+type CapabilityError struct {
+	Name string
+}
+
+func (e *CapabilityError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrCapabilityDisabled, e.Name)
+}
+
+// Unwrap 使 errors.Is(err, ErrCapabilityDisabled) 成立。
+func (e *CapabilityError) Unwrap() error {
+	return ErrCapabilityDisabled
+}
+
+// CapabilityEnabled 判断指定能力是否开启，未配置的能力默认开启。
+func (c Config) CapabilityEnabled(name string) bool {
+	enabled, ok := c.Capabilities[name]
+	return !ok || enabled
+}
+
+// DisabledCapabilities 返回按名称排序的已关闭能力，总是非 nil。
+func (c Config) DisabledCapabilities() []string {
+	disabled := make([]string, 0)
+	for name, enabled := range c.Capabilities {
+		if !enabled {
+			disabled = append(disabled, name)
+		}
+	}
+	sort.Strings(disabled)
+	return disabled
+}
+
+// RequireCapability 在任一能力被关闭时返回 *CapabilityError，API 与命令行在执行操作前都应调用。
+func (s *Service) RequireCapability(names ...string) error {
+	for _, name := range names {
+		if !s.cfg.CapabilityEnabled(name) {
+			return &CapabilityError{Name: name}
+		}
+	}
+	return nil
+}
+
+func validateCapabilities(caps map[string]bool) error {
+	for name := range caps {
+		known := false
+		for _, k := range KnownCapabilities {
+			if name == k {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return fmt.Errorf("capabilities 包含未知能力 %q，可选值: %v", name, KnownCapabilities)
+		}
+	}
+	return nil
+}
@@ -3,29 +3,138 @@ package core
 import (
 	"encoding/json"
 	"fmt"
+	"math"
+	"net"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
+	"golang.org/x/crypto/bcrypt"
+
 	"codex-backup-tool/internal/util"
 )
 
 type fileConfig struct {
-	CodexDir        string `json:"codex_dir"`
-	CodexFile       string `json:"codex_file"`
-	DataDir         string `json:"data_dir"`
-	HTTPPort        string `json:"http_port"`
-	ScanInterval    int    `json:"scan_interval"`
-	AutoOpenBrowser *bool  `json:"auto_open_browser"`
+	CodexDir               string `json:"codex_dir"`
+	CodexFile              string `json:"codex_file"`
+	DataDir                string `json:"data_dir"`
+	HTTPPort               string `json:"http_port"`
+	ScanInterval           int    `json:"scan_interval"`
+	AutoOpenBrowser        *bool  `json:"auto_open_browser"`
+	MinScanIntervalSeconds int    `json:"min_scan_interval_seconds"`
+	DiskReserveMB          int64  `json:"disk_reserve_mb"`
+	DataDirMode            string `json:"data_dir_mode"`
+	FingerprintSampleSize  int    `json:"fingerprint_sample_size"`
+	LockTimeoutSeconds     int    `json:"lock_timeout_seconds"`
+	LockStaleSeconds       int    `json:"lock_stale_seconds"`
+	TempFileGraceMinutes   int    `json:"temp_file_grace_minutes"`
+	MaxTargetSizeBytes     int64  `json:"max_target_size_bytes"`
+	UIPasswordHash         string `json:"ui_password_hash"`
+	SessionIdleMinutes     int    `json:"session_idle_minutes"`
+	MaxSessions            int    `json:"max_sessions"`
+	IndexBackupCount       int    `json:"index_backup_count"`
+	IndexCacheTTLMs        int    `json:"index_cache_ttl_ms"`
+	AuditMaxSizeMB         int64  `json:"audit_max_size_mb"`
+	IntegrityKey           string `json:"integrity_key"`
+	APIToken               string `json:"api_token"`
+	EncryptionPassphrase   string `json:"encryption_passphrase"`
+	EncryptionKeyFile      string `json:"encryption_key_file"`
+	EncryptionKeyEnv       string `json:"encryption_key_env"`
+	EncryptionKeyring      bool   `json:"encryption_keyring"`
+	BindHost               string `json:"bind_host"`
+	Security               struct {
+		RequireAuthForRemote *bool `json:"require_auth_for_remote"`
+	} `json:"security"`
+	Capabilities map[string]bool `json:"capabilities"`
+	TLS          struct {
+		CertFile     string `json:"cert_file"`
+		KeyFile      string `json:"key_file"`
+		ClientCAFile string `json:"client_ca_file"`
+	} `json:"tls"`
+	HealthAddr  string `json:"health_addr"`
+	DocsEnabled *bool  `json:"docs_enabled"`
+	// DataDirLockFile 为空时使用 <data_dir>/.instance.lock。
+	DataDirLockFile string       `json:"data_dir_lock_file"`
+	EventsMaxSizeMB int64        `json:"events_max_size_mb"`
+	Hooks           []HookConfig `json:"hooks"`
+	SlowRequestMs   int          `json:"slow_request_ms"`
+	// InFlightLogSeconds 为 0 时不记录“进行中”日志。
+	InFlightLogSeconds int `json:"in_flight_log_seconds"`
+	Tracing            struct {
+		OTLPEndpoint string `json:"otlp_endpoint"`
+	} `json:"tracing"`
+	LogLevel            string   `json:"log_level"`
+	AccessLogFormat     string   `json:"access_log_format"`
+	AccessLogExclude    []string `json:"access_log_exclude"`
+	AccessLogErrorsOnly bool     `json:"access_log_errors_only"`
+	CORSOrigins         []string `json:"cors_origins"`
+	CORSCredentials     bool     `json:"cors_credentials"`
+	RateLimitRPS        float64  `json:"rate_limit_rps"`
+	RateLimitBurst      int      `json:"rate_limit_burst"`
+	Alerts              struct {
+		MaxHoursWithoutBackup float64 `json:"max_hours_without_backup"`
+	} `json:"alerts"`
+	ScanHistorySize         int    `json:"scan_history_size"`
+	ScanHistoryPersist      int    `json:"scan_history_persist"`
+	RemarkMaxLength         int    `json:"remark_max_length"`
+	RemarkCaseInsensitive   bool   `json:"remark_case_insensitive"`
+	RestoreOverrideReadOnly bool   `json:"restore_override_readonly"`
+	TargetChangePolicy      string `json:"target_change_policy"`
+	MtimeGranularityMs      int    `json:"fingerprint_mtime_granularity_ms"`
+	Timestamps              string `json:"timestamps"`
+	Compression             string `json:"compression"`
+	CompressBackups         bool   `json:"compress_backups"`
+	HashAlgo                string `json:"hash_algo"`
+	Watch                   bool   `json:"watch"`
+	WatchDebounceMs         int    `json:"watch_debounce_ms"`
+	MaxAutoBackups          int    `json:"max_auto_backups"`
+	EnableMetrics           bool   `json:"enable_metrics"`
+	MetricsPublic           bool   `json:"metrics_public"`
+	AutoBackupMaxAgeDays    int    `json:"auto_backup_max_age_days"`
+	MaxBackupsTotalBytes    int64  `json:"max_backups_total_bytes"`
 }
 
+// minAPITokenLength 为 api_token 的最短长度，避免配置容易猜中的固定令牌。
+const minAPITokenLength = 16
+
+// defaultScanIntervalSeconds 为 scan_interval 未配置或为 0 时的自动扫描间隔。
+const defaultScanIntervalSeconds = 60
+
 func defaultFileConfig() fileConfig {
 	return fileConfig{
-		CodexDir:     "~/.codex",
-		CodexFile:    "auth.json",
-		DataDir:      "./data",
-		HTTPPort:     "8080",
-		ScanInterval: 60,
+		CodexDir:               "~/.codex",
+		CodexFile:              "auth.json",
+		DataDir:                "./data",
+		HTTPPort:               "8080",
+		MinScanIntervalSeconds: 5,
+		DiskReserveMB:          50,
+		DataDirMode:            "0700",
+		FingerprintSampleSize:  256,
+		LockTimeoutSeconds:     30,
+		LockStaleSeconds:       600,
+		TempFileGraceMinutes:   60,
+		MaxTargetSizeBytes:     10 << 20,
+		SessionIdleMinutes:     30,
+		MaxSessions:            5,
+		IndexBackupCount:       3,
+		IndexCacheTTLMs:        500,
+		AuditMaxSizeMB:         10,
+		BindHost:               "127.0.0.1",
+		EventsMaxSizeMB:        10,
+		SlowRequestMs:          1000,
+		InFlightLogSeconds:     10,
+		AccessLogFormat:        AccessLogFormatDefault,
+		ScanHistorySize:        defaultScanHistorySize,
+		ScanHistoryPersist:     defaultScanHistoryPersist,
+		RemarkMaxLength:        defaultRemarkMaxLength,
+		TargetChangePolicy:     TargetChangeMigrate,
+		Timestamps:             TimestampsLocal,
+		HashAlgo:               HashSHA256,
+		Watch:                  true,
+		WatchDebounceMs:        200,
 	}
 }
 
@@ -56,25 +165,303 @@ func buildConfig(raw fileConfig) (Config, error) {
 	if err != nil {
 		return Config{}, fmt.Errorf("解析 data_dir: %w", err)
 	}
-	scanInterval := raw.ScanInterval
-	if scanInterval <= 0 {
-		scanInterval = 60
+	lockFile := filepath.Join(dataDir, ".instance.lock")
+	if raw.DataDirLockFile != "" {
+		if lockFile, err = util.ExpandPath(raw.DataDirLockFile); err != nil {
+			return Config{}, fmt.Errorf("解析 data_dir_lock_file: %w", err)
+		}
+	}
+	if raw.MinScanIntervalSeconds < 1 {
+		return Config{}, fmt.Errorf("min_scan_interval_seconds 不能小于 1: %d", raw.MinScanIntervalSeconds)
+	}
+	if raw.DiskReserveMB < 0 {
+		return Config{}, fmt.Errorf("disk_reserve_mb 不能为负数: %d", raw.DiskReserveMB)
+	}
+	dirMode, err := strconv.ParseUint(raw.DataDirMode, 8, 32)
+	if err != nil || dirMode == 0 || dirMode > 0o777 {
+		return Config{}, fmt.Errorf("解析 data_dir_mode: 需要形如 \"0700\" 的八进制权限: %q", raw.DataDirMode)
+	}
+	if raw.FingerprintSampleSize < 0 {
+		return Config{}, fmt.Errorf("fingerprint_sample_size 不能为负数: %d", raw.FingerprintSampleSize)
+	}
+	if raw.MtimeGranularityMs < -1 {
+		return Config{}, fmt.Errorf("fingerprint_mtime_granularity_ms 只能为 -1（不截断）、0（自动推断）或正整数: %d", raw.MtimeGranularityMs)
+	}
+	if raw.LockTimeoutSeconds < 1 {
+		return Config{}, fmt.Errorf("lock_timeout_seconds 不能小于 1: %d", raw.LockTimeoutSeconds)
+	}
+	if raw.LockStaleSeconds < 0 {
+		return Config{}, fmt.Errorf("lock_stale_seconds 不能为负数: %d", raw.LockStaleSeconds)
+	}
+	if raw.TempFileGraceMinutes < 1 {
+		return Config{}, fmt.Errorf("temp_file_grace_minutes 不能小于 1: %d", raw.TempFileGraceMinutes)
+	}
+	if raw.MaxTargetSizeBytes < 0 {
+		return Config{}, fmt.Errorf("max_target_size_bytes 不能为负数: %d", raw.MaxTargetSizeBytes)
+	}
+	if raw.UIPasswordHash != "" {
+		if _, err := bcrypt.Cost([]byte(raw.UIPasswordHash)); err != nil {
+			return Config{}, fmt.Errorf("ui_password_hash 不是有效的 bcrypt 哈希: %w", err)
+		}
+	}
+	if raw.APIToken != "" && len(strings.TrimSpace(raw.APIToken)) < minAPITokenLength {
+		return Config{}, fmt.Errorf("api_token 至少需要 %d 个字符", minAPITokenLength)
+	}
+	encryptionKey, err := loadEncryptionKey(raw, dataDir)
+	if err != nil {
+		return Config{}, err
+	}
+	if raw.SessionIdleMinutes < 1 {
+		return Config{}, fmt.Errorf("session_idle_minutes 不能小于 1: %d", raw.SessionIdleMinutes)
+	}
+	if raw.MaxSessions < 1 {
+		return Config{}, fmt.Errorf("max_sessions 不能小于 1: %d", raw.MaxSessions)
+	}
+	if raw.IndexBackupCount < 0 {
+		return Config{}, fmt.Errorf("index_backup_count 不能为负数: %d", raw.IndexBackupCount)
+	}
+	if raw.IndexCacheTTLMs < 0 {
+		return Config{}, fmt.Errorf("index_cache_ttl_ms 不能为负数: %d", raw.IndexCacheTTLMs)
+	}
+	if raw.AuditMaxSizeMB < 0 {
+		return Config{}, fmt.Errorf("audit_max_size_mb 不能为负数: %d", raw.AuditMaxSizeMB)
+	}
+	if raw.EventsMaxSizeMB < 0 {
+		return Config{}, fmt.Errorf("events_max_size_mb 不能为负数: %d", raw.EventsMaxSizeMB)
+	}
+	if raw.SlowRequestMs < 0 {
+		return Config{}, fmt.Errorf("slow_request_ms 不能为负数: %d", raw.SlowRequestMs)
+	}
+	if raw.InFlightLogSeconds < 0 {
+		return Config{}, fmt.Errorf("in_flight_log_seconds 不能为负数: %d", raw.InFlightLogSeconds)
+	}
+	if err := validateHooks(raw.Hooks); err != nil {
+		return Config{}, err
+	}
+	if err := validateCapabilities(raw.Capabilities); err != nil {
+		return Config{}, err
+	}
+	tlsFiles := [3]string{raw.TLS.CertFile, raw.TLS.KeyFile, raw.TLS.ClientCAFile}
+	for i, p := range tlsFiles {
+		if p == "" {
+			continue
+		}
+		if tlsFiles[i], err = util.ExpandPath(p); err != nil {
+			return Config{}, fmt.Errorf("解析 tls 证书路径: %w", err)
+		}
+	}
+	if (tlsFiles[0] == "") != (tlsFiles[1] == "") {
+		return Config{}, fmt.Errorf("tls.cert_file 与 tls.key_file 必须同时配置")
+	}
+	if tlsFiles[2] != "" && tlsFiles[0] == "" {
+		return Config{}, fmt.Errorf("tls.client_ca_file 需要同时配置 tls.cert_file 与 tls.key_file")
+	}
+	if raw.HealthAddr != "" {
+		host, _, err := net.SplitHostPort(raw.HealthAddr)
+		if err != nil {
+			return Config{}, fmt.Errorf("解析 health_addr: %w", err)
+		}
+		if !util.IsLoopbackHost(host) {
+			return Config{}, fmt.Errorf("health_addr 只能监听回环地址: %s", raw.HealthAddr)
+		}
+	}
+	corsOrigins, err := parseCORSOrigins(raw.CORSOrigins, raw.CORSCredentials)
+	if err != nil {
+		return Config{}, err
+	}
+	if raw.RateLimitRPS < 0 || raw.RateLimitBurst < 0 {
+		return Config{}, fmt.Errorf("rate_limit_rps 与 rate_limit_burst 不能为负数")
+	}
+	if raw.RateLimitRPS > 0 && raw.RateLimitBurst == 0 {
+		raw.RateLimitBurst = int(math.Max(1, math.Ceil(raw.RateLimitRPS)))
+	}
+	if raw.ScanHistorySize < 0 || raw.ScanHistoryPersist < 0 {
+		return Config{}, fmt.Errorf("scan_history_size 与 scan_history_persist 不能为负数")
+	}
+	if raw.RemarkMaxLength < 1 {
+		return Config{}, fmt.Errorf("remark_max_length 需大于 0: %d", raw.RemarkMaxLength)
+	}
+	if raw.TargetChangePolicy != TargetChangeMigrate && raw.TargetChangePolicy != TargetChangeIsolate {
+		return Config{}, fmt.Errorf("target_change_policy 只能为 %q 或 %q: %q", TargetChangeMigrate, TargetChangeIsolate, raw.TargetChangePolicy)
+	}
+	if raw.Timestamps != TimestampsLocal && raw.Timestamps != TimestampsUTC {
+		return Config{}, fmt.Errorf("timestamps 只能为 %q 或 %q: %q", TimestampsLocal, TimestampsUTC, raw.Timestamps)
+	}
+	// compress_backups 为 compression 的简写，同时配置时以 compression 为准。
+	if raw.CompressBackups && raw.Compression == CompressionNone {
+		raw.Compression = CompressionGzip
+	}
+	if raw.Compression != CompressionNone && raw.Compression != CompressionGzip {
+		return Config{}, fmt.Errorf("compression 只能为空或 %q: %q", CompressionGzip, raw.Compression)
+	}
+	if raw.WatchDebounceMs < 1 {
+		return Config{}, fmt.Errorf("watch_debounce_ms 需大于 0: %d", raw.WatchDebounceMs)
+	}
+	if raw.MaxAutoBackups < 0 {
+		return Config{}, fmt.Errorf("max_auto_backups 不能为负数: %d", raw.MaxAutoBackups)
+	}
+	if raw.AutoBackupMaxAgeDays < 0 {
+		return Config{}, fmt.Errorf("auto_backup_max_age_days 不能为负数: %d", raw.AutoBackupMaxAgeDays)
+	}
+	if raw.MaxBackupsTotalBytes < 0 {
+		return Config{}, fmt.Errorf("max_backups_total_bytes 不能为负数: %d", raw.MaxBackupsTotalBytes)
+	}
+	if !ValidHashAlgo(raw.HashAlgo) {
+		return Config{}, fmt.Errorf("hash_algo 只能为 %q、%q 或 %q: %q", HashSHA256, HashSHA512, HashBLAKE2b, raw.HashAlgo)
+	}
+	if raw.Alerts.MaxHoursWithoutBackup < 0 {
+		return Config{}, fmt.Errorf("alerts.max_hours_without_backup 不能为负数: %v", raw.Alerts.MaxHoursWithoutBackup)
+	}
+	logLevel, err := ParseLogLevel(raw.LogLevel)
+	if err != nil {
+		return Config{}, err
+	}
+	if err := validateAccessLog(raw.AccessLogFormat, raw.AccessLogExclude); err != nil {
+		return Config{}, err
+	}
+	otlpEndpoint := strings.TrimSpace(raw.Tracing.OTLPEndpoint)
+	if otlpEndpoint != "" {
+		u, err := url.Parse(otlpEndpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return Config{}, fmt.Errorf("tracing.otlp_endpoint 必须是 http(s) 地址: %s", otlpEndpoint)
+		}
+	}
+	var warnings []string
+	// scan_interval：-1 关闭自动扫描，0 或未配置使用默认的 60 秒。
+	scanInterval, scanMode := raw.ScanInterval, ScanModeCustom
+	switch {
+	case scanInterval == -1:
+		scanInterval, scanMode = 0, ScanModeDisabled
+	case scanInterval < -1:
+		return Config{}, fmt.Errorf("scan_interval 只能为 -1（关闭自动扫描）、0（默认 60 秒）或正整数: %d", scanInterval)
+	case scanInterval == 0:
+		scanInterval, scanMode = defaultScanIntervalSeconds, ScanModeDefault
+	}
+	if scanMode != ScanModeDisabled && scanInterval < raw.MinScanIntervalSeconds {
+		warnings = append(warnings, fmt.Sprintf("scan_interval=%d 低于最小值 %d，已按 %d 秒执行", scanInterval, raw.MinScanIntervalSeconds, raw.MinScanIntervalSeconds))
+		scanInterval = raw.MinScanIntervalSeconds
 	}
 	autoOpen := true
 	if raw.AutoOpenBrowser != nil {
 		autoOpen = *raw.AutoOpenBrowser
 	}
+	requireAuth := true
+	if raw.Security.RequireAuthForRemote != nil {
+		requireAuth = *raw.Security.RequireAuthForRemote
+	}
+	docsEnabled := true
+	if raw.DocsEnabled != nil {
+		docsEnabled = *raw.DocsEnabled
+	}
 	cfg := Config{
-		TargetPath:      filepath.Join(codexDir, raw.CodexFile),
-		DataDir:         dataDir,
-		BackupsDir:      filepath.Join(dataDir, "backups"),
-		IndexPath:       filepath.Join(dataDir, "index.json"),
-		ScanInterval:    time.Duration(scanInterval) * time.Second,
-		Port:            raw.HTTPPort,
-		AutoOpenBrowser: autoOpen,
+		TargetPath:              filepath.Join(codexDir, raw.CodexFile),
+		DataDir:                 dataDir,
+		BackupsDir:              filepath.Join(dataDir, "backups"),
+		IndexPath:               filepath.Join(dataDir, "index.json"),
+		TokensPath:              filepath.Join(dataDir, "tokens.json"),
+		AuditPath:               filepath.Join(dataDir, "audit.log"),
+		DataDirLockFile:         lockFile,
+		AuditMaxSize:            raw.AuditMaxSizeMB << 20,
+		EventsPath:              filepath.Join(dataDir, "events.log"),
+		EventsMaxSize:           raw.EventsMaxSizeMB << 20,
+		Hooks:                   raw.Hooks,
+		SlowRequest:             time.Duration(raw.SlowRequestMs) * time.Millisecond,
+		InFlightLogAfter:        time.Duration(raw.InFlightLogSeconds) * time.Second,
+		OTLPEndpoint:            otlpEndpoint,
+		LogLevel:                logLevel,
+		AccessLogFormat:         raw.AccessLogFormat,
+		AccessLogExclude:        raw.AccessLogExclude,
+		AccessLogErrorsOnly:     raw.AccessLogErrorsOnly,
+		MaxTimeWithoutBackup:    time.Duration(raw.Alerts.MaxHoursWithoutBackup * float64(time.Hour)),
+		ScanHistorySize:         raw.ScanHistorySize,
+		ScanHistoryPersist:      raw.ScanHistoryPersist,
+		RemarkMaxLength:         raw.RemarkMaxLength,
+		RemarkCaseInsensitive:   raw.RemarkCaseInsensitive,
+		RestoreOverrideReadOnly: raw.RestoreOverrideReadOnly,
+		TargetChangePolicy:      raw.TargetChangePolicy,
+		Timestamps:              raw.Timestamps,
+		Compression:             raw.Compression,
+		HashAlgo:                raw.HashAlgo,
+		Watch:                   raw.Watch,
+		WatchDebounce:           time.Duration(raw.WatchDebounceMs) * time.Millisecond,
+		MaxAutoBackups:          raw.MaxAutoBackups,
+		AutoBackupMaxAge:        time.Duration(raw.AutoBackupMaxAgeDays) * 24 * time.Hour,
+		MaxBackupsTotalBytes:    raw.MaxBackupsTotalBytes,
+		EnableMetrics:           raw.EnableMetrics,
+		MetricsPublic:           raw.MetricsPublic,
+		IntegrityKey:            util.SecretString(raw.IntegrityKey),
+		Capabilities:            raw.Capabilities,
+		TLSCertFile:             tlsFiles[0],
+		TLSKeyFile:              tlsFiles[1],
+		TLSClientCAFile:         tlsFiles[2],
+		HealthAddr:              raw.HealthAddr,
+		CORS:                    CORSConfig{AllowedOrigins: corsOrigins, AllowCredentials: raw.CORSCredentials},
+		RateLimitRPS:            raw.RateLimitRPS,
+		RateLimitBurst:          raw.RateLimitBurst,
+		DocsEnabled:             docsEnabled,
+		ScanInterval:            time.Duration(scanInterval) * time.Second,
+		ScanMode:                scanMode,
+		Port:                    raw.HTTPPort,
+		AutoOpenBrowser:         autoOpen,
+		DiskReserve:             raw.DiskReserveMB << 20,
+		DataDirMode:             os.FileMode(dirMode),
+		FingerprintSampleSize:   raw.FingerprintSampleSize,
+		MtimeGranularity:        time.Duration(raw.MtimeGranularityMs) * time.Millisecond,
+		LockTimeout:             time.Duration(raw.LockTimeoutSeconds) * time.Second,
+		LockStaleAfter:          time.Duration(raw.LockStaleSeconds) * time.Second,
+		TempFileGrace:           time.Duration(raw.TempFileGraceMinutes) * time.Minute,
+		MaxTargetSize:           raw.MaxTargetSizeBytes,
+		UIPasswordHash:          util.SecretString(raw.UIPasswordHash),
+		APIToken:                util.SecretString(strings.TrimSpace(raw.APIToken)),
+		EncryptionPassphrase:    util.SecretString(raw.EncryptionPassphrase),
+		EncryptionKey:           encryptionKey,
+		SessionIdle:             time.Duration(raw.SessionIdleMinutes) * time.Minute,
+		MaxSessions:             raw.MaxSessions,
+		IndexBackupCount:        raw.IndexBackupCount,
+		IndexCacheTTL:           time.Duration(raw.IndexCacheTTLMs) * time.Millisecond,
+		BindHost:                strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(raw.BindHost), "["), "]"),
+		RequireAuthForRemote:    requireAuth,
+		Warnings:                warnings,
 	}
 	if cfg.Port == "" {
 		cfg.Port = "8080"
 	}
 	return cfg, nil
 }
+
+// loadEncryptionKey 返回 encryption_key_file、encryption_key_env 或 encryption_keyring 对应的口令来源，
+// 这几项与 encryption_passphrase 只能配置其一；直接配置口令或未启用加密时返回 nil。
+// 文件与环境变量在加载时即读取一次，尽早报告配置错误；系统凭据存储在服务启动时才读取，不可用时由调用方决定是否提示输入。
+func loadEncryptionKey(raw fileConfig, dataDir string) (KeyProvider, error) {
+	var sources []string
+	var key KeyProvider
+	if raw.EncryptionPassphrase != "" {
+		sources = append(sources, "encryption_passphrase")
+	}
+	if raw.EncryptionKeyFile != "" {
+		path, err := util.ExpandPath(raw.EncryptionKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("解析 encryption_key_file: %w", err)
+		}
+		sources = append(sources, "encryption_key_file")
+		key = FileKey{Path: path}
+	}
+	if raw.EncryptionKeyEnv != "" {
+		sources = append(sources, "encryption_key_env")
+		key = EnvKey{Name: raw.EncryptionKeyEnv}
+	}
+	if raw.EncryptionKeyring {
+		sources = append(sources, "encryption_keyring")
+		key = KeyringKey{Account: dataDir}
+	}
+	if len(sources) > 1 {
+		return nil, fmt.Errorf("%s 只能配置其一", strings.Join(sources, "、"))
+	}
+	switch key.(type) {
+	case FileKey, EnvKey:
+		if _, err := key.Passphrase(); err != nil {
+			return nil, err
+		}
+	}
+	return key, nil
+}
@@ -0,0 +1,375 @@
+package core_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/zalando/go-keyring"
+
+	"codex-backup-tool/internal/core"
+)
+
+func writeConfig(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	return path
+}
+
+func TestLoadConfigEnforcesMinScanInterval(t *testing.T) {
+	path := writeConfig(t, `{"data_dir":"./data","scan_interval":1}`)
+	cfg, _, err := core.LoadConfig(path)
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	if cfg.ScanInterval != 5*time.Second {
+		t.Fatalf("expected interval clamped to 5s, got %s", cfg.ScanInterval)
+	}
+	if len(cfg.Warnings) != 1 {
+		t.Fatalf("expected one warning, got %v", cfg.Warnings)
+	}
+
+	path = writeConfig(t, `{"data_dir":"./data","scan_interval":1,"min_scan_interval_seconds":1}`)
+	cfg, _, err = core.LoadConfig(path)
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	if cfg.ScanInterval != time.Second || len(cfg.Warnings) != 0 {
+		t.Fatalf("expected 1s without warnings, got %s %v", cfg.ScanInterval, cfg.Warnings)
+	}
+
+	path = writeConfig(t, `{"data_dir":"./data","min_scan_interval_seconds":0}`)
+	if _, _, err := core.LoadConfig(path); err == nil {
+		t.Fatalf("expected validation error for min_scan_interval_seconds=0")
+	}
+}
+
+func TestLoadConfigScanIntervalModes(t *testing.T) {
+	cases := []struct {
+		name     string
+		body     string
+		interval time.Duration
+		mode     string
+	}{
+		{"absent", `{"data_dir":"./data"}`, 60 * time.Second, core.ScanModeDefault},
+		{"zero", `{"data_dir":"./data","scan_interval":0}`, 60 * time.Second, core.ScanModeDefault},
+		{"custom", `{"data_dir":"./data","scan_interval":30}`, 30 * time.Second, core.ScanModeCustom},
+		{"disabled", `{"data_dir":"./data","scan_interval":-1}`, 0, core.ScanModeDisabled},
+	}
+	for _, tc := range cases {
+		cfg, _, err := core.LoadConfig(writeConfig(t, tc.body))
+		if err != nil {
+			t.Fatalf("%s: load config: %v", tc.name, err)
+		}
+		if cfg.ScanInterval != tc.interval || cfg.EffectiveScanMode() != tc.mode || len(cfg.Warnings) != 0 {
+			t.Fatalf("%s: got interval=%s mode=%s warnings=%v", tc.name, cfg.ScanInterval, cfg.EffectiveScanMode(), cfg.Warnings)
+		}
+	}
+	if _, _, err := core.LoadConfig(writeConfig(t, `{"data_dir":"./data","scan_interval":-2}`)); err == nil {
+		t.Fatalf("expected validation error for scan_interval=-2")
+	}
+}
+
+func TestLoadConfigMtimeGranularity(t *testing.T) {
+	cases := []struct {
+		body string
+		want time.Duration
+	}{
+		{`{"data_dir":"./data"}`, 0},
+		{`{"data_dir":"./data","fingerprint_mtime_granularity_ms":2000}`, 2 * time.Second},
+		{`{"data_dir":"./data","fingerprint_mtime_granularity_ms":-1}`, -time.Millisecond},
+	}
+	for _, tc := range cases {
+		cfg, _, err := core.LoadConfig(writeConfig(t, tc.body))
+		if err != nil {
+			t.Fatalf("%s: load config: %v", tc.body, err)
+		}
+		if cfg.MtimeGranularity != tc.want {
+			t.Fatalf("%s: got %s, want %s", tc.body, cfg.MtimeGranularity, tc.want)
+		}
+	}
+	if _, _, err := core.LoadConfig(writeConfig(t, `{"data_dir":"./data","fingerprint_mtime_granularity_ms":-2}`)); err == nil {
+		t.Fatalf("expected validation error for fingerprint_mtime_granularity_ms=-2")
+	}
+}
+
+func TestLoadConfigDataDirMode(t *testing.T) {
+	cfg, _, err := core.LoadConfig(writeConfig(t, `{"data_dir":"./data"}`))
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	if cfg.DataDirMode != 0o700 {
+		t.Fatalf("expected default 0700, got %o", cfg.DataDirMode)
+	}
+	cfg, _, err = core.LoadConfig(writeConfig(t, `{"data_dir":"./data","data_dir_mode":"0750"}`))
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	if cfg.DataDirMode != 0o750 {
+		t.Fatalf("expected 0750, got %o", cfg.DataDirMode)
+	}
+	for _, bad := range []string{"rwx", "0999", "0"} {
+		if _, _, err := core.LoadConfig(writeConfig(t, `{"data_dir":"./data","data_dir_mode":"`+bad+`"}`)); err == nil {
+			t.Fatalf("expected error for data_dir_mode=%s", bad)
+		}
+	}
+}
+
+func TestLoadConfigBindHost(t *testing.T) {
+	cfg, _, err := core.LoadConfig(writeConfig(t, `{"data_dir":"./data"}`))
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	if cfg.BindHost != "127.0.0.1" || !cfg.RequireAuthForRemote || cfg.Exposure() != core.ExposureLocal {
+		t.Fatalf("unexpected defaults: host=%q require=%v exposure=%s", cfg.BindHost, cfg.RequireAuthForRemote, cfg.Exposure())
+	}
+	cfg, _, err = core.LoadConfig(writeConfig(t, `{"data_dir":"./data","bind_host":"[::]","security":{"require_auth_for_remote":false}}`))
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	if cfg.BindHost != "::" || cfg.RequireAuthForRemote || cfg.Exposure() != core.ExposureRemote {
+		t.Fatalf("unexpected config: host=%q require=%v exposure=%s", cfg.BindHost, cfg.RequireAuthForRemote, cfg.Exposure())
+	}
+}
+
+func TestLoadConfigCapabilities(t *testing.T) {
+	cfg, _, err := core.LoadConfig(writeConfig(t, `{"data_dir":"./data","capabilities":{"delete":false,"restore":true}}`))
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	if cfg.CapabilityEnabled(core.CapDelete) || !cfg.CapabilityEnabled(core.CapRestore) || !cfg.CapabilityEnabled(core.CapCodexLogin) {
+		t.Fatalf("unexpected capabilities: %v", cfg.Capabilities)
+	}
+	if got := cfg.DisabledCapabilities(); len(got) != 1 || got[0] != core.CapDelete {
+		t.Fatalf("unexpected disabled list: %v", got)
+	}
+	if _, _, err := core.LoadConfig(writeConfig(t, `{"data_dir":"./data","capabilities":{"destroy":false}}`)); err == nil {
+		t.Fatalf("expected error for unknown capability")
+	}
+}
+
+func TestLoadConfigAccessLog(t *testing.T) {
+	cfg, _, err := core.LoadConfig(writeConfig(t, `{"data_dir":"./data","access_log_format":"json","access_log_exclude":["/api/status","/assets/*"]}`))
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	if cfg.AccessLogFormat != core.AccessLogFormatJSON || len(cfg.AccessLogExclude) != 2 {
+		t.Fatalf("unexpected access log config: %q %v", cfg.AccessLogFormat, cfg.AccessLogExclude)
+	}
+	if _, _, err := core.LoadConfig(writeConfig(t, `{"data_dir":"./data","access_log_format":"xml"}`)); err == nil {
+		t.Fatalf("expected error for unknown format")
+	}
+	if _, _, err := core.LoadConfig(writeConfig(t, `{"data_dir":"./data","access_log_exclude":["api/status"]}`)); err == nil {
+		t.Fatalf("expected error for relative path")
+	}
+}
+
+func TestLoadConfigRemarks(t *testing.T) {
+	cfg, _, err := core.LoadConfig(writeConfig(t, `{"data_dir":"./data"}`))
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	if cfg.RemarkMaxLength != 128 || cfg.RemarkCaseInsensitive {
+		t.Fatalf("unexpected remark defaults: %d %t", cfg.RemarkMaxLength, cfg.RemarkCaseInsensitive)
+	}
+	cfg, _, err = core.LoadConfig(writeConfig(t, `{"data_dir":"./data","remark_max_length":16,"remark_case_insensitive":true}`))
+	if err != nil || cfg.RemarkMaxLength != 16 || !cfg.RemarkCaseInsensitive {
+		t.Fatalf("unexpected remark config: %+v (%v)", cfg, err)
+	}
+	if _, _, err := core.LoadConfig(writeConfig(t, `{"data_dir":"./data","remark_max_length":0}`)); err == nil {
+		t.Fatalf("expected error for zero remark_max_length")
+	}
+}
+
+func TestLoadConfigTimestamps(t *testing.T) {
+	cfg, _, err := core.LoadConfig(writeConfig(t, `{"data_dir":"./data"}`))
+	if err != nil || cfg.Timestamps != core.TimestampsLocal {
+		t.Fatalf("expected local timestamps by default, got %q (%v)", cfg.Timestamps, err)
+	}
+	cfg, _, err = core.LoadConfig(writeConfig(t, `{"data_dir":"./data","timestamps":"utc"}`))
+	if err != nil || cfg.Timestamps != core.TimestampsUTC {
+		t.Fatalf("expected utc timestamps, got %q (%v)", cfg.Timestamps, err)
+	}
+	if _, _, err := core.LoadConfig(writeConfig(t, `{"data_dir":"./data","timestamps":"gmt"}`)); err == nil {
+		t.Fatalf("expected error for unknown timestamps mode")
+	}
+}
+
+func TestLoadConfigCompression(t *testing.T) {
+	cfg, _, err := core.LoadConfig(writeConfig(t, `{"data_dir":"./data"}`))
+	if err != nil || cfg.Compression != core.CompressionNone {
+		t.Fatalf("expected no compression by default, got %q (%v)", cfg.Compression, err)
+	}
+	cfg, _, err = core.LoadConfig(writeConfig(t, `{"data_dir":"./data","compression":"gzip"}`))
+	if err != nil || cfg.Compression != core.CompressionGzip {
+		t.Fatalf("expected gzip compression, got %q (%v)", cfg.Compression, err)
+	}
+	cfg, _, err = core.LoadConfig(writeConfig(t, `{"data_dir":"./data","compress_backups":true}`))
+	if err != nil || cfg.Compression != core.CompressionGzip {
+		t.Fatalf("expected compress_backups to enable gzip, got %q (%v)", cfg.Compression, err)
+	}
+	if _, _, err := core.LoadConfig(writeConfig(t, `{"data_dir":"./data","compression":"zstd"}`)); err == nil {
+		t.Fatalf("expected error for unknown compression")
+	}
+}
+
+func TestLoadConfigMaxAutoBackups(t *testing.T) {
+	cfg, _, err := core.LoadConfig(writeConfig(t, `{"data_dir":"./data","max_auto_backups":50}`))
+	if err != nil || cfg.MaxAutoBackups != 50 {
+		t.Fatalf("expected max_auto_backups 50, got %d (%v)", cfg.MaxAutoBackups, err)
+	}
+	if _, _, err := core.LoadConfig(writeConfig(t, `{"data_dir":"./data","max_auto_backups":-1}`)); err == nil {
+		t.Fatalf("expected error for negative max_auto_backups")
+	}
+	cfg, _, err = core.LoadConfig(writeConfig(t, `{"data_dir":"./data","auto_backup_max_age_days":30}`))
+	if err != nil || cfg.AutoBackupMaxAge != 30*24*time.Hour {
+		t.Fatalf("expected a 30 day max age, got %s (%v)", cfg.AutoBackupMaxAge, err)
+	}
+	if _, _, err := core.LoadConfig(writeConfig(t, `{"data_dir":"./data","auto_backup_max_age_days":-1}`)); err == nil {
+		t.Fatalf("expected error for negative auto_backup_max_age_days")
+	}
+	cfg, _, err = core.LoadConfig(writeConfig(t, `{"data_dir":"./data","max_backups_total_bytes":209715200}`))
+	if err != nil || cfg.MaxBackupsTotalBytes != 200<<20 {
+		t.Fatalf("expected a 200 MiB limit, got %d (%v)", cfg.MaxBackupsTotalBytes, err)
+	}
+}
+
+func TestLoadConfigAPIToken(t *testing.T) {
+	cfg, _, err := core.LoadConfig(writeConfig(t, `{"data_dir":"./data","api_token":" 0123456789abcdef "}`))
+	if err != nil || cfg.APIToken.Reveal() != "0123456789abcdef" {
+		t.Fatalf("expected trimmed api_token, got %q (%v)", cfg.APIToken.Reveal(), err)
+	}
+	if _, _, err := core.LoadConfig(writeConfig(t, `{"data_dir":"./data","api_token":"short"}`)); err == nil {
+		t.Fatalf("expected error for a short api_token")
+	}
+}
+
+func TestLoadConfigEncryption(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "backup.key")
+	if err := os.WriteFile(keyFile, []byte("from-key-file\n"), 0o600); err != nil {
+		t.Fatalf("write key file: %v", err)
+	}
+	t.Setenv("CODEX_BACKUP_TEST_KEY", " from-env ")
+	for _, tc := range []struct {
+		body, source, passphrase string
+	}{
+		{`{"data_dir":"./data","encryption_key_file":` + strconv.Quote(keyFile) + `}`, "file", "from-key-file"},
+		{`{"data_dir":"./data","encryption_key_env":"CODEX_BACKUP_TEST_KEY"}`, "env", "from-env"},
+		{`{"data_dir":"./data","encryption_passphrase":"inline"}`, "passphrase", "inline"},
+	} {
+		cfg, _, err := core.LoadConfig(writeConfig(t, tc.body))
+		if err != nil {
+			t.Fatalf("%s: %v", tc.body, err)
+		}
+		key := cfg.EncryptionKeyProvider()
+		if key == nil || key.Source() != tc.source {
+			t.Fatalf("%s: expected %s key source, got %v", tc.body, tc.source, key)
+		}
+		if got, err := key.Passphrase(); err != nil || got != tc.passphrase {
+			t.Fatalf("%s: expected passphrase %q, got %q (%v)", tc.body, tc.passphrase, got, err)
+		}
+	}
+
+	cfg, _, err := core.LoadConfig(writeConfig(t, `{"data_dir":"./data","encryption_keyring":true}`))
+	if err != nil {
+		t.Fatalf("keyring config: %v", err)
+	}
+	if key, ok := cfg.EncryptionKey.(core.KeyringKey); !ok || key.Account != cfg.DataDir {
+		t.Fatalf("expected keyring key for the data dir, got %#v", cfg.EncryptionKey)
+	}
+	if cfg, _, err := core.LoadConfig(writeConfig(t, `{"data_dir":"./data"}`)); err != nil || cfg.EncryptionKeyProvider() != nil {
+		t.Fatalf("expected encryption disabled by default, got %v (%v)", cfg.EncryptionKeyProvider(), err)
+	}
+
+	for _, body := range []string{
+		`{"data_dir":"./data","encryption_passphrase":"inline","encryption_key_file":` + strconv.Quote(keyFile) + `}`,
+		`{"data_dir":"./data","encryption_key_env":"CODEX_BACKUP_TEST_KEY","encryption_keyring":true}`,
+		`{"data_dir":"./data","encryption_key_file":` + strconv.Quote(keyFile+".missing") + `}`,
+		`{"data_dir":"./data","encryption_key_env":"CODEX_BACKUP_TEST_UNSET"}`,
+	} {
+		if _, _, err := core.LoadConfig(writeConfig(t, body)); err == nil {
+			t.Fatalf("expected validation error for %s", body)
+		}
+	}
+}
+
+func TestKeyringKey(t *testing.T) {
+	keyring.MockInit()
+	key := core.KeyringKey{Account: t.TempDir()}
+	if _, err := key.Passphrase(); !errors.Is(err, core.ErrKeyringPassphraseNotFound) {
+		t.Fatalf("expected ErrKeyringPassphraseNotFound, got %v", err)
+	}
+	if err := key.SetPassphrase("from-keyring"); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	if got, err := key.Passphrase(); err != nil || got != "from-keyring" {
+		t.Fatalf("expected stored passphrase, got %q (%v)", got, err)
+	}
+	if err := key.DeletePassphrase(); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if err := key.DeletePassphrase(); !errors.Is(err, core.ErrKeyringPassphraseNotFound) {
+		t.Fatalf("expected ErrKeyringPassphraseNotFound on second delete, got %v", err)
+	}
+
+	keyring.MockInitWithError(errors.New("no secret service"))
+	defer keyring.MockInit()
+	if _, err := key.Passphrase(); err == nil || errors.Is(err, core.ErrKeyringPassphraseNotFound) {
+		t.Fatalf("expected keyring unavailable error, got %v", err)
+	}
+}
+
+func TestLoadConfigCORS(t *testing.T) {
+	cfg, _, err := core.LoadConfig(writeConfig(t, `{"data_dir":"./data"}`))
+	if err != nil || cfg.CORS.Enabled() {
+		t.Fatalf("expected CORS disabled by default, got %+v (%v)", cfg.CORS, err)
+	}
+	cfg, _, err = core.LoadConfig(writeConfig(t, `{"data_dir":"./data","cors_origins":["http://localhost:5173/"],"cors_credentials":true}`))
+	if err != nil || len(cfg.CORS.AllowedOrigins) != 1 || cfg.CORS.AllowedOrigins[0] != "http://localhost:5173" || !cfg.CORS.AllowCredentials {
+		t.Fatalf("unexpected CORS config: %+v (%v)", cfg.CORS, err)
+	}
+	if !cfg.CORS.AllowsOrigin("http://LOCALHOST:5173") || cfg.CORS.AllowsOrigin("http://localhost:3000") || cfg.CORS.AllowsOrigin("") {
+		t.Fatalf("unexpected origin matching for %+v", cfg.CORS)
+	}
+	for _, body := range []string{
+		`{"data_dir":"./data","cors_origins":["localhost:5173"]}`,
+		`{"data_dir":"./data","cors_origins":["http://localhost:5173/app"]}`,
+		`{"data_dir":"./data","cors_origins":["*"],"cors_credentials":true}`,
+	} {
+		if _, _, err := core.LoadConfig(writeConfig(t, body)); err == nil {
+			t.Fatalf("expected validation error for %s", body)
+		}
+	}
+}
+
+func TestLoadConfigRateLimit(t *testing.T) {
+	cfg, _, err := core.LoadConfig(writeConfig(t, `{"data_dir":"./data","rate_limit_rps":2.5}`))
+	if err != nil || cfg.RateLimitRPS != 2.5 || cfg.RateLimitBurst != 3 {
+		t.Fatalf("expected 2.5 rps with a default burst of 3, got %v/%d (%v)", cfg.RateLimitRPS, cfg.RateLimitBurst, err)
+	}
+	if _, _, err := core.LoadConfig(writeConfig(t, `{"data_dir":"./data","rate_limit_rps":-1}`)); err == nil {
+		t.Fatalf("expected error for negative rate_limit_rps")
+	}
+}
+
+func TestLoadConfigHashAlgo(t *testing.T) {
+	cfg, _, err := core.LoadConfig(writeConfig(t, `{"data_dir":"./data"}`))
+	if err != nil || cfg.HashAlgo != core.HashSHA256 {
+		t.Fatalf("expected sha256 by default, got %q (%v)", cfg.HashAlgo, err)
+	}
+	for _, algo := range []string{core.HashSHA512, core.HashBLAKE2b} {
+		cfg, _, err = core.LoadConfig(writeConfig(t, `{"data_dir":"./data","hash_algo":"`+algo+`"}`))
+		if err != nil || cfg.HashAlgo != algo {
+			t.Fatalf("expected %s, got %q (%v)", algo, cfg.HashAlgo, err)
+		}
+	}
+	if _, _, err := core.LoadConfig(writeConfig(t, `{"data_dir":"./data","hash_algo":"md5"}`)); err == nil {
+		t.Fatalf("expected error for unknown hash_algo")
+	}
+}
@@ -0,0 +1,80 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"codex-backup-tool/internal/util"
+)
+
+// 一致性检查发现的问题类型。
+const (
+	IssueUnsafeFilename = "UNSAFE_FILENAME"
+	IssueFileMissing    = "FILE_MISSING"
+	IssueOrphanFile     = "ORPHAN_FILE"
+	IssueSortOrder      = "SORT_ORDER_INVALID"
+)
+
+// ConsistencyIssue 描述 index.json 与备份目录之间的一处不一致。
+type ConsistencyIssue struct {
+	Code     string `json:"code"`
+	ItemID   string `json:"item_id,omitempty"`
+	Filename string `json:"filename"`
+	Detail   string `json:"detail,omitempty"`
+}
+
+// ConsistencyReport 为一致性检查的结果。
+type ConsistencyReport struct {
+	ItemCount int                `json:"item_count"`
+	FileCount int                `json:"file_count"`
+	Issues    []ConsistencyIssue `json:"issues"`
+}
+
+// OK 表示未发现任何问题。
+func (r *ConsistencyReport) OK() bool {
+	return len(r.Issues) == 0
+}
+
+// CheckConsistency 对照索引检查备份目录，标记不安全的文件名、缺失文件与孤立文件，
+// 并校验 sorted_ids 与 items 是否一致。
+func (s *Service) CheckConsistency() (*ConsistencyReport, error) {
+	idx, err := s.store.Snapshot()
+	if err != nil {
+		return nil, err
+	}
+	report := &ConsistencyReport{ItemCount: len(idx.Items), Issues: make([]ConsistencyIssue, 0)}
+	if err := idx.ValidateSortedIDs(); err != nil {
+		report.Issues = append(report.Issues, ConsistencyIssue{Code: IssueSortOrder, Detail: err.Error()})
+	}
+	referenced := make(map[string]struct{}, len(idx.Items))
+	for _, item := range idx.Items {
+		path, err := util.SecureJoin(s.cfg.BackupsDir, item.Filename)
+		if err != nil {
+			report.Issues = append(report.Issues, ConsistencyIssue{Code: IssueUnsafeFilename, ItemID: item.ID, Filename: item.Filename, Detail: err.Error()})
+			continue
+		}
+		referenced[item.Filename] = struct{}{}
+		if _, err := os.Stat(path); err != nil {
+			report.Issues = append(report.Issues, ConsistencyIssue{Code: IssueFileMissing, ItemID: item.ID, Filename: item.Filename, Detail: err.Error()})
+		}
+	}
+	entries, err := os.ReadDir(s.cfg.BackupsDir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("读取备份目录: %w", err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		report.FileCount++
+		if _, ok := referenced[entry.Name()]; !ok {
+			report.Issues = append(report.Issues, ConsistencyIssue{Code: IssueOrphanFile, Filename: entry.Name()})
+		}
+	}
+	sort.SliceStable(report.Issues, func(i, j int) bool {
+		return report.Issues[i].Code < report.Issues[j].Code
+	})
+	return report, nil
+}
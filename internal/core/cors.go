@@ -0,0 +1,55 @@
+package core
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// CORSConfig 为跨域访问设置，AllowedOrigins 为空时不启用 CORS。
+type CORSConfig struct {
+	// AllowedOrigins 为允许跨域访问的来源（如 http://localhost:5173），"*" 表示任意来源。
+	AllowedOrigins []string
+	// AllowCredentials 为 true 时允许跨域请求携带 Cookie 与 Authorization，此时不能使用 "*"。
+	AllowCredentials bool
+}
+
+// Enabled 表示配置了至少一个允许的来源。
+func (c CORSConfig) Enabled() bool {
+	return len(c.AllowedOrigins) > 0
+}
+
+// AllowsOrigin 判断请求头 Origin 是否在允许列表中，比较时不区分大小写。
+func (c CORSConfig) AllowsOrigin(origin string) bool {
+	if origin == "" {
+		return false
+	}
+	for _, allowed := range c.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseCORSOrigins 校验 cors_origins：每项须为 "*" 或不带路径的 http(s)://host[:port]，末尾斜杠被去除。
+func parseCORSOrigins(origins []string, credentials bool) ([]string, error) {
+	out := make([]string, 0, len(origins))
+	for _, origin := range origins {
+		origin = strings.TrimSuffix(strings.TrimSpace(origin), "/")
+		if origin == "*" {
+			if credentials {
+				return nil, fmt.Errorf("cors_credentials 为 true 时 cors_origins 不能包含 \"*\"")
+			}
+			out = append(out, origin)
+			continue
+		}
+		u, err := url.Parse(origin)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" ||
+			u.Path != "" || u.RawQuery != "" || u.Fragment != "" || u.User != nil {
+			return nil, fmt.Errorf("cors_origins 中的来源无效（应为 http(s)://host[:port]）: %q", origin)
+		}
+		out = append(out, origin)
+	}
+	return out, nil
+}
@@ -0,0 +1,112 @@
+package core
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"time"
+
+	"codex-backup-tool/internal/util"
+)
+
+// 诊断包中包含的最近事件与审计记录条数。
+const (
+	debugBundleEvents = 500
+	debugBundleAudit  = 500
+)
+
+// debugPlatform 为诊断包中的 platform.json。
+type debugPlatform struct {
+	GeneratedAt   time.Time `json:"generated_at"`
+	Platform      string    `json:"platform"`
+	GoVersion     string    `json:"go_version"`
+	OS            string    `json:"os"`
+	Arch          string    `json:"arch"`
+	NumCPU        int       `json:"num_cpu"`
+	PID           int       `json:"pid"`
+	InstanceID    string    `json:"instance_id"`
+	StartedAt     time.Time `json:"started_at"`
+	UptimeSeconds int64     `json:"uptime_seconds"`
+}
+
+// debugSectionError 代替无法生成的部分写入诊断包，其余部分照常输出。
+type debugSectionError struct {
+	Error string `json:"error"`
+}
+
+// WriteDebugBundle 将排查问题所需的信息打包为 zip 写入 w：平台信息、脱敏后的配置、状态、自检、
+// 统计、索引、一致性检查、扫描历史以及最近的事件与审计记录。诊断包不包含任何备份文件内容、
+// 目标文件或令牌文件，每个条目写入前都经过 util.Redact。单个部分失败时记录错误信息并继续。
+func (s *Service) WriteDebugBundle(w io.Writer) error {
+	now := time.Now().UTC()
+	zw := zip.NewWriter(w)
+	add := func(name string, v any, err error) error {
+		if err != nil {
+			v = debugSectionError{Error: err.Error()}
+		}
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return fmt.Errorf("marshal %s: %w", name, err)
+		}
+		f, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: now})
+		if err != nil {
+			return fmt.Errorf("create %s: %w", name, err)
+		}
+		if _, err := io.WriteString(f, util.Redact(string(data))+"\n"); err != nil {
+			return fmt.Errorf("write %s: %w", name, err)
+		}
+		return nil
+	}
+
+	platform := debugPlatform{
+		GeneratedAt:   now,
+		Platform:      PlatformInfo(),
+		GoVersion:     runtime.Version(),
+		OS:            runtime.GOOS,
+		Arch:          runtime.GOARCH,
+		NumCPU:        runtime.NumCPU(),
+		PID:           os.Getpid(),
+		InstanceID:    s.instanceID,
+		StartedAt:     s.startedAt,
+		UptimeSeconds: int64(now.Sub(s.startedAt).Seconds()),
+	}
+	status, statusErr := s.Status()
+	idx, idxErr := s.store.Snapshot()
+	consistency, consistencyErr := s.CheckConsistency()
+	var events []Event
+	page, eventsErr := s.events.Query(EventQuery{Limit: debugBundleEvents})
+	if eventsErr == nil {
+		events = page.Events
+	}
+	audit, auditErr := s.audit.Recent(debugBundleAudit)
+
+	sections := []struct {
+		name string
+		v    any
+		err  error
+	}{
+		{"platform.json", platform, nil},
+		{"config.json", s.PublicConfig(), nil},
+		{"status.json", status, statusErr},
+		{"selftest.json", s.LastSelfTest(), nil},
+		{"stats.json", s.Stats(), nil},
+		{"index.json", idx, idxErr},
+		{"consistency.json", consistency, consistencyErr},
+		{"scan-history.json", s.ScanHistory(s.scanHistory.size), nil},
+		{"events.json", events, eventsErr},
+		{"audit.json", audit, auditErr},
+	}
+	for _, sec := range sections {
+		if err := add(sec.name, sec.v, sec.err); err != nil {
+			zw.Close()
+			return err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("close debug bundle: %w", err)
+	}
+	return nil
+}
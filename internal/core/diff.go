@@ -0,0 +1,371 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ErrNotJSON 在参与 JSON 差异比较的备份内容不是合法 JSON 时返回。
+var ErrNotJSON = errors.New("backup content is not valid JSON")
+
+// JSON Patch（RFC 6902）中使用的操作类型。
+const (
+	DiffOpAdd     = "add"
+	DiffOpRemove  = "remove"
+	DiffOpReplace = "replace"
+)
+
+// JSONKeyDiff 描述两个版本之间某个 JSON 路径上的变化，Path 为 RFC 6901 JSON Pointer。
+// add 只有 After，remove 只有 Before，replace 两者都有。
+type JSONKeyDiff struct {
+	Path   string          `json:"path"`
+	Op     string          `json:"op"`
+	Before json.RawMessage `json:"before,omitempty"`
+	After  json.RawMessage `json:"after,omitempty"`
+}
+
+// DiffBackupsJSON 按 JSON 结构比较两个备份，返回从 id1 变为 id2 所需的逐键变化，内容相同时返回空切片。
+// 对象按键递归比较；数组按下标比较，多出或缺少的尾部元素记为 add/remove。键名形似令牌的值与 DiffBackups 一样已掩码。
+func (s *Service) DiffBackupsJSON(id1, id2 string) ([]JSONKeyDiff, error) {
+	before, err := s.readBackupJSON(id1)
+	if err != nil {
+		return nil, err
+	}
+	after, err := s.readBackupJSON(id2)
+	if err != nil {
+		return nil, err
+	}
+	diffs := make([]JSONKeyDiff, 0)
+	if err := diffJSONValues("", before, after, &diffs); err != nil {
+		return nil, err
+	}
+	for i := range diffs {
+		if err := maskJSONKeyDiff(&diffs[i]); err != nil {
+			return nil, err
+		}
+	}
+	return diffs, nil
+}
+
+// DiffBackups 的结果格式。
+const (
+	DiffFormatJSON = "json"
+	DiffFormatText = "text"
+)
+
+// DiffResult 为 DiffBackups 的结果。两个备份都是合法 JSON 时 Format 为 json，Changes 列出逐键变化，
+// 键名形似令牌的值已按 MaskSecrets 掩码；否则 Format 为 text，Unified 为原始文本逐行比较的 unified diff（已脱敏）。
+type DiffResult struct {
+	From    string        `json:"from"`
+	To      string        `json:"to"`
+	Format  string        `json:"format"`
+	Same    bool          `json:"same"`
+	Changes []JSONKeyDiff `json:"changes,omitempty"`
+	Unified string        `json:"unified,omitempty"`
+	// TargetMissing 仅用于 DiffAgainstTarget，表示比较时目标文件不存在。
+	TargetMissing bool `json:"target_missing,omitempty"`
+}
+
+// DiffBackups 比较从 aID 到 bID 的变化，任一备份不是合法 JSON 时退回逐行文本比较。
+func (s *Service) DiffBackups(aID, bID string) (*DiffResult, error) {
+	aItem, aData, err := s.readBackup(aID)
+	if err != nil {
+		return nil, err
+	}
+	bItem, bData, err := s.readBackup(bID)
+	if err != nil {
+		return nil, err
+	}
+	res := &DiffResult{From: aID, To: bID}
+	if err := res.compare("a/"+aItem.Filename, "b/"+bItem.Filename, aData, bData); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// DiffTarget 为 DiffAgainstTarget 结果中 From 的取值，表示当前目标文件。
+const DiffTarget = "target"
+
+// DiffAgainstTarget 比较从当前目标文件到备份 id 的变化，即还原该备份时目标文件将发生的变化，格式与 DiffBackups 相同。
+// 目标文件不存在时 TargetMissing 为 true，备份中的每个字段都记为由还原新增（文本格式下每行都是新增行）。
+func (s *Service) DiffAgainstTarget(id string) (*DiffResult, error) {
+	item, backupData, err := s.readBackup(id)
+	if err != nil {
+		return nil, err
+	}
+	res := &DiffResult{From: DiffTarget, To: id}
+	_, targetData, err := ComputeContentHash(s.cfg.TargetPath, s.cfg.HashAlgo)
+	if errors.Is(err, fs.ErrNotExist) {
+		res.TargetMissing = true
+		targetData = nil
+	} else if err != nil {
+		return nil, fmt.Errorf("读取目标内容: %w", err)
+	}
+	if err := res.compare("a/"+filepath.Base(s.cfg.TargetPath), "b/"+item.Filename, targetData, backupData); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// compare 填充 Format、Changes/Unified 与 Same；TargetMissing 时旧版本视为不存在，新版本中的字段全部记为 add。
+func (res *DiffResult) compare(fromName, toName string, beforeData, afterData []byte) error {
+	after, errB := parseJSONContent(afterData)
+	var before interface{}
+	var errA error
+	if res.TargetMissing && errB == nil {
+		before = emptyLike(after)
+	} else {
+		before, errA = parseJSONContent(beforeData)
+	}
+	if errA != nil || errB != nil {
+		res.Format = DiffFormatText
+		res.Unified = unifiedDiff(fromName, toName, splitLines(beforeData), splitLines(afterData), 3)
+		res.Same = res.Unified == ""
+		return nil
+	}
+	diffs := make([]JSONKeyDiff, 0)
+	if before == nil {
+		raw, err := json.Marshal(after)
+		if err != nil {
+			return err
+		}
+		diffs = append(diffs, JSONKeyDiff{Path: "", Op: DiffOpAdd, After: raw})
+	} else if err := diffJSONValues("", before, after, &diffs); err != nil {
+		return err
+	}
+	for i := range diffs {
+		if err := maskJSONKeyDiff(&diffs[i]); err != nil {
+			return err
+		}
+	}
+	res.Format = DiffFormatJSON
+	res.Changes = diffs
+	res.Same = len(diffs) == 0
+	return nil
+}
+
+// emptyLike 返回与 v 同类的空容器，使缺失的旧版本与 v 逐键比较；标量返回 nil，表示整个值为新增。
+func emptyLike(v interface{}) interface{} {
+	switch v.(type) {
+	case map[string]interface{}:
+		return map[string]interface{}{}
+	case []interface{}:
+		return []interface{}{}
+	default:
+		return nil
+	}
+}
+
+// maskJSONKeyDiff 掩码变化前后的值；路径中最后一个非下标的键名决定标量值是否敏感。
+func maskJSONKeyDiff(d *JSONKeyDiff) error {
+	sensitive := false
+	segments := strings.Split(d.Path, "/")
+	for i := len(segments) - 1; i > 0; i-- {
+		if _, err := strconv.Atoi(segments[i]); err != nil {
+			sensitive = isSecretKey(segments[i])
+			break
+		}
+	}
+	for _, raw := range []*json.RawMessage{&d.Before, &d.After} {
+		if len(*raw) == 0 {
+			continue
+		}
+		v, err := parseJSONContent(*raw)
+		if err != nil {
+			return err
+		}
+		masked, err := json.Marshal(maskValue(v, sensitive))
+		if err != nil {
+			return err
+		}
+		*raw = masked
+	}
+	return nil
+}
+
+// ComparisonResult 为 CompareTargetToLatest 的结果。
+type ComparisonResult struct {
+	LatestBackup       *BackupItem `json:"latest_backup"`
+	CurrentContentHash string      `json:"current_content_hash"`
+	Same               bool        `json:"same"`
+	// Diff 每行一个发生变化的 JSON 路径（如 "replace /tokens/access_token"），不包含取值；
+	// 内容相同、目标不可读或任一侧不是 JSON 时为空。
+	Diff string `json:"diff"`
+}
+
+// CompareTargetToLatest 比较当前目标文件与最新备份，没有任何备份时返回 ErrBackupNotFound。
+// 目标文件不存在或超出大小限制时 CurrentContentHash 为空、Same 为 false。
+func (s *Service) CompareTargetToLatest() (*ComparisonResult, error) {
+	items, err := s.store.ListBackups()
+	if err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, ErrBackupNotFound
+	}
+	latest, backupData, err := s.readBackup(items[0].ID)
+	if err != nil {
+		return nil, err
+	}
+	res := &ComparisonResult{LatestBackup: latest}
+	target, err := s.EvaluateTarget()
+	if err != nil {
+		return nil, err
+	}
+	if !target.Backupable() {
+		return res, nil
+	}
+	hash, data, err := ComputeContentHash(s.cfg.TargetPath, s.cfg.HashAlgo)
+	if err != nil {
+		return nil, fmt.Errorf("读取目标内容: %w", err)
+	}
+	res.CurrentContentHash = hash
+	res.Same = hash == latest.ContentHash
+	if res.Same {
+		return res, nil
+	}
+	before, err := parseJSONContent(backupData)
+	if err != nil {
+		return res, nil
+	}
+	after, err := parseJSONContent(data)
+	if err != nil {
+		return res, nil
+	}
+	diffs := make([]JSONKeyDiff, 0)
+	if err := diffJSONValues("", before, after, &diffs); err != nil {
+		return nil, err
+	}
+	lines := make([]string, 0, len(diffs))
+	for _, d := range diffs {
+		lines = append(lines, d.Op+" "+d.Path)
+	}
+	res.Diff = strings.Join(lines, "\n")
+	return res, nil
+}
+
+func (s *Service) readBackupJSON(id string) (interface{}, error) {
+	_, data, err := s.readBackup(id)
+	if err != nil {
+		return nil, err
+	}
+	v, err := parseJSONContent(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: id=%s: %v", ErrNotJSON, id, err)
+	}
+	return v, nil
+}
+
+// parseJSONContent 解析单个 JSON 值，数字保留原始文本，末尾不允许有多余数据。
+func parseJSONContent(data []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errors.New("trailing data")
+	}
+	return v, nil
+}
+
+func diffJSONValues(path string, before, after interface{}, out *[]JSONKeyDiff) error {
+	switch b := before.(type) {
+	case map[string]interface{}:
+		if a, ok := after.(map[string]interface{}); ok {
+			return diffJSONObjects(path, b, a, out)
+		}
+	case []interface{}:
+		if a, ok := after.([]interface{}); ok {
+			return diffJSONArrays(path, b, a, out)
+		}
+	}
+	beforeRaw, err := json.Marshal(before)
+	if err != nil {
+		return err
+	}
+	afterRaw, err := json.Marshal(after)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(beforeRaw, afterRaw) {
+		*out = append(*out, JSONKeyDiff{Path: path, Op: DiffOpReplace, Before: beforeRaw, After: afterRaw})
+	}
+	return nil
+}
+
+func diffJSONObjects(path string, before, after map[string]interface{}, out *[]JSONKeyDiff) error {
+	keys := make([]string, 0, len(before)+len(after))
+	for k := range before {
+		keys = append(keys, k)
+	}
+	for k := range after {
+		if _, ok := before[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		child := path + "/" + escapeJSONPointer(k)
+		b, inBefore := before[k]
+		a, inAfter := after[k]
+		if err := diffJSONMember(child, b, inBefore, a, inAfter, out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func diffJSONArrays(path string, before, after []interface{}, out *[]JSONKeyDiff) error {
+	n := len(before)
+	if len(after) > n {
+		n = len(after)
+	}
+	for i := 0; i < n; i++ {
+		var b, a interface{}
+		if i < len(before) {
+			b = before[i]
+		}
+		if i < len(after) {
+			a = after[i]
+		}
+		if err := diffJSONMember(path+"/"+strconv.Itoa(i), b, i < len(before), a, i < len(after), out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func diffJSONMember(path string, before interface{}, inBefore bool, after interface{}, inAfter bool, out *[]JSONKeyDiff) error {
+	switch {
+	case inBefore && inAfter:
+		return diffJSONValues(path, before, after, out)
+	case inBefore:
+		raw, err := json.Marshal(before)
+		if err != nil {
+			return err
+		}
+		*out = append(*out, JSONKeyDiff{Path: path, Op: DiffOpRemove, Before: raw})
+	default:
+		raw, err := json.Marshal(after)
+		if err != nil {
+			return err
+		}
+		*out = append(*out, JSONKeyDiff{Path: path, Op: DiffOpAdd, After: raw})
+	}
+	return nil
+}
+
+// escapeJSONPointer 按 RFC 6901 转义路径中的 ~ 与 /。
+func escapeJSONPointer(key string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(key)
+}
@@ -0,0 +1,250 @@
+package core
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+
+	"golang.org/x/crypto/scrypt"
+
+	"codex-backup-tool/internal/util"
+)
+
+var (
+	// ErrDecryptFailed 在加密备份无法解密时返回，通常是 encryption_passphrase 与创建备份时不同，或备份文件已损坏。
+	ErrDecryptFailed = errors.New("backup decryption failed")
+	// ErrEncryptionKeyMissing 在读取加密备份但未配置 encryption_passphrase 时返回。
+	ErrEncryptionKeyMissing = errors.New("backup is encrypted but no encryption passphrase is configured")
+)
+
+const (
+	// encryptionSaltSize 为 scrypt 盐的字节数。
+	encryptionSaltSize = 16
+	// scrypt 参数取 N=2^15、r=8、p=1，派生一次约需 32 MiB 内存与数十毫秒；同一盐的密钥在进程内缓存。
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+	// keyIDSize 为密钥 ID 的字节数，十六进制表示为 16 个字符。
+	keyIDSize = 8
+)
+
+// encryptedHeaderMagic 为加密备份文件头的标识，其后依次为版本号、密钥 ID 与盐（各以一个长度字节开头），
+// 再之后是 nonce 与密文。文件头同时作为 AES-GCM 的附加数据参与认证。
+// 早期版本写入的加密文件没有文件头，直接以 nonce 开始，盐只记录在索引条目中。
+const (
+	encryptedHeaderMagic   = "CBKE"
+	encryptedHeaderVersion = 1
+)
+
+// keyIDSalt 为计算密钥 ID 的固定盐：同一口令总是得到同一 ID，与每个备份的随机盐无关。
+var keyIDSalt = []byte("codex-backup-tool/key-id/v1")
+
+// encryptionInfo 为加密写入备份时使用的盐（base64）与密钥 ID，记录在 BackupItem 中；未加密时均为空。
+type encryptionInfo struct {
+	Salt  string
+	KeyID string
+}
+
+// backupCipher 以 AES-256-GCM 加密备份文件，密钥由 scrypt 从口令与盐派生。
+// 新备份在进程内共用一个随机盐，只需派生一次密钥；读取时按文件头或条目记录的盐派生并缓存。
+type backupCipher struct {
+	passphrase []byte
+	salt       string
+
+	keyIDOnce sync.Once
+	keyID     string
+	keyIDErr  error
+
+	mu    sync.Mutex
+	aeads map[string]cipher.AEAD
+}
+
+// newBackupCipher 返回使用 passphrase 的加密器，并为本进程新建的备份生成随机盐。
+func newBackupCipher(passphrase string) (*backupCipher, error) {
+	salt := make([]byte, encryptionSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("生成加密盐: %w", err)
+	}
+	return &backupCipher{
+		passphrase: []byte(passphrase),
+		salt:       base64.StdEncoding.EncodeToString(salt),
+		aeads:      make(map[string]cipher.AEAD),
+	}, nil
+}
+
+// KeyID 返回口令的密钥 ID（十六进制），由 scrypt 以固定盐派生，不同口令的 ID 不同，且无法由 ID 还原口令。
+func (c *backupCipher) KeyID() (string, error) {
+	c.keyIDOnce.Do(func() {
+		id, err := scrypt.Key(c.passphrase, keyIDSalt, scryptN, scryptR, scryptP, keyIDSize)
+		c.keyID, c.keyIDErr = hex.EncodeToString(id), err
+	})
+	return c.keyID, c.keyIDErr
+}
+
+// aead 返回 salt（base64）对应的 AES-GCM 实例。
+func (c *backupCipher) aead(salt string) (cipher.AEAD, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if aead, ok := c.aeads[salt]; ok {
+		return aead, nil
+	}
+	rawSalt, err := base64.StdEncoding.DecodeString(salt)
+	if err != nil || len(rawSalt) == 0 {
+		return nil, fmt.Errorf("%w: 无效的 kdf_salt", ErrDecryptFailed)
+	}
+	key, err := scrypt.Key(c.passphrase, rawSalt, scryptN, scryptR, scryptP, 32)
+	if err != nil {
+		return nil, fmt.Errorf("派生加密密钥: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	c.aeads[salt] = aead
+	return aead, nil
+}
+
+// seal 加密 data，返回文件头、随机 nonce 与密文拼接后的内容，以及所用的盐与密钥 ID。
+func (c *backupCipher) seal(data []byte) ([]byte, encryptionInfo, error) {
+	keyID, err := c.KeyID()
+	if err != nil {
+		return nil, encryptionInfo{}, fmt.Errorf("派生密钥 ID: %w", err)
+	}
+	aead, err := c.aead(c.salt)
+	if err != nil {
+		return nil, encryptionInfo{}, err
+	}
+	rawSalt, _ := base64.StdEncoding.DecodeString(c.salt)
+	header := encodeEncryptedHeader(keyID, rawSalt)
+	out := make([]byte, len(header)+aead.NonceSize(), len(header)+aead.NonceSize()+len(data)+aead.Overhead())
+	copy(out, header)
+	nonce := out[len(header):]
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, encryptionInfo{}, fmt.Errorf("生成 nonce: %w", err)
+	}
+	return aead.Seal(out, nonce, data, header), encryptionInfo{Salt: c.salt, KeyID: keyID}, nil
+}
+
+// open 解密 seal 的输出；带文件头的文件使用文件头中的盐并核对密钥 ID，旧格式使用条目记录的 salt。
+// c 为 nil（未配置口令）时返回 ErrEncryptionKeyMissing。
+func (c *backupCipher) open(salt string, data []byte) ([]byte, error) {
+	if c == nil {
+		return nil, ErrEncryptionKeyMissing
+	}
+	var header []byte
+	if h, ok := parseEncryptedHeader(data); ok {
+		keyID, err := c.KeyID()
+		if err != nil {
+			return nil, fmt.Errorf("派生密钥 ID: %w", err)
+		}
+		if h.KeyID != keyID {
+			return nil, fmt.Errorf("%w: 备份使用密钥 %s，当前口令对应 %s", ErrDecryptFailed, h.KeyID, keyID)
+		}
+		header, salt, data = data[:h.Len], h.Salt, data[h.Len:]
+	}
+	aead, err := c.aead(salt)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < aead.NonceSize() {
+		return nil, ErrDecryptFailed
+	}
+	plain, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], header)
+	if err != nil {
+		return nil, ErrDecryptFailed
+	}
+	return plain, nil
+}
+
+// encryptedHeader 为解析出的加密文件头，Salt 为 base64，Len 为文件头字节数。
+type encryptedHeader struct {
+	KeyID string
+	Salt  string
+	Len   int
+}
+
+func encodeEncryptedHeader(keyID string, salt []byte) []byte {
+	header := make([]byte, 0, len(encryptedHeaderMagic)+3+len(keyID)+len(salt))
+	header = append(header, encryptedHeaderMagic...)
+	header = append(header, encryptedHeaderVersion, byte(len(keyID)))
+	header = append(header, keyID...)
+	header = append(header, byte(len(salt)))
+	return append(header, salt...)
+}
+
+// parseEncryptedHeader 解析 data 开头的文件头，没有文件头（旧格式）时 ok 为 false。
+func parseEncryptedHeader(data []byte) (encryptedHeader, bool) {
+	if !bytes.HasPrefix(data, []byte(encryptedHeaderMagic)) {
+		return encryptedHeader{}, false
+	}
+	p := len(encryptedHeaderMagic)
+	if len(data) < p+2 || data[p] != encryptedHeaderVersion {
+		return encryptedHeader{}, false
+	}
+	n := int(data[p+1])
+	p += 2
+	if n == 0 || len(data) < p+n+1 {
+		return encryptedHeader{}, false
+	}
+	keyID := string(data[p : p+n])
+	p += n
+	n = int(data[p])
+	p++
+	if n == 0 || len(data) < p+n {
+		return encryptedHeader{}, false
+	}
+	salt := base64.StdEncoding.EncodeToString(data[p : p+n])
+	return encryptedHeader{KeyID: keyID, Salt: salt, Len: p + n}, true
+}
+
+// cipher 返回当前用于新备份与读取的加密器，未配置加密口令时为 nil；轮换口令后替换为新口令的加密器。
+func (s *Service) cipher() *backupCipher {
+	return s.encryption.Load()
+}
+
+// writeBackupFile 将备份内容写入 BackupsDir；配置了加密口令时先按文件名压缩再加密，返回所用的盐与密钥 ID，未加密时为空。
+func (s *Service) writeBackupFile(filename string, data []byte) (encryptionInfo, error) {
+	c := s.cipher()
+	if c == nil {
+		_, err := WriteBackupFile(s.cfg.BackupsDir, filename, data)
+		return encryptionInfo{}, err
+	}
+	encoded, err := encodeBackupData(filename, data)
+	if err != nil {
+		return encryptionInfo{}, err
+	}
+	sealed, info, err := c.seal(encoded)
+	if err != nil {
+		return encryptionInfo{}, fmt.Errorf("加密备份内容: %w", err)
+	}
+	if err := util.EnsureDir(s.cfg.BackupsDir); err != nil {
+		return encryptionInfo{}, err
+	}
+	if err := util.AtomicWriteFile(filepath.Join(s.cfg.BackupsDir, filename), sealed, 0o600); err != nil {
+		return encryptionInfo{}, err
+	}
+	return info, nil
+}
+
+// decryptBackupData 返回备份文件解密后的内容，未加密的条目原样返回；压缩的备份仍为 gzip。
+func (s *Service) decryptBackupData(item *BackupItem, data []byte) ([]byte, error) {
+	if !item.Encrypted {
+		return data, nil
+	}
+	plain, err := s.cipher().open(item.KDFSalt, data)
+	if err != nil {
+		return nil, fmt.Errorf("解密备份文件 %s: %w", item.Filename, err)
+	}
+	return plain, nil
+}
@@ -0,0 +1,129 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"sync"
+
+	"codex-backup-tool/internal/util"
+)
+
+// ErrInvalidConsumer 在事件消费者名称不合法时返回。
+var ErrInvalidConsumer = errors.New("invalid event consumer")
+
+var consumerPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// eventCursorsFile 保存各消费者（webui、tray、cli 等）已读到的事件 ID。
+const eventCursorsFile = "event_cursors.json"
+
+// eventCursors 为按消费者名称保存的已读位置，写入时整体原子替换文件。
+type eventCursors struct {
+	path string
+	mu   sync.Mutex
+}
+
+func (c *eventCursors) loadLocked() (map[string]string, error) {
+	cursors := make(map[string]string)
+	data, err := os.ReadFile(util.LongPath(c.path))
+	if os.IsNotExist(err) {
+		return cursors, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read event cursors: %w", err)
+	}
+	if err := json.Unmarshal(data, &cursors); err != nil {
+		return nil, fmt.Errorf("parse event cursors: %w", err)
+	}
+	return cursors, nil
+}
+
+func (c *eventCursors) get(consumer string) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cursors, err := c.loadLocked()
+	if err != nil {
+		return 0, err
+	}
+	n, _ := strconv.ParseInt(cursors[consumer], 10, 64)
+	return n, nil
+}
+
+// advance 将消费者的游标移到 seq，seq 不大于当前游标时不做任何事，返回最终的游标。
+func (c *eventCursors) advance(consumer string, seq int64) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cursors, err := c.loadLocked()
+	if err != nil {
+		return 0, err
+	}
+	current, _ := strconv.ParseInt(cursors[consumer], 10, 64)
+	if seq <= current {
+		return current, nil
+	}
+	cursors[consumer] = strconv.FormatInt(seq, 10)
+	data, err := json.MarshalIndent(cursors, "", "  ")
+	if err != nil {
+		return 0, fmt.Errorf("marshal event cursors: %w", err)
+	}
+	if err := util.AtomicWriteFile(c.path, data, 0o600); err != nil {
+		return 0, fmt.Errorf("write event cursors: %w", err)
+	}
+	return seq, nil
+}
+
+// UnreadEvents 为某个消费者的未读事件，Events 为其中最新的若干条（新的在前），Count 为未读总数。
+type UnreadEvents struct {
+	Consumer string  `json:"consumer"`
+	Cursor   string  `json:"cursor"`
+	Count    int     `json:"count"`
+	Events   []Event `json:"events"`
+}
+
+// UnreadEvents 返回 consumer 游标之后的事件；从未确认过的消费者视全部事件为未读。
+func (s *Service) UnreadEvents(consumer string, limit int) (*UnreadEvents, error) {
+	if !consumerPattern.MatchString(consumer) {
+		return nil, ErrInvalidConsumer
+	}
+	cursor, err := s.eventCursors.get(consumer)
+	if err != nil {
+		return nil, err
+	}
+	events, count, err := s.events.after(cursor, limit)
+	if err != nil {
+		return nil, err
+	}
+	return &UnreadEvents{Consumer: consumer, Cursor: formatCursor(cursor), Count: count, Events: events}, nil
+}
+
+// AckEvents 将 consumer 的已读位置推进到事件 id。id 不存在或早于当前位置时不做任何事，
+// 返回确认后的游标。
+func (s *Service) AckEvents(consumer, id string) (string, error) {
+	if !consumerPattern.MatchString(consumer) {
+		return "", ErrInvalidConsumer
+	}
+	seq, err := strconv.ParseInt(id, 10, 64)
+	if err == nil {
+		var found bool
+		if found, err = s.events.contains(seq); err != nil {
+			return "", err
+		} else if !found {
+			seq = 0
+		}
+	}
+	cursor, err := s.eventCursors.advance(consumer, seq)
+	if err != nil {
+		return "", err
+	}
+	return formatCursor(cursor), nil
+}
+
+func formatCursor(cursor int64) string {
+	if cursor == 0 {
+		return ""
+	}
+	return strconv.FormatInt(cursor, 10)
+}
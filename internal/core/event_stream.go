@@ -0,0 +1,71 @@
+package core
+
+import (
+	"sync"
+	"time"
+
+	"codex-backup-tool/internal/util"
+)
+
+// eventSubscriberBuffer 为每个实时订阅者缓冲的事件数，订阅者读取过慢时新事件被丢弃，不阻塞发出事件的操作。
+const eventSubscriberBuffer = 64
+
+// EventSubscription 为一个实时事件订阅者，由 Service.SubscribeEvents 创建。
+type EventSubscription struct {
+	events chan Event
+	done   chan struct{}
+	once   sync.Once
+}
+
+// Events 返回接收事件的通道，该通道不会被关闭，结束请以 Done 判断。
+func (sub *EventSubscription) Events() <-chan Event {
+	return sub.events
+}
+
+// Done 在取消订阅或服务关闭实时事件流后关闭。
+func (sub *EventSubscription) Done() <-chan struct{} {
+	return sub.done
+}
+
+func (sub *EventSubscription) close() {
+	sub.once.Do(func() { close(sub.done) })
+}
+
+// SubscribeEvents 注册实时事件订阅者，此后发出的事件（与写入 events.log 的相同，尚未分配 ID）都会推送给它。
+// 调用方用完后须调用 UnsubscribeEvents。
+func (s *Service) SubscribeEvents() *EventSubscription {
+	sub := &EventSubscription{events: make(chan Event, eventSubscriberBuffer), done: make(chan struct{})}
+	s.subscribers.Store(sub, struct{}{})
+	return sub
+}
+
+// UnsubscribeEvents 注销订阅者并关闭其 Done 通道，可重复调用。
+func (s *Service) UnsubscribeEvents(sub *EventSubscription) {
+	s.subscribers.Delete(sub)
+	sub.close()
+}
+
+// CloseEventStreams 注销全部订阅者，使长连接的事件流结束，供 HTTP 服务优雅关闭前调用。
+func (s *Service) CloseEventStreams() {
+	s.subscribers.Range(func(key, _ any) bool {
+		s.UnsubscribeEvents(key.(*EventSubscription))
+		return true
+	})
+}
+
+// publish 将事件非阻塞地推送给所有订阅者，缓冲已满的订阅者错过该事件。与 EventLog.Emit 一样先脱敏。
+func (s *Service) publish(e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	e.Reason = util.Redact(e.Reason)
+	e.Summary = util.Redact(e.Summary)
+	s.subscribers.Range(func(key, _ any) bool {
+		sub := key.(*EventSubscription)
+		select {
+		case sub.events <- e:
+		default:
+		}
+		return true
+	})
+}
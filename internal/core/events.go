@@ -0,0 +1,373 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"codex-backup-tool/internal/util"
+)
+
+// 服务发出的事件类型。
+const (
+	EventBackupCreated       = "backup.created"
+	EventBackupDeleted       = "backup.deleted"
+	EventBackupRestored      = "backup.restored"
+	EventBackupRemarkUpdated = "backup.remark_updated"
+	EventScanFailed          = "scan.failed"
+	EventIndexTampered       = "index.tampered"
+	EventIndexAccepted       = "index.accepted"
+	EventDataPurged          = "data.purged"
+	EventBackupStale         = "backup.stale"
+	// EventHookFinished 记录 hooks 中命令的执行结果，不会再触发 hook。
+	EventHookFinished = "hook.finished"
+)
+
+// KnownEvents 为 hooks 配置中允许使用的事件类型。
+var KnownEvents = []string{
+	EventBackupCreated, EventBackupDeleted, EventBackupRestored, EventBackupRemarkUpdated,
+	EventScanFailed, EventIndexTampered, EventIndexAccepted, EventDataPurged,
+	EventBackupStale,
+}
+
+const (
+	// eventQueueSize 为等待写入的事件上限，队列满时新事件被丢弃并计数。
+	eventQueueSize = 1024
+	// eventKeep 为按大小轮转后保留的历史事件日志份数。
+	eventKeep = 3
+)
+
+// Event 为 events.log 中的一行记录，只包含备注、原因等元数据，不包含目标文件内容。
+// ID 为单调递增的十进制字符串（取自写入时间的纳秒数），可用于标记已读位置。
+type Event struct {
+	ID      string    `json:"id,omitempty"`
+	Time    time.Time `json:"ts"`
+	Type    string    `json:"type"`
+	ItemID  string    `json:"item_id,omitempty"`
+	Remark  string    `json:"remark,omitempty"`
+	Reason  string    `json:"reason,omitempty"`
+	Summary string    `json:"summary,omitempty"`
+	// InstanceID 为产生事件的数据目录实例 ID。
+	InstanceID string `json:"instance_id,omitempty"`
+}
+
+// EventQuery 为 EventLog.Query 的过滤条件，零值字段不参与过滤。
+type EventQuery struct {
+	// Since 只返回不早于该时间的事件。
+	Since time.Time
+	// Before 只返回早于该时间的事件，用于翻页。
+	Before time.Time
+	Type   string
+	Limit  int
+}
+
+// EventPage 为一页查询结果，事件新的在前。NextBefore 非空表示还有更早的事件，
+// 作为下一页的 Before 传入即可。
+type EventPage struct {
+	Events     []Event `json:"events"`
+	NextBefore string  `json:"next_before,omitempty"`
+	Dropped    int64   `json:"dropped"`
+}
+
+type eventRequest struct {
+	event   *Event
+	flushed chan struct{}
+}
+
+// EventLog 在后台 goroutine 中把事件追加写入 JSON Lines 文件，Emit 从不阻塞调用方。
+// 每批写入时打开文件、写完即关闭，文件被清空或轮转后无需额外处理。
+type EventLog struct {
+	path    string
+	maxSize int64
+
+	queue   chan eventRequest
+	stop    chan struct{}
+	done    chan struct{}
+	once    sync.Once
+	closed  atomic.Bool
+	dropped atomic.Int64
+	// lastID 为最近分配的事件 ID，只在写入 goroutine 中更新，保证时钟回拨时 ID 仍然递增。
+	lastID atomic.Int64
+
+	// mu 串行化写入批次与查询，避免查询读到轮转中途的文件。
+	mu sync.Mutex
+}
+
+// NewEventLog 创建事件日志并启动后台写入。maxSize 为 0 表示不轮转。
+func NewEventLog(path string, maxSize int64) *EventLog {
+	l := &EventLog{
+		path:    path,
+		maxSize: maxSize,
+		queue:   make(chan eventRequest, eventQueueSize),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	readLinesBackward(path, func(line []byte) bool {
+		var e Event
+		if json.Unmarshal(line, &e) != nil {
+			return true
+		}
+		l.lastID.Store(e.seq())
+		return false
+	})
+	go l.run()
+	return l
+}
+
+// seq 返回事件 ID 对应的序号；没有 ID 的旧记录以时间戳代替。
+func (e Event) seq() int64 {
+	if n, err := strconv.ParseInt(e.ID, 10, 64); err == nil {
+		return n
+	}
+	return e.Time.UnixNano()
+}
+
+// nextID 以 t 的纳秒时间戳作为 ID，不大于上一个 ID 时顺延。
+func (l *EventLog) nextID(t time.Time) int64 {
+	id := max(t.UnixNano(), l.lastID.Load()+1)
+	l.lastID.Store(id)
+	return id
+}
+
+// Emit 将事件放入写入队列。队列已满或日志已关闭时丢弃事件并计数，不返回错误。
+func (l *EventLog) Emit(e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	e.Reason = util.Redact(e.Reason)
+	e.Summary = util.Redact(e.Summary)
+	if l.closed.Load() {
+		l.dropped.Add(1)
+		return
+	}
+	select {
+	case l.queue <- eventRequest{event: &e}:
+	default:
+		l.dropped.Add(1)
+	}
+}
+
+// Dropped 返回因队列已满、日志已关闭或写入失败而丢失的事件数。
+func (l *EventLog) Dropped() int64 {
+	return l.dropped.Load()
+}
+
+// Flush 等待此前 Emit 的事件全部写入文件。
+func (l *EventLog) Flush() {
+	ch := make(chan struct{})
+	select {
+	case l.queue <- eventRequest{flushed: ch}:
+	case <-l.done:
+		return
+	}
+	select {
+	case <-ch:
+	case <-l.done:
+	}
+}
+
+// Close 写完队列中剩余的事件后停止后台写入，之后的 Emit 只计入丢弃数。
+func (l *EventLog) Close() {
+	l.once.Do(func() {
+		l.closed.Store(true)
+		close(l.stop)
+	})
+	<-l.done
+}
+
+func (l *EventLog) run() {
+	defer close(l.done)
+	for {
+		select {
+		case req := <-l.queue:
+			l.writeBatch(l.collect(req))
+		case <-l.stop:
+			for {
+				select {
+				case req := <-l.queue:
+					l.writeBatch(l.collect(req))
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// collect 以 first 开头，取出队列中已就绪的请求，合并为一次写入。
+func (l *EventLog) collect(first eventRequest) []eventRequest {
+	batch := []eventRequest{first}
+	for len(batch) < eventQueueSize {
+		select {
+		case req := <-l.queue:
+			batch = append(batch, req)
+		default:
+			return batch
+		}
+	}
+	return batch
+}
+
+func (l *EventLog) writeBatch(batch []eventRequest) {
+	var buf []byte
+	count := 0
+	for _, req := range batch {
+		if req.event == nil {
+			continue
+		}
+		// 在唯一的写入 goroutine 中分配 ID，文件中的顺序与 ID 顺序一致。
+		req.event.ID = strconv.FormatInt(l.nextID(req.event.Time), 10)
+		line, err := json.Marshal(req.event)
+		if err != nil {
+			l.dropped.Add(1)
+			continue
+		}
+		buf = append(append(buf, line...), '\n')
+		count++
+	}
+	if len(buf) > 0 {
+		l.mu.Lock()
+		if err := l.appendLocked(buf); err != nil {
+			l.dropped.Add(int64(count))
+		}
+		l.mu.Unlock()
+	}
+	for _, req := range batch {
+		if req.flushed != nil {
+			close(req.flushed)
+		}
+	}
+}
+
+func (l *EventLog) appendLocked(buf []byte) error {
+	if err := util.EnsureDir(filepath.Dir(l.path)); err != nil {
+		return err
+	}
+	if l.maxSize > 0 {
+		if info, err := os.Stat(util.LongPath(l.path)); err == nil && info.Size() > 0 && info.Size()+int64(len(buf)) > l.maxSize {
+			if err := rotateFiles(l.path, eventKeep); err != nil {
+				return err
+			}
+		}
+	}
+	f, err := os.OpenFile(util.LongPath(l.path), os.O_CREATE|os.O_APPEND|os.O_RDWR, 0o600)
+	if err != nil {
+		return err
+	}
+	// 上次崩溃可能留下不完整的最后一行，先补换行，避免与新记录拼接。
+	if info, err := f.Stat(); err == nil && info.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err := f.ReadAt(last, info.Size()-1); err == nil && last[0] != '\n' {
+			buf = append([]byte{'\n'}, buf...)
+		}
+	}
+	_, err = f.Write(buf)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Query 返回符合条件的事件（新的在前）。从文件末尾倒序读取，遇到早于 Since 的事件即停止，
+// 查询最近的事件不需要解析整个文件。时间戳完全相同的事件在翻页边界处可能被跳过。
+func (l *EventLog) Query(q EventQuery) (*EventPage, error) {
+	l.Flush()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if q.Limit <= 0 {
+		q.Limit = 100
+	}
+	events := make([]Event, 0)
+	done := false
+	for i := 0; i <= eventKeep && !done; i++ {
+		err := readLinesBackward(rotatedPath(l.path, i), func(line []byte) bool {
+			var e Event
+			// 崩溃时可能留下半行，跳过无法解析的行。
+			if json.Unmarshal(line, &e) != nil {
+				return true
+			}
+			if !q.Since.IsZero() && e.Time.Before(q.Since) {
+				done = true
+				return false
+			}
+			if (!q.Before.IsZero() && !e.Time.Before(q.Before)) || (q.Type != "" && e.Type != q.Type) {
+				return true
+			}
+			events = append(events, e)
+			// 多取一条用于判断是否还有下一页。
+			done = len(events) > q.Limit
+			return !done
+		})
+		if err != nil {
+			return nil, fmt.Errorf("read event log: %w", err)
+		}
+	}
+	page := &EventPage{Events: events, Dropped: l.Dropped()}
+	if len(events) > q.Limit {
+		page.Events = events[:q.Limit]
+		page.NextBefore = page.Events[q.Limit-1].Time.Format(time.RFC3339Nano)
+	}
+	return page, nil
+}
+
+// after 倒序读取序号大于 cursor 的事件，返回其中最新的 limit 条与总数。
+// 读到不大于 cursor 的事件即停止，因此只解析游标之后的部分。
+func (l *EventLog) after(cursor int64, limit int) ([]Event, int, error) {
+	l.Flush()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	events := make([]Event, 0)
+	count := 0
+	done := false
+	for i := 0; i <= eventKeep && !done; i++ {
+		err := readLinesBackward(rotatedPath(l.path, i), func(line []byte) bool {
+			var e Event
+			if json.Unmarshal(line, &e) != nil {
+				return true
+			}
+			if e.seq() <= cursor {
+				done = true
+				return false
+			}
+			count++
+			if len(events) < limit {
+				events = append(events, e)
+			}
+			return true
+		})
+		if err != nil {
+			return nil, 0, fmt.Errorf("read event log: %w", err)
+		}
+	}
+	return events, count, nil
+}
+
+// contains 判断日志中是否存在序号为 seq 的事件。
+func (l *EventLog) contains(seq int64) (bool, error) {
+	l.Flush()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	found, done := false, false
+	for i := 0; i <= eventKeep && !done; i++ {
+		err := readLinesBackward(rotatedPath(l.path, i), func(line []byte) bool {
+			var e Event
+			if json.Unmarshal(line, &e) != nil {
+				return true
+			}
+			if n := e.seq(); n <= seq {
+				found, done = n == seq, true
+				return false
+			}
+			return true
+		})
+		if err != nil {
+			return false, fmt.Errorf("read event log: %w", err)
+		}
+	}
+	return found, nil
+}
@@ -0,0 +1,172 @@
+package core_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"codex-backup-tool/internal/core"
+)
+
+func TestEventLogQueryPagesBackwards(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.log")
+	events := core.NewEventLog(path, 0)
+	defer events.Close()
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	// 足够多的事件使文件跨越多个读取块。
+	padding := strings.Repeat("x", 200)
+	for i := 0; i < 1000; i++ {
+		typ := core.EventBackupCreated
+		if i%2 == 1 {
+			typ = core.EventBackupDeleted
+		}
+		events.Emit(core.Event{Time: base.Add(time.Duration(i) * time.Second), Type: typ, ItemID: fmt.Sprintf("id-%d", i), Summary: padding})
+	}
+
+	page, err := events.Query(core.EventQuery{Type: core.EventBackupDeleted, Limit: 3})
+	if err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	if len(page.Events) != 3 || page.Events[0].ItemID != "id-999" || page.Events[2].ItemID != "id-995" || page.NextBefore == "" {
+		t.Fatalf("unexpected first page: %+v", page)
+	}
+	before, err := time.Parse(time.RFC3339Nano, page.NextBefore)
+	if err != nil {
+		t.Fatalf("parse cursor: %v", err)
+	}
+	page, err = events.Query(core.EventQuery{Type: core.EventBackupDeleted, Before: before, Limit: 2})
+	if err != nil {
+		t.Fatalf("query next page: %v", err)
+	}
+	if len(page.Events) != 2 || page.Events[0].ItemID != "id-993" {
+		t.Fatalf("unexpected second page: %+v", page)
+	}
+
+	page, err = events.Query(core.EventQuery{Since: base.Add(990 * time.Second), Limit: 100})
+	if err != nil {
+		t.Fatalf("query since: %v", err)
+	}
+	if len(page.Events) != 10 || page.NextBefore != "" || page.Events[9].ItemID != "id-990" {
+		t.Fatalf("unexpected since page: %d events, next=%q", len(page.Events), page.NextBefore)
+	}
+}
+
+func TestEventLogRotatesAndSkipsTornLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.log")
+	events := core.NewEventLog(path, 1024)
+	for i := 0; i < 30; i++ {
+		events.Emit(core.Event{Type: core.EventBackupCreated, ItemID: fmt.Sprintf("id-%d", i)})
+		events.Flush()
+	}
+	events.Close()
+	if _, err := os.Stat(path + ".1"); err != nil {
+		t.Fatalf("expected rotated file: %v", err)
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	f.WriteString(`{"ts":"2024-01-01T00:00:00Z","type":"tru`)
+	f.Close()
+
+	reopened := core.NewEventLog(path, 1024)
+	defer reopened.Close()
+	reopened.Emit(core.Event{Type: core.EventBackupRestored, ItemID: "id-last"})
+	page, err := reopened.Query(core.EventQuery{Limit: 20})
+	if err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	if len(page.Events) != 20 || page.Events[0].ItemID != "id-last" || page.Events[1].ItemID != "id-29" {
+		t.Fatalf("unexpected events: %+v", page.Events[:2])
+	}
+}
+
+func TestEventLogDropsAfterClose(t *testing.T) {
+	events := core.NewEventLog(filepath.Join(t.TempDir(), "events.log"), 0)
+	events.Close()
+	events.Emit(core.Event{Type: core.EventBackupCreated})
+	if events.Dropped() != 1 {
+		t.Fatalf("expected dropped event to be counted, got %d", events.Dropped())
+	}
+}
+
+func TestServiceEmitsEvents(t *testing.T) {
+	svc, cleanup := newTestService(t)
+	defer cleanup()
+
+	writeTarget(t, svc, `{"token":"secret-value"}`)
+	res, err := svc.Scan(false, nil)
+	if err != nil || !res.Created {
+		t.Fatalf("scan: %+v %v", res, err)
+	}
+	if err := svc.DeleteBackup(res.Item.ID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	page, err := svc.Events().Query(core.EventQuery{Limit: 10})
+	if err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	if len(page.Events) != 2 || page.Events[0].Type != core.EventBackupDeleted || page.Events[1].Type != core.EventBackupCreated || page.Events[1].ItemID != res.Item.ID {
+		t.Fatalf("unexpected events: %+v", page.Events)
+	}
+	data, err := os.ReadFile(svc.Config().EventsPath)
+	if err != nil {
+		t.Fatalf("read events: %v", err)
+	}
+	if strings.Contains(string(data), "secret-value") {
+		t.Fatalf("event log must not contain target content")
+	}
+}
+
+func TestUnreadEventsPerConsumer(t *testing.T) {
+	svc, cleanup := newTestService(t)
+	defer cleanup()
+
+	writeTarget(t, svc, `{"token":"a"}`)
+	first, err := svc.Scan(false, nil)
+	if err != nil || !first.Created {
+		t.Fatalf("scan: %+v %v", first, err)
+	}
+	if err := svc.DeleteBackup(first.Item.ID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	unread, err := svc.UnreadEvents("webui", 1)
+	if err != nil {
+		t.Fatalf("unread: %v", err)
+	}
+	if unread.Count != 2 || len(unread.Events) != 1 || unread.Events[0].Type != core.EventBackupDeleted || unread.Cursor != "" {
+		t.Fatalf("unexpected unread: %+v", unread)
+	}
+	page, err := svc.Events().Query(core.EventQuery{})
+	if err != nil || len(page.Events) != 2 {
+		t.Fatalf("query: %+v %v", page, err)
+	}
+	newest, oldest := page.Events[0].ID, page.Events[1].ID
+	if newest == "" || oldest == "" || newest <= oldest {
+		t.Fatalf("expected increasing ids, got %q then %q", oldest, newest)
+	}
+
+	if cursor, err := svc.AckEvents("webui", oldest); err != nil || cursor != oldest {
+		t.Fatalf("ack: %q %v", cursor, err)
+	}
+	// 未知与更早的 ID 不移动游标。
+	for _, id := range []string{"123", "not-an-id", ""} {
+		if cursor, err := svc.AckEvents("webui", id); err != nil || cursor != oldest {
+			t.Fatalf("ack %q should be a no-op, got %q %v", id, cursor, err)
+		}
+	}
+	unread, err = svc.UnreadEvents("webui", 10)
+	if err != nil || unread.Count != 1 || unread.Events[0].ID != newest {
+		t.Fatalf("expected one unread event for webui: %+v %v", unread, err)
+	}
+	if unread, err := svc.UnreadEvents("tray", 10); err != nil || unread.Count != 2 {
+		t.Fatalf("expected tray cursor to be independent: %+v %v", unread, err)
+	}
+	if _, err := svc.AckEvents("bad consumer", newest); !errors.Is(err, core.ErrInvalidConsumer) {
+		t.Fatalf("expected invalid consumer error, got %v", err)
+	}
+}
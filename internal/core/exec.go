@@ -5,18 +5,35 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"os"
 	"os/exec"
 	"time"
+
+	"codex-backup-tool/internal/tracing"
 )
 
 // RunCodexLogin 执行 `codex login` 命令，返回 stdout/stderr/退出码。
-func RunCodexLogin(ctx context.Context) (string, string, int, error) {
+// ctx 携带 span 时命令执行记录为其子 span，并通过 TRACEPARENT 环境变量传给子进程。
+func RunCodexLogin(ctx context.Context) (stdout, stderr string, exitCode int, err error) {
 	if ctx == nil {
 		ctx = context.Background()
 	}
+	ctx, span := tracing.Start(ctx, "codex.exec", tracing.String("exec.command", "codex login"))
+	defer func() {
+		span.SetAttributes(tracing.Int("exec.exit_code", int64(exitCode)))
+		span.SetError(err)
+		span.End()
+	}()
+	return runCodexLogin(ctx, span.TraceParent())
+}
+
+func runCodexLogin(ctx context.Context, traceParent string) (string, string, int, error) {
 	ctx, cancel := context.WithTimeout(ctx, 2*time.Minute)
 	defer cancel()
 	cmd := exec.CommandContext(ctx, "codex", "login")
+	if traceParent != "" {
+		cmd.Env = append(os.Environ(), "TRACEPARENT="+traceParent)
+	}
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
@@ -0,0 +1,178 @@
+package core
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"codex-backup-tool/internal/util"
+)
+
+// 导出包中的文件名。
+const (
+	exportIndexName    = "index.json"
+	exportManifestName = "manifest.json"
+	exportBackupsDir   = "backups/"
+)
+
+// ExportManifest 为导出包中的 manifest.json。
+type ExportManifest struct {
+	ToolVersion string    `json:"tool_version"`
+	ExportedAt  time.Time `json:"exported_at"`
+	TargetPath  string    `json:"target_path"`
+	InstanceID  string    `json:"instance_id"`
+	// Items 为导出的 index.json 中的备份数。
+	Items int `json:"items"`
+	// Missing 为索引中存在、但导出时备份文件已缺失或无法读取的备份，这些条目不写入导出的 index.json。
+	Missing []ExportMissing `json:"missing"`
+	// Orphans 为备份目录中未被索引引用、同样打包进 backups/ 的文件。
+	Orphans []string `json:"orphans"`
+}
+
+// ExportMissing 描述导出时跳过的一个备份。
+type ExportMissing struct {
+	ID       string `json:"id"`
+	Filename string `json:"filename"`
+	Error    string `json:"error"`
+}
+
+// toolVersion 返回构建信息中的模块版本，本地构建时为 "(devel)"。
+func toolVersion() string {
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		return info.Main.Version
+	}
+	return "(devel)"
+}
+
+// WriteExport 将索引与备份目录中的全部文件打包为 zip 写入 w，用于迁移到其他机器：
+// backups/ 下为备份文件原样（压缩、加密的保持原样），index.json 为索引，manifest.json 记录工具版本与目标路径。
+// 导出基于一份索引快照，随后逐个读取备份文件；读取时已缺失的备份（包括并发删除的）记入 manifest 的 missing，
+// 并从导出的 index.json 中去掉，因此导出的索引引用的文件总在包内，且无需在打包期间持有索引锁。
+func (s *Service) WriteExport(w io.Writer) (*ExportManifest, error) {
+	idx, err := s.store.Snapshot()
+	if err != nil {
+		return nil, err
+	}
+	manifest := &ExportManifest{
+		ToolVersion: toolVersion(),
+		ExportedAt:  time.Now().UTC(),
+		TargetPath:  idx.TargetPath,
+		InstanceID:  s.instanceID,
+		Missing:     []ExportMissing{},
+		Orphans:     []string{},
+	}
+	zw := zip.NewWriter(w)
+	fail := func(err error) (*ExportManifest, error) {
+		zw.Close()
+		return nil, err
+	}
+
+	referenced := make(map[string]struct{}, len(idx.Items))
+	kept := idx.Items[:0]
+	for _, item := range idx.Items {
+		if err := s.exportFile(zw, item.Filename); err != nil {
+			if isExportWriteError(err) {
+				return fail(err)
+			}
+			manifest.Missing = append(manifest.Missing, ExportMissing{ID: item.ID, Filename: item.Filename, Error: err.Error()})
+			continue
+		}
+		referenced[item.Filename] = struct{}{}
+		kept = append(kept, item)
+	}
+	idx.Items = kept
+	idx.rebuildSortedIDs()
+	manifest.Items = len(idx.Items)
+
+	entries, err := os.ReadDir(s.cfg.BackupsDir)
+	if err != nil && !os.IsNotExist(err) {
+		return fail(fmt.Errorf("读取备份目录: %w", err))
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if _, ok := referenced[name]; ok || !entry.Type().IsRegular() || strings.HasPrefix(name, ".") {
+			continue
+		}
+		if err := s.exportFile(zw, name); err != nil {
+			if isExportWriteError(err) {
+				return fail(err)
+			}
+			// 孤立文件在列目录后被删除，不影响导出的一致性。
+			continue
+		}
+		manifest.Orphans = append(manifest.Orphans, name)
+	}
+
+	payload, err := util.MarshalJSON(idx, util.AtomicWriteJSONOptions{Indent: true, EscapeHTML: true})
+	if err != nil {
+		return fail(err)
+	}
+	if err := writeZipEntry(zw, exportIndexName, manifest.ExportedAt, payload); err != nil {
+		return fail(err)
+	}
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fail(err)
+	}
+	if err := writeZipEntry(zw, exportManifestName, manifest.ExportedAt, append(data, '\n')); err != nil {
+		return fail(err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("close export: %w", err)
+	}
+	return manifest, nil
+}
+
+// exportWriteError 标记写入 zip 失败，与读取备份文件失败区分：前者中止导出，后者只记入 manifest。
+type exportWriteError struct{ err error }
+
+func (e *exportWriteError) Error() string { return e.err.Error() }
+func (e *exportWriteError) Unwrap() error { return e.err }
+
+func isExportWriteError(err error) bool {
+	_, ok := err.(*exportWriteError)
+	return ok
+}
+
+// exportFile 将备份目录中的 filename 写入 zip 的 backups/ 下。
+func (s *Service) exportFile(zw *zip.Writer, filename string) error {
+	path, err := util.SecureJoin(s.cfg.BackupsDir, filename)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	// 先打开源文件再创建 zip 条目，文件缺失时不会在包内留下空条目。
+	dst, err := zw.CreateHeader(&zip.FileHeader{Name: exportBackupsDir + filepath.Base(filename), Method: zip.Deflate, Modified: info.ModTime()})
+	if err != nil {
+		return &exportWriteError{fmt.Errorf("create %s: %w", filename, err)}
+	}
+	if _, err := io.Copy(dst, f); err != nil {
+		return &exportWriteError{fmt.Errorf("write %s: %w", filename, err)}
+	}
+	return nil
+}
+
+func writeZipEntry(zw *zip.Writer, name string, modified time.Time, data []byte) error {
+	f, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modified})
+	if err != nil {
+		return fmt.Errorf("create %s: %w", name, err)
+	}
+	if _, err := f.Write(data); err != nil {
+		return fmt.Errorf("write %s: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package core_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"codex-backup-tool/internal/core"
+)
+
+func TestWriteExportNotesMissingFiles(t *testing.T) {
+	svc, cleanup := newTestService(t)
+	defer cleanup()
+	var items []core.BackupItem
+	for _, c := range "ab" {
+		writeTarget(t, svc, `{"token":"`+string(c)+`"}`)
+		res, err := svc.Scan(true, nil)
+		if err != nil || !res.Created {
+			t.Fatalf("scan %c: %+v (%v)", c, res, err)
+		}
+		items = append(items, *res.Item)
+	}
+	backupsDir := svc.Config().BackupsDir
+	if err := os.Remove(filepath.Join(backupsDir, items[0].Filename)); err != nil {
+		t.Fatalf("remove backup: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(backupsDir, "stray.json"), []byte("{}"), 0o600); err != nil {
+		t.Fatalf("write orphan: %v", err)
+	}
+
+	var buf bytes.Buffer
+	manifest, err := svc.WriteExport(&buf)
+	if err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	files := readBundle(t, buf.Bytes())
+
+	var exported struct {
+		Items []core.BackupItem `json:"items"`
+	}
+	if err := json.Unmarshal([]byte(files["index.json"]), &exported); err != nil {
+		t.Fatalf("decode index.json: %v", err)
+	}
+	if len(exported.Items) != 1 || exported.Items[0].ID != items[1].ID {
+		t.Fatalf("exported index should only reference the intact backup: %+v", exported.Items)
+	}
+	for _, item := range exported.Items {
+		if _, ok := files["backups/"+item.Filename]; !ok {
+			t.Fatalf("index references %s which is not in the archive", item.Filename)
+		}
+	}
+	if _, ok := files["backups/stray.json"]; !ok {
+		t.Fatalf("orphan file not exported")
+	}
+
+	var onDisk core.ExportManifest
+	if err := json.Unmarshal([]byte(files["manifest.json"]), &onDisk); err != nil {
+		t.Fatalf("decode manifest.json: %v", err)
+	}
+	for _, m := range []core.ExportManifest{*manifest, onDisk} {
+		if m.TargetPath != svc.Config().TargetPath || m.ToolVersion == "" || m.Items != 1 ||
+			len(m.Missing) != 1 || m.Missing[0].ID != items[0].ID || len(m.Orphans) != 1 || m.Orphans[0] != "stray.json" {
+			t.Fatalf("unexpected manifest: %+v", m)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package core
+
+import (
+	"errors"
+
+	"codex-backup-tool/internal/util"
+)
+
+const (
+	// ExposureLocal 表示 HTTP 服务只监听回环地址。
+	ExposureLocal = "local"
+	// ExposureRemote 表示 HTTP 服务可能被其他主机访问。
+	ExposureRemote = "remote"
+)
+
+// ErrUnauthenticatedRemote 在监听非回环地址却未配置任何认证方式时返回。
+var ErrUnauthenticatedRemote = errors.New("listening on a non-loopback address without authentication")
+
+// Exposure 根据 BindHost 判断服务是仅本机可达还是对外暴露。
+func (c Config) Exposure() string {
+	if util.IsLoopbackHost(c.BindHost) {
+		return ExposureLocal
+	}
+	return ExposureRemote
+}
+
+// CheckExposure 在服务对外暴露且没有 API 令牌、登录密码或客户端证书校验时返回 ErrUnauthenticatedRemote，
+// 是否据此拒绝启动由调用方根据 RequireAuthForRemote 决定。
+func (s *Service) CheckExposure() error {
+	if s.cfg.Exposure() == ExposureLocal || s.cfg.UIPasswordHash != "" || s.cfg.APIToken != "" || s.cfg.TLSClientCAFile != "" {
+		return nil
+	}
+	configured, err := s.tokens.Configured()
+	if err != nil {
+		return err
+	}
+	if configured {
+		return nil
+	}
+	return ErrUnauthenticatedRemote
+}
@@ -2,14 +2,69 @@ package core
 
 import (
 	"crypto/sha256"
+	"crypto/sha512"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"hash"
 	"io"
 	"os"
 	"runtime"
 	"time"
+
+	"golang.org/x/crypto/blake2b"
+
+	"codex-backup-tool/internal/util"
 )
 
+// 内容哈希算法，用于去重与判断目标内容是否变化。
+const (
+	HashSHA256  = "sha256"
+	HashSHA512  = "sha512"
+	HashBLAKE2b = "blake2b"
+)
+
+// ErrAlgoMismatch 在索引记录的内容哈希算法与配置不符，或条目使用未知算法时返回。
+// 不同算法的哈希无法比较，继续使用会让去重失效。
+var ErrAlgoMismatch = errors.New("content hash algorithm mismatch")
+
+// ValidHashAlgo 判断 algo 是否为支持的内容哈希算法。
+func ValidHashAlgo(algo string) bool {
+	switch algo {
+	case HashSHA256, HashSHA512, HashBLAKE2b:
+		return true
+	default:
+		return false
+	}
+}
+
+// newContentHash 返回 algo 对应的哈希实现，空字符串按 sha256 处理。
+func newContentHash(algo string) (hash.Hash, error) {
+	switch algo {
+	case "", HashSHA256:
+		return sha256.New(), nil
+	case HashSHA512:
+		return sha512.New(), nil
+	case HashBLAKE2b:
+		return blake2b.New512(nil)
+	default:
+		return nil, fmt.Errorf("%w: 未知算法 %q", ErrAlgoMismatch, algo)
+	}
+}
+
+// hashContent 读取 r 的全部内容，返回按 algo 计算的十六进制哈希与内容本身。
+func hashContent(algo string, r io.Reader) (string, []byte, error) {
+	sum, err := newContentHash(algo)
+	if err != nil {
+		return "", nil, err
+	}
+	data, err := io.ReadAll(io.TeeReader(r, sum))
+	if err != nil {
+		return "", nil, fmt.Errorf("read file: %w", err)
+	}
+	return hex.EncodeToString(sum.Sum(nil)), data, nil
+}
+
 // FileStat 捕获文件指纹相关的元数据。
 type FileStat struct {
 	Size    int64     `json:"size"`
@@ -24,9 +79,24 @@ type FingerprintResult struct {
 	Fingerprint string
 }
 
-// ComputeFingerprint 基于文件元信息生成快速指纹。
-func ComputeFingerprint(path string) (*FingerprintResult, error) {
-	info, err := os.Stat(path)
+// FingerprintOptions 控制快速指纹的计算方式。
+type FingerprintOptions struct {
+	// SampleSize > 0 时额外读取文件前 SampleSize 字节参与指纹计算，
+	// 用于识别 inode 被回收复用、元数据完全相同但内容已变化的情况。
+	SampleSize int
+	// MtimeGranularity > 0 时修改时间先截断到该粒度再参与计算，避免 FAT/exFAT 与网络文件系统上
+	// 时间精度较粗、多次 stat 结果不一致导致指纹反复变化。FileStat.ModTime 仍为原始值。
+	MtimeGranularity time.Duration
+}
+
+// ComputeFingerprint 基于文件元信息生成快速指纹，sampleSize 含义同 FingerprintOptions.SampleSize。
+func ComputeFingerprint(path string, sampleSize int) (*FingerprintResult, error) {
+	return ComputeFingerprintWithOptions(path, FingerprintOptions{SampleSize: sampleSize})
+}
+
+// ComputeFingerprintWithOptions 与 ComputeFingerprint 相同，可指定修改时间粒度。
+func ComputeFingerprintWithOptions(path string, opts FingerprintOptions) (*FingerprintResult, error) {
+	info, err := os.Stat(util.LongPath(path))
 	if err != nil {
 		return nil, err
 	}
@@ -34,29 +104,49 @@ func ComputeFingerprint(path string) (*FingerprintResult, error) {
 		Size:    info.Size(),
 		ModTime: info.ModTime(),
 	}
-	inode, dev := extractSysMetadata(info)
+	inode, dev := extractSysMetadata(path, info)
 	stat.Inode = inode
 	stat.Dev = dev
-	seed := fmt.Sprintf("%d|%d|%d|%d", stat.Size, stat.ModTime.UnixNano(), stat.Inode, stat.Dev)
-	sum := sha256.Sum256([]byte(seed))
-	fingerprint := hex.EncodeToString(sum[:8])
+	sum := sha256.New()
+	modTime := stat.ModTime
+	if opts.MtimeGranularity > 0 {
+		modTime = modTime.Truncate(opts.MtimeGranularity)
+	}
+	fmt.Fprintf(sum, "%d|%d|%d|%d", stat.Size, modTime.UnixNano(), stat.Inode, stat.Dev)
+	if opts.SampleSize > 0 && !info.IsDir() {
+		sample, err := readSample(path, opts.SampleSize)
+		if err != nil {
+			return nil, err
+		}
+		sum.Write([]byte("|"))
+		sum.Write(sample)
+	}
+	fingerprint := hex.EncodeToString(sum.Sum(nil)[:8])
 	return &FingerprintResult{Stat: stat, Fingerprint: fingerprint}, nil
 }
 
-// ComputeContentHash 计算文件全量内容 SHA-256，同时返回文件字节。
-func ComputeContentHash(path string) (string, []byte, error) {
-	f, err := os.Open(path)
+func readSample(path string, n int) ([]byte, error) {
+	f, err := os.Open(util.LongPath(path))
 	if err != nil {
-		return "", nil, err
+		return nil, err
 	}
 	defer f.Close()
-	sum := sha256.New()
-	data, err := io.ReadAll(io.TeeReader(f, sum))
+	buf := make([]byte, n)
+	read, err := io.ReadFull(f, buf)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("read sample: %w", err)
+	}
+	return buf[:read], nil
+}
+
+// ComputeContentHash 按 algo（取值见 Hash* 常量，空字符串按 sha256 处理）计算文件全量内容的哈希，同时返回文件字节。
+func ComputeContentHash(path, algo string) (string, []byte, error) {
+	f, err := os.Open(util.LongPath(path))
 	if err != nil {
-		return "", nil, fmt.Errorf("read file: %w", err)
+		return "", nil, err
 	}
-	hash := hex.EncodeToString(sum.Sum(nil))
-	return hash, data, nil
+	defer f.Close()
+	return hashContent(algo, f)
 }
 
 // ShortHash 返回 content hash 截断字符串。
@@ -0,0 +1,117 @@
+package core_test
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/blake2b"
+
+	"codex-backup-tool/internal/core"
+)
+
+func TestFingerprintSampleDetectsSameMetadataContentChange(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "auth.json")
+	mtime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatalf("chtimes: %v", err)
+		}
+	}
+	fingerprint := func(sample int) string {
+		t.Helper()
+		res, err := core.ComputeFingerprint(path, sample)
+		if err != nil {
+			t.Fatalf("fingerprint: %v", err)
+		}
+		return res.Fingerprint
+	}
+
+	// 同一 inode、相同大小与 mtime，模拟 inode 复用后元数据完全一致的场景
+	write(`{"token":"alpha"}`)
+	metaBefore, sampleBefore := fingerprint(0), fingerprint(256)
+	write(`{"token":"omega"}`)
+	metaAfter, sampleAfter := fingerprint(0), fingerprint(256)
+
+	if metaBefore != metaAfter {
+		t.Fatalf("metadata-only fingerprint unexpectedly changed")
+	}
+	if sampleBefore == sampleAfter {
+		t.Fatalf("sampled fingerprint should change when content differs")
+	}
+	if fingerprint(256) != sampleAfter {
+		t.Fatalf("sampled fingerprint should be stable")
+	}
+}
+
+func TestFingerprintMtimeGranularity(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "auth.json")
+	if err := os.WriteFile(path, []byte(`{"token":"alpha"}`), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	// 模拟 FAT 上同一时刻被不同的 stat 报告为相差不到 2 秒的两个修改时间。
+	base := time.Now().Add(-time.Hour).Truncate(2 * time.Second)
+	fingerprint := func(mtime time.Time, granularity time.Duration) string {
+		t.Helper()
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatalf("chtimes: %v", err)
+		}
+		res, err := core.ComputeFingerprintWithOptions(path, core.FingerprintOptions{MtimeGranularity: granularity})
+		if err != nil {
+			t.Fatalf("fingerprint: %v", err)
+		}
+		if !res.Stat.ModTime.Equal(mtime) {
+			t.Fatalf("expected raw mod time %s, got %s", mtime, res.Stat.ModTime)
+		}
+		return res.Fingerprint
+	}
+
+	early, late := base.Add(100*time.Millisecond), base.Add(1900*time.Millisecond)
+	if fingerprint(early, 0) == fingerprint(late, 0) {
+		t.Fatalf("full-precision fingerprint should see the mtime difference")
+	}
+	if fingerprint(early, 2*time.Second) != fingerprint(late, 2*time.Second) {
+		t.Fatalf("truncated fingerprint should not flap within the granularity")
+	}
+	if fingerprint(late, 2*time.Second) == fingerprint(base.Add(2*time.Second), 2*time.Second) {
+		t.Fatalf("truncated fingerprint should change across granularity boundaries")
+	}
+}
+
+func TestComputeContentHashAlgorithms(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "auth.json")
+	content := []byte(`{"token":"abc"}`)
+	if err := os.WriteFile(path, content, 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	sha256Sum := sha256.Sum256(content)
+	sha512Sum := sha512.Sum512(content)
+	blakeSum := blake2b.Sum512(content)
+	cases := []struct {
+		algo string
+		want string
+	}{
+		{"", hex.EncodeToString(sha256Sum[:])},
+		{core.HashSHA256, hex.EncodeToString(sha256Sum[:])},
+		{core.HashSHA512, hex.EncodeToString(sha512Sum[:])},
+		{core.HashBLAKE2b, hex.EncodeToString(blakeSum[:])},
+	}
+	for _, tc := range cases {
+		got, data, err := core.ComputeContentHash(path, tc.algo)
+		if err != nil || got != tc.want || string(data) != string(content) {
+			t.Fatalf("%q: got %s (%v), want %s", tc.algo, got, err, tc.want)
+		}
+	}
+	if _, _, err := core.ComputeContentHash(path, "md5"); !errors.Is(err, core.ErrAlgoMismatch) {
+		t.Fatalf("expected ErrAlgoMismatch for an unknown algorithm, got %v", err)
+	}
+}
@@ -7,7 +7,7 @@ import (
 	"syscall"
 )
 
-func extractSysMetadata(info os.FileInfo) (uint64, uint64) {
+func extractSysMetadata(_ string, info os.FileInfo) (uint64, uint64) {
 	sys, ok := info.Sys().(*syscall.Stat_t)
 	if !ok || sys == nil {
 		return 0, 0
@@ -4,15 +4,27 @@ package core
 
 import (
 	"os"
-	"syscall"
+
+	"golang.org/x/sys/windows"
+
+	"codex-backup-tool/internal/util"
 )
 
-func extractSysMetadata(info os.FileInfo) (uint64, uint64) {
-	sys, ok := info.Sys().(*syscall.Win32FileAttributeData)
-	if !ok || sys == nil {
+func extractSysMetadata(path string, _ os.FileInfo) (uint64, uint64) {
+	p, err := windows.UTF16PtrFromString(util.LongPath(path))
+	if err != nil {
+		return 0, 0
+	}
+	h, err := windows.CreateFile(p, 0, windows.FILE_SHARE_READ|windows.FILE_SHARE_WRITE|windows.FILE_SHARE_DELETE, nil, windows.OPEN_EXISTING, windows.FILE_FLAG_BACKUP_SEMANTICS, 0)
+	if err != nil {
+		return 0, 0
+	}
+	defer windows.CloseHandle(h)
+	var data windows.ByHandleFileInformation
+	if err := windows.GetFileInformationByHandle(h, &data); err != nil {
 		return 0, 0
 	}
-	inode := uint64(sys.FileIndexHigh)<<32 | uint64(sys.FileIndexLow)
-	dev := uint64(sys.VolumeSerialNumber)
+	inode := uint64(data.FileIndexHigh)<<32 | uint64(data.FileIndexLow)
+	dev := uint64(data.VolumeSerialNumber)
 	return inode, dev
 }
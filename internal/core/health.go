@@ -0,0 +1,76 @@
+package core
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// 健康检查的组件名。
+const (
+	HealthStore  = "store"
+	HealthTarget = "target"
+)
+
+// 健康检查中组件的状态。HealthMissing 只用于目标文件，表示尚未登录等正常情况，不算降级。
+const (
+	HealthOK      = "ok"
+	HealthMissing = "missing"
+	HealthError   = "error"
+)
+
+// healthProbeInterval 为健康检查复用索引目录写入探测结果的时长。GET /api/health 无需认证，
+// 缓存结果避免每个请求都在数据目录中写入、删除探针文件。
+const healthProbeInterval = 10 * time.Second
+
+// healthProbe 缓存最近一次索引目录写入探测的结果。
+type healthProbe struct {
+	mu  sync.Mutex
+	at  time.Time
+	err error
+}
+
+// HealthCheck 检查各组件的当前状态：store 读取并解析索引、确认索引所在目录可写（写入探测的结果缓存 healthProbeInterval）；
+// target 检查目标文件是否存在且为普通文件。返回组件名到状态的映射，状态见 Health* 常量。
+func (s *Service) HealthCheck() map[string]string {
+	components := map[string]string{HealthStore: HealthOK, HealthTarget: HealthOK}
+	if _, err := s.store.Snapshot(); err != nil {
+		s.logger.Error("健康检查: 读取索引失败", "error", err)
+		components[HealthStore] = HealthError
+	} else if err := s.probeIndexDir(); err != nil {
+		s.logger.Error("健康检查: 索引目录不可写", "path", filepath.Dir(s.cfg.IndexPath), "error", err)
+		components[HealthStore] = HealthError
+	}
+	info, err := os.Stat(s.cfg.TargetPath)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		components[HealthTarget] = HealthMissing
+	case err != nil || !info.Mode().IsRegular():
+		components[HealthTarget] = HealthError
+	}
+	return components
+}
+
+// probeIndexDir 探测索引所在目录是否可写，距上次探测不足 healthProbeInterval 时直接返回上次的结果。
+func (s *Service) probeIndexDir() error {
+	s.healthProbe.mu.Lock()
+	defer s.healthProbe.mu.Unlock()
+	if !s.healthProbe.at.IsZero() && time.Since(s.healthProbe.at) < healthProbeInterval {
+		return s.healthProbe.err
+	}
+	_, err := probeDir(filepath.Dir(s.cfg.IndexPath))
+	s.healthProbe.at, s.healthProbe.err = time.Now(), err
+	return err
+}
+
+// Healthy 判断 HealthCheck 的结果中是否没有降级的组件，目标文件缺失不算降级。
+func Healthy(components map[string]string) bool {
+	for _, status := range components {
+		if status != HealthOK && status != HealthMissing {
+			return false
+		}
+	}
+	return true
+}
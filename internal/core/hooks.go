@@ -0,0 +1,161 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultHookTimeout     = 10 * time.Second
+	defaultHookMinInterval = time.Minute
+)
+
+// HookConfig 描述 hooks 配置中的一项：事件发生时执行 Command。
+// Command 为参数列表而非 shell 命令行，其中的 {event}、{item_id}、{remark}、{reason}、{instance_id}
+// 占位符替换为事件的元数据，命令不会收到任何文件内容。
+type HookConfig struct {
+	Event   string   `json:"event"`
+	Command []string `json:"command"`
+	// TimeoutSeconds 为命令的最长执行时间，0 表示默认 10 秒。
+	TimeoutSeconds int `json:"timeout_seconds"`
+	// MinIntervalSeconds 内展开后完全相同的命令只执行一次，0 表示默认 60 秒。
+	MinIntervalSeconds int `json:"min_interval_seconds"`
+}
+
+func (h HookConfig) timeout() time.Duration {
+	if h.TimeoutSeconds > 0 {
+		return time.Duration(h.TimeoutSeconds) * time.Second
+	}
+	return defaultHookTimeout
+}
+
+func (h HookConfig) minInterval() time.Duration {
+	if h.MinIntervalSeconds > 0 {
+		return time.Duration(h.MinIntervalSeconds) * time.Second
+	}
+	return defaultHookMinInterval
+}
+
+// validateHooks 检查 hooks 配置中的事件类型与命令。
+func validateHooks(hooks []HookConfig) error {
+	for i, h := range hooks {
+		if !slices.Contains(KnownEvents, h.Event) {
+			return fmt.Errorf("hooks[%d]: 未知事件 %q，可选值: %s", i, h.Event, strings.Join(KnownEvents, ", "))
+		}
+		if len(h.Command) == 0 || strings.TrimSpace(h.Command[0]) == "" {
+			return fmt.Errorf("hooks[%d]: command 不能为空", i)
+		}
+		if h.TimeoutSeconds < 0 || h.MinIntervalSeconds < 0 {
+			return fmt.Errorf("hooks[%d]: timeout_seconds 与 min_interval_seconds 不能为负数", i)
+		}
+	}
+	return nil
+}
+
+// hookRunner 在后台执行与事件匹配的 hook 命令，执行结果以 EventHookFinished 事件记录。
+type hookRunner struct {
+	hooks []HookConfig
+	emit  func(Event)
+	logf  func(format string, args ...any)
+
+	mu      sync.Mutex
+	lastRun map[string]time.Time
+	wg      sync.WaitGroup
+}
+
+func newHookRunner(hooks []HookConfig, emit func(Event), logf func(format string, args ...any)) *hookRunner {
+	return &hookRunner{hooks: hooks, emit: emit, logf: logf, lastRun: make(map[string]time.Time)}
+}
+
+// fire 为匹配 e 的每个 hook 启动命令，不等待其完成。
+func (h *hookRunner) fire(e Event) {
+	for _, hook := range h.hooks {
+		if hook.Event != e.Type {
+			continue
+		}
+		args := expandHookCommand(hook.Command, e)
+		if !h.allow(args, hook.minInterval()) {
+			continue
+		}
+		h.wg.Add(1)
+		go func() {
+			defer h.wg.Done()
+			h.run(hook, args, e)
+		}()
+	}
+}
+
+// allow 对展开后相同的命令限流。
+func (h *hookRunner) allow(args []string, interval time.Duration) bool {
+	key := strings.Join(args, "\x00")
+	now := time.Now()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if last, ok := h.lastRun[key]; ok && now.Sub(last) < interval {
+		return false
+	}
+	h.lastRun[key] = now
+	return true
+}
+
+func (h *hookRunner) run(hook HookConfig, args []string, e Event) {
+	ctx, cancel := context.WithTimeout(context.Background(), hook.timeout())
+	defer cancel()
+	err := exec.CommandContext(ctx, args[0], args[1:]...).Run()
+	exitCode := 0
+	var exitErr *exec.ExitError
+	switch {
+	case errors.As(err, &exitErr):
+		exitCode = exitErr.ExitCode()
+	case err != nil:
+		exitCode = -1
+	}
+	result := Event{Type: EventHookFinished, ItemID: e.ItemID, Summary: fmt.Sprintf("event=%s command=%s exit=%d", e.Type, args[0], exitCode)}
+	if ctx.Err() != nil {
+		result.Reason = fmt.Sprintf("超过 %s 未完成", hook.timeout())
+	} else if err != nil {
+		result.Reason = err.Error()
+	}
+	if err != nil && h.logf != nil {
+		h.logf("hook 执行失败 event=%s command=%s: %v", e.Type, args[0], err)
+	}
+	h.emit(result)
+}
+
+// wait 等待已启动的命令全部结束。
+func (h *hookRunner) wait() {
+	h.wg.Wait()
+}
+
+func expandHookCommand(command []string, e Event) []string {
+	r := strings.NewReplacer("{event}", e.Type, "{item_id}", e.ItemID, "{remark}", e.Remark, "{reason}", e.Reason, "{instance_id}", e.InstanceID)
+	args := make([]string, len(command))
+	for i, arg := range command {
+		args[i] = r.Replace(arg)
+	}
+	return args
+}
+
+// emit 持久化事件并触发匹配的 hook。
+func (s *Service) emit(e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	s.emitEvent(e)
+	if e.Type != EventHookFinished {
+		s.hooks.fire(e)
+	}
+}
+
+// emitEvent 为事件标注实例 ID 后写入事件日志并推送给实时订阅者，不触发 hook。
+func (s *Service) emitEvent(e Event) {
+	e.InstanceID = s.instanceID
+	s.events.Emit(e)
+	s.publish(e)
+}
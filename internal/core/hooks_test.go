@@ -0,0 +1,84 @@
+package core_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"codex-backup-tool/internal/core"
+)
+
+// TestHookHelperProcess 作为 hook 命令被调用时，把 "--" 之后的参数追加到第一个参数指定的文件并以 3 退出。
+func TestHookHelperProcess(t *testing.T) {
+	args := os.Args
+	for i, arg := range args {
+		if arg == "--" {
+			args = args[i+1:]
+			f, err := os.OpenFile(args[0], os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+			if err != nil {
+				os.Exit(1)
+			}
+			f.WriteString(strings.Join(args[1:], " ") + "\n")
+			f.Close()
+			os.Exit(3)
+		}
+	}
+}
+
+func TestHooksRunOnEventsWithRateLimit(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "hook.out")
+	helper := []string{os.Args[0], "-test.run=^TestHookHelperProcess$", "--", out}
+	svc, cleanup := newTestService(t, func(cfg *core.Config) {
+		cfg.Hooks = []core.HookConfig{
+			{Event: core.EventBackupCreated, Command: append(append([]string{}, helper...), "{event}", "{remark}")},
+			// 展开后相同的命令在限流间隔内只执行一次。
+			{Event: core.EventBackupCreated, Command: append(append([]string{}, helper...), "once")},
+		}
+	})
+
+	writeTarget(t, svc, `{"token":"secret-a"}`)
+	remark := "first"
+	if _, err := svc.Scan(false, &remark); err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	writeTarget(t, svc, `{"token":"secret-b"}`)
+	remark = "second"
+	if _, err := svc.Scan(false, &remark); err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	events := svc.Events()
+	cleanup()
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("read hook output: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 3 || strings.Count(string(data), "once") != 1 || !strings.Contains(string(data), "backup.created first") || !strings.Contains(string(data), "backup.created second") {
+		t.Fatalf("unexpected hook invocations: %q", data)
+	}
+	if strings.Contains(string(data), "secret") {
+		t.Fatalf("hook must not receive target content")
+	}
+	page, err := events.Query(core.EventQuery{Type: core.EventHookFinished, Limit: 10})
+	if err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	if len(page.Events) != 3 || !strings.Contains(page.Events[0].Summary, "exit=3") {
+		t.Fatalf("expected hook results in event log, got %+v", page.Events)
+	}
+}
+
+func TestLoadConfigHooksValidation(t *testing.T) {
+	if _, _, err := core.LoadConfig(writeConfig(t, `{"data_dir":"./data","hooks":[{"event":"nope","command":["true"]}]}`)); err == nil {
+		t.Fatalf("expected error for unknown event")
+	}
+	if _, _, err := core.LoadConfig(writeConfig(t, `{"data_dir":"./data","hooks":[{"event":"backup.restored","command":[]}]}`)); err == nil {
+		t.Fatalf("expected error for empty command")
+	}
+	cfg, _, err := core.LoadConfig(writeConfig(t, `{"data_dir":"./data","hooks":[{"event":"backup.restored","command":["notify-send","{remark}"]}]}`))
+	if err != nil || len(cfg.Hooks) != 1 {
+		t.Fatalf("load config: %v %+v", err, cfg.Hooks)
+	}
+}
@@ -0,0 +1,319 @@
+package core
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+
+	"codex-backup-tool/internal/util"
+)
+
+// ErrInvalidImport 在导入的归档不是 WriteExport 生成的 zip（无法解析、缺少 index.json 或解压后过大）时返回。
+var ErrInvalidImport = errors.New("invalid import archive")
+
+// 导入归档解压后的大小上限，防止压缩炸弹耗尽内存：maxImportEntrySize 限制单个文件（gzip 备份另按解压后的内容计），
+// maxImportTotalSize 限制一次导入读入内存的全部文件之和。
+const (
+	maxImportEntrySize = 64 << 20
+	maxImportTotalSize = 256 << 20
+)
+
+// importRemarkSuffix 为导入的备注与已有备注冲突时追加的后缀，再次冲突时依次为 -imported-2、-imported-3…
+const importRemarkSuffix = "-imported"
+
+// 导入时跳过条目的原因。
+const (
+	ImportSkipDuplicate   = "duplicate_content"
+	ImportSkipMissingFile = "missing_file"
+	ImportSkipInvalid     = "invalid_entry"
+	// ImportSkipHashMismatch 表示备份内容（解密、解压后）的哈希与索引记录的 content_hash 不符。
+	ImportSkipHashMismatch = "hash_mismatch"
+	// ImportSkipUndecryptable 表示加密的备份无法用当前的 encryption_passphrase 解密，内容无法校验。
+	ImportSkipUndecryptable = "undecryptable"
+)
+
+// ImportResult 汇总一次导入：Imported 为新增的备份（ID、备注、文件名均为导入后的值），
+// Renamed 列出其中 ID、备注或文件名被改写的条目。
+type ImportResult struct {
+	Imported []BackupItem    `json:"imported"`
+	Skipped  []ImportSkipped `json:"skipped"`
+	Renamed  []ImportRenamed `json:"renamed"`
+}
+
+// ImportSkipped 描述归档中未导入的一个条目，Reason 取值见 ImportSkip* 常量。
+type ImportSkipped struct {
+	ID       string `json:"id"`
+	Remark   string `json:"remark"`
+	Filename string `json:"filename"`
+	Reason   string `json:"reason"`
+	// ExistingID 为内容相同的已有备份，仅在 Reason 为 duplicate_content 时出现。
+	ExistingID string `json:"existing_id,omitempty"`
+}
+
+// ImportRenamed 记录导入时被改写的字段，未改写的字段为空。
+type ImportRenamed struct {
+	ID               string `json:"id"`
+	OriginalID       string `json:"original_id,omitempty"`
+	Remark           string `json:"remark,omitempty"`
+	OriginalRemark   string `json:"original_remark,omitempty"`
+	Filename         string `json:"filename,omitempty"`
+	OriginalFilename string `json:"original_filename,omitempty"`
+}
+
+// Import 将 WriteExport 生成的归档合并到当前索引：内容哈希已存在的条目跳过，ID 冲突时重新生成，
+// 备注与用户输入一样先规范化并截断到长度上限，为空时生成默认备注，冲突时追加 -imported 后缀；
+// 备份文件经 EnsureUniqueFilename 复制到备份目录，加密或压缩的文件保持原样。
+// 每个条目在合并前都会按记录的算法重新计算内容哈希，不符或无法解密的条目跳过，避免伪造的哈希干扰去重。
+// 合并在一次索引写入中完成；写入失败时删除已复制的文件，索引保持不变。
+func (s *Service) Import(r io.ReaderAt, size int64) (*ImportResult, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImport, err)
+	}
+	budget := &importBudget{remaining: maxImportTotalSize}
+	var source *IndexData
+	files := make(map[string]*zip.File)
+	for _, f := range zr.File {
+		switch {
+		case f.Name == exportIndexName:
+			data, err := budget.read(f)
+			if err != nil {
+				return nil, err
+			}
+			source = &IndexData{}
+			if err := json.Unmarshal(data, source); err != nil {
+				return nil, fmt.Errorf("%w: 解析 %s: %v", ErrInvalidImport, exportIndexName, err)
+			}
+		case strings.HasPrefix(f.Name, exportBackupsDir):
+			files[strings.TrimPrefix(f.Name, exportBackupsDir)] = f
+		}
+	}
+	if source == nil {
+		return nil, fmt.Errorf("%w: 缺少 %s", ErrInvalidImport, exportIndexName)
+	}
+
+	// 先在内存中读出并校验全部备份文件，持有索引锁期间只做去重与写入。
+	res := &ImportResult{Imported: []BackupItem{}, Skipped: []ImportSkipped{}, Renamed: []ImportRenamed{}}
+	var incoming []BackupItem
+	contents := make(map[string][]byte)
+	for _, item := range source.Items {
+		skip := ImportSkipped{ID: item.ID, Remark: item.Remark, Filename: item.Filename}
+		f, ok := files[item.Filename]
+		item.HashAlgo = effectiveHashAlgo(item.HashAlgo, source.HashAlgo)
+		if !isPlainFilename(item.Filename) || item.ContentHash == "" || !ValidHashAlgo(item.HashAlgo) {
+			skip.Reason = ImportSkipInvalid
+		} else if !ok {
+			skip.Reason = ImportSkipMissingFile
+		}
+		if skip.Reason != "" {
+			res.Skipped = append(res.Skipped, skip)
+			continue
+		}
+		data, seen := contents[item.Filename]
+		if !seen {
+			if data, err = budget.read(f); err != nil {
+				return nil, err
+			}
+			contents[item.Filename] = data
+		}
+		if skip.Reason = s.verifyImportItem(&item, data); skip.Reason != "" {
+			res.Skipped = append(res.Skipped, skip)
+			continue
+		}
+		incoming = append(incoming, item)
+	}
+
+	var written []string
+	_, err = s.store.update(func(idx *IndexData) error {
+		written = written[:0]
+		imported, skipped, renamed := []BackupItem{}, []ImportSkipped{}, []ImportRenamed{}
+		ids := make(map[string]struct{}, len(idx.Items))
+		for i := range idx.Items {
+			ids[idx.Items[i].ID] = struct{}{}
+		}
+		for _, item := range incoming {
+			if existing := findImportDuplicate(idx, &item); existing != "" {
+				skipped = append(skipped, ImportSkipped{ID: item.ID, Remark: item.Remark, Filename: item.Filename,
+					Reason: ImportSkipDuplicate, ExistingID: existing})
+				continue
+			}
+			rename := ImportRenamed{}
+			if _, taken := ids[item.ID]; taken || item.ID == "" {
+				rename.OriginalID, item.ID = item.ID, uuid.New().String()
+			}
+			ids[item.ID] = struct{}{}
+			original := item.Remark
+			if remark := s.importedRemark(item.Remark); remark == "" {
+				item.Remark = uniqueRemark(idx.Remarks, autoRemark(item.IsAuto, item.CreatedAt, s.cfg.Timestamps), s.cfg.RemarkCaseInsensitive)
+			} else {
+				item.Remark = importRemark(idx.Remarks, remark, s.cfg.RemarkCaseInsensitive)
+			}
+			if item.Remark != original {
+				rename.OriginalRemark, rename.Remark = original, item.Remark
+			}
+			idx.Remarks[item.Remark] = item.ID
+			filename, err := EnsureUniqueFilename(s.cfg.BackupsDir, item.Filename)
+			if err != nil {
+				return err
+			}
+			if err := util.AtomicWriteFile(filepath.Join(s.cfg.BackupsDir, filename), contents[item.Filename], 0o600); err != nil {
+				return err
+			}
+			written = append(written, filename)
+			if filename != item.Filename {
+				rename.OriginalFilename, rename.Filename, item.Filename = item.Filename, filename, filename
+			}
+			if rename != (ImportRenamed{}) {
+				rename.ID = item.ID
+				renamed = append(renamed, rename)
+			}
+			item.CreatedAt = item.CreatedAt.UTC()
+			if item.HashAlgo == idx.HashAlgo {
+				item.HashAlgo = ""
+			}
+			idx.Items = append(idx.Items, item)
+			imported = append(imported, item)
+		}
+		if len(imported) == 0 {
+			res.Skipped = append(res.Skipped, skipped...)
+			return errNoChange
+		}
+		idx.rebuildSortedIDs()
+		res.Imported, res.Renamed = imported, renamed
+		res.Skipped = append(res.Skipped, skipped...)
+		return nil
+	})
+	if err != nil && !errors.Is(err, errNoChange) {
+		for _, name := range written {
+			_ = os.Remove(filepath.Join(s.cfg.BackupsDir, name))
+		}
+		return nil, err
+	}
+	for _, item := range res.Imported {
+		s.logger.Info("导入备份", "id", item.ID, "remark", item.Remark, "filename", item.Filename)
+	}
+	return res, nil
+}
+
+// findImportDuplicate 返回与 item 内容哈希相同的已有备份 ID（item.HashAlgo 须已填为实际算法）；两者使用的哈希算法不同时无法比较，视为不重复。
+func findImportDuplicate(idx *IndexData, item *BackupItem) string {
+	for i := range idx.Items {
+		existing := &idx.Items[i]
+		if existing.ContentHash == item.ContentHash && effectiveHashAlgo(existing.HashAlgo, idx.HashAlgo) == item.HashAlgo {
+			return existing.ID
+		}
+	}
+	return ""
+}
+
+// effectiveHashAlgo 返回条目实际使用的哈希算法：条目未记录时沿用索引的算法，均为空时为 sha256。
+func effectiveHashAlgo(itemAlgo, indexAlgo string) string {
+	switch {
+	case itemAlgo != "":
+		return itemAlgo
+	case indexAlgo != "":
+		return indexAlgo
+	default:
+		return HashSHA256
+	}
+}
+
+// importedRemark 按 NormalizeRemark 规范化归档中的备注，超过备注长度上限时截断到上限。
+func (s *Service) importedRemark(remark string) string {
+	remark = NormalizeRemark(remark)
+	if limit := s.remarkMaxLength(); utf8.RuneCountInString(remark) > limit {
+		remark = strings.TrimSpace(string([]rune(remark)[:limit]))
+	}
+	return remark
+}
+
+// importRemark 在 remark 已被占用时依次尝试 remark-imported、remark-imported-2…
+func importRemark(remarks map[string]string, remark string, fold bool) string {
+	if _, ok := findRemark(remarks, remark, fold); !ok {
+		return remark
+	}
+	candidate := remark + importRemarkSuffix
+	for n := 2; ; n++ {
+		if _, ok := findRemark(remarks, candidate, fold); !ok {
+			return candidate
+		}
+		candidate = fmt.Sprintf("%s%s-%d", remark, importRemarkSuffix, n)
+	}
+}
+
+// isPlainFilename 判断 name 是否为可直接放入备份目录的文件名：不含路径分隔符，也不以点开头。
+func isPlainFilename(name string) bool {
+	return name != "" && !strings.HasPrefix(name, ".") && !strings.ContainsAny(name, `/\:`) && !strings.ContainsRune(name, 0)
+}
+
+// verifyImportItem 按 item.HashAlgo 重新计算备份内容的哈希并与 item.ContentHash 比较，通过时返回空字符串，
+// 否则返回跳过原因。加密的条目先用当前口令解密，gzip 压缩的再解压，与扫描时对目标文件原始内容计算的哈希一致。
+func (s *Service) verifyImportItem(item *BackupItem, data []byte) string {
+	if item.Encrypted {
+		plain, err := s.decryptBackupData(item, data)
+		if err != nil {
+			return ImportSkipUndecryptable
+		}
+		data = plain
+	}
+	var r io.Reader = bytes.NewReader(data)
+	if IsCompressedBackup(item.Filename) {
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return ImportSkipInvalid
+		}
+		defer zr.Close()
+		r = zr
+	}
+	sum, err := newContentHash(item.HashAlgo)
+	if err != nil {
+		return ImportSkipInvalid
+	}
+	// 解压后的内容只流经哈希，不保留在内存中，仍按 maxImportEntrySize 截断以免 gzip 炸弹长时间占用 CPU。
+	n, err := io.Copy(sum, io.LimitReader(r, maxImportEntrySize+1))
+	if err != nil || n > maxImportEntrySize {
+		return ImportSkipInvalid
+	}
+	if hex.EncodeToString(sum.Sum(nil)) != strings.ToLower(item.ContentHash) {
+		return ImportSkipHashMismatch
+	}
+	return ""
+}
+
+// importBudget 记录一次导入还可读入内存的字节数。
+type importBudget struct {
+	remaining int64
+}
+
+// read 读取归档中的一个文件，超过 maxImportEntrySize 或剩余预算时返回 ErrInvalidImport。
+func (b *importBudget) read(f *zip.File) ([]byte, error) {
+	limit := min(int64(maxImportEntrySize), b.remaining)
+	rc, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("%w: 打开 %s: %v", ErrInvalidImport, f.Name, err)
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(io.LimitReader(rc, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("%w: 读取 %s: %v", ErrInvalidImport, f.Name, err)
+	}
+	if int64(len(data)) > limit {
+		if limit < maxImportEntrySize {
+			return nil, fmt.Errorf("%w: 归档解压后超过 %d 字节", ErrInvalidImport, maxImportTotalSize)
+		}
+		return nil, fmt.Errorf("%w: %s 超过 %d 字节", ErrInvalidImport, f.Name, maxImportEntrySize)
+	}
+	b.remaining -= int64(len(data))
+	return data, nil
+}
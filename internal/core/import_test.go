@@ -0,0 +1,310 @@
+package core_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"codex-backup-tool/internal/core"
+	"codex-backup-tool/internal/util"
+)
+
+func scanWithRemark(t *testing.T, svc *core.Service, content, remark string) core.BackupItem {
+	t.Helper()
+	writeTarget(t, svc, content)
+	res, err := svc.Scan(false, &remark)
+	if err != nil || !res.Created {
+		t.Fatalf("scan %q: %+v (%v)", remark, res, err)
+	}
+	return *res.Item
+}
+
+// rewriteIndex 返回将归档中 index.json 的各条目交给 mutate 修改后的新归档。
+func rewriteIndex(t *testing.T, archive []byte, mutate func(items []map[string]any)) []byte {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		t.Fatalf("open archive: %v", err)
+	}
+	var out bytes.Buffer
+	zw := zip.NewWriter(&out)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("open %s: %v", f.Name, err)
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatalf("read %s: %v", f.Name, err)
+		}
+		if f.Name == "index.json" {
+			var idx map[string]any
+			if err := json.Unmarshal(data, &idx); err != nil {
+				t.Fatalf("decode index: %v", err)
+			}
+			var items []map[string]any
+			raw, _ := json.Marshal(idx["items"])
+			_ = json.Unmarshal(raw, &items)
+			mutate(items)
+			idx["items"] = items
+			if data, err = json.Marshal(idx); err != nil {
+				t.Fatalf("encode index: %v", err)
+			}
+		}
+		w, err := zw.Create(f.Name)
+		if err != nil {
+			t.Fatalf("create %s: %v", f.Name, err)
+		}
+		if _, err := w.Write(data); err != nil {
+			t.Fatalf("write %s: %v", f.Name, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("close archive: %v", err)
+	}
+	return out.Bytes()
+}
+
+func TestImportMergesExportedArchive(t *testing.T) {
+	src, cleanupSrc := newTestService(t)
+	defer cleanupSrc()
+	alpha := scanWithRemark(t, src, `{"token":"a"}`, "alpha")
+	beta := scanWithRemark(t, src, `{"token":"b"}`, "beta")
+	gamma := scanWithRemark(t, src, `{"token":"g"}`, "gamma")
+	var buf bytes.Buffer
+	if _, err := src.WriteExport(&buf); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+
+	dst, cleanupDst := newTestService(t)
+	defer cleanupDst()
+	dup := scanWithRemark(t, dst, `{"token":"a"}`, "local-a")
+	scanWithRemark(t, dst, `{"token":"c"}`, "beta")
+	scanWithRemark(t, dst, `{"token":"d"}`, "beta-imported")
+	// gamma 的 ID 与本地备份冲突，文件名也已被占用。
+	archive := rewriteIndex(t, buf.Bytes(), func(items []map[string]any) {
+		for _, item := range items {
+			if item["id"] == gamma.ID {
+				item["id"] = dup.ID
+			}
+		}
+	})
+	if err := os.WriteFile(filepath.Join(dst.Config().BackupsDir, gamma.Filename), []byte("{}"), 0o600); err != nil {
+		t.Fatalf("occupy filename: %v", err)
+	}
+
+	res, err := dst.Import(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if len(res.Skipped) != 1 || res.Skipped[0].ID != alpha.ID || res.Skipped[0].Reason != core.ImportSkipDuplicate || res.Skipped[0].ExistingID != dup.ID {
+		t.Fatalf("unexpected skipped: %+v", res.Skipped)
+	}
+	if len(res.Imported) != 2 {
+		t.Fatalf("expected 2 imported items, got %+v", res.Imported)
+	}
+	renamed := make(map[string]core.ImportRenamed)
+	for _, r := range res.Renamed {
+		renamed[r.OriginalRemark+r.OriginalID] = r
+	}
+	if r, ok := renamed["beta"]; !ok || r.Remark != "beta-imported-2" || r.ID != beta.ID {
+		t.Fatalf("beta should be renamed to beta-imported-2: %+v", res.Renamed)
+	}
+	r, ok := renamed[dup.ID]
+	if !ok || r.ID == dup.ID || r.ID == "" || r.OriginalFilename != gamma.Filename || r.Filename == gamma.Filename {
+		t.Fatalf("gamma should get a new id and filename: %+v", res.Renamed)
+	}
+
+	items, err := dst.ListBackups()
+	if err != nil || len(items) != 5 {
+		t.Fatalf("expected 5 backups after import, got %d (%v)", len(items), err)
+	}
+	rc, item, err := dst.OpenBackup(r.ID)
+	if err != nil {
+		t.Fatalf("open imported backup: %v", err)
+	}
+	content, _ := io.ReadAll(rc)
+	rc.Close()
+	if string(content) != `{"token":"g"}` || item.Remark != "gamma" {
+		t.Fatalf("unexpected imported backup %+v: %s", item, content)
+	}
+
+	again, err := dst.Import(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil || len(again.Imported) != 0 || len(again.Skipped) != 3 {
+		t.Fatalf("re-import should skip everything: %+v (%v)", again, err)
+	}
+}
+
+func TestImportRejectsInvalidArchive(t *testing.T) {
+	svc, cleanup := newTestService(t)
+	defer cleanup()
+	data := []byte("not a zip")
+	if _, err := svc.Import(bytes.NewReader(data), int64(len(data))); !errors.Is(err, core.ErrInvalidImport) {
+		t.Fatalf("expected ErrInvalidImport, got %v", err)
+	}
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	zw.Create("manifest.json")
+	zw.Close()
+	if _, err := svc.Import(bytes.NewReader(buf.Bytes()), int64(buf.Len())); !errors.Is(err, core.ErrInvalidImport) {
+		t.Fatalf("expected ErrInvalidImport for archive without index.json, got %v", err)
+	}
+}
+
+func TestImportRejectsOversizedArchive(t *testing.T) {
+	svc, cleanup := newTestService(t)
+	defer cleanup()
+	// 每个文件都低于单文件上限，合计超过一次导入的总上限。
+	const entries, entrySize = 5, 60 << 20
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	var items []map[string]any
+	chunk := make([]byte, 1<<20)
+	for i := range entries {
+		name := fmt.Sprintf("big-%d.json", i)
+		items = append(items, map[string]any{"id": name, "filename": name, "content_hash": "00"})
+		w, err := zw.Create("backups/" + name)
+		if err != nil {
+			t.Fatalf("create %s: %v", name, err)
+		}
+		for range entrySize / len(chunk) {
+			if _, err := w.Write(chunk); err != nil {
+				t.Fatalf("write %s: %v", name, err)
+			}
+		}
+	}
+	index, _ := json.Marshal(map[string]any{"items": items})
+	w, _ := zw.Create("index.json")
+	w.Write(index)
+	if err := zw.Close(); err != nil {
+		t.Fatalf("close archive: %v", err)
+	}
+
+	_, err := svc.Import(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if !errors.Is(err, core.ErrInvalidImport) {
+		t.Fatalf("expected ErrInvalidImport for oversized archive, got %v", err)
+	}
+	if items, _ := svc.ListBackups(); len(items) != 0 {
+		t.Fatalf("expected nothing imported, got %d backups", len(items))
+	}
+	if files, _ := os.ReadDir(svc.Config().BackupsDir); len(files) != 0 {
+		t.Fatalf("expected no backup files written, got %d", len(files))
+	}
+}
+
+func TestImportVerifiesContentHash(t *testing.T) {
+	src, cleanupSrc := newTestService(t)
+	defer cleanupSrc()
+	plain := scanWithRemark(t, src, `{"token":"a"}`, "plain")
+	forged := scanWithRemark(t, src, `{"token":"b"}`, "forged")
+	var buf bytes.Buffer
+	if _, err := src.WriteExport(&buf); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	// 伪造的哈希若被接受，之后内容为 {"token":"c"} 的备份会被误判为重复。
+	archive := rewriteIndex(t, buf.Bytes(), func(items []map[string]any) {
+		for _, item := range items {
+			if item["id"] == forged.ID {
+				item["content_hash"] = plain.ContentHash + "0"
+			}
+		}
+	})
+
+	dst, cleanupDst := newTestService(t)
+	defer cleanupDst()
+	res, err := dst.Import(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if len(res.Imported) != 1 || res.Imported[0].ID != plain.ID {
+		t.Fatalf("expected only the intact backup to be imported: %+v", res.Imported)
+	}
+	if len(res.Skipped) != 1 || res.Skipped[0].ID != forged.ID || res.Skipped[0].Reason != core.ImportSkipHashMismatch {
+		t.Fatalf("expected forged backup to be skipped as hash_mismatch: %+v", res.Skipped)
+	}
+}
+
+func TestImportVerifiesEncryptedBackups(t *testing.T) {
+	const passphrase = "correct horse battery staple"
+	withSecret := func(passphrase string) func(*core.Config) {
+		return func(cfg *core.Config) {
+			cfg.Compression = core.CompressionGzip
+			cfg.EncryptionPassphrase = util.SecretString(passphrase)
+		}
+	}
+	src, cleanupSrc := newTestService(t, withSecret(passphrase))
+	defer cleanupSrc()
+	secret := scanWithRemark(t, src, `{"token":"s"}`, "secret")
+	var buf bytes.Buffer
+	if _, err := src.WriteExport(&buf); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+
+	other, cleanupOther := newTestService(t)
+	defer cleanupOther()
+	res, err := other.Import(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil || len(res.Imported) != 0 || len(res.Skipped) != 1 || res.Skipped[0].Reason != core.ImportSkipUndecryptable {
+		t.Fatalf("expected encrypted backup to be skipped without the passphrase: %+v (%v)", res, err)
+	}
+
+	dst, cleanupDst := newTestService(t, withSecret(passphrase))
+	defer cleanupDst()
+	res, err = dst.Import(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil || len(res.Imported) != 1 || res.Imported[0].ID != secret.ID {
+		t.Fatalf("expected encrypted backup to be imported with the same passphrase: %+v (%v)", res, err)
+	}
+}
+
+func TestImportNormalizesRemarks(t *testing.T) {
+	src, cleanupSrc := newTestService(t)
+	defer cleanupSrc()
+	long := scanWithRemark(t, src, `{"token":"a"}`, "long")
+	empty := scanWithRemark(t, src, `{"token":"b"}`, "empty")
+	var buf bytes.Buffer
+	if _, err := src.WriteExport(&buf); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	// 归档中的备注绕过了 API 的校验：超长且未规范化，或为空。
+	archive := rewriteIndex(t, buf.Bytes(), func(items []map[string]any) {
+		for _, item := range items {
+			switch item["id"] {
+			case long.ID:
+				item["remark"] = "  Cafe\u0301 \t" + strings.Repeat("x", 50000)
+			case empty.ID:
+				item["remark"] = ""
+			}
+		}
+	})
+
+	dst, cleanupDst := newTestService(t, func(cfg *core.Config) { cfg.RemarkMaxLength = 20 })
+	defer cleanupDst()
+	res, err := dst.Import(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil || len(res.Imported) != 2 {
+		t.Fatalf("import: %+v (%v)", res, err)
+	}
+	if len(res.Renamed) != 2 {
+		t.Fatalf("expected both remarks to be reported as renamed: %+v", res.Renamed)
+	}
+	got, err := dst.GetBackup(long.ID)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if want := "Caf\u00e9 " + strings.Repeat("x", 15); got.Remark != want {
+		t.Fatalf("expected normalized, truncated remark %q, got %q", want, got.Remark)
+	}
+	got, err = dst.GetBackup(empty.ID)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if !strings.HasPrefix(got.Remark, "manual-") {
+		t.Fatalf("expected a default remark for the empty one, got %q", got.Remark)
+	}
+}
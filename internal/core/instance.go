@@ -0,0 +1,38 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"codex-backup-tool/internal/util"
+)
+
+// instanceIDFile 保存实例 ID，独立于 index.json，重建索引或清空数据后保持不变。
+const instanceIDFile = "instance_id"
+
+// LoadInstanceID 读取数据目录中的实例 ID，不存在或内容无效时生成新的 UUID 并写入。
+func LoadInstanceID(dataDir string) (string, error) {
+	path := filepath.Join(dataDir, instanceIDFile)
+	data, err := os.ReadFile(util.LongPath(path))
+	if err == nil {
+		if id, perr := uuid.Parse(strings.TrimSpace(string(data))); perr == nil {
+			return id.String(), nil
+		}
+	} else if !os.IsNotExist(err) {
+		return "", fmt.Errorf("read instance id: %w", err)
+	}
+	id := uuid.New().String()
+	if err := util.AtomicWriteFile(path, []byte(id+"\n"), 0o600); err != nil {
+		return "", fmt.Errorf("write instance id: %w", err)
+	}
+	return id, nil
+}
+
+// InstanceID 返回数据目录的实例 ID。
+func (s *Service) InstanceID() string {
+	return s.instanceID
+}
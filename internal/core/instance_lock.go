@@ -0,0 +1,47 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"codex-backup-tool/internal/util"
+)
+
+// instanceLockTimeout 为启动时等待数据目录实例锁的上限。
+const instanceLockTimeout = 5 * time.Second
+
+// ErrDataDirLocked 在数据目录已被另一个运行中的实例占用时由 NewService 返回，持有者见 DataDirLockedError。
+var ErrDataDirLocked = errors.New("data dir is locked by another instance")
+
+// DataDirLockedError 指明占用数据目录的实例。
+type DataDirLockedError struct {
+	Path     string
+	PID      int
+	Hostname string
+}
+
+func (e *DataDirLockedError) Error() string {
+	return fmt.Sprintf("%s: %s held by pid=%d host=%s", ErrDataDirLocked, e.Path, e.PID, e.Hostname)
+}
+
+// Unwrap 使 errors.Is(err, ErrDataDirLocked) 成立。
+func (e *DataDirLockedError) Unwrap() error {
+	return ErrDataDirLocked
+}
+
+// acquireInstanceLock 独占数据目录，避免两个实例各自维护 LatestFingerprint 等状态而互相覆盖。
+func acquireInstanceLock(path string) (*util.HeldLock, error) {
+	lock, err := util.AcquireFileLock(path, instanceLockTimeout)
+	if err == nil {
+		return lock, nil
+	}
+	if !errors.Is(err, util.ErrLockTimeout) {
+		return nil, fmt.Errorf("acquire instance lock: %w", err)
+	}
+	lockErr := &DataDirLockedError{Path: path}
+	if owner, _ := util.ReadLockOwner(path); owner != nil {
+		lockErr.PID, lockErr.Hostname = owner.PID, owner.Hostname
+	}
+	return nil, lockErr
+}
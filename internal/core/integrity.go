@@ -0,0 +1,103 @@
+package core
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"codex-backup-tool/internal/util"
+)
+
+// ErrIndexTampered 在 index.json 与签名不符、索引处于只读状态时返回。
+var ErrIndexTampered = errors.New("index signature mismatch")
+
+// signIndex 计算索引文件内容的 HMAC-SHA256。签名针对写入磁盘的原始字节，
+// 这些字节由 util.MarshalJSON 以固定选项生成，对同一份数据总是相同。
+func signIndex(key, data []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *Store) sigPath() string {
+	return s.indexPath + ".sig"
+}
+
+// verifySignatureUnlocked 校验 index.json 与 index.json.sig，更新 tampered 状态。
+// 未配置密钥或还没有签名文件（功能启用前的索引）时视为可信，下一次写入时补签。
+func (s *Store) verifySignatureUnlocked(data []byte, exists bool) error {
+	if len(s.opts.IntegrityKey) == 0 {
+		s.tampered = false
+		return nil
+	}
+	sig, sigExists, err := util.ReadFileIfExists(s.sigPath())
+	if err != nil {
+		return fmt.Errorf("read index signature: %w", err)
+	}
+	tampered := false
+	if sigExists {
+		expected := signIndex(s.opts.IntegrityKey, data)
+		tampered = !exists || !hmac.Equal([]byte(strings.TrimSpace(string(sig))), []byte(expected))
+	}
+	if tampered && !s.tampered {
+		s.log().Error("!!! 索引与签名不符，可能被其他程序修改，已进入只读模式；确认内容无误后调用 POST /api/index/accept 重新签名", "path", s.indexPath)
+		if s.opts.OnTampered != nil {
+			s.opts.OnTampered()
+		}
+	}
+	s.tampered = tampered
+	return nil
+}
+
+// writeSignatureUnlocked 为刚写入的索引内容生成签名文件。
+// 索引与签名分两次原子写入，若恰好在两者之间崩溃，下次启动会报告签名不符，需人工确认。
+func (s *Store) writeSignatureUnlocked(data []byte) error {
+	if len(s.opts.IntegrityKey) == 0 {
+		return nil
+	}
+	if err := util.AtomicWriteFile(s.sigPath(), []byte(signIndex(s.opts.IntegrityKey, data)+"\n"), 0o600); err != nil {
+		return fmt.Errorf("write index signature: %w", err)
+	}
+	return nil
+}
+
+// Tampered 报告当前索引是否与签名不符。
+func (s *Store) Tampered() (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.refreshUnlocked(); err != nil {
+		return false, err
+	}
+	return s.tampered, nil
+}
+
+// AcceptIndex 以当前磁盘上的 index.json 内容重新签名，解除只读状态。
+func (s *Store) AcceptIndex() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return util.WithFileLockOptions(s.lockPath, s.opts.Lock, func() error {
+		if len(s.opts.IntegrityKey) == 0 {
+			return nil
+		}
+		data, exists, err := util.ReadFileIfExists(s.indexPath)
+		if err != nil {
+			return fmt.Errorf("read index: %w", err)
+		}
+		if !exists {
+			if err := os.Remove(util.LongPath(s.sigPath())); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("remove index signature: %w", err)
+			}
+		} else if err := s.writeSignatureUnlocked(data); err != nil {
+			return err
+		}
+		s.cachedAt = time.Time{}
+		s.tampered = false
+		s.log().Info("已接受当前索引内容并重新签名", "path", s.indexPath)
+		return nil
+	})
+}
@@ -0,0 +1,126 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/zalando/go-keyring"
+
+	"codex-backup-tool/internal/util"
+)
+
+// KeyringService 为系统凭据存储（macOS Keychain、Windows 凭据管理器、Linux Secret Service）中加密口令所用的服务名，
+// 账户名为数据目录的绝对路径，多个实例各自保存口令。
+const KeyringService = "codex-backup-tool"
+
+// ErrKeyringPassphraseNotFound 在系统凭据存储中没有当前数据目录的加密口令时返回。
+var ErrKeyringPassphraseNotFound = errors.New("encryption passphrase not found in keyring")
+
+// KeyProvider 提供备份加密口令。配置中的口令、口令文件、环境变量与系统凭据存储都实现该接口，可相互替换。
+type KeyProvider interface {
+	Passphrase() (string, error)
+	// Source 返回口令来源的简短名称（passphrase、file、env、keyring），用于配置展示与日志，不含口令本身。
+	Source() string
+}
+
+// StaticKey 为直接配置或交互输入的口令，格式化输出与序列化时同 util.SecretString 只显示占位符。
+type StaticKey struct {
+	Secret util.SecretString
+}
+
+func (k StaticKey) Passphrase() (string, error) {
+	if k.Secret == "" {
+		return "", errors.New("加密口令为空")
+	}
+	return k.Secret.Reveal(), nil
+}
+
+func (StaticKey) Source() string { return "passphrase" }
+
+// FileKey 从文件读取口令，去除首尾空白。
+type FileKey struct {
+	Path string
+}
+
+func (k FileKey) Passphrase() (string, error) {
+	data, err := os.ReadFile(k.Path)
+	if err != nil {
+		return "", fmt.Errorf("读取 encryption_key_file: %w", err)
+	}
+	key := strings.TrimSpace(string(data))
+	if key == "" {
+		return "", fmt.Errorf("encryption_key_file 为空: %s", k.Path)
+	}
+	return key, nil
+}
+
+func (FileKey) Source() string { return "file" }
+
+// EnvKey 从环境变量读取口令，去除首尾空白。
+type EnvKey struct {
+	Name string
+}
+
+func (k EnvKey) Passphrase() (string, error) {
+	key := strings.TrimSpace(os.Getenv(k.Name))
+	if key == "" {
+		return "", fmt.Errorf("环境变量 %s 未设置或为空", k.Name)
+	}
+	return key, nil
+}
+
+func (EnvKey) Source() string { return "env" }
+
+// KeyringKey 从系统凭据存储读取口令，Account 为数据目录的绝对路径。
+type KeyringKey struct {
+	Account string
+}
+
+func (k KeyringKey) Passphrase() (string, error) {
+	key, err := keyring.Get(KeyringService, k.Account)
+	if errors.Is(err, keyring.ErrNotFound) {
+		return "", fmt.Errorf("%w: account=%s", ErrKeyringPassphraseNotFound, k.Account)
+	}
+	if err != nil {
+		return "", fmt.Errorf("读取系统凭据存储: %w", err)
+	}
+	return key, nil
+}
+
+func (KeyringKey) Source() string { return "keyring" }
+
+// SetPassphrase 将口令写入系统凭据存储，覆盖已有的值。
+func (k KeyringKey) SetPassphrase(passphrase string) error {
+	if passphrase == "" {
+		return errors.New("加密口令不能为空")
+	}
+	if err := keyring.Set(KeyringService, k.Account, passphrase); err != nil {
+		return fmt.Errorf("写入系统凭据存储: %w", err)
+	}
+	return nil
+}
+
+// DeletePassphrase 从系统凭据存储删除口令，不存在时返回 ErrKeyringPassphraseNotFound。
+func (k KeyringKey) DeletePassphrase() error {
+	err := keyring.Delete(KeyringService, k.Account)
+	if errors.Is(err, keyring.ErrNotFound) {
+		return fmt.Errorf("%w: account=%s", ErrKeyringPassphraseNotFound, k.Account)
+	}
+	if err != nil {
+		return fmt.Errorf("删除系统凭据存储中的口令: %w", err)
+	}
+	return nil
+}
+
+// EncryptionKeyProvider 返回配置的口令来源：EncryptionKey 优先，其次为 EncryptionPassphrase；未启用加密时返回 nil。
+func (c Config) EncryptionKeyProvider() KeyProvider {
+	if c.EncryptionKey != nil {
+		return c.EncryptionKey
+	}
+	if c.EncryptionPassphrase != "" {
+		return StaticKey{Secret: c.EncryptionPassphrase}
+	}
+	return nil
+}
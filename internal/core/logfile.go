@@ -0,0 +1,76 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+
+	"codex-backup-tool/internal/util"
+)
+
+// backwardChunkSize 为倒序读取 JSON Lines 文件时每次读取的字节数。
+const backwardChunkSize = 64 << 10
+
+// rotatedPath 返回按大小轮转后的第 n 份历史文件路径，n 为 0 时即当前文件。
+func rotatedPath(path string, n int) string {
+	if n == 0 {
+		return path
+	}
+	return fmt.Sprintf("%s.%d", path, n)
+}
+
+// rotateFiles 将 path 依次后移为 path.1 … path.keep，最旧的一份被覆盖。
+func rotateFiles(path string, keep int) error {
+	for i := keep - 1; i >= 0; i-- {
+		if err := os.Rename(util.LongPath(rotatedPath(path, i)), util.LongPath(rotatedPath(path, i+1))); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// readLinesBackward 从文件末尾开始按块读取，依次以从新到旧的顺序把非空行交给 fn，
+// fn 返回 false 时停止。查询最近的记录时只需读取文件尾部。文件不存在时直接返回。
+func readLinesBackward(path string, fn func(line []byte) bool) error {
+	f, err := os.Open(util.LongPath(path))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	var carry []byte
+	for pos := info.Size(); pos > 0; {
+		n := int64(backwardChunkSize)
+		if n > pos {
+			n = pos
+		}
+		pos -= n
+		chunk := make([]byte, n, n+int64(len(carry)))
+		if _, err := f.ReadAt(chunk, pos); err != nil && err != io.EOF {
+			return err
+		}
+		data := append(chunk, carry...)
+		for {
+			i := bytes.LastIndexByte(data, '\n')
+			if i < 0 {
+				break
+			}
+			if line := data[i+1:]; len(line) > 0 && !fn(line) {
+				return nil
+			}
+			data = data[:i]
+		}
+		carry = data
+	}
+	if len(carry) > 0 {
+		fn(carry)
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package core
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"strings"
+
+	"codex-backup-tool/internal/util"
+)
+
+// Logger 为服务使用的结构化日志接口，fields 为交替出现的键与值，如 "id", item.ID。
+type Logger interface {
+	Debug(msg string, fields ...any)
+	Info(msg string, fields ...any)
+	Warn(msg string, fields ...any)
+	Error(msg string, fields ...any)
+}
+
+// JSONLogger 基于 log/slog，每条日志输出为一行 JSON，包含 time、level、msg 与各字段。
+type JSONLogger struct {
+	l *slog.Logger
+}
+
+// NewJSONLogger 返回向 w 写入 JSON 行、只输出 level 及以上级别的 Logger。
+func NewJSONLogger(w io.Writer, level slog.Level) Logger {
+	return &JSONLogger{l: slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level}))}
+}
+
+// Debug 输出调试日志。
+func (j *JSONLogger) Debug(msg string, fields ...any) { j.l.Debug(msg, fields...) }
+
+// Info 输出一般日志。
+func (j *JSONLogger) Info(msg string, fields ...any) { j.l.Info(msg, fields...) }
+
+// Warn 输出警告日志。
+func (j *JSONLogger) Warn(msg string, fields ...any) { j.l.Warn(msg, fields...) }
+
+// Error 输出错误日志。
+func (j *JSONLogger) Error(msg string, fields ...any) { j.l.Error(msg, fields...) }
+
+// ParseLogLevel 解析 log_level 配置，取值为 debug、info、warn、error，空字符串为 info。
+func ParseLogLevel(level string) (slog.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(level)) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	default:
+		return 0, fmt.Errorf("log_level 只能是 debug、info、warn 或 error: %q", level)
+	}
+}
+
+// NewStdLogger 返回将每行输出作为一条 level 级别日志写入 l 的 *log.Logger，
+// 供仍使用标准库 log 的命令行与 HTTP 中间件输出到同一日志流。是否输出由 l 的级别决定，
+// 致命错误应使用 slog.LevelError 的 Logger，以免 log_level 较高时被丢弃。
+func NewStdLogger(l Logger, level slog.Level) *log.Logger {
+	return log.New(stdLogWriter{l: l, level: level}, "", 0)
+}
+
+type stdLogWriter struct {
+	l     Logger
+	level slog.Level
+}
+
+func (w stdLogWriter) Write(p []byte) (int, error) {
+	msg := strings.TrimSuffix(string(p), "\n")
+	switch {
+	case w.level >= slog.LevelError:
+		w.l.Error(msg)
+	case w.level >= slog.LevelWarn:
+		w.l.Warn(msg)
+	case w.level >= slog.LevelInfo:
+		w.l.Info(msg)
+	default:
+		w.l.Debug(msg)
+	}
+	return len(p), nil
+}
+
+// logfFunc 将 l 适配为 printf 风格的回调，供文件锁、hook 与追踪等只接受 Logf 的组件使用，输出为 Warn 级别。
+func logfFunc(l Logger) func(format string, args ...any) {
+	return func(format string, args ...any) {
+		l.Warn(fmt.Sprintf(format, args...))
+	}
+}
+
+// redactLogger 在转交给 next 之前脱敏消息与字符串、错误类字段，保证服务日志不含令牌，
+// 即使调用方传入的 Logger 未做处理。
+type redactLogger struct {
+	next Logger
+}
+
+func (r redactLogger) Debug(msg string, fields ...any) {
+	r.next.Debug(util.Redact(msg), redactFields(fields)...)
+}
+
+func (r redactLogger) Info(msg string, fields ...any) {
+	r.next.Info(util.Redact(msg), redactFields(fields)...)
+}
+
+func (r redactLogger) Warn(msg string, fields ...any) {
+	r.next.Warn(util.Redact(msg), redactFields(fields)...)
+}
+
+func (r redactLogger) Error(msg string, fields ...any) {
+	r.next.Error(util.Redact(msg), redactFields(fields)...)
+}
+
+func redactFields(fields []any) []any {
+	out := make([]any, len(fields))
+	for i, v := range fields {
+		switch v := v.(type) {
+		case string:
+			out[i] = util.Redact(v)
+		case error:
+			out[i] = util.Redact(v.Error())
+		case fmt.Stringer:
+			out[i] = util.Redact(v.String())
+		default:
+			out[i] = v
+		}
+	}
+	return out
+}
+
+// discardLogger 丢弃全部日志，为未配置 Logger 的 Store 使用。
+type discardLogger struct{}
+
+func (discardLogger) Debug(string, ...any) {}
+func (discardLogger) Info(string, ...any)  {}
+func (discardLogger) Warn(string, ...any)  {}
+func (discardLogger) Error(string, ...any) {}
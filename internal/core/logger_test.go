@@ -0,0 +1,189 @@
+package core_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"codex-backup-tool/internal/core"
+)
+
+// decodeLogLines 将 JSON 日志逐行解析，任何一行不是 JSON 对象都会使测试失败。
+func decodeLogLines(t *testing.T, out string) []map[string]any {
+	t.Helper()
+	var entries []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		if line == "" {
+			continue
+		}
+		var entry map[string]any
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("log line is not JSON: %q (%v)", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func findLogEntry(entries []map[string]any, msg string) map[string]any {
+	for _, entry := range entries {
+		if entry["msg"] == msg {
+			return entry
+		}
+	}
+	return nil
+}
+
+// newLoggedService 创建向 buf 写入 level 及以上级别 JSON 日志的服务。
+func newLoggedService(t *testing.T, buf *bytes.Buffer, level slog.Level) *core.Service {
+	t.Helper()
+	base := t.TempDir()
+	dataDir := filepath.Join(base, "data")
+	svc, err := core.NewService(core.Config{
+		TargetPath:   filepath.Join(base, "codex", "auth.json"),
+		DataDir:      dataDir,
+		BackupsDir:   filepath.Join(dataDir, "backups"),
+		IndexPath:    filepath.Join(dataDir, "index.json"),
+		ScanInterval: time.Second,
+	}, core.NewJSONLogger(buf, level))
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	t.Cleanup(svc.Stop)
+	return svc
+}
+
+func TestServiceWritesStructuredJSONLogs(t *testing.T) {
+	var buf bytes.Buffer
+	svc := newLoggedService(t, &buf, slog.LevelInfo)
+	writeTarget(t, svc, `{"token":"structured"}`)
+	remark := "key sk-structuredlogsecret0123456789"
+	res, err := svc.CreateBackup(&remark)
+	if err != nil || !res.Created {
+		t.Fatalf("backup: %+v %v", res, err)
+	}
+	if err := svc.DeleteBackup(res.Item.ID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+
+	entries := decodeLogLines(t, buf.String())
+	if init := findLogEntry(entries, "Service init"); init == nil || init["level"] != "INFO" || init["target"] != svc.Config().TargetPath {
+		t.Fatalf("unexpected init entry: %v", init)
+	}
+	created := findLogEntry(entries, "创建备份")
+	if created == nil {
+		t.Fatalf("missing backup entry in:\n%s", buf.String())
+	}
+	for _, key := range []string{"time", "level", "id", "remark", "fingerprint", "hash"} {
+		if _, ok := created[key]; !ok {
+			t.Fatalf("backup entry missing %q: %v", key, created)
+		}
+	}
+	if created["id"] != res.Item.ID || created["remark"] != "key [REDACTED]" {
+		t.Fatalf("unexpected backup entry fields: %v", created)
+	}
+	if deleted := findLogEntry(entries, "删除备份"); deleted == nil || deleted["id"] != res.Item.ID {
+		t.Fatalf("unexpected delete entry: %v", deleted)
+	}
+	if strings.Contains(buf.String(), "structuredlogsecret") {
+		t.Fatalf("log output leaked the remark secret:\n%s", buf.String())
+	}
+}
+
+func TestJSONLoggerLevelsAndStdAdapter(t *testing.T) {
+	var buf bytes.Buffer
+	logger := core.NewJSONLogger(&buf, slog.LevelWarn)
+	logger.Debug("hidden")
+	logger.Info("hidden")
+	logger.Warn("disk low", "free", 10)
+	core.NewStdLogger(logger, slog.LevelInfo).Printf("std %s", "line")
+	logger.Error("failed", "error", "boom")
+
+	entries := decodeLogLines(t, buf.String())
+	if len(entries) != 2 {
+		t.Fatalf("expected only warn and error entries, got:\n%s", buf.String())
+	}
+	if entries[0]["level"] != "WARN" || entries[0]["msg"] != "disk low" || entries[0]["free"] != float64(10) {
+		t.Fatalf("unexpected warn entry: %v", entries[0])
+	}
+	if entries[1]["level"] != "ERROR" || entries[1]["error"] != "boom" {
+		t.Fatalf("unexpected error entry: %v", entries[1])
+	}
+
+	buf.Reset()
+	core.NewStdLogger(core.NewJSONLogger(&buf, slog.LevelInfo), slog.LevelInfo).Println("from std")
+	if entries = decodeLogLines(t, buf.String()); len(entries) != 1 || entries[0]["msg"] != "from std" || entries[0]["level"] != "INFO" {
+		t.Fatalf("unexpected std adapter output: %s", buf.String())
+	}
+
+	// log_level 为 error 时，Error 级别的标准库 logger（main 的 Fatalf 与 panic 日志）仍会输出。
+	buf.Reset()
+	quiet := core.NewJSONLogger(&buf, slog.LevelError)
+	core.NewStdLogger(quiet, slog.LevelWarn).Println("hidden warn")
+	core.NewStdLogger(quiet, slog.LevelError).Printf("监听 %s 失败", ":8080")
+	if entries = decodeLogLines(t, buf.String()); len(entries) != 1 || entries[0]["msg"] != "监听 :8080 失败" || entries[0]["level"] != "ERROR" {
+		t.Fatalf("expected only the error entry at log_level=error: %s", buf.String())
+	}
+}
+
+func TestLoadConfigLogLevel(t *testing.T) {
+	cfg, _, err := core.LoadConfig(writeConfig(t, `{"data_dir":"./data"}`))
+	if err != nil || cfg.LogLevel != slog.LevelInfo {
+		t.Fatalf("default log level: %v (%v)", cfg.LogLevel, err)
+	}
+	cfg, _, err = core.LoadConfig(writeConfig(t, `{"data_dir":"./data","log_level":"debug"}`))
+	if err != nil || cfg.LogLevel != slog.LevelDebug {
+		t.Fatalf("debug log level: %v (%v)", cfg.LogLevel, err)
+	}
+	if _, _, err := core.LoadConfig(writeConfig(t, `{"data_dir":"./data","log_level":"verbose"}`)); err == nil {
+		t.Fatalf("expected unknown log_level to be rejected")
+	}
+}
+
+// scanDecisions 依次写入内容并扫描，覆盖新备份、指纹一致与内容重复三种分支，返回日志中记录的扫描决策。
+func scanDecisions(t *testing.T, level slog.Level) ([]string, []map[string]any) {
+	t.Helper()
+	var buf bytes.Buffer
+	svc := newLoggedService(t, &buf, level)
+	for _, content := range []string{`{"token":"a"}`, "", `{"token":"bb"}`, `{"token":"a"}`} {
+		if content != "" {
+			writeTarget(t, svc, content)
+		}
+		if _, err := svc.Scan(true, nil); err != nil {
+			t.Fatalf("scan: %v", err)
+		}
+	}
+	entries := decodeLogLines(t, buf.String())
+	var decisions []string
+	for _, entry := range entries {
+		if decision, ok := entry["decision"].(string); ok {
+			if entry["level"] != "DEBUG" {
+				t.Fatalf("scan decisions should be logged at debug level: %v", entry)
+			}
+			decisions = append(decisions, decision)
+		}
+	}
+	return decisions, entries
+}
+
+func TestScanDecisionDebugLogs(t *testing.T) {
+	decisions, _ := scanDecisions(t, slog.LevelDebug)
+	want := "new_backup,fingerprint_match,new_backup,content_dedup"
+	if got := strings.Join(decisions, ","); got != want {
+		t.Fatalf("unexpected scan decisions: got %s, want %s", got, want)
+	}
+
+	decisions, entries := scanDecisions(t, slog.LevelWarn)
+	if len(decisions) != 0 {
+		t.Fatalf("debug lines should be suppressed at warn level: %v", decisions)
+	}
+	for _, entry := range entries {
+		if entry["level"] == "DEBUG" || entry["level"] == "INFO" {
+			t.Fatalf("unexpected %s entry at warn level: %v", entry["level"], entry)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"math"
+
+	"codex-backup-tool/internal/metrics"
+)
+
+// serviceMetrics 为 Config.EnableMetrics 开启时导出的 Prometheus 指标。未开启时各字段为 nil，记录操作均为空操作。
+type serviceMetrics struct {
+	registry       *metrics.Registry
+	backupsCreated *metrics.Counter
+	backupsDeleted *metrics.Counter
+	restores       *metrics.Counter
+	scanDuration   *metrics.Histogram
+}
+
+// maxTargetLabelLen 为 target 标签直接使用路径的最大长度，更长的路径改用其哈希。
+const maxTargetLabelLen = 128
+
+// targetLabel 返回 target 标签的值：目标文件路径，超过 maxTargetLabelLen 时为 "sha256:" 加路径 SHA-256 的前 12 位。
+func targetLabel(path string) string {
+	if len(path) <= maxTargetLabelLen {
+		return path
+	}
+	sum := sha256.Sum256([]byte(path))
+	return "sha256:" + ShortHash(hex.EncodeToString(sum[:]))
+}
+
+// newServiceMetrics 注册服务的指标，每条序列都带 target 标签，以区分备份不同目标文件的实例；
+// 备份数量与总字节数在每次抓取时从索引快照计算。
+func newServiceMetrics(s *Service) serviceMetrics {
+	reg := metrics.NewRegistryWithLabels(map[string]string{"target": targetLabel(s.cfg.TargetPath)})
+	m := serviceMetrics{
+		registry:       reg,
+		backupsCreated: reg.NewCounter("backup_created_total", "Number of backups created since the process started."),
+		backupsDeleted: reg.NewCounter("backup_deleted_total", "Number of backups deleted since the process started, including retention."),
+		restores:       reg.NewCounter("restore_total", "Number of successful restores since the process started."),
+		scanDuration:   reg.NewHistogram("scan_duration_seconds", "Duration of scans, including ones that created no backup.", nil),
+	}
+	reg.NewGaugeFunc("backup_files_total", "Number of backups in the index.", func() float64 {
+		idx, err := s.store.Snapshot()
+		if err != nil {
+			return math.NaN()
+		}
+		return float64(len(idx.Items))
+	})
+	reg.NewGaugeFunc("backup_bytes_total", "Total size in bytes of the backups in the index.", func() float64 {
+		idx, err := s.store.Snapshot()
+		if err != nil {
+			return math.NaN()
+		}
+		var total int64
+		for _, item := range idx.Items {
+			total += item.Size
+		}
+		return float64(total)
+	})
+	return m
+}
+
+// Metrics 返回指标注册表，未开启 Config.EnableMetrics 时为 nil。
+func (s *Service) Metrics() *metrics.Registry {
+	return s.metrics.registry
+}
@@ -0,0 +1,90 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// secretKeyParts 为敏感字段名包含的片段（不区分大小写），这类字段的字符串值在预览中被掩码。
+var secretKeyParts = []string{"token", "key", "secret", "refresh"}
+
+// BackupPreview 为备份内容预览，Content 中的敏感值已被掩码。
+type BackupPreview struct {
+	ID        string      `json:"id"`
+	Size      int64       `json:"size"`
+	CreatedAt time.Time   `json:"created_at"`
+	Remark    string      `json:"remark"`
+	Content   interface{} `json:"content"`
+}
+
+// PreviewBackup 解析备份中的 JSON 并掩码敏感值，内容不是合法 JSON 时返回 ErrNotJSON。
+func (s *Service) PreviewBackup(id string) (*BackupPreview, error) {
+	item, data, err := s.readBackup(id)
+	if err != nil {
+		return nil, err
+	}
+	v, err := parseJSONContent(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: id=%s: %v", ErrNotJSON, id, err)
+	}
+	return &BackupPreview{
+		ID:        item.ID,
+		Size:      int64(len(data)),
+		CreatedAt: item.CreatedAt,
+		Remark:    item.Remark,
+		Content:   MaskSecrets(v),
+	}, nil
+}
+
+// MaskSecrets 返回 JSON 值的副本：对象中字段名包含 token、key、secret 或 refresh 的字符串值
+// 经 MaskSecret 掩码，这类字段下的字符串数组同样处理；嵌套对象按各自的字段名递归判断。
+func MaskSecrets(v interface{}) interface{} {
+	return maskValue(v, false)
+}
+
+func maskValue(v interface{}, sensitive bool) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(val))
+		for k, child := range val {
+			out[k] = maskValue(child, isSecretKey(k))
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, child := range val {
+			out[i] = maskValue(child, sensitive)
+		}
+		return out
+	case string:
+		if sensitive {
+			return MaskSecret(val)
+		}
+		return val
+	default:
+		return v
+	}
+}
+
+func isSecretKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, part := range secretKeyParts {
+		if strings.Contains(key, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// MaskSecret 保留前 3 个与后 4 个字符，如 sk-...abcd；不足 12 个字符的值整体替换为 ***，避免泄露大部分内容。
+func MaskSecret(s string) string {
+	r := []rune(s)
+	if len(r) < 12 {
+		if len(r) == 0 {
+			return s
+		}
+		return "***"
+	}
+	return string(r[:3]) + "..." + string(r[len(r)-4:])
+}
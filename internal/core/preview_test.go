@@ -0,0 +1,88 @@
+package core_test
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"codex-backup-tool/internal/core"
+)
+
+func TestMaskSecret(t *testing.T) {
+	cases := map[string]string{
+		"":                        "",
+		"short":                   "***",
+		"sk-proj-1234567890abcd":  "sk-...abcd",
+		"eyJhbGciOi.payload.wxyz": "eyJ...wxyz",
+	}
+	for in, want := range cases {
+		if got := core.MaskSecret(in); got != want {
+			t.Errorf("MaskSecret(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestMaskSecretsNested(t *testing.T) {
+	var v interface{}
+	if err := json.Unmarshal([]byte(`{
+		"OPENAI_API_KEY": "sk-proj-1234567890abcd",
+		"tokens": {
+			"access_token": "eyJhbGciOi.payload.wxyz",
+			"account_id": "acct-visible-0001",
+			"history": [{"refresh_token": "rt-0123456789-tail"}, {"note": "kept"}]
+		},
+		"api_keys": ["sk-first-0123456789", "sk-second-0123456789"],
+		"last_refresh": 42,
+		"email": "user@example.com"
+	}`), &v); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	var want interface{}
+	if err := json.Unmarshal([]byte(`{
+		"OPENAI_API_KEY": "sk-...abcd",
+		"tokens": {
+			"access_token": "eyJ...wxyz",
+			"account_id": "acct-visible-0001",
+			"history": [{"refresh_token": "rt-...tail"}, {"note": "kept"}]
+		},
+		"api_keys": ["sk-...6789", "sk-...6789"],
+		"last_refresh": 42,
+		"email": "user@example.com"
+	}`), &want); err != nil {
+		t.Fatalf("unmarshal want: %v", err)
+	}
+	if got := core.MaskSecrets(v); !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected masking:\n got %#v\nwant %#v", got, want)
+	}
+}
+
+func TestPreviewBackup(t *testing.T) {
+	svc, cleanup := newTestService(t)
+	defer cleanup()
+	writeTarget(t, svc, `{"tokens":{"id_token":"eyJhbGciOi.payload.wxyz"},"count":10000000000000000001}`)
+	res, err := svc.CreateBackup(nil)
+	if err != nil || !res.Created {
+		t.Fatalf("create: %+v (%v)", res, err)
+	}
+	preview, err := svc.PreviewBackup(res.Item.ID)
+	if err != nil {
+		t.Fatalf("preview: %v", err)
+	}
+	out, _ := json.Marshal(preview.Content)
+	if string(out) != `{"count":10000000000000000001,"tokens":{"id_token":"eyJ...wxyz"}}` {
+		t.Fatalf("unexpected preview content %s", out)
+	}
+	if preview.Remark != res.Item.Remark || preview.Size != res.Item.Size {
+		t.Fatalf("unexpected preview metadata %+v", preview)
+	}
+
+	if err := os.WriteFile(filepath.Join(svc.Config().BackupsDir, res.Item.Filename), []byte("not json"), 0o600); err != nil {
+		t.Fatalf("corrupt backup: %v", err)
+	}
+	if _, err := svc.PreviewBackup(res.Item.ID); !errors.Is(err, core.ErrNotJSON) {
+		t.Fatalf("expected ErrNotJSON, got %v", err)
+	}
+}
@@ -0,0 +1,139 @@
+package core
+
+import "fmt"
+
+// PublicConfig 为可以通过 API 返回的配置子集。
+// 它与 Config 分开定义：Config 新增字段不会自动出现在这里，敏感字段需显式决定是否以及如何暴露。
+type PublicConfig struct {
+	TargetPath              string   `json:"target_path"`
+	DataDir                 string   `json:"data_dir"`
+	BackupsDir              string   `json:"backups_dir"`
+	IndexPath               string   `json:"index_path"`
+	TokensPath              string   `json:"tokens_path"`
+	AuditPath               string   `json:"audit_path"`
+	ScanIntervalSeconds     int      `json:"scan_interval_seconds"`
+	ScanMode                string   `json:"scan_mode"`
+	BindHost                string   `json:"bind_host"`
+	Port                    string   `json:"port"`
+	Exposure                string   `json:"exposure"`
+	AutoOpenBrowser         bool     `json:"auto_open_browser"`
+	DiskReserveBytes        int64    `json:"disk_reserve_bytes"`
+	DataDirMode             string   `json:"data_dir_mode"`
+	FingerprintSampleSize   int      `json:"fingerprint_sample_size"`
+	MtimeGranularityMs      int64    `json:"fingerprint_mtime_granularity_ms"`
+	LockTimeoutSeconds      int      `json:"lock_timeout_seconds"`
+	LockStaleSeconds        int      `json:"lock_stale_seconds"`
+	TempFileGraceSeconds    int      `json:"temp_file_grace_seconds"`
+	MaxTargetSizeBytes      int64    `json:"max_target_size_bytes"`
+	IndexBackupCount        int      `json:"index_backup_count"`
+	IndexCacheTTLMs         int64    `json:"index_cache_ttl_ms"`
+	AuditMaxSizeBytes       int64    `json:"audit_max_size_bytes"`
+	SessionIdleSeconds      int      `json:"session_idle_seconds"`
+	MaxSessions             int      `json:"max_sessions"`
+	RequireAuthForRemote    bool     `json:"require_auth_for_remote"`
+	Warnings                []string `json:"warnings"`
+	DisabledCapabilities    []string `json:"disabled_capabilities"`
+	TLS                     bool     `json:"tls"`
+	ClientCertRequired      bool     `json:"client_cert_required"`
+	HealthAddr              string   `json:"health_addr"`
+	DocsEnabled             bool     `json:"docs_enabled"`
+	RemarkMaxLength         int      `json:"remark_max_length"`
+	RemarkCaseInsensitive   bool     `json:"remark_case_insensitive"`
+	RestoreOverrideReadOnly bool     `json:"restore_override_readonly"`
+	TargetChangePolicy      string   `json:"target_change_policy"`
+	Timestamps              string   `json:"timestamps"`
+	Compression             string   `json:"compression"`
+	Watch                   bool     `json:"watch"`
+	WatchDebounceMs         int64    `json:"watch_debounce_ms"`
+	MaxAutoBackups          int      `json:"max_auto_backups"`
+	AutoBackupMaxAgeDays    int      `json:"auto_backup_max_age_days"`
+	MaxBackupsTotalBytes    int64    `json:"max_backups_total_bytes"`
+	EnableMetrics           bool     `json:"enable_metrics"`
+	MetricsPublic           bool     `json:"metrics_public"`
+	CORSOrigins             []string `json:"cors_origins"`
+	CORSCredentials         bool     `json:"cors_credentials"`
+	RateLimitRPS            float64  `json:"rate_limit_rps"`
+	RateLimitBurst          int      `json:"rate_limit_burst"`
+	// PasswordLogin 只说明是否配置了 ui_password_hash，不返回哈希本身。
+	PasswordLogin bool `json:"password_login"`
+	// IntegrityProtected 只说明是否配置了 integrity_key。
+	IntegrityProtected bool `json:"integrity_protected"`
+	// StaticTokenAuth 只说明是否配置了 api_token。
+	StaticTokenAuth bool `json:"static_token_auth"`
+	// BackupEncryption 只说明是否配置了加密口令，新备份是否加密保存。
+	BackupEncryption bool `json:"backup_encryption"`
+	// EncryptionKeySource 为加密口令的来源（passphrase、file、env、keyring），未启用加密时为空。
+	EncryptionKeySource string `json:"encryption_key_source,omitempty"`
+	// EncryptionKeyID 为当前口令的密钥 ID，与备份条目的 key_id 对照可看出哪些备份需要轮换。
+	EncryptionKeyID string `json:"encryption_key_id,omitempty"`
+}
+
+// PublicConfig 返回去除敏感字段后的配置，供 GET /api/config 使用。
+func (s *Service) PublicConfig() PublicConfig {
+	cfg := s.cfg
+	warnings := append(make([]string, 0, len(cfg.Warnings)), cfg.Warnings...)
+	return PublicConfig{
+		TargetPath:              cfg.TargetPath,
+		DataDir:                 cfg.DataDir,
+		BackupsDir:              cfg.BackupsDir,
+		IndexPath:               cfg.IndexPath,
+		TokensPath:              cfg.TokensPath,
+		AuditPath:               cfg.AuditPath,
+		ScanIntervalSeconds:     int(cfg.ScanInterval.Seconds()),
+		ScanMode:                cfg.EffectiveScanMode(),
+		BindHost:                cfg.BindHost,
+		Port:                    cfg.Port,
+		Exposure:                cfg.Exposure(),
+		AutoOpenBrowser:         cfg.AutoOpenBrowser,
+		DiskReserveBytes:        cfg.DiskReserve,
+		DataDirMode:             fmt.Sprintf("%04o", uint32(cfg.DataDirMode.Perm())),
+		FingerprintSampleSize:   cfg.FingerprintSampleSize,
+		MtimeGranularityMs:      s.mtimeGranularity.Milliseconds(),
+		LockTimeoutSeconds:      int(cfg.LockTimeout.Seconds()),
+		LockStaleSeconds:        int(cfg.LockStaleAfter.Seconds()),
+		TempFileGraceSeconds:    int(cfg.TempFileGrace.Seconds()),
+		MaxTargetSizeBytes:      cfg.MaxTargetSize,
+		IndexBackupCount:        cfg.IndexBackupCount,
+		IndexCacheTTLMs:         cfg.IndexCacheTTL.Milliseconds(),
+		AuditMaxSizeBytes:       cfg.AuditMaxSize,
+		PasswordLogin:           cfg.UIPasswordHash != "",
+		IntegrityProtected:      cfg.IntegrityKey != "",
+		StaticTokenAuth:         cfg.APIToken != "",
+		BackupEncryption:        cfg.EncryptionKeyProvider() != nil,
+		EncryptionKeySource:     encryptionKeySource(cfg),
+		EncryptionKeyID:         s.EncryptionKeyID(),
+		TLS:                     cfg.TLSEnabled(),
+		ClientCertRequired:      cfg.TLSClientCAFile != "",
+		HealthAddr:              cfg.HealthAddr,
+		DocsEnabled:             cfg.DocsEnabled,
+		RemarkMaxLength:         s.remarkMaxLength(),
+		RemarkCaseInsensitive:   cfg.RemarkCaseInsensitive,
+		RestoreOverrideReadOnly: cfg.RestoreOverrideReadOnly,
+		TargetChangePolicy:      cfg.TargetChangePolicy,
+		Timestamps:              cfg.Timestamps,
+		Compression:             cfg.Compression,
+		Watch:                   cfg.Watch,
+		WatchDebounceMs:         cfg.WatchDebounce.Milliseconds(),
+		MaxAutoBackups:          cfg.MaxAutoBackups,
+		AutoBackupMaxAgeDays:    int(cfg.AutoBackupMaxAge.Hours() / 24),
+		MaxBackupsTotalBytes:    cfg.MaxBackupsTotalBytes,
+		EnableMetrics:           cfg.EnableMetrics,
+		MetricsPublic:           cfg.MetricsPublic,
+		CORSOrigins:             cfg.CORS.AllowedOrigins,
+		CORSCredentials:         cfg.CORS.AllowCredentials,
+		RateLimitRPS:            cfg.RateLimitRPS,
+		RateLimitBurst:          cfg.RateLimitBurst,
+		SessionIdleSeconds:      int(cfg.SessionIdle.Seconds()),
+		MaxSessions:             cfg.MaxSessions,
+		RequireAuthForRemote:    cfg.RequireAuthForRemote,
+		Warnings:                warnings,
+		DisabledCapabilities:    cfg.DisabledCapabilities(),
+	}
+}
+
+func encryptionKeySource(cfg Config) string {
+	if key := cfg.EncryptionKeyProvider(); key != nil {
+		return key.Source()
+	}
+	return ""
+}
@@ -0,0 +1,99 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"codex-backup-tool/internal/util"
+)
+
+// ErrUnsafePurge 在数据目录看起来不是本工具专用目录时返回，避免误删用户文件。
+var ErrUnsafePurge = errors.New("refusing to purge unsafe data dir")
+
+// PurgeAll 停止自动扫描并删除全部备份、索引、历史索引与令牌等数据，使服务回到全新安装的状态。
+// 只删除 BackupsDir 与 DataDir 顶层的文件，DataDir 中的其他子目录、审计与事件日志、实例锁文件、实例 ID 以及事件已读位置保持不变。
+// 自动扫描在重启服务前不会恢复，避免清空后立即重新备份当前目标文件。delete 能力关闭时拒绝执行。
+func (s *Service) PurgeAll(ctx context.Context) error {
+	if err := s.RequireCapability(CapDelete); err != nil {
+		return err
+	}
+	if err := s.checkPurgeTarget(); err != nil {
+		return err
+	}
+	s.tokens.Wait()
+	s.stopScheduler()
+	s.scanMu.Lock()
+	defer s.scanMu.Unlock()
+	s.store.mu.Lock()
+	defer s.store.mu.Unlock()
+
+	for _, dir := range []string{s.cfg.BackupsDir, s.cfg.DataDir} {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		n, err := removeFilesIn(dir, func(name string) bool {
+			return isLogFile(s.cfg.AuditPath, name) || isLogFile(s.cfg.EventsPath, name) || filepath.Join(dir, name) == s.cfg.DataDirLockFile ||
+				(dir == s.cfg.DataDir && (name == instanceIDFile || name == eventCursorsFile))
+		})
+		s.logger.Warn("清空数据", "dir", dir, "removed", n)
+		if err != nil {
+			return fmt.Errorf("purge %s: %w", dir, err)
+		}
+	}
+	if err := os.Remove(util.LongPath(s.cfg.IndexPath)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("remove index: %w", err)
+	}
+	s.sizeHistory.reset()
+	s.scanHistory.reset()
+	if err := util.EnsureDirMode(s.cfg.DataDir, s.cfg.DataDirMode); err != nil {
+		return fmt.Errorf("ensure data dir: %w", err)
+	}
+	if err := util.EnsureDirMode(s.cfg.BackupsDir, s.cfg.DataDirMode); err != nil {
+		return fmt.Errorf("ensure backups dir: %w", err)
+	}
+	s.logger.Warn("!!! 已清空全部备份数据，自动扫描已停止，重启服务后恢复")
+	s.emit(Event{Type: EventDataPurged})
+	return nil
+}
+
+// checkPurgeTarget 拒绝清空根目录、用户主目录或目标文件所在目录。
+func (s *Service) checkPurgeTarget() error {
+	for _, dir := range []string{s.cfg.DataDir, s.cfg.BackupsDir} {
+		clean := filepath.Clean(dir)
+		if clean == filepath.Dir(clean) {
+			return fmt.Errorf("%w: %s", ErrUnsafePurge, dir)
+		}
+		if home, err := os.UserHomeDir(); err == nil && clean == filepath.Clean(home) {
+			return fmt.Errorf("%w: %s", ErrUnsafePurge, dir)
+		}
+		if clean == filepath.Dir(filepath.Clean(s.cfg.TargetPath)) {
+			return fmt.Errorf("%w: %s", ErrUnsafePurge, dir)
+		}
+	}
+	return nil
+}
+
+// removeFilesIn 删除 dir 顶层除 keep 外的全部非目录文件，返回删除数量。
+func removeFilesIn(dir string, keep func(name string) bool) (int, error) {
+	entries, err := os.ReadDir(util.LongPath(dir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	removed := 0
+	for _, entry := range entries {
+		if entry.IsDir() || keep(entry.Name()) {
+			continue
+		}
+		if err := os.Remove(util.LongPath(filepath.Join(dir, entry.Name()))); err != nil && !os.IsNotExist(err) {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
@@ -0,0 +1,79 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// defaultRemarkMaxLength 为备注的默认最大字符数。
+const defaultRemarkMaxLength = 128
+
+// ErrInvalidRemark 在备注为空或超出长度限制时返回，具体原因见 RemarkError。
+var ErrInvalidRemark = errors.New("invalid remark")
+
+// RemarkError 说明备注不合法的原因。
+type RemarkError struct {
+	Reason string
+}
+
+func (e *RemarkError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrInvalidRemark, e.Reason)
+}
+
+// Unwrap 使 errors.Is(err, ErrInvalidRemark) 成立。
+func (e *RemarkError) Unwrap() error {
+	return ErrInvalidRemark
+}
+
+// NormalizeRemark 将备注转换为 NFC，去除首尾空白并把连续的空白合并为一个空格。
+func NormalizeRemark(remark string) string {
+	return strings.Join(strings.FieldsFunc(norm.NFC.String(remark), unicode.IsSpace), " ")
+}
+
+// remarkKey 返回判断备注是否重复时使用的键：规范化后的备注，fold 为 true 时再转为小写。
+// 索引中已有的备注保持原样，比较时同样先转换为键。
+func remarkKey(remark string, fold bool) string {
+	key := NormalizeRemark(remark)
+	if fold {
+		key = strings.ToLower(key)
+	}
+	return key
+}
+
+// findRemark 返回与 remark 视为相同的已有备注所属的备份 ID。
+func findRemark(remarks map[string]string, remark string, fold bool) (string, bool) {
+	if id, ok := remarks[remark]; ok {
+		return id, true
+	}
+	key := remarkKey(remark, fold)
+	for existing, id := range remarks {
+		if remarkKey(existing, fold) == key {
+			return id, true
+		}
+	}
+	return "", false
+}
+
+// ValidateRemark 规范化用户输入的备注并检查长度，不合法时返回 *RemarkError；allowEmpty 为 false 时拒绝空备注。
+func (s *Service) ValidateRemark(remark string, allowEmpty bool) (string, error) {
+	remark = NormalizeRemark(remark)
+	if remark == "" && !allowEmpty {
+		return "", &RemarkError{Reason: "备注不能为空字符串"}
+	}
+	if limit := s.remarkMaxLength(); utf8.RuneCountInString(remark) > limit {
+		return "", &RemarkError{Reason: fmt.Sprintf("备注不能超过 %d 个字符", limit)}
+	}
+	return remark, nil
+}
+
+func (s *Service) remarkMaxLength() int {
+	if s.cfg.RemarkMaxLength <= 0 {
+		return defaultRemarkMaxLength
+	}
+	return s.cfg.RemarkMaxLength
+}
@@ -0,0 +1,201 @@
+package core
+
+import (
+	"errors"
+	"os"
+	"sort"
+	"time"
+
+	"codex-backup-tool/internal/util"
+)
+
+// retentionReason 为保留策略删除备份时 backup.deleted 事件的 Reason。
+const retentionReason = "retention"
+
+// PruneResult 为一次 Prune 的结果。
+type PruneResult struct {
+	// Deleted 为被删除的备份 ID，预演时为空。
+	Deleted []string `json:"deleted"`
+	// Skipped 为按策略应删除但备份文件已缺失、本次未处理的备份 ID。
+	Skipped []string `json:"skipped,omitempty"`
+	// Items 为被删除（预演时为将被删除）的备份，从最旧的开始。
+	Items []PruneCandidate `json:"items"`
+	// BytesReclaimed 为 Items 的 Size 之和。
+	BytesReclaimed int64 `json:"bytes_reclaimed"`
+	// DryRun 表示本次只是预演，未修改索引，也未删除文件。
+	DryRun bool `json:"dry_run"`
+}
+
+// PrunePolicy 为选择待清理备份的规则，零值字段表示不启用对应规则。
+type PrunePolicy struct {
+	// KeepAuto 大于 0 时只保留最新的这么多个自动备份。
+	KeepAuto int
+	// OlderThan 大于 0 时清理创建时间早于 Now 减去该时长的自动备份。
+	OlderThan time.Duration
+	// MaxTotalBytes 大于 0 时，全部备份的 Size 之和超过该值则从最旧的自动备份开始清理，直到不再超出。
+	MaxTotalBytes int64
+	// Now 为判断 OlderThan 的当前时间，为零时取 time.Now()。
+	Now time.Time
+}
+
+// enabled 表示至少启用了一条规则。
+func (p PrunePolicy) enabled() bool {
+	return p.KeepAuto > 0 || p.OlderThan > 0 || p.MaxTotalBytes > 0
+}
+
+// PruneCandidate 为按规则选中的待清理备份及所依据的规则名。
+type PruneCandidate struct {
+	BackupItem
+	Policy string `json:"policy"`
+}
+
+// retentionEnabled 表示配置了 max_auto_backups、auto_backup_max_age_days 或 max_backups_total_bytes。
+func (s *Service) retentionEnabled() bool {
+	return s.retentionPolicy().enabled()
+}
+
+// retentionPolicy 返回配置中的保留策略。
+func (s *Service) retentionPolicy() PrunePolicy {
+	return PrunePolicy{
+		KeepAuto:      s.cfg.MaxAutoBackups,
+		OlderThan:     s.cfg.AutoBackupMaxAge,
+		MaxTotalBytes: s.cfg.MaxBackupsTotalBytes,
+	}
+}
+
+// 保留策略的名称，写入删除时的日志与 PruneCandidate.Policy。
+const (
+	policyMaxCount   = "max_auto_backups"
+	policyMaxAge     = "auto_backup_max_age_days"
+	policyTotalBytes = "max_backups_total_bytes"
+)
+
+// SelectPruneCandidates 按 policy 从 items 中选出应清理的备份，按创建时间从旧到新返回，不修改 items。
+// 规则依次为：创建时间早于 OlderThan 的自动备份、自动备份中超出 KeepAuto 的最旧者，
+// 以及剩余备份的 Size 之和仍超过 MaxTotalBytes 时从最旧开始的自动备份。
+// 手动备份计入总大小但不计数，也不会被选中；最新的备份总会保留，避免目标文件长期未变化时丢失当前内容的唯一备份。
+func SelectPruneCandidates(items []BackupItem, policy PrunePolicy) []PruneCandidate {
+	out := []PruneCandidate{}
+	if len(items) == 0 || !policy.enabled() {
+		return out
+	}
+	all := append([]BackupItem(nil), items...)
+	sort.SliceStable(all, func(i, j int) bool { return newerFirst(&all[i], &all[j]) })
+	newest := all[0].ID
+	var cutoff time.Time
+	if policy.OlderThan > 0 {
+		now := policy.Now
+		if now.IsZero() {
+			now = time.Now()
+		}
+		cutoff = now.Add(-policy.OlderThan)
+	}
+	// policies 记录每个待删除备份所依据的规则；all 按创建时间倒序，计数时自动备份中排在 KeepAuto 之后的都是更旧的。
+	policies := make(map[string]string)
+	var total int64
+	autoSeen := 0
+	for _, item := range all {
+		total += item.Size
+		if !item.IsAuto {
+			continue
+		}
+		autoSeen++
+		if item.ID == newest {
+			continue
+		}
+		switch {
+		case !cutoff.IsZero() && item.CreatedAt.Before(cutoff):
+			policies[item.ID] = policyMaxAge
+		case policy.KeepAuto > 0 && autoSeen > policy.KeepAuto:
+			policies[item.ID] = policyMaxCount
+		}
+	}
+	if policy.MaxTotalBytes > 0 {
+		for _, item := range all {
+			if _, ok := policies[item.ID]; ok {
+				total -= item.Size
+			}
+		}
+		for i := len(all) - 1; i >= 0 && total > policy.MaxTotalBytes; i-- {
+			item := all[i]
+			if _, ok := policies[item.ID]; ok || !item.IsAuto || item.ID == newest {
+				continue
+			}
+			policies[item.ID] = policyTotalBytes
+			total -= item.Size
+		}
+	}
+	for i := len(all) - 1; i >= 0; i-- {
+		if name, ok := policies[all[i].ID]; ok {
+			out = append(out, PruneCandidate{BackupItem: all[i], Policy: name})
+		}
+	}
+	return out
+}
+
+// Prune 按配置的保留策略（max_auto_backups、auto_backup_max_age_days、max_backups_total_bytes）清理自动备份，
+// 选择规则见 SelectPruneCandidates。
+func (s *Service) Prune() (*PruneResult, error) {
+	return s.PruneWithPolicy(s.retentionPolicy(), false)
+}
+
+// PruneWithPolicy 按 policy 清理自动备份；dryRun 为 true 时只返回将被删除的备份，不修改索引，也不删除文件。
+// 删除从最旧的开始，走与 DeleteBackup 相同的路径（同时维护 LatestFingerprint）；
+// 备份文件已缺失的条目计入 Skipped，不中断本次清理。
+func (s *Service) PruneWithPolicy(policy PrunePolicy, dryRun bool) (*PruneResult, error) {
+	if err := s.RequireCapability(CapPrune); err != nil {
+		return nil, err
+	}
+	res := &PruneResult{Deleted: []string{}, Items: []PruneCandidate{}, DryRun: dryRun}
+	if !policy.enabled() {
+		return res, nil
+	}
+	all, err := s.store.ListBackups()
+	if err != nil {
+		return nil, err
+	}
+	// 中途失败时保留的总是较新的备份。
+	for _, c := range SelectPruneCandidates(all, policy) {
+		item := c.BackupItem
+		if s.backupFileMissing(&item) {
+			s.logger.Warn("保留策略跳过备份文件已缺失的自动备份", "id", item.ID, "remark", item.Remark)
+			res.Skipped = append(res.Skipped, item.ID)
+			continue
+		}
+		if !dryRun {
+			if _, err := s.removeBackup(item.ID, retentionReason); err != nil {
+				if errors.Is(err, ErrBackupNotFound) {
+					continue
+				}
+				return res, err
+			}
+			s.logger.Info("保留策略删除自动备份", "id", item.ID, "remark", item.Remark, "policy", c.Policy)
+			res.Deleted = append(res.Deleted, item.ID)
+		}
+		res.Items = append(res.Items, c)
+		res.BytesReclaimed += item.Size
+	}
+	return res, nil
+}
+
+// backupFileMissing 判断备份文件是否已不存在；文件名不安全或无法判断时按存在处理，交由删除路径记录。
+func (s *Service) backupFileMissing(item *BackupItem) bool {
+	path, err := util.SecureJoin(s.cfg.BackupsDir, item.Filename)
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(path)
+	return errors.Is(err, os.ErrNotExist)
+}
+
+// autoPrune 在自动扫描、新建备份后与启动时执行保留策略，错误只记录日志。
+func (s *Service) autoPrune() {
+	if !s.retentionEnabled() {
+		return
+	}
+	if _, err := s.Prune(); errors.Is(err, ErrCapabilityDisabled) {
+		s.logger.Warn("已配置自动备份保留策略，但 prune 能力已关闭，跳过清理")
+	} else if err != nil {
+		s.logger.Error("执行自动备份保留策略失败", "error", err)
+	}
+}
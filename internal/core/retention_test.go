@@ -0,0 +1,95 @@
+package core_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"codex-backup-tool/internal/core"
+)
+
+func TestSelectPruneCandidates(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	item := func(id string, ageDays int, auto bool, size int64) core.BackupItem {
+		return core.BackupItem{ID: id, IsAuto: auto, Size: size, CreatedAt: now.Add(-time.Duration(ageDays) * 24 * time.Hour)}
+	}
+	// 故意打乱顺序，SelectPruneCandidates 应自行按创建时间排序。
+	items := []core.BackupItem{
+		item("a3", 3, true, 10),
+		item("m5", 5, false, 40),
+		item("a1", 1, true, 10),
+		item("a10", 10, true, 10),
+		item("a40", 40, true, 10),
+		item("a0", 0, true, 10),
+	}
+	cases := []struct {
+		name   string
+		items  []core.BackupItem
+		policy core.PrunePolicy
+		want   string
+	}{
+		{"no items", nil, core.PrunePolicy{KeepAuto: 1}, ""},
+		{"no policy", items, core.PrunePolicy{Now: now}, ""},
+		{"keep auto", items, core.PrunePolicy{KeepAuto: 2, Now: now}, "a40:max_auto_backups,a10:max_auto_backups,a3:max_auto_backups"},
+		{"keep auto above count", items, core.PrunePolicy{KeepAuto: 10, Now: now}, ""},
+		{"older than", items, core.PrunePolicy{OlderThan: 7 * 24 * time.Hour, Now: now}, "a40:auto_backup_max_age_days,a10:auto_backup_max_age_days"},
+		{"older than wins over keep", items, core.PrunePolicy{KeepAuto: 2, OlderThan: 30 * 24 * time.Hour, Now: now}, "a40:auto_backup_max_age_days,a10:max_auto_backups,a3:max_auto_backups"},
+		{"total bytes", items, core.PrunePolicy{MaxTotalBytes: 65, Now: now}, "a40:max_backups_total_bytes,a10:max_backups_total_bytes,a3:max_backups_total_bytes"},
+		{"total bytes after other rules", items, core.PrunePolicy{OlderThan: 7 * 24 * time.Hour, MaxTotalBytes: 70, Now: now}, "a40:auto_backup_max_age_days,a10:auto_backup_max_age_days"},
+		{"total bytes cannot go below manual", items, core.PrunePolicy{MaxTotalBytes: 1, Now: now}, "a40:max_backups_total_bytes,a10:max_backups_total_bytes,a3:max_backups_total_bytes,a1:max_backups_total_bytes"},
+		{"newest auto kept", []core.BackupItem{item("a0", 40, true, 10), item("a1", 50, true, 10)}, core.PrunePolicy{OlderThan: time.Hour, Now: now}, "a1:auto_backup_max_age_days"},
+		{"newest manual kept", []core.BackupItem{item("m0", 40, false, 10), item("a1", 50, true, 10)}, core.PrunePolicy{OlderThan: time.Hour, Now: now}, "a1:auto_backup_max_age_days"},
+		{"manual never selected", []core.BackupItem{item("a0", 0, true, 10), item("m1", 50, false, 10)}, core.PrunePolicy{KeepAuto: 1, OlderThan: time.Hour, MaxTotalBytes: 1, Now: now}, ""},
+	}
+	for _, tc := range cases {
+		before := append([]core.BackupItem(nil), tc.items...)
+		var got []string
+		for _, c := range core.SelectPruneCandidates(tc.items, tc.policy) {
+			got = append(got, c.ID+":"+c.Policy)
+		}
+		if strings.Join(got, ",") != tc.want {
+			t.Fatalf("%s: expected %q, got %q", tc.name, tc.want, strings.Join(got, ","))
+		}
+		for i := range before {
+			if tc.items[i].ID != before[i].ID {
+				t.Fatalf("%s: input items were reordered", tc.name)
+			}
+		}
+	}
+}
+
+func TestPruneWithPolicyDryRun(t *testing.T) {
+	svc, cleanup := newTestService(t)
+	defer cleanup()
+	var ids []string
+	for _, c := range "abc" {
+		writeTarget(t, svc, `{"token":"`+string(c)+`"}`)
+		res, err := svc.Scan(true, nil)
+		if err != nil || !res.Created {
+			t.Fatalf("auto backup %c: %+v (%v)", c, res, err)
+		}
+		ids = append(ids, res.Item.ID)
+	}
+
+	res, err := svc.PruneWithPolicy(core.PrunePolicy{KeepAuto: 1}, true)
+	if err != nil {
+		t.Fatalf("dry run: %v", err)
+	}
+	if !res.DryRun || len(res.Deleted) != 0 || len(res.Items) != 2 || res.Items[0].ID != ids[0] || res.Items[1].ID != ids[1] {
+		t.Fatalf("unexpected dry run result: %+v", res)
+	}
+	if want := res.Items[0].Size + res.Items[1].Size; res.BytesReclaimed != want {
+		t.Fatalf("expected %d bytes reclaimed, got %d", want, res.BytesReclaimed)
+	}
+	if items, err := svc.ListBackups(); err != nil || len(items) != 3 {
+		t.Fatalf("dry run must not delete backups, got %d (%v)", len(items), err)
+	}
+
+	res, err = svc.PruneWithPolicy(core.PrunePolicy{KeepAuto: 1}, false)
+	if err != nil || res.DryRun || strings.Join(res.Deleted, ",") != ids[0]+","+ids[1] {
+		t.Fatalf("unexpected prune result: %+v (%v)", res, err)
+	}
+	if items, err := svc.ListBackups(); err != nil || len(items) != 1 || items[0].ID != ids[2] {
+		t.Fatalf("expected only the newest backup to remain, got %+v (%v)", items, err)
+	}
+}
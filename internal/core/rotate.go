@@ -0,0 +1,187 @@
+package core
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+
+	"codex-backup-tool/internal/util"
+)
+
+// ErrInvalidRotation 在轮换口令的参数不完整或新旧口令相同时返回。
+var ErrInvalidRotation = errors.New("invalid encryption rotation")
+
+// RotateOptions 为 RotateEncryption 的参数。DryRun 时只验证旧口令能解密全部尚未轮换的备份，不修改任何文件，NewPassphrase 可以为空。
+type RotateOptions struct {
+	OldPassphrase util.SecretString `json:"old_passphrase"`
+	NewPassphrase util.SecretString `json:"new_passphrase"`
+	DryRun        bool              `json:"dry_run"`
+}
+
+// RotateResult 为 RotateEncryption 的结果。
+type RotateResult struct {
+	DryRun bool `json:"dry_run"`
+	// KeyID 为新口令的密钥 ID，DryRun 且未提供新口令时为空。
+	KeyID string `json:"key_id,omitempty"`
+	// Total 为加密备份的总数。
+	Total int `json:"total"`
+	// Rotated 为本次重新加密的备份 ID，DryRun 时为已验证、将被重新加密的备份。
+	Rotated []string `json:"rotated"`
+	// AlreadyRotated 为此前中断的轮换中已改用新口令的备份 ID，本次跳过。
+	AlreadyRotated []string `json:"already_rotated"`
+	// KeyringUpdated 表示口令来自系统凭据存储，且已写入新口令。
+	KeyringUpdated bool `json:"keyring_updated,omitempty"`
+	// ConfigUpdateRequired 表示口令来自配置文件、口令文件或环境变量，重启前需手动改为新口令。
+	ConfigUpdateRequired bool `json:"config_update_required,omitempty"`
+}
+
+// RotateEncryption 用新口令重新加密全部加密备份：逐个解密、按 ContentHash 校验明文，再以新口令加密写入临时文件并原子替换，
+// 每完成一个就更新索引中的盐与密钥 ID，进度因此随索引持久化。中断后以相同参数重新执行即可继续，
+// 文件头已是新密钥的备份直接跳过（索引尚未更新时一并补上）。轮换期间与中断后，新旧密钥的备份可以并存，
+// 只有与当前口令密钥 ID 相同的备份可以读取。完成后服务立即改用新口令；口令来自系统凭据存储时同时写入新口令。
+// 未启用加密时返回 ErrEncryptionKeyMissing，旧口令无法解密某个备份或校验失败时返回 ErrDecryptFailed，已完成的部分保留。
+func (s *Service) RotateEncryption(opts RotateOptions) (*RotateResult, error) {
+	if opts.OldPassphrase == "" {
+		return nil, fmt.Errorf("%w: 需要旧口令", ErrInvalidRotation)
+	}
+	if opts.NewPassphrase == "" && !opts.DryRun {
+		return nil, fmt.Errorf("%w: 需要新口令", ErrInvalidRotation)
+	}
+	if opts.NewPassphrase == opts.OldPassphrase {
+		return nil, fmt.Errorf("%w: 新旧口令相同", ErrInvalidRotation)
+	}
+	if s.cipher() == nil {
+		return nil, ErrEncryptionKeyMissing
+	}
+	oldCipher, err := newBackupCipher(opts.OldPassphrase.Reveal())
+	if err != nil {
+		return nil, err
+	}
+	res := &RotateResult{DryRun: opts.DryRun, Rotated: []string{}, AlreadyRotated: []string{}}
+	var newCipher *backupCipher
+	if opts.NewPassphrase != "" {
+		if newCipher, err = newBackupCipher(opts.NewPassphrase.Reveal()); err != nil {
+			return nil, err
+		}
+		if res.KeyID, err = newCipher.KeyID(); err != nil {
+			return nil, fmt.Errorf("派生密钥 ID: %w", err)
+		}
+	}
+
+	// 持有 scanMu，轮换期间不会有新的备份以旧口令写入。
+	s.scanMu.Lock()
+	defer s.scanMu.Unlock()
+	idx, err := s.store.Snapshot()
+	if err != nil {
+		return nil, err
+	}
+	for i := range idx.Items {
+		item := &idx.Items[i]
+		if !item.Encrypted {
+			continue
+		}
+		res.Total++
+		path, err := util.SecureJoin(s.cfg.BackupsDir, item.Filename)
+		if err != nil {
+			return res, fmt.Errorf("备份文件名: %w", err)
+		}
+		data, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			return res, fmt.Errorf("%w: %s", ErrBackupFileMissing, item.Filename)
+		}
+		if err != nil {
+			return res, fmt.Errorf("读取备份文件: %w", err)
+		}
+		if h, ok := parseEncryptedHeader(data); ok && newCipher != nil && h.KeyID == res.KeyID {
+			if _, err := s.verifyRotated(newCipher, item, idx.HashAlgo, data); err != nil {
+				return res, err
+			}
+			if !opts.DryRun && (item.KeyID != h.KeyID || item.KDFSalt != h.Salt) {
+				if err := s.recordRotated(item.ID, encryptionInfo{Salt: h.Salt, KeyID: h.KeyID}); err != nil {
+					return res, err
+				}
+			}
+			res.AlreadyRotated = append(res.AlreadyRotated, item.ID)
+			continue
+		}
+		plain, err := s.verifyRotated(oldCipher, item, idx.HashAlgo, data)
+		if err != nil {
+			return res, err
+		}
+		if opts.DryRun {
+			res.Rotated = append(res.Rotated, item.ID)
+			continue
+		}
+		sealed, info, err := newCipher.seal(plain)
+		if err != nil {
+			return res, fmt.Errorf("加密备份内容: %w", err)
+		}
+		if err := util.AtomicWriteFile(path, sealed, 0o600); err != nil {
+			return res, fmt.Errorf("写入备份文件 %s: %w", item.Filename, err)
+		}
+		if err := s.recordRotated(item.ID, info); err != nil {
+			return res, err
+		}
+		res.Rotated = append(res.Rotated, item.ID)
+		s.logger.Debug("已用新口令重新加密备份", "id", item.ID, "key_id", info.KeyID, "done", len(res.Rotated)+len(res.AlreadyRotated))
+	}
+	if opts.DryRun {
+		return res, nil
+	}
+
+	s.encryption.Store(newCipher)
+	if key, ok := s.cfg.EncryptionKey.(KeyringKey); ok {
+		if err := key.SetPassphrase(opts.NewPassphrase.Reveal()); err != nil {
+			return res, fmt.Errorf("备份已全部改用新口令，但%w", err)
+		}
+		res.KeyringUpdated = true
+	} else {
+		res.ConfigUpdateRequired = true
+	}
+	s.logger.Info("已轮换加密口令", "key_id", res.KeyID, "rotated", len(res.Rotated), "already_rotated", len(res.AlreadyRotated))
+	return res, nil
+}
+
+// verifyRotated 用 c 解密备份文件，并按条目的 ContentHash 校验解压后的明文，返回解密后（仍可能是 gzip）的内容。
+func (s *Service) verifyRotated(c *backupCipher, item *BackupItem, indexAlgo string, data []byte) ([]byte, error) {
+	plain, err := c.open(item.KDFSalt, data)
+	if err != nil {
+		return nil, fmt.Errorf("解密备份文件 %s: %w", item.Filename, err)
+	}
+	content, err := decodeBackupData(item.Filename, plain)
+	if err != nil {
+		return nil, err
+	}
+	sum, err := newContentHash(effectiveHashAlgo(item.HashAlgo, indexAlgo))
+	if err != nil {
+		return nil, err
+	}
+	sum.Write(content)
+	if hex.EncodeToString(sum.Sum(nil)) != item.ContentHash {
+		return nil, fmt.Errorf("%w: 备份文件 %s 解密后的内容哈希不符", ErrDecryptFailed, item.Filename)
+	}
+	return plain, nil
+}
+
+// recordRotated 将重新加密后的盐与密钥 ID 写入索引条目。
+func (s *Service) recordRotated(id string, info encryptionInfo) error {
+	_, err := s.store.updateItem(id, func(item *BackupItem) error {
+		if item.KDFSalt == info.Salt && item.KeyID == info.KeyID {
+			return errNoChange
+		}
+		item.KDFSalt, item.KeyID = info.Salt, info.KeyID
+		return nil
+	})
+	return err
+}
+
+// EncryptionKeyID 返回当前口令的密钥 ID，未启用加密时为空。
+func (s *Service) EncryptionKeyID() string {
+	c := s.cipher()
+	if c == nil {
+		return ""
+	}
+	id, _ := c.KeyID()
+	return id
+}
@@ -0,0 +1,116 @@
+package core_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"codex-backup-tool/internal/core"
+	"codex-backup-tool/internal/util"
+)
+
+func TestRotateEncryption(t *testing.T) {
+	base := t.TempDir()
+	open := func(passphrase string) (*core.Service, func()) {
+		return newTestService(t, func(cfg *core.Config) {
+			cfg.TargetPath = filepath.Join(base, "codex", "auth.json")
+			cfg.DataDir = filepath.Join(base, "data")
+			cfg.BackupsDir = filepath.Join(base, "data", "backups")
+			cfg.IndexPath = filepath.Join(base, "data", "index.json")
+			cfg.Compression = core.CompressionGzip
+			cfg.EncryptionPassphrase = util.SecretString(passphrase)
+		})
+	}
+	svc, cleanup := open("old passphrase")
+	var ids []string
+	for _, content := range []string{`{"access_token":"sk-first"}`, `{"access_token":"sk-second"}`} {
+		writeTarget(t, svc, content)
+		res, err := svc.CreateBackup(nil)
+		if err != nil || !res.Item.Encrypted || res.Item.KeyID == "" {
+			t.Fatalf("create: %+v (%v)", res, err)
+		}
+		if res.Item.KeyID != svc.EncryptionKeyID() {
+			t.Fatalf("expected item key id %q to match the service, got %q", svc.EncryptionKeyID(), res.Item.KeyID)
+		}
+		ids = append(ids, res.Item.ID)
+	}
+	oldKeyID := svc.EncryptionKeyID()
+	second, _ := svc.GetBackup(ids[1])
+	secondPath := filepath.Join(svc.Config().BackupsDir, second.Filename)
+	original, err := os.ReadFile(secondPath)
+	if err != nil {
+		t.Fatalf("read backup: %v", err)
+	}
+
+	for _, opts := range []core.RotateOptions{
+		{NewPassphrase: "new passphrase"},
+		{OldPassphrase: "old passphrase"},
+		{OldPassphrase: "old passphrase", NewPassphrase: "old passphrase"},
+	} {
+		if _, err := svc.RotateEncryption(opts); !errors.Is(err, core.ErrInvalidRotation) {
+			t.Fatalf("%+v: expected ErrInvalidRotation, got %v", opts, err)
+		}
+	}
+	if _, err := svc.RotateEncryption(core.RotateOptions{OldPassphrase: "wrong", DryRun: true}); !errors.Is(err, core.ErrDecryptFailed) {
+		t.Fatalf("expected dry run with a wrong passphrase to fail, got %v", err)
+	}
+	dry, err := svc.RotateEncryption(core.RotateOptions{OldPassphrase: "old passphrase", DryRun: true})
+	if err != nil || dry.Total != 2 || len(dry.Rotated) != 2 {
+		t.Fatalf("dry run: %+v (%v)", dry, err)
+	}
+	if data, _ := os.ReadFile(secondPath); !bytes.Equal(data, original) {
+		t.Fatalf("dry run must not rewrite backups")
+	}
+
+	// 第二个备份无法解密时轮换中断，第一个已改用新口令，索引中新旧密钥并存。
+	if err := os.WriteFile(secondPath, []byte("garbage"), 0o600); err != nil {
+		t.Fatalf("corrupt backup: %v", err)
+	}
+	opts := core.RotateOptions{OldPassphrase: "old passphrase", NewPassphrase: "new passphrase"}
+	partial, err := svc.RotateEncryption(opts)
+	if !errors.Is(err, core.ErrDecryptFailed) || partial == nil || len(partial.Rotated) != 1 {
+		t.Fatalf("expected interrupted rotation, got %+v (%v)", partial, err)
+	}
+	first, _ := svc.GetBackup(ids[0])
+	if first.KeyID != partial.KeyID || first.KeyID == oldKeyID {
+		t.Fatalf("expected the first backup to record the new key id, got %q", first.KeyID)
+	}
+	if _, err := svc.RestoreBackup(ids[0]); !errors.Is(err, core.ErrDecryptFailed) {
+		t.Fatalf("expected the rotated backup to be unreadable with the old passphrase, got %v", err)
+	}
+
+	if err := os.WriteFile(secondPath, original, 0o600); err != nil {
+		t.Fatalf("restore backup file: %v", err)
+	}
+	res, err := svc.RotateEncryption(opts)
+	if err != nil || len(res.AlreadyRotated) != 1 || res.AlreadyRotated[0] != ids[0] || len(res.Rotated) != 1 || res.Rotated[0] != ids[1] {
+		t.Fatalf("expected the rotation to resume, got %+v (%v)", res, err)
+	}
+	if !res.ConfigUpdateRequired || svc.EncryptionKeyID() != res.KeyID {
+		t.Fatalf("expected the service to switch to the new key, got %+v key=%s", res, svc.EncryptionKeyID())
+	}
+	for _, id := range ids {
+		if _, err := svc.RestoreBackup(id); err != nil {
+			t.Fatalf("restore %s after rotation: %v", id, err)
+		}
+	}
+	writeTarget(t, svc, `{"access_token":"sk-third"}`)
+	if third, err := svc.CreateBackup(nil); err != nil || third.Item.KeyID != res.KeyID {
+		t.Fatalf("expected new backups to use the new key, got %+v (%v)", third, err)
+	}
+	cleanup()
+
+	svc, cleanup = open("new passphrase")
+	defer cleanup()
+	for _, id := range ids {
+		if _, err := svc.RestoreBackup(id); err != nil {
+			t.Fatalf("restore %s with the new passphrase: %v", id, err)
+		}
+	}
+	stored, _ := os.ReadFile(secondPath)
+	if bytes.Equal(stored, original) {
+		t.Fatalf("expected the backup file to be re-encrypted")
+	}
+}
@@ -0,0 +1,101 @@
+package core
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"sync"
+	"time"
+
+	"codex-backup-tool/internal/util"
+)
+
+// 自动扫描错误的分类。missing 与 permission 通常需要人工处理，io 多为暂时性故障，其余归为 internal。
+const (
+	ScanErrorMissing    = "missing"
+	ScanErrorPermission = "permission"
+	ScanErrorIO         = "io"
+	ScanErrorInternal   = "internal"
+)
+
+// scanErrorSummaryInterval 为同一错误持续出现时输出汇总日志的间隔。
+const scanErrorSummaryInterval = time.Hour
+
+// ClassifyScanError 返回扫描错误所属的分类，err 为 nil 时返回空字符串。
+func ClassifyScanError(err error) string {
+	var pathErr *fs.PathError
+	var sysErr *os.SyscallError
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, fs.ErrNotExist):
+		return ScanErrorMissing
+	case errors.Is(err, fs.ErrPermission):
+		return ScanErrorPermission
+	case errors.As(err, &pathErr), errors.As(err, &sysErr), errors.Is(err, io.ErrUnexpectedEOF):
+		return ScanErrorIO
+	default:
+		return ScanErrorInternal
+	}
+}
+
+// scanErrorLog 对自动扫描的连续错误去重：同一错误只在首次出现、变为其他错误或恢复时输出日志，
+// 持续期间每 scanErrorSummaryInterval 输出一次被抑制的次数。
+type scanErrorLog struct {
+	mu    sync.Mutex
+	class string
+	msg   string
+	// count 为当前错误连续出现的次数，suppressed 为上次输出日志后被抑制的次数。
+	count      int
+	suppressed int
+	loggedAt   time.Time
+}
+
+// scanErrorLine 为 observe 返回的一条日志，msg 为空表示无需输出；recovered 为 true 时是恢复通知。
+type scanErrorLine struct {
+	msg       string
+	fields    []any
+	recovered bool
+}
+
+// observe 记录一次自动扫描的结果，返回需要输出的日志；first 表示这是一个新出现的错误。
+func (l *scanErrorLog) observe(err error, now time.Time) (line scanErrorLine, first bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err == nil {
+		if l.class == "" {
+			return line, false
+		}
+		line = scanErrorLine{msg: "Auto scan recovered", fields: []any{"class", l.class, "occurrences", l.count}, recovered: true}
+		l.class, l.msg, l.count, l.suppressed = "", "", 0, 0
+		return line, false
+	}
+	class, msg := ClassifyScanError(err), util.Redact(err.Error())
+	if class == l.class && msg == l.msg {
+		l.count++
+		l.suppressed++
+		if now.Sub(l.loggedAt) < scanErrorSummaryInterval {
+			return line, false
+		}
+		line = scanErrorLine{msg: "Auto scan error persists", fields: []any{"class", class, "error", msg, "suppressed", l.suppressed}}
+		l.suppressed = 0
+		l.loggedAt = now
+		return line, false
+	}
+	line = scanErrorLine{msg: "Auto scan error", fields: []any{"class", class, "error", msg}}
+	if l.suppressed > 0 {
+		line.fields = append(line.fields, "previous_class", l.class, "previous_suppressed", l.suppressed)
+	}
+	l.class, l.msg, l.count, l.suppressed = class, msg, 1, 0
+	l.loggedAt = now
+	return line, true
+}
+
+// fill 将当前连续错误的分类与次数写入 StatusInfo。
+func (l *scanErrorLog) fill(status *StatusInfo) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	status.ScanErrorClass = l.class
+	status.ScanErrorCount = l.count
+}
@@ -0,0 +1,129 @@
+package core_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"codex-backup-tool/internal/core"
+)
+
+func TestClassifyScanError(t *testing.T) {
+	cases := []struct {
+		err  error
+		want string
+	}{
+		{nil, ""},
+		{fmt.Errorf("stat target: %w", &fs.PathError{Op: "stat", Path: "x", Err: fs.ErrNotExist}), core.ScanErrorMissing},
+		{fmt.Errorf("open: %w", fs.ErrPermission), core.ScanErrorPermission},
+		{fmt.Errorf("stat target: %w", &fs.PathError{Op: "stat", Path: "x", Err: errors.New("not a directory")}), core.ScanErrorIO},
+		{fmt.Errorf("read: %w", io.ErrUnexpectedEOF), core.ScanErrorIO},
+		{errors.New("index corrupted"), core.ScanErrorInternal},
+	}
+	for _, tc := range cases {
+		if got := core.ClassifyScanError(tc.err); got != tc.want {
+			t.Errorf("ClassifyScanError(%v) = %q, want %q", tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestAutoScanErrorsAreDeduplicated(t *testing.T) {
+	base := t.TempDir()
+	dataDir := filepath.Join(base, "data")
+	backupsDir := filepath.Join(dataDir, "backups")
+	var logs bytes.Buffer
+	svc, err := core.NewService(core.Config{
+		TargetPath:   filepath.Join(base, "codex", "auth.json"),
+		DataDir:      dataDir,
+		BackupsDir:   backupsDir,
+		IndexPath:    filepath.Join(dataDir, "index.json"),
+		ScanInterval: 10 * time.Millisecond,
+		Port:         "0",
+	}, core.NewJSONLogger(&logs, slog.LevelInfo))
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	writeTarget(t, svc, `{"token":"alpha"}`)
+	// 备份目录被替换为普通文件，每次扫描都会以同一个错误失败。
+	if err := os.RemoveAll(backupsDir); err != nil {
+		t.Fatalf("remove backups dir: %v", err)
+	}
+	if err := os.WriteFile(backupsDir, []byte("not a dir"), 0o600); err != nil {
+		t.Fatalf("write backups file: %v", err)
+	}
+	svc.Start(context.Background())
+
+	var status *core.StatusInfo
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		status, err = svc.Status()
+		if err != nil {
+			svc.Stop()
+			t.Fatalf("status: %v", err)
+		}
+		if status.ScanErrorCount >= 5 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if status.ScanErrorClass != core.ScanErrorIO || status.ScanErrorCount < 5 {
+		svc.Stop()
+		t.Fatalf("expected repeated io errors in status, got class=%q count=%d", status.ScanErrorClass, status.ScanErrorCount)
+	}
+
+	if err := os.Remove(backupsDir); err != nil {
+		t.Fatalf("remove backups file: %v", err)
+	}
+	deadline = time.Now().Add(5 * time.Second)
+	for {
+		status, err = svc.Status()
+		if err != nil {
+			svc.Stop()
+			t.Fatalf("status: %v", err)
+		}
+		if status.ScanErrorClass == "" || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	svc.Stop()
+	if status.ScanErrorClass != "" || status.ScanErrorCount != 0 {
+		t.Fatalf("expected error state to clear after recovery, got class=%q count=%d", status.ScanErrorClass, status.ScanErrorCount)
+	}
+	errorLines, recovered := 0, false
+	for _, line := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+		var entry struct {
+			Level string `json:"level"`
+			Msg   string `json:"msg"`
+			Class string `json:"class"`
+		}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("log line is not JSON: %q (%v)", line, err)
+		}
+		switch entry.Msg {
+		case "Auto scan error":
+			errorLines++
+			if entry.Level != "ERROR" || entry.Class != core.ScanErrorIO {
+				t.Fatalf("unexpected error line: %s", line)
+			}
+		case "Auto scan recovered":
+			recovered = entry.Class == core.ScanErrorIO
+		}
+	}
+	if errorLines != 1 {
+		t.Fatalf("expected a single error line, got %d:\n%s", errorLines, logs.String())
+	}
+	if !recovered {
+		t.Fatalf("expected recovery line, got:\n%s", logs.String())
+	}
+}
@@ -0,0 +1,156 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"codex-backup-tool/internal/util"
+)
+
+const (
+	// scanHistoryFile 保存扫描历史，服务停止时以及每新增 scanHistorySaveEvery 条时写入。
+	scanHistoryFile      = "scan-history.json"
+	scanHistorySaveEvery = 20
+	// 默认在内存中保留的扫描记录数与写入文件的记录数。
+	defaultScanHistorySize    = 200
+	defaultScanHistoryPersist = 100
+)
+
+// ScanRecord 为一次扫描的结果摘要。PreviousRun 为 true 表示记录来自此前的进程，
+// 不代表当前状态。
+type ScanRecord struct {
+	Time        time.Time          `json:"ts"`
+	Trigger     string             `json:"trigger"`
+	Created     bool               `json:"created"`
+	ItemID      string             `json:"item_id,omitempty"`
+	Code        string             `json:"code,omitempty"`
+	Error       string             `json:"error,omitempty"`
+	DurationsMs map[string]float64 `json:"durations_ms,omitempty"`
+	PreviousRun bool               `json:"previous_run"`
+}
+
+// scanHistory 为最近扫描记录的环形缓冲，写入文件时只保留最新的 persist 条。
+type scanHistory struct {
+	path    string
+	size    int
+	persist int
+
+	mu      sync.Mutex
+	records []ScanRecord
+	unsaved int
+}
+
+// newScanHistory 创建扫描历史并载入此前进程保存的记录。文件损坏时只返回错误供记录日志，
+// 历史从空开始，不影响启动。
+func newScanHistory(path string, size, persist int) (*scanHistory, error) {
+	if size <= 0 {
+		size = defaultScanHistorySize
+	}
+	if persist <= 0 {
+		persist = defaultScanHistoryPersist
+	}
+	h := &scanHistory{path: path, size: size, persist: persist}
+	data, err := os.ReadFile(util.LongPath(path))
+	if os.IsNotExist(err) {
+		return h, nil
+	}
+	if err != nil {
+		return h, fmt.Errorf("read scan history: %w", err)
+	}
+	var records []ScanRecord
+	if err := json.Unmarshal(data, &records); err != nil {
+		return h, fmt.Errorf("parse scan history: %w", err)
+	}
+	if len(records) > size {
+		records = records[len(records)-size:]
+	}
+	for i := range records {
+		records[i].PreviousRun = true
+	}
+	h.records = records
+	return h, nil
+}
+
+// add 追加一条记录，累计 scanHistorySaveEvery 条未保存的记录时写入文件。
+func (h *scanHistory) add(rec ScanRecord) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.records = append(h.records, rec)
+	if len(h.records) > h.size {
+		h.records = append([]ScanRecord(nil), h.records[len(h.records)-h.size:]...)
+	}
+	h.unsaved++
+	if h.unsaved < scanHistorySaveEvery {
+		return nil
+	}
+	return h.saveLocked()
+}
+
+// save 在有未保存的记录时写入文件。
+func (h *scanHistory) save() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.unsaved == 0 {
+		return nil
+	}
+	return h.saveLocked()
+}
+
+func (h *scanHistory) saveLocked() error {
+	records := h.records
+	if len(records) > h.persist {
+		records = records[len(records)-h.persist:]
+	}
+	data, err := json.Marshal(records)
+	if err != nil {
+		return fmt.Errorf("marshal scan history: %w", err)
+	}
+	if err := util.AtomicWriteFile(h.path, data, 0o600); err != nil {
+		return fmt.Errorf("write scan history: %w", err)
+	}
+	h.unsaved = 0
+	return nil
+}
+
+// recent 返回最新的 limit 条记录，新的在前。
+func (h *scanHistory) recent(limit int) []ScanRecord {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	n := min(limit, len(h.records))
+	out := make([]ScanRecord, 0, n)
+	for i := len(h.records) - 1; i >= len(h.records)-n; i-- {
+		out = append(out, h.records[i])
+	}
+	return out
+}
+
+// reset 清空内存中的记录，由清空数据时调用。
+func (h *scanHistory) reset() {
+	h.mu.Lock()
+	h.records = nil
+	h.unsaved = 0
+	h.mu.Unlock()
+}
+
+func (s *Service) recordScanHistory(trigger string, res *ScanResult, err error, durations map[string]float64) {
+	rec := ScanRecord{Time: time.Now().UTC(), Trigger: trigger, DurationsMs: durations}
+	if err != nil {
+		rec.Error = util.Redact(err.Error())
+	} else if res != nil {
+		rec.Created, rec.Code = res.Created, res.Code
+		if res.Item != nil {
+			rec.ItemID = res.Item.ID
+		}
+	}
+	if err := s.scanHistory.add(rec); err != nil {
+		s.logger.Error("保存扫描历史失败", "error", err)
+	}
+}
+
+// ScanHistory 返回最近的 limit 条扫描记录，新的在前。
+func (s *Service) ScanHistory(limit int) []ScanRecord {
+	return s.scanHistory.recent(limit)
+}
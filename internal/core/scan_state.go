@@ -0,0 +1,75 @@
+package core
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"codex-backup-tool/internal/util"
+)
+
+// 扫描的触发方式。
+const (
+	ScanTriggerAuto   = "auto"
+	ScanTriggerManual = "manual"
+)
+
+// scanState 记录最近一次扫描的结果与下一次自动扫描的时间，供 Status 展示。
+// 使用独立的锁，Status 不必等待正在进行的扫描。
+type scanState struct {
+	mu         sync.Mutex
+	lastAt     time.Time
+	trigger    string
+	reasonCode string
+	lastErr    string
+	nextAt     time.Time
+	// successAt 为最近一次创建了备份或确认内容已有备份的扫描时间。
+	successAt time.Time
+}
+
+func (st *scanState) record(trigger string, res *ScanResult, err error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.lastAt = time.Now()
+	st.trigger = trigger
+	st.reasonCode = ""
+	st.lastErr = ""
+	if err != nil {
+		st.lastErr = util.Redact(err.Error())
+	} else if res != nil {
+		st.reasonCode = res.Code
+		if res.Created || res.Code == ReasonUnchanged || res.Code == ReasonDuplicateContent {
+			st.successAt = st.lastAt
+		}
+	}
+}
+
+func (st *scanState) lastSuccess() time.Time {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.successAt
+}
+
+// arm 设置下一次自动扫描的时间，零值表示当前没有已排定的自动扫描。
+func (st *scanState) arm(next time.Time) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.nextAt = next
+}
+
+// fill 将扫描状态写入 StatusInfo。尚未扫描时时间、触发方式等字段为空字符串，
+// 没有已排定的自动扫描时 NextScanETASeconds 为 -1。
+func (st *scanState) fill(status *StatusInfo) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if !st.lastAt.IsZero() {
+		status.LastScanAt = st.lastAt.Format(time.RFC3339)
+	}
+	status.LastScanTrigger = st.trigger
+	status.LastScanReasonCode = st.reasonCode
+	status.LastScanError = st.lastErr
+	status.NextScanETASeconds = -1
+	if !st.nextAt.IsZero() {
+		status.NextScanETASeconds = int(math.Max(0, math.Ceil(time.Until(st.nextAt).Seconds())))
+	}
+}
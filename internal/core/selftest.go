@@ -0,0 +1,194 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"codex-backup-tool/internal/util"
+)
+
+// 自检项名称。
+const (
+	CheckDataDirWrite    = "data_dir_write"
+	CheckBackupsDirWrite = "backups_dir_write"
+	CheckFileLock        = "file_lock"
+	CheckTargetDir       = "target_dir"
+	CheckClock           = "clock"
+)
+
+// ErrSelfTestFailed 在启动自检存在致命失败项时由 NewService 返回。
+var ErrSelfTestFailed = errors.New("startup self-test failed")
+
+const (
+	// clockSkewTolerance 为探针文件修改时间与系统时间允许的最大偏差，网络文件系统的时钟通常不会差得更多。
+	clockSkewTolerance = 2 * time.Minute
+	selfTestLockWait   = time.Second
+)
+
+// clockFloor 早于该时间的系统时钟视为未校准，备份文件名与排序都会失真。
+var clockFloor = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// SelfTestCheck 为一项自检结果；Fatal 的失败项意味着服务无法正常工作。
+type SelfTestCheck struct {
+	Name   string `json:"name"`
+	Passed bool   `json:"passed"`
+	Fatal  bool   `json:"fatal"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// SelfTestReport 为一次自检的结果。
+type SelfTestReport struct {
+	RanAt  time.Time       `json:"ran_at"`
+	Checks []SelfTestCheck `json:"checks"`
+}
+
+// Ready 表示不存在致命失败项。
+func (r *SelfTestReport) Ready() bool {
+	for _, c := range r.Checks {
+		if !c.Passed && c.Fatal {
+			return false
+		}
+	}
+	return true
+}
+
+// Warnings 返回非致命失败项的说明，形如 "clock: ..."。
+func (r *SelfTestReport) Warnings() []string {
+	warnings := make([]string, 0)
+	for _, c := range r.Checks {
+		if !c.Passed && !c.Fatal {
+			warnings = append(warnings, c.Name+": "+c.Detail)
+		}
+	}
+	return warnings
+}
+
+// failedNames 返回失败项名称，fatalOnly 为 true 时只包含致命项。
+func (r *SelfTestReport) failedNames(fatalOnly bool) []string {
+	names := make([]string, 0)
+	for _, c := range r.Checks {
+		if !c.Passed && (c.Fatal || !fatalOnly) {
+			names = append(names, c.Name)
+		}
+	}
+	return names
+}
+
+// SelfTest 检查运行环境：数据目录与备份目录可写入/重命名/删除、文件锁可用、
+// 目标目录可访问、系统时钟与文件修改时间一致。结果会写入日志并作为最近一次自检保存。
+func (s *Service) SelfTest() *SelfTestReport {
+	report := &SelfTestReport{RanAt: time.Now().UTC(), Checks: make([]SelfTestCheck, 0, 5)}
+	add := func(name string, fatal bool, err error) {
+		check := SelfTestCheck{Name: name, Passed: err == nil, Fatal: fatal}
+		if err != nil {
+			check.Detail = err.Error()
+		}
+		report.Checks = append(report.Checks, check)
+	}
+	mtime, err := probeDir(s.cfg.DataDir)
+	add(CheckDataDirWrite, true, err)
+	_, backupsErr := probeDir(s.cfg.BackupsDir)
+	add(CheckBackupsDirWrite, true, backupsErr)
+	add(CheckFileLock, true, probeLock(filepath.Join(s.cfg.DataDir, ".selftest.lock")))
+	var targetErr error
+	if msg := s.checkTargetDir(); msg != "" {
+		targetErr = errors.New(msg)
+	}
+	add(CheckTargetDir, false, targetErr)
+	if err == nil {
+		add(CheckClock, false, s.checkClock(mtime, time.Now()))
+	}
+	s.selfTest.Store(report)
+	s.logSelfTest(report)
+	return report
+}
+
+// LastSelfTest 返回最近一次自检结果。
+func (s *Service) LastSelfTest() *SelfTestReport {
+	return s.selfTest.Load()
+}
+
+func (s *Service) logSelfTest(report *SelfTestReport) {
+	for _, c := range report.Checks {
+		result := "PASS"
+		if !c.Passed {
+			result = "FAIL"
+			if !c.Fatal {
+				result = "WARN"
+			}
+		}
+		fields := []any{"check", c.Name, "result", result}
+		if c.Detail != "" {
+			fields = append(fields, "detail", c.Detail)
+		}
+		switch result {
+		case "FAIL":
+			s.logger.Error("自检", fields...)
+		case "WARN":
+			s.logger.Warn("自检", fields...)
+		default:
+			s.logger.Info("自检", fields...)
+		}
+	}
+	if failed := report.failedNames(false); len(failed) > 0 {
+		s.logger.Warn("自检完成", "ready", report.Ready(), "failed", failed)
+	}
+}
+
+// checkClock 对照探针文件与目标文件的修改时间检查系统时钟。
+func (s *Service) checkClock(probeMtime, now time.Time) error {
+	if now.Before(clockFloor) {
+		return fmt.Errorf("系统时间 %s 早于 %s，时钟可能未校准", now.Format(time.RFC3339), clockFloor.Format("2006-01-02"))
+	}
+	if skew := now.Sub(probeMtime); skew > clockSkewTolerance || skew < -clockSkewTolerance {
+		return fmt.Errorf("数据目录文件修改时间与系统时间相差 %s，文件系统与本机时钟不一致", skew.Round(time.Second))
+	}
+	if info, err := os.Stat(util.LongPath(s.cfg.TargetPath)); err == nil && info.ModTime().After(now.Add(clockSkewTolerance)) {
+		return fmt.Errorf("目标文件修改时间 %s 晚于当前系统时间", info.ModTime().Format(time.RFC3339))
+	}
+	return nil
+}
+
+// probeMu 串行化 probeDir：探针文件名只含进程 ID，自检与健康检查并发时会互相干扰。
+var probeMu sync.Mutex
+
+// probeDir 在 dir 中写入、重命名并删除一个探针文件，返回探针文件的修改时间。
+func probeDir(dir string) (time.Time, error) {
+	probeMu.Lock()
+	defer probeMu.Unlock()
+	name := fmt.Sprintf(".selftest-%d", os.Getpid())
+	tmp := filepath.Join(dir, name+".tmp")
+	final := filepath.Join(dir, name+".probe")
+	if err := os.WriteFile(util.LongPath(tmp), []byte("probe"), 0o600); err != nil {
+		return time.Time{}, fmt.Errorf("写入探针文件: %w", err)
+	}
+	if err := os.Rename(util.LongPath(tmp), util.LongPath(final)); err != nil {
+		os.Remove(util.LongPath(tmp))
+		return time.Time{}, fmt.Errorf("重命名探针文件: %w", err)
+	}
+	info, statErr := os.Stat(util.LongPath(final))
+	if err := os.Remove(util.LongPath(final)); err != nil {
+		return time.Time{}, fmt.Errorf("删除探针文件: %w", err)
+	}
+	if statErr != nil {
+		return time.Time{}, fmt.Errorf("读取探针文件: %w", statErr)
+	}
+	return info.ModTime(), nil
+}
+
+// probeLock 获取并释放一次文件锁，确认所在文件系统支持加锁。
+func probeLock(path string) error {
+	lock, err := util.AcquireFileLock(path, selfTestLockWait)
+	if err != nil {
+		return fmt.Errorf("获取文件锁: %w", err)
+	}
+	if err := lock.Release(); err != nil {
+		return fmt.Errorf("释放文件锁: %w", err)
+	}
+	os.Remove(util.LongPath(path))
+	return nil
+}
@@ -1,19 +1,25 @@
 package core
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
 	"errors"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
 
+	"codex-backup-tool/internal/tracing"
 	"codex-backup-tool/internal/util"
+	"codex-backup-tool/internal/watcher"
 )
 
 // Config 包含服务运行所需的配置。
@@ -25,94 +31,538 @@ type Config struct {
 	ScanInterval    time.Duration
 	Port            string
 	AutoOpenBrowser bool
+	// TargetChangePolicy 为目标路径与索引记录不一致时的处理方式，取值见 TargetChange* 常量，空字符串按 migrate 处理。
+	TargetChangePolicy string
+	// Timestamps 决定备份文件名与默认备注中的时间格式，取值见 Timestamps* 常量，空字符串按 local 处理。
+	Timestamps string
+	// HashAlgo 为内容哈希算法，取值见 Hash* 常量，空字符串按 sha256 处理；须与索引记录的算法一致，见 ErrAlgoMismatch。
+	HashAlgo string
+	// Watch 为 true 时自动扫描优先由目标文件的变化通知触发，无法监听时退回按 ScanInterval 轮询。
+	Watch bool
+	// WatchDebounce 为合并连续变化事件的等待时长，0 表示 200ms。
+	WatchDebounce time.Duration
+	// MaxAutoBackups 大于 0 时，每次自动扫描、新建备份后只保留最新的这么多个自动备份，手动备份不受影响，见 Prune。
+	MaxAutoBackups int
+	// AutoBackupMaxAge 大于 0 时，每次自动扫描、新建备份后与启动时删除创建早于该时长的自动备份，见 Prune。
+	AutoBackupMaxAge time.Duration
+	// MaxBackupsTotalBytes 大于 0 时，全部备份的大小之和超过该值即从最旧的自动备份开始删除，见 Prune。
+	MaxBackupsTotalBytes int64
+	// EnableMetrics 为 true 时在 /metrics 以 Prometheus 文本格式导出指标，见 Service.Metrics。
+	EnableMetrics bool
+	// MetricsPublic 为 true 时 /metrics 无需认证；默认与 /api/ 一样要求令牌或会话。
+	MetricsPublic bool
+	// Compression 为新备份文件的压缩方式，取值见 Compression* 常量；已有备份按文件名后缀读取，不受该项影响。
+	Compression string
+	// ScanMode 说明 ScanInterval 的来源，取值见 ScanMode* 常量；为空时按 ScanInterval 推断，见 EffectiveScanMode。
+	ScanMode string
+	// DiskReserve 为写入备份后备份卷至少保留的剩余字节数。
+	DiskReserve int64
+	// DataDirMode 为新建数据目录与备份目录时使用的权限，零值按 0700 处理。
+	DataDirMode os.FileMode
+	// FingerprintSampleSize 为参与快速指纹计算的文件头字节数，0 表示仅使用元数据。
+	FingerprintSampleSize int
+	// MtimeGranularity 为参与指纹计算前修改时间截断到的粒度：大于 0 时固定使用该值，
+	// 0 表示按目标所在文件系统自动推断，小于 0 表示不截断。
+	MtimeGranularity time.Duration
+	// LockTimeout 为等待 index.json.lock 的上限，零值使用 util.DefaultLockTimeout。
+	LockTimeout time.Duration
+	// LockStaleAfter 大于 0 时，持有超过该时长的索引锁视为陈旧并可被强制解除。
+	LockStaleAfter time.Duration
+	// TempFileGrace 为原子写遗留临时文件的保留时长，超过后会被清理，零值按 1 小时处理。
+	TempFileGrace time.Duration
+	// TokensPath 为 API 令牌文件路径，为空时使用 DataDir/tokens.json。
+	TokensPath string
+	// MaxTargetSize 为允许备份的目标文件最大字节数，0 表示不限制。
+	MaxTargetSize int64
+	// UIPasswordHash 为 Web 登录密码的 bcrypt 哈希，为空时不启用密码登录。
+	UIPasswordHash util.SecretString
+	// APIToken 非空时作为额外的 Bearer 令牌，与 tokens.json 中的令牌同等有效，适合无法运行 --create-token 的部署。
+	APIToken util.SecretString
+	// EncryptionPassphrase 非空时新备份以 AES-256-GCM 加密保存（密钥由 scrypt 派生），读取时自动解密；
+	// 已有的未加密备份保持不变，仍可正常还原。更换口令后，用旧口令加密的备份将无法解密。
+	EncryptionPassphrase util.SecretString
+	// EncryptionKey 非空时优先于 EncryptionPassphrase，在 NewService 中读取一次口令（文件、环境变量或系统凭据存储）。
+	EncryptionKey KeyProvider
+	// SessionIdle 为登录会话的空闲过期时间，零值按 30 分钟处理。
+	SessionIdle time.Duration
+	// MaxSessions 为同时有效的登录会话上限，超出时淘汰最久未活动的会话，零值按 5 处理。
+	MaxSessions int
+	// IndexBackupCount 为每次写入索引后保留的历史索引份数，0 表示不保留。
+	IndexBackupCount int
+	// AuditPath 为审计日志路径，为空时使用 DataDir/audit.log。
+	AuditPath string
+	// AuditMaxSize 为审计日志轮转阈值（字节），0 表示不轮转。
+	AuditMaxSize int64
+	// EventsPath 为持久化事件日志（JSON Lines），为空时使用 DataDir/events.log。
+	EventsPath string
+	// EventsMaxSize 为事件日志轮转阈值（字节），0 表示不轮转。
+	EventsMaxSize int64
+	// IndexCacheTTL 为索引内存缓存的有效期，0 表示每次读取都访问磁盘。
+	IndexCacheTTL time.Duration
+	// BindHost 为 HTTP 服务监听的主机地址，为空表示监听所有网卡。
+	BindHost string
+	// RequireAuthForRemote 为 true 时，监听非回环地址却未配置任何认证方式将拒绝启动。
+	RequireAuthForRemote bool
+	// IntegrityKey 非空时对 index.json 做 HMAC 签名校验，签名不符时索引进入只读状态。
+	IntegrityKey util.SecretString
+	// Capabilities 可单独关闭还原、删除等操作，未出现的能力默认开启。
+	Capabilities map[string]bool
+	// TLSCertFile 与 TLSKeyFile 非空时以 HTTPS 提供服务。
+	TLSCertFile string
+	TLSKeyFile  string
+	// TLSClientCAFile 非空时要求客户端出示由该 CA 签发的证书（双向 TLS）。
+	TLSClientCAFile string
+	// HealthAddr 非空时在该回环地址上额外提供明文 /healthz，供无法出示客户端证书的健康检查使用。
+	HealthAddr string
+	// CORS 为跨域访问设置，未配置来源时不发送任何 CORS 响应头。
+	CORS CORSConfig
+	// RateLimitRPS 大于 0 时，所有 /api/ 请求共用一个每秒补充 RateLimitRPS 个、最多积攒 RateLimitBurst 个令牌的令牌桶，
+	// 超出时返回 429。
+	RateLimitRPS   float64
+	RateLimitBurst int
+	// DataDirLockFile 为实例锁文件，服务运行期间独占持有，防止多个实例同时使用同一数据目录。
+	DataDirLockFile string
+	// DocsEnabled 为 false 时 /api/docs/ 与 /api/openapi.json 返回 404。
+	DocsEnabled bool
+	// SlowRequest 大于 0 时，耗时超过该值的 HTTP 请求在访问日志中标记为 SLOW。
+	SlowRequest time.Duration
+	// InFlightLogAfter 大于 0 时，执行超过该时长仍未完成的请求会额外记录一条“进行中”日志。
+	InFlightLogAfter time.Duration
+	// LogLevel 为服务日志输出的最低级别。
+	LogLevel slog.Level
+	// AccessLogFormat 为访问日志格式，取值见 AccessLogFormat* 常量。
+	AccessLogFormat string
+	// AccessLogExclude 中的路径不写访问日志；以 * 结尾的项按前缀匹配，其余须完全相同。
+	AccessLogExclude []string
+	// AccessLogErrorsOnly 为 true 时只记录非 2xx 响应与慢请求。
+	AccessLogErrorsOnly bool
+	// RemarkMaxLength 为备注的最大字符数，0 表示默认的 128。
+	RemarkMaxLength int
+	// RemarkCaseInsensitive 为 true 时仅大小写不同的备注视为重复。
+	RemarkCaseInsensitive bool
+	// RestoreOverrideReadOnly 为 true 时还原会临时取消目标文件的只读属性，写入后再恢复；否则拒绝还原只读目标。
+	RestoreOverrideReadOnly bool
+	// ScanHistorySize 为内存中保留的扫描记录数，ScanHistoryPersist 为写入 scan-history.json 的记录数，0 表示默认值。
+	ScanHistorySize    int
+	ScanHistoryPersist int
+	// MaxTimeWithoutBackup 大于 0 时，超过该时长没有成功的备份或扫描即视为过期并告警。
+	MaxTimeWithoutBackup time.Duration
+	// OTLPEndpoint 非空时将 HTTP 请求、扫描、还原与 codex 命令的 span 以 OTLP/HTTP 发送到该地址。
+	OTLPEndpoint string
+	// SpanExporter 供测试或嵌入方直接指定 span 导出器，优先于 OTLPEndpoint。
+	SpanExporter tracing.Exporter
+	// Hooks 为事件发生时在后台执行的外部命令。
+	Hooks []HookConfig
+	// Warnings 记录加载配置时被自动修正的项，由调用方输出日志。
+	Warnings []string
 }
 
 // Service 管理备份逻辑与定时任务。
 type Service struct {
 	cfg    Config
 	store  *Store
-	logger *log.Logger
+	tokens *TokenStore
+	audit  *AuditLog
+	events *EventLog
+	hooks  *hookRunner
+	logger Logger
+	// instanceLock 在 NewService 中获取，Stop 时释放。
+	instanceLock *util.HeldLock
+	// sizeHistory 记录每次扫描时目标文件的大小，用于趋势图。
+	sizeHistory *sizeHistory
+	// scanHistory 为最近的扫描记录，跨重启保留。
+	scanHistory *scanHistory
+	// mtimeGranularity 为计算指纹时修改时间截断到的粒度，0 表示不截断。
+	mtimeGranularity time.Duration
+	// targetChange 为尚未确认的目标路径变更提示。
+	targetChange *targetChangeNotice
+	// selfTest 为最近一次环境自检的结果，启动时生成，POST /api/selftest 可刷新。
+	selfTest atomic.Pointer[SelfTestReport]
+	// tracer 未配置追踪时为 nil，所有 span 操作均为空操作。
+	tracer *tracing.Tracer
+	// metrics 未开启 EnableMetrics 时为零值，所有记录操作均为空操作。
+	metrics serviceMetrics
+	// instanceID 为数据目录的持久标识，startedAt 为本进程创建服务的时间。
+	instanceID string
+	startedAt  time.Time
+	// eventCursors 保存各事件消费者的已读位置。
+	eventCursors *eventCursors
+	// subscribers 为 GET /api/events 实时事件流的订阅者，键为 *EventSubscription。
+	subscribers sync.Map
+	// staleAlerted 表示当前过期期间已告警过，恢复后清除。
+	staleMu      sync.Mutex
+	staleAlerted bool
+	// healthProbe 为健康检查缓存的索引目录写入探测结果。
+	healthProbe healthProbe
+	// slowRequests 与 panics 为 HTTP 中间件统计的慢请求数与被恢复的 panic 数。
+	slowRequests atomic.Int64
+	panics       atomic.Int64
 
-	scanMu sync.Mutex
-	ticker *time.Ticker
-	stopCh chan struct{}
-	wg     sync.WaitGroup
+	// encryption 仅在配置了加密口令时非空，新备份以此加密保存；RotateEncryption 完成后替换为新口令的加密器。
+	encryption atomic.Pointer[backupCipher]
+
+	scanMu    sync.Mutex
+	scanState scanState
+	scanErrs  scanErrorLog
+	latency   *latencyRecorder
+	tempSwept atomic.Int64
+	ticker    *time.Ticker
+	// watcher 非 nil 时自动扫描由目标文件的变化触发，watching 供 Status 读取。
+	watcher  watcher.Watcher
+	watching atomic.Bool
+	stopCh   chan struct{}
+	wg       sync.WaitGroup
 }
 
-// NewService 创建服务实例。
-func NewService(cfg Config, logger *log.Logger) (*Service, error) {
+// NewService 创建服务实例，logger 为空时向标准输出写入 info 级别的 JSON 日志。
+func NewService(cfg Config, logger Logger) (*Service, error) {
 	if logger == nil {
-		logger = log.New(os.Stdout, "", log.LstdFlags)
+		logger = NewJSONLogger(os.Stdout, slog.LevelInfo)
+	}
+	// 所有服务日志都经过脱敏，即使调用方传入的 logger 未做处理。
+	logger = redactLogger{next: logger}
+	if cfg.DataDirMode == 0 {
+		cfg.DataDirMode = 0o700
 	}
-	if err := util.EnsureDir(cfg.DataDir); err != nil {
+	// isolate 模式需要在创建目录与 Store 之前确定实际使用的索引与备份目录。
+	var targetChange *TargetChange
+	if cfg.TargetChangePolicy == TargetChangeIsolate {
+		targetChange = isolateTarget(&cfg)
+	}
+	if err := util.EnsureDirMode(cfg.DataDir, cfg.DataDirMode); err != nil {
 		return nil, fmt.Errorf("ensure data dir: %w", err)
 	}
-	if err := util.EnsureDir(cfg.BackupsDir); err != nil {
+	if err := util.EnsureDirMode(cfg.BackupsDir, cfg.DataDirMode); err != nil {
 		return nil, fmt.Errorf("ensure backups dir: %w", err)
 	}
-	s := &Service{
-		cfg:    cfg,
-		store:  NewStore(cfg.IndexPath, cfg.TargetPath),
-		logger: logger,
+	storeOpts := StoreOptions{
+		Lock: util.LockOptions{
+			Timeout:    cfg.LockTimeout,
+			StaleAfter: cfg.LockStaleAfter,
+			Logf:       logfFunc(logger),
+		},
+		BackupCount: cfg.IndexBackupCount,
+		Logger:      logger,
+		CacheTTL:    cfg.IndexCacheTTL,
+		// 备注唯一性按规范化后的形式判断，可选忽略大小写。
+		RemarkCaseInsensitive: cfg.RemarkCaseInsensitive,
+		Timestamps:            cfg.Timestamps,
+		HashAlgo:              cfg.HashAlgo,
+	}
+	if cfg.IntegrityKey != "" {
+		storeOpts.IntegrityKey = []byte(cfg.IntegrityKey.Reveal())
+	}
+	var encryption *backupCipher
+	if key := cfg.EncryptionKeyProvider(); key != nil {
+		passphrase, err := key.Passphrase()
+		if err != nil {
+			return nil, fmt.Errorf("读取加密口令（%s）: %w", key.Source(), err)
+		}
+		if encryption, err = newBackupCipher(passphrase); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.TokensPath == "" {
+		cfg.TokensPath = filepath.Join(cfg.DataDir, "tokens.json")
+	}
+	if cfg.AuditPath == "" {
+		cfg.AuditPath = filepath.Join(cfg.DataDir, "audit.log")
+	}
+	if cfg.EventsPath == "" {
+		cfg.EventsPath = filepath.Join(cfg.DataDir, "events.log")
+	}
+	if cfg.DataDirLockFile == "" {
+		cfg.DataDirLockFile = filepath.Join(cfg.DataDir, ".instance.lock")
+	}
+	instanceLock, err := acquireInstanceLock(cfg.DataDirLockFile)
+	if err != nil {
+		return nil, err
+	}
+	instanceID, err := LoadInstanceID(cfg.DataDir)
+	if err != nil {
+		instanceLock.Release()
+		return nil, err
+	}
+	notice, noticeErr := loadTargetChangeNotice(filepath.Join(cfg.DataDir, targetChangeFile))
+	scanHist, histErr := newScanHistory(filepath.Join(cfg.DataDir, scanHistoryFile), cfg.ScanHistorySize, cfg.ScanHistoryPersist)
+	events := NewEventLog(cfg.EventsPath, cfg.EventsMaxSize)
+	var s *Service
+	storeOpts.OnTampered = func() { s.emit(Event{Type: EventIndexTampered}) }
+	storeOpts.TargetFingerprint = func() (string, error) {
+		res, err := s.fingerprintTarget()
+		if err != nil {
+			return "", err
+		}
+		return res.Fingerprint, nil
+	}
+	s = &Service{
+		cfg:          cfg,
+		store:        NewStoreWithOptions(cfg.IndexPath, cfg.TargetPath, storeOpts),
+		tokens:       NewTokenStore(cfg.TokensPath, storeOpts.Lock),
+		audit:        NewAuditLog(cfg.AuditPath, cfg.AuditMaxSize),
+		events:       events,
+		logger:       logger,
+		instanceLock: instanceLock,
+		instanceID:   instanceID,
+		eventCursors: &eventCursors{path: filepath.Join(cfg.DataDir, eventCursorsFile)},
+		startedAt:    time.Now(),
+		latency:      newLatencyRecorder(),
+		sizeHistory:  newSizeHistory(filepath.Join(cfg.DataDir, "target_history.jsonl")),
+		scanHistory:  scanHist,
+		targetChange: notice,
+	}
+	s.encryption.Store(encryption)
+	s.hooks = newHookRunner(cfg.Hooks, s.emitEvent, logfFunc(logger))
+	if cfg.EnableMetrics {
+		s.metrics = newServiceMetrics(s)
+	}
+	if s.mtimeGranularity = resolveMtimeGranularity(cfg); s.mtimeGranularity > 0 {
+		s.logger.Info("指纹计算将修改时间截断到指定粒度", "granularity", s.mtimeGranularity.String())
+	}
+	if histErr != nil {
+		s.logger.Warn("忽略无法读取的扫描历史，从空记录开始", "error", histErr)
+	}
+	if report := s.SelfTest(); !report.Ready() {
+		s.events.Close()
+		s.audit.Close()
+		instanceLock.Release()
+		return nil, fmt.Errorf("%w: %s", ErrSelfTestFailed, strings.Join(report.failedNames(true), ","))
+	}
+	s.SweepTempFiles()
+	if noticeErr != nil {
+		s.logger.Warn("忽略无法读取的目标路径变更提示", "error", noticeErr)
+	}
+	if cfg.TargetChangePolicy != TargetChangeIsolate {
+		if targetChange, err = s.migrateTarget(); err != nil {
+			s.logger.Error("检查索引目标路径失败", "error", err)
+		}
+	}
+	if targetChange != nil {
+		s.recordTargetChange(targetChange)
 	}
-	s.logger.Printf("Service init target=%s data_dir=%s scan_interval=%s %s", cfg.TargetPath, cfg.DataDir, cfg.ScanInterval, PlatformInfo())
+	exporter := cfg.SpanExporter
+	if exporter == nil && cfg.OTLPEndpoint != "" {
+		exporter = tracing.NewOTLPExporter(cfg.OTLPEndpoint, "codex-backup-tool")
+	}
+	s.tracer = tracing.New(exporter, logfFunc(s.logger))
+	s.logger.Info("Service init", "target", cfg.TargetPath, "data_dir", cfg.DataDir, "scan_interval", cfg.ScanInterval.String(),
+		"scan_mode", cfg.EffectiveScanMode(), "platform", PlatformInfo())
 	return s, nil
 }
 
-// Start 启动定时扫描。
+const (
+	// defaultWatchDebounce 为 WatchDebounce 未配置时合并变化事件的等待时长。
+	defaultWatchDebounce = 200 * time.Millisecond
+	// watchRescanInterval 为监听文件变化时兜底扫描的最短间隔，用于补上网络文件系统等场景下漏掉的事件。
+	watchRescanInterval = 10 * time.Minute
+)
+
+// Start 启动自动扫描与临时文件清理。启用 watch 时优先由目标文件的变化触发扫描，
+// 并以较长的间隔兜底扫描；无法监听时按 ScanInterval 轮询。
 func (s *Service) Start(ctx context.Context) {
-	if s.cfg.ScanInterval <= 0 {
-		s.logger.Println("Scan interval <=0, auto scan disabled")
+	if s.stopCh != nil {
 		return
 	}
-	if s.ticker != nil {
+	s.stopCh = make(chan struct{})
+	s.startTempSweeper(ctx)
+	s.startStaleWatcher(ctx)
+	s.autoPrune()
+	if s.cfg.EffectiveScanMode() == ScanModeDisabled {
+		s.logger.Info("Auto scan disabled")
 		return
 	}
-	s.ticker = time.NewTicker(s.cfg.ScanInterval)
-	s.stopCh = make(chan struct{})
+	events, interval := s.startWatcher()
+	s.ticker = time.NewTicker(interval)
+	s.scanState.arm(time.Now().Add(interval))
 	s.wg.Add(1)
 	go func() {
 		defer s.wg.Done()
+		debounce := s.cfg.WatchDebounce
+		if debounce <= 0 {
+			debounce = defaultWatchDebounce
+		}
+		var timer *time.Timer
+		var fire <-chan time.Time
+		defer func() {
+			if timer != nil {
+				timer.Stop()
+			}
+		}()
 		for {
 			select {
 			case <-ctx.Done():
-				s.logger.Println("Auto scan stopped: context canceled")
+				s.logger.Info("Auto scan stopped", "reason", "context canceled")
 				return
 			case <-s.stopCh:
-				s.logger.Println("Auto scan stopped: stop signal")
+				s.logger.Info("Auto scan stopped", "reason", "stop signal")
 				return
-			case <-s.ticker.C:
-				if _, err := s.Scan(true, nil); err != nil {
-					s.logger.Printf("Auto scan error: %v", err)
+			case tick := <-s.ticker.C:
+				s.scanState.arm(tick.Add(interval))
+				s.autoScan()
+			case _, ok := <-events:
+				if !ok {
+					// 监听意外结束（如目标目录被删除），退回按 ScanInterval 轮询。
+					events = nil
+					s.watching.Store(false)
+					interval = s.cfg.ScanInterval
+					s.ticker.Reset(interval)
+					s.scanState.arm(time.Now().Add(interval))
+					s.logger.Warn("文件监听已结束，改为轮询", "scan_interval", interval.String())
+					continue
+				}
+				// 连续的写入事件在 debounce 内合并为一次扫描。
+				if timer == nil {
+					timer = time.NewTimer(debounce)
+				} else {
+					timer.Reset(debounce)
 				}
+				fire = timer.C
+			case <-fire:
+				fire = nil
+				s.autoScan()
 			}
 		}
 	}()
 }
 
-// Stop 停止定时任务。
+// startWatcher 在启用 watch 时监听目标文件，成功时返回事件通道与兜底扫描间隔 max(ScanInterval, watchRescanInterval)；
+// 平台不支持或目标目录不存在时返回 nil 通道与 ScanInterval，即退回固定间隔轮询。
+func (s *Service) startWatcher() (<-chan struct{}, time.Duration) {
+	if !s.cfg.Watch {
+		return nil, s.cfg.ScanInterval
+	}
+	w, err := watcher.New()
+	if err != nil {
+		s.logger.Warn("无法创建文件监听，改为轮询", "scan_interval", s.cfg.ScanInterval.String(), "error", err)
+		return nil, s.cfg.ScanInterval
+	}
+	events, err := w.Watch(s.cfg.TargetPath)
+	if err != nil {
+		w.Close()
+		s.logger.Warn("无法监听目标文件，改为轮询", "scan_interval", s.cfg.ScanInterval.String(), "error", err)
+		return nil, s.cfg.ScanInterval
+	}
+	s.watcher = w
+	s.watching.Store(true)
+	interval := s.cfg.ScanInterval
+	if interval < watchRescanInterval {
+		interval = watchRescanInterval
+	}
+	s.logger.Info("已监听目标文件变化", "rescan_interval", interval.String())
+	return events, interval
+}
+
+// autoScan 执行一次自动扫描并记录日志与审计。
+func (s *Service) autoScan() {
+	res, err := s.Scan(true, nil)
+	line, first := s.scanErrs.observe(err, time.Now())
+	switch {
+	case line.recovered:
+		s.logger.Info(line.msg, line.fields...)
+	case line.msg != "":
+		s.logger.Error(line.msg, line.fields...)
+	}
+	if err != nil {
+		// 持续出现的同一错误只审计第一次，避免每个周期写入相同的记录。
+		if first {
+			s.RecordAudit(AuditEntry{Op: "backup.create", Source: AuditSourceScheduler, Outcome: AuditOutcomeFailed, Error: err.Error()})
+		}
+	} else if res.Created {
+		s.RecordAudit(AuditEntry{Op: "backup.create", ItemID: res.Item.ID, Source: AuditSourceScheduler})
+	}
+}
+
+// Stop 停止定时任务，写完待写入的事件、将审计日志落盘并释放数据目录实例锁。
 func (s *Service) Stop() {
-	if s.ticker == nil {
+	s.tokens.Wait()
+	s.stopScheduler()
+	if err := s.scanHistory.save(); err != nil {
+		s.logger.Error("保存扫描历史失败", "error", err)
+	}
+	s.hooks.wait()
+	s.tracer.Shutdown()
+	s.CloseEventStreams()
+	s.events.Close()
+	if err := s.audit.Close(); err != nil {
+		s.logger.Warn("关闭审计日志失败", "error", err)
+	}
+	if err := s.instanceLock.Release(); err != nil {
+		s.logger.Warn("释放实例锁失败", "error", err)
+	}
+}
+
+// stopScheduler 停止定时扫描与临时文件清理。
+func (s *Service) stopScheduler() {
+	if s.stopCh == nil {
 		return
 	}
-	s.ticker.Stop()
+	if s.ticker != nil {
+		s.ticker.Stop()
+	}
 	close(s.stopCh)
 	s.wg.Wait()
+	if s.watcher != nil {
+		if err := s.watcher.Close(); err != nil {
+			s.logger.Warn("关闭文件监听失败", "error", err)
+		}
+		s.watcher = nil
+		s.watching.Store(false)
+	}
+	s.scanState.arm(time.Time{})
 	s.ticker = nil
+	s.stopCh = nil
 }
 
-// StatusInfo 描述当前目标文件状态。
+// StatusInfo 描述当前目标文件状态。目标是目录或无法读取时 Exists 仍为 true，Problem 说明原因。
 type StatusInfo struct {
-	Exists              bool   `json:"exists"`
-	Size                int64  `json:"size"`
-	ModTime             string `json:"mod_time"`
-	Fingerprint         string `json:"fingerprint"`
-	ContentHash         string `json:"content_hash"`
-	ContentHashShort    string `json:"content_hash_short"`
-	LatestFingerprint   string `json:"latest_fingerprint"`
-	TargetPath          string `json:"target_path"`
-	ScanIntervalSeconds int    `json:"scan_interval_seconds"`
-	AutoOpenBrowser     bool   `json:"auto_open_browser"`
+	Exists               bool     `json:"exists"`
+	IsDirectory          bool     `json:"is_directory"`
+	Readable             bool     `json:"readable"`
+	Problem              string   `json:"problem,omitempty"`
+	Size                 int64    `json:"size"`
+	ModTime              string   `json:"mod_time"`
+	Fingerprint          string   `json:"fingerprint"`
+	ContentHash          string   `json:"content_hash"`
+	ContentHashShort     string   `json:"content_hash_short"`
+	LatestFingerprint    string   `json:"latest_fingerprint"`
+	TargetPath           string   `json:"target_path"`
+	ScanIntervalSeconds  int      `json:"scan_interval_seconds"`
+	ScanMode             string   `json:"scan_mode"`
+	AutoOpenBrowser      bool     `json:"auto_open_browser"`
+	DiskFreeBytes        *int64   `json:"disk_free_bytes"`
+	DiskReserveBytes     int64    `json:"disk_reserve_bytes"`
+	DiskLow              bool     `json:"disk_low"`
+	MaxTargetSizeBytes   int64    `json:"max_target_size_bytes"`
+	Exposure             string   `json:"exposure"`
+	Tampered             bool     `json:"tampered"`
+	DisabledCapabilities []string `json:"disabled_capabilities"`
+	Warnings             []string `json:"warnings"`
+	// BackupsTotalBytes 为索引中全部备份的大小之和，MaxBackupsTotalBytes 为配置的上限（0 表示不限制）。
+	BackupsTotalBytes    int64 `json:"backups_total_bytes"`
+	MaxBackupsTotalBytes int64 `json:"max_backups_total_bytes"`
+	// 最近一次扫描的情况，首次扫描前为空字符串；NextScanETASeconds 为 -1 表示未排定自动扫描。
+	LastScanAt         string `json:"last_scan_at"`
+	LastScanTrigger    string `json:"last_scan_trigger"`
+	LastScanReasonCode string `json:"last_scan_reason_code"`
+	LastScanError      string `json:"last_scan_error"`
+	// 自动扫描连续出现的同一错误的分类（ScanError* 常量）与次数，恢复后清空。
+	ScanErrorClass     string `json:"scan_error_class"`
+	ScanErrorCount     int    `json:"scan_error_count"`
+	NextScanETASeconds int    `json:"next_scan_eta_seconds"`
+	// Watching 为 true 表示自动扫描由目标文件的变化通知触发，否则按 scan_interval 轮询。
+	Watching bool `json:"watching"`
+	// 实例信息，用于跨重启关联日志并确认界面连接的是哪个实例。
+	InstanceID    string `json:"instance_id"`
+	StartedAt     string `json:"started_at"`
+	UptimeSeconds int64  `json:"uptime_seconds"`
+	PID           int    `json:"pid"`
+	DataDir       string `json:"data_dir"`
+	IndexPath     string `json:"index_path"`
+	// Stale 为 true 表示超过 alerts.max_hours_without_backup 没有成功的备份，详情见 Staleness。
+	Stale     bool           `json:"stale"`
+	Staleness *StalenessInfo `json:"staleness,omitempty"`
+	// TargetChange 为启动时检测到、尚未通过 POST /api/target/acknowledge 确认的目标路径变更。
+	TargetChange *TargetChange `json:"target_change,omitempty"`
 }
 
 // Status 返回目标文件状态。
@@ -121,25 +571,80 @@ func (s *Service) Status() (*StatusInfo, error) {
 	if err != nil {
 		return nil, err
 	}
+	tampered, err := s.store.Tampered()
+	if err != nil {
+		return nil, err
+	}
 	status := &StatusInfo{
-		LatestFingerprint:   idx.LatestFingerprint,
-		TargetPath:          s.cfg.TargetPath,
-		ScanIntervalSeconds: int(s.cfg.ScanInterval / time.Second),
-		AutoOpenBrowser:     s.cfg.AutoOpenBrowser,
+		LatestFingerprint:    idx.LatestFingerprint,
+		TargetPath:           s.cfg.TargetPath,
+		ScanIntervalSeconds:  int(s.cfg.ScanInterval / time.Second),
+		ScanMode:             s.cfg.EffectiveScanMode(),
+		AutoOpenBrowser:      s.cfg.AutoOpenBrowser,
+		DiskReserveBytes:     s.cfg.DiskReserve,
+		MaxTargetSizeBytes:   s.cfg.MaxTargetSize,
+		MaxBackupsTotalBytes: s.cfg.MaxBackupsTotalBytes,
+		Exposure:             s.cfg.Exposure(),
+		Tampered:             tampered,
+		DisabledCapabilities: s.cfg.DisabledCapabilities(),
+		Warnings:             make([]string, 0),
+		InstanceID:           s.instanceID,
+		StartedAt:            s.startedAt.UTC().Format(time.RFC3339),
+		UptimeSeconds:        int64(time.Since(s.startedAt) / time.Second),
+		PID:                  os.Getpid(),
+		DataDir:              s.cfg.DataDir,
+		IndexPath:            s.cfg.IndexPath,
 	}
-	fingerprintRes, err := ComputeFingerprint(s.cfg.TargetPath)
-	if err != nil {
-		if os.IsNotExist(err) {
-			status.Exists = false
-			return status, nil
+	for _, item := range idx.Items {
+		status.BackupsTotalBytes += item.Size
+	}
+	s.scanState.fill(status)
+	s.scanErrs.fill(status)
+	status.Watching = s.watching.Load()
+	status.TargetChange = s.targetChange.get()
+	if status.Staleness, err = s.Staleness(); err != nil {
+		return nil, err
+	}
+	status.Stale = status.Staleness != nil && status.Staleness.Stale
+	// 目标目录在下面实时检查，不再重复自检时的结果。
+	if report := s.LastSelfTest(); report != nil {
+		for _, c := range report.Checks {
+			if !c.Passed && !c.Fatal && c.Name != CheckTargetDir {
+				status.Warnings = append(status.Warnings, c.Name+": "+c.Detail)
+			}
 		}
+	}
+	if free, ok := s.diskFree(); ok {
+		status.DiskFreeBytes = &free
+		status.DiskLow = free < s.cfg.DiskReserve
+	}
+	target, err := s.EvaluateTarget()
+	if err != nil {
 		return nil, fmt.Errorf("fingerprint: %w", err)
 	}
+	if !target.Exists {
+		status.Exists = false
+		if msg := s.checkTargetDir(); msg != "" {
+			status.Warnings = append(status.Warnings, msg)
+		}
+		return status, nil
+	}
 	status.Exists = true
-	status.Size = fingerprintRes.Stat.Size
-	status.ModTime = fingerprintRes.Stat.ModTime.Format(time.RFC3339)
-	status.Fingerprint = fingerprintRes.Fingerprint
-	contentHash, _, err := ComputeContentHash(s.cfg.TargetPath)
+	status.IsDirectory = target.IsDirectory
+	status.Readable = !target.IsDirectory && !target.Unreadable
+	if target.IsDirectory || target.Unreadable {
+		status.Problem = target.Reason
+	}
+	if target.Stat != nil {
+		status.Size = target.Stat.Size
+		status.ModTime = target.Stat.ModTime.Format(time.RFC3339)
+	}
+	status.Fingerprint = target.Fingerprint
+	if !target.Backupable() {
+		status.Warnings = append(status.Warnings, target.Reason)
+		return status, nil
+	}
+	contentHash, _, err := ComputeContentHash(s.cfg.TargetPath, s.cfg.HashAlgo)
 	if err != nil {
 		return nil, fmt.Errorf("content hash: %w", err)
 	}
@@ -148,83 +653,222 @@ func (s *Service) Status() (*StatusInfo, error) {
 	return status, nil
 }
 
+// 自动扫描模式：scan_interval 未配置或为 0 时为 default，-1 为 disabled，正数为 custom。
+const (
+	ScanModeDefault  = "default"
+	ScanModeCustom   = "custom"
+	ScanModeDisabled = "disabled"
+)
+
+// EffectiveScanMode 返回实际生效的自动扫描模式。ScanInterval 不大于 0 时总是 disabled，
+// 直接构造的 Config 未设置 ScanMode 时视为 custom。
+func (c Config) EffectiveScanMode() string {
+	switch {
+	case c.ScanMode == ScanModeDisabled || c.ScanInterval <= 0:
+		return ScanModeDisabled
+	case c.ScanMode == "":
+		return ScanModeCustom
+	default:
+		return c.ScanMode
+	}
+}
+
+// 扫描未创建备份时的原因代码。
+const (
+	ReasonTargetMissing     = "TARGET_MISSING"
+	ReasonUnchanged         = "UNCHANGED"
+	ReasonDuplicateContent  = "DUPLICATE_CONTENT"
+	ReasonDiskFull          = "DISK_FULL"
+	ReasonTargetTooLarge    = "TARGET_TOO_LARGE"
+	ReasonTargetIsDirectory = "TARGET_IS_DIRECTORY"
+	ReasonTargetUnreadable  = "TARGET_UNREADABLE"
+)
+
 // ScanResult 描述一次扫描结果。
 type ScanResult struct {
 	Created bool        `json:"created"`
 	Item    *BackupItem `json:"item,omitempty"`
+	Code    string      `json:"code,omitempty"`
 	Reason  string      `json:"reason,omitempty"`
+	// DurationsMs 为本次扫描各阶段的耗时（毫秒），键为 Phase* 常量，只包含实际执行过的阶段。
+	DurationsMs map[string]float64 `json:"durations_ms,omitempty"`
 }
 
-// Scan 执行扫描与备份逻辑。
-
 // CreateBackup 手动创建备份。
 func (s *Service) CreateBackup(remark *string) (*ScanResult, error) {
 	return s.Scan(false, remark)
 }
 
+// Scan 执行扫描与备份逻辑。
 func (s *Service) Scan(isAuto bool, remark *string) (*ScanResult, error) {
+	return s.ScanContext(context.Background(), isAuto, remark)
+}
+
+// ScanOptions 描述一次扫描的方式与来源。
+type ScanOptions struct {
+	// Auto 决定新备份的 IsAuto 与默认备注前缀（auto-/manual-）。
+	Auto bool
+	// Remark 为新备份的备注，为空时按 Auto 生成默认备注。
+	Remark *string
+	// Trigger 写入新备份的 Trigger 字段，为空时 Auto 取 ticker，否则取 manual_create。
+	Trigger string
+}
+
+// ScanContext 与 Scan 相同；ctx 携带 span 时扫描及各阶段记录为其子 span。
+func (s *Service) ScanContext(ctx context.Context, isAuto bool, remark *string) (*ScanResult, error) {
+	return s.ScanWithOptions(ctx, ScanOptions{Auto: isAuto, Remark: remark})
+}
+
+// ScanWithOptions 按 opts 执行扫描。只有定时扫描在状态与扫描历史中记为 auto，
+// 其余来源即使 opts.Auto 为 true 也记为 manual。
+func (s *Service) ScanWithOptions(ctx context.Context, opts ScanOptions) (*ScanResult, error) {
+	if opts.Trigger == "" {
+		opts.Trigger = BackupTriggerManualCreate
+		if opts.Auto {
+			opts.Trigger = BackupTriggerTicker
+		}
+	}
+	trigger := ScanTriggerManual
+	if opts.Trigger == BackupTriggerTicker {
+		trigger = ScanTriggerAuto
+	}
+	_, span := s.tracer.Start(ctx, "scan", tracing.KindInternal, tracing.String("scan.trigger", trigger))
+	defer span.End()
 	s.scanMu.Lock()
 	defer s.scanMu.Unlock()
+	timer := newPhaseTimer(span)
+	res, err := s.scanLocked(opts, timer)
+	durations := timer.finish(PhaseScan, s.latency)
+	s.metrics.scanDuration.Observe(durations[PhaseScan] / 1000)
+	s.recordScanHistory(trigger, res, err, durations)
+	if res != nil {
+		res.DurationsMs = durations
+		span.SetAttributes(tracing.Bool("scan.created", res.Created), tracing.String("scan.code", res.Code))
+	}
+	span.SetError(err)
+	s.scanState.record(trigger, res, err)
+	if s.cfg.MaxTimeWithoutBackup > 0 {
+		s.CheckStaleness()
+	}
+	switch {
+	case err != nil:
+		s.emit(Event{Type: EventScanFailed, Reason: err.Error(), Summary: trigger})
+	case res.Created:
+		s.metrics.backupsCreated.Inc()
+		s.emit(Event{Type: EventBackupCreated, ItemID: res.Item.ID, Remark: res.Item.Remark, Summary: trigger})
+	}
+	// 仍持有 scanMu，保留策略不会与并发的手动备份交错。
+	if err == nil && (opts.Auto || res.Created) {
+		s.autoPrune()
+	}
+	return res, err
+}
 
+func (s *Service) scanLocked(opts ScanOptions, timer *phaseTimer) (*ScanResult, error) {
+	isAuto := opts.Auto
 	idx, err := s.store.Snapshot()
 	if err != nil {
 		return nil, err
 	}
-	fingerprintRes, err := ComputeFingerprint(s.cfg.TargetPath)
+	timer.mark(PhaseIndex)
+	target, err := s.EvaluateTarget()
 	if err != nil {
-		if os.IsNotExist(err) {
-			return &ScanResult{Created: false, Reason: "目标文件不存在"}, nil
-		}
-		return nil, fmt.Errorf("stat target: %w", err)
+		return nil, err
 	}
-	fingerprint := fingerprintRes.Fingerprint
+	timer.mark(PhaseStat)
+	changed := false
+	defer func() { s.recordTargetSample(target, changed) }()
+	if !target.Backupable() {
+		s.logger.Debug("扫描决策：目标不可备份", "decision", "not_backupable", "code", target.Code)
+		return &ScanResult{Created: false, Code: target.Code, Reason: target.Reason}, nil
+	}
+	fingerprint := target.Fingerprint
 	if idx.LatestFingerprint == fingerprint {
-		return &ScanResult{Created: false, Reason: "文件未变更"}, nil
+		s.logger.Debug("扫描决策：指纹与最新备份一致", "decision", "fingerprint_match", "fingerprint", fingerprint)
+		return &ScanResult{Created: false, Code: ReasonUnchanged, Reason: "文件未变更"}, nil
 	}
-	contentHash, data, err := ComputeContentHash(s.cfg.TargetPath)
+	contentHash, data, err := ComputeContentHash(s.cfg.TargetPath, s.cfg.HashAlgo)
 	if err != nil {
 		return nil, fmt.Errorf("读取目标内容: %w", err)
 	}
+	timer.mark(PhaseHash)
+	// 指纹变化但大小与最近一次备份相同，且内容哈希也一致：只是元数据变化（如粗粒度文件系统上的
+	// 修改时间抖动），更新指纹后按未变更处理。
+	if latest := newestBackup(idx); latest != nil && latest.Size == target.Stat.Size && latest.ContentHash == contentHash {
+		if _, err := s.store.UpdateLatestFingerprint(fingerprint); err != nil {
+			return nil, fmt.Errorf("更新最新指纹: %w", err)
+		}
+		timer.mark(PhaseIndex)
+		s.logger.Debug("扫描决策：仅元数据变化", "decision", "metadata_only", "fingerprint", fingerprint, "id", latest.ID)
+		return &ScanResult{Created: false, Code: ReasonUnchanged, Reason: "仅文件元数据变化，内容未变更"}, nil
+	}
+	changed = true
 	if existing := findByContentHash(idx.Items, contentHash); existing != nil {
 		if _, err := s.store.UpdateLatestFingerprint(fingerprint); err != nil {
 			return nil, fmt.Errorf("更新最新指纹: %w", err)
 		}
-		s.logger.Printf("扫描跳过：指纹不同但内容重复 hash=%s", ShortHash(contentHash))
-		return &ScanResult{Created: false, Reason: "内容已存在备份"}, nil
+		timer.mark(PhaseIndex)
+		s.logger.Debug("扫描决策：指纹不同但内容重复", "decision", "content_dedup", "fingerprint", fingerprint,
+			"hash", ShortHash(contentHash), "id", existing.ID)
+		return &ScanResult{Created: false, Code: ReasonDuplicateContent, Reason: "内容已存在备份"}, nil
 	}
-	finalRemark, err := s.prepareRemark(idx, isAuto, remark)
+	// 文件名、默认备注与 CreatedAt 使用同一时刻；CreatedAt 总以 UTC 保存，排序只比较该时刻。
+	now := time.Now().UTC()
+	finalRemark, err := s.prepareRemark(idx, isAuto, opts.Remark, now)
 	if err != nil {
 		return nil, err
 	}
-	now := time.Now()
-	filename := BuildBackupFilename(now, contentHash)
+	if free, ok := s.diskFree(); ok && free < int64(len(data))+s.cfg.DiskReserve {
+		s.logger.Warn("扫描跳过：备份卷剩余空间不足", "free", free, "need", len(data), "reserve", s.cfg.DiskReserve)
+		return &ScanResult{Created: false, Code: ReasonDiskFull, Reason: "备份目录所在磁盘空间不足"}, nil
+	}
+	timer.skip()
+	s.logger.Debug("扫描决策：内容已变化，创建新备份", "decision", "new_backup", "fingerprint", fingerprint,
+		"hash", ShortHash(contentHash), "size", target.Stat.Size)
+	filename := BuildBackupFilename(now, s.cfg.Timestamps, contentHash, s.cfg.Compression)
 	filename, err = EnsureUniqueFilename(s.cfg.BackupsDir, filename)
 	if err != nil {
 		return nil, fmt.Errorf("生成备份文件名: %w", err)
 	}
-	if _, err := WriteBackupFile(s.cfg.BackupsDir, filename, data); err != nil {
+	enc, err := s.writeBackupFile(filename, data)
+	if err != nil {
 		return nil, fmt.Errorf("写入备份文件: %w", err)
 	}
+	timer.mark(PhaseWrite)
 	item := BackupItem{
 		ID:              uuid.New().String(),
 		Filename:        filename,
 		ContentHash:     contentHash,
 		FileFingerprint: fingerprint,
-		Size:            fingerprintRes.Stat.Size,
+		Size:            target.Stat.Size,
 		CreatedAt:       now,
 		Remark:          finalRemark,
 		IsAuto:          isAuto,
 		SourcePath:      s.cfg.TargetPath,
-		LastModified:    fingerprintRes.Stat.ModTime,
+		LastModified:    target.Stat.ModTime,
+		Trigger:         opts.Trigger,
+		Encrypted:       enc.Salt != "",
+		KDFSalt:         enc.Salt,
+		KeyID:           enc.KeyID,
 	}
 	if err := s.persistBackup(item, fingerprint, isAuto); err != nil {
 		os.Remove(filepath.Join(s.cfg.BackupsDir, filename))
 		return nil, err
 	}
-	s.logger.Printf("创建备份 succeed id=%s remark=%q fingerprint=%s hash=%s", item.ID, item.Remark, fingerprint, ShortHash(contentHash))
+	timer.mark(PhaseIndex)
+	s.logger.Info("创建备份", "id", item.ID, "remark", item.Remark, "fingerprint", fingerprint, "hash", ShortHash(contentHash))
 	return &ScanResult{Created: true, Item: &item}, nil
 }
 
+// diskFree 返回备份卷剩余空间；平台不支持时 ok 为 false，调用方直接尝试写入。
+func (s *Service) diskFree() (int64, bool) {
+	free, err := util.DiskFree(s.cfg.BackupsDir)
+	if err != nil {
+		return 0, false
+	}
+	return int64(free), true
+}
+
 func (s *Service) persistBackup(item BackupItem, fingerprint string, isAuto bool) error {
 	baseRemark := item.Remark
 	counter := 1
@@ -236,43 +880,64 @@ func (s *Service) persistBackup(item BackupItem, fingerprint string, isAuto bool
 		if errors.Is(err, ErrRemarkExists) && isAuto {
 			item.Remark = fmt.Sprintf("%s-%d", baseRemark, counter)
 			counter++
-			s.logger.Printf("自动备备注名冲突，尝试 %s", item.Remark)
+			s.logger.Debug("自动备份备注名冲突，改用新备注", "remark", item.Remark)
 			continue
 		}
 		return err
 	}
 }
 
-func (s *Service) prepareRemark(idx *IndexData, isAuto bool, req *string) (string, error) {
+func (s *Service) prepareRemark(idx *IndexData, isAuto bool, req *string, now time.Time) (string, error) {
 	if req != nil {
-		r := strings.TrimSpace(*req)
-		if r == "" {
-			return "", errors.New("备注不能为空字符串")
+		r, err := s.ValidateRemark(*req, false)
+		if err != nil {
+			return "", err
 		}
-		if _, ok := idx.Remarks[r]; ok {
+		if _, ok := findRemark(idx.Remarks, r, s.cfg.RemarkCaseInsensitive); ok {
 			return "", ErrRemarkExists
 		}
 		return r, nil
 	}
-	now := time.Now()
+	return uniqueRemark(idx.Remarks, autoRemark(isAuto, now, s.cfg.Timestamps), s.cfg.RemarkCaseInsensitive), nil
+}
+
+// autoRemark 生成形如 auto-20060102-150405+0800 / manual-20060102-150405Z 的默认备注，时间格式见 FormatTimestamp。
+func autoRemark(isAuto bool, t time.Time, mode string) string {
 	base := "manual-"
 	if isAuto {
 		base = "auto-"
 	}
-	remark := fmt.Sprintf("%s%s", base, now.Format("20060102-150405"))
-	if _, ok := idx.Remarks[remark]; !ok {
-		return remark, nil
+	return base + FormatTimestamp(t, mode)
+}
+
+// uniqueRemark 在 remark 已被占用时依次追加 -1、-2… 直到不冲突。
+func uniqueRemark(remarks map[string]string, remark string, fold bool) string {
+	if _, ok := findRemark(remarks, remark, fold); !ok {
+		return remark
 	}
 	counter := 1
 	for {
 		candidate := fmt.Sprintf("%s-%d", remark, counter)
-		if _, exists := idx.Remarks[candidate]; !exists {
-			return candidate, nil
+		if _, exists := findRemark(remarks, candidate, fold); !exists {
+			return candidate
 		}
 		counter++
 	}
 }
 
+// newestBackup 返回创建时间最新的备份，没有备份时返回 nil。
+func newestBackup(idx *IndexData) *BackupItem {
+	if len(idx.SortedIDs) == 0 {
+		return nil
+	}
+	for i := range idx.Items {
+		if idx.Items[i].ID == idx.SortedIDs[0] {
+			return &idx.Items[i]
+		}
+	}
+	return nil
+}
+
 func findByContentHash(items []BackupItem, hash string) *BackupItem {
 	for i := range items {
 		if items[i].ContentHash == hash {
@@ -288,57 +953,276 @@ func (s *Service) ListBackups() ([]BackupItem, error) {
 	return s.store.ListBackups()
 }
 
-// UpdateRemark 更新备注。
+// ListBackupsPage 在满足 f 的备份中分页，参数与返回值见 Store.ListBackupsPaged。
+func (s *Service) ListBackupsPage(f BackupFilter, order BackupSort, cursor string, offset, limit int) (*BackupPage, error) {
+	return s.store.ListBackupsPaged(f, order, cursor, offset, limit)
+}
+
+// GetBackup 返回指定 ID 的备份条目，不存在时返回 ErrBackupNotFound。
+func (s *Service) GetBackup(id string) (*BackupItem, error) {
+	return s.store.FindByID(id)
+}
+
+// UpdateRemark 更新备注，备注不能为空；恢复默认备注使用 ClearRemark。
 func (s *Service) UpdateRemark(id, remark string) (*BackupItem, error) {
-	return s.store.UpdateRemark(id, strings.TrimSpace(remark))
+	remark, err := s.ValidateRemark(remark, false)
+	if err != nil {
+		return nil, err
+	}
+	item, err := s.store.UpdateRemark(id, remark)
+	if err == nil {
+		s.emit(Event{Type: EventBackupRemarkUpdated, ItemID: id, Remark: item.Remark})
+	}
+	return item, err
 }
 
-// RestoreBackup 将备份还原为目标文件。
-func (s *Service) RestoreBackup(id string) error {
-	item, err := s.store.FindByID(id)
+// ClearRemark 清除自定义备注，改为按创建时间重新生成的默认备注。
+func (s *Service) ClearRemark(id string) (*BackupItem, error) {
+	item, err := s.store.ResetRemark(id)
+	if err == nil {
+		s.emit(Event{Type: EventBackupRemarkUpdated, ItemID: id, Remark: item.Remark})
+	}
+	return item, err
+}
+
+// RestoreBackup 将备份还原为目标文件，返回被还原的备份条目。
+func (s *Service) RestoreBackup(id string) (*BackupItem, error) {
+	return s.RestoreBackupContext(context.Background(), id)
+}
+
+// RestoreByRemark 将备注对应的备份还原为目标文件，备注未登记时返回 ErrBackupNotFound。
+func (s *Service) RestoreByRemark(remark string) (*BackupItem, error) {
+	return s.RestoreByRemarkContext(context.Background(), remark)
+}
+
+// RestoreByRemarkContext 与 RestoreByRemark 相同，ctx 的用法见 RestoreBackupContext。
+func (s *Service) RestoreByRemarkContext(ctx context.Context, remark string) (*BackupItem, error) {
+	item, err := s.store.FindByRemark(remark)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	path := filepath.Join(s.cfg.BackupsDir, item.Filename)
-	data, err := os.ReadFile(path)
+	return s.RestoreBackupContext(ctx, item.ID)
+}
+
+// RestoreBackupContext 与 RestoreBackup 相同；ctx 携带 span 时还原记录为其子 span。
+func (s *Service) RestoreBackupContext(ctx context.Context, id string) (item *BackupItem, err error) {
+	_, span := s.tracer.Start(ctx, "restore", tracing.KindInternal, tracing.String("backup.id", id))
+	defer func() {
+		span.SetError(err)
+		span.End()
+	}()
+	if err := s.RequireCapability(CapRestore, CapTargetWrite); err != nil {
+		return nil, err
+	}
+	// 索引签名不符时其中的条目不可信，拒绝据此覆盖目标文件。
+	if tampered, err := s.store.Tampered(); err != nil {
+		return nil, err
+	} else if tampered {
+		return nil, ErrIndexTampered
+	}
+	start := time.Now()
+	item, data, err := s.readBackup(id)
 	if err != nil {
-		return fmt.Errorf("读取备份文件: %w", err)
+		return nil, err
 	}
 	if err := util.EnsureDir(filepath.Dir(s.cfg.TargetPath)); err != nil {
-		return fmt.Errorf("确保目标目录: %w", err)
+		return nil, fmt.Errorf("确保目标目录: %w", err)
 	}
-	if err := util.AtomicWriteFile(s.cfg.TargetPath, data, 0o600); err != nil {
-		return fmt.Errorf("写入目标文件: %w", err)
+	if err := s.writeTargetFile(data); err != nil {
+		return nil, err
 	}
-	if res, err := ComputeFingerprint(s.cfg.TargetPath); err == nil {
+	if res, err := s.fingerprintTarget(); err == nil {
 		if _, err := s.store.UpdateLatestFingerprint(res.Fingerprint); err != nil {
-			s.logger.Printf("更新指纹失败: %v", err)
+			s.logger.Warn("更新指纹失败", "error", err)
 		}
 	}
-	s.logger.Printf("还原完成 id=%s -> %s", id, s.cfg.TargetPath)
-	return nil
+	s.latency.observe(PhaseRestore, time.Since(start))
+	s.metrics.restores.Inc()
+	s.emit(Event{Type: EventBackupRestored, ItemID: id, Remark: item.Remark})
+	s.logger.Info("还原完成", "id", id, "target", s.cfg.TargetPath)
+	return item, nil
+}
+
+// OpenBackup 打开备份文件供流式读取，调用方负责关闭。返回的内容总是备份的原始内容：加密的备份先整体解密，
+// 压缩的备份在读取时解压。返回条目的 Size 为读取内容的实际大小，压缩的备份解压前无法得知，为 -1。
+// 索引中存在该备份但文件已丢失时返回 ErrBackupFileMissing。
+func (s *Service) OpenBackup(id string) (io.ReadCloser, *BackupItem, error) {
+	item, err := s.store.FindByID(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	path, err := util.SecureJoin(s.cfg.BackupsDir, item.Filename)
+	if err != nil {
+		return nil, nil, fmt.Errorf("备份文件名: %w", err)
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil, fmt.Errorf("%w: %s", ErrBackupFileMissing, item.Filename)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("打开备份文件: %w", err)
+	}
+	var rc io.ReadCloser = f
+	if item.Encrypted {
+		data, err := io.ReadAll(f)
+		f.Close()
+		if err != nil {
+			return nil, nil, fmt.Errorf("读取备份文件: %w", err)
+		}
+		if data, err = s.decryptBackupData(item, data); err != nil {
+			return nil, nil, err
+		}
+		item.Size = int64(len(data))
+		rc = io.NopCloser(bytes.NewReader(data))
+	} else {
+		info, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return nil, nil, fmt.Errorf("读取备份文件信息: %w", err)
+		}
+		item.Size = info.Size()
+	}
+	if !IsCompressedBackup(item.Filename) {
+		return rc, item, nil
+	}
+	zr, err := gzip.NewReader(rc)
+	if err != nil {
+		rc.Close()
+		return nil, nil, fmt.Errorf("解压备份文件 %s: %w", item.Filename, err)
+	}
+	item.Size = -1
+	return &gzipReadCloser{Reader: zr, src: rc}, item, nil
+}
+
+// readBackup 查找备份条目并读取对应的备份文件内容，加密或压缩的备份返回解密、解压后的原始内容。
+func (s *Service) readBackup(id string) (*BackupItem, []byte, error) {
+	item, err := s.store.FindByID(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	path, err := util.SecureJoin(s.cfg.BackupsDir, item.Filename)
+	if err != nil {
+		return nil, nil, fmt.Errorf("备份文件名: %w", err)
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil, fmt.Errorf("%w: %s", ErrBackupFileMissing, item.Filename)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("读取备份文件: %w", err)
+	}
+	if data, err = s.decryptBackupData(item, data); err != nil {
+		return nil, nil, err
+	}
+	data, err = decodeBackupData(item.Filename, data)
+	if err != nil {
+		return nil, nil, err
+	}
+	return item, data, nil
 }
 
 // DeleteBackup 删除备份。
 func (s *Service) DeleteBackup(id string) error {
-	item, err := s.store.DeleteBackup(id)
-	if err != nil {
+	if err := s.RequireCapability(CapDelete); err != nil {
 		return err
 	}
-	path := filepath.Join(s.cfg.BackupsDir, item.Filename)
-	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
-		s.logger.Printf("删除备份文件失败: %v", err)
+	item, err := s.removeBackup(id, "")
+	if err != nil {
+		return err
 	}
-	s.logger.Printf("删除备份 id=%s remark=%q", id, item.Remark)
+	s.logger.Info("删除备份", "id", id, "remark", item.Remark)
 	return nil
 }
 
+// removeBackup 删除索引条目与备份文件并发出 backup.deleted 事件，reason 写入事件的 Reason。不检查能力开关。
+func (s *Service) removeBackup(id, reason string) (*BackupItem, error) {
+	item, err := s.store.DeleteBackup(id)
+	if err != nil {
+		return nil, err
+	}
+	path, err := util.SecureJoin(s.cfg.BackupsDir, item.Filename)
+	if err != nil {
+		s.logger.Warn("跳过删除不安全的备份文件名", "id", id, "filename", item.Filename, "error", err)
+	} else if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		s.logger.Error("删除备份文件失败", "id", id, "filename", item.Filename, "error", err)
+	}
+	s.metrics.backupsDeleted.Inc()
+	s.emit(Event{Type: EventBackupDeleted, ItemID: id, Remark: item.Remark, Reason: reason})
+	return item, nil
+}
+
+// Tracer 返回追踪器，未配置追踪时为 nil。
+func (s *Service) Tracer() *tracing.Tracer {
+	return s.tracer
+}
+
 // CodexLogin 执行 codex login 命令。
 func (s *Service) CodexLogin(ctx context.Context) (string, string, int, error) {
-	return RunCodexLogin(ctx)
+	if err := s.RequireCapability(CapCodexLogin, CapTargetWrite); err != nil {
+		return "", "", 0, err
+	}
+	stdout, stderr, exitCode, err := RunCodexLogin(ctx)
+	if err != nil {
+		s.logger.Error("codex login 失败", "exit_code", exitCode, "error", err, "stderr", tail(util.Redact(stderr), 512))
+	} else {
+		s.logger.Info("codex login 完成", "exit_code", exitCode)
+	}
+	return stdout, stderr, exitCode, err
+}
+
+// tail 截取字符串末尾最多 n 个字节，用于日志中展示命令输出。
+func tail(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return "…" + s[len(s)-n:]
+}
+
+// MigrateRemarks 为缺少备注的旧备份补齐默认备注。
+func (s *Service) MigrateRemarks() (int, error) {
+	n, err := s.store.MigrateRemarks()
+	if err != nil {
+		return 0, err
+	}
+	if n > 0 {
+		s.logger.Info("已为旧备份补齐备注", "count", n)
+	}
+	return n, nil
 }
 
 // Config 返回当前配置。
 func (s *Service) Config() Config {
 	return s.cfg
 }
+
+// Tokens 返回 API 令牌存储。
+func (s *Service) Tokens() *TokenStore {
+	return s.tokens
+}
+
+// AcceptIndex 确认当前 index.json 内容可信并重新签名，解除只读状态。
+func (s *Service) AcceptIndex() error {
+	if err := s.store.AcceptIndex(); err != nil {
+		return err
+	}
+	s.emit(Event{Type: EventIndexAccepted})
+	return nil
+}
+
+// Audit 返回审计日志。
+func (s *Service) Audit() *AuditLog {
+	return s.audit
+}
+
+// Events 返回持久化的事件日志。
+func (s *Service) Events() *EventLog {
+	return s.events
+}
+
+// RecordAudit 写入一条审计记录，失败时只记录日志，不影响已完成的操作。
+func (s *Service) RecordAudit(entry AuditEntry) {
+	entry.InstanceID = s.instanceID
+	if err := s.audit.Record(entry); err != nil {
+		s.logger.Error("写入审计日志失败", "op", entry.Op, "error", err)
+	}
+}
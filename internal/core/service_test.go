@@ -1,15 +1,27 @@
 package core_test
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
-	"log"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/zalando/go-keyring"
+
 	"codex-backup-tool/internal/core"
+	"codex-backup-tool/internal/util"
 )
 
 func TestServiceBackupLifecycle(t *testing.T) {
@@ -90,9 +102,13 @@ func TestServiceBackupLifecycle(t *testing.T) {
 	if err := os.WriteFile(target, []byte(`{"token":"gamma"}`), 0o600); err != nil {
 		t.Fatalf("overwrite target: %v", err)
 	}
-	if err := svc.RestoreBackup(first.ID); err != nil {
+	restored, err := svc.RestoreBackup(first.ID)
+	if err != nil {
 		t.Fatalf("restore: %v", err)
 	}
+	if restored.ID != first.ID || restored.Remark != "my-manual" {
+		t.Fatalf("unexpected restored item: %+v", restored)
+	}
 	after, err := os.ReadFile(target)
 	if err != nil {
 		t.Fatalf("read restored: %v", err)
@@ -100,8 +116,12 @@ func TestServiceBackupLifecycle(t *testing.T) {
 	if string(after) != string(original) {
 		t.Fatalf("restore content mismatch: got %s", after)
 	}
-	// 删除最新备份后 latest_fingerprint 应回退
+	// 还原后 latest_fingerprint 对应目标文件，删除与之无关的最新备份不应改变它
 	svcCfg := svc.Config()
+	live, err := core.ComputeFingerprint(target, svcCfg.FingerprintSampleSize)
+	if err != nil {
+		t.Fatalf("fingerprint target: %v", err)
+	}
 	if err := svc.DeleteBackup(latest.ID); err != nil {
 		t.Fatalf("delete latest: %v", err)
 	}
@@ -121,25 +141,1714 @@ func TestServiceBackupLifecycle(t *testing.T) {
 	if len(idx.Items) != 1 {
 		t.Fatalf("expected 1 item after delete, got %d", len(idx.Items))
 	}
-	if idx.LatestFingerprint != first.FileFingerprint {
-		t.Fatalf("latest fingerprint mismatch after delete: want %s got %s", first.FileFingerprint, idx.LatestFingerprint)
+	if idx.LatestFingerprint != live.Fingerprint {
+		t.Fatalf("latest fingerprint mismatch after delete: want %s got %s", live.Fingerprint, idx.LatestFingerprint)
 	}
 }
 
-func newTestService(t *testing.T) (*core.Service, func()) {
-	t.Helper()
+func TestScanRefusesWhenDiskReserveExceeded(t *testing.T) {
+	svc, cleanup := newTestService(t, func(cfg *core.Config) {
+		cfg.DiskReserve = 1 << 62
+	})
+	defer cleanup()
+	writeTarget(t, svc, `{"token":"alpha"}`)
+
+	res, err := svc.CreateBackup(nil)
+	if err != nil {
+		t.Fatalf("backup: %v", err)
+	}
+	if res.Created || res.Code != core.ReasonDiskFull {
+		t.Fatalf("expected DISK_FULL refusal, got %+v", res)
+	}
+	items, err := svc.ListBackups()
+	if err != nil {
+		t.Fatalf("list backups: %v", err)
+	}
+	if len(items) != 0 {
+		t.Fatalf("expected no backups, got %d", len(items))
+	}
+}
+
+func TestOversizedTargetIsRefused(t *testing.T) {
+	svc, cleanup := newTestService(t, func(cfg *core.Config) {
+		cfg.MaxTargetSize = 8
+	})
+	defer cleanup()
+	writeTarget(t, svc, `{"token":"alpha"}`)
+
+	res, err := svc.CreateBackup(nil)
+	if err != nil {
+		t.Fatalf("backup: %v", err)
+	}
+	if res.Created || res.Code != core.ReasonTargetTooLarge {
+		t.Fatalf("expected TARGET_TOO_LARGE refusal, got %+v", res)
+	}
+	status, err := svc.Status()
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	if !status.Exists || status.ContentHash != "" || len(status.Warnings) != 1 {
+		t.Fatalf("expected oversized warning without content hash, got %+v", status)
+	}
+}
+
+func TestMissingTargetDirIsReportedInStatus(t *testing.T) {
+	svc, cleanup := newTestService(t)
+	defer cleanup()
+
+	status, err := svc.Status()
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	if status.Exists || len(status.Warnings) != 1 {
+		t.Fatalf("expected missing target dir warning, got %+v", status)
+	}
+	writeTarget(t, svc, `{"token":"alpha"}`)
+	status, err = svc.Status()
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	if len(status.Warnings) != 0 {
+		t.Fatalf("expected no warnings once target exists, got %v", status.Warnings)
+	}
+}
+
+func TestTraversalFilenameInIndexIsRejected(t *testing.T) {
+	svc, cleanup := newTestService(t)
+	defer cleanup()
+	writeTarget(t, svc, `{"token":"alpha"}`)
+	res, err := svc.CreateBackup(nil)
+	if err != nil || !res.Created {
+		t.Fatalf("backup: %+v %v", res, err)
+	}
+	cfg := svc.Config()
+	outside := filepath.Join(cfg.DataDir, "outside.json")
+	if err := os.WriteFile(outside, []byte("keep me"), 0o600); err != nil {
+		t.Fatalf("write outside: %v", err)
+	}
+	for _, name := range []string{"../outside.json", `..\outside.json`} {
+		raw, err := os.ReadFile(cfg.IndexPath)
+		if err != nil {
+			t.Fatalf("read index: %v", err)
+		}
+		var idx map[string]any
+		if err := json.Unmarshal(raw, &idx); err != nil {
+			t.Fatalf("unmarshal index: %v", err)
+		}
+		idx["items"].([]any)[0].(map[string]any)["filename"] = name
+		raw, _ = json.Marshal(idx)
+		if err := os.WriteFile(cfg.IndexPath, raw, 0o600); err != nil {
+			t.Fatalf("write index: %v", err)
+		}
+
+		if _, err := svc.RestoreBackup(res.Item.ID); !errors.Is(err, util.ErrUnsafePath) {
+			t.Fatalf("restore %q: expected ErrUnsafePath, got %v", name, err)
+		}
+		report, err := svc.CheckConsistency()
+		if err != nil {
+			t.Fatalf("check consistency: %v", err)
+		}
+		flagged := false
+		for _, issue := range report.Issues {
+			if issue.Code == core.IssueUnsafeFilename && issue.ItemID == res.Item.ID {
+				flagged = true
+			}
+		}
+		if !flagged {
+			t.Fatalf("expected %q to be flagged, got %+v", name, report.Issues)
+		}
+	}
+	if err := svc.DeleteBackup(res.Item.ID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := os.Stat(outside); err != nil {
+		t.Fatalf("file outside backups dir must survive delete: %v", err)
+	}
+}
+
+func TestStartupSweepsAgedTempFiles(t *testing.T) {
+	svc, cleanup := newTestService(t)
+	cfg := svc.Config()
+	cleanup()
+	aged := filepath.Join(cfg.BackupsDir, ".tmp-4242")
+	fresh := filepath.Join(cfg.DataDir, ".tmp-4343")
+	for _, p := range []string{aged, fresh} {
+		if err := os.WriteFile(p, []byte("partial"), 0o600); err != nil {
+			t.Fatalf("write temp: %v", err)
+		}
+	}
+	old := time.Now().Add(-3 * time.Hour)
+	if err := os.Chtimes(aged, old, old); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+	svc2, err := core.NewService(cfg, core.NewJSONLogger(io.Discard, slog.LevelInfo))
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	defer svc2.Stop()
+	if svc2.TempFilesSwept() != 1 {
+		t.Fatalf("expected 1 swept file, got %d", svc2.TempFilesSwept())
+	}
+	if _, err := os.Stat(aged); !os.IsNotExist(err) {
+		t.Fatalf("aged temp file should be removed, stat err=%v", err)
+	}
+	if _, err := os.Stat(fresh); err != nil {
+		t.Fatalf("fresh temp file should be kept: %v", err)
+	}
+}
+
+func TestOperationsNeverLogTargetSecrets(t *testing.T) {
+	const canary = "canary-secret-5f1d2c7a"
+	var buf bytes.Buffer
 	base := t.TempDir()
-	targetDir := filepath.Join(base, "codex")
 	dataDir := filepath.Join(base, "data")
-	cfg := core.Config{
-		TargetPath:   filepath.Join(targetDir, "auth.json"),
+	svc, err := core.NewService(core.Config{
+		TargetPath:   filepath.Join(base, "codex", "auth.json"),
 		DataDir:      dataDir,
 		BackupsDir:   filepath.Join(dataDir, "backups"),
 		IndexPath:    filepath.Join(dataDir, "index.json"),
 		ScanInterval: time.Second,
-		Port:         "0",
+	}, core.NewJSONLogger(&buf, slog.LevelDebug))
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	defer svc.Stop()
+
+	writeTarget(t, svc, `{"OPENAI_API_KEY":"sk-`+canary+`","tokens":{"access_token":"`+canary+`"}}`)
+	res, err := svc.CreateBackup(nil)
+	if err != nil || !res.Created {
+		t.Fatalf("backup: %+v %v", res, err)
+	}
+	if _, err := svc.Scan(true, nil); err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	writeTarget(t, svc, `{"tokens":{"refresh_token":"`+canary+`-2"}}`)
+	if _, err := svc.Scan(true, nil); err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	if _, err := svc.Status(); err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	if _, err := svc.UpdateRemark(res.Item.ID, "renamed"); err != nil {
+		t.Fatalf("remark: %v", err)
+	}
+	if _, err := svc.RestoreBackup(res.Item.ID); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if _, err := svc.CheckConsistency(); err != nil {
+		t.Fatalf("check: %v", err)
+	}
+	if err := svc.DeleteBackup(res.Item.ID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if buf.Len() == 0 {
+		t.Fatalf("expected operations to produce log output")
+	}
+	if strings.Contains(buf.String(), canary) {
+		t.Fatalf("log output leaked the canary secret:\n%s", buf.String())
+	}
+}
+
+func TestPurgeAllResetsToFreshInstall(t *testing.T) {
+	svc, cleanup := newTestService(t, func(cfg *core.Config) {
+		cfg.IndexBackupCount = 2
+	})
+	defer cleanup()
+	writeTarget(t, svc, `{"token":"alpha"}`)
+	if res, err := svc.CreateBackup(nil); err != nil || !res.Created {
+		t.Fatalf("backup: %+v %v", res, err)
+	}
+	writeTarget(t, svc, `{"token":"beta"}`)
+	if res, err := svc.CreateBackup(nil); err != nil || !res.Created {
+		t.Fatalf("backup: %+v %v", res, err)
+	}
+
+	svc.RecordAudit(core.AuditEntry{Op: "backup.create", Source: core.AuditSourceCLI})
+
+	if err := svc.PurgeAll(context.Background()); err != nil {
+		t.Fatalf("purge: %v", err)
+	}
+	cfg := svc.Config()
+	for _, dir := range []string{cfg.DataDir, cfg.BackupsDir} {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatalf("read %s: %v", dir, err)
+		}
+		for _, e := range entries {
+			path := filepath.Join(dir, e.Name())
+			if !e.IsDir() && path != cfg.AuditPath && path != cfg.EventsPath && path != cfg.DataDirLockFile && e.Name() != "instance_id" {
+				t.Fatalf("unexpected file left after purge: %s", path)
+			}
+		}
+	}
+	items, err := svc.ListBackups()
+	if err != nil || len(items) != 0 {
+		t.Fatalf("expected no backups after purge, got %d %v", len(items), err)
+	}
+	// 审计日志不随数据一起清空
+	if entries, err := svc.Audit().Recent(10); err != nil || len(entries) != 1 {
+		t.Fatalf("expected audit log to survive purge, got %+v %v", entries, err)
+	}
+	// 清空后与全新安装一样，当前目标文件会被重新备份
+	if res, err := svc.CreateBackup(nil); err != nil || !res.Created {
+		t.Fatalf("backup after purge: %+v %v", res, err)
+	}
+	if _, err := os.Stat(cfg.TargetPath); err != nil {
+		t.Fatalf("purge must not touch the target file: %v", err)
+	}
+}
+
+func TestPurgeAllRefusesTargetDir(t *testing.T) {
+	svc, cleanup := newTestService(t, func(cfg *core.Config) {
+		cfg.DataDir = filepath.Dir(cfg.TargetPath)
+	})
+	defer cleanup()
+	writeTarget(t, svc, `{"token":"alpha"}`)
+	if err := svc.PurgeAll(context.Background()); !errors.Is(err, core.ErrUnsafePurge) {
+		t.Fatalf("expected ErrUnsafePurge, got %v", err)
+	}
+	if _, err := os.Stat(svc.Config().TargetPath); err != nil {
+		t.Fatalf("target must survive refused purge: %v", err)
+	}
+}
+
+func TestCheckExposure(t *testing.T) {
+	svc, cleanup := newTestService(t, func(cfg *core.Config) { cfg.BindHost = "::1" })
+	defer cleanup()
+	if err := svc.CheckExposure(); err != nil {
+		t.Fatalf("loopback should pass: %v", err)
+	}
+
+	remote, cleanupRemote := newTestService(t, func(cfg *core.Config) { cfg.BindHost = "0.0.0.0" })
+	defer cleanupRemote()
+	if err := remote.CheckExposure(); !errors.Is(err, core.ErrUnauthenticatedRemote) {
+		t.Fatalf("expected ErrUnauthenticatedRemote, got %v", err)
+	}
+	if _, _, err := remote.Tokens().Create("ci", nil); err != nil {
+		t.Fatalf("create token: %v", err)
+	}
+	if err := remote.CheckExposure(); err != nil {
+		t.Fatalf("remote with token should pass: %v", err)
+	}
+}
+
+func TestDiffBackupsJSON(t *testing.T) {
+	svc, cleanup := newTestService(t)
+	defer cleanup()
+
+	writeTarget(t, svc, `{"a/b":1,"keep":true,"gone":"x","list":[1,2],"nested":{"n":1}}`)
+	first, err := svc.CreateBackup(nil)
+	if err != nil {
+		t.Fatalf("first backup: %v", err)
+	}
+	writeTarget(t, svc, `{"a/b":2,"keep":true,"list":[1,2,3],"nested":{"n":1,"m":"new"}}`)
+	second, err := svc.CreateBackup(nil)
+	if err != nil {
+		t.Fatalf("second backup: %v", err)
+	}
+
+	diffs, err := svc.DiffBackupsJSON(first.Item.ID, second.Item.ID)
+	if err != nil {
+		t.Fatalf("diff: %v", err)
+	}
+	want := []core.JSONKeyDiff{
+		{Path: "/a~1b", Op: core.DiffOpReplace, Before: json.RawMessage(`1`), After: json.RawMessage(`2`)},
+		{Path: "/gone", Op: core.DiffOpRemove, Before: json.RawMessage(`"x"`)},
+		{Path: "/list/2", Op: core.DiffOpAdd, After: json.RawMessage(`3`)},
+		{Path: "/nested/m", Op: core.DiffOpAdd, After: json.RawMessage(`"new"`)},
+	}
+	got, _ := json.Marshal(diffs)
+	expected, _ := json.Marshal(want)
+	if !bytes.Equal(got, expected) {
+		t.Fatalf("unexpected diff:\n got %s\nwant %s", got, expected)
+	}
+
+	same, err := svc.DiffBackupsJSON(first.Item.ID, first.Item.ID)
+	if err != nil || len(same) != 0 {
+		t.Fatalf("expected no changes for identical backups, got %v %v", same, err)
+	}
+
+	writeTarget(t, svc, "not json")
+	plain, err := svc.CreateBackup(nil)
+	if err != nil {
+		t.Fatalf("plain backup: %v", err)
+	}
+	if _, err := svc.DiffBackupsJSON(first.Item.ID, plain.Item.ID); !errors.Is(err, core.ErrNotJSON) {
+		t.Fatalf("expected ErrNotJSON, got %v", err)
+	}
+}
+
+func TestStatusReportsLastScan(t *testing.T) {
+	svc, cleanup := newTestService(t, func(cfg *core.Config) { cfg.ScanInterval = time.Hour })
+	defer cleanup()
+
+	status, err := svc.Status()
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	if status.LastScanAt != "" || status.LastScanTrigger != "" || status.NextScanETASeconds != -1 {
+		t.Fatalf("expected empty scan state before first scan, got %+v", status)
+	}
+
+	if _, err := svc.Scan(false, nil); err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	svc.Start(context.Background())
+	status, err = svc.Status()
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	if status.LastScanAt == "" || status.LastScanTrigger != core.ScanTriggerManual || status.LastScanReasonCode != core.ReasonTargetMissing {
+		t.Fatalf("unexpected last scan: %+v", status)
+	}
+	if status.NextScanETASeconds <= 0 || status.NextScanETASeconds > 3600 {
+		t.Fatalf("expected eta within the interval, got %d", status.NextScanETASeconds)
+	}
+}
+
+func TestWatcherCoalescesRapidWrites(t *testing.T) {
+	svc, cleanup := newTestService(t, func(cfg *core.Config) {
+		cfg.ScanInterval = time.Hour
+		cfg.Watch = true
+		cfg.WatchDebounce = 200 * time.Millisecond
+	})
+	defer cleanup()
+	writeTarget(t, svc, `{"token":"initial"}`)
+	svc.Start(context.Background())
+	status, err := svc.Status()
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	if !status.Watching {
+		t.Skip("file watching is not available on this platform")
+	}
+	if status.NextScanETASeconds < 3500 {
+		t.Fatalf("expected the hourly fallback scan to be scheduled, got eta %d", status.NextScanETASeconds)
+	}
+
+	// 快速连续写入，间隔远小于 debounce，只应触发一次扫描。
+	for i := 0; i < 10; i++ {
+		writeTarget(t, svc, fmt.Sprintf(`{"token":"rapid-%d"}`, i))
+		time.Sleep(5 * time.Millisecond)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	var items []core.BackupItem
+	for time.Now().Before(deadline) {
+		if items, err = svc.ListBackups(); err != nil {
+			t.Fatalf("list: %v", err)
+		}
+		if len(items) > 0 {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	time.Sleep(500 * time.Millisecond)
+	if items, err = svc.ListBackups(); err != nil || len(items) != 1 {
+		t.Fatalf("expected exactly one backup after rapid writes, got %d (%v)", len(items), err)
+	}
+	data, err := os.ReadFile(filepath.Join(svc.Config().BackupsDir, items[0].Filename))
+	if err != nil || string(data) != `{"token":"rapid-9"}` {
+		t.Fatalf("expected the final content to be backed up, got %q (%v)", data, err)
+	}
+}
+
+func TestDiffBackupsMasksSecrets(t *testing.T) {
+	svc, cleanup := newTestService(t)
+	defer cleanup()
+
+	writeTarget(t, svc, `{"tokens":{"access_token":"eyJold-0123456789-aaaa","account_id":"acct-1"},"keys":["sk-one-0123456789"]}`)
+	first, err := svc.CreateBackup(nil)
+	if err != nil {
+		t.Fatalf("first backup: %v", err)
+	}
+	writeTarget(t, svc, `{"tokens":{"access_token":"eyJnew-0123456789-bbbb","account_id":"acct-2"},"keys":["sk-one-0123456789","sk-two-0123456789"]}`)
+	second, err := svc.CreateBackup(nil)
+	if err != nil {
+		t.Fatalf("second backup: %v", err)
+	}
+	res, err := svc.DiffBackups(first.Item.ID, second.Item.ID)
+	if err != nil {
+		t.Fatalf("diff: %v", err)
+	}
+	want := []core.JSONKeyDiff{
+		{Path: "/keys/1", Op: core.DiffOpAdd, After: json.RawMessage(`"sk-...6789"`)},
+		{Path: "/tokens/access_token", Op: core.DiffOpReplace, Before: json.RawMessage(`"eyJ...aaaa"`), After: json.RawMessage(`"eyJ...bbbb"`)},
+		{Path: "/tokens/account_id", Op: core.DiffOpReplace, Before: json.RawMessage(`"acct-1"`), After: json.RawMessage(`"acct-2"`)},
+	}
+	got, _ := json.Marshal(res.Changes)
+	expected, _ := json.Marshal(want)
+	if res.Format != core.DiffFormatJSON || res.Same || !bytes.Equal(got, expected) {
+		t.Fatalf("unexpected diff %s:\n got %s\nwant %s", res.Format, got, expected)
+	}
+}
+
+func TestDiffBackupsFallsBackToText(t *testing.T) {
+	svc, cleanup := newTestService(t)
+	defer cleanup()
+
+	lines := []string{"line1", "line2", "line3", "line4", "line5", "line6", "line7", "line8", "line9", "line10", "line11", "line12"}
+	writeTarget(t, svc, strings.Join(lines, "\n")+"\n")
+	first, err := svc.CreateBackup(nil)
+	if err != nil {
+		t.Fatalf("first backup: %v", err)
+	}
+	lines[2] = "changed"
+	writeTarget(t, svc, strings.Join(append(lines, "line13"), "\n")+"\n")
+	second, err := svc.CreateBackup(nil)
+	if err != nil {
+		t.Fatalf("second backup: %v", err)
+	}
+	res, err := svc.DiffBackups(first.Item.ID, second.Item.ID)
+	if err != nil {
+		t.Fatalf("diff: %v", err)
+	}
+	want := "--- a/" + first.Item.Filename + "\n+++ b/" + second.Item.Filename + "\n" +
+		"@@ -1,6 +1,6 @@\n line1\n line2\n-line3\n+changed\n line4\n line5\n line6\n" +
+		"@@ -10,3 +10,4 @@\n line10\n line11\n line12\n+line13\n"
+	if res.Format != core.DiffFormatText || res.Unified != want {
+		t.Fatalf("unexpected text diff %s:\n%s", res.Format, res.Unified)
+	}
+	if same, err := svc.DiffBackups(first.Item.ID, first.Item.ID); err != nil || !same.Same || same.Unified != "" {
+		t.Fatalf("expected identical backups to be same, got %+v (%v)", same, err)
+	}
+}
+
+func TestDiffAgainstTarget(t *testing.T) {
+	svc, cleanup := newTestService(t)
+	defer cleanup()
+
+	writeTarget(t, svc, `{"access_token":"sk-abcdefghijklmnop","user":"alice"}`)
+	created, err := svc.CreateBackup(nil)
+	if err != nil {
+		t.Fatalf("backup: %v", err)
+	}
+	id := created.Item.ID
+	res, err := svc.DiffAgainstTarget(id)
+	if err != nil || !res.Same || res.Format != core.DiffFormatJSON || res.From != core.DiffTarget || res.To != id {
+		t.Fatalf("expected identical content to be same, got %+v (%v)", res, err)
+	}
+
+	writeTarget(t, svc, `{"user":"bob"}`)
+	res, err = svc.DiffAgainstTarget(id)
+	if err != nil || res.Same || len(res.Changes) != 2 {
+		t.Fatalf("expected two changes, got %+v (%v)", res, err)
+	}
+	if c := res.Changes[0]; c.Path != "/access_token" || c.Op != core.DiffOpAdd || string(c.After) != `"sk-...mnop"` {
+		t.Fatalf("expected masked token added by restore, got %+v", c)
+	}
+
+	if err := os.Remove(svc.Config().TargetPath); err != nil {
+		t.Fatalf("remove target: %v", err)
+	}
+	res, err = svc.DiffAgainstTarget(id)
+	if err != nil || !res.TargetMissing || res.Same || len(res.Changes) != 2 {
+		t.Fatalf("expected every field added with missing target, got %+v (%v)", res, err)
+	}
+	for _, c := range res.Changes {
+		if c.Op != core.DiffOpAdd || len(c.Before) != 0 {
+			t.Fatalf("expected add without before, got %+v", c)
+		}
+	}
+}
+
+func TestCompareTargetToLatest(t *testing.T) {
+	svc, cleanup := newTestService(t)
+	defer cleanup()
+
+	if _, err := svc.CompareTargetToLatest(); !errors.Is(err, core.ErrBackupNotFound) {
+		t.Fatalf("expected ErrBackupNotFound without backups, got %v", err)
+	}
+
+	writeTarget(t, svc, `{"token":"a","keep":1}`)
+	created, err := svc.Scan(false, nil)
+	if err != nil || !created.Created {
+		t.Fatalf("scan: %+v %v", created, err)
+	}
+	res, err := svc.CompareTargetToLatest()
+	if err != nil {
+		t.Fatalf("compare: %v", err)
+	}
+	if !res.Same || res.Diff != "" || res.LatestBackup.ID != created.Item.ID || res.CurrentContentHash != created.Item.ContentHash {
+		t.Fatalf("expected same content, got %+v", res)
+	}
+
+	writeTarget(t, svc, `{"token":"b","keep":1,"extra":true}`)
+	res, err = svc.CompareTargetToLatest()
+	if err != nil {
+		t.Fatalf("compare: %v", err)
+	}
+	if res.Same || res.Diff != "add /extra\nreplace /token" {
+		t.Fatalf("expected key diff, got %+v", res)
+	}
+	if strings.Contains(res.Diff, `"b"`) {
+		t.Fatalf("diff must not include values: %q", res.Diff)
+	}
+}
+
+func TestNewServiceRejectsLockedDataDir(t *testing.T) {
+	if testing.Short() {
+		t.Skip("waits for the instance lock timeout")
+	}
+	svc, cleanup := newTestService(t)
+	cfg := svc.Config()
+
+	_, err := core.NewService(cfg, core.NewJSONLogger(io.Discard, slog.LevelInfo))
+	var lockErr *core.DataDirLockedError
+	if !errors.Is(err, core.ErrDataDirLocked) || !errors.As(err, &lockErr) || lockErr.PID != os.Getpid() {
+		t.Fatalf("expected ErrDataDirLocked held by this process, got %v", err)
+	}
+
+	cleanup()
+	svc2, err := core.NewService(cfg, core.NewJSONLogger(io.Discard, slog.LevelInfo))
+	if err != nil {
+		t.Fatalf("expected lock to be released by Stop: %v", err)
+	}
+	svc2.Stop()
+}
+
+func TestStatsRecordsScanPhaseDurations(t *testing.T) {
+	svc, cleanup := newTestService(t)
+	defer cleanup()
+
+	stats := svc.Stats()
+	if stats.Latency[core.PhaseScan].Count != 0 || len(stats.Latency) == 0 {
+		t.Fatalf("expected empty stats for every phase, got %+v", stats)
+	}
+
+	writeTarget(t, svc, `{"token":"a"}`)
+	res, err := svc.Scan(false, nil)
+	if err != nil || !res.Created {
+		t.Fatalf("scan: %+v %v", res, err)
+	}
+	for _, phase := range []string{core.PhaseScan, core.PhaseStat, core.PhaseHash, core.PhaseWrite, core.PhaseIndex} {
+		if _, ok := res.DurationsMs[phase]; !ok {
+			t.Fatalf("expected %s duration in scan result, got %v", phase, res.DurationsMs)
+		}
+	}
+	if _, err := svc.Scan(false, nil); err != nil {
+		t.Fatalf("second scan: %v", err)
+	}
+	if _, err := svc.RestoreBackup(res.Item.ID); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+
+	stats = svc.Stats()
+	scan := stats.Latency[core.PhaseScan]
+	if scan.Count != 2 || stats.Latency[core.PhaseWrite].Count != 1 || stats.Latency[core.PhaseRestore].Count != 1 {
+		t.Fatalf("unexpected sample counts: %+v", stats.Latency)
+	}
+	if scan.P50Ms > scan.P95Ms || scan.P95Ms > scan.MaxMs {
+		t.Fatalf("percentiles out of order: %+v", scan)
+	}
+}
+
+func TestTargetHistorySamplesEachScan(t *testing.T) {
+	svc, cleanup := newTestService(t)
+
+	if _, err := svc.Scan(false, nil); err != nil {
+		t.Fatalf("scan missing target: %v", err)
+	}
+	writeTarget(t, svc, `{"token":"a"}`)
+	if _, err := svc.Scan(true, nil); err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	if _, err := svc.Scan(true, nil); err != nil {
+		t.Fatalf("scan unchanged: %v", err)
+	}
+	cfg := svc.Config()
+	cleanup()
+
+	// 重新打开服务后从文件恢复样本。
+	reopened, err := core.NewService(cfg, core.NewJSONLogger(io.Discard, slog.LevelInfo))
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer reopened.Stop()
+	samples, err := reopened.TargetHistory(time.Hour)
+	if err != nil {
+		t.Fatalf("history: %v", err)
+	}
+	if len(samples) != 2 || !samples[0].Changed || samples[1].Changed || samples[0].Size != int64(len(`{"token":"a"}`)) {
+		t.Fatalf("unexpected samples: %+v", samples)
+	}
+}
+
+func TestSelfTestReportsSoftFailures(t *testing.T) {
+	svc, cleanup := newTestService(t)
+	defer cleanup()
+	report := svc.LastSelfTest()
+	if report == nil || !report.Ready() {
+		t.Fatalf("expected startup self-test to pass, got %+v", report)
+	}
+	failed := map[string]bool{}
+	for _, c := range report.Checks {
+		if !c.Passed {
+			failed[c.Name] = c.Fatal
+		}
+	}
+	if fatal, ok := failed[core.CheckTargetDir]; !ok || fatal || len(failed) != 1 {
+		t.Fatalf("expected only a soft target_dir failure, got %+v", report.Checks)
+	}
+	if len(report.Warnings()) != 1 {
+		t.Fatalf("expected one warning, got %v", report.Warnings())
+	}
+
+	writeTarget(t, svc, `{"token":"a"}`)
+	report = svc.SelfTest()
+	if len(report.Warnings()) != 0 {
+		t.Fatalf("expected no warnings once target dir exists, got %v", report.Warnings())
+	}
+	entries, err := os.ReadDir(svc.Config().DataDir)
+	if err != nil {
+		t.Fatalf("read data dir: %v", err)
+	}
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), ".selftest") {
+			t.Fatalf("self-test left probe file %s", e.Name())
+		}
+	}
+}
+
+func TestInstanceIDSurvivesRestartAndIndexRebuild(t *testing.T) {
+	dataDir := filepath.Join(t.TempDir(), "data")
+	useDataDir := func(cfg *core.Config) {
+		cfg.DataDir = dataDir
+		cfg.BackupsDir = filepath.Join(dataDir, "backups")
+		cfg.IndexPath = filepath.Join(dataDir, "index.json")
+	}
+	svc, cleanup := newTestService(t, useDataDir)
+	status, err := svc.Status()
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	id := status.InstanceID
+	if id == "" || status.PID != os.Getpid() || status.DataDir != dataDir || status.StartedAt == "" || status.UptimeSeconds < 0 {
+		t.Fatalf("unexpected instance info: %+v", status)
+	}
+	svc.RecordAudit(core.AuditEntry{Op: "backup.create", Source: core.AuditSourceCLI})
+	entries, err := svc.Audit().Recent(1)
+	if err != nil || len(entries) != 1 || entries[0].InstanceID != id {
+		t.Fatalf("expected audit entry to carry instance id, got %+v %v", entries, err)
+	}
+	cleanup()
+
+	if err := os.Remove(filepath.Join(dataDir, "index.json")); err != nil && !os.IsNotExist(err) {
+		t.Fatalf("remove index: %v", err)
+	}
+	svc, cleanup = newTestService(t, useDataDir)
+	defer cleanup()
+	if svc.InstanceID() != id {
+		t.Fatalf("instance id changed across restart: %s -> %s", id, svc.InstanceID())
+	}
+}
+
+func TestStalenessAlarmFiresOnceAndClears(t *testing.T) {
+	svc, cleanup := newTestService(t, func(cfg *core.Config) { cfg.MaxTimeWithoutBackup = 200 * time.Millisecond })
+	defer cleanup()
+	status, err := svc.Status()
+	if err != nil || status.Stale || status.Staleness == nil {
+		t.Fatalf("expected fresh service not to be stale: %+v %v", status, err)
+	}
+	time.Sleep(250 * time.Millisecond)
+	svc.CheckStaleness()
+	svc.CheckStaleness()
+	status, err = svc.Status()
+	if err != nil || !status.Stale || status.Staleness.LastBackupAt != "" {
+		t.Fatalf("expected stale status: %+v %v", status.Staleness, err)
+	}
+	page, err := svc.Events().Query(core.EventQuery{Type: core.EventBackupStale})
+	if err != nil || len(page.Events) != 1 {
+		t.Fatalf("expected exactly one stale event, got %+v %v", page.Events, err)
+	}
+
+	writeTarget(t, svc, `{"token":"a"}`)
+	if res, err := svc.Scan(false, nil); err != nil || !res.Created {
+		t.Fatalf("scan: %+v %v", res, err)
+	}
+	status, err = svc.Status()
+	if err != nil || status.Stale || status.Staleness.LastBackupAt == "" || status.Staleness.LastSuccessfulScanAt == "" {
+		t.Fatalf("expected backup to clear staleness: %+v %v", status.Staleness, err)
+	}
+}
+
+func TestScanHistoryPersistsAcrossRestart(t *testing.T) {
+	dataDir := filepath.Join(t.TempDir(), "data")
+	useDataDir := func(cfg *core.Config) {
+		cfg.DataDir = dataDir
+		cfg.BackupsDir = filepath.Join(dataDir, "backups")
+		cfg.IndexPath = filepath.Join(dataDir, "index.json")
+		cfg.ScanHistoryPersist = 2
+	}
+	svc, cleanup := newTestService(t, useDataDir)
+	for i := 0; i < 3; i++ {
+		if _, err := svc.Scan(false, nil); err != nil {
+			t.Fatalf("scan: %v", err)
+		}
+	}
+	if got := svc.ScanHistory(10); len(got) != 3 || got[0].PreviousRun || got[0].Code != core.ReasonTargetMissing {
+		t.Fatalf("unexpected history: %+v", got)
+	}
+	cleanup()
+
+	svc, cleanup = newTestService(t, useDataDir)
+	writeTarget(t, svc, `{"token":"a"}`)
+	if _, err := svc.Scan(false, nil); err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	got := svc.ScanHistory(10)
+	if len(got) != 3 || got[0].PreviousRun || !got[0].Created || !got[1].PreviousRun || !got[2].PreviousRun {
+		t.Fatalf("expected one current and two previous-run records, got %+v", got)
+	}
+	cleanup()
+
+	if err := os.WriteFile(filepath.Join(dataDir, "scan-history.json"), []byte("{not json"), 0o600); err != nil {
+		t.Fatalf("corrupt history: %v", err)
+	}
+	svc, cleanup = newTestService(t, useDataDir)
+	defer cleanup()
+	if got := svc.ScanHistory(10); len(got) != 0 {
+		t.Fatalf("expected empty history after corruption, got %+v", got)
+	}
+}
+
+func TestScanRecordsBackupTrigger(t *testing.T) {
+	svc, cleanup := newTestService(t)
+	defer cleanup()
+	cases := []struct {
+		scan    func() (*core.ScanResult, error)
+		trigger string
+		auto    bool
+	}{
+		{func() (*core.ScanResult, error) { return svc.Scan(true, nil) }, core.BackupTriggerTicker, true},
+		{func() (*core.ScanResult, error) { return svc.CreateBackup(nil) }, core.BackupTriggerManualCreate, false},
+		{func() (*core.ScanResult, error) {
+			return svc.ScanWithOptions(context.Background(), core.ScanOptions{Auto: true, Trigger: core.BackupTriggerAPIScan})
+		}, core.BackupTriggerAPIScan, true},
+	}
+	for i, tc := range cases {
+		writeTarget(t, svc, fmt.Sprintf(`{"token":"%d"}`, i))
+		res, err := tc.scan()
+		if err != nil || !res.Created {
+			t.Fatalf("case %d: res=%+v err=%v", i, res, err)
+		}
+		if res.Item.Trigger != tc.trigger || res.Item.IsAuto != tc.auto {
+			t.Fatalf("case %d: got trigger=%q auto=%t", i, res.Item.Trigger, res.Item.IsAuto)
+		}
+	}
+}
+
+func TestDeleteBackupKeepsLatestFingerprint(t *testing.T) {
+	svc, cleanup := newTestService(t)
+	defer cleanup()
+	latestFingerprint := func() string {
+		t.Helper()
+		var idx struct {
+			LatestFingerprint string `json:"latest_fingerprint"`
+		}
+		data, err := os.ReadFile(svc.Config().IndexPath)
+		if err != nil {
+			t.Fatalf("read index: %v", err)
+		}
+		if err := json.Unmarshal(data, &idx); err != nil {
+			t.Fatalf("unmarshal index: %v", err)
+		}
+		return idx.LatestFingerprint
+	}
+	expectUnchangedScan := func(step string) {
+		t.Helper()
+		res, err := svc.Scan(true, nil)
+		if err != nil || res.Created || res.Code != core.ReasonUnchanged {
+			t.Fatalf("%s: expected unchanged scan, got %+v (%v)", step, res, err)
+		}
+	}
+	items := make([]*core.BackupItem, 0, 3)
+	for i := 0; i < 3; i++ {
+		writeTarget(t, svc, fmt.Sprintf(`{"token":"%d"}`, i))
+		res, err := svc.Scan(true, nil)
+		if err != nil || !res.Created {
+			t.Fatalf("scan %d: %+v (%v)", i, res, err)
+		}
+		items = append(items, res.Item)
+	}
+	live := latestFingerprint()
+
+	// 删除中间的备份：latest_fingerprint 不变。
+	if err := svc.DeleteBackup(items[1].ID); err != nil {
+		t.Fatalf("delete middle: %v", err)
+	}
+	if got := latestFingerprint(); got != live {
+		t.Fatalf("delete middle changed latest fingerprint: %s -> %s", live, got)
+	}
+	expectUnchangedScan("after deleting middle")
+
+	// 删除最新的备份：目标未变，重新计算的指纹与之前相同，不会再次备份。
+	if err := svc.DeleteBackup(items[2].ID); err != nil {
+		t.Fatalf("delete latest: %v", err)
+	}
+	if got := latestFingerprint(); got != live {
+		t.Fatalf("delete latest should keep live target fingerprint: %s -> %s", live, got)
+	}
+	expectUnchangedScan("after deleting latest")
+
+	// 目标文件不存在时退回剩余备份中最新一项的指纹。
+	writeTarget(t, svc, `{"token":"3"}`)
+	res, err := svc.Scan(true, nil)
+	if err != nil || !res.Created {
+		t.Fatalf("scan: %+v (%v)", res, err)
+	}
+	if err := os.Remove(svc.Config().TargetPath); err != nil {
+		t.Fatalf("remove target: %v", err)
+	}
+	if err := svc.DeleteBackup(res.Item.ID); err != nil {
+		t.Fatalf("delete latest without target: %v", err)
+	}
+	if got := latestFingerprint(); got != items[0].FileFingerprint {
+		t.Fatalf("expected fallback to remaining item fingerprint %s, got %s", items[0].FileFingerprint, got)
+	}
+
+	// 删除唯一的备份：目标不存在时清空。
+	if err := svc.DeleteBackup(items[0].ID); err != nil {
+		t.Fatalf("delete only: %v", err)
+	}
+	if got := latestFingerprint(); got != "" {
+		t.Fatalf("expected empty latest fingerprint after deleting only item, got %s", got)
+	}
+}
+
+func TestNormalizeRemark(t *testing.T) {
+	cases := []struct {
+		name, in, want string
+	}{
+		{"ascii", " prod-2024 ", "prod-2024"},
+		{"combining acute", "Cafe\u0301", "Caf\u00E9"},
+		{"reordered marks", "a\u0302\u0323", "\u1EAD"},
+		{"singleton", "\u212B", "\u00C5"},
+		{"hangul jamo", "\u1112\u1161\u11AB", "\uD55C"},
+		{"whitespace", "备注\u3000\t生产", "备注 生产"},
+	}
+	for _, tc := range cases {
+		if got := core.NormalizeRemark(tc.in); got != tc.want {
+			t.Errorf("%s: NormalizeRemark(%+q) = %+q, want %+q", tc.name, tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestRemarkNormalizationAndUniqueness(t *testing.T) {
+	svc, cleanup := newTestService(t, func(cfg *core.Config) { cfg.RemarkMaxLength = 10 })
+	defer cleanup()
+	writeTarget(t, svc, `{"token":"a"}`)
+	remark := "  Cafe\u0301 \t prod "
+	res, err := svc.CreateBackup(&remark)
+	if err != nil || !res.Created {
+		t.Fatalf("create: %+v (%v)", res, err)
+	}
+	if res.Item.Remark != "Caf\u00e9 prod" {
+		t.Fatalf("remark not normalized: %+q", res.Item.Remark)
+	}
+	item, err := svc.UpdateRemark(res.Item.ID, "x\u3000 y ")
+	if err != nil || item.Remark != "x y" {
+		t.Fatalf("update remark: %+v (%v)", item, err)
+	}
+	if _, err := svc.UpdateRemark(res.Item.ID, "12345678901"); !errors.Is(err, core.ErrInvalidRemark) {
+		t.Fatalf("expected ErrInvalidRemark for long remark, got %v", err)
+	}
+	// 长度按字符计算，而不是字节。
+	if _, err := svc.UpdateRemark(res.Item.ID, "备注备注备注备注备注"); err != nil {
+		t.Fatalf("10 characters should be allowed: %v", err)
+	}
+	if _, err := svc.UpdateRemark(res.Item.ID, "Prod"); err != nil {
+		t.Fatalf("update remark: %v", err)
+	}
+
+	writeTarget(t, svc, `{"token":"b"}`)
+	dup := " Prod  "
+	if _, err := svc.CreateBackup(&dup); !errors.Is(err, core.ErrRemarkExists) {
+		t.Fatalf("expected normalized duplicate to conflict, got %v", err)
+	}
+	lower := "prod"
+	res, err = svc.CreateBackup(&lower)
+	if err != nil || !res.Created {
+		t.Fatalf("case-sensitive mode should allow %q: %+v (%v)", lower, res, err)
+	}
+}
+
+func TestRemarkCaseInsensitiveComparesStoredRemarksNormalized(t *testing.T) {
+	svc, cleanup := newTestService(t, func(cfg *core.Config) { cfg.RemarkCaseInsensitive = true })
+	defer cleanup()
+	writeTarget(t, svc, `{"token":"a"}`)
+	res, err := svc.CreateBackup(nil)
+	if err != nil || !res.Created {
+		t.Fatalf("create: %+v (%v)", res, err)
+	}
+	// 模拟旧版本写入的未规范化备注：原样保留，但比较时按规范化形式。
+	cfg := svc.Config()
+	data, err := os.ReadFile(cfg.IndexPath)
+	if err != nil {
+		t.Fatalf("read index: %v", err)
+	}
+	var idx core.IndexData
+	if err := json.Unmarshal(data, &idx); err != nil {
+		t.Fatalf("unmarshal index: %v", err)
+	}
+	legacy := "Prod  Cafe\u0301 "
+	delete(idx.Remarks, idx.Items[0].Remark)
+	idx.Items[0].Remark = legacy
+	idx.Remarks[legacy] = idx.Items[0].ID
+	if err := util.AtomicWriteJSONDefault(cfg.IndexPath, &idx); err != nil {
+		t.Fatalf("write index: %v", err)
+	}
+
+	writeTarget(t, svc, `{"token":"b"}`)
+	dup := "prod caf\u00e9"
+	if _, err := svc.CreateBackup(&dup); !errors.Is(err, core.ErrRemarkExists) {
+		t.Fatalf("expected case-insensitive normalized conflict, got %v", err)
+	}
+	items, err := svc.ListBackups()
+	if err != nil || len(items) != 1 || items[0].Remark != legacy {
+		t.Fatalf("stored remark must be left as-is: %+v (%v)", items, err)
+	}
+}
+
+func TestClearRemarkRegeneratesDefault(t *testing.T) {
+	svc, cleanup := newTestService(t)
+	defer cleanup()
+	remark := "custom"
+	writeTarget(t, svc, `{"token":"a"}`)
+	first, err := svc.CreateBackup(&remark)
+	if err != nil || !first.Created {
+		t.Fatalf("create: %+v (%v)", first, err)
+	}
+	writeTarget(t, svc, `{"token":"b"}`)
+	second, err := svc.Scan(true, nil)
+	if err != nil || !second.Created {
+		t.Fatalf("scan: %+v (%v)", second, err)
+	}
+	if _, err := svc.UpdateRemark(first.Item.ID, " "); !errors.Is(err, core.ErrInvalidRemark) {
+		t.Fatalf("expected empty remark to be rejected, got %v", err)
+	}
+
+	// 另一个备份已占用重新生成的默认备注时追加 -1。
+	want := "manual-" + core.FormatTimestamp(first.Item.CreatedAt, core.TimestampsLocal)
+	if _, err := svc.UpdateRemark(second.Item.ID, want); err != nil {
+		t.Fatalf("occupy default remark: %v", err)
+	}
+	item, err := svc.ClearRemark(first.Item.ID)
+	if err != nil {
+		t.Fatalf("clear remark: %v", err)
+	}
+	if item.Remark != want+"-1" {
+		t.Fatalf("expected %q, got %q", want+"-1", item.Remark)
+	}
+	// 原备注已释放，可被其他备份使用。
+	if _, err := svc.UpdateRemark(second.Item.ID, "custom"); err != nil {
+		t.Fatalf("reuse cleared remark: %v", err)
+	}
+	item, err = svc.ClearRemark(second.Item.ID)
+	if err != nil || item.Remark != "auto-"+core.FormatTimestamp(second.Item.CreatedAt, core.TimestampsLocal) {
+		t.Fatalf("unexpected cleared auto remark: %+v (%v)", item, err)
+	}
+	if _, err := svc.ClearRemark("missing"); !errors.Is(err, core.ErrBackupNotFound) {
+		t.Fatalf("expected ErrBackupNotFound, got %v", err)
+	}
+}
+
+func TestDirectoryTargetIsReported(t *testing.T) {
+	svc, cleanup := newTestService(t)
+	defer cleanup()
+	target := svc.Config().TargetPath
+	if err := os.MkdirAll(target, 0o755); err != nil {
+		t.Fatalf("mkdir target: %v", err)
+	}
+
+	status, err := svc.Status()
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	if !status.Exists || !status.IsDirectory || status.Readable || status.Problem == "" {
+		t.Fatalf("expected directory problem, got %+v", status)
+	}
+	res, err := svc.ScanContext(context.Background(), false, nil)
+	if err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	if res.Created || res.Code != core.ReasonTargetIsDirectory {
+		t.Fatalf("expected TARGET_IS_DIRECTORY, got %+v", res)
+	}
+}
+
+func TestUnreadableTargetIsReported(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows 不支持 Unix 权限位")
+	}
+	if os.Geteuid() == 0 {
+		t.Skip("root 不受文件权限限制")
+	}
+	svc, cleanup := newTestService(t)
+	defer cleanup()
+	writeTarget(t, svc, `{"token":"alpha"}`)
+	target := svc.Config().TargetPath
+	if err := os.Chmod(target, 0); err != nil {
+		t.Fatalf("chmod: %v", err)
+	}
+	defer os.Chmod(target, 0o600)
+
+	status, err := svc.Status()
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	if !status.Exists || status.IsDirectory || status.Readable || !strings.Contains(status.Problem, "permission denied") {
+		t.Fatalf("expected unreadable problem, got %+v", status)
+	}
+	res, err := svc.ScanContext(context.Background(), false, nil)
+	if err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	if res.Created || res.Code != core.ReasonTargetUnreadable {
+		t.Fatalf("expected TARGET_UNREADABLE, got %+v", res)
+	}
+}
+
+func TestCompressedBackupRestoresIdenticalBytes(t *testing.T) {
+	svc, cleanup := newTestService(t, func(cfg *core.Config) { cfg.Compression = core.CompressionGzip })
+	defer cleanup()
+
+	content := `{"access_token":"sk-abcdefghijklmnop","padding":"` + strings.Repeat("x", 4096) + `"}` + "\n"
+	writeTarget(t, svc, content)
+	first, err := svc.CreateBackup(nil)
+	if err != nil || !first.Created {
+		t.Fatalf("backup: %+v (%v)", first, err)
+	}
+	if !strings.HasSuffix(first.Item.Filename, ".json.gz") {
+		t.Fatalf("expected .json.gz filename, got %s", first.Item.Filename)
+	}
+	stored, err := os.ReadFile(filepath.Join(svc.Config().BackupsDir, first.Item.Filename))
+	if err != nil {
+		t.Fatalf("read backup file: %v", err)
+	}
+	if len(stored) >= len(content) || !bytes.HasPrefix(stored, []byte{0x1f, 0x8b}) {
+		t.Fatalf("expected gzip data smaller than the original, got %d bytes", len(stored))
+	}
+	if hash, _, err := core.ComputeContentHash(svc.Config().TargetPath, svc.Config().HashAlgo); err != nil || hash != first.Item.ContentHash {
+		t.Fatalf("expected content hash of uncompressed bytes, got %s want %s (%v)", first.Item.ContentHash, hash, err)
+	}
+	// 内容未变时按未压缩内容去重，不再生成新备份。
+	if again, err := svc.CreateBackup(nil); err != nil || again.Created {
+		t.Fatalf("expected duplicate content to be skipped, got %+v (%v)", again, err)
+	}
+
+	writeTarget(t, svc, `{"access_token":"other"}`)
+	if _, err := svc.RestoreBackup(first.Item.ID); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	restored, err := os.ReadFile(svc.Config().TargetPath)
+	if err != nil || string(restored) != content {
+		t.Fatalf("expected byte-identical restore, got %d bytes (%v)", len(restored), err)
+	}
+}
+
+func TestEncryptionKeyFromKeyring(t *testing.T) {
+	keyring.MockInit()
+	base := t.TempDir()
+	key := core.KeyringKey{Account: filepath.Join(base, "data")}
+	withKeyring := func(cfg *core.Config) {
+		cfg.TargetPath = filepath.Join(base, "codex", "auth.json")
+		cfg.DataDir = key.Account
+		cfg.BackupsDir = filepath.Join(key.Account, "backups")
+		cfg.IndexPath = filepath.Join(key.Account, "index.json")
+		cfg.EncryptionKey = key
+	}
+	cfg := core.Config{}
+	withKeyring(&cfg)
+	if _, err := core.NewService(cfg, core.NewJSONLogger(io.Discard, slog.LevelInfo)); !errors.Is(err, core.ErrKeyringPassphraseNotFound) {
+		t.Fatalf("expected ErrKeyringPassphraseNotFound without a stored passphrase, got %v", err)
+	}
+	if err := key.SetPassphrase("from the keychain"); err != nil {
+		t.Fatalf("set passphrase: %v", err)
+	}
+	svc, cleanup := newTestService(t, withKeyring)
+	writeTarget(t, svc, `{"access_token":"sk-keyring-token"}`)
+	res, err := svc.CreateBackup(nil)
+	if err != nil || !res.Item.Encrypted {
+		t.Fatalf("expected encrypted backup, got %+v (%v)", res, err)
+	}
+	if src := svc.PublicConfig().EncryptionKeySource; src != "keyring" {
+		t.Fatalf("expected keyring key source, got %q", src)
+	}
+	cleanup()
+
+	// 同一口令从其他来源读取时可以解密。
+	svc, cleanup = newTestService(t, withKeyring, func(cfg *core.Config) {
+		cfg.EncryptionKey = core.StaticKey{Secret: "from the keychain"}
+	})
+	defer cleanup()
+	if _, err := svc.RestoreBackup(res.Item.ID); err != nil {
+		t.Fatalf("restore with the same passphrase from another source: %v", err)
+	}
+}
+
+func TestEncryptedBackups(t *testing.T) {
+	base := t.TempDir()
+	open := func(passphrase string) (*core.Service, func()) {
+		return newTestService(t, func(cfg *core.Config) {
+			cfg.TargetPath = filepath.Join(base, "codex", "auth.json")
+			cfg.DataDir = filepath.Join(base, "data")
+			cfg.BackupsDir = filepath.Join(base, "data", "backups")
+			cfg.IndexPath = filepath.Join(base, "data", "index.json")
+			cfg.Compression = core.CompressionGzip
+			cfg.EncryptionPassphrase = util.SecretString(passphrase)
+		})
+	}
+	const plainContent = `{"access_token":"sk-plain-token"}`
+	const secretContent = `{"access_token":"sk-secret-token"}`
+
+	svc, cleanup := open("")
+	writeTarget(t, svc, plainContent)
+	plain, err := svc.CreateBackup(nil)
+	if err != nil || !plain.Created || plain.Item.Encrypted {
+		t.Fatalf("plain backup: %+v (%v)", plain, err)
+	}
+	cleanup()
+
+	svc, cleanup = open("correct horse battery staple")
+	writeTarget(t, svc, secretContent)
+	secret, err := svc.CreateBackup(nil)
+	if err != nil || !secret.Created || !secret.Item.Encrypted || secret.Item.KDFSalt == "" {
+		t.Fatalf("encrypted backup: %+v (%v)", secret, err)
+	}
+	stored, err := os.ReadFile(filepath.Join(svc.Config().BackupsDir, secret.Item.Filename))
+	if err != nil || bytes.Contains(stored, []byte("sk-secret-token")) {
+		t.Fatalf("expected ciphertext on disk, got %q (%v)", stored, err)
+	}
+	// 内容哈希基于明文，与未加密的旧备份之间照常去重。
+	writeTarget(t, svc, plainContent)
+	if again, err := svc.CreateBackup(nil); err != nil || again.Created {
+		t.Fatalf("expected duplicate content to be skipped, got %+v (%v)", again, err)
+	}
+	if _, err := svc.RestoreBackup(secret.Item.ID); err != nil {
+		t.Fatalf("restore encrypted: %v", err)
+	}
+	if data, err := os.ReadFile(svc.Config().TargetPath); err != nil || string(data) != secretContent {
+		t.Fatalf("expected decrypted restore, got %q (%v)", data, err)
+	}
+	if preview, err := svc.PreviewBackup(secret.Item.ID); err != nil || preview.Size != int64(len(secretContent)) {
+		t.Fatalf("preview encrypted: %+v (%v)", preview, err)
+	}
+	f, item, err := svc.OpenBackup(secret.Item.ID)
+	if err != nil {
+		t.Fatalf("open encrypted: %v", err)
+	}
+	downloaded, err := io.ReadAll(f)
+	f.Close()
+	if err != nil || string(downloaded) != secretContent || item.Size != -1 {
+		t.Fatalf("expected decrypted download, got %q size=%d (%v)", downloaded, item.Size, err)
+	}
+	if _, err := svc.RestoreBackup(plain.Item.ID); err != nil {
+		t.Fatalf("restore plain backup with encryption enabled: %v", err)
+	}
+	cleanup()
+
+	svc, cleanup = open("wrong passphrase")
+	if _, err := svc.RestoreBackup(secret.Item.ID); !errors.Is(err, core.ErrDecryptFailed) {
+		t.Fatalf("expected ErrDecryptFailed, got %v", err)
+	}
+	if data, err := os.ReadFile(svc.Config().TargetPath); err != nil || string(data) != plainContent {
+		t.Fatalf("failed restore must leave the target untouched, got %q (%v)", data, err)
+	}
+	cleanup()
+
+	svc, cleanup = open("")
+	defer cleanup()
+	if _, err := svc.RestoreBackup(secret.Item.ID); !errors.Is(err, core.ErrEncryptionKeyMissing) {
+		t.Fatalf("expected ErrEncryptionKeyMissing, got %v", err)
+	}
+}
+
+func TestServiceBackupsWithHashAlgo(t *testing.T) {
+	for _, algo := range []string{core.HashSHA256, core.HashSHA512, core.HashBLAKE2b} {
+		svc, cleanup := newTestService(t, func(cfg *core.Config) { cfg.HashAlgo = algo })
+		writeTarget(t, svc, `{"token":"`+algo+`"}`)
+		res, err := svc.CreateBackup(nil)
+		if err != nil || !res.Created {
+			t.Fatalf("%s: backup: %+v (%v)", algo, res, err)
+		}
+		want, _, err := core.ComputeContentHash(svc.Config().TargetPath, algo)
+		if err != nil || res.Item.ContentHash != want {
+			t.Fatalf("%s: expected content hash %s, got %s (%v)", algo, want, res.Item.ContentHash, err)
+		}
+		if again, err := svc.CreateBackup(nil); err != nil || again.Created {
+			t.Fatalf("%s: expected duplicate content to be skipped, got %+v (%v)", algo, again, err)
+		}
+		cleanup()
+	}
+}
+
+func TestMaxAutoBackupsKeepsNewest(t *testing.T) {
+	const keep = 3
+	svc, cleanup := newTestService(t, func(cfg *core.Config) { cfg.MaxAutoBackups = keep })
+	defer cleanup()
+
+	writeTarget(t, svc, `{"token":"manual"}`)
+	manual, err := svc.CreateBackup(nil)
+	if err != nil || !manual.Created {
+		t.Fatalf("manual backup: %+v (%v)", manual, err)
+	}
+	var created []*core.BackupItem
+	for i := 0; i < keep+3; i++ {
+		writeTarget(t, svc, `{"token":"`+strings.Repeat("a", i+1)+`"}`)
+		res, err := svc.Scan(true, nil)
+		if err != nil || !res.Created {
+			t.Fatalf("auto backup %d: %+v (%v)", i, res, err)
+		}
+		created = append(created, res.Item)
+	}
+
+	items, err := svc.ListBackups()
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	var autoIDs []string
+	for _, item := range items {
+		if item.IsAuto {
+			autoIDs = append(autoIDs, item.ID)
+		}
+	}
+	want := []string{created[5].ID, created[4].ID, created[3].ID}
+	if strings.Join(autoIDs, ",") != strings.Join(want, ",") {
+		t.Fatalf("expected newest auto backups %v, got %v", want, autoIDs)
+	}
+	if _, err := svc.GetBackup(manual.Item.ID); err != nil {
+		t.Fatalf("manual backup must be kept: %v", err)
+	}
+	for _, item := range created[:3] {
+		if _, err := os.Stat(filepath.Join(svc.Config().BackupsDir, item.Filename)); !os.IsNotExist(err) {
+			t.Fatalf("expected file of pruned backup %s to be removed, got %v", item.ID, err)
+		}
+	}
+}
+
+func TestMaxAutoBackupsRespectsPruneCapability(t *testing.T) {
+	svc, cleanup := newTestService(t, func(cfg *core.Config) {
+		cfg.MaxAutoBackups = 1
+		cfg.Capabilities = map[string]bool{core.CapPrune: false}
+	})
+	defer cleanup()
+	for i := 0; i < 3; i++ {
+		writeTarget(t, svc, `{"token":"`+strings.Repeat("b", i+1)+`"}`)
+		if res, err := svc.Scan(true, nil); err != nil || !res.Created {
+			t.Fatalf("auto backup %d: %+v (%v)", i, res, err)
+		}
+	}
+	if items, err := svc.ListBackups(); err != nil || len(items) != 3 {
+		t.Fatalf("expected no pruning with the prune capability disabled, got %d (%v)", len(items), err)
+	}
+}
+
+// backdateBackups 直接改写索引，将指定备份的创建时间提前 age。
+func backdateBackups(t *testing.T, svc *core.Service, age time.Duration, ids ...string) {
+	t.Helper()
+	path := svc.Config().IndexPath
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read index: %v", err)
+	}
+	var idx core.IndexData
+	if err := json.Unmarshal(data, &idx); err != nil {
+		t.Fatalf("decode index: %v", err)
+	}
+	for i := range idx.Items {
+		for _, id := range ids {
+			if idx.Items[i].ID == id {
+				idx.Items[i].CreatedAt = idx.Items[i].CreatedAt.Add(-age)
+			}
+		}
+	}
+	if err := util.AtomicWriteJSONDefault(path, &idx); err != nil {
+		t.Fatalf("write index: %v", err)
+	}
+}
+
+func TestPruneExpiresOldAutoBackups(t *testing.T) {
+	svc, cleanup := newTestService(t, func(cfg *core.Config) { cfg.AutoBackupMaxAge = 30 * 24 * time.Hour })
+	defer cleanup()
+
+	writeTarget(t, svc, `{"token":"manual"}`)
+	manual, err := svc.CreateBackup(nil)
+	if err != nil || !manual.Created {
+		t.Fatalf("manual backup: %+v (%v)", manual, err)
+	}
+	var auto []*core.BackupItem
+	for i := 0; i < 3; i++ {
+		writeTarget(t, svc, `{"token":"`+strings.Repeat("c", i+1)+`"}`)
+		res, err := svc.Scan(true, nil)
+		if err != nil || !res.Created {
+			t.Fatalf("auto backup %d: %+v (%v)", i, res, err)
+		}
+		auto = append(auto, res.Item)
+	}
+	backdateBackups(t, svc, 40*24*time.Hour, manual.Item.ID, auto[0].ID, auto[1].ID)
+	// 备份文件缺失的到期备份被跳过，不影响其他备份的清理。
+	if err := os.Remove(filepath.Join(svc.Config().BackupsDir, auto[1].Filename)); err != nil {
+		t.Fatalf("remove backup file: %v", err)
+	}
+
+	res, err := svc.Prune()
+	if err != nil {
+		t.Fatalf("prune: %v", err)
+	}
+	if len(res.Deleted) != 1 || res.Deleted[0] != auto[0].ID || len(res.Skipped) != 1 || res.Skipped[0] != auto[1].ID {
+		t.Fatalf("unexpected prune result: %+v", res)
+	}
+	for _, id := range []string{manual.Item.ID, auto[1].ID, auto[2].ID} {
+		if _, err := svc.GetBackup(id); err != nil {
+			t.Fatalf("expected %s to be kept: %v", id, err)
+		}
+	}
+	if again, err := svc.Scan(true, nil); err != nil || again.Created {
+		t.Fatalf("expected the unchanged target to be skipped after pruning, got %+v (%v)", again, err)
+	}
+}
+
+func TestPruneKeepsNewestBackup(t *testing.T) {
+	svc, cleanup := newTestService(t, func(cfg *core.Config) { cfg.AutoBackupMaxAge = 24 * time.Hour })
+	defer cleanup()
+	var ids []string
+	for i := 0; i < 2; i++ {
+		writeTarget(t, svc, `{"token":"`+strings.Repeat("d", i+1)+`"}`)
+		res, err := svc.Scan(true, nil)
+		if err != nil || !res.Created {
+			t.Fatalf("auto backup %d: %+v (%v)", i, res, err)
+		}
+		ids = append(ids, res.Item.ID)
+	}
+	backdateBackups(t, svc, 48*time.Hour, ids...)
+	res, err := svc.Prune()
+	if err != nil || len(res.Deleted) != 1 || res.Deleted[0] != ids[0] {
+		t.Fatalf("expected only the older backup to be pruned, got %+v (%v)", res, err)
+	}
+	if _, err := svc.GetBackup(ids[1]); err != nil {
+		t.Fatalf("expected the newest backup to be kept: %v", err)
+	}
+}
+
+func TestMaxBackupsTotalBytes(t *testing.T) {
+	const autoContent = `{"token":"%c-0123456789"}`
+	autoSize := int64(len(fmt.Sprintf(autoContent, 'a')))
+	manualContent := `{"token":"manual-backup-content"}`
+	limit := int64(len(manualContent)) + 2*autoSize
+	svc, cleanup := newTestService(t, func(cfg *core.Config) { cfg.MaxBackupsTotalBytes = limit })
+	defer cleanup()
+
+	writeTarget(t, svc, manualContent)
+	manual, err := svc.CreateBackup(nil)
+	if err != nil || !manual.Created {
+		t.Fatalf("manual backup: %+v (%v)", manual, err)
+	}
+	var auto []string
+	for _, c := range "abcd" {
+		writeTarget(t, svc, fmt.Sprintf(autoContent, c))
+		res, err := svc.Scan(true, nil)
+		if err != nil || !res.Created {
+			t.Fatalf("auto backup %c: %+v (%v)", c, res, err)
+		}
+		auto = append(auto, res.Item.ID)
+	}
+
+	items, err := svc.ListBackups()
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	var ids []string
+	for _, item := range items {
+		ids = append(ids, item.ID)
+	}
+	want := []string{auto[3], auto[2], manual.Item.ID}
+	if strings.Join(ids, ",") != strings.Join(want, ",") {
+		t.Fatalf("expected %v to remain, got %v", want, ids)
+	}
+	status, err := svc.Status()
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	if status.MaxBackupsTotalBytes != limit || status.BackupsTotalBytes != limit {
+		t.Fatalf("expected usage %d of %d, got %d of %d", limit, limit, status.BackupsTotalBytes, status.MaxBackupsTotalBytes)
+	}
+}
+
+func TestPruneRequiresCapability(t *testing.T) {
+	svc, cleanup := newTestService(t, func(cfg *core.Config) {
+		cfg.AutoBackupMaxAge = time.Hour
+		cfg.Capabilities = map[string]bool{core.CapPrune: false}
+	})
+	defer cleanup()
+	if _, err := svc.Prune(); !errors.Is(err, core.ErrCapabilityDisabled) {
+		t.Fatalf("expected ErrCapabilityDisabled, got %v", err)
+	}
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	disabled, cleanup := newTestService(t)
+	if disabled.Metrics() != nil {
+		t.Fatalf("expected no metrics registry unless enabled")
+	}
+	cleanup()
+
+	svc, cleanup := newTestService(t, func(cfg *core.Config) { cfg.EnableMetrics = true })
+	defer cleanup()
+	writeTarget(t, svc, `{"token":"a"}`)
+	first, err := svc.CreateBackup(nil)
+	if err != nil || !first.Created {
+		t.Fatalf("backup: %+v (%v)", first, err)
+	}
+	writeTarget(t, svc, `{"token":"bb"}`)
+	second, err := svc.CreateBackup(nil)
+	if err != nil || !second.Created {
+		t.Fatalf("backup: %+v (%v)", second, err)
+	}
+	if _, err := svc.RestoreBackup(first.Item.ID); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if err := svc.DeleteBackup(second.Item.ID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+
+	srv := httptest.NewServer(svc.Metrics().Handler())
+	defer srv.Close()
+	resp, err := http.Get(srv.URL + "/metrics")
+	if err != nil {
+		t.Fatalf("scrape: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	labels := fmt.Sprintf("{target=%q}", svc.Config().TargetPath)
+	for _, line := range []string{
+		"backup_created_total" + labels + " 2",
+		"backup_deleted_total" + labels + " 1",
+		"restore_total" + labels + " 1",
+		"backup_files_total" + labels + " 1",
+		fmt.Sprintf("backup_bytes_total%s %d", labels, first.Item.Size),
+		"scan_duration_seconds_count" + labels + " 2",
+	} {
+		if !strings.Contains(string(body), line+"\n") {
+			t.Fatalf("expected %q in metrics output:\n%s", line, body)
+		}
+	}
+}
+
+func TestMetricsTargetLabel(t *testing.T) {
+	a, cleanupA := newTestService(t, func(cfg *core.Config) { cfg.EnableMetrics = true })
+	defer cleanupA()
+	b, cleanupB := newTestService(t, func(cfg *core.Config) {
+		cfg.EnableMetrics = true
+		cfg.TargetPath = filepath.Join(filepath.Dir(cfg.TargetPath), strings.Repeat("d", 130), "auth.json")
+	})
+	defer cleanupB()
+
+	targetLabels := func(svc *core.Service) map[string]bool {
+		var buf strings.Builder
+		if _, err := svc.Metrics().WriteTo(&buf); err != nil {
+			t.Fatalf("write metrics: %v", err)
+		}
+		values := map[string]bool{}
+		for _, line := range strings.Split(buf.String(), "\n") {
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			m := regexp.MustCompile(`target="((?:[^"\\]|\\.)*)"`).FindStringSubmatch(line)
+			if m == nil {
+				t.Fatalf("series without target label: %q", line)
+			}
+			values[m[1]] = true
+		}
+		return values
+	}
+	labelsA, labelsB := targetLabels(a), targetLabels(b)
+	if len(labelsA) != 1 || len(labelsB) != 1 {
+		t.Fatalf("expected one target label value per service, got %v and %v", labelsA, labelsB)
+	}
+	if !labelsA[a.Config().TargetPath] {
+		t.Fatalf("expected target label %q, got %v", a.Config().TargetPath, labelsA)
+	}
+	for v := range labelsB {
+		if labelsA[v] {
+			t.Fatalf("expected distinct target labels, both are %q", v)
+		}
+		if !strings.HasPrefix(v, "sha256:") || len(v) != len("sha256:")+12 {
+			t.Fatalf("expected hashed target label for long path, got %q", v)
+		}
+	}
+}
+
+func TestHealthCheckCachesWriteProbe(t *testing.T) {
+	svc, cleanup := newTestService(t)
+	defer cleanup()
+	if got := svc.HealthCheck()[core.HealthStore]; got != core.HealthOK {
+		t.Fatalf("expected store ok, got %s", got)
+	}
+	// 在探针文件的位置放一个非空目录，之后的写入探测必然失败。
+	dir := filepath.Dir(svc.Config().IndexPath)
+	blocker := filepath.Join(dir, fmt.Sprintf(".selftest-%d.tmp", os.Getpid()))
+	if err := os.MkdirAll(filepath.Join(blocker, "x"), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	defer os.RemoveAll(blocker)
+	for i := 0; i < 3; i++ {
+		if got := svc.HealthCheck()[core.HealthStore]; got != core.HealthOK {
+			t.Fatalf("expected cached probe result, got %s", got)
+		}
+	}
+}
+
+func TestRestoreReadOnlyTarget(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows 不支持 Unix 权限位")
+	}
+	svc, cleanup := newTestService(t)
+	defer cleanup()
+	writeTarget(t, svc, `{"token":"alpha"}`)
+	res, err := svc.CreateBackup(nil)
+	if err != nil || !res.Created {
+		t.Fatalf("backup: %+v %v", res, err)
+	}
+	target := svc.Config().TargetPath
+	writeTarget(t, svc, `{"token":"beta"}`)
+	if err := os.Chmod(target, 0o440); err != nil {
+		t.Fatalf("chmod: %v", err)
+	}
+
+	if _, err := svc.RestoreBackup(res.Item.ID); !errors.Is(err, core.ErrTargetReadOnly) {
+		t.Fatalf("expected ErrTargetReadOnly, got %v", err)
+	}
+	if data, _ := os.ReadFile(target); string(data) != `{"token":"beta"}` {
+		t.Fatalf("read-only target was modified: %s", data)
+	}
+}
+
+func TestRestoreOverrideReadOnlyKeepsMode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows 不支持 Unix 权限位")
+	}
+	svc, cleanup := newTestService(t, func(cfg *core.Config) {
+		cfg.RestoreOverrideReadOnly = true
+	})
+	defer cleanup()
+	writeTarget(t, svc, `{"token":"alpha"}`)
+	res, err := svc.CreateBackup(nil)
+	if err != nil || !res.Created {
+		t.Fatalf("backup: %+v %v", res, err)
+	}
+	target := svc.Config().TargetPath
+	writeTarget(t, svc, `{"token":"beta"}`)
+	if err := os.Chmod(target, 0o440); err != nil {
+		t.Fatalf("chmod: %v", err)
+	}
+
+	if _, err := svc.RestoreBackup(res.Item.ID); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if data, _ := os.ReadFile(target); string(data) != `{"token":"alpha"}` {
+		t.Fatalf("unexpected restored content: %s", data)
+	}
+	info, err := os.Stat(target)
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	if got := info.Mode().Perm(); got != 0o440 {
+		t.Fatalf("expected mode 0440 after restore, got %o", got)
+	}
+}
+
+func TestMetadataOnlyChangeIsUnchanged(t *testing.T) {
+	svc, cleanup := newTestService(t, func(cfg *core.Config) {
+		cfg.MtimeGranularity = -1
+	})
+	defer cleanup()
+	writeTarget(t, svc, `{"token":"alpha"}`)
+	if res, err := svc.Scan(true, nil); err != nil || !res.Created {
+		t.Fatalf("scan: %+v %v", res, err)
+	}
+	// 内容不变、只有修改时间变化，例如网络文件系统重新报告了不同的时间戳。
+	mtime := time.Now().Add(-time.Minute)
+	if err := os.Chtimes(svc.Config().TargetPath, mtime, mtime); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+	res, err := svc.Scan(true, nil)
+	if err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	if res.Created || res.Code != core.ReasonUnchanged {
+		t.Fatalf("expected UNCHANGED for metadata-only change, got %+v", res)
+	}
+	status, err := svc.Status()
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	if status.LatestFingerprint != status.Fingerprint {
+		t.Fatalf("expected latest fingerprint to follow the new metadata, got %s want %s", status.LatestFingerprint, status.Fingerprint)
+	}
+}
+
+func TestMtimeGranularitySuppressesFlapping(t *testing.T) {
+	svc, cleanup := newTestService(t, func(cfg *core.Config) {
+		cfg.MtimeGranularity = 2 * time.Second
+	})
+	defer cleanup()
+	writeTarget(t, svc, `{"token":"alpha"}`)
+	base := time.Now().Add(-time.Hour).Truncate(2 * time.Second)
+	target := svc.Config().TargetPath
+	if err := os.Chtimes(target, base, base); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+	if res, err := svc.Scan(true, nil); err != nil || !res.Created {
+		t.Fatalf("scan: %+v %v", res, err)
+	}
+	before, err := svc.Status()
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	flapped := base.Add(1500 * time.Millisecond)
+	if err := os.Chtimes(target, flapped, flapped); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+	after, err := svc.Status()
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	if after.Fingerprint != before.Fingerprint {
+		t.Fatalf("fingerprint flapped within the 2s granularity")
+	}
+	if cfg := svc.PublicConfig(); cfg.MtimeGranularityMs != 2000 {
+		t.Fatalf("expected effective granularity 2000ms, got %d", cfg.MtimeGranularityMs)
+	}
+}
+
+func writeTarget(t *testing.T, svc *core.Service, content string) {
+	t.Helper()
+	target := svc.Config().TargetPath
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(target, []byte(content), 0o600); err != nil {
+		t.Fatalf("write target: %v", err)
+	}
+}
+
+func newTestService(t *testing.T, opts ...func(*core.Config)) (*core.Service, func()) {
+	t.Helper()
+	base := t.TempDir()
+	targetDir := filepath.Join(base, "codex")
+	dataDir := filepath.Join(base, "data")
+	cfg := core.Config{
+		TargetPath:   filepath.Join(targetDir, "auth.json"),
+		DataDir:      dataDir,
+		BackupsDir:   filepath.Join(dataDir, "backups"),
+		IndexPath:    filepath.Join(dataDir, "index.json"),
+		ScanInterval: time.Second,
+		Port:         "0",
+	}
+	for _, opt := range opts {
+		opt(&cfg)
 	}
-	svc, err := core.NewService(cfg, log.New(io.Discard, "", 0))
+	svc, err := core.NewService(cfg, core.NewJSONLogger(io.Discard, slog.LevelInfo))
 	if err != nil {
 		t.Fatalf("new service: %v", err)
 	}
@@ -0,0 +1,105 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// staleCheckInterval 为后台检查备份是否过期的间隔。
+const staleCheckInterval = time.Minute
+
+// StalenessInfo 描述距最近一次成功备份的时间；只在配置了 alerts.max_hours_without_backup 时出现在状态中。
+// 成功是指创建了备份，或扫描确认目标内容已有备份（UNCHANGED/DUPLICATE_CONTENT）。
+type StalenessInfo struct {
+	Stale                bool    `json:"stale"`
+	ThresholdHours       float64 `json:"threshold_hours"`
+	LastBackupAt         string  `json:"last_backup_at,omitempty"`
+	LastSuccessfulScanAt string  `json:"last_successful_scan_at,omitempty"`
+	// HoursSinceSuccess 从最近一次成功备份或扫描起算，两者都没有时从服务启动起算。
+	HoursSinceSuccess float64 `json:"hours_since_success"`
+}
+
+// Staleness 计算当前是否过期，未配置阈值时返回 nil。
+func (s *Service) Staleness() (*StalenessInfo, error) {
+	threshold := s.cfg.MaxTimeWithoutBackup
+	if threshold <= 0 {
+		return nil, nil
+	}
+	idx, err := s.store.Snapshot()
+	if err != nil {
+		return nil, err
+	}
+	info := &StalenessInfo{ThresholdHours: threshold.Hours()}
+	since := s.startedAt
+	var lastBackup time.Time
+	for _, item := range idx.Items {
+		if item.CreatedAt.After(lastBackup) {
+			lastBackup = item.CreatedAt
+		}
+	}
+	if !lastBackup.IsZero() {
+		info.LastBackupAt = lastBackup.UTC().Format(time.RFC3339)
+		if lastBackup.After(since) {
+			since = lastBackup
+		}
+	}
+	if lastScan := s.scanState.lastSuccess(); !lastScan.IsZero() {
+		info.LastSuccessfulScanAt = lastScan.UTC().Format(time.RFC3339)
+		if lastScan.After(since) {
+			since = lastScan
+		}
+	}
+	elapsed := time.Since(since)
+	info.HoursSinceSuccess = elapsed.Hours()
+	info.Stale = elapsed > threshold
+	return info, nil
+}
+
+// CheckStaleness 检查备份是否过期：进入过期状态时记录一次警告并发出 backup.stale 事件，
+// 恢复后记录一次日志，同一次过期期间不会重复告警。
+func (s *Service) CheckStaleness() {
+	info, err := s.Staleness()
+	if err != nil {
+		s.logger.Error("检查备份时效失败", "error", err)
+		return
+	}
+	if info == nil {
+		return
+	}
+	s.staleMu.Lock()
+	defer s.staleMu.Unlock()
+	switch {
+	case info.Stale && !s.staleAlerted:
+		s.staleAlerted = true
+		reason := fmt.Sprintf("已 %.1f 小时没有成功的备份或扫描（阈值 %.1f 小时）", info.HoursSinceSuccess, info.ThresholdHours)
+		s.logger.Warn("备份已过期，请检查目标路径与扫描状态", "reason", reason, "hours_since_success", info.HoursSinceSuccess, "threshold_hours", info.ThresholdHours)
+		s.emit(Event{Type: EventBackupStale, Reason: reason})
+	case !info.Stale && s.staleAlerted:
+		s.staleAlerted = false
+		s.logger.Info("备份已恢复，解除过期告警")
+	}
+}
+
+func (s *Service) startStaleWatcher(ctx context.Context) {
+	if s.cfg.MaxTimeWithoutBackup <= 0 {
+		return
+	}
+	ticker := time.NewTicker(staleCheckInterval)
+	stopCh := s.stopCh
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-stopCh:
+				return
+			case <-ticker.C:
+				s.CheckStaleness()
+			}
+		}
+	}()
+}
@@ -0,0 +1,159 @@
+package core
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"codex-backup-tool/internal/tracing"
+)
+
+// 记录耗时的操作阶段。扫描按阶段分别统计，PhaseScan 为整次扫描的耗时。
+const (
+	PhaseScan    = "scan"
+	PhaseStat    = "stat"
+	PhaseHash    = "hash"
+	PhaseWrite   = "write"
+	PhaseIndex   = "index_update"
+	PhaseRestore = "restore"
+)
+
+// latencyPhases 为 Stats 中总会出现的阶段。
+var latencyPhases = []string{PhaseScan, PhaseStat, PhaseHash, PhaseWrite, PhaseIndex, PhaseRestore}
+
+// latencyWindowSize 为每个阶段保留的最近样本数，百分位按这些样本计算。
+const latencyWindowSize = 256
+
+// LatencyStats 为某个阶段最近若干次耗时的统计，单位毫秒。
+type LatencyStats struct {
+	Count int     `json:"count"`
+	P50Ms float64 `json:"p50_ms"`
+	P95Ms float64 `json:"p95_ms"`
+	MaxMs float64 `json:"max_ms"`
+}
+
+// Stats 为 /api/stats 返回的运行统计。
+type Stats struct {
+	// Latency 以阶段名为键，样本窗口见 WindowSize。
+	Latency    map[string]LatencyStats `json:"latency"`
+	WindowSize int                     `json:"window_size"`
+	// SlowRequests 为耗时超过 slow_request_ms 的 HTTP 请求数。
+	SlowRequests int64 `json:"slow_requests"`
+	// Panics 为 HTTP 处理器中被恢复的 panic 次数。
+	Panics int64 `json:"panics"`
+}
+
+// latencyRecorder 为每个阶段维护固定大小的环形样本窗口。
+type latencyRecorder struct {
+	mu      sync.Mutex
+	samples map[string][]time.Duration
+	next    map[string]int
+}
+
+func newLatencyRecorder() *latencyRecorder {
+	return &latencyRecorder{samples: make(map[string][]time.Duration), next: make(map[string]int)}
+}
+
+func (r *latencyRecorder) observe(phase string, d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	window := r.samples[phase]
+	if len(window) < latencyWindowSize {
+		r.samples[phase] = append(window, d)
+		return
+	}
+	i := r.next[phase]
+	window[i] = d
+	r.next[phase] = (i + 1) % latencyWindowSize
+}
+
+func (r *latencyRecorder) stats(phase string) LatencyStats {
+	r.mu.Lock()
+	sorted := append([]time.Duration(nil), r.samples[phase]...)
+	r.mu.Unlock()
+	if len(sorted) == 0 {
+		return LatencyStats{}
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return LatencyStats{
+		Count: len(sorted),
+		P50Ms: durationMs(percentile(sorted, 50)),
+		P95Ms: durationMs(percentile(sorted, 95)),
+		MaxMs: durationMs(sorted[len(sorted)-1]),
+	}
+}
+
+// percentile 按最近秩法取已排序样本的第 p 百分位。
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+func durationMs(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// Stats 返回各阶段最近的耗时统计，尚无样本的阶段各项为 0。
+func (s *Service) Stats() *Stats {
+	stats := &Stats{
+		Latency:      make(map[string]LatencyStats, len(latencyPhases)),
+		WindowSize:   latencyWindowSize,
+		SlowRequests: s.slowRequests.Load(),
+		Panics:       s.panics.Load(),
+	}
+	for _, phase := range latencyPhases {
+		stats.Latency[phase] = s.latency.stats(phase)
+	}
+	return stats
+}
+
+// phaseTimer 累计一次操作中各阶段的耗时。
+// span 非空时每个阶段另记为其子 span。
+type phaseTimer struct {
+	start     time.Time
+	last      time.Time
+	durations map[string]time.Duration
+	span      *tracing.Span
+}
+
+func newPhaseTimer(span *tracing.Span) *phaseTimer {
+	now := time.Now()
+	return &phaseTimer{start: now, last: now, durations: make(map[string]time.Duration), span: span}
+}
+
+// mark 将自上次 mark/skip 以来的耗时计入 phase。
+func (t *phaseTimer) mark(phase string) {
+	now := time.Now()
+	t.durations[phase] += now.Sub(t.last)
+	t.span.Child("scan."+phase, t.last, now)
+	t.last = now
+}
+
+// skip 丢弃自上次 mark/skip 以来的耗时，不计入任何阶段。
+func (t *phaseTimer) skip() {
+	t.last = time.Now()
+}
+
+// finish 记录整次操作耗时，将各阶段样本交给 recorder，并返回以毫秒为单位的各阶段耗时。
+func (t *phaseTimer) finish(total string, recorder *latencyRecorder) map[string]float64 {
+	t.durations[total] = time.Since(t.start)
+	ms := make(map[string]float64, len(t.durations))
+	for phase, d := range t.durations {
+		recorder.observe(phase, d)
+		ms[phase] = durationMs(d)
+	}
+	return ms
+}
+
+// CountSlowRequest 由访问日志中间件在请求耗时超过阈值时调用。
+func (s *Service) CountSlowRequest() {
+	s.slowRequests.Add(1)
+}
+
+// CountPanic 由 panic 恢复中间件在捕获 panic 后调用。
+func (s *Service) CountPanic() {
+	s.panics.Add(1)
+}
@@ -1,10 +1,15 @@
 package core
 
 import (
+	"bytes"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
 	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -16,6 +21,11 @@ var (
 	ErrRemarkExists = errors.New("remark already exists")
 	// ErrBackupNotFound 在指定备份不存在时返回。
 	ErrBackupNotFound = errors.New("backup not found")
+	// ErrBackupFileMissing 在索引中存在该备份、但磁盘上的备份文件已丢失时返回。
+	ErrBackupFileMissing = errors.New("backup file missing")
+
+	// errNoChange 由 update 的 mutator 返回，表示无需写回索引。
+	errNoChange = errors.New("no change")
 )
 
 // BackupItem 对应 index.json 的 items 元素。
@@ -30,15 +40,63 @@ type BackupItem struct {
 	IsAuto          bool      `json:"is_auto"`
 	SourcePath      string    `json:"source_path"`
 	LastModified    time.Time `json:"last_modified"`
+	// Trigger 为创建备份的来源，取值见 BackupTrigger* 常量；旧版本创建的备份为空。
+	Trigger string `json:"trigger,omitempty"`
+	// Tags 为机器可读的标签，与供人阅读的 Remark 分开；键非空，值可以为空。
+	Tags map[string]string `json:"tags,omitempty"`
+	// HashAlgo 非空时表示该条目的 ContentHash 使用与索引不同的算法（如迁移自旧索引），为空时沿用 IndexData.HashAlgo。
+	HashAlgo string `json:"hash_algo,omitempty"`
+	// Encrypted 表示备份文件以 AES-256-GCM 加密保存，KDFSalt 为派生密钥所用的 scrypt 盐（base64）。
+	// KeyID 为加密所用口令的密钥 ID，同时写在文件头中；轮换口令中断时，条目可分属新旧两个密钥。旧版本创建的条目为空。
+	Encrypted bool   `json:"encrypted,omitempty"`
+	KDFSalt   string `json:"kdf_salt,omitempty"`
+	KeyID     string `json:"key_id,omitempty"`
 }
 
+// 备份的来源：定时扫描、POST /api/scan 与 POST /api/backups。
+const (
+	BackupTriggerTicker       = "ticker"
+	BackupTriggerAPIScan      = "api_scan"
+	BackupTriggerManualCreate = "manual_create"
+)
+
 // IndexData 对应 index.json 文件结构。
 type IndexData struct {
-	TargetPath        string            `json:"target_path"`
+	TargetPath string `json:"target_path"`
+	// HashAlgo 为创建索引时使用的内容哈希算法，取值见 Hash* 常量；旧版本索引为空，按 sha256 处理。
 	HashAlgo          string            `json:"hash_algo"`
 	LatestFingerprint string            `json:"latest_fingerprint"`
 	Items             []BackupItem      `json:"items"`
 	Remarks           map[string]string `json:"remarks"`
+	// SortedIDs 为按创建时间倒序排列的备份 ID，ListBackups 直接按此顺序输出而无需排序。
+	SortedIDs []string `json:"sorted_ids,omitempty"`
+}
+
+// StoreOptions 定制 Store 的可选行为。
+type StoreOptions struct {
+	// Lock 控制 index.json.lock 的等待超时与陈旧锁处理。
+	Lock util.LockOptions
+	// BackupCount 为保留的历史索引份数（index.1.json … index.N.json），0 表示不保留。
+	BackupCount int
+	// Logger 输出索引回退等诊断信息，可为空。
+	Logger Logger
+	// CacheTTL 大于 0 时，距上次读取 index.json 不足该时长的读操作直接使用内存缓存，
+	// 其他进程的修改最多延迟 CacheTTL 可见；本进程的写入会立即使缓存失效。
+	CacheTTL time.Duration
+	// IntegrityKey 非空时为每次写入的索引生成 HMAC 签名（index.json.sig），并在加载时校验。
+	IntegrityKey []byte
+	// OnTampered 在索引由可信变为签名不符时调用，调用时持有 Store 的锁，不得回调 Store。
+	OnTampered func()
+	// RemarkCaseInsensitive 为 true 时仅大小写不同的备注视为重复；备注总是按规范化后的形式比较。
+	RemarkCaseInsensitive bool
+	// Timestamps 决定重新生成默认备注时的时间格式，取值见 Timestamps* 常量。
+	Timestamps string
+	// HashAlgo 为配置的内容哈希算法；非空时已有备份的索引必须记录相同的算法，否则读取返回 ErrAlgoMismatch。
+	// 没有备份的索引直接改用该算法。
+	HashAlgo string
+	// TargetFingerprint 计算目标文件当前的快速指纹，删除最新备份时用于修正 latest_fingerprint；
+	// 为空或返回错误时退回使用剩余备份中最新一项的指纹。
+	TargetFingerprint func() (string, error)
 }
 
 // Store 管理 index.json 的读写与并发控制。
@@ -46,15 +104,36 @@ type Store struct {
 	indexPath  string
 	lockPath   string
 	targetPath string
+	opts       StoreOptions
 	mu         sync.Mutex
+
+	// raw 与 cached 为最近一次解析的 index.json 原文及结果，内容未变化时免去重复解析。
+	raw       []byte
+	rawExists bool
+	// rawValid 为 false 表示 raw 解析失败、cached 来自历史索引，此时不应轮转 raw。
+	rawValid bool
+	cached   *IndexData
+	itemsMap map[string]*BackupItem
+	// sortedValid 表示 cached.SortedIDs 满足 ValidateSortedIDs 的不变式。
+	sortedValid bool
+	// tampered 为 true 表示索引与签名不符，此时拒绝一切写入。
+	tampered bool
+	// cachedAt 为最近一次从磁盘读取 index.json 的时间，零值表示缓存已失效。
+	cachedAt time.Time
 }
 
 // NewStore 创建 Store 实例。
 func NewStore(indexPath, targetPath string) *Store {
+	return NewStoreWithOptions(indexPath, targetPath, StoreOptions{})
+}
+
+// NewStoreWithOptions 使用自定义选项创建 Store 实例。
+func NewStoreWithOptions(indexPath, targetPath string, opts StoreOptions) *Store {
 	return &Store{
 		indexPath:  indexPath,
 		lockPath:   indexPath + ".lock",
 		targetPath: targetPath,
+		opts:       opts,
 	}
 }
 
@@ -73,12 +152,23 @@ func (s *Store) Snapshot() (*IndexData, error) {
 func (s *Store) AddBackup(item BackupItem, latestFingerprint string) (*IndexData, error) {
 	return s.update(func(idx *IndexData) error {
 		if item.Remark != "" {
-			if existing, ok := idx.Remarks[item.Remark]; ok && existing != item.ID {
+			if existing, ok := findRemark(idx.Remarks, item.Remark, s.opts.RemarkCaseInsensitive); ok && existing != item.ID {
 				return ErrRemarkExists
 			}
 			idx.Remarks[item.Remark] = item.ID
 		}
+		item.CreatedAt = item.CreatedAt.UTC()
+		item.Tags = cloneTags(item.Tags)
+		if item.HashAlgo == idx.HashAlgo {
+			item.HashAlgo = ""
+		}
 		idx.Items = append(idx.Items, item)
+		// 通常新备份最新，直接放在最前；时钟回拨等情况下按创建时刻重排。
+		if newest := idx.newestItem(); newest != nil && newerFirst(newest, &item) {
+			idx.rebuildSortedIDs()
+		} else {
+			idx.SortedIDs = append([]string{item.ID}, idx.SortedIDs...)
+		}
 		idx.LatestFingerprint = latestFingerprint
 		return nil
 	})
@@ -92,6 +182,18 @@ func (s *Store) UpdateLatestFingerprint(fingerprint string) (*IndexData, error)
 	})
 }
 
+// MigrateTargetPath 将索引记录的目标路径改为 target 并清空最新指纹，返回原来记录的路径。
+func (s *Store) MigrateTargetPath(target string) (string, error) {
+	var previous string
+	_, err := s.update(func(idx *IndexData) error {
+		previous = idx.TargetPath
+		idx.TargetPath = target
+		idx.LatestFingerprint = ""
+		return nil
+	})
+	return previous, err
+}
+
 // UpdateRemark 修改备注，保持唯一。
 func (s *Store) UpdateRemark(id, newRemark string) (*BackupItem, error) {
 	var updatedItem *BackupItem
@@ -111,7 +213,7 @@ func (s *Store) UpdateRemark(id, newRemark string) (*BackupItem, error) {
 			return nil
 		}
 		if newRemark != "" {
-			if existing, ok := idx.Remarks[newRemark]; ok && existing != id {
+			if existing, ok := findRemark(idx.Remarks, newRemark, s.opts.RemarkCaseInsensitive); ok && existing != id {
 				return ErrRemarkExists
 			}
 		}
@@ -128,7 +230,35 @@ func (s *Store) UpdateRemark(id, newRemark string) (*BackupItem, error) {
 	return updatedItem, err
 }
 
-// DeleteBackup 删除备份文件记录。
+// ResetRemark 将备注替换为按创建时间生成的默认备注（auto-/manual- 加时间戳），
+// 与其他备注冲突时追加 -n 后缀，保证每个备份都有唯一且非空的备注。
+func (s *Store) ResetRemark(id string) (*BackupItem, error) {
+	var updatedItem *BackupItem
+	_, err := s.update(func(idx *IndexData) error {
+		var item *BackupItem
+		for i := range idx.Items {
+			if idx.Items[i].ID == id {
+				item = &idx.Items[i]
+				break
+			}
+		}
+		if item == nil {
+			return ErrBackupNotFound
+		}
+		if item.Remark != "" {
+			delete(idx.Remarks, item.Remark)
+		}
+		item.Remark = uniqueRemark(idx.Remarks, autoRemark(item.IsAuto, item.CreatedAt, s.opts.Timestamps), s.opts.RemarkCaseInsensitive)
+		idx.Remarks[item.Remark] = id
+		updatedItem = item.clone()
+		return nil
+	})
+	return updatedItem, err
+}
+
+// DeleteBackup 删除备份文件记录。只有被删除的备份恰好对应 latest_fingerprint 时才修正它：
+// 优先重新计算目标文件的指纹，目标不存在时退回剩余备份中最新一项的指纹。
+// 删除较旧的备份不改变 latest_fingerprint，以免下次扫描把未变化的目标误判为变更。
 func (s *Store) DeleteBackup(id string) (*BackupItem, error) {
 	var removed BackupItem
 	_, err := s.update(func(idx *IndexData) error {
@@ -150,14 +280,26 @@ func (s *Store) DeleteBackup(id string) (*BackupItem, error) {
 			return ErrBackupNotFound
 		}
 		idx.Items = items
+		sorted := make([]string, 0, len(items))
+		for _, sid := range idx.SortedIDs {
+			if sid != id {
+				sorted = append(sorted, sid)
+			}
+		}
+		idx.SortedIDs = sorted
 		if removed.Remark != "" {
 			delete(idx.Remarks, removed.Remark)
 		}
-		if latest.ID != "" {
-			idx.LatestFingerprint = latest.FileFingerprint
-		} else {
-			idx.LatestFingerprint = ""
+		if removed.FileFingerprint != idx.LatestFingerprint {
+			return nil
 		}
+		if s.opts.TargetFingerprint != nil {
+			if fp, err := s.opts.TargetFingerprint(); err == nil {
+				idx.LatestFingerprint = fp
+				return nil
+			}
+		}
+		idx.LatestFingerprint = latest.FileFingerprint
 		return nil
 	})
 	if err != nil {
@@ -166,6 +308,38 @@ func (s *Store) DeleteBackup(id string) (*BackupItem, error) {
 	return &removed, nil
 }
 
+// MigrateRemarks 为备注为空的旧备份按创建时间补齐默认备注，返回补齐的数量。
+// 按创建时间顺序处理，结果可重复；名称冲突时沿用 -n 后缀规则。
+func (s *Store) MigrateRemarks() (int, error) {
+	migrated := 0
+	_, err := s.update(func(idx *IndexData) error {
+		order := make([]int, 0)
+		for i := range idx.Items {
+			if idx.Items[i].Remark == "" {
+				order = append(order, i)
+			}
+		}
+		sort.SliceStable(order, func(a, b int) bool {
+			return idx.Items[order[a]].CreatedAt.Before(idx.Items[order[b]].CreatedAt)
+		})
+		for _, i := range order {
+			item := &idx.Items[i]
+			remark := uniqueRemark(idx.Remarks, autoRemark(item.IsAuto, item.CreatedAt, s.opts.Timestamps), s.opts.RemarkCaseInsensitive)
+			item.Remark = remark
+			idx.Remarks[remark] = item.ID
+			migrated++
+		}
+		if migrated == 0 {
+			return errNoChange
+		}
+		return nil
+	})
+	if errors.Is(err, errNoChange) {
+		return 0, nil
+	}
+	return migrated, err
+}
+
 // FindByContentHash 查找同内容备份。
 func (s *Store) FindByContentHash(hash string) (*BackupItem, error) {
 	idx, err := s.Snapshot()
@@ -181,92 +355,441 @@ func (s *Store) FindByContentHash(hash string) (*BackupItem, error) {
 	return nil, nil
 }
 
-// FindByID 查找备份。
+// FindByID 通过 ID 索引查找备份。
 func (s *Store) FindByID(id string) (*BackupItem, error) {
-	idx, err := s.Snapshot()
-	if err != nil {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.refreshUnlocked(); err != nil {
 		return nil, err
 	}
-	for _, item := range idx.Items {
-		if item.ID == id {
-			clone := item
-			return &clone, nil
-		}
+	item, ok := s.itemsMap[id]
+	if !ok {
+		return nil, ErrBackupNotFound
+	}
+	return item.clone(), nil
+}
+
+// FindByRemark 通过备注索引查找备份，备注按规范化后的形式（及 RemarkCaseInsensitive）匹配，
+// 未登记时返回 ErrBackupNotFound。
+func (s *Store) FindByRemark(remark string) (*BackupItem, error) {
+	s.mu.Lock()
+	if err := s.refreshUnlocked(); err != nil {
+		s.mu.Unlock()
+		return nil, err
+	}
+	id, ok := findRemark(s.cached.Remarks, NormalizeRemark(remark), s.opts.RemarkCaseInsensitive)
+	s.mu.Unlock()
+	if !ok {
+		return nil, ErrBackupNotFound
 	}
-	return nil, ErrBackupNotFound
+	return s.FindByID(id)
 }
 
 // ListBackups 返回按创建时间倒序排列的备份列表。
 func (s *Store) ListBackups() ([]BackupItem, error) {
-	idx, err := s.Snapshot()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.refreshUnlocked(); err != nil {
+		return nil, err
+	}
+	return s.sortedItemsUnlocked(nil), nil
+}
+
+// 备份列表分页的条数上限；limit 超出 1..MaxBackupPageLimit 时使用 DefaultBackupPageLimit。
+const (
+	DefaultBackupPageLimit = 100
+	MaxBackupPageLimit     = 1000
+)
+
+// ErrInvalidCursor 在分页游标无法解析时返回。
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// ListBackupsPage 按 ListBackups 的顺序返回游标之后的至多 limit 个备份以及下一页的游标，
+// cursor 为空时从第一页开始，没有更多结果时下一页游标为空字符串。
+// 游标记录上一页最后一项的创建时刻与 ID，该备份被删除后仍可继续翻页，同一游标可重复使用。
+func (s *Store) ListBackupsPage(cursor string, limit int) ([]BackupItem, string, error) {
+	return s.ListBackupsFilteredPage(BackupFilter{}, cursor, limit)
+}
+
+// ListBackupsFilteredPage 与 ListBackupsPage 相同，但只在满足 f 的备份中分页。
+func (s *Store) ListBackupsFilteredPage(f BackupFilter, cursor string, limit int) ([]BackupItem, string, error) {
+	page, err := s.ListBackupsPaged(f, BackupSort{}, cursor, 0, limit)
 	if err != nil {
+		return nil, "", err
+	}
+	return page.Items, page.NextCursor, nil
+}
+
+// BackupPage 为分页列出备份的结果。Total 为满足筛选条件的备份总数，Offset 为本页第一项在完整列表中的位置，
+// Limit 为实际使用的每页条数；NextCursor 为下一页的游标，没有更多结果时为空字符串。
+type BackupPage struct {
+	Items      []BackupItem `json:"items"`
+	NextCursor string       `json:"next_cursor"`
+	Total      int          `json:"total"`
+	Limit      int          `json:"limit"`
+	Offset     int          `json:"offset"`
+}
+
+// ListBackupsPaged 在满足 f 的备份中按 order 分页，排序与切片在持有锁时一次完成。cursor 非空时从游标之后开始，
+// 再跳过 offset 项；offset 超出范围时返回空页而不是错误，负数按 0 处理。
+// 游标只记录创建时刻与 ID，按 size 或 remark 排序时只能用 offset 翻页：不返回 NextCursor，传入 cursor 返回 ErrInvalidSort。
+func (s *Store) ListBackupsPaged(f BackupFilter, order BackupSort, cursor string, offset, limit int) (*BackupPage, error) {
+	if limit < 1 || limit > MaxBackupPageLimit {
+		limit = DefaultBackupPageLimit
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	var after *BackupItem
+	if cursor != "" {
+		if order.Key != "" && order.Key != SortCreatedAt {
+			return nil, fmt.Errorf("%w: 按 %s 排序时不支持 cursor，请使用 offset", ErrInvalidSort, order.Key)
+		}
+		c, err := decodeBackupCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		after = c
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.refreshUnlocked(); err != nil {
 		return nil, err
 	}
-	items := make([]BackupItem, len(idx.Items))
-	copy(items, idx.Items)
-	sort.Slice(items, func(i, j int) bool {
-		return items[i].CreatedAt.After(items[j].CreatedAt)
+	items := s.sortedItemsUnlocked(f.match)
+	order.sortItems(items)
+	start := 0
+	if after != nil {
+		start = sort.Search(len(items), func(i int) bool { return order.less(after, &items[i]) })
+	}
+	start += offset
+	if start > len(items) {
+		start = len(items)
+	}
+	page := &BackupPage{Total: len(items), Limit: limit, Offset: start}
+	end := start + limit
+	if end >= len(items) {
+		page.Items = items[start:]
+		return page, nil
+	}
+	page.Items = items[start:end]
+	if order.Key == "" || order.Key == SortCreatedAt {
+		page.NextCursor = encodeBackupCursor(&items[end-1])
+	}
+	return page, nil
+}
+
+// sortedItemsUnlocked 返回按 newerFirst 排序的备份副本，keep 非 nil 时先筛掉不满足的备份。
+// 通常直接按 SortedIDs 输出；SortedIDs 被外部改坏时退回排序，下一次写入会修复它。调用方需持有 s.mu。
+func (s *Store) sortedItemsUnlocked(keep func(*BackupItem) bool) []BackupItem {
+	idx := s.cached
+	items := make([]BackupItem, 0, len(idx.Items))
+	if s.sortedValid {
+		for _, id := range idx.SortedIDs {
+			if item := s.itemsMap[id]; keep == nil || keep(item) {
+				items = append(items, *item.clone())
+			}
+		}
+		return items
+	}
+	for i := range idx.Items {
+		if keep == nil || keep(&idx.Items[i]) {
+			items = append(items, *idx.Items[i].clone())
+		}
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return newerFirst(&items[i], &items[j])
 	})
-	return items, nil
+	return items
+}
+
+// encodeBackupCursor 将备份的创建时刻与 ID 编码为不透明的分页游标。
+func encodeBackupCursor(item *BackupItem) string {
+	raw := item.CreatedAt.UTC().Format(time.RFC3339Nano) + "|" + item.ID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeBackupCursor(cursor string) (*BackupItem, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	ts, id, ok := strings.Cut(string(raw), "|")
+	if !ok || id == "" {
+		return nil, ErrInvalidCursor
+	}
+	at, err := time.Parse(time.RFC3339Nano, ts)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &BackupItem{ID: id, CreatedAt: at}, nil
 }
 
 func (s *Store) update(mutator func(*IndexData) error) (*IndexData, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	var updated *IndexData
-	err := util.WithFileLock(s.lockPath, func() error {
+	err := util.WithFileLockOptions(s.lockPath, s.opts.Lock, func() error {
+		// 持有文件锁后必须重新读取磁盘，不能使用可能过期的缓存。
+		s.cachedAt = time.Time{}
 		idx, err := s.loadIndexUnlocked()
 		if err != nil {
 			return err
 		}
+		if s.tampered {
+			return ErrIndexTampered
+		}
 		if err := mutator(idx); err != nil {
 			return err
 		}
-		idx.ensureDefaults(s.targetPath)
-		if err := util.AtomicWriteJSON(s.indexPath, idx); err != nil {
+		idx.ensureDefaults(s.targetPath, s.opts.HashAlgo)
+		if idx.ValidateSortedIDs() != nil {
+			idx.rebuildSortedIDs()
+		}
+		prev, rotate := s.raw, s.rawExists && s.rawValid
+		payload, err := util.MarshalJSON(idx, util.AtomicWriteJSONOptions{Indent: true, EscapeHTML: true})
+		if err != nil {
+			return err
+		}
+		if err := util.AtomicWriteFile(s.indexPath, payload, 0o600); err != nil {
 			return err
 		}
+		s.cachedAt = time.Time{}
+		if err := s.writeSignatureUnlocked(payload); err != nil {
+			return err
+		}
+		if rotate {
+			s.rotateBackupsUnlocked(prev)
+		}
 		updated = idx.clone()
 		return nil
 	})
 	return updated, err
 }
 
+// backupIndexPath 返回第 n 份历史索引路径，如 index.json → index.1.json。
+func (s *Store) backupIndexPath(n int) string {
+	ext := filepath.Ext(s.indexPath)
+	return fmt.Sprintf("%s.%d%s", strings.TrimSuffix(s.indexPath, ext), n, ext)
+}
+
+// rotateBackupsUnlocked 将历史索引依次后移一位，并把写入前的索引内容保存为 index.1.json。
+// 轮转失败不影响本次写入结果，只记录日志。
+func (s *Store) rotateBackupsUnlocked(prev []byte) {
+	n := s.opts.BackupCount
+	if n <= 0 {
+		return
+	}
+	for i := n - 1; i >= 1; i-- {
+		if err := os.Rename(util.LongPath(s.backupIndexPath(i)), util.LongPath(s.backupIndexPath(i+1))); err != nil && !os.IsNotExist(err) {
+			s.log().Warn("轮转历史索引失败", "path", s.backupIndexPath(i), "error", err)
+		}
+	}
+	if err := util.AtomicWriteFile(s.backupIndexPath(1), prev, 0o600); err != nil {
+		s.log().Warn("保存历史索引失败", "path", s.backupIndexPath(1), "error", err)
+	}
+}
+
+func (s *Store) log() Logger {
+	if s.opts.Logger == nil {
+		return discardLogger{}
+	}
+	return s.opts.Logger
+}
+
 func (s *Store) loadIndexUnlocked() (*IndexData, error) {
+	if err := s.refreshUnlocked(); err != nil {
+		return nil, err
+	}
+	return s.cached.clone(), nil
+}
+
+// refreshUnlocked 读取 index.json，内容与上次解析时不同才重新解析并重建 itemsMap。
+// 缓存未超过 CacheTTL 时不读取磁盘。
+func (s *Store) refreshUnlocked() error {
+	if s.cached != nil && s.opts.CacheTTL > 0 && !s.cachedAt.IsZero() && time.Since(s.cachedAt) < s.opts.CacheTTL {
+		return nil
+	}
+	readAt := time.Now()
 	data, exists, err := util.ReadFileIfExists(s.indexPath)
 	if err != nil {
-		return nil, fmt.Errorf("read index: %w", err)
+		return fmt.Errorf("read index: %w", err)
+	}
+	if err := s.verifySignatureUnlocked(data, exists); err != nil {
+		return err
+	}
+	if s.cached != nil && exists == s.rawExists && bytes.Equal(data, s.raw) {
+		s.cachedAt = readAt
+		return nil
 	}
 	var idx IndexData
+	valid := true
 	if exists {
 		if err := json.Unmarshal(data, &idx); err != nil {
-			return nil, fmt.Errorf("unmarshal index: %w", err)
+			recovered, ok := s.loadBackupIndex(err)
+			if !ok {
+				return fmt.Errorf("unmarshal index: %w", err)
+			}
+			idx, valid = *recovered, false
 		}
 	}
-	idx.ensureDefaults(s.targetPath)
-	return &idx, nil
+	idx.ensureDefaults(s.targetPath, s.opts.HashAlgo)
+	if err := idx.checkHashAlgo(s.opts.HashAlgo); err != nil {
+		return err
+	}
+	s.raw = data
+	s.rawExists = exists
+	s.rawValid = valid
+	s.cached = &idx
+	s.cachedAt = readAt
+	s.itemsMap = make(map[string]*BackupItem, len(idx.Items))
+	for i := range idx.Items {
+		s.itemsMap[idx.Items[i].ID] = &idx.Items[i]
+	}
+	if err := idx.ValidateSortedIDs(); err != nil {
+		s.sortedValid = false
+		s.log().Warn("索引 sorted_ids 无效，列表将临时排序，下一次写入时重建", "error", err)
+	} else {
+		s.sortedValid = true
+	}
+	return nil
+}
+
+// loadBackupIndex 在 index.json 无法解析时依次尝试历史索引，下一次写入会用恢复的内容覆盖损坏的索引。
+func (s *Store) loadBackupIndex(cause error) (*IndexData, bool) {
+	for i := 1; i <= s.opts.BackupCount; i++ {
+		path := s.backupIndexPath(i)
+		s.log().Warn("索引解析失败，尝试回退到历史索引", "path", s.indexPath, "error", cause, "fallback", path)
+		data, exists, err := util.ReadFileIfExists(path)
+		if err != nil || !exists {
+			continue
+		}
+		var idx IndexData
+		if err := json.Unmarshal(data, &idx); err != nil {
+			cause = err
+			continue
+		}
+		s.log().Info("已从历史索引恢复索引", "path", path)
+		return &idx, true
+	}
+	return nil, false
 }
 
-func (idx *IndexData) ensureDefaults(target string) {
+// ensureDefaults 补齐旧版本索引缺少的字段；algo 非空且索引中没有备份时改用该哈希算法。
+func (idx *IndexData) ensureDefaults(target, algo string) {
 	if idx.Remarks == nil {
 		idx.Remarks = make(map[string]string)
 	}
 	if idx.Items == nil {
 		idx.Items = make([]BackupItem, 0)
 	}
+	if len(idx.Items) == 0 && algo != "" {
+		idx.HashAlgo = algo
+	}
 	if idx.HashAlgo == "" {
-		idx.HashAlgo = "sha256"
+		idx.HashAlgo = HashSHA256
 	}
 	if idx.TargetPath == "" {
 		idx.TargetPath = target
 	}
+	// 旧版本索引没有 sorted_ids，加载时按 items 补建。
+	if len(idx.SortedIDs) == 0 && len(idx.Items) > 0 {
+		idx.rebuildSortedIDs()
+	}
+}
+
+// checkHashAlgo 校验索引与各条目的哈希算法：索引算法须受支持且与 want（非空时）一致，
+// 条目要么沿用索引算法，要么以 HashAlgo 标明另一种受支持的算法。
+func (idx *IndexData) checkHashAlgo(want string) error {
+	if !ValidHashAlgo(idx.HashAlgo) {
+		return fmt.Errorf("%w: 索引使用未知算法 %q", ErrAlgoMismatch, idx.HashAlgo)
+	}
+	if want != "" && idx.HashAlgo != want {
+		return fmt.Errorf("%w: 索引使用 %s，配置为 %s", ErrAlgoMismatch, idx.HashAlgo, want)
+	}
+	for i := range idx.Items {
+		if algo := idx.Items[i].HashAlgo; algo != "" && !ValidHashAlgo(algo) {
+			return fmt.Errorf("%w: 备份 %s 使用未知算法 %q", ErrAlgoMismatch, idx.Items[i].ID, algo)
+		}
+	}
+	return nil
+}
+
+// newestItem 返回 SortedIDs 首项对应的备份，没有备份时返回 nil。
+func (idx *IndexData) newestItem() *BackupItem {
+	if len(idx.SortedIDs) == 0 {
+		return nil
+	}
+	for i := range idx.Items {
+		if idx.Items[i].ID == idx.SortedIDs[0] {
+			return &idx.Items[i]
+		}
+	}
+	return nil
+}
+
+// newerFirst 定义备份列表的顺序：按创建时刻倒序，时刻相同时按 ID 倒序，保证分页游标位置唯一。
+func newerFirst(a, b *BackupItem) bool {
+	if !a.CreatedAt.Equal(b.CreatedAt) {
+		return a.CreatedAt.After(b.CreatedAt)
+	}
+	return a.ID > b.ID
+}
+
+// rebuildSortedIDs 按创建时间倒序重建 SortedIDs。
+func (idx *IndexData) rebuildSortedIDs() {
+	items := make([]*BackupItem, len(idx.Items))
+	for i := range idx.Items {
+		items[i] = &idx.Items[i]
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return newerFirst(items[i], items[j])
+	})
+	idx.SortedIDs = make([]string, len(items))
+	for i, item := range items {
+		idx.SortedIDs[i] = item.ID
+	}
+}
+
+// ValidateSortedIDs 检查 SortedIDs 是否恰好包含每个备份一次，且按创建时间倒序（相同时按 ID 倒序）排列。
+func (idx *IndexData) ValidateSortedIDs() error {
+	if len(idx.SortedIDs) != len(idx.Items) {
+		return fmt.Errorf("sorted_ids 有 %d 项，items 有 %d 项", len(idx.SortedIDs), len(idx.Items))
+	}
+	byID := make(map[string]*BackupItem, len(idx.Items))
+	for i := range idx.Items {
+		byID[idx.Items[i].ID] = &idx.Items[i]
+	}
+	seen := make(map[string]struct{}, len(idx.SortedIDs))
+	var prev *BackupItem
+	for _, id := range idx.SortedIDs {
+		item, ok := byID[id]
+		if !ok {
+			return fmt.Errorf("sorted_ids 包含不存在的备份 %s", id)
+		}
+		if _, dup := seen[id]; dup {
+			return fmt.Errorf("sorted_ids 重复包含备份 %s", id)
+		}
+		seen[id] = struct{}{}
+		if prev != nil && newerFirst(item, prev) {
+			return fmt.Errorf("sorted_ids 中备份 %s 晚于其前一项 %s", id, prev.ID)
+		}
+		prev = item
+	}
+	return nil
 }
 
 func (idx *IndexData) clone() *IndexData {
 	copyIdx := *idx
 	if idx.Items != nil {
 		copyIdx.Items = make([]BackupItem, len(idx.Items))
-		copy(copyIdx.Items, idx.Items)
+		for i := range idx.Items {
+			copyIdx.Items[i] = *idx.Items[i].clone()
+		}
+	}
+	if idx.SortedIDs != nil {
+		copyIdx.SortedIDs = make([]string, len(idx.SortedIDs))
+		copy(copyIdx.SortedIDs, idx.SortedIDs)
 	}
 	if idx.Remarks != nil {
 		copyIdx.Remarks = make(map[string]string, len(idx.Remarks))
@@ -279,5 +802,17 @@ func (idx *IndexData) clone() *IndexData {
 
 func (item *BackupItem) clone() *BackupItem {
 	copyItem := *item
+	copyItem.Tags = cloneTags(item.Tags)
 	return &copyItem
 }
+
+func cloneTags(tags map[string]string) map[string]string {
+	if tags == nil {
+		return nil
+	}
+	out := make(map[string]string, len(tags))
+	for k, v := range tags {
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,831 @@
+package core_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"codex-backup-tool/internal/core"
+	"codex-backup-tool/internal/util"
+)
+
+func newStoreWithItems(tb testing.TB, n int) (*core.Store, []string) {
+	tb.Helper()
+	dir := tb.TempDir()
+	idx := core.IndexData{
+		TargetPath: filepath.Join(dir, "auth.json"),
+		HashAlgo:   "sha256",
+		Items:      make([]core.BackupItem, 0, n),
+		Remarks:    make(map[string]string, n),
+	}
+	ids := make([]string, 0, n)
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < n; i++ {
+		id := fmt.Sprintf("item-%05d", i)
+		remark := fmt.Sprintf("auto-%05d", i)
+		idx.Items = append(idx.Items, core.BackupItem{
+			ID:          id,
+			Filename:    id + ".json",
+			ContentHash: fmt.Sprintf("%064d", i),
+			CreatedAt:   base.Add(time.Duration(i) * time.Second),
+			Remark:      remark,
+			IsAuto:      true,
+		})
+		idx.Remarks[remark] = id
+		ids = append(ids, id)
+	}
+	indexPath := filepath.Join(dir, "index.json")
+	if err := util.AtomicWriteJSONDefault(indexPath, &idx); err != nil {
+		tb.Fatalf("write index: %v", err)
+	}
+	return core.NewStore(indexPath, idx.TargetPath), ids
+}
+
+func TestStoreFindByIDSeesExternalChanges(t *testing.T) {
+	store, ids := newStoreWithItems(t, 3)
+	item, err := store.FindByID(ids[1])
+	if err != nil || item.ID != ids[1] {
+		t.Fatalf("find: %+v %v", item, err)
+	}
+	if _, err := store.DeleteBackup(ids[1]); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := store.FindByID(ids[1]); err != core.ErrBackupNotFound {
+		t.Fatalf("expected ErrBackupNotFound after delete, got %v", err)
+	}
+	// 修改返回值不能影响 Store 内部的缓存
+	item, _ = store.FindByID(ids[2])
+	item.Remark = "changed"
+	again, _ := store.FindByID(ids[2])
+	if again.Remark == "changed" {
+		t.Fatalf("FindByID must return a copy")
+	}
+}
+
+// BenchmarkFindByID10000Items 对比 ID 索引查找与对快照的线性扫描。
+func BenchmarkFindByID10000Items(b *testing.B) {
+	store, ids := newStoreWithItems(b, 10000)
+	target := ids[len(ids)-1]
+	b.Run("map", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := store.FindByID(target); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("linear", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			idx, err := store.Snapshot()
+			if err != nil {
+				b.Fatal(err)
+			}
+			found := false
+			for _, item := range idx.Items {
+				if item.ID == target {
+					found = true
+					break
+				}
+			}
+			if !found {
+				b.Fatal("not found")
+			}
+		}
+	})
+}
+
+// TestStoreConcurrentReadWrite 让多个读写协程同时操作同一个 Store，
+// 需配合 go test -race 运行以发现数据竞争。
+func TestStoreConcurrentReadWrite(t *testing.T) {
+	t.Parallel()
+	store, ids := newStoreWithItems(t, 20)
+	duration := 5 * time.Second
+	if testing.Short() {
+		duration = 500 * time.Millisecond
+	}
+	deadline := time.Now().Add(duration)
+
+	var wg sync.WaitGroup
+	errCh := make(chan error, 12)
+	for r := 0; r < 10; r++ {
+		wg.Add(1)
+		go func(r int) {
+			defer wg.Done()
+			for i := 0; time.Now().Before(deadline); i++ {
+				idx, err := store.Snapshot()
+				if err != nil {
+					errCh <- fmt.Errorf("snapshot: %w", err)
+					return
+				}
+				// assert.NoRace(): 快照是深拷贝，读取其中的 map 与切片不应与写协程冲突；
+				// 同时 Remarks 必须与 Items 一致，不能读到写了一半的索引。
+				byID := make(map[string]string, len(idx.Items))
+				for _, item := range idx.Items {
+					byID[item.ID] = item.Remark
+				}
+				for remark, id := range idx.Remarks {
+					if byID[id] != remark {
+						errCh <- fmt.Errorf("remark %q points to %q which has remark %q", remark, id, byID[id])
+						return
+					}
+				}
+				// assert.NoRace(): FindByID 返回副本，只能得到完整条目或 ErrBackupNotFound。
+				item, err := store.FindByID(ids[(r+i)%len(ids)])
+				if err != nil && !errors.Is(err, core.ErrBackupNotFound) {
+					errCh <- fmt.Errorf("find: %w", err)
+					return
+				}
+				if err == nil {
+					item.Remark = "reader-local"
+				}
+				if _, err := store.ListBackups(); err != nil {
+					errCh <- fmt.Errorf("list: %w", err)
+					return
+				}
+			}
+		}(r)
+	}
+	for w := 0; w < 2; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; time.Now().Before(deadline); i++ {
+				id := fmt.Sprintf("w%d-%d", w, i)
+				item := core.BackupItem{ID: id, Filename: id + ".json", CreatedAt: time.Now(), Remark: "r-" + id}
+				if _, err := store.AddBackup(item, id); err != nil {
+					errCh <- fmt.Errorf("add: %w", err)
+					return
+				}
+				if _, err := store.UpdateRemark(id, "u-"+id); err != nil {
+					errCh <- fmt.Errorf("update remark: %w", err)
+					return
+				}
+				// assert.NoRace(): 两个写协程只删除自己新增的条目，删除必然成功。
+				if _, err := store.DeleteBackup(id); err != nil {
+					errCh <- fmt.Errorf("delete: %w", err)
+					return
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errCh)
+	for err := range errCh {
+		t.Error(err)
+	}
+	idx, err := store.Snapshot()
+	if err != nil {
+		t.Fatalf("final snapshot: %v", err)
+	}
+	if len(idx.Items) != len(ids) || len(idx.Remarks) != len(ids) {
+		t.Fatalf("expected %d items after writers cleaned up, got %d items / %d remarks", len(ids), len(idx.Items), len(idx.Remarks))
+	}
+}
+
+func TestStoreRotatesAndRecoversIndexBackups(t *testing.T) {
+	dir := t.TempDir()
+	indexPath := filepath.Join(dir, "index.json")
+	var logs bytes.Buffer
+	opts := core.StoreOptions{
+		BackupCount: 2,
+		Logger:      core.NewJSONLogger(&logs, slog.LevelInfo),
+	}
+	store := core.NewStoreWithOptions(indexPath, filepath.Join(dir, "auth.json"), opts)
+	for i := 0; i < 4; i++ {
+		id := fmt.Sprintf("item-%d", i)
+		if _, err := store.AddBackup(core.BackupItem{ID: id, Filename: id + ".json", CreatedAt: time.Now()}, id); err != nil {
+			t.Fatalf("add %d: %v", i, err)
+		}
+	}
+	for _, name := range []string{"index.1.json", "index.2.json"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Fatalf("expected %s: %v", name, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "index.3.json")); !os.IsNotExist(err) {
+		t.Fatalf("index.3.json should not exist, err=%v", err)
+	}
+
+	if err := os.WriteFile(indexPath, []byte("{corrupt"), 0o600); err != nil {
+		t.Fatalf("corrupt index: %v", err)
+	}
+	recovered := core.NewStoreWithOptions(indexPath, filepath.Join(dir, "auth.json"), opts)
+	idx, err := recovered.Snapshot()
+	if err != nil {
+		t.Fatalf("snapshot after corruption: %v", err)
+	}
+	if len(idx.Items) != 3 {
+		t.Fatalf("expected index.1.json with 3 items, got %d", len(idx.Items))
+	}
+	if !strings.Contains(logs.String(), `"fallback":`) {
+		t.Fatalf("expected fallback to be logged, got:\n%s", logs.String())
+	}
+
+	// 下一次写入用恢复的内容覆盖损坏的索引，且不会把损坏内容轮转进历史
+	if _, err := recovered.UpdateLatestFingerprint("healed"); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "index.1.json"))
+	if err != nil || string(data) == "{corrupt" {
+		t.Fatalf("corrupt index must not be rotated: %q %v", data, err)
+	}
+	if _, err := core.NewStore(indexPath, "").Snapshot(); err != nil {
+		t.Fatalf("index.json should be valid again: %v", err)
+	}
+}
+
+func TestStoreMigrateRemarks(t *testing.T) {
+	dir := t.TempDir()
+	store := core.NewStore(filepath.Join(dir, "index.json"), filepath.Join(dir, "auth.json"))
+	at := time.Date(2024, 5, 1, 8, 30, 0, 0, time.Local)
+	stamp := core.FormatTimestamp(at, core.TimestampsLocal)
+	items := []core.BackupItem{
+		{ID: "a", Filename: "a.json", CreatedAt: at, IsAuto: true, Remark: "auto-" + stamp},
+		{ID: "b", Filename: "b.json", CreatedAt: at, IsAuto: true},
+		{ID: "c", Filename: "c.json", CreatedAt: at.Add(time.Second)},
+	}
+	for _, item := range items {
+		if _, err := store.AddBackup(item, item.ID); err != nil {
+			t.Fatalf("add %s: %v", item.ID, err)
+		}
+	}
+	n, err := store.MigrateRemarks()
+	if err != nil || n != 2 {
+		t.Fatalf("migrate: n=%d err=%v", n, err)
+	}
+	want := map[string]string{"b": "auto-" + stamp + "-1", "c": "manual-" + core.FormatTimestamp(at.Add(time.Second), core.TimestampsLocal)}
+	for id, remark := range want {
+		item, err := store.FindByID(id)
+		if err != nil || item.Remark != remark {
+			t.Fatalf("item %s: remark=%q err=%v, want %q", id, item.Remark, err, remark)
+		}
+	}
+	idx, _ := store.Snapshot()
+	if idx.Remarks["auto-"+stamp+"-1"] != "b" {
+		t.Fatalf("remarks map not updated: %v", idx.Remarks)
+	}
+	if n, err := store.MigrateRemarks(); err != nil || n != 0 {
+		t.Fatalf("second migrate should be a no-op: n=%d err=%v", n, err)
+	}
+}
+
+func TestDSTBackwardBackupsStayDistinct(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("tzdata unavailable: %v", err)
+	}
+	prev := time.Local
+	time.Local = ny
+	t.Cleanup(func() { time.Local = prev })
+
+	// 2024-11-03 纽约夏令时结束，本地时间 01:30 先后出现两次，相隔一小时。
+	first := time.Date(2024, 11, 3, 5, 30, 0, 0, time.UTC)
+	second := first.Add(time.Hour)
+	if first.In(ny).Format("15:04") != second.In(ny).Format("15:04") {
+		t.Fatalf("expected identical wall clock, got %s and %s", first.In(ny), second.In(ny))
+	}
+	for _, mode := range []string{core.TimestampsLocal, core.TimestampsUTC} {
+		a, b := core.BuildBackupFilename(first, mode, "deadbeef", core.CompressionNone), core.BuildBackupFilename(second, mode, "deadbeef", core.CompressionNone)
+		if a == b {
+			t.Fatalf("%s: filenames collide: %s", mode, a)
+		}
+	}
+	if got := core.FormatTimestamp(first, core.TimestampsLocal); got != "20241103-013000-0400" {
+		t.Fatalf("unexpected local stamp %s", got)
+	}
+	if got := core.FormatTimestamp(second, core.TimestampsUTC); got != "20241103-063000Z" {
+		t.Fatalf("unexpected utc stamp %s", got)
+	}
+
+	dir := t.TempDir()
+	store := core.NewStore(filepath.Join(dir, "index.json"), filepath.Join(dir, "auth.json"))
+	// 先写入较晚的备份，确认排序只依据保存的时刻而非写入顺序或本地时间字符串。
+	for _, item := range []core.BackupItem{
+		{ID: "later", Filename: "later.json", CreatedAt: second.In(ny), IsAuto: true},
+		{ID: "earlier", Filename: "earlier.json", CreatedAt: first.In(ny), IsAuto: true},
+	} {
+		if _, err := store.AddBackup(item, item.ID); err != nil {
+			t.Fatalf("add %s: %v", item.ID, err)
+		}
+	}
+	if n, err := store.MigrateRemarks(); err != nil || n != 2 {
+		t.Fatalf("migrate: n=%d err=%v", n, err)
+	}
+	items, err := store.ListBackups()
+	if err != nil || len(items) != 2 || items[0].ID != "later" || items[1].ID != "earlier" {
+		t.Fatalf("unexpected order: %+v (%v)", items, err)
+	}
+	if items[0].CreatedAt.Location() != time.UTC {
+		t.Fatalf("expected CreatedAt stored in UTC, got %s", items[0].CreatedAt)
+	}
+	if items[0].Remark == items[1].Remark || strings.HasSuffix(items[0].Remark, "-1") {
+		t.Fatalf("expected distinct remarks without suffix, got %q and %q", items[0].Remark, items[1].Remark)
+	}
+	idx, err := store.Snapshot()
+	if err != nil {
+		t.Fatalf("snapshot: %v", err)
+	}
+	if err := idx.ValidateSortedIDs(); err != nil {
+		t.Fatalf("sorted ids: %v", err)
+	}
+}
+
+func TestStoreListBackupsPage(t *testing.T) {
+	store, ids := newStoreWithItems(t, 250)
+	var got []string
+	cursor := ""
+	pages := 0
+	for {
+		items, next, err := store.ListBackupsPage(cursor, 40)
+		if err != nil {
+			t.Fatalf("page %d: %v", pages, err)
+		}
+		if next != "" && len(items) != 40 {
+			t.Fatalf("page %d: expected a full page before the end, got %d", pages, len(items))
+		}
+		for _, item := range items {
+			got = append(got, item.ID)
+		}
+		pages++
+		if next == "" {
+			break
+		}
+		// 同一游标重复使用得到相同的结果。
+		again, againNext, err := store.ListBackupsPage(cursor, 40)
+		if err != nil || len(again) != len(items) || again[0].ID != items[0].ID || againNext != next {
+			t.Fatalf("page %d: cursor reuse differs (%v)", pages, err)
+		}
+		cursor = next
+	}
+	if pages != 7 || len(got) != len(ids) {
+		t.Fatalf("expected 7 pages with %d items, got %d pages with %d items", len(ids), pages, len(got))
+	}
+	for i, id := range got {
+		if want := ids[len(ids)-1-i]; id != want {
+			t.Fatalf("position %d: got %s, want %s", i, id, want)
+		}
+	}
+
+	// 游标对应的备份被删除后仍从其之后继续。
+	first, next, err := store.ListBackupsPage("", 10)
+	if err != nil {
+		t.Fatalf("first page: %v", err)
+	}
+	if _, err := store.DeleteBackup(first[9].ID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	items, _, err := store.ListBackupsPage(next, 1)
+	if err != nil || len(items) != 1 || items[0].ID != ids[len(ids)-11] {
+		t.Fatalf("expected to resume after the deleted item, got %+v (%v)", items, err)
+	}
+
+	for _, limit := range []int{0, -5, core.MaxBackupPageLimit + 1} {
+		items, _, err := store.ListBackupsPage("", limit)
+		if err != nil || len(items) != core.DefaultBackupPageLimit {
+			t.Fatalf("limit %d: expected clamp to %d, got %d (%v)", limit, core.DefaultBackupPageLimit, len(items), err)
+		}
+	}
+	if _, _, err := store.ListBackupsPage("not-a-cursor", 10); !errors.Is(err, core.ErrInvalidCursor) {
+		t.Fatalf("expected ErrInvalidCursor, got %v", err)
+	}
+}
+
+func TestStoreListBackupsPagedOffset(t *testing.T) {
+	store, _ := newStoreWithItems(t, 5)
+	all, err := store.ListBackups()
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	page, err := store.ListBackupsPaged(core.BackupFilter{}, core.BackupSort{}, "", 2, 2)
+	if err != nil || page.Total != 5 || page.Offset != 2 || page.Limit != 2 || len(page.Items) != 2 ||
+		page.Items[0].ID != all[2].ID || page.NextCursor == "" {
+		t.Fatalf("unexpected page: %+v (%v)", page, err)
+	}
+	// 游标之后再跳过 offset 项，Offset 报告本页在完整列表中的位置。
+	next, err := store.ListBackupsPaged(core.BackupFilter{}, core.BackupSort{}, page.NextCursor, 1, 2)
+	if err != nil || next.Offset != 5 || len(next.Items) != 0 || next.NextCursor != "" {
+		t.Fatalf("unexpected page after cursor: %+v (%v)", next, err)
+	}
+	for _, offset := range []int{5, 100} {
+		empty, err := store.ListBackupsPaged(core.BackupFilter{}, core.BackupSort{}, "", offset, 10)
+		if err != nil || empty.Total != 5 || empty.Items == nil || len(empty.Items) != 0 || empty.NextCursor != "" {
+			t.Fatalf("offset %d: expected empty page, got %+v (%v)", offset, empty, err)
+		}
+	}
+}
+
+func TestStoreFindByRemark(t *testing.T) {
+	store, ids := newStoreWithItems(t, 3)
+	item, err := store.FindByRemark(" auto-00001 ")
+	if err != nil || item.ID != ids[1] {
+		t.Fatalf("find by remark: %+v (%v)", item, err)
+	}
+	if _, err := store.FindByRemark("missing"); !errors.Is(err, core.ErrBackupNotFound) {
+		t.Fatalf("expected ErrBackupNotFound, got %v", err)
+	}
+}
+
+func TestStoreSearchBackups(t *testing.T) {
+	dir := t.TempDir()
+	store := core.NewStore(filepath.Join(dir, "index.json"), filepath.Join(dir, "auth.json"))
+	base := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	for i, remark := range []string{"before-team-switch", "Before-Team-Switch-2", "daily"} {
+		item := core.BackupItem{
+			ID:          fmt.Sprintf("s%d", i),
+			Filename:    fmt.Sprintf("s%d.json", i),
+			CreatedAt:   base.Add(time.Duration(i) * time.Hour),
+			Remark:      remark,
+			ContentHash: fmt.Sprintf("%02dabcdef0123456789", i),
+		}
+		if _, err := store.AddBackup(item, item.ID); err != nil {
+			t.Fatalf("add %s: %v", item.ID, err)
+		}
+	}
+	cases := []struct {
+		query string
+		want  string
+	}{
+		{"", "s2,s1,s0"},
+		{"TEAM-switch", "s1,s0"},
+		{"02ABC", "s2"},
+		{"nothing", ""},
+	}
+	for _, tc := range cases {
+		items, err := store.SearchBackups(tc.query)
+		if err != nil {
+			t.Fatalf("%q: %v", tc.query, err)
+		}
+		if items == nil {
+			t.Fatalf("%q: expected an empty slice, got nil", tc.query)
+		}
+		ids := make([]string, 0, len(items))
+		for _, item := range items {
+			ids = append(ids, item.ID)
+		}
+		if got := strings.Join(ids, ","); got != tc.want {
+			t.Fatalf("%q: got %s, want %s", tc.query, got, tc.want)
+		}
+	}
+}
+
+func TestStoreListBackupsSorted(t *testing.T) {
+	dir := t.TempDir()
+	store := core.NewStore(filepath.Join(dir, "index.json"), filepath.Join(dir, "auth.json"))
+	base := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	for i, it := range []struct {
+		remark string
+		size   int64
+	}{{"beta", 300}, {"Alpha", 100}, {"gamma", 300}, {"alpha-2", 200}} {
+		item := core.BackupItem{
+			ID:        fmt.Sprintf("o%d", i),
+			Filename:  fmt.Sprintf("o%d.json", i),
+			CreatedAt: base.Add(time.Duration(i) * time.Hour),
+			Remark:    it.remark,
+			Size:      it.size,
+		}
+		if _, err := store.AddBackup(item, item.ID); err != nil {
+			t.Fatalf("add %s: %v", item.ID, err)
+		}
+	}
+	cases := []struct {
+		key, order string
+		want       string
+	}{
+		{"", "", "o3,o2,o1,o0"},
+		{"created_at", "asc", "o0,o1,o2,o3"},
+		// 大小相同时按默认顺序（新的在前）。
+		{"size", "desc", "o2,o0,o3,o1"},
+		{"size", "asc", "o1,o3,o2,o0"},
+		{"remark", "", "o1,o3,o0,o2"},
+		{"REMARK", "desc", "o2,o0,o3,o1"},
+	}
+	for _, tc := range cases {
+		order, err := core.ParseBackupSort(tc.key, tc.order)
+		if err != nil {
+			t.Fatalf("%s %s: parse: %v", tc.key, tc.order, err)
+		}
+		items, err := store.ListBackupsSorted(core.BackupFilter{}, order)
+		if err != nil {
+			t.Fatalf("%s %s: %v", tc.key, tc.order, err)
+		}
+		ids := make([]string, 0, len(items))
+		for _, item := range items {
+			ids = append(ids, item.ID)
+		}
+		if got := strings.Join(ids, ","); got != tc.want {
+			t.Fatalf("%s %s: got %s, want %s", tc.key, tc.order, got, tc.want)
+		}
+	}
+
+	for _, bad := range [][2]string{{"name", ""}, {"size", "up"}} {
+		if _, err := core.ParseBackupSort(bad[0], bad[1]); !errors.Is(err, core.ErrInvalidSort) {
+			t.Fatalf("%v: expected ErrInvalidSort, got %v", bad, err)
+		}
+	}
+
+	// created_at 升序可以用游标翻页；按 size 排序只能用 offset。
+	asc := core.BackupSort{Key: core.SortCreatedAt, Order: core.OrderAsc}
+	page, err := store.ListBackupsPaged(core.BackupFilter{}, asc, "", 0, 2)
+	if err != nil || len(page.Items) != 2 || page.Items[1].ID != "o1" || page.NextCursor == "" {
+		t.Fatalf("unexpected first page: %+v (%v)", page, err)
+	}
+	next, err := store.ListBackupsPaged(core.BackupFilter{}, asc, page.NextCursor, 0, 2)
+	if err != nil || len(next.Items) != 2 || next.Items[0].ID != "o2" || next.NextCursor != "" {
+		t.Fatalf("unexpected second page: %+v (%v)", next, err)
+	}
+	bySize := core.BackupSort{Key: core.SortSize, Order: core.OrderDesc}
+	if _, err := store.ListBackupsPaged(core.BackupFilter{}, bySize, page.NextCursor, 0, 2); !errors.Is(err, core.ErrInvalidSort) {
+		t.Fatalf("expected ErrInvalidSort for cursor with size sort, got %v", err)
+	}
+	sized, err := store.ListBackupsPaged(core.BackupFilter{}, bySize, "", 1, 2)
+	if err != nil || sized.Total != 4 || len(sized.Items) != 2 || sized.Items[0].ID != "o0" || sized.Items[1].ID != "o3" ||
+		sized.NextCursor != "" {
+		t.Fatalf("unexpected size page: %+v (%v)", sized, err)
+	}
+}
+
+func TestStoreListBackupsFiltered(t *testing.T) {
+	dir := t.TempDir()
+	store := core.NewStore(filepath.Join(dir, "index.json"), filepath.Join(dir, "auth.json"))
+	base := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	for i, remark := range []string{"auto-a", "Work laptop", "auto-b", "work desktop", "auto-c"} {
+		item := core.BackupItem{
+			ID:        fmt.Sprintf("b%d", i),
+			Filename:  fmt.Sprintf("b%d.json", i),
+			CreatedAt: base.Add(time.Duration(i) * time.Hour),
+			Remark:    remark,
+			IsAuto:    strings.HasPrefix(remark, "auto-"),
+		}
+		if _, err := store.AddBackup(item, item.ID); err != nil {
+			t.Fatalf("add %s: %v", item.ID, err)
+		}
+	}
+	yes, no := true, false
+	cases := []struct {
+		name   string
+		filter core.BackupFilter
+		want   string
+	}{
+		{"none", core.BackupFilter{}, "b4,b3,b2,b1,b0"},
+		{"from", core.BackupFilter{From: base.Add(3 * time.Hour)}, "b4,b3"},
+		{"to", core.BackupFilter{To: base.Add(time.Hour)}, "b1,b0"},
+		{"from equals to", core.BackupFilter{From: base.Add(2 * time.Hour), To: base.Add(2 * time.Hour)}, "b2"},
+		{"from after to", core.BackupFilter{From: base.Add(3 * time.Hour), To: base.Add(time.Hour)}, ""},
+		{"auto", core.BackupFilter{IsAuto: &yes}, "b4,b2,b0"},
+		{"manual", core.BackupFilter{IsAuto: &no}, "b3,b1"},
+		{"remark", core.BackupFilter{RemarkContains: "WORK"}, "b3,b1"},
+		{"combined", core.BackupFilter{From: base.Add(time.Hour), To: base.Add(4 * time.Hour), IsAuto: &no, RemarkContains: "desk"}, "b3"},
+		{"created after excludes cutoff", core.BackupFilter{CreatedAfter: base.Add(3 * time.Hour)}, "b4"},
+		{"created before excludes cutoff", core.BackupFilter{CreatedBefore: base.Add(time.Hour)}, "b0"},
+		{"created window", core.BackupFilter{CreatedAfter: base, CreatedBefore: base.Add(4 * time.Hour)}, "b3,b2,b1"},
+		{"created window manual", core.BackupFilter{CreatedAfter: base, CreatedBefore: base.Add(4 * time.Hour), IsAuto: &no}, "b3,b1"},
+		{"created empty window", core.BackupFilter{CreatedAfter: base.Add(2 * time.Hour), CreatedBefore: base.Add(2 * time.Hour)}, ""},
+	}
+	for _, tc := range cases {
+		items, err := store.ListBackupsFiltered(tc.filter)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		ids := make([]string, 0, len(items))
+		for _, item := range items {
+			ids = append(ids, item.ID)
+		}
+		if got := strings.Join(ids, ","); got != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.name, got, tc.want)
+		}
+	}
+	page, next, err := store.ListBackupsFilteredPage(core.BackupFilter{IsAuto: &yes}, "", 2)
+	if err != nil || len(page) != 2 || page[1].ID != "b2" || next == "" {
+		t.Fatalf("unexpected filtered page: %+v %q (%v)", page, next, err)
+	}
+	page, next, err = store.ListBackupsFilteredPage(core.BackupFilter{IsAuto: &yes}, next, 2)
+	if err != nil || len(page) != 1 || page[0].ID != "b0" || next != "" {
+		t.Fatalf("unexpected last filtered page: %+v %q (%v)", page, next, err)
+	}
+}
+
+func TestStoreTags(t *testing.T) {
+	store, ids := newStoreWithItems(t, 3)
+	item, err := store.SetTag(ids[0], " env ", "prod")
+	if err != nil || item.Tags["env"] != "prod" {
+		t.Fatalf("set tag: %+v (%v)", item, err)
+	}
+	if _, err := store.SetTag(ids[1], "env", ""); err != nil {
+		t.Fatalf("set empty value: %v", err)
+	}
+	if _, err := store.SetTag(ids[0], "  ", "x"); !errors.Is(err, core.ErrInvalidTag) {
+		t.Fatalf("expected ErrInvalidTag, got %v", err)
+	}
+	if _, err := store.SetTag("missing", "env", "x"); !errors.Is(err, core.ErrBackupNotFound) {
+		t.Fatalf("expected ErrBackupNotFound, got %v", err)
+	}
+
+	items, err := store.ListBackupsFiltered(core.BackupFilter{TagKey: "env"})
+	if err != nil || len(items) != 2 {
+		t.Fatalf("expected 2 items with tag env, got %+v (%v)", items, err)
+	}
+	items, err = store.ListBackupsFiltered(core.BackupFilter{TagKey: "env", TagValue: "prod"})
+	if err != nil || len(items) != 1 || items[0].ID != ids[0] {
+		t.Fatalf("expected only %s with env=prod, got %+v (%v)", ids[0], items, err)
+	}
+	// 列表返回的副本被修改时不应影响索引中的标签。
+	items[0].Tags["env"] = "dev"
+	if again, err := store.FindByID(ids[0]); err != nil || again.Tags["env"] != "prod" {
+		t.Fatalf("expected stored tag unchanged, got %+v (%v)", again, err)
+	}
+
+	item, err = store.DeleteTag(ids[0], "env")
+	if err != nil || item.Tags != nil {
+		t.Fatalf("delete tag: %+v (%v)", item, err)
+	}
+	if _, err := store.DeleteTag(ids[0], "env"); !errors.Is(err, core.ErrTagNotFound) {
+		t.Fatalf("expected ErrTagNotFound, got %v", err)
+	}
+}
+
+func TestStoreHashAlgoMismatch(t *testing.T) {
+	dir := t.TempDir()
+	indexPath := filepath.Join(dir, "index.json")
+	target := filepath.Join(dir, "auth.json")
+	open := func(algo string) *core.Store {
+		return core.NewStoreWithOptions(indexPath, target, core.StoreOptions{HashAlgo: algo})
+	}
+
+	// 没有备份的索引直接改用配置的算法。
+	if snap, err := open(core.HashSHA512).Snapshot(); err != nil || snap.HashAlgo != core.HashSHA512 {
+		t.Fatalf("expected empty index to adopt sha512, got %+v (%v)", snap, err)
+	}
+	store := open(core.HashBLAKE2b)
+	item := core.BackupItem{ID: "a", Filename: "a.json", CreatedAt: time.Now(), HashAlgo: core.HashBLAKE2b}
+	idx, err := store.AddBackup(item, "fp")
+	if err != nil || idx.HashAlgo != core.HashBLAKE2b || idx.Items[0].HashAlgo != "" {
+		t.Fatalf("expected blake2b index without per-item override, got %+v (%v)", idx, err)
+	}
+
+	if _, err := open(core.HashSHA256).ListBackups(); !errors.Is(err, core.ErrAlgoMismatch) {
+		t.Fatalf("expected ErrAlgoMismatch when the configured algorithm differs, got %v", err)
+	}
+	// 未配置算法时沿用索引记录的算法。
+	if items, err := core.NewStore(indexPath, target).ListBackups(); err != nil || len(items) != 1 {
+		t.Fatalf("expected index to load without a configured algorithm, got %+v (%v)", items, err)
+	}
+
+	// 条目可以标明另一种受支持的算法，未知算法视为不一致。
+	override := core.BackupItem{ID: "b", Filename: "b.json", CreatedAt: time.Now(), HashAlgo: core.HashSHA256}
+	if _, err := store.AddBackup(override, "fp2"); err != nil {
+		t.Fatalf("add override: %v", err)
+	}
+	if items, err := store.ListBackups(); err != nil || len(items) != 2 {
+		t.Fatalf("expected per-item override to be accepted, got %+v (%v)", items, err)
+	}
+	data, err := os.ReadFile(indexPath)
+	if err != nil {
+		t.Fatalf("read index: %v", err)
+	}
+	bad := strings.Replace(string(data), `"hash_algo": "sha256"`, `"hash_algo": "md5"`, 1)
+	if err := os.WriteFile(indexPath, []byte(bad), 0o600); err != nil {
+		t.Fatalf("write index: %v", err)
+	}
+	if _, err := open(core.HashBLAKE2b).ListBackups(); !errors.Is(err, core.ErrAlgoMismatch) {
+		t.Fatalf("expected ErrAlgoMismatch for an unknown per-item algorithm, got %v", err)
+	}
+}
+
+func TestStoreSnapshotUsesCacheWithinTTL(t *testing.T) {
+	seed, _ := newStoreWithItems(t, 3)
+	idx, err := seed.Snapshot()
+	if err != nil {
+		t.Fatalf("seed snapshot: %v", err)
+	}
+	indexPath := filepath.Join(filepath.Dir(idx.TargetPath), "index.json")
+	store := core.NewStoreWithOptions(indexPath, idx.TargetPath, core.StoreOptions{CacheTTL: 200 * time.Millisecond})
+
+	if idx, err := store.Snapshot(); err != nil || len(idx.Items) != 3 {
+		t.Fatalf("first snapshot: %v %v", idx, err)
+	}
+	// 删除 index.json 后缓存期内的 Snapshot 仍返回原内容，说明只在第一次读取了文件。
+	if err := os.Remove(indexPath); err != nil {
+		t.Fatalf("remove index: %v", err)
+	}
+	for i := 0; i < 20; i++ {
+		idx, err := store.Snapshot()
+		if err != nil || len(idx.Items) != 3 {
+			t.Fatalf("cached snapshot %d: items=%d err=%v", i, len(idx.Items), err)
+		}
+	}
+	time.Sleep(250 * time.Millisecond)
+	if idx, err := store.Snapshot(); err != nil || len(idx.Items) != 0 {
+		t.Fatalf("expected re-read after TTL, got items=%d err=%v", len(idx.Items), err)
+	}
+
+	// 本进程写入会立即使缓存失效。
+	if _, err := store.AddBackup(core.BackupItem{ID: "fresh", Filename: "fresh.json"}, "fp"); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	if idx, err := store.Snapshot(); err != nil || len(idx.Items) != 1 || idx.LatestFingerprint != "fp" {
+		t.Fatalf("expected write to be visible immediately, got %+v %v", idx, err)
+	}
+}
+
+func TestStoreSortedIDsMigrationAndMaintenance(t *testing.T) {
+	// newStoreWithItems 写入的索引没有 sorted_ids，相当于旧版本索引。
+	store, ids := newStoreWithItems(t, 3)
+	items, err := store.ListBackups()
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(items) != 3 || items[0].ID != ids[2] || items[2].ID != ids[0] {
+		t.Fatalf("expected newest first after migration, got %v", items)
+	}
+
+	if _, err := store.AddBackup(core.BackupItem{ID: "newest", Filename: "newest.json", CreatedAt: time.Now()}, "fp"); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	if _, err := store.DeleteBackup(ids[1]); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	idx, err := store.Snapshot()
+	if err != nil {
+		t.Fatalf("snapshot: %v", err)
+	}
+	want := []string{"newest", ids[2], ids[0]}
+	if fmt.Sprint(idx.SortedIDs) != fmt.Sprint(want) {
+		t.Fatalf("sorted ids = %v, want %v", idx.SortedIDs, want)
+	}
+	if err := idx.ValidateSortedIDs(); err != nil {
+		t.Fatalf("invariant broken: %v", err)
+	}
+
+	// 外部把 sorted_ids 改坏后，列表仍按时间倒序输出，下一次写入修复。
+	idx.SortedIDs = []string{ids[0], "newest"}
+	if err := idx.ValidateSortedIDs(); err == nil {
+		t.Fatalf("expected invalid sorted ids to be detected")
+	}
+	indexPath := filepath.Join(filepath.Dir(idx.TargetPath), "index.json")
+	if err := util.AtomicWriteJSONDefault(indexPath, idx); err != nil {
+		t.Fatalf("write index: %v", err)
+	}
+	items, err = store.ListBackups()
+	if err != nil || len(items) != 3 || items[0].ID != "newest" {
+		t.Fatalf("expected fallback ordering, got %v %v", items, err)
+	}
+	if _, err := store.UpdateLatestFingerprint("fp2"); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if idx, err = store.Snapshot(); err != nil || idx.ValidateSortedIDs() != nil {
+		t.Fatalf("expected sorted ids repaired on write: %v %v", idx.SortedIDs, err)
+	}
+}
+
+func TestStoreIntegritySignature(t *testing.T) {
+	seed, ids := newStoreWithItems(t, 2)
+	idx, err := seed.Snapshot()
+	if err != nil {
+		t.Fatalf("seed snapshot: %v", err)
+	}
+	indexPath := filepath.Join(filepath.Dir(idx.TargetPath), "index.json")
+	store := core.NewStoreWithOptions(indexPath, idx.TargetPath, core.StoreOptions{IntegrityKey: []byte("k3y")})
+
+	// 功能启用前的索引没有签名文件，照常加载并在第一次写入时补签。
+	if tampered, err := store.Tampered(); err != nil || tampered {
+		t.Fatalf("unsigned legacy index must be trusted: %v %v", tampered, err)
+	}
+	if _, err := store.UpdateLatestFingerprint("fp"); err != nil {
+		t.Fatalf("first write: %v", err)
+	}
+	if _, err := os.Stat(indexPath + ".sig"); err != nil {
+		t.Fatalf("expected signature after first write: %v", err)
+	}
+
+	// 绕过 Store 修改索引后进入只读状态。
+	data, err := os.ReadFile(indexPath)
+	if err != nil {
+		t.Fatalf("read index: %v", err)
+	}
+	edited := []byte(strings.Replace(string(data), `"fp"`, `"forged"`, 1))
+	if err := os.WriteFile(indexPath, edited, 0o600); err != nil {
+		t.Fatalf("tamper: %v", err)
+	}
+	if tampered, err := store.Tampered(); err != nil || !tampered {
+		t.Fatalf("expected tampering to be detected: %v %v", tampered, err)
+	}
+	if _, err := store.DeleteBackup(ids[0]); !errors.Is(err, core.ErrIndexTampered) {
+		t.Fatalf("expected writes to be refused, got %v", err)
+	}
+
+	if err := store.AcceptIndex(); err != nil {
+		t.Fatalf("accept: %v", err)
+	}
+	if tampered, err := store.Tampered(); err != nil || tampered {
+		t.Fatalf("expected accepted index to be trusted: %v %v", tampered, err)
+	}
+	if _, err := store.DeleteBackup(ids[0]); err != nil {
+		t.Fatalf("write after accept: %v", err)
+	}
+}
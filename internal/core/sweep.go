@@ -0,0 +1,66 @@
+package core
+
+import (
+	"context"
+	"path/filepath"
+	"time"
+
+	"codex-backup-tool/internal/util"
+)
+
+const defaultTempFileGrace = time.Hour
+
+func (s *Service) tempFileGrace() time.Duration {
+	if s.cfg.TempFileGrace > 0 {
+		return s.cfg.TempFileGrace
+	}
+	return defaultTempFileGrace
+}
+
+// sweepDirs 返回工具会进行原子写入的全部目录。
+func (s *Service) sweepDirs() []string {
+	return []string{s.cfg.DataDir, s.cfg.BackupsDir, filepath.Dir(s.cfg.TargetPath)}
+}
+
+// SweepTempFiles 清理进程中断后遗留的过期临时文件，返回本次删除数量。
+func (s *Service) SweepTempFiles() int {
+	grace := s.tempFileGrace()
+	total := 0
+	for _, dir := range s.sweepDirs() {
+		n, err := util.SweepTempFiles(dir, grace)
+		total += n
+		if err != nil {
+			s.logger.Warn("清理临时文件失败", "dir", dir, "error", err)
+		}
+	}
+	if total > 0 {
+		s.tempSwept.Add(int64(total))
+		s.logger.Info("已清理遗留临时文件", "count", total)
+	}
+	return total
+}
+
+// TempFilesSwept 返回自启动以来累计清理的临时文件数量。
+func (s *Service) TempFilesSwept() int64 {
+	return s.tempSwept.Load()
+}
+
+func (s *Service) startTempSweeper(ctx context.Context) {
+	ticker := time.NewTicker(s.tempFileGrace())
+	stopCh := s.stopCh
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-stopCh:
+				return
+			case <-ticker.C:
+				s.SweepTempFiles()
+			}
+		}
+	}()
+}
@@ -0,0 +1,77 @@
+package core
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	// ErrInvalidTag 在标签键为空时返回。
+	ErrInvalidTag = errors.New("invalid tag")
+	// ErrTagNotFound 在要删除的标签不存在时返回。
+	ErrTagNotFound = errors.New("tag not found")
+)
+
+// SetTag 设置备份的标签，键去除首尾空白后不能为空，值可以为空；已存在的键被覆盖。
+func (s *Store) SetTag(id, key, value string) (*BackupItem, error) {
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return nil, ErrInvalidTag
+	}
+	return s.updateItem(id, func(item *BackupItem) error {
+		if item.Tags == nil {
+			item.Tags = make(map[string]string, 1)
+		}
+		if v, ok := item.Tags[key]; ok && v == value {
+			return errNoChange
+		}
+		item.Tags[key] = value
+		return nil
+	})
+}
+
+// DeleteTag 删除备份的标签，标签不存在时返回 ErrTagNotFound。
+func (s *Store) DeleteTag(id, key string) (*BackupItem, error) {
+	key = strings.TrimSpace(key)
+	return s.updateItem(id, func(item *BackupItem) error {
+		if _, ok := item.Tags[key]; !ok {
+			return ErrTagNotFound
+		}
+		delete(item.Tags, key)
+		if len(item.Tags) == 0 {
+			item.Tags = nil
+		}
+		return nil
+	})
+}
+
+// updateItem 在索引中找到 id 对应的备份并交给 mutate 修改，返回修改后的副本；
+// mutate 返回 errNoChange 时不写回索引，仍返回当前内容。
+func (s *Store) updateItem(id string, mutate func(*BackupItem) error) (*BackupItem, error) {
+	var updated *BackupItem
+	_, err := s.update(func(idx *IndexData) error {
+		for i := range idx.Items {
+			if idx.Items[i].ID != id {
+				continue
+			}
+			err := mutate(&idx.Items[i])
+			updated = idx.Items[i].clone()
+			return err
+		}
+		return ErrBackupNotFound
+	})
+	if errors.Is(err, errNoChange) {
+		err = nil
+	}
+	return updated, err
+}
+
+// SetTag 设置备份的标签，见 Store.SetTag。
+func (s *Service) SetTag(id, key, value string) (*BackupItem, error) {
+	return s.store.SetTag(id, key, value)
+}
+
+// DeleteTag 删除备份的标签，见 Store.DeleteTag。
+func (s *Service) DeleteTag(id, key string) (*BackupItem, error) {
+	return s.store.DeleteTag(id, key)
+}
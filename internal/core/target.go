@@ -0,0 +1,174 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"codex-backup-tool/internal/util"
+)
+
+// ErrTargetReadOnly 在目标文件只读且未开启 restore_override_readonly 时由还原返回。
+var ErrTargetReadOnly = errors.New("target file is read-only")
+
+// TargetState 为 EvaluateTarget 对目标文件的检查结果。
+type TargetState struct {
+	Exists      bool
+	Stat        *FileStat
+	Fingerprint string
+	// IsDirectory 表示目标路径实际是目录；Unreadable 表示目标存在但权限不足无法读取。
+	IsDirectory bool
+	Unreadable  bool
+	// Code 非空表示目标当前不可备份，取值为 Reason* 常量。
+	Code   string
+	Reason string
+}
+
+// Backupable 表示目标文件可以继续计算内容哈希并备份。
+func (t *TargetState) Backupable() bool {
+	return t.Exists && t.Code == ""
+}
+
+// EvaluateTarget 检查目标文件是否存在、是否超出大小限制并计算快速指纹。
+// Status、Scan 与手动备份共用该判定，大小检查在读取任何文件内容之前完成。
+// 目标是目录或因权限不足无法读取时不返回错误，而是通过 Code 说明原因。
+func (s *Service) EvaluateTarget() (*TargetState, error) {
+	info, err := os.Stat(util.LongPath(s.cfg.TargetPath))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &TargetState{Code: ReasonTargetMissing, Reason: "目标文件不存在"}, nil
+		}
+		if errors.Is(err, fs.ErrPermission) {
+			return unreadableTarget(nil, err), nil
+		}
+		return nil, fmt.Errorf("stat target: %w", err)
+	}
+	if info.IsDir() {
+		return &TargetState{
+			Exists:      true,
+			IsDirectory: true,
+			Code:        ReasonTargetIsDirectory,
+			Reason:      fmt.Sprintf("目标路径是目录而不是文件，请检查 codex_file 配置: %s", s.cfg.TargetPath),
+		}, nil
+	}
+	stat := &FileStat{Size: info.Size(), ModTime: info.ModTime()}
+	if s.cfg.MaxTargetSize > 0 && info.Size() > s.cfg.MaxTargetSize {
+		return &TargetState{
+			Exists: true,
+			Stat:   stat,
+			Code:   ReasonTargetTooLarge,
+			Reason: fmt.Sprintf("目标文件大小 %d 字节超过上限 %d 字节", info.Size(), s.cfg.MaxTargetSize),
+		}, nil
+	}
+	f, err := os.Open(util.LongPath(s.cfg.TargetPath))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &TargetState{Code: ReasonTargetMissing, Reason: "目标文件不存在"}, nil
+		}
+		if errors.Is(err, fs.ErrPermission) {
+			return unreadableTarget(stat, err), nil
+		}
+		return nil, fmt.Errorf("open target: %w", err)
+	}
+	f.Close()
+	res, err := s.fingerprintTarget()
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &TargetState{Code: ReasonTargetMissing, Reason: "目标文件不存在"}, nil
+		}
+		if errors.Is(err, fs.ErrPermission) {
+			return unreadableTarget(stat, err), nil
+		}
+		return nil, fmt.Errorf("stat target: %w", err)
+	}
+	return &TargetState{Exists: true, Stat: res.Stat, Fingerprint: res.Fingerprint}, nil
+}
+
+// unreadableTarget 构造权限不足时的检查结果，原因中只保留操作系统错误本身，不重复路径。
+func unreadableTarget(stat *FileStat, err error) *TargetState {
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		err = pathErr.Err
+	}
+	return &TargetState{
+		Exists:     true,
+		Stat:       stat,
+		Unreadable: true,
+		Code:       ReasonTargetUnreadable,
+		Reason:     fmt.Sprintf("目标文件无法读取: %v", err),
+	}
+}
+
+// writeTargetFile 以原子方式写入目标文件并保留原有权限位。目标只读时按 RestoreOverrideReadOnly
+// 拒绝写入，或临时取消只读属性、写入后再对新文件恢复。
+func (s *Service) writeTargetFile(data []byte) error {
+	path := s.cfg.TargetPath
+	readOnly, err := util.IsReadOnly(path)
+	if err != nil {
+		return fmt.Errorf("检查目标只读属性: %w", err)
+	}
+	if readOnly {
+		if !s.cfg.RestoreOverrideReadOnly {
+			return ErrTargetReadOnly
+		}
+		if err := util.SetReadOnly(path, false); err != nil {
+			return fmt.Errorf("取消目标只读属性: %w", err)
+		}
+		s.logger.Warn("目标文件为只读，已临时取消只读属性", "path", path)
+	}
+	if err := util.AtomicWriteFile(path, data, util.PreserveMode); err != nil {
+		if readOnly {
+			// 写入失败时原文件仍在，恢复其只读属性。
+			if rerr := util.SetReadOnly(path, true); rerr != nil {
+				s.logger.Error("恢复目标只读属性失败", "path", path, "error", rerr)
+			}
+		}
+		return fmt.Errorf("写入目标文件: %w", err)
+	}
+	if readOnly {
+		if err := util.SetReadOnly(path, true); err != nil {
+			return fmt.Errorf("恢复目标只读属性: %w", err)
+		}
+	}
+	return nil
+}
+
+// fingerprintTarget 按配置的采样大小与修改时间粒度计算目标文件的快速指纹。
+func (s *Service) fingerprintTarget() (*FingerprintResult, error) {
+	return ComputeFingerprintWithOptions(s.cfg.TargetPath, FingerprintOptions{
+		SampleSize:       s.cfg.FingerprintSampleSize,
+		MtimeGranularity: s.mtimeGranularity,
+	})
+}
+
+// resolveMtimeGranularity 返回实际使用的修改时间粒度，配置为 0 时按目标所在文件系统推断。
+func resolveMtimeGranularity(cfg Config) time.Duration {
+	switch {
+	case cfg.MtimeGranularity > 0:
+		return cfg.MtimeGranularity
+	case cfg.MtimeGranularity < 0:
+		return 0
+	default:
+		return util.MtimeGranularity(cfg.TargetPath)
+	}
+}
+
+// checkTargetDir 检查目标文件所在目录是否可访问，返回的提示为空表示正常。
+// 目录缺失只作为警告，被监控的程序可能稍后才创建它。
+func (s *Service) checkTargetDir() string {
+	dir := filepath.Dir(s.cfg.TargetPath)
+	info, err := os.Stat(util.LongPath(dir))
+	switch {
+	case err == nil && !info.IsDir():
+		return fmt.Sprintf("目标文件所在路径不是目录: %s", dir)
+	case err == nil:
+		return ""
+	case os.IsNotExist(err):
+		return fmt.Sprintf("目标文件所在目录不存在: %s", dir)
+	default:
+		return fmt.Sprintf("目标文件所在目录无法访问: %v", err)
+	}
+}
@@ -0,0 +1,177 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"codex-backup-tool/internal/util"
+)
+
+// 目标路径与索引记录不一致时的处理方式。
+const (
+	// TargetChangeMigrate 将索引记录的目标路径改为新路径，沿用原有备份历史。
+	TargetChangeMigrate = "migrate"
+	// TargetChangeIsolate 为新路径使用独立的 index-<hash>.json 与备份子目录，各目标的历史互不混合。
+	TargetChangeIsolate = "isolate"
+)
+
+// targetChangeFile 保存尚未确认的目标路径变更，确认后删除。
+const targetChangeFile = "target_change.json"
+
+// TargetChange 描述启动时检测到的目标路径变更。
+type TargetChange struct {
+	PreviousPath string    `json:"previous_path"`
+	CurrentPath  string    `json:"current_path"`
+	Policy       string    `json:"policy"`
+	IndexPath    string    `json:"index_path"`
+	DetectedAt   time.Time `json:"detected_at"`
+}
+
+// targetChangeNotice 为持久化的变更提示，在 /api/status 中展示直到被确认。
+type targetChangeNotice struct {
+	path   string
+	mu     sync.Mutex
+	change *TargetChange
+}
+
+func loadTargetChangeNotice(path string) (*targetChangeNotice, error) {
+	n := &targetChangeNotice{path: path}
+	data, exists, err := util.ReadFileIfExists(path)
+	if err != nil || !exists {
+		return n, err
+	}
+	var change TargetChange
+	if err := json.Unmarshal(data, &change); err != nil {
+		return n, fmt.Errorf("parse %s: %w", targetChangeFile, err)
+	}
+	n.change = &change
+	return n, nil
+}
+
+func (n *targetChangeNotice) set(change TargetChange) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.change = &change
+	return util.AtomicWriteJSON(n.path, change, util.AtomicWriteJSONOptions{Indent: true})
+}
+
+func (n *targetChangeNotice) get() *TargetChange {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.change == nil {
+		return nil
+	}
+	change := *n.change
+	return &change
+}
+
+// clear 删除提示，返回此前是否存在未确认的变更。
+func (n *targetChangeNotice) clear() (bool, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	had := n.change != nil
+	n.change = nil
+	if err := os.Remove(util.LongPath(n.path)); err != nil && !os.IsNotExist(err) {
+		return had, fmt.Errorf("remove %s: %w", targetChangeFile, err)
+	}
+	return had, nil
+}
+
+// samePath 判断两个目标路径是否指向同一位置，Windows 上忽略大小写。
+func samePath(a, b string) bool {
+	a, b = filepath.Clean(a), filepath.Clean(b)
+	if runtime.GOOS == "windows" {
+		return strings.EqualFold(a, b)
+	}
+	return a == b
+}
+
+// targetKey 返回目标路径的短哈希，用于 isolate 模式下的索引文件名与备份子目录名。
+func targetKey(target string) string {
+	key := filepath.Clean(target)
+	if runtime.GOOS == "windows" {
+		key = strings.ToLower(key)
+	}
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:6])
+}
+
+// indexTargetPath 只读取索引中记录的 target_path，索引不存在或无法解析时返回空字符串。
+func indexTargetPath(indexPath string) string {
+	data, exists, err := util.ReadFileIfExists(indexPath)
+	if err != nil || !exists {
+		return ""
+	}
+	var head struct {
+		TargetPath string `json:"target_path"`
+	}
+	if json.Unmarshal(data, &head) != nil {
+		return ""
+	}
+	return head.TargetPath
+}
+
+// isolateTarget 在 isolate 模式下为与 index.json 记录不同的目标改用独立的索引与备份目录。
+// 返回值非 nil 表示首次为该目标建立独立历史，需要提示用户。
+func isolateTarget(cfg *Config) *TargetChange {
+	stored := indexTargetPath(cfg.IndexPath)
+	if stored == "" || samePath(stored, cfg.TargetPath) {
+		return nil
+	}
+	key := targetKey(cfg.TargetPath)
+	cfg.IndexPath = filepath.Join(filepath.Dir(cfg.IndexPath), "index-"+key+".json")
+	cfg.BackupsDir = filepath.Join(cfg.BackupsDir, "target-"+key)
+	if _, err := os.Stat(util.LongPath(cfg.IndexPath)); err == nil {
+		return nil
+	}
+	return &TargetChange{PreviousPath: stored, CurrentPath: cfg.TargetPath, Policy: TargetChangeIsolate, IndexPath: cfg.IndexPath}
+}
+
+// migrateTarget 在 migrate 模式下把索引记录的目标路径改为当前配置并清空最新指纹，
+// 避免旧文件的指纹抑制新目标的第一次备份。
+func (s *Service) migrateTarget() (*TargetChange, error) {
+	idx, err := s.store.Snapshot()
+	if err != nil {
+		return nil, err
+	}
+	if idx.TargetPath == "" || samePath(idx.TargetPath, s.cfg.TargetPath) {
+		return nil, nil
+	}
+	previous, err := s.store.MigrateTargetPath(s.cfg.TargetPath)
+	if err != nil {
+		return nil, err
+	}
+	return &TargetChange{PreviousPath: previous, CurrentPath: s.cfg.TargetPath, Policy: TargetChangeMigrate, IndexPath: s.cfg.IndexPath}, nil
+}
+
+// recordTargetChange 输出警告并持久化变更提示。
+func (s *Service) recordTargetChange(change *TargetChange) {
+	change.DetectedAt = time.Now().UTC()
+	switch change.Policy {
+	case TargetChangeIsolate:
+		s.logger.Warn("目标路径已变化，已为新目标使用独立的索引", "previous_path", change.PreviousPath, "current_path", change.CurrentPath, "index_path", change.IndexPath)
+	default:
+		s.logger.Warn("目标路径已变化，已将索引迁移到新路径并重置最新指纹", "previous_path", change.PreviousPath, "current_path", change.CurrentPath)
+	}
+	if err := s.targetChange.set(*change); err != nil {
+		s.logger.Error("保存目标路径变更提示失败", "error", err)
+	}
+}
+
+// TargetChange 返回尚未确认的目标路径变更，没有时返回 nil。
+func (s *Service) TargetChange() *TargetChange {
+	return s.targetChange.get()
+}
+
+// AcknowledgeTargetChange 确认目标路径变更，之后 /api/status 不再提示。返回此前是否存在未确认的变更。
+func (s *Service) AcknowledgeTargetChange() (bool, error) {
+	return s.targetChange.clear()
+}
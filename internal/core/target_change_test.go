@@ -0,0 +1,140 @@
+package core_test
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"codex-backup-tool/internal/core"
+)
+
+// openTargetService 在共享的数据目录上以指定目标文件创建服务，模拟修改 codex_dir 后重启。
+func openTargetService(t *testing.T, base, target, policy string) *core.Service {
+	t.Helper()
+	dataDir := filepath.Join(base, "data")
+	svc, err := core.NewService(core.Config{
+		TargetPath:         filepath.Join(base, target, "auth.json"),
+		DataDir:            dataDir,
+		BackupsDir:         filepath.Join(dataDir, "backups"),
+		IndexPath:          filepath.Join(dataDir, "index.json"),
+		ScanInterval:       time.Minute,
+		Port:               "0",
+		TargetChangePolicy: policy,
+	}, core.NewJSONLogger(io.Discard, slog.LevelInfo))
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	return svc
+}
+
+func TestTargetChangeMigratesIndex(t *testing.T) {
+	base := t.TempDir()
+	svc := openTargetService(t, base, "alice", core.TargetChangeMigrate)
+	writeTarget(t, svc, `{"token":"alice"}`)
+	if res, err := svc.Scan(false, nil); err != nil || !res.Created {
+		t.Fatalf("scan: %+v %v", res, err)
+	}
+	svc.Stop()
+
+	svc = openTargetService(t, base, "bob", core.TargetChangeMigrate)
+	defer func() { svc.Stop() }()
+	change := svc.TargetChange()
+	if change == nil || change.Policy != core.TargetChangeMigrate ||
+		change.PreviousPath != filepath.Join(base, "alice", "auth.json") || change.CurrentPath != svc.Config().TargetPath {
+		t.Fatalf("unexpected target change: %+v", change)
+	}
+	status, err := svc.Status()
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	if status.TargetChange == nil || status.LatestFingerprint != "" {
+		t.Fatalf("expected change notice and reset fingerprint, got %+v", status)
+	}
+	writeTarget(t, svc, `{"token":"bob"}`)
+	if res, err := svc.Scan(false, nil); err != nil || !res.Created {
+		t.Fatalf("first scan of new target: %+v %v", res, err)
+	}
+	items, err := svc.ListBackups()
+	if err != nil || len(items) != 2 {
+		t.Fatalf("expected migrated index to keep history, got %d items (%v)", len(items), err)
+	}
+
+	if ok, err := svc.AcknowledgeTargetChange(); err != nil || !ok {
+		t.Fatalf("acknowledge: %t %v", ok, err)
+	}
+	if svc.TargetChange() != nil {
+		t.Fatalf("expected notice to clear after acknowledge")
+	}
+	svc.Stop()
+	svc = openTargetService(t, base, "bob", core.TargetChangeMigrate)
+	if change := svc.TargetChange(); change != nil {
+		t.Fatalf("expected no notice after restart with the same target, got %+v", change)
+	}
+}
+
+func TestTargetChangeNoticeSurvivesRestart(t *testing.T) {
+	base := t.TempDir()
+	svc := openTargetService(t, base, "alice", core.TargetChangeMigrate)
+	writeTarget(t, svc, `{"token":"alice"}`)
+	if _, err := svc.Scan(false, nil); err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	svc.Stop()
+	openTargetService(t, base, "bob", core.TargetChangeMigrate).Stop()
+
+	svc = openTargetService(t, base, "bob", core.TargetChangeMigrate)
+	defer svc.Stop()
+	if change := svc.TargetChange(); change == nil || change.CurrentPath != svc.Config().TargetPath {
+		t.Fatalf("expected unacknowledged notice to persist, got %+v", change)
+	}
+}
+
+func TestTargetChangeIsolatesHistory(t *testing.T) {
+	base := t.TempDir()
+	svc := openTargetService(t, base, "alice", core.TargetChangeIsolate)
+	writeTarget(t, svc, `{"token":"alice"}`)
+	if res, err := svc.Scan(false, nil); err != nil || !res.Created {
+		t.Fatalf("scan: %+v %v", res, err)
+	}
+	svc.Stop()
+
+	svc = openTargetService(t, base, "bob", core.TargetChangeIsolate)
+	change := svc.TargetChange()
+	if change == nil || change.Policy != core.TargetChangeIsolate || change.IndexPath != svc.Config().IndexPath {
+		t.Fatalf("unexpected target change: %+v", change)
+	}
+	if filepath.Base(svc.Config().IndexPath) == "index.json" {
+		t.Fatalf("expected a per-target index, got %s", svc.Config().IndexPath)
+	}
+	if items, err := svc.ListBackups(); err != nil || len(items) != 0 {
+		t.Fatalf("expected empty isolated history, got %d items (%v)", len(items), err)
+	}
+	writeTarget(t, svc, `{"token":"bob"}`)
+	if res, err := svc.Scan(false, nil); err != nil || !res.Created {
+		t.Fatalf("scan: %+v %v", res, err)
+	}
+	if _, err := svc.AcknowledgeTargetChange(); err != nil {
+		t.Fatalf("acknowledge: %v", err)
+	}
+	svc.Stop()
+
+	// 切回原目标时继续使用 index.json，两个目标的历史互不混合。
+	svc = openTargetService(t, base, "alice", core.TargetChangeIsolate)
+	defer svc.Stop()
+	if change := svc.TargetChange(); change != nil {
+		t.Fatalf("expected no notice for the original target, got %+v", change)
+	}
+	items, err := svc.ListBackups()
+	if err != nil || len(items) != 1 || items[0].SourcePath != svc.Config().TargetPath {
+		t.Fatalf("expected only the original history, got %+v (%v)", items, err)
+	}
+	if report, err := svc.CheckConsistency(); err != nil || len(report.Issues) != 0 {
+		t.Fatalf("expected no orphan files from the isolated target, got %+v (%v)", report, err)
+	}
+	if _, err := os.Stat(filepath.Join(base, "data", "target_change.json")); !os.IsNotExist(err) {
+		t.Fatalf("expected notice file to be removed, got %v", err)
+	}
+}
@@ -0,0 +1,154 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"codex-backup-tool/internal/util"
+)
+
+// targetHistoryCap 为保留的目标文件大小样本数，按默认 60 秒扫描间隔约覆盖一周。
+const targetHistoryCap = 10000
+
+// SizeSample 为一次扫描时目标文件的大小，Changed 表示本次扫描发现文件内容被修改。
+type SizeSample struct {
+	Time    time.Time `json:"ts"`
+	Size    int64     `json:"size"`
+	Changed bool      `json:"changed"`
+}
+
+// sizeHistory 以每行一个 [unix秒, 大小, 0/1] 的紧凑格式追加保存样本，
+// 行数超过容量两倍时重写文件只保留最近 targetHistoryCap 条。
+type sizeHistory struct {
+	path string
+
+	mu      sync.Mutex
+	loaded  bool
+	samples []SizeSample
+	lines   int
+}
+
+func newSizeHistory(path string) *sizeHistory {
+	return &sizeHistory{path: path}
+}
+
+func (h *sizeHistory) add(sample SizeSample) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if err := h.loadLocked(); err != nil {
+		return err
+	}
+	h.samples = append(h.samples, sample)
+	if len(h.samples) > targetHistoryCap {
+		h.samples = append([]SizeSample(nil), h.samples[len(h.samples)-targetHistoryCap:]...)
+	}
+	if h.lines+1 > 2*targetHistoryCap {
+		return h.rewriteLocked()
+	}
+	f, err := os.OpenFile(util.LongPath(h.path), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("open target history: %w", err)
+	}
+	_, err = f.Write(encodeSizeSample(sample))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("write target history: %w", err)
+	}
+	h.lines++
+	return nil
+}
+
+// since 返回不早于 t 的样本，按时间从旧到新排列。
+func (h *sizeHistory) since(t time.Time) ([]SizeSample, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if err := h.loadLocked(); err != nil {
+		return nil, err
+	}
+	out := make([]SizeSample, 0)
+	for _, sample := range h.samples {
+		if !sample.Time.Before(t) {
+			out = append(out, sample)
+		}
+	}
+	return out, nil
+}
+
+// reset 丢弃内存中的样本，下次访问时重新读取文件。
+func (h *sizeHistory) reset() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.loaded = false
+	h.samples = nil
+	h.lines = 0
+}
+
+func (h *sizeHistory) loadLocked() error {
+	if h.loaded {
+		return nil
+	}
+	data, exists, err := util.ReadFileIfExists(h.path)
+	if err != nil {
+		return fmt.Errorf("read target history: %w", err)
+	}
+	h.samples, h.lines = nil, 0
+	if exists {
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		for scanner.Scan() {
+			h.lines++
+			var raw [3]int64
+			// 崩溃时可能留下半行，跳过无法解析的行。
+			if json.Unmarshal(scanner.Bytes(), &raw) != nil {
+				continue
+			}
+			h.samples = append(h.samples, SizeSample{Time: time.Unix(raw[0], 0).UTC(), Size: raw[1], Changed: raw[2] != 0})
+		}
+		if len(h.samples) > targetHistoryCap {
+			h.samples = h.samples[len(h.samples)-targetHistoryCap:]
+		}
+	}
+	h.loaded = true
+	return nil
+}
+
+func (h *sizeHistory) rewriteLocked() error {
+	var buf bytes.Buffer
+	for _, sample := range h.samples {
+		buf.Write(encodeSizeSample(sample))
+	}
+	if err := util.AtomicWriteFile(h.path, buf.Bytes(), 0o600); err != nil {
+		return fmt.Errorf("prune target history: %w", err)
+	}
+	h.lines = len(h.samples)
+	return nil
+}
+
+func encodeSizeSample(sample SizeSample) []byte {
+	changed := 0
+	if sample.Changed {
+		changed = 1
+	}
+	return []byte(fmt.Sprintf("[%d,%d,%d]\n", sample.Time.Unix(), sample.Size, changed))
+}
+
+// recordTargetSample 记录扫描时已取得的目标文件大小，不额外读取文件；目标不存在时不记录。
+func (s *Service) recordTargetSample(target *TargetState, changed bool) {
+	if target == nil || target.Stat == nil {
+		return
+	}
+	if err := s.sizeHistory.add(SizeSample{Time: time.Now().UTC(), Size: target.Stat.Size, Changed: changed}); err != nil {
+		s.logger.Warn("记录目标文件大小失败", "error", err)
+	}
+}
+
+// TargetHistory 返回最近 window 内的目标文件大小样本，按时间从旧到新排列。
+func (s *Service) TargetHistory(window time.Duration) ([]SizeSample, error) {
+	return s.sizeHistory.since(time.Now().Add(-window))
+}
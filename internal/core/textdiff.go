@@ -0,0 +1,150 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"codex-backup-tool/internal/util"
+)
+
+// maxTextDiffCells 限制逐行比较的规模（两侧行数之积），超出时整段输出为删除加新增。
+const maxTextDiffCells = 4 << 20
+
+// diffLine 为逐行比较结果中的一行，op 为 ' '、'-' 或 '+'。
+type diffLine struct {
+	op   byte
+	text string
+}
+
+// splitLines 按换行拆分文本，忽略末尾换行。
+func splitLines(data []byte) []string {
+	data = bytes.TrimSuffix(data, []byte("\n"))
+	if len(data) == 0 {
+		return nil
+	}
+	return strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+}
+
+// unifiedDiff 返回 a、b 两组行的 unified diff，相同返回空字符串。每行都经过 util.Redact 脱敏。
+func unifiedDiff(aName, bName string, a, b []string, context int) string {
+	lines := diffLines(a, b)
+	var sb strings.Builder
+	for start := 0; start < len(lines); {
+		// 找到下一处变化，向前带上 context 行。
+		first := start
+		for first < len(lines) && lines[first].op == ' ' {
+			first++
+		}
+		if first == len(lines) {
+			break
+		}
+		hunkStart := max(first-context, start)
+		// 相邻变化间的相同行不超过 2*context 时合并为同一段。
+		end, same := first, 0
+		for i := first; i < len(lines); i++ {
+			if lines[i].op == ' ' {
+				same++
+				if same > 2*context {
+					break
+				}
+				continue
+			}
+			same = 0
+			end = i + 1
+		}
+		hunkEnd := min(end+context, len(lines))
+		if sb.Len() == 0 {
+			fmt.Fprintf(&sb, "--- %s\n+++ %s\n", aName, bName)
+		}
+		writeHunk(&sb, lines, hunkStart, hunkEnd)
+		start = hunkEnd
+	}
+	return sb.String()
+}
+
+func writeHunk(sb *strings.Builder, lines []diffLine, from, to int) {
+	aLine, bLine := 1, 1
+	for _, l := range lines[:from] {
+		if l.op != '+' {
+			aLine++
+		}
+		if l.op != '-' {
+			bLine++
+		}
+	}
+	aCount, bCount := 0, 0
+	for _, l := range lines[from:to] {
+		if l.op != '+' {
+			aCount++
+		}
+		if l.op != '-' {
+			bCount++
+		}
+	}
+	// 按 unified diff 约定，长度为 0 的一侧起始行号指向其前一行。
+	if aCount == 0 {
+		aLine--
+	}
+	if bCount == 0 {
+		bLine--
+	}
+	fmt.Fprintf(sb, "@@ -%d,%d +%d,%d @@\n", aLine, aCount, bLine, bCount)
+	for _, l := range lines[from:to] {
+		sb.WriteByte(l.op)
+		sb.WriteString(util.Redact(l.text))
+		sb.WriteByte('\n')
+	}
+}
+
+// diffLines 基于最长公共子序列逐行比较，规模超过 maxTextDiffCells 时退化为整段替换。
+func diffLines(a, b []string) []diffLine {
+	n, m := len(a), len(b)
+	if n*m > maxTextDiffCells {
+		out := make([]diffLine, 0, n+m)
+		for _, l := range a {
+			out = append(out, diffLine{'-', l})
+		}
+		for _, l := range b {
+			out = append(out, diffLine{'+', l})
+		}
+		return out
+	}
+	// lcs[i][j] 为 a[i:] 与 b[j:] 的最长公共子序列长度。
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	out := make([]diffLine, 0, n+m)
+	i, j := 0, 0
+	for i < n && j < m {
+		switch {
+		case a[i] == b[j]:
+			out = append(out, diffLine{' ', a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			out = append(out, diffLine{'-', a[i]})
+			i++
+		default:
+			out = append(out, diffLine{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < n; i++ {
+		out = append(out, diffLine{'-', a[i]})
+	}
+	for ; j < m; j++ {
+		out = append(out, diffLine{'+', b[j]})
+	}
+	return out
+}
@@ -0,0 +1,45 @@
+package core
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+// TLSEnabled 判断是否配置了服务端证书。
+func (c Config) TLSEnabled() bool {
+	return c.TLSCertFile != ""
+}
+
+// ServerTLSConfig 构造 HTTPS 监听使用的 tls.Config。配置了 TLSClientCAFile 时要求客户端
+// 出示由该 CA 签发的证书，握手阶段即拒绝无证书或证书无效的连接。未启用 TLS 时返回 nil。
+func (c Config) ServerTLSConfig() (*tls.Config, error) {
+	if !c.TLSEnabled() {
+		return nil, nil
+	}
+	tlsCfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if c.TLSClientCAFile == "" {
+		return tlsCfg, nil
+	}
+	pem, err := os.ReadFile(c.TLSClientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("读取 tls.client_ca_file: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("tls.client_ca_file 中没有有效的 PEM 证书: %s", c.TLSClientCAFile)
+	}
+	tlsCfg.ClientCAs = pool
+	tlsCfg.ClientAuth = tls.RequireAndVerifyClientCert
+	return tlsCfg, nil
+}
+
+// ClientCertCN 返回请求所用客户端证书的 CommonName，未使用客户端证书时返回空字符串。
+func ClientCertCN(r *http.Request) string {
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return ""
+	}
+	return r.TLS.PeerCertificates[0].Subject.CommonName
+}
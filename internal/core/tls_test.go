@@ -0,0 +1,90 @@
+package core_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"codex-backup-tool/internal/core"
+)
+
+func writeTestCA(t *testing.T) string {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create certificate: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("write ca: %v", err)
+	}
+	return path
+}
+
+func TestLoadConfigTLSValidation(t *testing.T) {
+	cases := []string{
+		`{"data_dir":"./data","tls":{"cert_file":"server.pem"}}`,
+		`{"data_dir":"./data","tls":{"client_ca_file":"ca.pem"}}`,
+		`{"data_dir":"./data","health_addr":"0.0.0.0:9090"}`,
+		`{"data_dir":"./data","health_addr":"127.0.0.1"}`,
+	}
+	for _, body := range cases {
+		if _, _, err := core.LoadConfig(writeConfig(t, body)); err == nil {
+			t.Fatalf("expected validation error for %s", body)
+		}
+	}
+	cfg, _, err := core.LoadConfig(writeConfig(t, `{"data_dir":"./data","tls":{"cert_file":"server.pem","key_file":"server.key"},"health_addr":"127.0.0.1:9090"}`))
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	if !cfg.TLSEnabled() || cfg.HealthAddr != "127.0.0.1:9090" {
+		t.Fatalf("unexpected config: tls=%t health=%q", cfg.TLSEnabled(), cfg.HealthAddr)
+	}
+}
+
+func TestServerTLSConfigRequiresClientCert(t *testing.T) {
+	cfg := core.Config{}
+	if tlsCfg, err := cfg.ServerTLSConfig(); err != nil || tlsCfg != nil {
+		t.Fatalf("expected nil config without TLS, got %v %v", tlsCfg, err)
+	}
+
+	cfg = core.Config{TLSCertFile: "server.pem", TLSKeyFile: "server.key", TLSClientCAFile: writeTestCA(t)}
+	tlsCfg, err := cfg.ServerTLSConfig()
+	if err != nil {
+		t.Fatalf("server tls config: %v", err)
+	}
+	if tlsCfg.ClientAuth != tls.RequireAndVerifyClientCert || tlsCfg.ClientCAs == nil {
+		t.Fatalf("expected client certificate verification, got %v", tlsCfg.ClientAuth)
+	}
+
+	bad := filepath.Join(t.TempDir(), "bad.pem")
+	if err := os.WriteFile(bad, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatalf("write bad ca: %v", err)
+	}
+	cfg.TLSClientCAFile = bad
+	if _, err := cfg.ServerTLSConfig(); err == nil {
+		t.Fatalf("expected error for invalid CA file")
+	}
+}
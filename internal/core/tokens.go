@@ -0,0 +1,275 @@
+package core
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"codex-backup-tool/internal/util"
+)
+
+var (
+	// ErrTokenExists 在令牌名称重复时返回。
+	ErrTokenExists = errors.New("token already exists")
+	// ErrTokenNotFound 在指定令牌不存在时返回。
+	ErrTokenNotFound = errors.New("token not found")
+	// ErrInvalidTokenName 在令牌名称不合法时返回。
+	ErrInvalidTokenName = errors.New("invalid token name")
+	// ErrLastToken 在试图删除最后一个令牌时返回，删除后 API 会重新对所有人开放。
+	ErrLastToken = errors.New("cannot delete the last token")
+)
+
+// tokenSecretPrefix 便于在日志或配置中识别本工具签发的令牌。
+const tokenSecretPrefix = "cbt_"
+
+// touchInterval 为 last_used_at 落盘的最小间隔，避免每个请求都重写 tokens.json。
+const touchInterval = time.Minute
+
+var tokenNamePattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// APIToken 对应 tokens.json 中的一条令牌记录，只保存密钥的哈希。
+type APIToken struct {
+	Name       string     `json:"name"`
+	SecretHash string     `json:"secret_hash"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+}
+
+// TokenInfo 为对外展示的令牌元数据，不包含任何密钥信息。
+type TokenInfo struct {
+	Name       string     `json:"name"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	Expired    bool       `json:"expired"`
+}
+
+type tokenFile struct {
+	Tokens []APIToken `json:"tokens"`
+}
+
+// TokenStore 管理 tokens.json 的读写，与 Store 一样每次从磁盘读取以感知其他进程的修改。
+type TokenStore struct {
+	path     string
+	lockPath string
+	lock     util.LockOptions
+	mu       sync.Mutex
+
+	touchMu sync.Mutex
+	touched map[string]time.Time
+	touchWG sync.WaitGroup
+}
+
+// NewTokenStore 创建 TokenStore 实例。
+func NewTokenStore(path string, lock util.LockOptions) *TokenStore {
+	return &TokenStore{
+		path:     path,
+		lockPath: path + ".lock",
+		lock:     lock,
+		touched:  make(map[string]time.Time),
+	}
+}
+
+// Create 签发新令牌，返回的明文密钥只出现这一次。
+func (t *TokenStore) Create(name string, expiresAt *time.Time) (string, *TokenInfo, error) {
+	if !tokenNamePattern.MatchString(name) {
+		return "", nil, ErrInvalidTokenName
+	}
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", nil, fmt.Errorf("generate token: %w", err)
+	}
+	secret := tokenSecretPrefix + base64.RawURLEncoding.EncodeToString(raw)
+	record := APIToken{
+		Name:       name,
+		SecretHash: hashTokenSecret(secret),
+		CreatedAt:  time.Now().UTC(),
+		ExpiresAt:  expiresAt,
+	}
+	err := t.update(func(f *tokenFile) error {
+		for _, tok := range f.Tokens {
+			if tok.Name == name {
+				return ErrTokenExists
+			}
+		}
+		f.Tokens = append(f.Tokens, record)
+		return nil
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	info := record.info(time.Now())
+	return secret, &info, nil
+}
+
+// List 返回全部令牌的元数据，按创建时间排序。
+func (t *TokenStore) List() ([]TokenInfo, error) {
+	f, err := t.snapshot()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	infos := make([]TokenInfo, 0, len(f.Tokens))
+	for _, tok := range f.Tokens {
+		infos = append(infos, tok.info(now))
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].CreatedAt.Before(infos[j].CreatedAt)
+	})
+	return infos, nil
+}
+
+// Delete 吊销指定名称的令牌，最后一个令牌不允许删除。
+func (t *TokenStore) Delete(name string) error {
+	return t.update(func(f *tokenFile) error {
+		kept := f.Tokens[:0]
+		found := false
+		for _, tok := range f.Tokens {
+			if tok.Name == name {
+				found = true
+				continue
+			}
+			kept = append(kept, tok)
+		}
+		if !found {
+			return ErrTokenNotFound
+		}
+		if len(kept) == 0 {
+			return ErrLastToken
+		}
+		f.Tokens = kept
+		return nil
+	})
+}
+
+// Configured 判断是否存在任何令牌记录（包括已过期的）。
+func (t *TokenStore) Configured() (bool, error) {
+	f, err := t.snapshot()
+	if err != nil {
+		return false, err
+	}
+	return len(f.Tokens) > 0, nil
+}
+
+// Authenticate 校验明文密钥，返回匹配且未过期的令牌名称。
+func (t *TokenStore) Authenticate(secret string) (string, bool, error) {
+	f, err := t.snapshot()
+	if err != nil {
+		return "", false, err
+	}
+	hash := []byte(hashTokenSecret(secret))
+	now := time.Now()
+	matched := ""
+	for _, tok := range f.Tokens {
+		if subtle.ConstantTimeCompare(hash, []byte(tok.SecretHash)) == 1 && !tok.expired(now) {
+			matched = tok.Name
+		}
+	}
+	return matched, matched != "", nil
+}
+
+// Touch 记录令牌最近一次使用时间，同一令牌在 touchInterval 内只落盘一次。
+func (t *TokenStore) Touch(name string, at time.Time) error {
+	t.touchMu.Lock()
+	if last, ok := t.touched[name]; ok && at.Sub(last) < touchInterval {
+		t.touchMu.Unlock()
+		return nil
+	}
+	t.touched[name] = at
+	t.touchMu.Unlock()
+	err := t.update(func(f *tokenFile) error {
+		for i := range f.Tokens {
+			if f.Tokens[i].Name == name {
+				used := at.UTC()
+				f.Tokens[i].LastUsedAt = &used
+				return nil
+			}
+		}
+		return ErrTokenNotFound
+	})
+	if errors.Is(err, ErrTokenNotFound) {
+		return nil
+	}
+	return err
+}
+
+// TouchAsync 在后台执行 Touch，避免认证路径等待磁盘写入。
+func (t *TokenStore) TouchAsync(name string, at time.Time) {
+	t.touchWG.Add(1)
+	go func() {
+		defer t.touchWG.Done()
+		_ = t.Touch(name, at)
+	}()
+}
+
+// Wait 等待所有后台 Touch 完成。
+func (t *TokenStore) Wait() {
+	t.touchWG.Wait()
+}
+
+func (t *TokenStore) snapshot() (*tokenFile, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.loadUnlocked()
+}
+
+func (t *TokenStore) update(mutator func(*tokenFile) error) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return util.WithFileLockOptions(t.lockPath, t.lock, func() error {
+		f, err := t.loadUnlocked()
+		if err != nil {
+			return err
+		}
+		if err := mutator(f); err != nil {
+			return err
+		}
+		return util.AtomicWriteJSONDefault(t.path, f)
+	})
+}
+
+func (t *TokenStore) loadUnlocked() (*tokenFile, error) {
+	data, exists, err := util.ReadFileIfExists(t.path)
+	if err != nil {
+		return nil, fmt.Errorf("read tokens: %w", err)
+	}
+	var f tokenFile
+	if exists {
+		if err := json.Unmarshal(data, &f); err != nil {
+			return nil, fmt.Errorf("unmarshal tokens: %w", err)
+		}
+	}
+	if f.Tokens == nil {
+		f.Tokens = make([]APIToken, 0)
+	}
+	return &f, nil
+}
+
+func (tok *APIToken) expired(now time.Time) bool {
+	return tok.ExpiresAt != nil && !now.Before(*tok.ExpiresAt)
+}
+
+func (tok *APIToken) info(now time.Time) TokenInfo {
+	return TokenInfo{
+		Name:       tok.Name,
+		CreatedAt:  tok.CreatedAt,
+		LastUsedAt: tok.LastUsedAt,
+		ExpiresAt:  tok.ExpiresAt,
+		Expired:    tok.expired(now),
+	}
+}
+
+func hashTokenSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
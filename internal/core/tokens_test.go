@@ -0,0 +1,70 @@
+package core_test
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"codex-backup-tool/internal/core"
+	"codex-backup-tool/internal/util"
+)
+
+func TestTokenStoreLifecycle(t *testing.T) {
+	store := core.NewTokenStore(filepath.Join(t.TempDir(), "tokens.json"), util.LockOptions{})
+
+	if configured, err := store.Configured(); err != nil || configured {
+		t.Fatalf("expected empty store, configured=%v err=%v", configured, err)
+	}
+	secret, info, err := store.Create("ci", nil)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if info.Name != "ci" || secret == "" {
+		t.Fatalf("unexpected create result %q %+v", secret, info)
+	}
+	if _, _, err := store.Create("ci", nil); !errors.Is(err, core.ErrTokenExists) {
+		t.Fatalf("expected ErrTokenExists, got %v", err)
+	}
+	if _, _, err := store.Create("bad name", nil); !errors.Is(err, core.ErrInvalidTokenName) {
+		t.Fatalf("expected ErrInvalidTokenName, got %v", err)
+	}
+
+	name, ok, err := store.Authenticate(secret)
+	if err != nil || !ok || name != "ci" {
+		t.Fatalf("authenticate: name=%q ok=%v err=%v", name, ok, err)
+	}
+	if _, ok, _ := store.Authenticate(secret + "x"); ok {
+		t.Fatalf("tampered secret must not authenticate")
+	}
+
+	past := time.Now().Add(-time.Minute)
+	expired, _, err := store.Create("old", &past)
+	if err != nil {
+		t.Fatalf("create expired: %v", err)
+	}
+	if _, ok, _ := store.Authenticate(expired); ok {
+		t.Fatalf("expired token must not authenticate")
+	}
+
+	if err := store.Touch("ci", time.Now()); err != nil {
+		t.Fatalf("touch: %v", err)
+	}
+	infos, err := store.List()
+	if err != nil || len(infos) != 2 {
+		t.Fatalf("list: %+v %v", infos, err)
+	}
+	if infos[0].LastUsedAt == nil || !infos[1].Expired {
+		t.Fatalf("unexpected metadata %+v", infos)
+	}
+
+	if err := store.Delete("old"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if err := store.Delete("ci"); !errors.Is(err, core.ErrLastToken) {
+		t.Fatalf("expected ErrLastToken, got %v", err)
+	}
+	if err := store.Delete("missing"); !errors.Is(err, core.ErrTokenNotFound) {
+		t.Fatalf("expected ErrTokenNotFound, got %v", err)
+	}
+}
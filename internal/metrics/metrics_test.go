@@ -0,0 +1,111 @@
+package metrics_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"codex-backup-tool/internal/metrics"
+)
+
+func TestRegistryTextFormat(t *testing.T) {
+	reg := metrics.NewRegistry()
+	c := reg.NewCounter("things_total", "Things.\nSecond line.")
+	h := reg.NewHistogram("wait_seconds", "Waits.", []float64{0.1, 1})
+	reg.NewGaugeFunc("queue_depth", "Depth.", func() float64 { return 7 })
+	c.Inc()
+	c.Inc()
+	for _, v := range []float64{0.05, 0.5, 5} {
+		h.Observe(v)
+	}
+
+	srv := httptest.NewServer(reg.Handler())
+	defer srv.Close()
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Fatalf("unexpected response: %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	want := `# HELP queue_depth Depth.
+# TYPE queue_depth gauge
+queue_depth 7
+# HELP things_total Things.\nSecond line.
+# TYPE things_total counter
+things_total 2
+# HELP wait_seconds Waits.
+# TYPE wait_seconds histogram
+wait_seconds_bucket{le="0.1"} 1
+wait_seconds_bucket{le="1"} 2
+wait_seconds_bucket{le="+Inf"} 3
+wait_seconds_sum 5.55
+wait_seconds_count 3
+`
+	if string(body) != want {
+		t.Fatalf("unexpected output:\n%s", body)
+	}
+
+	post, err := http.Post(srv.URL, "text/plain", strings.NewReader(""))
+	if err != nil {
+		t.Fatalf("post: %v", err)
+	}
+	post.Body.Close()
+	if post.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405 for POST, got %d", post.StatusCode)
+	}
+}
+
+func TestNilMetricsAreNoOps(t *testing.T) {
+	var c *metrics.Counter
+	var h *metrics.Histogram
+	c.Inc()
+	h.Observe(1)
+}
+
+func TestRegistryConstLabels(t *testing.T) {
+	reg := metrics.NewRegistryWithLabels(map[string]string{"target": "C:\\a \"b\"\nc", "instance": "x"})
+	reg.NewCounter("things_total", "Things.").Inc()
+	reg.NewHistogram("wait_seconds", "Waits.", []float64{1}).Observe(0.5)
+
+	var buf strings.Builder
+	if _, err := reg.WriteTo(&buf); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	labels := `instance="x",target="C:\\a \"b\"\nc"`
+	want := `# HELP things_total Things.
+# TYPE things_total counter
+things_total{` + labels + `} 1
+# HELP wait_seconds Waits.
+# TYPE wait_seconds histogram
+wait_seconds_bucket{` + labels + `,le="1"} 1
+wait_seconds_bucket{` + labels + `,le="+Inf"} 1
+wait_seconds_sum{` + labels + `} 0.5
+wait_seconds_count{` + labels + `} 1
+`
+	if buf.String() != want {
+		t.Fatalf("unexpected output:\n%s", buf.String())
+	}
+}
+
+func TestRegistryRejectsInvalidMetrics(t *testing.T) {
+	mustPanic := func(name string, fn func()) {
+		t.Helper()
+		defer func() {
+			if recover() == nil {
+				t.Errorf("expected panic for %s", name)
+			}
+		}()
+		fn()
+	}
+	reg := metrics.NewRegistry()
+	reg.NewCounter("things_total", "Things.")
+	mustPanic("duplicate metric", func() { reg.NewCounter("things_total", "Things.") })
+	// le 是直方图桶的保留标签，不能作为常量标签。
+	labeled := metrics.NewRegistryWithLabels(map[string]string{"le": "x"})
+	mustPanic("reserved label", func() { labeled.NewHistogram("wait_seconds", "Waits.", nil) })
+}
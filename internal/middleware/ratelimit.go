@@ -0,0 +1,45 @@
+// Package middleware 提供与业务无关的 HTTP 中间件。
+package middleware
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+
+	"golang.org/x/time/rate"
+)
+
+// CodeRateLimited 为请求被限流时响应中的错误代码。
+const CodeRateLimited = "RATE_LIMITED"
+
+// requestIDHeader 与 api.RequestIDHeader 相同，限流响应据此附带请求 ID。
+const requestIDHeader = "X-Request-ID"
+
+// RateLimitMiddleware 在限流器没有可用令牌时返回 429，Retry-After 为下一个令牌可用前的秒数（至少 1 秒），
+// 响应体与 API 的错误响应格式相同。被拒绝的请求不消耗令牌。
+func RateLimitMiddleware(limiter *rate.Limiter, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		res := limiter.Reserve()
+		wait := res.Delay()
+		if res.OK() && wait == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		// 预约的令牌要等 wait 之后才可用，请求不会等待，归还预约。
+		res.Cancel()
+		seconds := int64(math.Ceil(wait.Seconds()))
+		if seconds < 1 || wait == rate.InfDuration {
+			seconds = 1
+		}
+		w.Header().Set("Retry-After", strconv.FormatInt(seconds, 10))
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusTooManyRequests)
+		_ = json.NewEncoder(w).Encode(struct {
+			Ok        bool   `json:"ok"`
+			Error     string `json:"error"`
+			Code      string `json:"code"`
+			RequestID string `json:"request_id,omitempty"`
+		}{false, "请求过于频繁，请稍后重试", CodeRateLimited, w.Header().Get(requestIDHeader)})
+	})
+}
@@ -0,0 +1,67 @@
+package middleware_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"codex-backup-tool/internal/middleware"
+)
+
+func TestRateLimitMiddleware(t *testing.T) {
+	const burst, total = 5, 20
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) })
+	handler := middleware.RateLimitMiddleware(rate.NewLimiter(0.001, burst), next)
+
+	limited := 0
+	for i := 0; i < total; i++ {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/backups", nil))
+		switch rec.Code {
+		case http.StatusNoContent:
+		case http.StatusTooManyRequests:
+			limited++
+			if rec.Header().Get("Retry-After") == "" {
+				t.Fatalf("expected Retry-After on 429")
+			}
+			var body struct {
+				Ok   bool   `json:"ok"`
+				Code string `json:"code"`
+			}
+			if err := json.NewDecoder(rec.Body).Decode(&body); err != nil || body.Ok || body.Code != middleware.CodeRateLimited {
+				t.Fatalf("unexpected 429 body: %+v (%v)", body, err)
+			}
+		default:
+			t.Fatalf("unexpected status %d", rec.Code)
+		}
+	}
+	if limited != total-burst {
+		t.Fatalf("expected %d rate-limited responses, got %d", total-burst, limited)
+	}
+}
+
+func TestRateLimitRetryAfterAndRefill(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) })
+	handler := middleware.RateLimitMiddleware(rate.NewLimiter(10, 1), next)
+	serve := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/status", nil))
+		return rec
+	}
+	if rec := serve(); rec.Code != http.StatusNoContent {
+		t.Fatalf("expected the first request to pass, got %d", rec.Code)
+	}
+	rec := serve()
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "1" {
+		t.Fatalf("expected 429 with Retry-After: 1, got %d %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	// 被拒绝的请求归还了预约，150ms 补充的 1.5 个令牌足够放行下一个请求。
+	time.Sleep(150 * time.Millisecond)
+	if rec := serve(); rec.Code != http.StatusNoContent {
+		t.Fatalf("expected a token after refilling, got %d", rec.Code)
+	}
+}
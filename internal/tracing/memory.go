@@ -0,0 +1,34 @@
+package tracing
+
+import (
+	"context"
+	"sync"
+)
+
+// InMemoryExporter 将导出的 span 保存在内存中，供测试检查。
+type InMemoryExporter struct {
+	mu    sync.Mutex
+	spans []SpanData
+}
+
+// Export 实现 Exporter。
+func (e *InMemoryExporter) Export(_ context.Context, spans []SpanData) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = append(e.spans, spans...)
+	return nil
+}
+
+// Spans 返回已导出 span 的副本。
+func (e *InMemoryExporter) Spans() []SpanData {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]SpanData(nil), e.spans...)
+}
+
+// Reset 清空已保存的 span。
+func (e *InMemoryExporter) Reset() {
+	e.mu.Lock()
+	e.spans = nil
+	e.mu.Unlock()
+}
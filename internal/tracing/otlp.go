@@ -0,0 +1,121 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OTLPExporter 以 OTLP/HTTP JSON 编码将 span 发送到 collector（如 Jaeger 的 4318 端口）。
+type OTLPExporter struct {
+	url         string
+	serviceName string
+	client      *http.Client
+}
+
+// NewOTLPExporter 创建导出器；endpoint 未以 /v1/traces 结尾时自动补上。
+func NewOTLPExporter(endpoint, serviceName string) *OTLPExporter {
+	url := strings.TrimRight(endpoint, "/")
+	if !strings.HasSuffix(url, "/v1/traces") {
+		url += "/v1/traces"
+	}
+	return &OTLPExporter{url: url, serviceName: serviceName, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// Export 实现 Exporter。
+func (e *OTLPExporter) Export(ctx context.Context, spans []SpanData) error {
+	payload, err := json.Marshal(e.encode(spans))
+	if err != nil {
+		return fmt.Errorf("encode spans: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("post spans: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("post spans: collector returned %s", resp.Status)
+	}
+	return nil
+}
+
+type otlpKeyValue struct {
+	Key   string         `json:"key"`
+	Value map[string]any `json:"value"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID      string         `json:"traceId"`
+	SpanID       string         `json:"spanId"`
+	ParentSpanID string         `json:"parentSpanId,omitempty"`
+	Name         string         `json:"name"`
+	Kind         int            `json:"kind"`
+	Start        string         `json:"startTimeUnixNano"`
+	End          string         `json:"endTimeUnixNano"`
+	Attributes   []otlpKeyValue `json:"attributes,omitempty"`
+	Status       *otlpStatus    `json:"status,omitempty"`
+}
+
+// encode 生成 ExportTraceServiceRequest 的 JSON 结构；OTLP/JSON 中的 ID 使用十六进制字符串。
+func (e *OTLPExporter) encode(spans []SpanData) map[string]any {
+	out := make([]otlpSpan, 0, len(spans))
+	for _, sd := range spans {
+		span := otlpSpan{
+			TraceID: sd.TraceID.String(),
+			SpanID:  sd.SpanID.String(),
+			Name:    sd.Name,
+			Kind:    int(sd.Kind),
+			Start:   strconv.FormatInt(sd.Start.UnixNano(), 10),
+			End:     strconv.FormatInt(sd.End.UnixNano(), 10),
+		}
+		if sd.ParentID.IsValid() {
+			span.ParentSpanID = sd.ParentID.String()
+		}
+		for _, attr := range sd.Attributes {
+			span.Attributes = append(span.Attributes, otlpAttribute(attr))
+		}
+		if sd.Error != "" {
+			span.Status = &otlpStatus{Code: 2, Message: sd.Error}
+		}
+		out = append(out, span)
+	}
+	resource := []otlpKeyValue{otlpAttribute(String("service.name", e.serviceName))}
+	return map[string]any{
+		"resourceSpans": []any{map[string]any{
+			"resource": map[string]any{"attributes": resource},
+			"scopeSpans": []any{map[string]any{
+				"scope": map[string]any{"name": e.serviceName},
+				"spans": out,
+			}},
+		}},
+	}
+}
+
+func otlpAttribute(attr Attribute) otlpKeyValue {
+	switch v := attr.Value.(type) {
+	case int64:
+		// OTLP/JSON 将 64 位整数编码为字符串。
+		return otlpKeyValue{Key: attr.Key, Value: map[string]any{"intValue": strconv.FormatInt(v, 10)}}
+	case bool:
+		return otlpKeyValue{Key: attr.Key, Value: map[string]any{"boolValue": v}}
+	default:
+		return otlpKeyValue{Key: attr.Key, Value: map[string]any{"stringValue": fmt.Sprint(v)}}
+	}
+}
@@ -0,0 +1,309 @@
+// Package tracing 提供最小化的分布式追踪：W3C traceparent 传播、批量导出与 OTLP/HTTP 导出器。
+// 未配置导出器时 Tracer 为 nil，所有方法都是空操作，不创建任何 goroutine。
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SpanKind 对应 OTLP 的 span kind。
+type SpanKind int
+
+const (
+	KindInternal SpanKind = 1
+	KindServer   SpanKind = 2
+)
+
+const (
+	queueSize     = 2048
+	batchSize     = 512
+	flushInterval = 5 * time.Second
+)
+
+// TraceID 与 SpanID 为 W3C Trace Context 定义的标识。
+type (
+	TraceID [16]byte
+	SpanID  [8]byte
+)
+
+func (id TraceID) String() string { return hex.EncodeToString(id[:]) }
+func (id SpanID) String() string  { return hex.EncodeToString(id[:]) }
+
+// IsValid 表示标识不全为零。
+func (id TraceID) IsValid() bool { return id != TraceID{} }
+func (id SpanID) IsValid() bool  { return id != SpanID{} }
+
+// Attribute 为 span 上的一个键值对，值为 string、int64 或 bool。
+type Attribute struct {
+	Key   string
+	Value any
+}
+
+// SpanData 为已结束 span 的只读快照，交给 Exporter 导出。
+type SpanData struct {
+	TraceID    TraceID
+	SpanID     SpanID
+	ParentID   SpanID
+	Name       string
+	Kind       SpanKind
+	Start      time.Time
+	End        time.Time
+	Attributes []Attribute
+	// Error 非空表示 span 以错误状态结束。
+	Error string
+}
+
+// Exporter 接收批量结束的 span。
+type Exporter interface {
+	Export(ctx context.Context, spans []SpanData) error
+}
+
+// Tracer 创建 span 并在后台批量导出；nil Tracer 的所有方法均为空操作。
+type Tracer struct {
+	exporter Exporter
+	logf     func(format string, args ...any)
+	queue    chan SpanData
+	flushReq chan chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// New 创建 Tracer 并启动后台导出；exporter 为 nil 时返回 nil。logf 用于记录导出失败，可为空。
+func New(exporter Exporter, logf func(format string, args ...any)) *Tracer {
+	if exporter == nil {
+		return nil
+	}
+	t := &Tracer{
+		exporter: exporter,
+		logf:     logf,
+		queue:    make(chan SpanData, queueSize),
+		flushReq: make(chan chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go t.loop()
+	return t
+}
+
+// Start 创建 span：ctx 中带有 span（或传入的 traceparent）时作为其子 span，否则开启新的 trace。
+func (t *Tracer) Start(ctx context.Context, name string, kind SpanKind, attrs ...Attribute) (context.Context, *Span) {
+	if t == nil {
+		return ctx, nil
+	}
+	span := &Span{tracer: t, data: SpanData{Name: name, Kind: kind, Start: time.Now(), Attributes: attrs}}
+	if parent := SpanFromContext(ctx); parent != nil {
+		span.data.TraceID, span.data.ParentID = parent.data.TraceID, parent.data.SpanID
+	} else if remote, ok := ctx.Value(remoteKey{}).(remoteParent); ok {
+		span.data.TraceID, span.data.ParentID = remote.traceID, remote.spanID
+	} else {
+		rand.Read(span.data.TraceID[:])
+	}
+	rand.Read(span.data.SpanID[:])
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// Start 在 ctx 中已有 span 时创建其子 span，否则不做任何事，供不持有 Tracer 的代码使用。
+func Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, *Span) {
+	parent := SpanFromContext(ctx)
+	if parent == nil {
+		return ctx, nil
+	}
+	return parent.tracer.Start(ctx, name, KindInternal, attrs...)
+}
+
+// Flush 导出所有已结束的 span 后返回。
+func (t *Tracer) Flush() {
+	if t == nil {
+		return
+	}
+	ack := make(chan struct{})
+	select {
+	case t.flushReq <- ack:
+		<-ack
+	case <-t.done:
+	}
+}
+
+// Shutdown 导出剩余 span 并停止后台 goroutine，重复调用无副作用。
+func (t *Tracer) Shutdown() {
+	if t == nil {
+		return
+	}
+	t.stopOnce.Do(func() {
+		t.Flush()
+		close(t.done)
+	})
+}
+
+func (t *Tracer) loop() {
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	batch := make([]SpanData, 0, batchSize)
+	export := func() {
+		// 排空已入队的 span，保证 Flush 之前结束的 span 都被导出。
+		for drained := false; !drained; {
+			select {
+			case sd := <-t.queue:
+				batch = append(batch, sd)
+			default:
+				drained = true
+			}
+		}
+		if len(batch) == 0 {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		if err := t.exporter.Export(ctx, batch); err != nil && t.logf != nil {
+			t.logf("导出 %d 个 span 失败: %v", len(batch), err)
+		}
+		cancel()
+		batch = make([]SpanData, 0, batchSize)
+	}
+	for {
+		select {
+		case sd := <-t.queue:
+			batch = append(batch, sd)
+			if len(batch) >= batchSize {
+				export()
+			}
+		case <-ticker.C:
+			export()
+		case ack := <-t.flushReq:
+			export()
+			close(ack)
+		case <-t.done:
+			return
+		}
+	}
+}
+
+func (t *Tracer) enqueue(sd SpanData) {
+	select {
+	case t.queue <- sd:
+	default:
+		// 队列已满时丢弃，追踪不应拖慢请求。
+	}
+}
+
+// Span 为进行中的 span；nil Span 的所有方法均为空操作。
+type Span struct {
+	tracer *Tracer
+	mu     sync.Mutex
+	data   SpanData
+	ended  bool
+}
+
+// SetAttributes 追加属性。
+func (s *Span) SetAttributes(attrs ...Attribute) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.data.Attributes = append(s.data.Attributes, attrs...)
+	s.mu.Unlock()
+}
+
+// SetError 将 span 标记为错误状态，err 为 nil 时不做任何事。
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	s.data.Error = err.Error()
+	s.mu.Unlock()
+}
+
+// End 结束 span 并交给后台导出，重复调用无副作用。
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.End = time.Now()
+	sd := s.data
+	s.mu.Unlock()
+	s.tracer.enqueue(sd)
+}
+
+// Child 以给定的起止时间记录一个已完成的子 span，用于事后补记各阶段耗时。
+func (s *Span) Child(name string, start, end time.Time) {
+	if s == nil {
+		return
+	}
+	sd := SpanData{TraceID: s.data.TraceID, ParentID: s.data.SpanID, Name: name, Kind: KindInternal, Start: start, End: end}
+	rand.Read(sd.SpanID[:])
+	s.tracer.enqueue(sd)
+}
+
+// TraceParent 返回可向下游传播的 traceparent 头。
+func (s *Span) TraceParent() string {
+	if s == nil {
+		return ""
+	}
+	return "00-" + s.data.TraceID.String() + "-" + s.data.SpanID.String() + "-01"
+}
+
+type spanKey struct{}
+
+type remoteKey struct{}
+
+type remoteParent struct {
+	traceID TraceID
+	spanID  SpanID
+}
+
+// SpanFromContext 返回 ctx 中当前的 span，没有时返回 nil。
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// ContextWithTraceParent 解析 W3C traceparent 头，合法时让之后的 Start 沿用其中的 trace 与父 span。
+func ContextWithTraceParent(ctx context.Context, header string) context.Context {
+	traceID, spanID, ok := ParseTraceParent(header)
+	if !ok {
+		return ctx
+	}
+	return context.WithValue(ctx, remoteKey{}, remoteParent{traceID: traceID, spanID: spanID})
+}
+
+// ParseTraceParent 解析形如 00-<32 hex>-<16 hex>-<2 hex> 的 traceparent 头。
+func ParseTraceParent(header string) (TraceID, SpanID, bool) {
+	var traceID TraceID
+	var spanID SpanID
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || len(parts[3]) != 2 {
+		return traceID, spanID, false
+	}
+	// 版本 00 必须恰好四段，更高版本允许在末尾追加字段。
+	if parts[0] == "00" && len(parts) != 4 {
+		return traceID, spanID, false
+	}
+	if len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return traceID, spanID, false
+	}
+	if _, err := hex.Decode(traceID[:], []byte(parts[1])); err != nil {
+		return TraceID{}, SpanID{}, false
+	}
+	if _, err := hex.Decode(spanID[:], []byte(parts[2])); err != nil {
+		return TraceID{}, SpanID{}, false
+	}
+	if !traceID.IsValid() || !spanID.IsValid() {
+		return TraceID{}, SpanID{}, false
+	}
+	return traceID, spanID, true
+}
+
+// String、Int 与 Bool 构造属性。
+func String(key, value string) Attribute    { return Attribute{Key: key, Value: value} }
+func Int(key string, value int64) Attribute { return Attribute{Key: key, Value: value} }
+func Bool(key string, value bool) Attribute { return Attribute{Key: key, Value: value} }
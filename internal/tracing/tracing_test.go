@@ -0,0 +1,83 @@
+package tracing_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"codex-backup-tool/internal/tracing"
+)
+
+func TestParseTraceParent(t *testing.T) {
+	cases := map[string]bool{
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01":       true,
+		"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra": true,
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra": false,
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01":       false,
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01":       false,
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01":       false,
+		"00-4bf92f3577b34da6a3ce929d0e0e473-00f067aa0ba902b7-01":        false,
+		"00-4bf92f3577b34da6a3ce929d0e0e4736aa-00f067aa0ba902b7-01":     false,
+		"00-zzf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01":       false,
+		"": false,
+	}
+	for header, want := range cases {
+		if _, _, ok := tracing.ParseTraceParent(header); ok != want {
+			t.Errorf("ParseTraceParent(%q) = %t, want %t", header, ok, want)
+		}
+	}
+}
+
+func TestNilTracerIsNoop(t *testing.T) {
+	var tracer *tracing.Tracer
+	ctx, span := tracer.Start(context.Background(), "noop", tracing.KindInternal)
+	span.SetAttributes(tracing.String("k", "v"))
+	span.End()
+	if span != nil || tracing.SpanFromContext(ctx) != nil {
+		t.Fatalf("expected nil tracer to create no span")
+	}
+	if _, child := tracing.Start(ctx, "child"); child != nil {
+		t.Fatalf("expected no child span without a parent")
+	}
+	tracer.Flush()
+	tracer.Shutdown()
+}
+
+func TestOTLPExporterPostsJSON(t *testing.T) {
+	var body struct {
+		ResourceSpans []struct {
+			ScopeSpans []struct {
+				Spans []struct {
+					TraceID      string `json:"traceId"`
+					SpanID       string `json:"spanId"`
+					ParentSpanID string `json:"parentSpanId"`
+					Name         string `json:"name"`
+				} `json:"spans"`
+			} `json:"scopeSpans"`
+		} `json:"resourceSpans"`
+	}
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected request %s %q", r.URL.Path, r.Header.Get("Content-Type"))
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+	}))
+	defer collector.Close()
+
+	tracer := tracing.New(tracing.NewOTLPExporter(collector.URL, "test"), t.Logf)
+	ctx, parent := tracer.Start(context.Background(), "parent", tracing.KindServer)
+	_, child := tracing.Start(ctx, "child")
+	child.End()
+	parent.End()
+	tracer.Shutdown()
+
+	if len(body.ResourceSpans) != 1 || len(body.ResourceSpans[0].ScopeSpans) != 1 {
+		t.Fatalf("unexpected payload %+v", body)
+	}
+	spans := body.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 2 || spans[0].Name != "child" || spans[0].ParentSpanID != spans[1].SpanID || spans[0].TraceID != spans[1].TraceID {
+		t.Fatalf("unexpected spans %+v", spans)
+	}
+}
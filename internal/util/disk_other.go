@@ -0,0 +1,10 @@
+//go:build !linux && !darwin && !freebsd && !dragonfly && !windows
+
+package util
+
+import "errors"
+
+// DiskFree 在不支持的平台上返回错误，调用方应直接尝试写入。
+func DiskFree(string) (uint64, error) {
+	return 0, errors.New("disk free not supported on this platform")
+}
@@ -0,0 +1,14 @@
+//go:build linux || darwin || freebsd || dragonfly
+
+package util
+
+import "syscall"
+
+// DiskFree 返回 path 所在文件系统对当前用户可用的剩余字节数。
+func DiskFree(path string) (uint64, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, err
+	}
+	return uint64(st.Bavail) * uint64(st.Bsize), nil
+}
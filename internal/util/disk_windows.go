@@ -0,0 +1,18 @@
+//go:build windows
+
+package util
+
+import "golang.org/x/sys/windows"
+
+// DiskFree 返回 path 所在卷对当前用户可用的剩余字节数。
+func DiskFree(path string) (uint64, error) {
+	p, err := windows.UTF16PtrFromString(LongPath(path))
+	if err != nil {
+		return 0, err
+	}
+	var free, total, totalFree uint64
+	if err := windows.GetDiskFreeSpaceEx(p, &free, &total, &totalFree); err != nil {
+		return 0, err
+	}
+	return free, nil
+}
@@ -1,12 +1,14 @@
 package util
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 )
 
 // ExpandPath 将 ~ 展开并返回绝对路径。
@@ -14,6 +16,12 @@ func ExpandPath(p string) (string, error) {
 	if p == "" {
 		return "", errors.New("path is empty")
 	}
+	if isExtendedPath(p) {
+		return p, nil
+	}
+	if isUNC(p) {
+		return filepath.Clean(p), nil
+	}
 	if strings.HasPrefix(p, "~") {
 		home, err := os.UserHomeDir()
 		if err != nil {
@@ -36,47 +44,72 @@ func ExpandPath(p string) (string, error) {
 
 // EnsureDir 确保目录存在。
 func EnsureDir(dir string) error {
+	return EnsureDirMode(dir, 0o755)
+}
+
+// EnsureDirMode 确保目录存在，新建的目录使用 mode 权限（受 umask 影响）。
+func EnsureDirMode(dir string, mode os.FileMode) error {
 	if dir == "" {
 		return errors.New("dir is empty")
 	}
-	return os.MkdirAll(dir, 0o755)
+	return os.MkdirAll(LongPath(dir), mode)
 }
 
-// AtomicWriteJSON 以原子方式写入 JSON 文件。
-func AtomicWriteJSON(path string, data any) error {
-	if err := EnsureDir(filepath.Dir(path)); err != nil {
-		return fmt.Errorf("ensure dir: %w", err)
-	}
-	payload, err := json.MarshalIndent(data, "", "  ")
-	if err != nil {
-		return fmt.Errorf("marshal json: %w", err)
-	}
-	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+// AtomicWriteJSONOptions 控制 AtomicWriteJSON 的编码方式。
+type AtomicWriteJSONOptions struct {
+	// Indent 为 true 时以两个空格缩进输出，否则输出紧凑的单行。
+	Indent bool
+	// EscapeHTML 为 true 时与标准库一样将 <、>、& 转义为 \u003c 等形式。
+	EscapeHTML bool
+	// SortKeys 为 true 时对所有对象（包括结构体）按键名排序，便于比较与签名。
+	SortKeys bool
+}
+
+// AtomicWriteJSON 按 opts 编码 data 并以原子方式写入 JSON 文件。
+func AtomicWriteJSON(path string, data any, opts AtomicWriteJSONOptions) error {
+	payload, err := MarshalJSON(data, opts)
 	if err != nil {
-		return fmt.Errorf("create temp: %w", err)
+		return err
 	}
-	defer func() {
-		tmp.Close()
-		os.Remove(tmp.Name())
-	}()
-	if _, err := tmp.Write(payload); err != nil {
-		return fmt.Errorf("write temp: %w", err)
-	}
-	if err := tmp.Sync(); err != nil {
-		return fmt.Errorf("sync temp: %w", err)
+	return AtomicWriteFile(path, payload, 0o600)
+}
+
+// AtomicWriteJSONDefault 使用缩进并转义 HTML 字符的默认格式写入，与引入选项前的输出一致。
+func AtomicWriteJSONDefault(path string, data any) error {
+	return AtomicWriteJSON(path, data, AtomicWriteJSONOptions{Indent: true, EscapeHTML: true})
+}
+
+// MarshalJSON 按 opts 编码 data，结果不以换行结尾。
+func MarshalJSON(data any, opts AtomicWriteJSONOptions) ([]byte, error) {
+	if opts.SortKeys {
+		// 结构体字段按声明顺序输出，先转为通用值再编码，map 会按键名排序。
+		raw, err := json.Marshal(data)
+		if err != nil {
+			return nil, fmt.Errorf("marshal json: %w", err)
+		}
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		dec.UseNumber()
+		var generic any
+		if err := dec.Decode(&generic); err != nil {
+			return nil, fmt.Errorf("marshal json: %w", err)
+		}
+		data = generic
 	}
-	if err := tmp.Close(); err != nil {
-		return fmt.Errorf("close temp: %w", err)
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(opts.EscapeHTML)
+	if opts.Indent {
+		enc.SetIndent("", "  ")
 	}
-	if err := os.Rename(tmp.Name(), path); err != nil {
-		return fmt.Errorf("rename temp: %w", err)
+	if err := enc.Encode(data); err != nil {
+		return nil, fmt.Errorf("marshal json: %w", err)
 	}
-	return nil
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
 }
 
 // ReadFileIfExists 读取文件，若不存在返回 (nil, false, nil)。
 func ReadFileIfExists(path string) ([]byte, bool, error) {
-	data, err := os.ReadFile(path)
+	data, err := os.ReadFile(LongPath(path))
 	if err != nil {
 		if os.IsNotExist(err) {
 			return nil, false, nil
@@ -86,14 +119,24 @@ func ReadFileIfExists(path string) ([]byte, bool, error) {
 	return data, true, nil
 }
 
-// WithFileLock 对 lockPath 加锁，执行 fn 后释放。
+// PreserveMode 作为 AtomicWriteFile 的 perm 传入时，沿用目标文件原有权限。
+const PreserveMode os.FileMode = 0
 
 // AtomicWriteFile 以原子方式写入原始字节。
+// perm 为 PreserveMode 时保留已有文件的权限位（含 setuid/setgid/sticky），文件不存在则使用 0600。
+// 临时文件始终以 0600 创建，内容写完后才调整为最终权限。
 func AtomicWriteFile(path string, data []byte, perm os.FileMode) error {
 	if err := EnsureDir(filepath.Dir(path)); err != nil {
 		return fmt.Errorf("ensure dir: %w", err)
 	}
-	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if perm == PreserveMode {
+		mode, err := existingMode(path)
+		if err != nil {
+			return err
+		}
+		perm = mode
+	}
+	tmp, err := os.CreateTemp(LongPath(filepath.Dir(path)), tempPrefix+"*")
 	if err != nil {
 		return fmt.Errorf("create temp: %w", err)
 	}
@@ -113,24 +156,67 @@ func AtomicWriteFile(path string, data []byte, perm os.FileMode) error {
 	if err := tmp.Close(); err != nil {
 		return fmt.Errorf("close temp: %w", err)
 	}
-	if err := os.Rename(tmp.Name(), path); err != nil {
+	if err := os.Rename(tmp.Name(), LongPath(path)); err != nil {
 		return fmt.Errorf("rename temp: %w", err)
 	}
 	return nil
 }
 
-func WithFileLock(lockPath string, fn func() error) error {
-	if err := EnsureDir(filepath.Dir(lockPath)); err != nil {
-		return fmt.Errorf("ensure lock dir: %w", err)
+func existingMode(path string) (os.FileMode, error) {
+	info, err := os.Stat(LongPath(path))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0o600, nil
+		}
+		return 0, fmt.Errorf("stat existing: %w", err)
 	}
-	f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_RDWR, 0o600)
+	return info.Mode() & preservedModeBits, nil
+}
+
+// preservedModeBits 为覆盖已有文件时保留的权限位，包括 setuid、setgid 与 sticky。
+const preservedModeBits = os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky
+
+// tempPrefix 为 AtomicWriteFile/AtomicWriteJSON 创建临时文件使用的前缀。
+const tempPrefix = ".tmp-"
+
+// isAtomicTempName 判断文件名是否为 os.CreateTemp(dir, ".tmp-*") 生成的临时文件名。
+func isAtomicTempName(name string) bool {
+	rest, ok := strings.CutPrefix(name, tempPrefix)
+	if !ok || rest == "" {
+		return false
+	}
+	for _, r := range rest {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// SweepTempFiles 删除 dir 下修改时间早于 olderThan 的原子写临时文件，返回删除数量。
+// 仅匹配 `.tmp-<数字>` 形式的文件名，不会触及备份文件或其他文件。
+func SweepTempFiles(dir string, olderThan time.Duration) (int, error) {
+	entries, err := os.ReadDir(LongPath(dir))
 	if err != nil {
-		return fmt.Errorf("open lock file: %w", err)
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("read dir: %w", err)
 	}
-	defer f.Close()
-	if err := lockFile(f); err != nil {
-		return fmt.Errorf("lock file: %w", err)
+	cutoff := time.Now().Add(-olderThan)
+	removed := 0
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || !isAtomicTempName(entry.Name()) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || info.ModTime().After(cutoff) {
+			continue
+		}
+		if err := os.Remove(LongPath(filepath.Join(dir, entry.Name()))); err != nil && !os.IsNotExist(err) {
+			return removed, fmt.Errorf("remove temp: %w", err)
+		}
+		removed++
 	}
-	defer unlockFile(f)
-	return fn()
+	return removed, nil
 }
@@ -0,0 +1,161 @@
+package util_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"codex-backup-tool/internal/util"
+)
+
+func TestAtomicWriteFilePreserveMode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows 不支持 Unix 权限位")
+	}
+	cases := []struct {
+		name     string
+		existing os.FileMode // 0 表示目标不存在
+		want     os.FileMode
+	}{
+		{name: "keep 0644", existing: 0o644, want: 0o644},
+		{name: "keep 0600", existing: 0o600, want: 0o600},
+		{name: "keep read-only 0400", existing: 0o400, want: 0o400},
+		{name: "keep setuid", existing: 0o755 | os.ModeSetuid, want: 0o755 | os.ModeSetuid},
+		{name: "absent defaults to 0600", want: 0o600},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "auth.json")
+			if tc.existing != 0 {
+				if err := os.WriteFile(path, []byte("old"), tc.existing); err != nil {
+					t.Fatalf("write existing: %v", err)
+				}
+				if err := os.Chmod(path, tc.existing); err != nil {
+					t.Fatalf("chmod existing: %v", err)
+				}
+			}
+			if err := util.AtomicWriteFile(path, []byte("new"), util.PreserveMode); err != nil {
+				t.Fatalf("atomic write: %v", err)
+			}
+			info, err := os.Stat(path)
+			if err != nil {
+				t.Fatalf("stat: %v", err)
+			}
+			if got := info.Mode() & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky); got != tc.want {
+				t.Fatalf("mode mismatch: want %v got %v", tc.want, got)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("read: %v", err)
+			}
+			if string(data) != "new" {
+				t.Fatalf("content mismatch: %s", data)
+			}
+		})
+	}
+}
+
+func TestAtomicWriteFileExplicitMode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows 不支持 Unix 权限位")
+	}
+	path := filepath.Join(t.TempDir(), "auth.json")
+	if err := os.WriteFile(path, []byte("old"), 0o644); err != nil {
+		t.Fatalf("write existing: %v", err)
+	}
+	if err := util.AtomicWriteFile(path, []byte("new"), 0o600); err != nil {
+		t.Fatalf("atomic write: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	if got := info.Mode().Perm(); got != 0o600 {
+		t.Fatalf("mode mismatch: want 600 got %o", got)
+	}
+}
+
+func TestSweepTempFilesRemovesOnlyAgedTempFiles(t *testing.T) {
+	dir := t.TempDir()
+	old := time.Now().Add(-2 * time.Hour)
+	files := map[string]bool{ // 文件名 -> 是否应被删除
+		".tmp-123456":                 true,
+		".tmp-999":                    false, // 新建的临时文件
+		"20250101-000000_abcdef.json": false,
+		".tmp-notdigits":              false,
+		"index.json":                  false,
+	}
+	for name := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte("x"), 0o600); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+		if name != ".tmp-999" {
+			if err := os.Chtimes(path, old, old); err != nil {
+				t.Fatalf("chtimes %s: %v", name, err)
+			}
+		}
+	}
+	removed, err := util.SweepTempFiles(dir, time.Hour)
+	if err != nil {
+		t.Fatalf("sweep: %v", err)
+	}
+	if removed != 1 {
+		t.Fatalf("expected 1 removal, got %d", removed)
+	}
+	for name, gone := range files {
+		_, err := os.Stat(filepath.Join(dir, name))
+		if gone != os.IsNotExist(err) {
+			t.Fatalf("%s: expected removed=%v, stat err=%v", name, gone, err)
+		}
+	}
+	if n, err := util.SweepTempFiles(filepath.Join(dir, "missing"), time.Hour); err != nil || n != 0 {
+		t.Fatalf("missing dir should be a no-op, got %d %v", n, err)
+	}
+}
+
+func TestAtomicWriteJSONOptions(t *testing.T) {
+	type payload struct {
+		Zeta  string `json:"zeta"`
+		Alpha string `json:"alpha"`
+	}
+	v := payload{Zeta: "<b>", Alpha: "a&b"}
+	cases := []struct {
+		name string
+		opts util.AtomicWriteJSONOptions
+		want string
+	}{
+		{"compact", util.AtomicWriteJSONOptions{}, `{"zeta":"<b>","alpha":"a&b"}`},
+		{"escape", util.AtomicWriteJSONOptions{EscapeHTML: true}, `{"zeta":"\u003cb\u003e","alpha":"a\u0026b"}`},
+		{"sorted", util.AtomicWriteJSONOptions{SortKeys: true}, `{"alpha":"a&b","zeta":"<b>"}`},
+		{"indent", util.AtomicWriteJSONOptions{Indent: true, SortKeys: true}, "{\n  \"alpha\": \"a&b\",\n  \"zeta\": \"<b>\"\n}"},
+	}
+	dir := t.TempDir()
+	for _, tc := range cases {
+		path := filepath.Join(dir, tc.name+".json")
+		if err := util.AtomicWriteJSON(path, v, tc.opts); err != nil {
+			t.Fatalf("%s: write: %v", tc.name, err)
+		}
+		got, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("%s: read: %v", tc.name, err)
+		}
+		if string(got) != tc.want {
+			t.Fatalf("%s: got %s, want %s", tc.name, got, tc.want)
+		}
+	}
+
+	// 默认包装保持引入选项前 json.MarshalIndent 的输出。
+	path := filepath.Join(dir, "default.json")
+	if err := util.AtomicWriteJSONDefault(path, v); err != nil {
+		t.Fatalf("default: %v", err)
+	}
+	got, _ := os.ReadFile(path)
+	want, _ := json.MarshalIndent(v, "", "  ")
+	if string(got) != string(want) {
+		t.Fatalf("default: got %s, want %s", got, want)
+	}
+}
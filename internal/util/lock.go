@@ -0,0 +1,283 @@
+package util
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ErrLockTimeout 在等待文件锁超时且锁不满足陈旧条件时返回。
+var ErrLockTimeout = errors.New("lock timeout")
+
+const (
+	// DefaultLockTimeout 为未指定 LockOptions.Timeout 时的等待上限。
+	DefaultLockTimeout = 30 * time.Second
+	lockPollInterval   = 20 * time.Millisecond
+	breakMarkerTTL     = time.Minute
+)
+
+// LockOptions 控制 WithFileLockOptions 的等待与陈旧锁处理。
+type LockOptions struct {
+	// Timeout 为获取锁的最长等待时间，超时后才检查锁是否陈旧。
+	Timeout time.Duration
+	// StaleAfter 大于 0 时，持有时间超过该值的锁即使进程仍存活也视为陈旧。
+	StaleAfter time.Duration
+	// Logf 用于输出强制解除陈旧锁等重要事件，可为空。
+	Logf func(format string, args ...any)
+}
+
+// LockOwner 为写入锁文件的持有者信息。
+type LockOwner struct {
+	PID        int       `json:"pid"`
+	Hostname   string    `json:"hostname"`
+	AcquiredAt time.Time `json:"acquired_at"`
+}
+
+func (o LockOwner) String() string {
+	return fmt.Sprintf("pid=%d host=%s acquired_at=%s", o.PID, o.Hostname, o.AcquiredAt.Format(time.RFC3339))
+}
+
+// WithFileLock 对 lockPath 加锁，执行 fn 后释放。
+func WithFileLock(lockPath string, fn func() error) error {
+	return WithFileLockOptions(lockPath, LockOptions{}, fn)
+}
+
+// WithFileLockOptions 对 lockPath 加锁并写入持有者信息，执行 fn 后释放。
+// 等待超时后，若记录的持有者为本机已退出的进程，或持有时间超过 StaleAfter，
+// 则在 `<lockPath>.break` 互斥标记的保护下重新校验并解除陈旧锁后重试。
+func WithFileLockOptions(lockPath string, opts LockOptions, fn func() error) error {
+	if err := EnsureDir(filepath.Dir(lockPath)); err != nil {
+		return fmt.Errorf("ensure lock dir: %w", err)
+	}
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = DefaultLockTimeout
+	}
+	waitStart := time.Now()
+	deadline := waitStart.Add(timeout)
+	for {
+		f, err := acquireOnce(lockPath)
+		if err != nil {
+			return err
+		}
+		if f != nil {
+			defer func() {
+				f.Truncate(0)
+				unlockFile(f)
+				f.Close()
+			}()
+			return fn()
+		}
+		if time.Now().Before(deadline) {
+			time.Sleep(lockPollInterval)
+			continue
+		}
+		owner, stale := inspectLock(lockPath, opts.StaleAfter)
+		if !stale {
+			if owner != nil && owner.AcquiredAt.After(waitStart) {
+				// 等待期间锁已易主，说明仍在正常流转，按新持有者重新计时。
+				waitStart = time.Now()
+				deadline = waitStart.Add(timeout)
+				continue
+			}
+			return fmt.Errorf("%w: %s held by %s", ErrLockTimeout, lockPath, describeOwner(owner))
+		}
+		if _, err := breakStaleLock(lockPath, owner, opts); err != nil {
+			return err
+		}
+		// 无论由谁解除，新的持有者都应获得完整的等待时间。
+		waitStart = time.Now()
+		deadline = waitStart.Add(timeout)
+	}
+}
+
+// HeldLock 为 AcquireFileLock 获得的、需显式释放的文件锁。
+type HeldLock struct {
+	path string
+	f    *os.File
+}
+
+// AcquireFileLock 对 lockPath 加锁并写入持有者信息，锁一直持有到 Release。
+// 持有进程退出时操作系统会自动释放锁，因此不做陈旧锁处理；超时返回 ErrLockTimeout。
+func AcquireFileLock(lockPath string, timeout time.Duration) (*HeldLock, error) {
+	if err := EnsureDir(filepath.Dir(lockPath)); err != nil {
+		return nil, fmt.Errorf("ensure lock dir: %w", err)
+	}
+	if timeout <= 0 {
+		timeout = DefaultLockTimeout
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		f, err := acquireOnce(lockPath)
+		if err != nil {
+			return nil, err
+		}
+		if f != nil {
+			return &HeldLock{path: lockPath, f: f}, nil
+		}
+		if time.Now().After(deadline) {
+			owner, _ := ReadLockOwner(lockPath)
+			return nil, fmt.Errorf("%w: %s held by %s", ErrLockTimeout, lockPath, describeOwner(owner))
+		}
+		time.Sleep(lockPollInterval)
+	}
+}
+
+// Release 清空持有者信息并释放锁，重复调用无副作用。
+func (l *HeldLock) Release() error {
+	if l == nil || l.f == nil {
+		return nil
+	}
+	l.f.Truncate(0)
+	err := unlockFile(l.f)
+	if cerr := l.f.Close(); err == nil {
+		err = cerr
+	}
+	l.f = nil
+	return err
+}
+
+// acquireOnce 尝试以非阻塞方式加锁；成功返回已写入持有者信息的文件，锁被占用时返回 nil。
+func acquireOnce(lockPath string) (*os.File, error) {
+	f, err := os.OpenFile(LongPath(lockPath), os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("open lock file: %w", err)
+	}
+	ok, err := tryLockFile(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("lock file: %w", err)
+	}
+	if !ok {
+		f.Close()
+		return nil, nil
+	}
+	// 加锁期间文件可能已被其他进程作为陈旧锁移除，此时锁住的是失效的 inode。
+	if !sameAsPath(f, lockPath) {
+		unlockFile(f)
+		f.Close()
+		return nil, nil
+	}
+	if err := writeOwner(f); err != nil {
+		unlockFile(f)
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+func sameAsPath(f *os.File, path string) bool {
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	pi, err := os.Stat(LongPath(path))
+	if err != nil {
+		return false
+	}
+	return os.SameFile(fi, pi)
+}
+
+func currentOwner() LockOwner {
+	host, _ := os.Hostname()
+	return LockOwner{PID: os.Getpid(), Hostname: host, AcquiredAt: time.Now().UTC()}
+}
+
+func writeOwner(f *os.File) error {
+	payload, err := json.Marshal(currentOwner())
+	if err != nil {
+		return fmt.Errorf("marshal lock owner: %w", err)
+	}
+	if err := f.Truncate(0); err != nil {
+		return fmt.Errorf("truncate lock file: %w", err)
+	}
+	if _, err := f.WriteAt(payload, 0); err != nil {
+		return fmt.Errorf("write lock owner: %w", err)
+	}
+	return f.Sync()
+}
+
+// ReadLockOwner 读取锁文件中记录的持有者信息。
+func ReadLockOwner(lockPath string) (*LockOwner, error) {
+	data, err := os.ReadFile(LongPath(lockPath))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+	var owner LockOwner
+	if err := json.Unmarshal(data, &owner); err != nil {
+		return nil, fmt.Errorf("parse lock owner: %w", err)
+	}
+	return &owner, nil
+}
+
+func describeOwner(owner *LockOwner) string {
+	if owner == nil {
+		return "unknown owner"
+	}
+	return owner.String()
+}
+
+// inspectLock 判断当前锁是否陈旧：持有者为本机已退出的进程，或持有时间超过 staleAfter。
+func inspectLock(lockPath string, staleAfter time.Duration) (*LockOwner, bool) {
+	owner, err := ReadLockOwner(lockPath)
+	if err != nil || owner == nil {
+		// 没有可用的持有者信息时，只能依据锁文件的修改时间判断。
+		if staleAfter <= 0 {
+			return nil, false
+		}
+		info, statErr := os.Stat(LongPath(lockPath))
+		return nil, statErr == nil && time.Since(info.ModTime()) > staleAfter
+	}
+	if staleAfter > 0 && time.Since(owner.AcquiredAt) > staleAfter {
+		return owner, true
+	}
+	host, _ := os.Hostname()
+	if owner.Hostname == host && owner.PID != os.Getpid() && !processAlive(owner.PID) {
+		return owner, true
+	}
+	return owner, false
+}
+
+// breakStaleLock 在 break 标记保护下再次确认持有者未变化后删除锁文件。
+// 返回 false 表示锁已被他人处理或持有者已变化，调用方应继续正常等待。
+func breakStaleLock(lockPath string, observed *LockOwner, opts LockOptions) (bool, error) {
+	marker := lockPath + ".break"
+	m, err := os.OpenFile(LongPath(marker), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		if os.IsExist(err) {
+			if info, statErr := os.Stat(LongPath(marker)); statErr == nil && time.Since(info.ModTime()) > breakMarkerTTL {
+				os.Remove(LongPath(marker))
+			}
+			time.Sleep(lockPollInterval)
+			return false, nil
+		}
+		return false, fmt.Errorf("create break marker: %w", err)
+	}
+	m.Close()
+	defer os.Remove(LongPath(marker))
+
+	current, stale := inspectLock(lockPath, opts.StaleAfter)
+	if !stale || !sameOwner(current, observed) {
+		return false, nil
+	}
+	if err := os.Remove(LongPath(lockPath)); err != nil && !os.IsNotExist(err) {
+		return false, fmt.Errorf("remove stale lock: %w", err)
+	}
+	if opts.Logf != nil {
+		opts.Logf("!!! 已强制解除陈旧文件锁 %s（原持有者 %s）", lockPath, describeOwner(observed))
+	}
+	return true, nil
+}
+
+func sameOwner(a, b *LockOwner) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.PID == b.PID && a.Hostname == b.Hostname && a.AcquiredAt.Equal(b.AcquiredAt)
+}
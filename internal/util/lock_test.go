@@ -0,0 +1,171 @@
+package util_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"codex-backup-tool/internal/util"
+)
+
+// holdLock 在后台持有锁，并把锁文件中的持有者信息替换为 owner，直到 release 被调用。
+func holdLock(t *testing.T, lockPath string, owner util.LockOwner) (release func()) {
+	t.Helper()
+	held := make(chan struct{})
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		err := util.WithFileLock(lockPath, func() error {
+			payload, _ := json.Marshal(owner)
+			if err := os.WriteFile(lockPath, payload, 0o600); err != nil {
+				return err
+			}
+			close(held)
+			<-done
+			return nil
+		})
+		if err != nil {
+			t.Errorf("hold lock: %v", err)
+		}
+	}()
+	select {
+	case <-held:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("failed to hold lock")
+	}
+	return func() {
+		close(done)
+		<-finished
+	}
+}
+
+func deadPID(t *testing.T) int {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("spawn helper: %v", err)
+	}
+	return cmd.Process.Pid
+}
+
+func TestFileLockBreaksLockOfDeadProcess(t *testing.T) {
+	lockPath := filepath.Join(t.TempDir(), "index.json.lock")
+	host, _ := os.Hostname()
+	release := holdLock(t, lockPath, util.LockOwner{PID: deadPID(t), Hostname: host, AcquiredAt: time.Now()})
+	defer release()
+
+	var logged []string
+	opts := util.LockOptions{Timeout: 100 * time.Millisecond, Logf: func(format string, args ...any) {
+		logged = append(logged, fmt.Sprintf(format, args...))
+	}}
+	ran := false
+	if err := util.WithFileLockOptions(lockPath, opts, func() error {
+		ran = true
+		owner, err := util.ReadLockOwner(lockPath)
+		if err != nil || owner == nil || owner.PID != os.Getpid() {
+			t.Errorf("expected lock file to record current process, got %+v %v", owner, err)
+		}
+		return nil
+	}); err != nil {
+		t.Fatalf("expected stale lock to be broken: %v", err)
+	}
+	if !ran || len(logged) != 1 {
+		t.Fatalf("expected fn to run and one break log, ran=%v logs=%v", ran, logged)
+	}
+}
+
+func TestFileLockTimesOutOnLiveOwner(t *testing.T) {
+	lockPath := filepath.Join(t.TempDir(), "index.json.lock")
+	host, _ := os.Hostname()
+	release := holdLock(t, lockPath, util.LockOwner{PID: os.Getpid(), Hostname: host, AcquiredAt: time.Now()})
+	defer release()
+
+	err := util.WithFileLockOptions(lockPath, util.LockOptions{Timeout: 100 * time.Millisecond}, func() error {
+		t.Fatalf("fn must not run while lock is held")
+		return nil
+	})
+	if !errors.Is(err, util.ErrLockTimeout) {
+		t.Fatalf("expected ErrLockTimeout, got %v", err)
+	}
+}
+
+func TestFileLockBreaksLockOlderThanThreshold(t *testing.T) {
+	lockPath := filepath.Join(t.TempDir(), "index.json.lock")
+	host, _ := os.Hostname()
+	release := holdLock(t, lockPath, util.LockOwner{PID: os.Getpid(), Hostname: host, AcquiredAt: time.Now().Add(-time.Hour)})
+	defer release()
+
+	opts := util.LockOptions{Timeout: 100 * time.Millisecond, StaleAfter: time.Minute}
+	if err := util.WithFileLockOptions(lockPath, opts, func() error { return nil }); err != nil {
+		t.Fatalf("expected aged lock to be broken: %v", err)
+	}
+}
+
+func TestFileLockConcurrentBreakersStayExclusive(t *testing.T) {
+	lockPath := filepath.Join(t.TempDir(), "index.json.lock")
+	host, _ := os.Hostname()
+	release := holdLock(t, lockPath, util.LockOwner{PID: deadPID(t), Hostname: host, AcquiredAt: time.Now()})
+	defer release()
+
+	var active, maxActive atomic.Int32
+	var wg sync.WaitGroup
+	errs := make(chan error, 4)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- util.WithFileLockOptions(lockPath, util.LockOptions{Timeout: 300 * time.Millisecond}, func() error {
+				n := active.Add(1)
+				for {
+					m := maxActive.Load()
+					if n <= m || maxActive.CompareAndSwap(m, n) {
+						break
+					}
+				}
+				time.Sleep(10 * time.Millisecond)
+				active.Add(-1)
+				return nil
+			})
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("breaker failed: %v", err)
+		}
+	}
+	if maxActive.Load() != 1 {
+		t.Fatalf("expected exclusive access, max concurrent holders=%d", maxActive.Load())
+	}
+}
+
+func TestAcquireFileLockHoldsUntilRelease(t *testing.T) {
+	lockPath := filepath.Join(t.TempDir(), ".instance.lock")
+	held, err := util.AcquireFileLock(lockPath, time.Second)
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	if _, err := util.AcquireFileLock(lockPath, 50*time.Millisecond); !errors.Is(err, util.ErrLockTimeout) {
+		t.Fatalf("expected ErrLockTimeout while held, got %v", err)
+	}
+	if owner, err := util.ReadLockOwner(lockPath); err != nil || owner == nil || owner.PID != os.Getpid() {
+		t.Fatalf("expected lock file to record current process, got %+v %v", owner, err)
+	}
+	if err := held.Release(); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+	again, err := util.AcquireFileLock(lockPath, time.Second)
+	if err != nil {
+		t.Fatalf("acquire after release: %v", err)
+	}
+	again.Release()
+}
@@ -3,21 +3,52 @@
 package util
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
 	"golang.org/x/sys/windows"
 )
 
-func lockFile(f *os.File) error {
+// lockOverlapped 锁定文件 4GiB 偏移处的一个字节，避免阻塞其他进程读取文件头部的持有者信息。
+func lockOverlapped() *windows.Overlapped {
+	return &windows.Overlapped{OffsetHigh: 1}
+}
+
+func tryLockFile(f *os.File) (bool, error) {
 	h := windows.Handle(f.Fd())
-	return windows.LockFileEx(h, windows.LOCKFILE_EXCLUSIVE_LOCK, 0, 1, 0, &windows.Overlapped{})
+	err := windows.LockFileEx(h, windows.LOCKFILE_EXCLUSIVE_LOCK|windows.LOCKFILE_FAIL_IMMEDIATELY, 0, 1, 0, lockOverlapped())
+	if err == nil {
+		return true, nil
+	}
+	if errors.Is(err, windows.ERROR_LOCK_VIOLATION) || errors.Is(err, windows.ERROR_IO_PENDING) {
+		return false, nil
+	}
+	return false, err
 }
 
 func unlockFile(f *os.File) error {
 	h := windows.Handle(f.Fd())
-	if err := windows.UnlockFileEx(h, 0, 1, 0, &windows.Overlapped{}); err != nil {
+	if err := windows.UnlockFileEx(h, 0, 1, 0, lockOverlapped()); err != nil {
 		return fmt.Errorf("unlock file: %w", err)
 	}
 	return nil
 }
+
+// processAlive 判断本机上的 pid 是否仍在运行。
+func processAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	h, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, uint32(pid))
+	if err != nil {
+		return errors.Is(err, windows.ERROR_ACCESS_DENIED)
+	}
+	defer windows.CloseHandle(h)
+	var code uint32
+	if err := windows.GetExitCodeProcess(h, &code); err != nil {
+		return true
+	}
+	const stillActive = 259
+	return code == stillActive
+}
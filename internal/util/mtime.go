@@ -0,0 +1,20 @@
+package util
+
+import (
+	"path/filepath"
+	"time"
+)
+
+// coarseMtimeGranularity 为 FAT/exFAT 与网络文件系统使用的修改时间粒度。
+// FAT 的时间戳精度为 2 秒，SMB/NFS 在多次 stat 之间也可能返回不一致的亚秒部分。
+const coarseMtimeGranularity = 2 * time.Second
+
+// MtimeGranularity 根据 path 所在文件系统类型推断修改时间的有效粒度，无法识别或精度足够时返回 0。
+// path 不存在时改为检查其所在目录。
+func MtimeGranularity(path string) time.Duration {
+	if g, ok := fsMtimeGranularity(path); ok {
+		return g
+	}
+	g, _ := fsMtimeGranularity(filepath.Dir(path))
+	return g
+}
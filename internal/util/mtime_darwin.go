@@ -0,0 +1,27 @@
+//go:build darwin
+
+package util
+
+import (
+	"syscall"
+	"time"
+)
+
+func fsMtimeGranularity(path string) (time.Duration, bool) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, false
+	}
+	name := make([]byte, 0, len(st.Fstypename))
+	for _, c := range st.Fstypename {
+		if c == 0 {
+			break
+		}
+		name = append(name, byte(c))
+	}
+	switch string(name) {
+	case "msdos", "exfat", "smbfs", "nfs", "afpfs", "webdav":
+		return coarseMtimeGranularity, true
+	}
+	return 0, true
+}
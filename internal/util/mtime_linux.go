@@ -0,0 +1,30 @@
+//go:build linux
+
+package util
+
+import (
+	"syscall"
+	"time"
+)
+
+// Linux statfs 返回的文件系统类型，取值见 statfs(2)。
+const (
+	msdosSuperMagic = 0x4d44
+	exfatSuperMagic = 0x2011bab0
+	nfsSuperMagic   = 0x6969
+	smbSuperMagic   = 0x517b
+	smb2SuperMagic  = 0xfe534d42
+	cifsSuperMagic  = 0xff534d42
+)
+
+func fsMtimeGranularity(path string) (time.Duration, bool) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, false
+	}
+	switch uint32(st.Type) {
+	case msdosSuperMagic, exfatSuperMagic, nfsSuperMagic, smbSuperMagic, smb2SuperMagic, cifsSuperMagic:
+		return coarseMtimeGranularity, true
+	}
+	return 0, true
+}
@@ -0,0 +1,10 @@
+//go:build !linux && !darwin && !windows
+
+package util
+
+import "time"
+
+// fsMtimeGranularity 在无法识别文件系统类型的平台上不做推断。
+func fsMtimeGranularity(string) (time.Duration, bool) {
+	return 0, false
+}
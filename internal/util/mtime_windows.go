@@ -0,0 +1,30 @@
+//go:build windows
+
+package util
+
+import (
+	"strings"
+	"time"
+
+	"golang.org/x/sys/windows"
+)
+
+func fsMtimeGranularity(path string) (time.Duration, bool) {
+	p, err := windows.UTF16PtrFromString(LongPath(path))
+	if err != nil {
+		return 0, false
+	}
+	root := make([]uint16, windows.MAX_PATH+1)
+	if err := windows.GetVolumePathName(p, &root[0], uint32(len(root))); err != nil {
+		return 0, false
+	}
+	fsName := make([]uint16, windows.MAX_PATH+1)
+	if err := windows.GetVolumeInformation(&root[0], nil, 0, nil, nil, nil, &fsName[0], uint32(len(fsName))); err != nil {
+		return 0, false
+	}
+	switch strings.ToUpper(windows.UTF16ToString(fsName)) {
+	case "FAT", "FAT32", "EXFAT":
+		return coarseMtimeGranularity, true
+	}
+	return 0, true
+}
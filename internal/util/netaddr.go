@@ -0,0 +1,21 @@
+package util
+
+import (
+	"net"
+	"strings"
+)
+
+// IsLoopbackHost 判断监听地址是否只在本机可达。
+// 空字符串与 0.0.0.0、:: 等未指定地址会监听所有网卡，视为非回环；
+// 除 localhost 外的主机名无法在不解析的情况下确认，同样视为非回环。
+func IsLoopbackHost(host string) bool {
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	if i := strings.IndexByte(host, '%'); i >= 0 {
+		host = host[:i]
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
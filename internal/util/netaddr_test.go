@@ -0,0 +1,30 @@
+package util_test
+
+import (
+	"testing"
+
+	"codex-backup-tool/internal/util"
+)
+
+func TestIsLoopbackHost(t *testing.T) {
+	cases := map[string]bool{
+		"127.0.0.1":        true,
+		"127.0.0.2":        true,
+		"localhost":        true,
+		"::1":              true,
+		"[::1]":            true,
+		"::ffff:127.0.0.1": true,
+		"":                 false,
+		"0.0.0.0":          false,
+		"::":               false,
+		"[::]":             false,
+		"192.168.1.10":     false,
+		"fe80::1%eth0":     false,
+		"example.com":      false,
+	}
+	for host, want := range cases {
+		if got := util.IsLoopbackHost(host); got != want {
+			t.Errorf("IsLoopbackHost(%q) = %v, want %v", host, got, want)
+		}
+	}
+}
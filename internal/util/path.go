@@ -0,0 +1,37 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// ErrUnsafePath 在文件名试图逃逸基准目录时返回。
+var ErrUnsafePath = errors.New("unsafe path")
+
+// SecureJoin 将 name 拼接到 base 下，拒绝绝对路径、盘符以及任何 `..` 组件。
+// name 中的 `/` 与 `\` 均视为分隔符，因此在所有平台上得到相同的判定结果。
+func SecureJoin(base, name string) (string, error) {
+	if name == "" {
+		return "", fmt.Errorf("%w: empty name", ErrUnsafePath)
+	}
+	if filepath.IsAbs(name) || filepath.VolumeName(name) != "" || strings.HasPrefix(name, "/") || strings.HasPrefix(name, `\`) {
+		return "", fmt.Errorf("%w: absolute path %q", ErrUnsafePath, name)
+	}
+	if strings.ContainsRune(name, ':') || strings.ContainsRune(name, 0) {
+		return "", fmt.Errorf("%w: invalid character in %q", ErrUnsafePath, name)
+	}
+	for _, part := range strings.FieldsFunc(name, func(r rune) bool { return r == '/' || r == '\\' }) {
+		if part == ".." {
+			return "", fmt.Errorf("%w: parent reference in %q", ErrUnsafePath, name)
+		}
+	}
+	cleanBase := filepath.Clean(base)
+	joined := filepath.Join(cleanBase, filepath.FromSlash(strings.ReplaceAll(name, `\`, "/")))
+	rel, err := filepath.Rel(cleanBase, joined)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%w: %q escapes %q", ErrUnsafePath, name, base)
+	}
+	return joined, nil
+}
@@ -0,0 +1,45 @@
+package util_test
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"codex-backup-tool/internal/util"
+)
+
+func TestSecureJoinRejectsTraversal(t *testing.T) {
+	base := filepath.Join(t.TempDir(), "backups")
+	bad := []string{
+		"",
+		".",
+		"../index.json",
+		"../../etc/passwd",
+		`..\..\Windows\win.ini`,
+		`a/../../b.json`,
+		`a\..\..\b.json`,
+		"/etc/passwd",
+		`\Windows\win.ini`,
+		`C:\Windows\win.ini`,
+		"C:relative.json",
+		`\\server\share\x.json`,
+	}
+	for _, name := range bad {
+		if got, err := util.SecureJoin(base, name); !errors.Is(err, util.ErrUnsafePath) {
+			t.Errorf("expected ErrUnsafePath for %q, got %q (%v)", name, got, err)
+		}
+	}
+}
+
+func TestSecureJoinAcceptsPlainNames(t *testing.T) {
+	base := filepath.Join(t.TempDir(), "backups")
+	for _, name := range []string{"20250101-000000_abc.json", "..hidden.json", "a..b.json", "sub/x.json"} {
+		got, err := util.SecureJoin(base, name)
+		if err != nil {
+			t.Fatalf("unexpected error for %q: %v", name, err)
+		}
+		if filepath.Dir(got) != base && filepath.Dir(filepath.Dir(got)) != base {
+			t.Fatalf("joined path %q is not under %q", got, base)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+//go:build !windows
+
+package util
+
+import "os"
+
+// IsReadOnly 判断 path 是否为只读文件：Unix 上以所有者写权限位为准。文件不存在时返回 false。
+func IsReadOnly(path string) (bool, error) {
+	info, err := os.Stat(LongPath(path))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return info.Mode().Perm()&0o200 == 0, nil
+}
+
+// SetReadOnly 增删所有者写权限位，其余权限位与 setuid/setgid/sticky 保持不变。
+func SetReadOnly(path string, readOnly bool) error {
+	info, err := os.Stat(LongPath(path))
+	if err != nil {
+		return err
+	}
+	mode := info.Mode() & preservedModeBits
+	if readOnly {
+		mode &^= 0o200
+	} else {
+		mode |= 0o200
+	}
+	return os.Chmod(LongPath(path), mode)
+}
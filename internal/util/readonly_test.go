@@ -0,0 +1,45 @@
+package util_test
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"codex-backup-tool/internal/util"
+)
+
+func TestSetReadOnlyRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "auth.json")
+	if ro, err := util.IsReadOnly(path); err != nil || ro {
+		t.Fatalf("missing file: readOnly=%t err=%v", ro, err)
+	}
+	if err := os.WriteFile(path, []byte("{}"), 0o640); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if ro, err := util.IsReadOnly(path); err != nil || ro {
+		t.Fatalf("writable file: readOnly=%t err=%v", ro, err)
+	}
+	if err := util.SetReadOnly(path, true); err != nil {
+		t.Fatalf("set read-only: %v", err)
+	}
+	t.Cleanup(func() { util.SetReadOnly(path, false) })
+	if ro, err := util.IsReadOnly(path); err != nil || !ro {
+		t.Fatalf("after set: readOnly=%t err=%v", ro, err)
+	}
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("stat: %v", err)
+		}
+		if got := info.Mode().Perm(); got != 0o440 {
+			t.Fatalf("expected only the owner write bit cleared, got %o", got)
+		}
+	}
+	if err := util.SetReadOnly(path, false); err != nil {
+		t.Fatalf("clear read-only: %v", err)
+	}
+	if ro, err := util.IsReadOnly(path); err != nil || ro {
+		t.Fatalf("after clear: readOnly=%t err=%v", ro, err)
+	}
+}
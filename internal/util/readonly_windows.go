@@ -0,0 +1,54 @@
+//go:build windows
+
+package util
+
+import (
+	"errors"
+	"io/fs"
+	"syscall"
+)
+
+// IsReadOnly 判断 path 是否带有 FILE_ATTRIBUTE_READONLY 属性。文件不存在时返回 false。
+func IsReadOnly(path string) (bool, error) {
+	attrs, err := fileAttributes(path)
+	if err != nil {
+		if errors.Is(err, syscall.ERROR_FILE_NOT_FOUND) || errors.Is(err, syscall.ERROR_PATH_NOT_FOUND) {
+			return false, nil
+		}
+		return false, err
+	}
+	return attrs&syscall.FILE_ATTRIBUTE_READONLY != 0, nil
+}
+
+// SetReadOnly 设置或清除 FILE_ATTRIBUTE_READONLY，隐藏、存档等其他属性保持不变。
+func SetReadOnly(path string, readOnly bool) error {
+	attrs, err := fileAttributes(path)
+	if err != nil {
+		return err
+	}
+	if readOnly {
+		attrs |= syscall.FILE_ATTRIBUTE_READONLY
+	} else {
+		attrs &^= syscall.FILE_ATTRIBUTE_READONLY
+	}
+	name, err := syscall.UTF16PtrFromString(LongPath(path))
+	if err != nil {
+		return err
+	}
+	if err := syscall.SetFileAttributes(name, attrs); err != nil {
+		return &fs.PathError{Op: "setfileattributes", Path: path, Err: err}
+	}
+	return nil
+}
+
+func fileAttributes(path string) (uint32, error) {
+	name, err := syscall.UTF16PtrFromString(LongPath(path))
+	if err != nil {
+		return 0, err
+	}
+	attrs, err := syscall.GetFileAttributes(name)
+	if err != nil {
+		return 0, &fs.PathError{Op: "getfileattributes", Path: path, Err: err}
+	}
+	return attrs, nil
+}
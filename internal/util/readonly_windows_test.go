@@ -0,0 +1,48 @@
+//go:build windows
+
+package util
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestSetReadOnlyKeepsOtherAttributes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "auth.json")
+	if err := os.WriteFile(path, []byte("{}"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	name, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		t.Fatalf("utf16: %v", err)
+	}
+	if err := syscall.SetFileAttributes(name, syscall.FILE_ATTRIBUTE_READONLY|syscall.FILE_ATTRIBUTE_HIDDEN); err != nil {
+		t.Fatalf("set attributes: %v", err)
+	}
+	t.Cleanup(func() { syscall.SetFileAttributes(name, syscall.FILE_ATTRIBUTE_NORMAL) })
+	if ro, err := IsReadOnly(path); err != nil || !ro {
+		t.Fatalf("expected read-only attribute: readOnly=%t err=%v", ro, err)
+	}
+	if err := SetReadOnly(path, false); err != nil {
+		t.Fatalf("clear read-only: %v", err)
+	}
+	attrs, err := syscall.GetFileAttributes(name)
+	if err != nil {
+		t.Fatalf("get attributes: %v", err)
+	}
+	if attrs&syscall.FILE_ATTRIBUTE_READONLY != 0 || attrs&syscall.FILE_ATTRIBUTE_HIDDEN == 0 {
+		t.Fatalf("expected read-only cleared and hidden kept, got %#x", attrs)
+	}
+	// 取消只读后原子写入可以替换文件，再恢复只读属性。
+	if err := AtomicWriteFile(path, []byte(`{"a":1}`), PreserveMode); err != nil {
+		t.Fatalf("atomic write: %v", err)
+	}
+	if err := SetReadOnly(path, true); err != nil {
+		t.Fatalf("restore read-only: %v", err)
+	}
+	if ro, err := IsReadOnly(path); err != nil || !ro {
+		t.Fatalf("expected read-only after restore: readOnly=%t err=%v", ro, err)
+	}
+}
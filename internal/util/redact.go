@@ -0,0 +1,95 @@
+package util
+
+import (
+	"encoding/json"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// Redacted 为替换敏感内容后的占位符。
+const Redacted = "[REDACTED]"
+
+var (
+	// secretFieldPattern 匹配 JSON 或 key=value 形式的敏感字段值。
+	secretFieldPattern = regexp.MustCompile(`(?i)("?(?:api[_-]?key|access[_-]?token|refresh[_-]?token|id[_-]?token|token|password|passphrase|secret|authorization)"?\s*[:=]\s*)("[^"]*"|[^\s,;&}]+)`)
+	// bearerPattern 匹配 HTTP Authorization 头中的凭据。
+	bearerPattern = regexp.MustCompile(`(?i)\b(Bearer|Basic)\s+[A-Za-z0-9._~+/=-]+`)
+	// tokenPatterns 匹配常见的令牌格式：OpenAI 密钥、本工具签发的 API 令牌与 JWT。
+	tokenPatterns = []*regexp.Regexp{
+		regexp.MustCompile(`\bsk-[A-Za-z0-9_-]{16,}`),
+		regexp.MustCompile(`\bcbt_[A-Za-z0-9_-]{16,}`),
+		regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`),
+	}
+)
+
+// Redact 将字符串中形似令牌、密钥或密码的片段替换为 [REDACTED]。
+func Redact(s string) string {
+	s = bearerPattern.ReplaceAllString(s, "$1 "+Redacted)
+	s = secretFieldPattern.ReplaceAllStringFunc(s, func(m string) string {
+		sub := secretFieldPattern.FindStringSubmatch(m)
+		if strings.HasPrefix(sub[2], `"`) {
+			return sub[1] + `"` + Redacted + `"`
+		}
+		return sub[1] + Redacted
+	})
+	for _, p := range tokenPatterns {
+		s = p.ReplaceAllString(s, Redacted)
+	}
+	return s
+}
+
+// SecretString 保存敏感字符串，格式化输出与 JSON 序列化时只显示占位符。
+type SecretString string
+
+// String 实现 fmt.Stringer，避免 %v/%s 打印出明文。
+func (s SecretString) String() string {
+	if s == "" {
+		return ""
+	}
+	return Redacted
+}
+
+// GoString 实现 fmt.GoStringer，覆盖 %#v 输出。
+func (s SecretString) GoString() string {
+	return s.String()
+}
+
+// MarshalJSON 序列化时同样只输出占位符。
+func (s SecretString) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + s.String() + `"`), nil
+}
+
+// UnmarshalJSON 允许从 JSON 请求体或配置文件中读取明文。
+func (s *SecretString) UnmarshalJSON(data []byte) error {
+	var v string
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*s = SecretString(v)
+	return nil
+}
+
+// Reveal 返回明文，仅在真正需要使用密钥时调用。
+func (s SecretString) Reveal() string {
+	return string(s)
+}
+
+type redactWriter struct {
+	w io.Writer
+}
+
+// NewRedactWriter 包装 w，写入前对每段内容执行 Redact。log.Logger 每条日志只调用一次 Write。
+func NewRedactWriter(w io.Writer) io.Writer {
+	if _, ok := w.(*redactWriter); ok {
+		return w
+	}
+	return &redactWriter{w: w}
+}
+
+func (r *redactWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(r.w, Redact(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
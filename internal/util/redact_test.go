@@ -0,0 +1,68 @@
+package util_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"testing"
+
+	"codex-backup-tool/internal/util"
+)
+
+func TestRedact(t *testing.T) {
+	cases := []struct {
+		in   string
+		leak string
+	}{
+		{`{"OPENAI_API_KEY": "sk-proj-abcdefghijklmnop1234"}`, "sk-proj-abcdefghijklmnop1234"},
+		{`{"tokens":{"access_token":"opaque-value-1"}}`, "opaque-value-1"},
+		{`refresh_token=opaque-value-2&x=1`, "opaque-value-2"},
+		{`Authorization: Bearer abc.def.ghi`, "abc.def.ghi"},
+		{`issued cbt_AAAAAAAAAAAAAAAAAAAAAAAA`, "cbt_AAAAAAAAAAAAAAAAAAAAAAAA"},
+		{`jwt eyJhbGciOiJIUzI1NiJ9.eyJzdWIiOiIxIn0.sig`, "eyJhbGciOiJIUzI1NiJ9"},
+	}
+	for _, tc := range cases {
+		got := util.Redact(tc.in)
+		if strings.Contains(got, tc.leak) || !strings.Contains(got, util.Redacted) {
+			t.Errorf("Redact(%q) = %q", tc.in, got)
+		}
+	}
+	plain := "创建备份 succeed id=1 hash=0123456789abcdef path=/tmp/codex/auth.json"
+	if got := util.Redact(plain); got != plain {
+		t.Errorf("Redact changed harmless text: %q", got)
+	}
+}
+
+func TestSecretStringNeverFormatsPlaintext(t *testing.T) {
+	secret := util.SecretString("hunter2")
+	for _, out := range []string{
+		fmt.Sprintf("%v %s %+v %#v", secret, secret, struct{ P util.SecretString }{secret}, secret),
+	} {
+		if strings.Contains(out, "hunter2") {
+			t.Fatalf("secret leaked via fmt: %q", out)
+		}
+	}
+	data, err := json.Marshal(struct {
+		P util.SecretString `json:"p"`
+	}{secret})
+	if err != nil || strings.Contains(string(data), "hunter2") {
+		t.Fatalf("secret leaked via json: %s %v", data, err)
+	}
+	var decoded struct {
+		P util.SecretString `json:"p"`
+	}
+	if err := json.Unmarshal([]byte(`{"p":"hunter2"}`), &decoded); err != nil || decoded.P.Reveal() != "hunter2" {
+		t.Fatalf("unmarshal: %+v %v", decoded, err)
+	}
+}
+
+func TestRedactWriterScrubsLogLines(t *testing.T) {
+	var buf bytes.Buffer
+	logger := log.New(util.NewRedactWriter(&buf), "", 0)
+	logger.Printf("login with password=%s", "hunter2")
+	if strings.Contains(buf.String(), "hunter2") {
+		t.Fatalf("log leaked secret: %q", buf.String())
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !windows
+
+package util
+
+func isUNC(string) bool { return false }
+
+func isExtendedPath(string) bool { return false }
+
+// LongPath 在非 Windows 平台原样返回路径。
+func LongPath(p string) string { return p }
@@ -0,0 +1,35 @@
+//go:build windows
+
+package util
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// maxShortPath 为不加前缀时 Win32 API 可接受的路径长度上限（目录需预留 8.3 文件名空间）。
+const maxShortPath = 248
+
+// isUNC 判断路径是否为 UNC 共享路径（\\server\share 或 //server/share）。
+func isUNC(p string) bool {
+	if isExtendedPath(p) {
+		return false
+	}
+	return len(p) > 2 && (strings.HasPrefix(p, `\\`) || strings.HasPrefix(p, `//`))
+}
+
+func isExtendedPath(p string) bool {
+	return strings.HasPrefix(p, `\\?\`) || strings.HasPrefix(p, `//?/`)
+}
+
+// LongPath 为超长的绝对路径加上 \\?\ 前缀，UNC 路径转换为 \\?\UNC\ 形式。
+func LongPath(p string) string {
+	if len(p) < maxShortPath || isExtendedPath(p) || !filepath.IsAbs(p) {
+		return p
+	}
+	cleaned := filepath.Clean(p)
+	if isUNC(cleaned) {
+		return `\\?\UNC\` + strings.TrimLeft(cleaned, `\`)
+	}
+	return `\\?\` + cleaned
+}
@@ -0,0 +1,64 @@
+//go:build windows
+
+package util
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExpandPathUNC(t *testing.T) {
+	cases := map[string]string{
+		`\\server\share\codex`:        `\\server\share\codex`,
+		`\\server\share\codex\..\x`:   `\\server\share\x`,
+		`//server/share/codex`:        `\\server\share\codex`,
+		`\\?\C:\very\long\path\codex`: `\\?\C:\very\long\path\codex`,
+	}
+	for in, want := range cases {
+		got, err := ExpandPath(in)
+		if err != nil {
+			t.Fatalf("expand %s: %v", in, err)
+		}
+		if got != want {
+			t.Fatalf("expand %s: want %s got %s", in, want, got)
+		}
+	}
+}
+
+func TestLongPathPrefix(t *testing.T) {
+	short := `C:\codex\auth.json`
+	if got := LongPath(short); got != short {
+		t.Fatalf("short path should be untouched, got %s", got)
+	}
+	long := `C:\` + strings.Repeat(`a\`, 150) + "auth.json"
+	if got := LongPath(long); !strings.HasPrefix(got, `\\?\C:\`) {
+		t.Fatalf("long path should be prefixed, got %s", got)
+	}
+	unc := `\\server\share\` + strings.Repeat(`b\`, 150) + "auth.json"
+	if got := LongPath(unc); !strings.HasPrefix(got, `\\?\UNC\server\share\`) {
+		t.Fatalf("long UNC path should use UNC prefix, got %s", got)
+	}
+}
+
+func TestAtomicWriteFileLongPath(t *testing.T) {
+	dir := t.TempDir()
+	for len(dir) < 300 {
+		dir = filepath.Join(dir, strings.Repeat("d", 40))
+	}
+	path := filepath.Join(dir, "auth.json")
+	if err := AtomicWriteFile(path, []byte(`{"k":1}`), 0o600); err != nil {
+		t.Fatalf("atomic write long path: %v", err)
+	}
+	data, exists, err := ReadFileIfExists(path)
+	if err != nil || !exists {
+		t.Fatalf("read long path: exists=%v err=%v", exists, err)
+	}
+	if string(data) != `{"k":1}` {
+		t.Fatalf("content mismatch: %s", data)
+	}
+	if _, err := os.Stat(LongPath(path)); err != nil {
+		t.Fatalf("stat long path: %v", err)
+	}
+}
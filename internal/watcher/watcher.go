@@ -0,0 +1,42 @@
+// Package watcher 借助操作系统的文件变化通知（Linux inotify、macOS kqueue、Windows ReadDirectoryChangesW）
+// 监听单个文件，供自动扫描在文件实际变化时才触发，而不是按固定间隔轮询。
+package watcher
+
+import (
+	"errors"
+	"path/filepath"
+)
+
+// ErrUnsupported 在当前平台没有可用的文件变化通知机制时由 New 返回，调用方应退回轮询。
+var ErrUnsupported = errors.New("file watching is not supported on this platform")
+
+// errAlreadyWatching 在同一个 Watcher 上重复调用 Watch 时返回。
+var errAlreadyWatching = errors.New("watcher is already watching a path")
+
+// Watcher 监听一个文件的变化。
+type Watcher interface {
+	// Watch 开始监听 path：文件被写入、创建、删除、重命名或被整体替换时向返回的通道发送信号。
+	// 监听的是文件所在目录，文件暂不存在时也能收到创建事件，但目录必须存在。
+	// 通道容量为 1，连续的多个事件可能合并为一个信号；监听因 Close 或目录被删除而结束时通道被关闭。
+	// 每个 Watcher 只能监听一个路径。
+	Watch(path string) (<-chan struct{}, error)
+	// Close 停止监听并释放资源，可重复调用。
+	Close() error
+}
+
+// splitPath 返回 path 所在的目录与文件名。
+func splitPath(path string) (dir, name string, err error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", "", err
+	}
+	return filepath.Dir(abs), filepath.Base(abs), nil
+}
+
+// notify 非阻塞地发送信号，接收方尚未取走上一个信号时直接合并。
+func notify(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
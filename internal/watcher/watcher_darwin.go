@@ -0,0 +1,166 @@
+//go:build darwin
+
+package watcher
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// kqueuePollInterval 为等待事件的超时，决定 Close 后读取循环最迟多久退出。
+const kqueuePollInterval = 500 * time.Millisecond
+
+const (
+	// dirFflags：目录项增删或重命名时目录本身收到 NOTE_WRITE，目录被删除或移走时收到 DELETE/RENAME。
+	dirFflags = unix.NOTE_WRITE | unix.NOTE_DELETE | unix.NOTE_RENAME
+	// fileFflags：文件内容或属性变化，以及文件被删除、重命名。
+	fileFflags = unix.NOTE_WRITE | unix.NOTE_EXTEND | unix.NOTE_ATTRIB | unix.NOTE_DELETE | unix.NOTE_RENAME
+)
+
+// kqueueWatcher 同时监听目录与文件本身：kqueue 的目录事件不包含目录内文件的内容写入，
+// 文件被替换（如原子重命名）后需要重新打开才能继续收到写入事件。
+type kqueueWatcher struct {
+	kq        int
+	done      chan struct{}
+	mu        sync.Mutex
+	watching  bool
+	closeOnce sync.Once
+}
+
+// New 返回基于 kqueue 的 Watcher。
+func New() (Watcher, error) {
+	kq, err := unix.Kqueue()
+	if err != nil {
+		return nil, fmt.Errorf("kqueue: %w", err)
+	}
+	unix.CloseOnExec(kq)
+	return &kqueueWatcher{kq: kq, done: make(chan struct{})}, nil
+}
+
+func (w *kqueueWatcher) Watch(path string) (<-chan struct{}, error) {
+	dir, name, err := splitPath(path)
+	if err != nil {
+		return nil, err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.watching {
+		return nil, errAlreadyWatching
+	}
+	dirFd, err := unix.Open(dir, unix.O_EVTONLY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", dir, err)
+	}
+	if err := w.register(dirFd, dirFflags); err != nil {
+		unix.Close(dirFd)
+		return nil, err
+	}
+	w.watching = true
+	ch := make(chan struct{}, 1)
+	go w.loop(dirFd, filepath.Join(dir, name), ch)
+	return ch, nil
+}
+
+func (w *kqueueWatcher) register(fd int, fflags uint32) error {
+	var ev unix.Kevent_t
+	unix.SetKevent(&ev, fd, unix.EVFILT_VNODE, unix.EV_ADD|unix.EV_CLEAR)
+	ev.Fflags = fflags
+	if _, err := unix.Kevent(w.kq, []unix.Kevent_t{ev}, nil, nil); err != nil {
+		return fmt.Errorf("kevent: %w", err)
+	}
+	return nil
+}
+
+// openFile 打开并登记目标文件，文件不存在时返回 -1。
+func (w *kqueueWatcher) openFile(path string) (int, *unix.Stat_t) {
+	fd, err := unix.Open(path, unix.O_EVTONLY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return -1, nil
+	}
+	var st unix.Stat_t
+	if unix.Fstat(fd, &st) != nil || w.register(fd, fileFflags) != nil {
+		unix.Close(fd)
+		return -1, nil
+	}
+	return fd, &st
+}
+
+func (w *kqueueWatcher) loop(dirFd int, path string, ch chan struct{}) {
+	fileFd, fileStat := w.openFile(path)
+	defer func() {
+		if fileFd >= 0 {
+			unix.Close(fileFd)
+		}
+		unix.Close(dirFd)
+		unix.Close(w.kq)
+		close(ch)
+	}()
+	events := make([]unix.Kevent_t, 8)
+	timeout := unix.NsecToTimespec(int64(kqueuePollInterval))
+	for {
+		select {
+		case <-w.done:
+			return
+		default:
+		}
+		n, err := unix.Kevent(w.kq, nil, events, &timeout)
+		if err == unix.EINTR {
+			continue
+		}
+		if err != nil {
+			return
+		}
+		changed := false
+		for _, ev := range events[:n] {
+			fd := int(ev.Ident)
+			switch {
+			case fd == dirFd && ev.Fflags&(unix.NOTE_DELETE|unix.NOTE_RENAME) != 0:
+				notify(ch)
+				return
+			case fd == dirFd:
+				// 目录内有文件增删或重命名，只有目标文件出现、消失或被替换时才算变化。
+				var st unix.Stat_t
+				err := unix.Stat(path, &st)
+				switch {
+				case err != nil && fileFd >= 0:
+					unix.Close(fileFd)
+					fileFd, fileStat = -1, nil
+					changed = true
+				case err == nil && (fileStat == nil || st.Dev != fileStat.Dev || st.Ino != fileStat.Ino):
+					if fileFd >= 0 {
+						unix.Close(fileFd)
+					}
+					fileFd, fileStat = w.openFile(path)
+					changed = true
+				}
+			case fd == fileFd:
+				changed = true
+				if ev.Fflags&(unix.NOTE_DELETE|unix.NOTE_RENAME) != 0 {
+					unix.Close(fileFd)
+					fileFd, fileStat = w.openFile(path)
+				}
+			}
+		}
+		if changed {
+			notify(ch)
+		}
+	}
+}
+
+func (w *kqueueWatcher) Close() error {
+	w.closeOnce.Do(func() {
+		close(w.done)
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		// 尚未开始监听时没有读取循环负责关闭 kqueue。
+		if !w.watching {
+			w.watching = true
+			unix.Close(w.kq)
+		}
+	})
+	return nil
+}
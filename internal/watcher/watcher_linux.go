@@ -0,0 +1,99 @@
+//go:build linux
+
+package watcher
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"sync"
+
+	"golang.org/x/sys/unix"
+)
+
+// inotifyMask 为监听目录时关心的事件：文件内容写入、创建、删除与重命名。
+const inotifyMask = unix.IN_CLOSE_WRITE | unix.IN_MODIFY | unix.IN_ATTRIB | unix.IN_CREATE |
+	unix.IN_DELETE | unix.IN_MOVED_FROM | unix.IN_MOVED_TO | unix.IN_DELETE_SELF | unix.IN_MOVE_SELF
+
+type inotifyWatcher struct {
+	fd int
+	// f 包装非阻塞的 inotify 描述符，Read 由运行时的网络轮询器调度，Close 会唤醒阻塞中的 Read。
+	f         *os.File
+	mu        sync.Mutex
+	watching  bool
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// New 返回基于 inotify 的 Watcher。
+func New() (Watcher, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, fmt.Errorf("inotify init: %w", err)
+	}
+	return &inotifyWatcher{fd: fd, f: os.NewFile(uintptr(fd), "inotify")}, nil
+}
+
+func (w *inotifyWatcher) Watch(path string) (<-chan struct{}, error) {
+	dir, name, err := splitPath(path)
+	if err != nil {
+		return nil, err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.watching {
+		return nil, errAlreadyWatching
+	}
+	if _, err := unix.InotifyAddWatch(w.fd, dir, inotifyMask); err != nil {
+		return nil, fmt.Errorf("inotify watch %s: %w", dir, err)
+	}
+	w.watching = true
+	ch := make(chan struct{}, 1)
+	go w.readLoop(name, ch)
+	return ch, nil
+}
+
+// readLoop 读取 inotify 事件，与 name 相关的事件或事件队列溢出时发送信号；目录被删除或描述符关闭后退出。
+func (w *inotifyWatcher) readLoop(name string, ch chan struct{}) {
+	defer close(ch)
+	buf := make([]byte, 64*(unix.SizeofInotifyEvent+unix.NAME_MAX+1))
+	for {
+		n, err := w.f.Read(buf)
+		if err != nil {
+			return
+		}
+		matched, gone := false, false
+		for off := 0; off+unix.SizeofInotifyEvent <= n; {
+			mask := binary.NativeEndian.Uint32(buf[off+4:])
+			nameLen := int(binary.NativeEndian.Uint32(buf[off+12:]))
+			start := off + unix.SizeofInotifyEvent
+			if start+nameLen > n {
+				break
+			}
+			evName := string(bytes.TrimRight(buf[start:start+nameLen], "\x00"))
+			switch {
+			case mask&unix.IN_Q_OVERFLOW != 0:
+				matched = true
+			case mask&(unix.IN_IGNORED|unix.IN_DELETE_SELF|unix.IN_MOVE_SELF) != 0:
+				gone = true
+			case evName == name:
+				matched = true
+			}
+			off = start + nameLen
+		}
+		if matched || gone {
+			notify(ch)
+		}
+		if gone {
+			return
+		}
+	}
+}
+
+func (w *inotifyWatcher) Close() error {
+	w.closeOnce.Do(func() {
+		w.closeErr = w.f.Close()
+	})
+	return w.closeErr
+}
@@ -0,0 +1,8 @@
+//go:build !linux && !darwin && !windows
+
+package watcher
+
+// New 在不支持的平台上返回 ErrUnsupported。
+func New() (Watcher, error) {
+	return nil, ErrUnsupported
+}
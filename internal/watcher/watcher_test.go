@@ -0,0 +1,122 @@
+package watcher_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"codex-backup-tool/internal/watcher"
+)
+
+func newWatcher(t *testing.T) watcher.Watcher {
+	t.Helper()
+	w, err := watcher.New()
+	if errors.Is(err, watcher.ErrUnsupported) {
+		t.Skip("file watching is not supported on this platform")
+	}
+	if err != nil {
+		t.Fatalf("new watcher: %v", err)
+	}
+	return w
+}
+
+func waitSignal(t *testing.T, ch <-chan struct{}, what string) {
+	t.Helper()
+	select {
+	case _, ok := <-ch:
+		if !ok {
+			t.Fatalf("%s: channel closed unexpectedly", what)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("%s: no signal", what)
+	}
+}
+
+func TestWatchSignalsTargetChanges(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "auth.json")
+	w := newWatcher(t)
+	ch, err := w.Watch(target)
+	if err != nil {
+		t.Fatalf("watch: %v", err)
+	}
+	if _, err := w.Watch(target); err == nil {
+		t.Fatalf("expected error when watching twice")
+	}
+
+	if err := os.WriteFile(target, []byte(`{"token":"a"}`), 0o600); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	waitSignal(t, ch, "create")
+
+	// 原子替换：写入临时文件后重命名覆盖目标。
+	tmp := filepath.Join(dir, "auth.json.tmp")
+	if err := os.WriteFile(tmp, []byte(`{"token":"b"}`), 0o600); err != nil {
+		t.Fatalf("write tmp: %v", err)
+	}
+	drain(ch)
+	if err := os.Rename(tmp, target); err != nil {
+		t.Fatalf("rename: %v", err)
+	}
+	waitSignal(t, ch, "replace")
+
+	drain(ch)
+	if err := os.WriteFile(target, []byte(`{"token":"c"}`), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	waitSignal(t, ch, "write")
+
+	if err := w.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	deadline := time.After(5 * time.Second)
+	for {
+		select {
+		case _, ok := <-ch:
+			if !ok {
+				return
+			}
+		case <-deadline:
+			t.Fatalf("expected channel to close after Close")
+		}
+	}
+}
+
+func TestWatchIgnoresOtherFiles(t *testing.T) {
+	dir := t.TempDir()
+	w := newWatcher(t)
+	defer w.Close()
+	ch, err := w.Watch(filepath.Join(dir, "auth.json"))
+	if err != nil {
+		t.Fatalf("watch: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "other.json"), []byte("{}"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	select {
+	case <-ch:
+		t.Fatalf("unexpected signal for an unrelated file")
+	case <-time.After(300 * time.Millisecond):
+	}
+}
+
+func TestWatchMissingDirectory(t *testing.T) {
+	w := newWatcher(t)
+	defer w.Close()
+	if _, err := w.Watch(filepath.Join(t.TempDir(), "missing", "auth.json")); err == nil {
+		t.Fatalf("expected error for a missing directory")
+	}
+}
+
+// drain 取走此前事件残留的信号，避免误判下一步操作。
+func drain(ch <-chan struct{}) {
+	for {
+		select {
+		case <-ch:
+		case <-time.After(100 * time.Millisecond):
+			return
+		}
+	}
+}
@@ -0,0 +1,153 @@
+//go:build windows
+
+package watcher
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"sync"
+	"unicode/utf16"
+
+	"golang.org/x/sys/windows"
+)
+
+// notifyFilter 为监听目录时关心的变化：文件名（创建、删除、重命名）、大小与最后写入时间。
+const notifyFilter = windows.FILE_NOTIFY_CHANGE_FILE_NAME | windows.FILE_NOTIFY_CHANGE_SIZE |
+	windows.FILE_NOTIFY_CHANGE_LAST_WRITE | windows.FILE_NOTIFY_CHANGE_CREATION
+
+// rdcWatcher 以重叠 I/O 调用 ReadDirectoryChangesW，同时等待完成事件与 quit 事件，使 Close 能中断等待。
+type rdcWatcher struct {
+	quit windows.Handle
+	// exited 在读取循环退出后关闭，Close 等它关闭后才释放 quit。
+	exited    chan struct{}
+	mu        sync.Mutex
+	watching  bool
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// rdcState 保存读取循环使用的句柄与缓冲区；内核异步写入 ov 与 buf，二者必须分配在堆上。
+type rdcState struct {
+	dir windows.Handle
+	ov  windows.Overlapped
+	buf []byte
+}
+
+// New 返回基于 ReadDirectoryChangesW 的 Watcher。
+func New() (Watcher, error) {
+	quit, err := windows.CreateEvent(nil, 1, 0, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create event: %w", err)
+	}
+	return &rdcWatcher{quit: quit, exited: make(chan struct{})}, nil
+}
+
+func (w *rdcWatcher) Watch(path string) (<-chan struct{}, error) {
+	dir, name, err := splitPath(path)
+	if err != nil {
+		return nil, err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.watching {
+		return nil, errAlreadyWatching
+	}
+	p, err := windows.UTF16PtrFromString(dir)
+	if err != nil {
+		return nil, err
+	}
+	h, err := windows.CreateFile(p, windows.FILE_LIST_DIRECTORY,
+		windows.FILE_SHARE_READ|windows.FILE_SHARE_WRITE|windows.FILE_SHARE_DELETE, nil,
+		windows.OPEN_EXISTING, windows.FILE_FLAG_BACKUP_SEMANTICS|windows.FILE_FLAG_OVERLAPPED, 0)
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", dir, err)
+	}
+	ev, err := windows.CreateEvent(nil, 1, 0, nil)
+	if err != nil {
+		windows.CloseHandle(h)
+		return nil, fmt.Errorf("create event: %w", err)
+	}
+	st := &rdcState{dir: h, buf: make([]byte, 64<<10)}
+	st.ov.HEvent = ev
+	w.watching = true
+	ch := make(chan struct{}, 1)
+	go w.loop(st, name, ch)
+	return ch, nil
+}
+
+func (w *rdcWatcher) loop(st *rdcState, name string, ch chan struct{}) {
+	defer func() {
+		windows.CloseHandle(st.ov.HEvent)
+		windows.CloseHandle(st.dir)
+		close(ch)
+		close(w.exited)
+	}()
+	for {
+		if err := windows.ResetEvent(st.ov.HEvent); err != nil {
+			return
+		}
+		if err := windows.ReadDirectoryChanges(st.dir, &st.buf[0], uint32(len(st.buf)), false, notifyFilter, nil, &st.ov, 0); err != nil {
+			// 目录被删除等情况下无法继续监听。
+			notify(ch)
+			return
+		}
+		which, err := windows.WaitForMultipleObjects([]windows.Handle{st.ov.HEvent, w.quit}, false, windows.INFINITE)
+		var n uint32
+		if err != nil || which != windows.WAIT_OBJECT_0 {
+			// 收到 quit：取消未完成的读取并等待取消完成，之后才能释放缓冲区。
+			windows.CancelIoEx(st.dir, &st.ov)
+			windows.GetOverlappedResult(st.dir, &st.ov, &n, true)
+			return
+		}
+		if err := windows.GetOverlappedResult(st.dir, &st.ov, &n, false); err != nil {
+			notify(ch)
+			return
+		}
+		// n 为 0 表示缓冲区溢出、变化记录被丢弃，按有变化处理。
+		if n == 0 || matchesName(st.buf[:n], name) {
+			notify(ch)
+		}
+	}
+}
+
+// matchesName 遍历 FILE_NOTIFY_INFORMATION 记录，判断是否有与 name 同名（不区分大小写）的文件发生变化。
+func matchesName(buf []byte, name string) bool {
+	for off := 0; off+12 <= len(buf); {
+		next := int(binary.LittleEndian.Uint32(buf[off:]))
+		nameLen := int(binary.LittleEndian.Uint32(buf[off+8:]))
+		start := off + 12
+		if start+nameLen > len(buf) {
+			return false
+		}
+		units := make([]uint16, nameLen/2)
+		for i := range units {
+			units[i] = binary.LittleEndian.Uint16(buf[start+2*i:])
+		}
+		if strings.EqualFold(string(utf16.Decode(units)), name) {
+			return true
+		}
+		if next == 0 {
+			return false
+		}
+		off += next
+	}
+	return false
+}
+
+func (w *rdcWatcher) Close() error {
+	w.closeOnce.Do(func() {
+		w.mu.Lock()
+		watching := w.watching
+		w.watching = true
+		w.mu.Unlock()
+		if watching {
+			w.closeErr = windows.SetEvent(w.quit)
+			<-w.exited
+		}
+		if err := windows.CloseHandle(w.quit); err != nil && w.closeErr == nil {
+			w.closeErr = err
+		}
+	})
+	return w.closeErr
+}
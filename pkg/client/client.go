@@ -0,0 +1,259 @@
+// Package client 提供访问备份工具 REST API 的 Go 客户端。
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"codex-backup-tool/internal/core"
+)
+
+// APIError 表示服务端返回的业务错误。
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("api error %d: %s", e.StatusCode, e.Message)
+}
+
+// Client 封装 net/http.Client，提供带类型的 API 调用。
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	maxRetries int
+	backoff    time.Duration
+	token      string
+}
+
+// Option 用于定制 Client。
+type Option func(*Client)
+
+// WithHTTPClient 替换底层 http.Client。
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.httpClient = hc }
+}
+
+// WithRetry 设置网络错误或 502/503/504 时的最大重试次数与初始退避时间（每次翻倍）。
+// 只有 GET 与 HEAD 请求会自动重试：创建、还原、删除等写操作失败时可能已在服务端生效，重试可能重复执行，由调用方决定是否重发。
+func WithRetry(maxRetries int, backoff time.Duration) Option {
+	return func(c *Client) {
+		c.maxRetries = maxRetries
+		c.backoff = backoff
+	}
+}
+
+// WithToken 设置 API 令牌，请求时以 Authorization: Bearer 头发送。
+func WithToken(token string) Option {
+	return func(c *Client) { c.token = token }
+}
+
+// New 创建客户端，baseURL 形如 http://localhost:8080。
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		maxRetries: 2,
+		backoff:    200 * time.Millisecond,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Status 获取目标文件状态。
+func (c *Client) Status() (*core.StatusInfo, error) {
+	return c.StatusContext(context.Background())
+}
+
+// StatusContext 同 Status，支持 context 取消。
+func (c *Client) StatusContext(ctx context.Context) (*core.StatusInfo, error) {
+	var out core.StatusInfo
+	if err := c.do(ctx, http.MethodGet, "/api/status", nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListBackups 列出全部备份（按创建时间倒序）。
+func (c *Client) ListBackups() ([]core.BackupItem, error) {
+	return c.ListBackupsContext(context.Background())
+}
+
+// ListBackupsContext 同 ListBackups，支持 context 取消。
+func (c *Client) ListBackupsContext(ctx context.Context) ([]core.BackupItem, error) {
+	var out []core.BackupItem
+	if err := c.do(ctx, http.MethodGet, "/api/backups", nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CreateBackup 手动创建备份，remark 为 nil 时由服务端生成备注。
+func (c *Client) CreateBackup(remark *string) (*core.ScanResult, error) {
+	return c.CreateBackupContext(context.Background(), remark)
+}
+
+// CreateBackupContext 同 CreateBackup，支持 context 取消。
+func (c *Client) CreateBackupContext(ctx context.Context, remark *string) (*core.ScanResult, error) {
+	body := map[string]*string{}
+	if remark != nil {
+		body["remark"] = remark
+	}
+	var out core.ScanResult
+	if err := c.do(ctx, http.MethodPost, "/api/backups", body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// RestoreBackup 将备份还原为目标文件。
+func (c *Client) RestoreBackup(id string) error {
+	return c.RestoreBackupContext(context.Background(), id)
+}
+
+// RestoreBackupContext 同 RestoreBackup，支持 context 取消。
+func (c *Client) RestoreBackupContext(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodPost, "/api/backups/"+url.PathEscape(id)+"/restore", nil, nil)
+}
+
+// DeleteBackup 删除备份。
+func (c *Client) DeleteBackup(id string) error {
+	return c.DeleteBackupContext(context.Background(), id)
+}
+
+// DeleteBackupContext 同 DeleteBackup，支持 context 取消。
+func (c *Client) DeleteBackupContext(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/api/backups/"+url.PathEscape(id), nil, nil)
+}
+
+// UpdateRemark 修改备份备注。
+func (c *Client) UpdateRemark(id, remark string) (*core.BackupItem, error) {
+	return c.UpdateRemarkContext(context.Background(), id, remark)
+}
+
+// UpdateRemarkContext 同 UpdateRemark，支持 context 取消。
+func (c *Client) UpdateRemarkContext(ctx context.Context, id, remark string) (*core.BackupItem, error) {
+	var out core.BackupItem
+	body := map[string]string{"remark": remark}
+	if err := c.do(ctx, http.MethodPatch, "/api/backups/"+url.PathEscape(id)+"/remark", body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ClearRemark 清除备份的自定义备注，服务端改为按创建时间生成的默认备注。
+func (c *Client) ClearRemark(id string) (*core.BackupItem, error) {
+	return c.ClearRemarkContext(context.Background(), id)
+}
+
+// ClearRemarkContext 同 ClearRemark，支持 context 取消。
+func (c *Client) ClearRemarkContext(ctx context.Context, id string) (*core.BackupItem, error) {
+	var out core.BackupItem
+	body := map[string]bool{"clear": true}
+	if err := c.do(ctx, http.MethodPatch, "/api/backups/"+url.PathEscape(id)+"/remark", body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+type envelope struct {
+	Ok    bool            `json:"ok"`
+	Data  json.RawMessage `json:"data"`
+	Error string          `json:"error"`
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body, out any) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		payload, err = json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("marshal request: %w", err)
+		}
+	}
+	backoff := c.backoff
+	for attempt := 0; ; attempt++ {
+		err := c.once(ctx, method, path, payload, out)
+		if err == nil || attempt >= c.maxRetries || !retryableMethod(method) || !retryable(err) {
+			return err
+		}
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
+
+func (c *Client) once(ctx context.Context, method, path string, payload []byte, out any) error {
+	var reader io.Reader
+	if payload != nil {
+		reader = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return fmt.Errorf("build request: %w", err)
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var env envelope
+	if err := json.NewDecoder(resp.Body).Decode(&env); err != nil {
+		return &APIError{StatusCode: resp.StatusCode, Message: fmt.Sprintf("invalid response: %v", err)}
+	}
+	if resp.StatusCode >= 300 || !env.Ok {
+		msg := env.Error
+		if msg == "" {
+			msg = http.StatusText(resp.StatusCode)
+		}
+		return &APIError{StatusCode: resp.StatusCode, Message: msg}
+	}
+	if out != nil && len(env.Data) > 0 {
+		if err := json.Unmarshal(env.Data, out); err != nil {
+			return fmt.Errorf("decode data: %w", err)
+		}
+	}
+	return nil
+}
+
+// retryableMethod 判断请求方法是否可以自动重试，只有不修改服务端状态的 GET 与 HEAD 可以。
+func retryableMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead
+}
+
+func retryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.StatusCode {
+		case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+	return true
+}
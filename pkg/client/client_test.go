@@ -0,0 +1,182 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"codex-backup-tool/internal/api"
+	"codex-backup-tool/internal/core"
+	"codex-backup-tool/pkg/client"
+)
+
+func newTestServer(t *testing.T) (*httptest.Server, core.Config) {
+	t.Helper()
+	srv, cfg, _ := newTestServerWithService(t)
+	return srv, cfg
+}
+
+func newTestServerWithService(t *testing.T) (*httptest.Server, core.Config, *core.Service) {
+	t.Helper()
+	base := t.TempDir()
+	dataDir := filepath.Join(base, "data")
+	cfg := core.Config{
+		TargetPath:   filepath.Join(base, "codex", "auth.json"),
+		DataDir:      dataDir,
+		BackupsDir:   filepath.Join(dataDir, "backups"),
+		IndexPath:    filepath.Join(dataDir, "index.json"),
+		ScanInterval: time.Minute,
+	}
+	svc, err := core.NewService(cfg, core.NewJSONLogger(io.Discard, slog.LevelInfo))
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	t.Cleanup(svc.Stop)
+	mux := http.NewServeMux()
+	handlers := api.New(svc)
+	handlers.Register(mux)
+	srv := httptest.NewServer(handlers.RequireAuth(mux))
+	t.Cleanup(srv.Close)
+	return srv, cfg, svc
+}
+
+func TestClientRoundTrip(t *testing.T) {
+	srv, cfg := newTestServer(t)
+	if err := os.MkdirAll(filepath.Dir(cfg.TargetPath), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(cfg.TargetPath, []byte(`{"token":"alpha"}`), 0o600); err != nil {
+		t.Fatalf("write target: %v", err)
+	}
+	c := client.New(srv.URL)
+
+	status, err := c.Status()
+	if err != nil || !status.Exists {
+		t.Fatalf("status: %+v %v", status, err)
+	}
+	remark := "first"
+	res, err := c.CreateBackup(&remark)
+	if err != nil || !res.Created {
+		t.Fatalf("create backup: %+v %v", res, err)
+	}
+	items, err := c.ListBackups()
+	if err != nil || len(items) != 1 {
+		t.Fatalf("list backups: %d %v", len(items), err)
+	}
+	item, err := c.UpdateRemark(items[0].ID, "renamed")
+	if err != nil || item.Remark != "renamed" {
+		t.Fatalf("update remark: %+v %v", item, err)
+	}
+	if err := c.RestoreBackup(items[0].ID); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if err := c.DeleteBackup(items[0].ID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	var apiErr *client.APIError
+	if err := c.DeleteBackup(items[0].ID); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 APIError, got %v", err)
+	}
+}
+
+func TestClientRetriesUnavailable(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			io.WriteString(w, `{"ok":false,"error":"busy"}`)
+			return
+		}
+		io.WriteString(w, `{"ok":true,"data":[]}`)
+	}))
+	defer srv.Close()
+
+	c := client.New(srv.URL, client.WithRetry(3, time.Millisecond))
+	if _, err := c.ListBackups(); err != nil {
+		t.Fatalf("expected success after retries: %v", err)
+	}
+	if got := calls.Load(); got != 3 {
+		t.Fatalf("expected 3 calls, got %d", got)
+	}
+}
+
+func TestClientDoesNotRetryWrites(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadGateway)
+		io.WriteString(w, `{"ok":false,"error":"bad gateway"}`)
+	}))
+	defer srv.Close()
+
+	c := client.New(srv.URL, client.WithRetry(3, time.Millisecond))
+	var apiErr *client.APIError
+	if _, err := c.CreateBackup(nil); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadGateway {
+		t.Fatalf("expected 502 APIError, got %v", err)
+	}
+	if err := c.RestoreBackup("id"); !errors.As(err, &apiErr) {
+		t.Fatalf("expected APIError, got %v", err)
+	}
+	if err := c.DeleteBackup("id"); !errors.As(err, &apiErr) {
+		t.Fatalf("expected APIError, got %v", err)
+	}
+	if got := calls.Load(); got != 3 {
+		t.Fatalf("expected one call per write, got %d", got)
+	}
+
+	// 网络错误同样不重试写操作。
+	srv.Close()
+	start := time.Now()
+	if _, err := client.New(srv.URL, client.WithRetry(3, time.Hour)).CreateBackup(nil); err == nil {
+		t.Fatalf("expected network error")
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Fatalf("write was retried after a network error")
+	}
+}
+
+func TestClientRespectsContextCancel(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		io.WriteString(w, `{"ok":false,"error":"busy"}`)
+	}))
+	defer srv.Close()
+
+	c := client.New(srv.URL, client.WithRetry(10, time.Hour))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := c.StatusContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Fatalf("cancellation was not respected")
+	}
+}
+
+func TestClientSendsToken(t *testing.T) {
+	srv, _, svc := newTestServerWithService(t)
+	secret, _, err := svc.Tokens().Create("ci", nil)
+	if err != nil {
+		t.Fatalf("create token: %v", err)
+	}
+
+	_, err = client.New(srv.URL).Status()
+	var apiErr *client.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without token, got %v", err)
+	}
+	if _, err := client.New(srv.URL, client.WithToken(secret)).Status(); err != nil {
+		t.Fatalf("status with token: %v", err)
+	}
+}
@@ -14,6 +14,7 @@ const els = {
   hash: document.getElementById('status-hash'),
   latestFingerprint: document.getElementById('status-latest-fp'),
   interval: document.getElementById('status-interval'),
+  disk: document.getElementById('status-disk'),
   remarkInput: document.getElementById('remark-input'),
   scanBtn: document.getElementById('scan-btn'),
   backupBtn: document.getElementById('backup-btn'),
@@ -24,10 +25,27 @@ const els = {
   metricCount: document.getElementById('metric-count'),
   metricLatest: document.getElementById('metric-latest'),
   metricAuto: document.getElementById('metric-auto'),
+  logoutBtn: document.getElementById('logout-btn'),
+  loginDialog: document.getElementById('login-dialog'),
+  loginForm: document.getElementById('login-form'),
+  loginPassword: document.getElementById('login-password'),
+  loginError: document.getElementById('login-error'),
 };
 
-async function apiRequest(path, { method = 'GET', body, allowOkFalse = false } = {}) {
+const TOKEN_STORAGE_KEY = 'codexBackupApiToken';
+
+// csrfToken 为会话登录时服务端下发的令牌，写请求需放在 X-CSRF-Token 头中。
+let csrfToken = '';
+
+async function apiRequest(path, { method = 'GET', body, allowOkFalse = false, retried = false } = {}) {
   const options = { method, headers: { 'Content-Type': 'application/json' } };
+  const token = localStorage.getItem(TOKEN_STORAGE_KEY);
+  if (token) {
+    options.headers.Authorization = `Bearer ${token}`;
+  }
+  if (csrfToken && method !== 'GET') {
+    options.headers['X-CSRF-Token'] = csrfToken;
+  }
   if (body !== undefined) {
     options.body = JSON.stringify(body);
   }
@@ -38,6 +56,15 @@ async function apiRequest(path, { method = 'GET', body, allowOkFalse = false } =
   } catch (err) {
     throw new Error('服务器返回非 JSON 数据');
   }
+  if (resp.status === 401 && token) {
+    localStorage.removeItem(TOKEN_STORAGE_KEY);
+  }
+  if (resp.status === 401 && !retried && (await authenticate(json.data))) {
+    return apiRequest(path, { method, body, allowOkFalse, retried: true });
+  }
+  if (resp.status === 403 && json.code === 'CSRF_FAILED' && !retried && (await refreshCsrfToken())) {
+    return apiRequest(path, { method, body, allowOkFalse, retried: true });
+  }
   if (!resp.ok) {
     const msg = json.error || resp.statusText;
     const error = new Error(msg);
@@ -54,6 +81,78 @@ async function apiRequest(path, { method = 'GET', body, allowOkFalse = false } =
   return json;
 }
 
+let loginPending = null;
+
+// authenticate 在收到 401 后引导用户登录：启用密码登录时弹出登录框，否则提示输入 API 令牌。
+async function authenticate(data) {
+  if (data && data.password_login) {
+    if (!loginPending) {
+      loginPending = showLoginDialog().finally(() => {
+        loginPending = null;
+      });
+    }
+    return loginPending;
+  }
+  const input = window.prompt('服务已启用令牌认证，请输入 API 令牌：');
+  if (input && input.trim()) {
+    localStorage.setItem(TOKEN_STORAGE_KEY, input.trim());
+    return true;
+  }
+  return false;
+}
+
+async function refreshCsrfToken() {
+  const resp = await fetch('/api/csrf');
+  const json = await resp.json().catch(() => ({}));
+  csrfToken = (resp.ok && json.data && json.data.csrf_token) || '';
+  return csrfToken !== '';
+}
+
+function showLoginDialog() {
+  return new Promise((resolve) => {
+    els.loginError.textContent = '';
+    els.loginPassword.value = '';
+    els.loginDialog.showModal();
+    els.loginPassword.focus();
+    const onSubmit = async (event) => {
+      event.preventDefault();
+      const resp = await fetch('/api/login', {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({ password: els.loginPassword.value }),
+      });
+      if (resp.ok) {
+        const json = await resp.json().catch(() => ({}));
+        csrfToken = (json.data && json.data.csrf_token) || '';
+        cleanup();
+        els.loginDialog.close();
+        els.logoutBtn.hidden = false;
+        resolve(true);
+        return;
+      }
+      const json = await resp.json().catch(() => ({}));
+      els.loginError.textContent = json.error || '登录失败';
+    };
+    const onCancel = () => {
+      cleanup();
+      resolve(false);
+    };
+    const cleanup = () => {
+      els.loginForm.removeEventListener('submit', onSubmit);
+      els.loginDialog.removeEventListener('cancel', onCancel);
+    };
+    els.loginForm.addEventListener('submit', onSubmit);
+    els.loginDialog.addEventListener('cancel', onCancel);
+  });
+}
+
+async function logout() {
+  await fetch('/api/logout', { method: 'POST', headers: { 'X-CSRF-Token': csrfToken } });
+  csrfToken = '';
+  els.logoutBtn.hidden = true;
+  showToast('已退出登录', 'success');
+}
+
 function showToast(message, type = 'info', timeout = 3600) {
   const toast = document.createElement('div');
   toast.className = `toast ${type === 'error' ? 'error' : type === 'success' ? 'success' : ''}`;
@@ -110,6 +209,13 @@ function renderStatus() {
   els.badge.className = `badge ${exists ? 'badge-success' : 'badge-danger'}`;
   els.size.textContent = exists ? formatBytes(status.size) : '-';
   els.size.title = exists ? `${status.size} 字节` : '';
+  const warnings = Array.isArray(status.warnings) ? status.warnings : [];
+  if (warnings.length) {
+    els.size.title = warnings.join('\n');
+    els.size.className = 'text-danger';
+  } else {
+    els.size.className = '';
+  }
   els.mtime.textContent = exists && status.mod_time ? formatDate(status.mod_time) : '-';
   els.fingerprint.textContent = exists && status.fingerprint ? status.fingerprint : '-';
   els.fingerprint.title = status.fingerprint || '';
@@ -129,6 +235,51 @@ function renderStatus() {
     els.interval.textContent = '已关闭';
     els.interval.title = '自动刷新已禁用';
   }
+  if (status.last_scan_at) {
+    const outcome = status.last_scan_error || status.last_scan_reason_code || '已备份';
+    const trigger = status.last_scan_trigger === 'auto' ? '自动' : '手动';
+    let hint = `上次${trigger}检查 ${formatDate(status.last_scan_at)}：${outcome}`;
+    if (status.next_scan_eta_seconds >= 0) {
+      hint += `\n约 ${status.next_scan_eta_seconds} 秒后再次检查`;
+    }
+    els.interval.title = hint;
+  }
+  if (typeof status.disk_free_bytes === 'number') {
+    els.disk.textContent = formatBytes(status.disk_free_bytes);
+    els.disk.title = `预留 ${formatBytes(status.disk_reserve_bytes)}`;
+    els.disk.className = status.disk_low ? 'text-danger' : '';
+  } else {
+    els.disk.textContent = '-';
+    els.disk.title = '当前平台无法获取剩余空间';
+    els.disk.className = '';
+  }
+  applyCapabilities();
+}
+
+// 操作所需的能力，任一被服务端配置禁用时置灰对应按钮。
+const ACTION_CAPABILITIES = {
+  restore: ['restore', 'target_write'],
+  delete: ['delete'],
+};
+const CODEX_CAPABILITIES = ['codex_login', 'target_write'];
+
+function disabledCapability(names) {
+  const disabled = state.status?.disabled_capabilities || [];
+  return names.find((name) => disabled.includes(name)) || '';
+}
+
+function toggleCapability(button, names) {
+  const cap = disabledCapability(names);
+  button.disabled = !!cap;
+  button.title = cap ? `已在配置中禁用：${cap}` : '';
+}
+
+function applyCapabilities() {
+  toggleCapability(els.codexBtn, CODEX_CAPABILITIES);
+  els.tbody.querySelectorAll('button[data-action]').forEach((btn) => {
+    const names = ACTION_CAPABILITIES[btn.dataset.action];
+    if (names) toggleCapability(btn, names);
+  });
 }
 
 async function loadBackups({ silent = false } = {}) {
@@ -215,6 +366,7 @@ function renderBackups() {
 
     tbody.appendChild(tr);
   });
+  applyCapabilities();
 }
 
 function renderMetrics() {
@@ -318,6 +470,7 @@ async function handleCodexLogin() {
     if (output.stderr) console.warn('[codex stderr]\n' + output.stderr);
   }
   setButtonLoading(els.codexBtn, false);
+  applyCapabilities();
 }
 
 
@@ -347,12 +500,13 @@ els.tbody.addEventListener('click', async (event) => {
 async function handleEditRemark(btn) {
   const id = btn.dataset.id;
   const current = btn.dataset.remark || '';
-  const next = window.prompt('请输入新的备注（留空表示清除备注）', current);
+  const next = window.prompt('请输入新的备注（留空表示恢复默认备注）', current);
   if (next === null) return;
   const trimmed = next.trim();
+  const body = trimmed ? { remark: trimmed } : { clear: true };
   btn.disabled = true;
   try {
-    await apiRequest(`/api/backups/${id}/remark`, { method: 'PATCH', body: { remark: trimmed } });
+    await apiRequest(`/api/backups/${id}/remark`, { method: 'PATCH', body });
     showToast('备注已更新', 'success');
     await refreshAll();
   } catch (err) {
@@ -422,9 +576,15 @@ function truncate(str, length) {
 }
 
 async function init() {
+  if (await refreshCsrfToken().catch(() => false)) {
+    els.logoutBtn.hidden = false;
+  }
   els.scanBtn.addEventListener('click', handleScan);
   els.backupBtn.addEventListener('click', handleBackup);
   els.codexBtn.addEventListener('click', handleCodexLogin);
+  els.logoutBtn.addEventListener('click', () => {
+    logout().catch((err) => showToast(`退出登录失败：${err.message}`, 'error'));
+  });
   els.refreshBtn.addEventListener('click', async () => {
     await refreshAll();
     showToast('状态已刷新', 'success');